@@ -28,6 +28,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors/builtin"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/power"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/blockstore"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
@@ -125,6 +126,11 @@ type FullNode interface {
 	// If oldmsgskip is set, messages from before the requested roots are also not included.
 	ChainExport(ctx context.Context, nroots abi.ChainEpoch, oldmsgskip bool, tsk types.TipSetKey) (<-chan []byte, error)
 
+	// ChainBlockstoreCacheStats returns the hit and miss counters of the
+	// in-memory read cache of the chain blockstore. It fails if the cache is
+	// not enabled in the Chainstore config section.
+	ChainBlockstoreCacheStats(context.Context) (blockstore.ReadCacheStats, error)
+
 	// MethodGroup: Beacon
 	// The Beacon method group contains methods for interacting with the random beacon (DRAND)
 
@@ -347,6 +353,10 @@ type FullNode interface {
 	StateListMessages(ctx context.Context, match *MessageMatch, tsk types.TipSetKey, toht abi.ChainEpoch) ([]cid.Cid, error)
 	// StateDecodeParams attempts to decode the provided params, based on the recipient actor address and method number.
 	StateDecodeParams(ctx context.Context, toAddr address.Address, method abi.MethodNum, params []byte, tsk types.TipSetKey) (interface{}, error)
+	// StateDecodeMessages decodes the params, and return values when provided, of
+	// a batch of messages, based on the code of the recipient actors at the given
+	// tipset. Messages which fail to decode have Error set.
+	StateDecodeMessages(ctx context.Context, msgs []DecodeMessage, tsk types.TipSetKey) ([]DecodedMessage, error)
 
 	// StateNetworkName returns the name of the network the node is synced to
 	StateNetworkName(context.Context) (dtypes.NetworkName, error)
@@ -375,6 +385,12 @@ type FullNode interface {
 	StateMinerPreCommitDepositForPower(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)
 	// StateMinerInitialPledgeCollateral returns the initial pledge collateral for the specified miner's sector
 	StateMinerInitialPledgeCollateral(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)
+	// StateMinerTerminationEstimate compares terminating the given sectors now
+	// with letting them expire: it returns the termination fees, the pledge
+	// locked for the sectors, and the block rewards the sectors are expected to
+	// earn until their expiration. If the sectors bitfield is nil, all sectors
+	// are included.
+	StateMinerTerminationEstimate(context.Context, address.Address, *bitfield.BitField, types.TipSetKey) (*TerminationEstimate, error)
 	// StateMinerAvailableBalance returns the portion of a miner's balance that can be withdrawn or spent
 	StateMinerAvailableBalance(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)
 	// StateMinerSectorAllocated checks if a sector is allocated
@@ -708,6 +724,34 @@ type MinerPower struct {
 	HasMinPower bool
 }
 
+// TerminationEstimate is the economic outcome of terminating a set of sectors
+// at Epoch, summed over the sectors
+type TerminationEstimate struct {
+	Epoch   abi.ChainEpoch
+	Sectors []SectorTerminationEstimate
+
+	TerminationFee abi.TokenAmount
+	InitialPledge  abi.TokenAmount
+	ExpectedReward abi.TokenAmount
+}
+
+type SectorTerminationEstimate struct {
+	SectorNumber abi.SectorNumber
+	Expiration   abi.ChainEpoch
+
+	// TerminationFee is burned from the miner's locked funds when the sector
+	// is terminated now; there is no fee when the sector expires
+	TerminationFee abi.TokenAmount
+	// InitialPledge is unlocked when the sector expires; when terminating,
+	// the termination fee is taken from it
+	InitialPledge abi.TokenAmount
+	// ExpectedReward is the block reward the sector is expected to earn until
+	// its expiration, at the day reward recorded on the sector at activation;
+	// forgone when terminating. The termination fee only covers a capped
+	// number of days of that reward.
+	ExpectedReward abi.TokenAmount
+}
+
 type QueryOffer struct {
 	Err string
 
@@ -774,6 +818,21 @@ type InvocResult struct {
 	Duration       time.Duration
 }
 
+type DecodeMessage struct {
+	To     address.Address
+	Method abi.MethodNum
+	Params []byte
+	Return []byte // not decoded when empty
+}
+
+type DecodedMessage struct {
+	Actor  string // actor name, e.g. fil/2/storageminer
+	Method string
+	Params interface{}
+	Return interface{}
+	Error  string
+}
+
 type MethodCall struct {
 	types.MessageReceipt
 	Error string
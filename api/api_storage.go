@@ -21,6 +21,8 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
 
 // StorageMiner is a low-level interface to the Filecoin network storage miner node
@@ -49,6 +51,10 @@ type StorageMiner interface {
 	// List sectors in particular states
 	SectorsListInStates(context.Context, []SectorState) ([]abi.SectorNumber, error)
 
+	// SectorsPackingReport lists the sectors open for deals, and which of them
+	// pieces of various sizes would be added to
+	SectorsPackingReport(ctx context.Context) (sealiface.PackingReport, error)
+
 	SectorsRefs(context.Context) (map[string][]SealedRef, error)
 
 	// SectorStartSealing can be called on sectors in Empty or WaitDeals states
@@ -67,6 +73,30 @@ type StorageMiner interface {
 	SectorsUpdate(context.Context, abi.SectorNumber, SectorState) error
 	SectorRemove(context.Context, abi.SectorNumber) error
 	SectorMarkForUpgrade(ctx context.Context, id abi.SectorNumber) error
+	// SectorCommitPending lists the ProveCommit messages waiting for operator
+	// approval, when the ApproveCommits sealing option is enabled
+	SectorCommitPending(ctx context.Context) ([]sealiface.PendingCommit, error)
+	// SectorCommitApprove lets the pending ProveCommit message of a sector be
+	// sent to the chain
+	SectorCommitApprove(ctx context.Context, id abi.SectorNumber) error
+	// SectorCommitReject drops the pending ProveCommit message of a sector,
+	// and has the sector compute its proof again
+	SectorCommitReject(ctx context.Context, id abi.SectorNumber) error
+	// SectorAddPieceToAny adds a deal piece to any sector accepting deals.
+	// The piece data is downloaded from pieceURL by the worker the piece is
+	// assigned to, so in split markets/miner deployments it doesn't need to
+	// be staged on the miner node
+	SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, pieceURL string, deal PieceDealInfo) (SectorOffset, error)
+	// SectorUnsealRange starts unsealing a byte range of a sector in the
+	// background. The returned job ID can be polled with SectorUnsealStatus;
+	// once the job is done, the range can be downloaded from /unsealed/{job-id}
+	SectorUnsealRange(ctx context.Context, sid abi.SectorNumber, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (uuid.UUID, error)
+	// SectorUnsealStatus returns the progress of a SectorUnsealRange job
+	SectorUnsealStatus(ctx context.Context, id uuid.UUID) (storiface.UnsealJob, error)
+	// SectorSealRecord returns the proof parameters a sector was sealed with,
+	// and the workers, with their software versions, which ran its sealing
+	// calls
+	SectorSealRecord(ctx context.Context, sid abi.SectorNumber) (storiface.SealRecord, error)
 
 	StorageList(ctx context.Context) (map[stores.ID][]stores.Decl, error)
 	StorageLocal(ctx context.Context) (map[stores.ID]string, error)
@@ -76,11 +106,32 @@ type StorageMiner interface {
 	WorkerConnect(context.Context, string) error
 	WorkerStats(context.Context) (map[uuid.UUID]storiface.WorkerStats, error)
 	WorkerJobs(context.Context) (map[uuid.UUID][]storiface.WorkerJob, error)
+	// WorkerTasksChanged tells the scheduler that a connected worker changed
+	// the task types it accepts, so that it schedules again right away
+	WorkerTasksChanged(context.Context) error
 	storiface.WorkerReturn
 
 	// SealingSchedDiag dumps internal sealing scheduler state
 	SealingSchedDiag(ctx context.Context, doSched bool) (interface{}, error)
 	SealingAbort(ctx context.Context, call storiface.CallID) error
+	// SealingAbortRemote aborts a call pending on an external sealing
+	// appliance: the appliance is asked to cancel it, and a result it still
+	// reports for the call is discarded
+	SealingAbortRemote(ctx context.Context, call storiface.CallID) error
+	// SealingCallLogs streams the output the worker running a call wrote while
+	// the call was running, e.g. the proofs library logs. Output of running
+	// calls is streamed until the call finishes
+	SealingCallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error)
+	// SealingSetThrottle caps task starts across all workers, e.g. when the
+	// datacenter is short on power or cooling. Running tasks are not
+	// interrupted, held back tasks stay queued until the limits allow them
+	SealingSetThrottle(ctx context.Context, limits storiface.SchedThrottle) error
+	// SealingGetThrottle returns the current limits set with SealingSetThrottle
+	SealingGetThrottle(ctx context.Context) (storiface.SchedThrottle, error)
+	// SealingStats returns long-term sealing statistics: job phase duration
+	// histograms, call outcomes by worker, and hourly throughput. Unlike
+	// metrics, they are kept across miner restarts
+	SealingStats(ctx context.Context) (storiface.SealingStats, error)
 
 	stores.SectorIndex
 
@@ -131,6 +182,44 @@ type StorageMiner interface {
 	CreateBackup(ctx context.Context, fpath string) error
 
 	CheckProvable(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, expensive bool) (map[abi.SectorNumber]string, error)
+
+	// ProvingGetConfig returns the window PoSt configuration, including
+	// per-deadline overrides
+	ProvingGetConfig(ctx context.Context) (dtypes.ProvingConfig, error)
+	// ProvingSetConfig replaces the window PoSt configuration. Changes apply
+	// to proofs started after the call returns
+	ProvingSetConfig(ctx context.Context, cfg dtypes.ProvingConfig) error
+	// ProvingCalendar returns the deadlines of the current proving period, and
+	// of the given number of following ones, with their epochs, estimated wall
+	// clock times, and the partitions and sectors currently assigned to them
+	ProvingCalendar(ctx context.Context, periods uint64) (*ProvingCalendar, error)
+}
+
+// ProvingCalendar lists the window PoSt deadlines of a miner over several
+// proving periods. Partition and sector counts are those of the current
+// state, later periods only differ as sectors are added or expire.
+type ProvingCalendar struct {
+	Miner        address.Address
+	CurrentEpoch abi.ChainEpoch
+	Deadlines    []CalendarDeadline
+}
+
+type CalendarDeadline struct {
+	PeriodStart abi.ChainEpoch
+	Index       uint64
+
+	Open        abi.ChainEpoch
+	Close       abi.ChainEpoch
+	Challenge   abi.ChainEpoch
+	FaultCutoff abi.ChainEpoch
+
+	// estimated from the epochs, assuming no drift of the chain
+	OpenTime  time.Time
+	CloseTime time.Time
+
+	Partitions int
+	Sectors    uint64
+	Faults     uint64
 }
 
 type SealRes struct {
@@ -156,6 +245,7 @@ type SectorInfo struct {
 	CommR        *cid.Cid
 	Proof        []byte
 	Deals        []abi.DealID
+	Pieces       []abi.PieceInfo
 	Ticket       SealTicket
 	Seed         SealSeed
 	PreCommitMsg *cid.Cid
@@ -181,6 +271,19 @@ type SectorInfo struct {
 	Early abi.ChainEpoch
 }
 
+type PieceDealInfo struct {
+	PublishCid   *cid.Cid
+	DealID       abi.DealID
+	StartEpoch   abi.ChainEpoch
+	EndEpoch     abi.ChainEpoch
+	KeepUnsealed bool
+}
+
+type SectorOffset struct {
+	Sector abi.SectorNumber
+	Offset abi.PaddedPieceSize
+}
+
 type SealedRef struct {
 	SectorID abi.SectorNumber
 	Offset   abi.PaddedPieceSize
@@ -23,6 +23,8 @@ type WorkerAPI interface {
 
 	storiface.WorkerCalls
 
+	// TaskDisable / TaskEnable change the task types accepted by the worker.
+	// Tasks already assigned to the worker are not affected
 	TaskDisable(ctx context.Context, tt sealtasks.TaskType) error
 	TaskEnable(ctx context.Context, tt sealtasks.TaskType) error
 
@@ -46,4 +48,12 @@ type WorkerAPI interface {
 
 	// Like ProcessSession, but returns an error when worker is disabled
 	Session(context.Context) (uuid.UUID, error)
+
+	// CallLogs streams the output the worker process wrote while running the
+	// call. For running calls, output is streamed until the call finishes
+	CallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error)
+
+	// ManagerShutdown is called by the miner when it's shutting down. Results
+	// which can't be returned until the miner is back are retried less often.
+	ManagerShutdown(ctx context.Context) error
 }
@@ -29,6 +29,7 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 	marketevents "github.com/filecoin-project/lotus/markets/loggers"
 	"github.com/filecoin-project/specs-storage/storage"
 
@@ -37,6 +38,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/paych"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/blockstore"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
 
@@ -102,6 +104,7 @@ type FullNodeStruct struct {
 		ChainGetMessage               func(context.Context, cid.Cid) (*types.Message, error)                                                             `perm:"read"`
 		ChainGetPath                  func(context.Context, types.TipSetKey, types.TipSetKey) ([]*api.HeadChange, error)                                 `perm:"read"`
 		ChainExport                   func(context.Context, abi.ChainEpoch, bool, types.TipSetKey) (<-chan []byte, error)                                `perm:"read"`
+		ChainBlockstoreCacheStats     func(context.Context) (blockstore.ReadCacheStats, error)                                                           `perm:"read"`
 
 		BeaconGetEntry func(ctx context.Context, epoch abi.ChainEpoch) (*types.BeaconEntry, error) `perm:"read"`
 
@@ -193,6 +196,7 @@ type FullNodeStruct struct {
 		StateMinerRecoveries               func(context.Context, address.Address, types.TipSetKey) (bitfield.BitField, error)                                  `perm:"read"`
 		StateMinerPreCommitDepositForPower func(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)            `perm:"read"`
 		StateMinerInitialPledgeCollateral  func(context.Context, address.Address, miner.SectorPreCommitInfo, types.TipSetKey) (types.BigInt, error)            `perm:"read"`
+		StateMinerTerminationEstimate      func(context.Context, address.Address, *bitfield.BitField, types.TipSetKey) (*api.TerminationEstimate, error)       `perm:"read"`
 		StateMinerAvailableBalance         func(context.Context, address.Address, types.TipSetKey) (types.BigInt, error)                                       `perm:"read"`
 		StateMinerSectorAllocated          func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (bool, error)                             `perm:"read"`
 		StateSectorPreCommitInfo           func(context.Context, address.Address, abi.SectorNumber, types.TipSetKey) (miner.SectorPreCommitOnChainInfo, error) `perm:"read"`
@@ -219,6 +223,7 @@ type FullNodeStruct struct {
 		StateMinerSectorCount              func(context.Context, address.Address, types.TipSetKey) (api.MinerSectors, error)                                   `perm:"read"`
 		StateListMessages                  func(ctx context.Context, match *api.MessageMatch, tsk types.TipSetKey, toht abi.ChainEpoch) ([]cid.Cid, error)     `perm:"read"`
 		StateDecodeParams                  func(context.Context, address.Address, abi.MethodNum, []byte, types.TipSetKey) (interface{}, error)                 `perm:"read"`
+		StateDecodeMessages                func(context.Context, []api.DecodeMessage, types.TipSetKey) ([]api.DecodedMessage, error)                           `perm:"read"`
 		StateCompute                       func(context.Context, abi.ChainEpoch, []*types.Message, types.TipSetKey) (*api.ComputeStateOutput, error)           `perm:"read"`
 		StateVerifierStatus                func(context.Context, address.Address, types.TipSetKey) (*abi.StoragePower, error)                                  `perm:"read"`
 		StateVerifiedClientStatus          func(context.Context, address.Address, types.TipSetKey) (*abi.StoragePower, error)                                  `perm:"read"`
@@ -300,23 +305,32 @@ type StorageMinerStruct struct {
 
 		PledgeSector func(context.Context) error `perm:"write"`
 
-		SectorsStatus                 func(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error) `perm:"read"`
-		SectorsList                   func(context.Context) ([]abi.SectorNumber, error)                                             `perm:"read"`
-		SectorsListInStates           func(context.Context, []api.SectorState) ([]abi.SectorNumber, error)                          `perm:"read"`
-		SectorsSummary                func(ctx context.Context) (map[api.SectorState]int, error)                                    `perm:"read"`
-		SectorsRefs                   func(context.Context) (map[string][]api.SealedRef, error)                                     `perm:"read"`
-		SectorStartSealing            func(context.Context, abi.SectorNumber) error                                                 `perm:"write"`
-		SectorSetSealDelay            func(context.Context, time.Duration) error                                                    `perm:"write"`
-		SectorGetSealDelay            func(context.Context) (time.Duration, error)                                                  `perm:"read"`
-		SectorSetExpectedSealDuration func(context.Context, time.Duration) error                                                    `perm:"write"`
-		SectorGetExpectedSealDuration func(context.Context) (time.Duration, error)                                                  `perm:"read"`
-		SectorsUpdate                 func(context.Context, abi.SectorNumber, api.SectorState) error                                `perm:"admin"`
-		SectorRemove                  func(context.Context, abi.SectorNumber) error                                                 `perm:"admin"`
-		SectorMarkForUpgrade          func(ctx context.Context, id abi.SectorNumber) error                                          `perm:"admin"`
-
-		WorkerConnect func(context.Context, string) error                                `perm:"admin" retry:"true"` // TODO: worker perm
-		WorkerStats   func(context.Context) (map[uuid.UUID]storiface.WorkerStats, error) `perm:"admin"`
-		WorkerJobs    func(context.Context) (map[uuid.UUID][]storiface.WorkerJob, error) `perm:"admin"`
+		SectorsStatus                 func(ctx context.Context, sid abi.SectorNumber, showOnChainInfo bool) (api.SectorInfo, error)                                      `perm:"read"`
+		SectorsList                   func(context.Context) ([]abi.SectorNumber, error)                                                                                  `perm:"read"`
+		SectorsListInStates           func(context.Context, []api.SectorState) ([]abi.SectorNumber, error)                                                               `perm:"read"`
+		SectorsSummary                func(ctx context.Context) (map[api.SectorState]int, error)                                                                         `perm:"read"`
+		SectorsPackingReport          func(ctx context.Context) (sealiface.PackingReport, error)                                                                         `perm:"read"`
+		SectorsRefs                   func(context.Context) (map[string][]api.SealedRef, error)                                                                          `perm:"read"`
+		SectorStartSealing            func(context.Context, abi.SectorNumber) error                                                                                      `perm:"write"`
+		SectorSetSealDelay            func(context.Context, time.Duration) error                                                                                         `perm:"write"`
+		SectorGetSealDelay            func(context.Context) (time.Duration, error)                                                                                       `perm:"read"`
+		SectorSetExpectedSealDuration func(context.Context, time.Duration) error                                                                                         `perm:"write"`
+		SectorGetExpectedSealDuration func(context.Context) (time.Duration, error)                                                                                       `perm:"read"`
+		SectorsUpdate                 func(context.Context, abi.SectorNumber, api.SectorState) error                                                                     `perm:"admin"`
+		SectorRemove                  func(context.Context, abi.SectorNumber) error                                                                                      `perm:"admin"`
+		SectorMarkForUpgrade          func(ctx context.Context, id abi.SectorNumber) error                                                                               `perm:"admin"`
+		SectorCommitPending           func(ctx context.Context) ([]sealiface.PendingCommit, error)                                                                       `perm:"read"`
+		SectorCommitApprove           func(ctx context.Context, id abi.SectorNumber) error                                                                               `perm:"admin"`
+		SectorCommitReject            func(ctx context.Context, id abi.SectorNumber) error                                                                               `perm:"admin"`
+		SectorAddPieceToAny           func(ctx context.Context, size abi.UnpaddedPieceSize, pieceURL string, deal api.PieceDealInfo) (api.SectorOffset, error)           `perm:"admin"`
+		SectorUnsealRange             func(ctx context.Context, sid abi.SectorNumber, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (uuid.UUID, error) `perm:"admin"`
+		SectorUnsealStatus            func(ctx context.Context, id uuid.UUID) (storiface.UnsealJob, error)                                                               `perm:"read"`
+		SectorSealRecord              func(ctx context.Context, sid abi.SectorNumber) (storiface.SealRecord, error)                                                      `perm:"read"`
+
+		WorkerConnect      func(context.Context, string) error                                `perm:"admin" retry:"true"` // TODO: worker perm
+		WorkerStats        func(context.Context) (map[uuid.UUID]storiface.WorkerStats, error) `perm:"admin"`
+		WorkerJobs         func(context.Context) (map[uuid.UUID][]storiface.WorkerJob, error) `perm:"admin"`
+		WorkerTasksChanged func(context.Context) error                                        `perm:"admin"`
 
 		ReturnAddPiece        func(ctx context.Context, callID storiface.CallID, pi abi.PieceInfo, err *storiface.CallError) error          `perm:"admin" retry:"true"`
 		ReturnSealPreCommit1  func(ctx context.Context, callID storiface.CallID, p1o storage.PreCommit1Out, err *storiface.CallError) error `perm:"admin" retry:"true"`
@@ -330,8 +344,13 @@ type StorageMinerStruct struct {
 		ReturnReadPiece       func(ctx context.Context, callID storiface.CallID, ok bool, err *storiface.CallError) error                   `perm:"admin" retry:"true"`
 		ReturnFetch           func(ctx context.Context, callID storiface.CallID, err *storiface.CallError) error                            `perm:"admin" retry:"true"`
 
-		SealingSchedDiag func(context.Context, bool) (interface{}, error)       `perm:"admin"`
-		SealingAbort     func(ctx context.Context, call storiface.CallID) error `perm:"admin"`
+		SealingSchedDiag   func(context.Context, bool) (interface{}, error)                                       `perm:"admin"`
+		SealingAbort       func(ctx context.Context, call storiface.CallID) error                                 `perm:"admin"`
+		SealingAbortRemote func(ctx context.Context, call storiface.CallID) error                                 `perm:"admin"`
+		SealingCallLogs    func(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) `perm:"admin"`
+		SealingSetThrottle func(ctx context.Context, limits storiface.SchedThrottle) error                        `perm:"admin"`
+		SealingGetThrottle func(ctx context.Context) (storiface.SchedThrottle, error)                             `perm:"admin"`
+		SealingStats       func(ctx context.Context) (storiface.SealingStats, error)                              `perm:"read"`
 
 		StorageList          func(context.Context) (map[stores.ID][]stores.Decl, error)                                                                                   `perm:"admin"`
 		StorageLocal         func(context.Context) (map[stores.ID]string, error)                                                                                          `perm:"admin"`
@@ -373,6 +392,10 @@ type StorageMinerStruct struct {
 		CreateBackup func(ctx context.Context, fpath string) error `perm:"admin"`
 
 		CheckProvable func(ctx context.Context, pp abi.RegisteredPoStProof, sectors []storage.SectorRef, expensive bool) (map[abi.SectorNumber]string, error) `perm:"admin"`
+
+		ProvingGetConfig func(ctx context.Context) (dtypes.ProvingConfig, error)                 `perm:"read"`
+		ProvingSetConfig func(ctx context.Context, cfg dtypes.ProvingConfig) error               `perm:"admin"`
+		ProvingCalendar  func(ctx context.Context, periods uint64) (*api.ProvingCalendar, error) `perm:"read"`
 	}
 }
 
@@ -386,17 +409,17 @@ type WorkerStruct struct {
 		Paths     func(context.Context) ([]stores.StoragePath, error)            `perm:"admin"`
 		Info      func(context.Context) (storiface.WorkerInfo, error)            `perm:"admin"`
 
-		AddPiece        func(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (storiface.CallID, error)                 `perm:"admin"`
-		SealPreCommit1  func(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storiface.CallID, error)                                                              `perm:"admin"`
-		SealPreCommit2  func(ctx context.Context, sector storage.SectorRef, pc1o storage.PreCommit1Out) (storiface.CallID, error)                                                                                     `perm:"admin"`
-		SealCommit1     func(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storiface.CallID, error) `perm:"admin"`
-		SealCommit2     func(ctx context.Context, sector storage.SectorRef, c1o storage.Commit1Out) (storiface.CallID, error)                                                                                         `perm:"admin"`
-		FinalizeSector  func(ctx context.Context, sector storage.SectorRef, keepUnsealed []storage.Range) (storiface.CallID, error)                                                                                   `perm:"admin"`
-		ReleaseUnsealed func(ctx context.Context, sector storage.SectorRef, safeToFree []storage.Range) (storiface.CallID, error)                                                                                     `perm:"admin"`
-		MoveStorage     func(ctx context.Context, sector storage.SectorRef, types storiface.SectorFileType) (storiface.CallID, error)                                                                                 `perm:"admin"`
-		UnsealPiece     func(context.Context, storage.SectorRef, storiface.UnpaddedByteIndex, abi.UnpaddedPieceSize, abi.SealRandomness, cid.Cid) (storiface.CallID, error)                                           `perm:"admin"`
-		ReadPiece       func(context.Context, io.Writer, storage.SectorRef, storiface.UnpaddedByteIndex, abi.UnpaddedPieceSize) (storiface.CallID, error)                                                             `perm:"admin"`
-		Fetch           func(context.Context, storage.SectorRef, storiface.SectorFileType, storiface.PathType, storiface.AcquireMode) (storiface.CallID, error)                                                       `perm:"admin"`
+		AddPiece        func(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (storiface.CallID, error)                                             `perm:"admin"`
+		SealPreCommit1  func(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, meta []storiface.PieceMeta, numaNode int) (storiface.CallID, error)                                                `perm:"admin"`
+		SealPreCommit2  func(ctx context.Context, sector storage.SectorRef, pc1o storage.PreCommit1Out) (storiface.CallID, error)                                                                                                                 `perm:"admin"`
+		SealCommit1     func(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids, meta []storiface.PieceMeta) (storiface.CallID, error) `perm:"admin"`
+		SealCommit2     func(ctx context.Context, sector storage.SectorRef, c1o storage.Commit1Out) (storiface.CallID, error)                                                                                                                     `perm:"admin"`
+		FinalizeSector  func(ctx context.Context, sector storage.SectorRef, keepUnsealed []storage.Range) (storiface.CallID, error)                                                                                                               `perm:"admin"`
+		ReleaseUnsealed func(ctx context.Context, sector storage.SectorRef, safeToFree []storage.Range) (storiface.CallID, error)                                                                                                                 `perm:"admin"`
+		MoveStorage     func(ctx context.Context, sector storage.SectorRef, types storiface.SectorFileType) (storiface.CallID, error)                                                                                                             `perm:"admin"`
+		UnsealPiece     func(context.Context, storage.SectorRef, storiface.UnpaddedByteIndex, abi.UnpaddedPieceSize, abi.SealRandomness, cid.Cid) (storiface.CallID, error)                                                                       `perm:"admin"`
+		ReadPiece       func(context.Context, io.Writer, storage.SectorRef, storiface.UnpaddedByteIndex, abi.UnpaddedPieceSize) (storiface.CallID, error)                                                                                         `perm:"admin"`
+		Fetch           func(context.Context, storage.SectorRef, storiface.SectorFileType, storiface.PathType, storiface.AcquireMode, storiface.FetchToken) (storiface.CallID, error)                                                             `perm:"admin"`
 
 		TaskDisable func(ctx context.Context, tt sealtasks.TaskType) error `perm:"admin"`
 		TaskEnable  func(ctx context.Context, tt sealtasks.TaskType) error `perm:"admin"`
@@ -411,6 +434,9 @@ type WorkerStruct struct {
 
 		ProcessSession func(context.Context) (uuid.UUID, error) `perm:"admin"`
 		Session        func(context.Context) (uuid.UUID, error) `perm:"admin"`
+
+		CallLogs        func(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) `perm:"admin"`
+		ManagerShutdown func(ctx context.Context) error                                                        `perm:"admin"`
 	}
 }
 
@@ -864,6 +890,10 @@ func (c *FullNodeStruct) ChainExport(ctx context.Context, nroots abi.ChainEpoch,
 	return c.Internal.ChainExport(ctx, nroots, iom, tsk)
 }
 
+func (c *FullNodeStruct) ChainBlockstoreCacheStats(ctx context.Context) (blockstore.ReadCacheStats, error) {
+	return c.Internal.ChainBlockstoreCacheStats(ctx)
+}
+
 func (c *FullNodeStruct) BeaconGetEntry(ctx context.Context, epoch abi.ChainEpoch) (*types.BeaconEntry, error) {
 	return c.Internal.BeaconGetEntry(ctx, epoch)
 }
@@ -956,6 +986,10 @@ func (c *FullNodeStruct) StateMinerInitialPledgeCollateral(ctx context.Context,
 	return c.Internal.StateMinerInitialPledgeCollateral(ctx, maddr, pci, tsk)
 }
 
+func (c *FullNodeStruct) StateMinerTerminationEstimate(ctx context.Context, maddr address.Address, sectors *bitfield.BitField, tsk types.TipSetKey) (*api.TerminationEstimate, error) {
+	return c.Internal.StateMinerTerminationEstimate(ctx, maddr, sectors, tsk)
+}
+
 func (c *FullNodeStruct) StateMinerAvailableBalance(ctx context.Context, maddr address.Address, tsk types.TipSetKey) (types.BigInt, error) {
 	return c.Internal.StateMinerAvailableBalance(ctx, maddr, tsk)
 }
@@ -1056,6 +1090,10 @@ func (c *FullNodeStruct) StateDecodeParams(ctx context.Context, toAddr address.A
 	return c.Internal.StateDecodeParams(ctx, toAddr, method, params, tsk)
 }
 
+func (c *FullNodeStruct) StateDecodeMessages(ctx context.Context, msgs []api.DecodeMessage, tsk types.TipSetKey) ([]api.DecodedMessage, error) {
+	return c.Internal.StateDecodeMessages(ctx, msgs, tsk)
+}
+
 func (c *FullNodeStruct) StateCompute(ctx context.Context, height abi.ChainEpoch, msgs []*types.Message, tsk types.TipSetKey) (*api.ComputeStateOutput, error) {
 	return c.Internal.StateCompute(ctx, height, msgs, tsk)
 }
@@ -1268,6 +1306,10 @@ func (c *StorageMinerStruct) SectorsSummary(ctx context.Context) (map[api.Sector
 	return c.Internal.SectorsSummary(ctx)
 }
 
+func (c *StorageMinerStruct) SectorsPackingReport(ctx context.Context) (sealiface.PackingReport, error) {
+	return c.Internal.SectorsPackingReport(ctx)
+}
+
 func (c *StorageMinerStruct) SectorsRefs(ctx context.Context) (map[string][]api.SealedRef, error) {
 	return c.Internal.SectorsRefs(ctx)
 }
@@ -1304,6 +1346,34 @@ func (c *StorageMinerStruct) SectorMarkForUpgrade(ctx context.Context, number ab
 	return c.Internal.SectorMarkForUpgrade(ctx, number)
 }
 
+func (c *StorageMinerStruct) SectorCommitPending(ctx context.Context) ([]sealiface.PendingCommit, error) {
+	return c.Internal.SectorCommitPending(ctx)
+}
+
+func (c *StorageMinerStruct) SectorCommitApprove(ctx context.Context, number abi.SectorNumber) error {
+	return c.Internal.SectorCommitApprove(ctx, number)
+}
+
+func (c *StorageMinerStruct) SectorCommitReject(ctx context.Context, number abi.SectorNumber) error {
+	return c.Internal.SectorCommitReject(ctx, number)
+}
+
+func (c *StorageMinerStruct) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, pieceURL string, deal api.PieceDealInfo) (api.SectorOffset, error) {
+	return c.Internal.SectorAddPieceToAny(ctx, size, pieceURL, deal)
+}
+
+func (c *StorageMinerStruct) SectorUnsealRange(ctx context.Context, sid abi.SectorNumber, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (uuid.UUID, error) {
+	return c.Internal.SectorUnsealRange(ctx, sid, offset, size)
+}
+
+func (c *StorageMinerStruct) SectorUnsealStatus(ctx context.Context, id uuid.UUID) (storiface.UnsealJob, error) {
+	return c.Internal.SectorUnsealStatus(ctx, id)
+}
+
+func (c *StorageMinerStruct) SectorSealRecord(ctx context.Context, sid abi.SectorNumber) (storiface.SealRecord, error) {
+	return c.Internal.SectorSealRecord(ctx, sid)
+}
+
 func (c *StorageMinerStruct) WorkerConnect(ctx context.Context, url string) error {
 	return c.Internal.WorkerConnect(ctx, url)
 }
@@ -1316,6 +1386,10 @@ func (c *StorageMinerStruct) WorkerJobs(ctx context.Context) (map[uuid.UUID][]st
 	return c.Internal.WorkerJobs(ctx)
 }
 
+func (c *StorageMinerStruct) WorkerTasksChanged(ctx context.Context) error {
+	return c.Internal.WorkerTasksChanged(ctx)
+}
+
 func (c *StorageMinerStruct) ReturnAddPiece(ctx context.Context, callID storiface.CallID, pi abi.PieceInfo, err *storiface.CallError) error {
 	return c.Internal.ReturnAddPiece(ctx, callID, pi, err)
 }
@@ -1368,6 +1442,26 @@ func (c *StorageMinerStruct) SealingAbort(ctx context.Context, call storiface.Ca
 	return c.Internal.SealingAbort(ctx, call)
 }
 
+func (c *StorageMinerStruct) SealingAbortRemote(ctx context.Context, call storiface.CallID) error {
+	return c.Internal.SealingAbortRemote(ctx, call)
+}
+
+func (c *StorageMinerStruct) SealingCallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) {
+	return c.Internal.SealingCallLogs(ctx, call)
+}
+
+func (c *StorageMinerStruct) SealingSetThrottle(ctx context.Context, limits storiface.SchedThrottle) error {
+	return c.Internal.SealingSetThrottle(ctx, limits)
+}
+
+func (c *StorageMinerStruct) SealingGetThrottle(ctx context.Context) (storiface.SchedThrottle, error) {
+	return c.Internal.SealingGetThrottle(ctx)
+}
+
+func (c *StorageMinerStruct) SealingStats(ctx context.Context) (storiface.SealingStats, error) {
+	return c.Internal.SealingStats(ctx)
+}
+
 func (c *StorageMinerStruct) StorageAttach(ctx context.Context, si stores.StorageInfo, st fsutil.FsStat) error {
 	return c.Internal.StorageAttach(ctx, si, st)
 }
@@ -1560,6 +1654,18 @@ func (c *StorageMinerStruct) CheckProvable(ctx context.Context, pp abi.Registere
 	return c.Internal.CheckProvable(ctx, pp, sectors, expensive)
 }
 
+func (c *StorageMinerStruct) ProvingGetConfig(ctx context.Context) (dtypes.ProvingConfig, error) {
+	return c.Internal.ProvingGetConfig(ctx)
+}
+
+func (c *StorageMinerStruct) ProvingSetConfig(ctx context.Context, cfg dtypes.ProvingConfig) error {
+	return c.Internal.ProvingSetConfig(ctx, cfg)
+}
+
+func (c *StorageMinerStruct) ProvingCalendar(ctx context.Context, periods uint64) (*api.ProvingCalendar, error) {
+	return c.Internal.ProvingCalendar(ctx, periods)
+}
+
 // WorkerStruct
 
 func (w *WorkerStruct) Version(ctx context.Context) (build.Version, error) {
@@ -1582,16 +1688,16 @@ func (w *WorkerStruct) AddPiece(ctx context.Context, sector storage.SectorRef, p
 	return w.Internal.AddPiece(ctx, sector, pieceSizes, newPieceSize, pieceData)
 }
 
-func (w *WorkerStruct) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storiface.CallID, error) {
-	return w.Internal.SealPreCommit1(ctx, sector, ticket, pieces)
+func (w *WorkerStruct) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, meta []storiface.PieceMeta, numaNode int) (storiface.CallID, error) {
+	return w.Internal.SealPreCommit1(ctx, sector, ticket, pieces, meta, numaNode)
 }
 
 func (w *WorkerStruct) SealPreCommit2(ctx context.Context, sector storage.SectorRef, pc1o storage.PreCommit1Out) (storiface.CallID, error) {
 	return w.Internal.SealPreCommit2(ctx, sector, pc1o)
 }
 
-func (w *WorkerStruct) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storiface.CallID, error) {
-	return w.Internal.SealCommit1(ctx, sector, ticket, seed, pieces, cids)
+func (w *WorkerStruct) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids, meta []storiface.PieceMeta) (storiface.CallID, error) {
+	return w.Internal.SealCommit1(ctx, sector, ticket, seed, pieces, cids, meta)
 }
 
 func (w *WorkerStruct) SealCommit2(ctx context.Context, sector storage.SectorRef, c1o storage.Commit1Out) (storiface.CallID, error) {
@@ -1618,8 +1724,8 @@ func (w *WorkerStruct) ReadPiece(ctx context.Context, sink io.Writer, sector sto
 	return w.Internal.ReadPiece(ctx, sink, sector, offset, size)
 }
 
-func (w *WorkerStruct) Fetch(ctx context.Context, id storage.SectorRef, fileType storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode) (storiface.CallID, error) {
-	return w.Internal.Fetch(ctx, id, fileType, ptype, am)
+func (w *WorkerStruct) Fetch(ctx context.Context, id storage.SectorRef, fileType storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode, token storiface.FetchToken) (storiface.CallID, error) {
+	return w.Internal.Fetch(ctx, id, fileType, ptype, am, token)
 }
 
 func (w *WorkerStruct) TaskDisable(ctx context.Context, tt sealtasks.TaskType) error {
@@ -1658,6 +1764,14 @@ func (w *WorkerStruct) Session(ctx context.Context) (uuid.UUID, error) {
 	return w.Internal.Session(ctx)
 }
 
+func (w *WorkerStruct) CallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) {
+	return w.Internal.CallLogs(ctx, call)
+}
+
+func (w *WorkerStruct) ManagerShutdown(ctx context.Context) error {
+	return w.Internal.ManagerShutdown(ctx)
+}
+
 func (g GatewayStruct) ChainGetBlockMessages(ctx context.Context, c cid.Cid) (*api.BlockMessages, error) {
 	return g.Internal.ChainGetBlockMessages(ctx, c)
 }
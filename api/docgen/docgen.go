@@ -211,6 +211,12 @@ func init() {
 				RunWait:  0,
 				Start:    time.Unix(1605172927, 0).UTC(),
 				Hostname: "host",
+				State:    storiface.JobComputing,
+				History: []storiface.JobStateTime{
+					{State: storiface.JobQueued, Time: time.Unix(1605172827, 0).UTC()},
+					{State: storiface.JobTransferringIn, Time: time.Unix(1605172867, 0).UTC()},
+					{State: storiface.JobComputing, Time: time.Unix(1605172927, 0).UTC()},
+				},
 			},
 		},
 	})
@@ -233,6 +239,40 @@ func init() {
 			CpuUse:     0,
 		},
 	})
+	addExample(storiface.SealingStats{
+		Since: time.Unix(1605172827, 0).UTC(),
+		Phases: map[sealtasks.TaskType]map[storiface.JobState]storiface.DurationHistogram{
+			sealtasks.TTPreCommit2: {
+				storiface.JobComputing: {
+					Bounds: []time.Duration{10 * time.Minute, time.Hour},
+					Counts: []uint64{3, 40, 1},
+					Sum:    30 * time.Hour,
+				},
+			},
+		},
+		Workers: map[string]map[sealtasks.TaskType]storiface.CallOutcomes{
+			"host": {
+				sealtasks.TTPreCommit2: {Done: 43, Failed: 1},
+			},
+		},
+		Throughput: []storiface.ThroughputSample{
+			{
+				Hour: time.Unix(1605171600, 0).UTC(),
+				Done: map[sealtasks.TaskType]uint64{sealtasks.TTPreCommit2: 2},
+			},
+		},
+	})
+	addExample(storiface.SealRecord{
+		Sector:           abi.SectorID{Miner: 1000, Number: 9},
+		ProofType:        abi.RegisteredSealProof_StackedDrg32GiBV1_1,
+		SectorSize:       32 << 30,
+		WindowPoStProof:  abi.RegisteredPoStProof_StackedDrgWindow32GiBV1,
+		WinningPoStProof: abi.RegisteredPoStProof_StackedDrgWinning32GiBV1,
+		Calls: []storiface.SealCall{
+			{Task: sealtasks.TTPreCommit1, Worker: "host", Version: "1.5.0+mainnet+git.1234abcd", Started: time.Unix(1605172827, 0).UTC()},
+			{Task: sealtasks.TTPreCommit2, Worker: "host", Version: "1.5.0+mainnet+git.1234abcd", Started: time.Unix(1605190827, 0).UTC()},
+		},
+	})
 	addExample(storiface.ErrorCode(0))
 	addExample(map[abi.SectorNumber]string{
 		123: "can't acquire read lock",
@@ -241,14 +281,28 @@ func init() {
 		api.SectorState(sealing.Proving): 120,
 	})
 	addExample([]abi.SectorNumber{123, 124})
+	addExample(map[uint64]dtypes.DeadlineProvingConfig{
+		42: {
+			GenerateLeadEpochs:      10101,
+			MaxPartitionsPerMessage: 123,
+		},
+	})
 
 	// worker specific
 	addExample(storiface.AcquireMove)
+	addExample(storiface.FetchToken("9fa1d3b0c7e5a2f6"))
 	addExample(storiface.UnpaddedByteIndex(abi.PaddedPieceSize(1 << 20).Unpadded()))
 	addExample(map[sealtasks.TaskType]struct{}{
 		sealtasks.TTPreCommit2: {},
 	})
 	addExample(sealtasks.TTCommit2)
+	addExample(map[sealtasks.TaskType]float64{
+		sealtasks.TTPreCommit2: 4.2,
+	})
+	addExample(map[sealtasks.TaskType]int{
+		sealtasks.TTPreCommit1: 4,
+	})
+	addExample(storiface.UnsealDone)
 }
 
 func exampleValue(method string, t, parent reflect.Type) interface{} {
@@ -83,9 +83,9 @@ func VersionForType(nodeType NodeType) (Version, error) {
 
 // semver versions of the rpc api exposed
 var (
-	FullAPIVersion   = newVer(1, 0, 0)
-	MinerAPIVersion  = newVer(1, 0, 0)
-	WorkerAPIVersion = newVer(1, 0, 0)
+	FullAPIVersion   = newVer(1, 1, 0)
+	MinerAPIVersion  = newVer(1, 1, 0)
+	WorkerAPIVersion = newVer(1, 1, 0)
 )
 
 //nolint:varcheck,deadcode
@@ -7,6 +7,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-state-types/cbor"
+	"github.com/filecoin-project/go-state-types/network"
 	builtin0 "github.com/filecoin-project/specs-actors/actors/builtin"
 	builtin2 "github.com/filecoin-project/specs-actors/v2/actors/builtin"
 
@@ -56,6 +57,11 @@ type State interface {
 
 	InitialPledgeForPower(abi.StoragePower, abi.TokenAmount, *builtin.FilterEstimate, abi.TokenAmount) (abi.TokenAmount, error)
 	PreCommitDepositForPower(builtin.FilterEstimate, abi.StoragePower) (abi.TokenAmount, error)
+
+	// PledgePenaltyForTermination returns the penalty for terminating a sector
+	// of the given age and QA power now. dayReward and twentyDayReward are the
+	// expected rewards recorded on the sector at its activation.
+	PledgePenaltyForTermination(dayReward, twentyDayReward abi.TokenAmount, sectorAge abi.ChainEpoch, networkQAPower builtin.FilterEstimate, qaSectorPower abi.StoragePower, nv network.Version) (abi.TokenAmount, error)
 }
 
 type AwardBlockRewardParams = reward0.AwardBlockRewardParams
@@ -2,6 +2,7 @@ package reward
 
 import (
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/network"
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/lotus/chain/actors/adt"
@@ -81,3 +82,13 @@ func (s *state0) PreCommitDepositForPower(networkQAPower builtin.FilterEstimate,
 		},
 		sectorWeight), nil
 }
+
+func (s *state0) PledgePenaltyForTermination(dayReward, twentyDayReward abi.TokenAmount, sectorAge abi.ChainEpoch, networkQAPower builtin.FilterEstimate, qaSectorPower abi.StoragePower, nv network.Version) (abi.TokenAmount, error) {
+	return miner0.PledgePenaltyForTermination(dayReward, twentyDayReward, sectorAge,
+		s.State.ThisEpochRewardSmoothed,
+		&smoothing0.FilterEstimate{
+			PositionEstimate: networkQAPower.PositionEstimate,
+			VelocityEstimate: networkQAPower.VelocityEstimate,
+		},
+		qaSectorPower, nv), nil
+}
@@ -2,6 +2,8 @@ package reward
 
 import (
 	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/network"
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/lotus/chain/actors/adt"
@@ -84,3 +86,16 @@ func (s *state2) PreCommitDepositForPower(networkQAPower builtin.FilterEstimate,
 		},
 		sectorWeight), nil
 }
+
+func (s *state2) PledgePenaltyForTermination(dayReward, twentyDayReward abi.TokenAmount, sectorAge abi.ChainEpoch, networkQAPower builtin.FilterEstimate, qaSectorPower abi.StoragePower, _ network.Version) (abi.TokenAmount, error) {
+	// replaced sector info isn't exposed in SectorOnChainInfo; for sectors
+	// which replaced committed capacity this underestimates the penalty slightly
+	return miner2.PledgePenaltyForTermination(dayReward, sectorAge, twentyDayReward,
+		smoothing2.FilterEstimate{
+			PositionEstimate: networkQAPower.PositionEstimate,
+			VelocityEstimate: networkQAPower.VelocityEstimate,
+		},
+		qaSectorPower,
+		s.State.ThisEpochRewardSmoothed,
+		big.Zero(), 0), nil
+}
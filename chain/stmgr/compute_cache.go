@@ -0,0 +1,41 @@
+package stmgr
+
+import (
+	"crypto/sha256"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// DefaultComputeStateCacheSize is the number of ComputeState results kept in
+// memory by state managers created without an explicit cache size.
+const DefaultComputeStateCacheSize = 64
+
+type computeStateKey struct {
+	tsk    types.TipSetKey
+	height abi.ChainEpoch
+	msgs   [sha256.Size]byte
+}
+
+type computeStateResult struct {
+	root  cid.Cid
+	trace []*api.InvocResult
+}
+
+func newComputeStateKey(ts *types.TipSet, height abi.ChainEpoch, msgs []*types.Message) computeStateKey {
+	h := sha256.New()
+	for _, msg := range msgs {
+		_, _ = h.Write(msg.Cid().Bytes())
+	}
+
+	k := computeStateKey{
+		tsk:    ts.Key(),
+		height: height,
+	}
+	copy(k.msgs[:], h.Sum(nil))
+	return k
+}
@@ -0,0 +1,37 @@
+package stmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/types/mock"
+)
+
+func TestComputeStateKey(t *testing.T) {
+	ts := mock.TipSet(mock.MkBlock(nil, 1, 1))
+	other := mock.TipSet(mock.MkBlock(nil, 1, 2))
+
+	mkMsg := func(nonce uint64) *types.Message {
+		return &types.Message{
+			From:       mock.Address(100),
+			To:         mock.Address(101),
+			Nonce:      nonce,
+			Value:      big.Zero(),
+			GasFeeCap:  big.Zero(),
+			GasPremium: big.Zero(),
+		}
+	}
+
+	msgs := []*types.Message{mkMsg(0), mkMsg(1)}
+	key := newComputeStateKey(ts, 10, msgs)
+
+	require.Equal(t, key, newComputeStateKey(ts, 10, []*types.Message{mkMsg(0), mkMsg(1)}))
+	require.NotEqual(t, key, newComputeStateKey(other, 10, msgs))
+	require.NotEqual(t, key, newComputeStateKey(ts, 11, msgs))
+	require.NotEqual(t, key, newComputeStateKey(ts, 10, msgs[:1]))
+	require.NotEqual(t, key, newComputeStateKey(ts, 10, []*types.Message{mkMsg(1), mkMsg(0)}))
+}
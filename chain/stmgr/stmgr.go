@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sync"
 
+	lru "github.com/hashicorp/golang-lru"
 	"github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
 	logging "github.com/ipfs/go-log/v2"
@@ -83,8 +84,18 @@ type StateManager struct {
 
 	genesisPledge      abi.TokenAmount
 	genesisMarketFunds abi.TokenAmount
+
+	// ComputeState results; nil when disabled
+	computeCache *lru.ARCCache
+
+	// decides whether cron is ticked; nil ticks cron at every epoch
+	cronSchedule CronSchedule
 }
 
+// CronSchedule decides whether the cron actor is ticked at the epoch when
+// applying blocks. nullRound is true for the ticks of null rounds.
+type CronSchedule func(epoch abi.ChainEpoch, nullRound bool) bool
+
 func NewStateManager(cs *store.ChainStore) *StateManager {
 	sm, err := NewStateManagerWithUpgradeSchedule(cs, DefaultUpgradeSchedule())
 	if err != nil {
@@ -94,6 +105,12 @@ func NewStateManager(cs *store.ChainStore) *StateManager {
 }
 
 func NewStateManagerWithUpgradeSchedule(cs *store.ChainStore, us UpgradeSchedule) (*StateManager, error) {
+	return NewStateManagerWithComputeCache(cs, us, DefaultComputeStateCacheSize)
+}
+
+// NewStateManagerWithComputeCache creates a state manager which keeps up to
+// computeCacheSize ComputeState results in memory; 0 disables the cache.
+func NewStateManagerWithComputeCache(cs *store.ChainStore, us UpgradeSchedule, computeCacheSize int) (*StateManager, error) {
 	// If we have upgrades, make sure they're in-order and make sense.
 	if err := us.Validate(); err != nil {
 		return nil, err
@@ -124,7 +141,17 @@ func NewStateManagerWithUpgradeSchedule(cs *store.ChainStore, us UpgradeSchedule
 		lastVersion = build.NewestNetworkVersion
 	}
 
+	var computeCache *lru.ARCCache
+	if computeCacheSize > 0 {
+		var err error
+		computeCache, err = lru.NewARC(computeCacheSize)
+		if err != nil {
+			return nil, xerrors.Errorf("creating compute state cache: %w", err)
+		}
+	}
+
 	return &StateManager{
+		computeCache:      computeCache,
 		networkVersions:   networkVersions,
 		latestVersion:     lastVersion,
 		stateMigrations:   stateMigrations,
@@ -256,7 +283,10 @@ func (sm *StateManager) ApplyBlocks(ctx context.Context, parentEpoch abi.ChainEp
 		return cid.Undef, cid.Undef, xerrors.Errorf("making vm: %w", err)
 	}
 
-	runCron := func(epoch abi.ChainEpoch) error {
+	runCron := func(epoch abi.ChainEpoch, nullRound bool) error {
+		if sm.cronSchedule != nil && !sm.cronSchedule(epoch, nullRound) {
+			return nil
+		}
 
 		cronMsg := &types.Message{
 			To:         cron.Address,
@@ -288,7 +318,7 @@ func (sm *StateManager) ApplyBlocks(ctx context.Context, parentEpoch abi.ChainEp
 	for i := parentEpoch; i < epoch; i++ {
 		if i > parentEpoch {
 			// run cron for null rounds if any
-			if err := runCron(i); err != nil {
+			if err := runCron(i, true); err != nil {
 				return cid.Undef, cid.Undef, err
 			}
 
@@ -380,7 +410,7 @@ func (sm *StateManager) ApplyBlocks(ctx context.Context, parentEpoch abi.ChainEp
 		}
 	}
 
-	if err := runCron(epoch); err != nil {
+	if err := runCron(epoch, false); err != nil {
 		return cid.Cid{}, cid.Cid{}, err
 	}
 
@@ -893,6 +923,12 @@ func (sm *StateManager) SetVMConstructor(nvm func(context.Context, *vm.VMOpts) (
 	sm.newVM = nvm
 }
 
+// SetCronSchedule overrides when cron is ticked. This breaks consensus, and is
+// only meant for conformance testing.
+func (sm *StateManager) SetCronSchedule(cs CronSchedule) {
+	sm.cronSchedule = cs
+}
+
 // sets up information about the vesting schedule
 func (sm *StateManager) setupGenesisVestingSchedule(ctx context.Context) error {
 
@@ -341,11 +341,24 @@ func ListMinerActors(ctx context.Context, sm *StateManager, ts *types.TipSet) ([
 	return powState.ListAllMiners()
 }
 
+// ComputeState applies msgs on top of the state resulting from the execution
+// of ts, at the given height. Results are cached by tipset, height and message
+// set, so the returned trace is shared between callers and must not be
+// modified.
 func ComputeState(ctx context.Context, sm *StateManager, height abi.ChainEpoch, msgs []*types.Message, ts *types.TipSet) (cid.Cid, []*api.InvocResult, error) {
 	if ts == nil {
 		ts = sm.cs.GetHeaviestTipSet()
 	}
 
+	var cacheKey computeStateKey
+	if sm.computeCache != nil {
+		cacheKey = newComputeStateKey(ts, height, msgs)
+		if res, ok := sm.computeCache.Get(cacheKey); ok {
+			res := res.(computeStateResult)
+			return res.root, res.trace, nil
+		}
+	}
+
 	base, trace, err := sm.ExecutionTrace(ctx, ts)
 	if err != nil {
 		return cid.Undef, nil, err
@@ -394,6 +407,10 @@ func ComputeState(ctx context.Context, sm *StateManager, height abi.ChainEpoch,
 		return cid.Undef, nil, err
 	}
 
+	if sm.computeCache != nil {
+		sm.computeCache.Add(cacheKey, computeStateResult{root: root, trace: trace})
+	}
+
 	return root, trace, nil
 }
 
@@ -132,6 +132,9 @@ type ChainStore struct {
 
 	vmcalls vm.SyscallBuilder
 
+	// weights are taken from block headers, as state isn't computed
+	headersOnly bool
+
 	evtTypes [1]journal.EventType
 	journal  journal.Journal
 
@@ -139,6 +142,16 @@ type ChainStore struct {
 	wg       sync.WaitGroup
 }
 
+// SetHeadersOnly makes the chain store take tipset weights from block headers
+// instead of computing them from the tipset's parent state, for nodes which
+// sync without computing state. The weight of a tipset is then the weight of
+// its parent, which still orders tipsets of a chain by height. Header weights
+// aren't verified, so they must only come from trusted peers, see
+// Syncer.SetHeadersOnly.
+func (cs *ChainStore) SetHeadersOnly(b bool) {
+	cs.headersOnly = b
+}
+
 // localbs is guaranteed to fail Get* if requested block isn't stored locally
 func NewChainStore(bs bstore.Blockstore, localbs bstore.Blockstore, ds dstore.Batching, vmcalls vm.SyscallBuilder, j journal.Journal) *ChainStore {
 	mmCache, _ := lru.NewARC(DefaultMsgMetaCacheSize)
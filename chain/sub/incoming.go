@@ -227,6 +227,11 @@ type BlockValidator struct {
 	// necessary for block validation
 	chain *store.ChainStore
 	stmgr *stmgr.StateManager
+
+	// Trusted is set on nodes syncing headers only, which can't check miner
+	// power and block signatures without chain state; blocks are accepted
+	// without those checks, from the peers it returns true for only
+	Trusted func(peer.ID) bool
 }
 
 func NewBlockValidator(self peer.ID, chain *store.ChainStore, stmgr *stmgr.StateManager, blacklist func(peer.ID)) *BlockValidator {
@@ -293,6 +298,19 @@ func (bv *BlockValidator) Validate(ctx context.Context, pid peer.ID, msg *pubsub
 		return pubsub.ValidationReject
 	}
 
+	if blk.Header.ElectionProof.WinCount < 1 {
+		log.Errorf("block is not claiming to be winning")
+		recordFailureFlagPeer("not_winning")
+		return pubsub.ValidationReject
+	}
+
+	if bv.Trusted != nil {
+		if !bv.Trusted(pid) {
+			return pubsub.ValidationIgnore
+		}
+		return bv.acceptBlock(ctx, msg, blk)
+	}
+
 	// we want to ensure that it is a block from a known miner; we reject blocks from unknown miners
 	// to prevent spam attacks.
 	// the logic works as follows: we lookup the miner in the chain for its key.
@@ -319,12 +337,10 @@ func (bv *BlockValidator) Validate(ctx context.Context, pid peer.ID, msg *pubsub
 		return pubsub.ValidationReject
 	}
 
-	if blk.Header.ElectionProof.WinCount < 1 {
-		log.Errorf("block is not claiming to be winning")
-		recordFailureFlagPeer("not_winning")
-		return pubsub.ValidationReject
-	}
+	return bv.acceptBlock(ctx, msg, blk)
+}
 
+func (bv *BlockValidator) acceptBlock(ctx context.Context, msg *pubsub.Message, blk *types.BlockMsg) pubsub.ValidationResult {
 	// it's a good block! make sure we've only seen it once
 	if bv.recvBlocks.add(blk.Header.Cid()) > 0 {
 		// TODO: once these changes propagate to the network, we can consider
@@ -136,10 +136,51 @@ type Syncer struct {
 	checkpt types.TipSetKey
 
 	ds dtypes.MetadataDS
+
+	// only sync headers and messages from heads of trusted peers, see
+	// SetHeadersOnly
+	headersOnly bool
+	trusted     map[peer.ID]struct{}
 }
 
 type SyncManagerCtor func(syncFn SyncFunc) SyncManager
 
+// SetHeadersOnly makes the syncer only fetch block headers and messages,
+// without computing state or validating blocks against it, for tooling nodes
+// which serve chain data but don't need the state. Message roots are still
+// checked against the headers.
+//
+// Without state, block signatures, tickets, election proofs and the weights
+// in headers can't be verified, so the node trusts the given peers instead:
+// only heads they announce are synced, and fork choice follows the parent
+// weights in their headers. Heads announced by other peers are ignored. The
+// node is only as correct as the trusted peers, which should be validating
+// nodes run by the same operator. Must be called before the syncer is
+// started.
+func (syncer *Syncer) SetHeadersOnly(trusted []peer.ID) error {
+	if len(trusted) == 0 {
+		return xerrors.Errorf("headers-only sync needs at least one trusted peer")
+	}
+
+	syncer.headersOnly = true
+	syncer.trusted = map[peer.ID]struct{}{}
+	for _, p := range trusted {
+		syncer.trusted[p] = struct{}{}
+	}
+	syncer.store.SetHeadersOnly(true)
+	return nil
+}
+
+// Trusted returns whether heads announced by the peer are synced, which is
+// the case for all peers unless the syncer only syncs headers
+func (syncer *Syncer) Trusted(p peer.ID) bool {
+	if !syncer.headersOnly || p == syncer.self {
+		return true
+	}
+	_, ok := syncer.trusted[p]
+	return ok
+}
+
 // NewSyncer creates a new Syncer object.
 func NewSyncer(ds dtypes.MetadataDS, sm *stmgr.StateManager, exchange exchange.Client, syncMgrCtor SyncManagerCtor, connmgr connmgr.ConnManager, self peer.ID, beacon beacon.Schedule, verifier ffiwrapper.Verifier) (*Syncer, error) {
 	gen, err := sm.ChainStore().GetGenesis()
@@ -232,6 +273,11 @@ func (syncer *Syncer) InformNewHead(from peer.ID, fts *store.FullTipSet) bool {
 		return false
 	}
 
+	if !syncer.Trusted(from) {
+		log.Debugw("ignoring head from untrusted peer in headers-only mode", "peer", from)
+		return false
+	}
+
 	if syncer.IsEpochBeyondCurrMax(fts.TipSet().Height()) {
 		log.Errorf("Received block with impossibly large height %d", fts.TipSet().Height())
 		return false
@@ -1507,10 +1553,19 @@ func (syncer *Syncer) syncMessagesAndCheckState(ctx context.Context, headers []*
 	ss.SetHeight(headers[len(headers)-1].Height())
 
 	return syncer.iterFullTipsets(ctx, headers, func(ctx context.Context, fts *store.FullTipSet) error {
-		log.Debugw("validating tipset", "height", fts.TipSet().Height(), "size", len(fts.TipSet().Cids()))
-		if err := syncer.ValidateTipSet(ctx, fts, true); err != nil {
-			log.Errorf("failed to validate tipset: %+v", err)
-			return xerrors.Errorf("message processing failed: %w", err)
+		if syncer.headersOnly {
+			// messages were checked against the headers when fetched
+			for _, b := range fts.Blocks {
+				if err := syncer.store.AddToTipSetTracker(b.Header); err != nil {
+					return xerrors.Errorf("failed to add header to tipset tracker: %w", err)
+				}
+			}
+		} else {
+			log.Debugw("validating tipset", "height", fts.TipSet().Height(), "size", len(fts.TipSet().Cids()))
+			if err := syncer.ValidateTipSet(ctx, fts, true); err != nil {
+				log.Errorf("failed to validate tipset: %+v", err)
+				return xerrors.Errorf("message processing failed: %w", err)
+			}
 		}
 
 		stats.Record(ctx, metrics.ChainNodeWorkerHeight.M(int64(fts.TipSet().Height())))
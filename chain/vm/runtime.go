@@ -500,7 +500,7 @@ func (rt *Runtime) stateCommit(oldh, newh cid.Cid) aerrors.ActorError {
 func (rt *Runtime) finilizeGasTracing() {
 	if EnableGasTracing {
 		if rt.lastGasCharge != nil {
-			rt.lastGasCharge.TimeTaken = time.Since(rt.lastGasChargeTime)
+			rt.lastGasCharge.TimeTaken = build.Clock.Since(rt.lastGasChargeTime)
 		}
 	}
 }
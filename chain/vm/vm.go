@@ -352,7 +352,7 @@ func (vm *VM) send(ctx context.Context, msg *types.Message, parent *Runtime,
 		GasUsed:  rt.gasUsed,
 	}
 	rt.executionTrace.MsgRct = &mr
-	rt.executionTrace.Duration = time.Since(start)
+	rt.executionTrace.Duration = build.Clock.Since(start)
 	if err != nil {
 		rt.executionTrace.Error = err.Error()
 	}
@@ -397,7 +397,7 @@ func (vm *VM) ApplyImplicitMessage(ctx context.Context, msg *types.Message) (*Ap
 		ActorErr:       actorErr,
 		ExecutionTrace: rt.executionTrace,
 		GasCosts:       nil,
-		Duration:       time.Since(start),
+		Duration:       build.Clock.Since(start),
 	}, actorErr
 }
 
@@ -433,7 +433,7 @@ func (vm *VM) ApplyMessage(ctx context.Context, cmsg types.ChainMsg) (*ApplyRet,
 				GasUsed:  0,
 			},
 			GasCosts: &gasOutputs,
-			Duration: time.Since(start),
+			Duration: build.Clock.Since(start),
 		}, nil
 	}
 
@@ -453,7 +453,7 @@ func (vm *VM) ApplyMessage(ctx context.Context, cmsg types.ChainMsg) (*ApplyRet,
 				},
 				ActorErr: aerrors.Newf(exitcode.SysErrSenderInvalid, "actor not found: %s", msg.From),
 				GasCosts: &gasOutputs,
-				Duration: time.Since(start),
+				Duration: build.Clock.Since(start),
 			}, nil
 		}
 		return nil, xerrors.Errorf("failed to look up from actor: %w", err)
@@ -470,7 +470,7 @@ func (vm *VM) ApplyMessage(ctx context.Context, cmsg types.ChainMsg) (*ApplyRet,
 			},
 			ActorErr: aerrors.Newf(exitcode.SysErrSenderInvalid, "send from not account actor: %s", fromActor.Code),
 			GasCosts: &gasOutputs,
-			Duration: time.Since(start),
+			Duration: build.Clock.Since(start),
 		}, nil
 	}
 
@@ -486,7 +486,7 @@ func (vm *VM) ApplyMessage(ctx context.Context, cmsg types.ChainMsg) (*ApplyRet,
 				"actor nonce invalid: msg:%d != state:%d", msg.Nonce, fromActor.Nonce),
 
 			GasCosts: &gasOutputs,
-			Duration: time.Since(start),
+			Duration: build.Clock.Since(start),
 		}, nil
 	}
 
@@ -502,7 +502,7 @@ func (vm *VM) ApplyMessage(ctx context.Context, cmsg types.ChainMsg) (*ApplyRet,
 			ActorErr: aerrors.Newf(exitcode.SysErrSenderStateInvalid,
 				"actor balance less than needed: %s < %s", types.FIL(fromActor.Balance), types.FIL(gascost)),
 			GasCosts: &gasOutputs,
-			Duration: time.Since(start),
+			Duration: build.Clock.Since(start),
 		}, nil
 	}
 
@@ -602,7 +602,7 @@ func (vm *VM) ApplyMessage(ctx context.Context, cmsg types.ChainMsg) (*ApplyRet,
 		ActorErr:       actorErr,
 		ExecutionTrace: rt.executionTrace,
 		GasCosts:       &gasOutputs,
-		Duration:       time.Since(start),
+		Duration:       build.Clock.Since(start),
 	}, nil
 }
 
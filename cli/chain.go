@@ -56,6 +56,7 @@ var chainCmd = &cli.Command{
 		chainGasPriceCmd,
 		chainInspectUsage,
 		chainDecodeCmd,
+		chainCacheStatsCmd,
 	},
 }
 
@@ -1241,6 +1242,36 @@ var chainGasPriceCmd = &cli.Command{
 	},
 }
 
+var chainCacheStatsCmd = &cli.Command{
+	Name:  "cache-stats",
+	Usage: "Print chain blockstore read cache statistics",
+	Action: func(cctx *cli.Context) error {
+		api, closer, err := GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := ReqContext(cctx)
+
+		st, err := api.ChainBlockstoreCacheStats(ctx)
+		if err != nil {
+			return err
+		}
+
+		var ratio float64
+		if lookups := st.Hits + st.Misses; lookups > 0 {
+			ratio = float64(st.Hits) / float64(lookups) * 100
+		}
+
+		fmt.Printf("Type:     %s\n", st.Type)
+		fmt.Printf("Blocks:   %d / %d\n", st.Len, st.Size)
+		fmt.Printf("Hits:     %d\n", st.Hits)
+		fmt.Printf("Misses:   %d\n", st.Misses)
+		fmt.Printf("Hit rate: %.2f%%\n", ratio)
+		return nil
+	},
+}
+
 var chainDecodeCmd = &cli.Command{
 	Name:  "decode",
 	Usage: "decode various types",
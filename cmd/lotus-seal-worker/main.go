@@ -35,6 +35,7 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/lib/lotuslog"
+	"github.com/filecoin-project/lotus/lib/probes"
 	"github.com/filecoin-project/lotus/lib/rpcenc"
 	"github.com/filecoin-project/lotus/metrics"
 	"github.com/filecoin-project/lotus/node/modules"
@@ -121,6 +122,21 @@ var runCmd = &cli.Command{
 			Usage: "don't use swap",
 			Value: false,
 		},
+		&cli.BoolFlag{
+			Name:  "no-numa",
+			Usage: "don't pin PC1 to NUMA nodes (e.g. when the worker is pinned with numactl)",
+			Value: false,
+		},
+		&cli.DurationFlag{
+			Name:  "scratch-cleanup-grace",
+			Usage: "remove PreCommit1 layers of sectors without calls on this worker, left by crashed jobs, once untouched for this long; 0 disables",
+			Value: 72 * time.Hour,
+		},
+		&cli.BoolFlag{
+			Name:  "preflight-bench",
+			Usage: "benchmark PreCommit2/Commit2 on a small sector on startup, and report the scores to the miner",
+			Value: false,
+		},
 		&cli.BoolFlag{
 			Name:  "addpiece",
 			Usage: "enable addpiece",
@@ -151,11 +167,20 @@ var runCmd = &cli.Command{
 			Usage: "maximum fetch operations to run in parallel",
 			Value: 5,
 		},
+		&cli.StringSliceFlag{
+			Name:  "shared-path",
+			Usage: "local mount of a storage path shared with the miner (e.g. over NFS); sector files on it are used in place when copied to long-term storage, instead of being fetched",
+		},
 		&cli.StringFlag{
 			Name:  "timeout",
 			Usage: "used when 'listen' is unspecified. must be a valid duration recognized by golang's time.ParseDuration function",
 			Value: "30m",
 		},
+		&cli.BoolFlag{
+			Name:  "call-logs",
+			Usage: "keep the recent process output, so that the output of calls can be retrieved with 'lotus-miner sealing logs'",
+			Value: true,
+		},
 	},
 	Before: func(cctx *cli.Context) error {
 		if cctx.IsSet("address") {
@@ -256,6 +281,14 @@ var runCmd = &cli.Command{
 			return xerrors.Errorf("no task types specified")
 		}
 
+		var benchmark map[sealtasks.TaskType]float64
+		if cctx.Bool("preflight-bench") && (cctx.Bool("precommit2") || cctx.Bool("commit")) {
+			benchmark, err = preflightBenchmark(ctx)
+			if err != nil {
+				return xerrors.Errorf("preflight benchmark: %w", err)
+			}
+		}
+
 		// Open repo
 
 		repoPath := cctx.String(FlagWorkerRepo)
@@ -362,6 +395,12 @@ var runCmd = &cli.Command{
 		}
 
 		remote := stores.NewRemote(localStore, nodeApi, sminfo.AuthHeader(), cctx.Int("parallel-fetch-limit"))
+		if err := remote.SetSharedPaths(cctx.StringSlice("shared-path")); err != nil {
+			return xerrors.Errorf("setting up shared paths: %w", err)
+		}
+		if err := remote.PersistTransfers(ctx, namespace.Wrap(ds, modules.SectorTransfersPrefix)); err != nil {
+			return xerrors.Errorf("recovering sector transfers: %w", err)
+		}
 
 		fh := &stores.FetchHandler{Local: localStore}
 		remoteHandler := func(w http.ResponseWriter, r *http.Request) {
@@ -378,13 +417,29 @@ var runCmd = &cli.Command{
 
 		wsts := statestore.New(namespace.Wrap(ds, modules.WorkerCallsPrefix))
 
+		sectorstorage.WorkerVersion = build.UserVersion()
+
 		workerApi := &worker{
 			LocalWorker: sectorstorage.NewLocalWorker(sectorstorage.WorkerConfig{
 				TaskTypes: taskTypes,
 				NoSwap:    cctx.Bool("no-swap"),
+				NoNUMA:    cctx.Bool("no-numa"),
+				Benchmark: benchmark,
+
+				ScratchCleanupGrace: cctx.Duration("scratch-cleanup-grace"),
 			}, remote, localStore, nodeApi, nodeApi, wsts),
-			localStore: localStore,
-			ls:         lr,
+			localStore:   localStore,
+			ls:           lr,
+			tasksChanged: nodeApi.WorkerTasksChanged,
+		}
+
+		if cctx.Bool("call-logs") {
+			outputLog := sectorstorage.NewOutputLog(sectorstorage.DefaultOutputLogLines)
+			if err := sectorstorage.CaptureOutput(outputLog); err != nil {
+				log.Warnf("call logs won't be available: %+v", err)
+			} else {
+				workerApi.SetOutputLog(outputLog)
+			}
 		}
 
 		mux := mux.NewRouter()
@@ -398,6 +453,21 @@ var runCmd = &cli.Command{
 		mux.Handle("/rpc/v0", rpcServer)
 		mux.Handle("/rpc/streams/v0/push/{uuid}", readerHandler)
 		mux.PathPrefix("/remote").HandlerFunc(remoteHandler)
+		mux.HandleFunc("/healthz", probes.Handler(nil))
+		mux.HandleFunc("/readyz", probes.Handler(map[string]probes.Check{
+			"miner": func(ctx context.Context) error {
+				_, err := nodeApi.Version(ctx)
+				return err
+			},
+			"enabled": func(ctx context.Context) error {
+				if enabled, _ := workerApi.Enabled(ctx); !enabled {
+					return xerrors.New("worker disabled")
+				}
+				return nil
+			},
+		}))
+		mux.HandleFunc("/status", statusHandler(workerApi))
+		log.Infof("Worker status page at http://%s/status (pass an API token as ?token=)", address)
 		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
 
 		ah := &auth.Handler{
@@ -557,3 +627,30 @@ func extractRoutableIP(timeout time.Duration) (string, error) {
 
 	return strings.Split(localAddr.IP.String(), ":")[0], nil
 }
+
+func preflightBenchmark(ctx context.Context) (map[sealtasks.TaskType]float64, error) {
+	ssize, err := sectorstorage.BenchmarkProofType.SectorSize()
+	if err != nil {
+		return nil, err
+	}
+	if err := paramfetch.GetParams(ctx, build.ParametersJSON(), uint64(ssize)); err != nil {
+		return nil, xerrors.Errorf("get params: %w", err)
+	}
+
+	dir, err := ioutil.TempDir("", "lotus-worker-bench")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir) // nolint
+
+	log.Info("Running preflight benchmark")
+	scores, err := sectorstorage.PreflightBenchmark(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+	for task, score := range scores {
+		log.Infof("Preflight benchmark: %s score %.2f", task.Short(), score)
+	}
+
+	return scores, nil
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/filecoin-project/lotus/build"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
@@ -20,6 +21,10 @@ type worker struct {
 	localStore *stores.Local
 	ls         stores.LocalStorage
 
+	// tells the miner about changes to accepted task types, so that they
+	// are picked up by the scheduler right away
+	tasksChanged func(ctx context.Context) error
+
 	disabled int64
 }
 
@@ -46,6 +51,32 @@ func (w *worker) StorageAddLocal(ctx context.Context, path string) error {
 	return nil
 }
 
+func (w *worker) TaskEnable(ctx context.Context, tt sealtasks.TaskType) error {
+	if err := w.LocalWorker.TaskEnable(ctx, tt); err != nil {
+		return err
+	}
+
+	w.notifyTasksChanged(ctx)
+	return nil
+}
+
+func (w *worker) TaskDisable(ctx context.Context, tt sealtasks.TaskType) error {
+	if err := w.LocalWorker.TaskDisable(ctx, tt); err != nil {
+		return err
+	}
+
+	w.notifyTasksChanged(ctx)
+	return nil
+}
+
+func (w *worker) notifyTasksChanged(ctx context.Context) {
+	// selectors query task types on every scheduling pass, so the change will
+	// be picked up on the next one anyways; notifying just triggers it now
+	if err := w.tasksChanged(ctx); err != nil {
+		log.Warnf("notifying miner about task type change: %+v", err)
+	}
+}
+
 func (w *worker) SetEnabled(ctx context.Context, enabled bool) error {
 	disabled := int64(1)
 	if enabled {
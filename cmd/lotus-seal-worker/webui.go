@@ -0,0 +1,197 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/elastic/go-sysinfo"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api/apistruct"
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+)
+
+// statusPage is the data rendered by the worker status page
+type statusPage struct {
+	Hostname string
+	Now      time.Time
+	Enabled  bool
+	Tasks    []string
+
+	CPUs        uint64
+	GPUs        []string
+	MemPhysical string
+	MemUsed     string
+	MemSwap     string
+	MemReserved string
+
+	Paths  []statusPath
+	Calls  []sectorstorage.LocalCall
+	Errors []sectorstorage.LocalCallError
+}
+
+type statusPath struct {
+	ID        string
+	Path      string
+	CanSeal   bool
+	CanStore  bool
+	Capacity  string
+	Available string
+	Reserved  string
+	Error     string
+}
+
+var statusTmpl = template.Must(template.New("status").Funcs(template.FuncMap{
+	"since": func(t time.Time) string {
+		return time.Since(t).Truncate(time.Second).String()
+	},
+	"time": func(t time.Time) string {
+		return t.Format(time.RFC3339)
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>lotus-worker: {{.Hostname}}</title>
+<style>
+body { font-family: monospace; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+.err { color: #c00; }
+</style>
+</head>
+<body>
+<h2>{{.Hostname}}</h2>
+<p>
+{{if .Enabled}}Enabled{{else}}<span class="err">Disabled</span>{{end}}; as of {{time .Now}}<br>
+Tasks: {{range .Tasks}}{{.}} {{end}}
+</p>
+
+<h3>Resources</h3>
+<table>
+<tr><th>CPUs</th><td>{{.CPUs}}</td></tr>
+<tr><th>GPUs</th><td>{{range .GPUs}}{{.}}<br>{{else}}none{{end}}</td></tr>
+<tr><th>Memory</th><td>{{.MemUsed}} used / {{.MemPhysical}}</td></tr>
+<tr><th>Reserved memory</th><td>{{.MemReserved}}</td></tr>
+<tr><th>Swap</th><td>{{.MemSwap}}</td></tr>
+</table>
+
+<h3>Running tasks</h3>
+<table>
+<tr><th>Sector</th><th>Task</th><th>Call</th><th>Running for</th></tr>
+{{range .Calls}}<tr><td>{{.ID.Sector.Number}}</td><td>{{.Task}}</td><td>{{.ID.ID}}</td><td>{{since .Start}}</td></tr>
+{{else}}<tr><td colspan="4">none</td></tr>
+{{end}}</table>
+
+<h3>Storage</h3>
+<table>
+<tr><th>ID</th><th>Path</th><th>Use</th><th>Available</th><th>Reserved</th><th>Capacity</th></tr>
+{{range .Paths}}<tr><td>{{.ID}}</td><td>{{.Path}}</td><td>{{if .CanSeal}}seal {{end}}{{if .CanStore}}store{{end}}</td>
+{{if .Error}}<td colspan="3" class="err">{{.Error}}</td>{{else}}<td>{{.Available}}</td><td>{{.Reserved}}</td><td>{{.Capacity}}</td>{{end}}</tr>
+{{end}}</table>
+
+<h3>Recent errors</h3>
+<table>
+<tr><th>Time</th><th>Sector</th><th>Task</th><th>Error</th></tr>
+{{range .Errors}}<tr><td>{{time .End}}</td><td>{{.ID.Sector.Number}}</td><td>{{.Task}}</td><td class="err">{{.Error}}</td></tr>
+{{else}}<tr><td colspan="4">none</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// statusHandler serves a page showing what the worker is doing. It requires
+// a token with read permission, which browsers can pass as ?token=
+func statusHandler(w *worker) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		if !auth.HasPerm(r.Context(), nil, apistruct.PermRead) {
+			http.Error(rw, "unauthorized: missing read permission", http.StatusUnauthorized)
+			return
+		}
+
+		page, err := w.statusPage(r)
+		if err != nil {
+			log.Errorf("collecting worker status: %+v", err)
+			http.Error(rw, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err := statusTmpl.Execute(rw, page); err != nil {
+			log.Errorf("rendering worker status: %+v", err)
+		}
+	}
+}
+
+func (w *worker) statusPage(r *http.Request) (*statusPage, error) {
+	ctx := r.Context()
+
+	info, err := w.Info(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting worker info: %w", err)
+	}
+	enabled, err := w.Enabled(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := w.TaskTypes(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting task types: %w", err)
+	}
+	paths, err := w.Paths(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting storage paths: %w", err)
+	}
+
+	page := &statusPage{
+		Hostname: info.Hostname,
+		Now:      time.Now(),
+		Enabled:  enabled,
+
+		CPUs:        info.Resources.CPUs,
+		GPUs:        info.Resources.GPUs,
+		MemPhysical: units.BytesSize(float64(info.Resources.MemPhysical)),
+		MemUsed:     "?",
+		MemSwap:     units.BytesSize(float64(info.Resources.MemSwap)),
+		MemReserved: units.BytesSize(float64(info.Resources.MemReserved)),
+
+		Calls:  w.ActiveCalls(),
+		Errors: w.RecentErrors(),
+	}
+
+	for tt := range tasks {
+		page.Tasks = append(page.Tasks, tt.Short())
+	}
+	sort.Strings(page.Tasks)
+
+	if h, err := sysinfo.Host(); err == nil {
+		if mem, err := h.Memory(); err == nil {
+			page.MemUsed = units.BytesSize(float64(mem.Used))
+		}
+	}
+
+	for _, p := range paths {
+		sp := statusPath{
+			ID:       string(p.ID),
+			Path:     p.LocalPath,
+			CanSeal:  p.CanSeal,
+			CanStore: p.CanStore,
+		}
+
+		st, err := w.localStore.FsStat(ctx, p.ID)
+		if err != nil {
+			sp.Error = err.Error()
+		} else {
+			sp.Capacity = units.BytesSize(float64(st.Capacity))
+			sp.Available = units.BytesSize(float64(st.Available))
+			sp.Reserved = units.BytesSize(float64(st.Reserved))
+		}
+
+		page.Paths = append(page.Paths, sp)
+	}
+
+	return page, nil
+}
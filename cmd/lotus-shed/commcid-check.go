@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/filecoin-project/go-address"
+	commcid "github.com/filecoin-project/go-fil-commcid"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var sectorsCheckCommCIDsCmd = &cli.Command{
+	Name:  "check-commcids",
+	Usage: "Round-trip on-chain sector commitments through the commcid helpers",
+	Description: `Converts the sealed CIDs of all sectors of the given miners, and
+optionally the piece CIDs of their deals, to raw commitments and back with
+the go-fil-commcid helpers the sealing pipeline uses, and reports CIDs which
+don't convert, or don't convert back to the same bytes.
+
+Unsealed CIDs aren't stored on chain, so they aren't checked.`,
+	ArgsUsage: "[minerAddress...]",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "tipset",
+			Usage: "tipset to read sectors at, defaults to chain head",
+		},
+		&cli.BoolFlag{
+			Name:  "deals",
+			Usage: "also check the piece CIDs of the deals in the sectors",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify at least one miner address")
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ts, err := lcli.LoadTipSet(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+		tsk := types.EmptyTSK
+		if ts != nil {
+			tsk = ts.Key()
+		}
+
+		var checked, bad int
+		report := func(what string, err error) {
+			bad++
+			fmt.Printf("%s: %s\n", what, err)
+		}
+
+		for _, arg := range cctx.Args().Slice() {
+			maddr, err := address.NewFromString(arg)
+			if err != nil {
+				return xerrors.Errorf("parsing miner address %q: %w", arg, err)
+			}
+
+			sectors, err := api.StateMinerSectors(ctx, maddr, nil, tsk)
+			if err != nil {
+				return xerrors.Errorf("getting sectors of %s: %w", maddr, err)
+			}
+
+			seenDeals := map[abi.DealID]struct{}{}
+			for _, s := range sectors {
+				checked++
+				if err := checkCommCID(s.SealedCID, commSealed); err != nil {
+					report(fmt.Sprintf("%s sector %d sealed CID %s", maddr, s.SectorNumber, s.SealedCID), err)
+				}
+
+				if !cctx.Bool("deals") {
+					continue
+				}
+
+				for _, id := range s.DealIDs {
+					if _, seen := seenDeals[id]; seen {
+						continue
+					}
+					seenDeals[id] = struct{}{}
+
+					deal, err := api.StateMarketStorageDeal(ctx, id, tsk)
+					if err != nil {
+						// deals of expired sectors may be gone from the market
+						log.Warnf("getting deal %d of %s sector %d: %s", id, maddr, s.SectorNumber, err)
+						continue
+					}
+
+					checked++
+					if err := checkCommCID(deal.Proposal.PieceCID, commPiece); err != nil {
+						report(fmt.Sprintf("%s sector %d deal %d piece CID %s", maddr, s.SectorNumber, id, deal.Proposal.PieceCID), err)
+					}
+				}
+			}
+		}
+
+		fmt.Printf("checked %d CIDs, %d non-canonical\n", checked, bad)
+		if bad > 0 {
+			return xerrors.Errorf("found %d non-canonical commitment CIDs", bad)
+		}
+		return nil
+	},
+}
+
+type commKind int
+
+const (
+	commSealed commKind = iota
+	commPiece
+)
+
+// checkCommCID converts a commitment CID to its raw commitment and back,
+// returning an error if it doesn't convert, or doesn't convert back to the
+// exact same CID bytes
+func checkCommCID(c cid.Cid, kind commKind) error {
+	if !c.Defined() {
+		return xerrors.Errorf("undefined CID")
+	}
+
+	var (
+		back cid.Cid
+		err  error
+	)
+	switch kind {
+	case commSealed:
+		var commR []byte
+		commR, err = commcid.CIDToReplicaCommitmentV1(c)
+		if err != nil {
+			return xerrors.Errorf("to commitment: %w", err)
+		}
+		back, err = commcid.ReplicaCommitmentV1ToCID(commR)
+	case commPiece:
+		var commP []byte
+		commP, err = commcid.CIDToPieceCommitmentV1(c)
+		if err != nil {
+			return xerrors.Errorf("to commitment: %w", err)
+		}
+		back, err = commcid.PieceCommitmentV1ToCID(commP)
+	default:
+		return xerrors.Errorf("unknown commitment kind %d", kind)
+	}
+	if err != nil {
+		return xerrors.Errorf("from commitment: %w", err)
+	}
+
+	if !bytes.Equal(c.Bytes(), back.Bytes()) {
+		return xerrors.Errorf("round-trips to %s (%x, was %x)", back, back.Bytes(), c.Bytes())
+	}
+
+	return nil
+}
@@ -51,6 +51,8 @@ func main() {
 		rpcCmd,
 		cidCmd,
 		blockmsgidCmd,
+		storageMigrateCmd,
+		vectorCarCmd,
 	}
 
 	app := &cli.App{
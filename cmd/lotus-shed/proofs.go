@@ -18,6 +18,7 @@ var proofsCmd = &cli.Command{
 	Name: "proofs",
 	Subcommands: []*cli.Command{
 		verifySealProofCmd,
+		replaySealCmd,
 	},
 }
 
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper/basicfs"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+)
+
+var replaySealCmd = &cli.Command{
+	Name:      "replay-seal",
+	Usage:     "Re-seal a sector locally from its recorded inputs, and compare the results with the chain",
+	ArgsUsage: "<sectorNum>",
+	Description: `Reads the ticket, seed and pieces recorded by the miner for the sector, and the
+   proof type and commitments from the chain, then runs PreCommit1/2 and, once
+   the sector has a seed, Commit1/2 locally.
+
+   The resulting CommR and CommD must match the on-chain (or recorded) ones
+   exactly. SNARK proofs aren't deterministic, so the new proof is checked by
+   verifying it against the on-chain inputs instead of comparing bytes.
+
+   Sectors with deals need the data of their pieces, pass the sector's unsealed
+   file from the miner's storage with --unsealed; sectors made only of filler
+   pieces are replayed without it.
+
+   Sealing needs the same resources as on a worker, including the parameters
+   and scratch space in --storage-dir.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "unsealed",
+			Usage: "path to the unsealed sector file",
+		},
+		&cli.StringFlag{
+			Name:  "storage-dir",
+			Usage: "directory for the replayed sector files, a temporary directory is used if not set",
+		},
+		&cli.BoolFlag{
+			Name:  "skip-commit",
+			Usage: "only replay PreCommit1/2 and compare commitments",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		snum, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("parsing sector number: %w", err)
+		}
+		num := abi.SectorNumber(snum)
+
+		minerApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		nodeApi, ncloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer ncloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := minerApi.ActorAddress(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting miner address: %w", err)
+		}
+
+		mid, err := address.IDFromAddress(maddr)
+		if err != nil {
+			return err
+		}
+
+		si, err := minerApi.SectorsStatus(ctx, num, false)
+		if err != nil {
+			return xerrors.Errorf("getting sector info: %w", err)
+		}
+		if len(si.Pieces) == 0 || len(si.Ticket.Value) == 0 {
+			return xerrors.Errorf("sector %d has no recorded pieces or ticket", num)
+		}
+
+		// the commitments to compare with, from the chain if the sector is
+		// there, the miner's records otherwise
+		var spt abi.RegisteredSealProof
+		var commR, commD *cid.Cid
+		var deals []abi.DealID
+
+		onChain, err := nodeApi.StateSectorGetInfo(ctx, maddr, num, types.EmptyTSK)
+		if err != nil {
+			return xerrors.Errorf("getting on-chain sector info: %w", err)
+		}
+		if onChain != nil {
+			spt, commR, deals = onChain.SealProof, &onChain.SealedCID, onChain.DealIDs
+			fmt.Println("Comparing with the sector's on-chain info")
+		} else {
+			pci, err := nodeApi.StateSectorPreCommitInfo(ctx, maddr, num, types.EmptyTSK)
+			if err != nil {
+				return xerrors.Errorf("sector not found on chain, and getting its precommit failed: %w", err)
+			}
+			spt, commR, deals = pci.Info.SealProof, &pci.Info.SealedCID, pci.Info.DealIDs
+			fmt.Println("Comparing with the sector's on-chain precommit")
+		}
+		commD = si.CommD
+
+		dir := cctx.String("storage-dir")
+		if dir == "" {
+			dir, err = ioutil.TempDir("", "replay-seal")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dir) // nolint
+		}
+
+		sbfs := &basicfs.Provider{
+			Root: dir,
+		}
+		sb, err := ffiwrapper.New(sbfs)
+		if err != nil {
+			return err
+		}
+
+		sector := storage.SectorRef{
+			ID: abi.SectorID{
+				Miner:  abi.ActorID(mid),
+				Number: num,
+			},
+			ProofType: spt,
+		}
+
+		if err := replayUnsealed(cctx, sb, sbfs, sector, si.Pieces); err != nil {
+			return err
+		}
+
+		fmt.Println("Running PreCommit1")
+		p1o, err := sb.SealPreCommit1(ctx, sector, abi.SealRandomness(si.Ticket.Value), si.Pieces)
+		if err != nil {
+			return xerrors.Errorf("precommit1: %w", err)
+		}
+
+		fmt.Println("Running PreCommit2")
+		cids, err := sb.SealPreCommit2(ctx, sector, p1o)
+		if err != nil {
+			return xerrors.Errorf("precommit2: %w", err)
+		}
+
+		mismatch := false
+		compare := func(name string, expected *cid.Cid, got cid.Cid) {
+			switch {
+			case expected == nil:
+				fmt.Printf("%s: %s (nothing to compare with)\n", name, got)
+			case !expected.Equals(got):
+				fmt.Printf("%s: MISMATCH, expected %s, got %s\n", name, expected, got)
+				mismatch = true
+			default:
+				fmt.Printf("%s: %s, ok\n", name, got)
+			}
+		}
+		compare("CommR", commR, cids.Sealed)
+		compare("CommD", commD, cids.Unsealed)
+
+		if mismatch {
+			return xerrors.Errorf("replayed commitments don't match")
+		}
+
+		if cctx.Bool("skip-commit") {
+			return nil
+		}
+		if len(si.Seed.Value) == 0 {
+			fmt.Println("Sector has no seed yet, skipping Commit1/2")
+			return nil
+		}
+
+		fmt.Println("Running Commit1")
+		c1o, err := sb.SealCommit1(ctx, sector, abi.SealRandomness(si.Ticket.Value), abi.InteractiveSealRandomness(si.Seed.Value), si.Pieces, cids)
+		if err != nil {
+			return xerrors.Errorf("commit1: %w", err)
+		}
+
+		fmt.Println("Running Commit2")
+		proof, err := sb.SealCommit2(ctx, sector, c1o)
+		if err != nil {
+			return xerrors.Errorf("commit2: %w", err)
+		}
+
+		ok, err := ffiwrapper.ProofVerifier.VerifySeal(proof2.SealVerifyInfo{
+			SealProof:             spt,
+			SectorID:              sector.ID,
+			DealIDs:               deals,
+			Randomness:            abi.SealRandomness(si.Ticket.Value),
+			InteractiveRandomness: abi.InteractiveSealRandomness(si.Seed.Value),
+			Proof:                 proof,
+			SealedCID:             *commR,
+			UnsealedCID:           cids.Unsealed,
+		})
+		if err != nil {
+			return xerrors.Errorf("verifying replayed proof: %w", err)
+		}
+		if !ok {
+			return xerrors.Errorf("replayed proof is invalid")
+		}
+
+		fmt.Println("Proof: valid")
+		if len(si.Proof) > 0 && !bytes.Equal(si.Proof, proof) {
+			fmt.Println("(differs from the recorded proof, as expected of SNARKs)")
+		}
+
+		return nil
+	},
+}
+
+// replayUnsealed puts the sector's unsealed data in place, either copied from
+// the file passed by the user, or regenerated for filler pieces.
+func replayUnsealed(cctx *cli.Context, sb *ffiwrapper.Sealer, sbfs *basicfs.Provider, sector storage.SectorRef, pieces []abi.PieceInfo) error {
+	ctx := lcli.ReqContext(cctx)
+
+	if src := cctx.String("unsealed"); src != "" {
+		paths, done, err := sbfs.AcquireSector(ctx, sector, storiface.FTNone, storiface.FTUnsealed, storiface.PathSealing)
+		if err != nil {
+			return xerrors.Errorf("acquiring unsealed path: %w", err)
+		}
+		defer done()
+
+		return copyFile(src, paths.Unsealed)
+	}
+
+	fmt.Println("Regenerating filler pieces")
+
+	var existing []abi.UnpaddedPieceSize
+	for i, p := range pieces {
+		size := p.Size.Unpadded()
+
+		pi, err := sb.AddPiece(ctx, sector, existing, size, sealing.NewNullReader(size))
+		if err != nil {
+			return xerrors.Errorf("adding piece %d: %w", i, err)
+		}
+		if !pi.PieceCID.Equals(p.PieceCID) {
+			return xerrors.Errorf("piece %d isn't a filler piece (%s), pass the unsealed sector file with --unsealed", i, p.PieceCID)
+		}
+
+		existing = append(existing, size)
+	}
+
+	return nil
+}
+
+func copyFile(from, to string) error {
+	src, err := os.Open(from)
+	if err != nil {
+		return xerrors.Errorf("opening unsealed file: %w", err)
+	}
+	defer src.Close() // nolint
+
+	dst, err := os.OpenFile(to, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return xerrors.Errorf("creating unsealed file: %w", err)
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		return xerrors.Errorf("copying unsealed file: %w", err)
+	}
+
+	return dst.Close()
+}
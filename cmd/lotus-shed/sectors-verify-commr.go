@@ -0,0 +1,182 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/ipfs/go-cid"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	ffi "github.com/filecoin-project/filecoin-ffi"
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-actors/actors/runtime/proof"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+var sectorsVerifyCommRCmd = &cli.Command{
+	Name:      "verify-commr",
+	Usage:     "Check sealed sectors on local storage against their on-chain CommR",
+	ArgsUsage: "<minerAddress> <fromSector> <toSector>",
+	Description: `For every sector in the range, finds its sealed file and cache in the
+storage paths given with --storage, and generates a WindowPoSt vanilla proof
+for random challenges with the sector's on-chain sealed CID, as CheckProvable
+does with --slow. Generating the proof fails when the CommR recorded in the
+cache (p_aux) doesn't match the on-chain one, or when the sealed file or the
+tree_r_last files are corrupt at the challenged nodes.
+
+Mismatches are reported before they make the sectors fault. Sectors not on
+chain, and sectors which files aren't in the storage paths, are reported
+separately; files on the storage of external sealers must be mounted locally
+to be checked.`,
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:  "storage",
+			Usage: "storage path holding sealed/ and cache/ directories, can be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "tipset",
+			Usage: "tipset to read sectors at, defaults to chain head",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 3 {
+			return xerrors.Errorf("expected 3 args: miner address, first and last sector number")
+		}
+		if len(cctx.StringSlice("storage")) == 0 {
+			return xerrors.Errorf("must specify at least one --storage path")
+		}
+
+		maddr, err := address.NewFromString(cctx.Args().Get(0))
+		if err != nil {
+			return xerrors.Errorf("parsing miner address: %w", err)
+		}
+		mid, err := address.IDFromAddress(maddr)
+		if err != nil {
+			return xerrors.Errorf("getting miner id: %w", err)
+		}
+
+		from, err := strconv.ParseUint(cctx.Args().Get(1), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("parsing first sector number: %w", err)
+		}
+		to, err := strconv.ParseUint(cctx.Args().Get(2), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("parsing last sector number: %w", err)
+		}
+		if to < from {
+			return xerrors.Errorf("last sector number %d is before the first %d", to, from)
+		}
+
+		api, closer, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ts, err := lcli.LoadTipSet(ctx, cctx, api)
+		if err != nil {
+			return err
+		}
+		tsk := types.EmptyTSK
+		if ts != nil {
+			tsk = ts.Key()
+		}
+
+		var ok, bad, missing, notOnChain int
+		for n := from; n <= to; n++ {
+			num := abi.SectorNumber(n)
+			sid := abi.SectorID{Miner: abi.ActorID(mid), Number: num}
+
+			info, err := api.StateSectorGetInfo(ctx, maddr, num, tsk)
+			if err != nil {
+				return xerrors.Errorf("getting info of sector %d: %w", num, err)
+			}
+			if info == nil {
+				notOnChain++
+				continue
+			}
+
+			sealed, cache := findSectorFiles(cctx.StringSlice("storage"), sid)
+			if sealed == "" || cache == "" {
+				missing++
+				fmt.Printf("sector %d: files not found (sealed %q, cache %q)\n", num, sealed, cache)
+				continue
+			}
+
+			if err := checkCommR(sid, info.SealProof, info.SealedCID, sealed, cache); err != nil {
+				bad++
+				fmt.Printf("sector %d: MISMATCH with on-chain CommR %s: %s\n", num, info.SealedCID, err)
+				continue
+			}
+			ok++
+		}
+
+		fmt.Printf("checked %d sectors: %d ok, %d mismatched, %d without local files, %d not on chain\n", to-from+1, ok, bad, missing, notOnChain)
+		if bad > 0 {
+			return xerrors.Errorf("%d sectors don't match their on-chain CommR", bad)
+		}
+		return nil
+	},
+}
+
+// findSectorFiles returns the paths of the sealed file and cache of the
+// sector in the first storage paths having them
+func findSectorFiles(paths []string, sid abi.SectorID) (sealed, cache string) {
+	name := storiface.SectorName(sid)
+	for _, p := range paths {
+		if s := filepath.Join(p, storiface.FTSealed.String(), name); sealed == "" && exists(s) {
+			sealed = s
+		}
+		if c := filepath.Join(p, storiface.FTCache.String(), name); cache == "" && exists(c) {
+			cache = c
+		}
+	}
+	return sealed, cache
+}
+
+func exists(p string) bool {
+	_, err := os.Stat(p)
+	return err == nil
+}
+
+// checkCommR generates a vanilla proof of the sector for random challenges,
+// which fails if the files don't match the sealed CID
+func checkCommR(sid abi.SectorID, spt abi.RegisteredSealProof, commR cid.Cid, sealed, cache string) error {
+	wpp, err := spt.RegisteredWindowPoStProof()
+	if err != nil {
+		return err
+	}
+
+	var pr abi.PoStRandomness = make([]byte, abi.RandomnessLength)
+	_, _ = rand.Read(pr)
+	pr[31] &= 0x3f
+
+	ch, err := ffi.GeneratePoStFallbackSectorChallenges(wpp, sid.Miner, pr, []abi.SectorNumber{sid.Number})
+	if err != nil {
+		return xerrors.Errorf("generating challenges: %w", err)
+	}
+
+	_, err = ffi.GenerateSingleVanillaProof(ffi.PrivateSectorInfo{
+		SectorInfo: proof.SectorInfo{
+			SealProof:    spt,
+			SectorNumber: sid.Number,
+			SealedCID:    commR,
+		},
+		CacheDirPath:     cache,
+		PoStProofType:    wpp,
+		SealedSectorPath: sealed,
+	}, ch.Challenges[sid.Number])
+	if err != nil {
+		return xerrors.Errorf("generating vanilla proof: %w", err)
+	}
+	return nil
+}
@@ -26,6 +26,8 @@ var sectorsCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		terminateSectorCmd,
 		terminateSectorPenaltyEstimationCmd,
+		sectorsCheckCommCIDsCmd,
+		sectorsVerifyCommRCmd,
 	},
 }
 
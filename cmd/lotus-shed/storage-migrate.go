@@ -0,0 +1,414 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/mitchellh/go-homedir"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/node/repo"
+)
+
+const migrateProgressFile = "migrate-progress.json"
+
+var storageMigrateCmd = &cli.Command{
+	Name:  "storage-migrate",
+	Usage: "Move all sector files from a storage path into a local storage path",
+	Description: `Copies every sector file declared in the source storage path into the
+destination path through the fetch API, so the source may be on another
+machine. Each file is verified against a checksum of the data received, then
+the destination is declared in the sector index and the source declaration is
+dropped. Source files are left in place; remove them once the migration is done.
+
+The destination must be an initialized storage path attached to the miner or
+to a worker. Progress is kept in the destination path, so an interrupted
+migration can be resumed by running the command again. Sectors which are locked
+for writing (e.g. being sealed) are skipped, and reported at the end.`,
+	ArgsUsage: "[source storage ID] [destination path]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "dry-run",
+			Usage: "only list the files which would be moved",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 2 {
+			return xerrors.Errorf("expected 2 arguments: source storage ID, destination path")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		ainfo, err := lcli.GetAPIInfo(cctx, repo.StorageMiner)
+		if err != nil {
+			return xerrors.Errorf("could not get miner api info: %w", err)
+		}
+
+		srcID := stores.ID(cctx.Args().Get(0))
+		dest, err := homedir.Expand(cctx.Args().Get(1))
+		if err != nil {
+			return err
+		}
+
+		mb, err := ioutil.ReadFile(filepath.Join(dest, stores.MetaFile))
+		if err != nil {
+			return xerrors.Errorf("reading storage metadata for %s: %w", dest, err)
+		}
+		var meta stores.LocalStorageMeta
+		if err := json.Unmarshal(mb, &meta); err != nil {
+			return xerrors.Errorf("unmarshalling storage metadata for %s: %w", dest, err)
+		}
+		if meta.ID == srcID {
+			return xerrors.Errorf("source and destination are the same storage path")
+		}
+		if _, err := nodeApi.StorageInfo(ctx, meta.ID); err != nil {
+			return xerrors.Errorf("destination path %s isn't attached: %w", meta.ID, err)
+		}
+
+		decls, err := nodeApi.StorageList(ctx)
+		if err != nil {
+			return xerrors.Errorf("listing storage: %w", err)
+		}
+		srcDecls, ok := decls[srcID]
+		if !ok {
+			return xerrors.Errorf("storage path %s not found", srcID)
+		}
+		sort.Slice(srcDecls, func(i, j int) bool {
+			if srcDecls[i].Miner != srcDecls[j].Miner {
+				return srcDecls[i].Miner < srcDecls[j].Miner
+			}
+			return srcDecls[i].Number < srcDecls[j].Number
+		})
+
+		progress, err := loadMigrateProgress(dest)
+		if err != nil {
+			return err
+		}
+
+		var moved, done, skipped int
+		for _, decl := range srcDecls {
+			for _, ft := range storiface.PathTypes {
+				if decl.SectorFileType&ft == 0 {
+					continue
+				}
+				name := filepath.Join(ft.String(), storiface.SectorName(decl.SectorID))
+
+				if _, ok := progress.Done[name]; ok {
+					done++
+					continue
+				}
+
+				if cctx.Bool("dry-run") {
+					fmt.Println(name)
+					continue
+				}
+
+				m := &sectorMigration{
+					api:    nodeApi,
+					auth:   ainfo.AuthHeader(),
+					src:    srcID,
+					dest:   meta.ID,
+					path:   dest,
+					sector: decl.SectorID,
+					ft:     ft,
+				}
+				sum, err := m.run(ctx)
+				if err == errSectorLocked {
+					fmt.Printf("%s: locked, skipping\n", name)
+					skipped++
+					continue
+				}
+				if err != nil {
+					return xerrors.Errorf("migrating %s: %w", name, err)
+				}
+
+				progress.Done[name] = fmt.Sprintf("%x", sum)
+				if err := progress.save(dest); err != nil {
+					return err
+				}
+
+				fmt.Printf("%s: moved (sha256 %x)\n", name, sum)
+				moved++
+			}
+		}
+
+		if cctx.Bool("dry-run") {
+			return nil
+		}
+
+		fmt.Printf("moved %d files, %d already moved before", moved, done)
+		if skipped > 0 {
+			fmt.Printf(", %d skipped; run again to retry those", skipped)
+		}
+		fmt.Println()
+
+		return nil
+	},
+}
+
+var errSectorLocked = xerrors.New("sector locked")
+
+type migrateProgress struct {
+	// sector file (e.g. "sealed/s-t01000-1") -> hex sha256 of its contents
+	Done map[string]string
+}
+
+func loadMigrateProgress(path string) (*migrateProgress, error) {
+	p := &migrateProgress{Done: map[string]string{}}
+
+	b, err := ioutil.ReadFile(filepath.Join(path, migrateProgressFile))
+	switch {
+	case os.IsNotExist(err):
+		return p, nil
+	case err != nil:
+		return nil, xerrors.Errorf("reading migration progress: %w", err)
+	}
+
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, xerrors.Errorf("unmarshalling migration progress: %w", err)
+	}
+	if p.Done == nil {
+		p.Done = map[string]string{}
+	}
+	return p, nil
+}
+
+func (p *migrateProgress) save(path string) error {
+	b, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := filepath.Join(path, migrateProgressFile+".tmp")
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		return xerrors.Errorf("writing migration progress: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(path, migrateProgressFile))
+}
+
+type sectorMigration struct {
+	api  api.StorageMiner
+	auth http.Header
+
+	src, dest stores.ID
+	path      string
+
+	sector abi.SectorID
+	ft     storiface.SectorFileType
+}
+
+// run copies one sector file into the destination path and moves its index
+// declaration over. It returns the checksum of the copied data.
+func (m *sectorMigration) run(ctx context.Context) ([]byte, error) {
+	// hold a read lock so the file doesn't get moved or removed under us
+	lctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	locked, err := m.api.StorageTryLock(lctx, m.sector, m.ft, storiface.FTNone)
+	if err != nil {
+		return nil, xerrors.Errorf("locking sector: %w", err)
+	}
+	if !locked {
+		return nil, errSectorLocked
+	}
+
+	si, err := m.api.StorageFindSector(ctx, m.sector, m.ft, 0, false)
+	if err != nil {
+		return nil, xerrors.Errorf("finding sector: %w", err)
+	}
+
+	var src *stores.SectorStorageInfo
+	for i := range si {
+		if si[i].ID == m.src {
+			src = &si[i]
+		}
+	}
+	if src == nil {
+		return nil, xerrors.Errorf("sector no longer declared in the source path")
+	}
+
+	final := filepath.Join(m.path, m.ft.String(), storiface.SectorName(m.sector))
+	// fetch into the temp dir, so that the store doesn't pick up partial files
+	tmpDir := filepath.Join(m.path, stores.FetchTempSubdir)
+	if err := os.MkdirAll(tmpDir, 0755); err != nil { // nolint
+		return nil, xerrors.Errorf("creating temp dir: %w", err)
+	}
+	tmp := filepath.Join(tmpDir, "migrate-"+m.ft.String()+"-"+storiface.SectorName(m.sector))
+
+	var merr error
+	for _, url := range src.URLs {
+		sum, err := m.fetch(ctx, url, tmp)
+		if err != nil {
+			merr = multierror.Append(merr, xerrors.Errorf("fetch error %s: %w", url, err))
+			continue
+		}
+
+		if err := os.RemoveAll(final); err != nil {
+			return nil, xerrors.Errorf("removing stale destination: %w", err)
+		}
+		if err := os.Rename(tmp, final); err != nil {
+			return nil, xerrors.Errorf("moving into place: %w", err)
+		}
+
+		if err := m.api.StorageDeclareSector(ctx, m.dest, m.sector, m.ft, src.Primary); err != nil {
+			return nil, xerrors.Errorf("declaring sector in destination: %w", err)
+		}
+		if err := m.api.StorageDropSector(ctx, m.src, m.sector, m.ft); err != nil {
+			return nil, xerrors.Errorf("dropping sector from source: %w", err)
+		}
+
+		return sum, nil
+	}
+
+	return nil, xerrors.Errorf("fetching failed: %w", merr)
+}
+
+// fetch downloads a sector file into outname, then reads back what was written
+// and checks it against what was received. The returned checksum covers the
+// contents of all files in order of their names.
+func (m *sectorMigration) fetch(ctx context.Context, url, outname string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, xerrors.Errorf("request: %w", err)
+	}
+	req.Header = m.auth
+	req = req.WithContext(ctx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != 200 {
+		return nil, xerrors.Errorf("non-200 code: %d", resp.StatusCode)
+	}
+
+	mediatype, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return nil, xerrors.Errorf("parse media type: %w", err)
+	}
+
+	if err := os.RemoveAll(outname); err != nil {
+		return nil, xerrors.Errorf("removing dest: %w", err)
+	}
+
+	// file name ("" for a plain file) -> sha256 of data received
+	received := map[string][]byte{}
+
+	switch mediatype {
+	case "application/x-tar":
+		if err := os.MkdirAll(outname, 0755); err != nil { // nolint
+			return nil, xerrors.Errorf("mkdir: %w", err)
+		}
+
+		tr := tar.NewReader(resp.Body)
+		for {
+			header, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, xerrors.Errorf("reading tar: %w", err)
+			}
+
+			name := filepath.Base(header.Name)
+			sum, err := writeHashed(filepath.Join(outname, name), tr)
+			if err != nil {
+				return nil, err
+			}
+			received[name] = sum
+		}
+	case "application/octet-stream":
+		sum, err := writeHashed(outname, resp.Body)
+		if err != nil {
+			return nil, err
+		}
+		received[""] = sum
+	default:
+		return nil, xerrors.Errorf("unknown CopyFrom content type: %s", mediatype)
+	}
+
+	return verifyMigrated(outname, received)
+}
+
+func writeHashed(path string, r io.Reader) ([]byte, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, xerrors.Errorf("creating %s: %w", path, err)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(f, h), r); err != nil {
+		_ = f.Close()
+		return nil, xerrors.Errorf("writing %s: %w", path, err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return nil, xerrors.Errorf("syncing %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// verifyMigrated re-hashes the files written to path, and checks that they
+// match the checksums of the data received.
+func verifyMigrated(path string, received map[string][]byte) ([]byte, error) {
+	names := make([]string, 0, len(received))
+	for name := range received {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	total := sha256.New()
+	for _, name := range names {
+		f, err := os.Open(filepath.Join(path, name))
+		if err != nil {
+			return nil, xerrors.Errorf("opening %s for verification: %w", name, err)
+		}
+
+		h := sha256.New()
+		_, err = io.Copy(h, f)
+		_ = f.Close()
+		if err != nil {
+			return nil, xerrors.Errorf("reading %s for verification: %w", name, err)
+		}
+
+		sum := h.Sum(nil)
+		if !bytes.Equal(sum, received[name]) {
+			return nil, xerrors.Errorf("checksum mismatch for %s: wrote %x, received %x", filepath.Join(path, name), sum, received[name])
+		}
+
+		_, _ = total.Write([]byte(name))
+		_, _ = total.Write(sum)
+	}
+
+	return total.Sum(nil), nil
+}
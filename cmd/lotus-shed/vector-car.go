@@ -0,0 +1,269 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	block "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+	"github.com/ipld/go-car"
+	"github.com/urfave/cli/v2"
+	cbg "github.com/whyrusleeping/cbor-gen"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/chain/actors/builtin"
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/conformance"
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+var vectorCarCmd = &cli.Command{
+	Name:      "vector-car",
+	Usage:     "print statistics about the CAR embedded in a test vector",
+	ArgsUsage: "[vector.json]",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:  "top",
+			Usage: "number of largest actor subtrees to list per state root",
+			Value: 10,
+		},
+		&cli.BoolFlag{
+			Name:  "list-unreferenced",
+			Usage: "list the blocks not reachable from any root",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected one vector file")
+		}
+
+		b, err := ioutil.ReadFile(cctx.Args().First())
+		if err != nil {
+			return err
+		}
+		var vector schema.TestVector
+		if err := json.Unmarshal(b, &vector); err != nil {
+			return xerrors.Errorf("parsing vector: %w", err)
+		}
+
+		blks, carRoots, err := readVectorCar(vector.CAR)
+		if err != nil {
+			return err
+		}
+
+		var total uint64
+		byCodec := map[uint64]*objStat{}
+		bs := blockstore.NewTemporary()
+		for _, blk := range blks {
+			size := uint64(len(blk.RawData()))
+			total += size
+
+			codec := blk.Cid().Prefix().Codec
+			if byCodec[codec] == nil {
+				byCodec[codec] = &objStat{}
+			}
+			byCodec[codec].add(size)
+
+			if err := bs.Put(blk); err != nil {
+				return err
+			}
+		}
+
+		if vector.Meta != nil {
+			fmt.Printf("Vector:\t%s\n", vector.Meta.ID)
+		}
+		fmt.Printf("CAR:\t%s compressed, %s uncompressed\n", types.SizeStr(types.NewInt(uint64(len(vector.CAR)))), types.SizeStr(types.NewInt(total)))
+		fmt.Printf("Blocks:\t%d\n", len(blks))
+		codecs := make([]uint64, 0, len(byCodec))
+		for codec := range byCodec {
+			codecs = append(codecs, codec)
+		}
+		sort.Slice(codecs, func(i, j int) bool { return codecs[i] < codecs[j] })
+		for _, codec := range codecs {
+			st := byCodec[codec]
+			fmt.Printf("  %s:\t%d blocks, %s\n", cid.CodecToStr[codec], st.blocks, types.SizeStr(types.NewInt(st.size)))
+		}
+
+		type namedRoot struct {
+			name string
+			root cid.Cid
+		}
+		var roots []namedRoot
+		for _, c := range carRoots {
+			roots = append(roots, namedRoot{"car root", c})
+		}
+		if vector.Pre != nil && vector.Pre.StateTree != nil {
+			roots = append(roots, namedRoot{"pre state", vector.Pre.StateTree.RootCID})
+		}
+		if vector.Post != nil && vector.Post.StateTree != nil {
+			roots = append(roots, namedRoot{"post state", vector.Post.StateTree.RootCID})
+		}
+
+		fmt.Println()
+		reachable := map[cid.Cid]struct{}{}
+		for _, r := range roots {
+			st, err := walkCarObjects(blks, r.root, reachable)
+			if err != nil {
+				return xerrors.Errorf("walking %s %s: %w", r.name, r.root, err)
+			}
+			fmt.Printf("%s %s:\t%d blocks, %s\n", r.name, r.root, st.blocks, types.SizeStr(types.NewInt(st.size)))
+		}
+
+		var unref objStat
+		var unrefCids []cid.Cid
+		for c, blk := range blks {
+			if _, ok := reachable[c]; ok {
+				continue
+			}
+			unref.add(uint64(len(blk.RawData())))
+			unrefCids = append(unrefCids, c)
+		}
+		fmt.Printf("Unreferenced:\t%d blocks, %s\n", unref.blocks, types.SizeStr(types.NewInt(unref.size)))
+		if cctx.Bool("list-unreferenced") {
+			sort.Slice(unrefCids, func(i, j int) bool {
+				return unrefCids[i].KeyString() < unrefCids[j].KeyString()
+			})
+			for _, c := range unrefCids {
+				fmt.Printf("  %s\t%d\n", c, len(blks[c].RawData()))
+			}
+		}
+
+		cst := cbor.NewCborStore(bs)
+		for _, r := range roots {
+			if r.name == "car root" {
+				continue
+			}
+			if err := printActorSubtrees(cst, blks, r.name, r.root, cctx.Int("top")); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	},
+}
+
+type objStat struct {
+	blocks int
+	size   uint64
+}
+
+func (s *objStat) add(size uint64) {
+	s.blocks++
+	s.size += size
+}
+
+func readVectorCar(data []byte) (map[cid.Cid]block.Block, []cid.Cid, error) {
+	zr, err := conformance.NewCARReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, xerrors.Errorf("inflating CAR: %w", err)
+	}
+	defer zr.Close() // nolint
+
+	cr, err := car.NewCarReader(zr)
+	if err != nil {
+		return nil, nil, xerrors.Errorf("reading CAR: %w", err)
+	}
+
+	blks := map[cid.Cid]block.Block{}
+	for {
+		blk, err := cr.Next()
+		switch err {
+		case io.EOF:
+			return blks, cr.Header.Roots, nil
+		case nil:
+			blks[blk.Cid()] = blk
+		default:
+			return nil, nil, xerrors.Errorf("reading CAR block: %w", err)
+		}
+	}
+}
+
+// walkCarObjects sums up the blocks reachable from root which are present in
+// the CAR, marking them in seen; blocks already seen are not counted again.
+func walkCarObjects(blks map[cid.Cid]block.Block, root cid.Cid, seen map[cid.Cid]struct{}) (objStat, error) {
+	var out objStat
+	queue := []cid.Cid{root}
+	for len(queue) > 0 {
+		c := queue[0]
+		queue = queue[1:]
+
+		if _, ok := seen[c]; ok {
+			continue
+		}
+		blk, ok := blks[c]
+		if !ok {
+			continue // not included in the vector
+		}
+		seen[c] = struct{}{}
+		out.add(uint64(len(blk.RawData())))
+
+		if c.Prefix().Codec != cid.DagCBOR {
+			continue
+		}
+		err := cbg.ScanForLinks(bytes.NewReader(blk.RawData()), func(l cid.Cid) {
+			queue = append(queue, l)
+		})
+		if err != nil {
+			return objStat{}, xerrors.Errorf("scanning %s for links: %w", c, err)
+		}
+	}
+	return out, nil
+}
+
+func printActorSubtrees(cst cbor.IpldStore, blks map[cid.Cid]block.Block, name string, root cid.Cid, top int) error {
+	if _, ok := blks[root]; !ok {
+		return nil
+	}
+
+	tree, err := state.LoadStateTree(cst, root)
+	if err != nil {
+		return xerrors.Errorf("loading %s tree: %w", name, err)
+	}
+
+	type actorStat struct {
+		addr address.Address
+		code cid.Cid
+		objStat
+	}
+	var stats []actorStat
+	err = tree.ForEach(func(addr address.Address, act *types.Actor) error {
+		st, err := walkCarObjects(blks, act.Head, map[cid.Cid]struct{}{})
+		if err != nil {
+			return xerrors.Errorf("walking state of %s: %w", addr, err)
+		}
+		if st.blocks > 0 {
+			stats = append(stats, actorStat{addr: addr, code: act.Code, objStat: st})
+		}
+		return nil
+	})
+	if err != nil {
+		// the vector only holds the parts of the state tree it accessed
+		fmt.Printf("\n%s: only partially included (%s)\n", name, err)
+	}
+
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].size > stats[j].size
+	})
+	if len(stats) > top {
+		stats = stats[:top]
+	}
+
+	fmt.Printf("\nLargest actor subtrees in %s:\n", name)
+	tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintf(tw, "Address\tActor\tBlocks\tSize\n")
+	for _, st := range stats {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", st.addr, builtin.ActorNameByCode(st.code), st.blocks, types.SizeStr(types.NewInt(st.size)))
+	}
+	return tw.Flush()
+}
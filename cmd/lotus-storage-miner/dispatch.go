@@ -0,0 +1,48 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/dispatchschema"
+)
+
+var dispatchCmd = &cli.Command{
+	Name:  "dispatch",
+	Usage: "Inspect how the miner dispatches calls to workers",
+	Subcommands: []*cli.Command{
+		dispatchSchemaCmd,
+	},
+}
+
+var dispatchSchemaCmd = &cli.Command{
+	Name:  "schema",
+	Usage: "Print the request/response schema of calls dispatched to workers",
+	Description: `Prints a JSON Schema describing the parameters of every call dispatched to
+   workers, and of the WorkerReturn call the worker reports its result with.
+   This is the contract third-party sealers implement. With --proto, prints
+   equivalent proto3 definitions instead.
+
+   The same definitions are kept in documentation/en by go generate.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "proto",
+			Usage: "print proto3 definitions instead of JSON Schema",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		gen := dispatchschema.JSONSchema
+		if cctx.Bool("proto") {
+			gen = dispatchschema.Proto
+		}
+
+		out, err := gen()
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(out))
+		return nil
+	},
+}
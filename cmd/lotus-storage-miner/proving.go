@@ -27,8 +27,10 @@ var provingCmd = &cli.Command{
 		provingInfoCmd,
 		provingDeadlinesCmd,
 		provingDeadlineInfoCmd,
+		provingDeadlineSectorsCmd,
 		provingFaultsCmd,
 		provingCheckProvableCmd,
+		provingCalendarCmd,
 	},
 }
 
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var provingCalendarCmd = &cli.Command{
+	Name:  "calendar",
+	Usage: "Export the window PoSt deadlines of the coming proving periods",
+	Description: `Lists every deadline of the current proving period and of the following ones,
+   with their epochs, estimated times, and the partitions and sectors currently
+   assigned to them, to plan maintenance around proving.
+
+   The iCal output has one event per deadline with sectors to prove, and can be
+   imported into calendar applications.`,
+	Flags: []cli.Flag{
+		&cli.Uint64Flag{
+			Name:  "periods",
+			Usage: "number of proving periods to list after the current one",
+			Value: 1,
+		},
+		&cli.StringFlag{
+			Name:  "format",
+			Usage: "output format, json or ical",
+			Value: "json",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		cal, err := nodeApi.ProvingCalendar(ctx, cctx.Uint64("periods"))
+		if err != nil {
+			return xerrors.Errorf("getting proving calendar: %w", err)
+		}
+
+		switch cctx.String("format") {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(cal)
+		case "ical":
+			return writeICal(os.Stdout, cal, time.Now())
+		default:
+			return xerrors.Errorf("unknown format %q", cctx.String("format"))
+		}
+	},
+}
+
+const icalTimeFormat = "20060102T150405Z"
+
+// writeICal writes the deadlines with sectors to prove as iCalendar events
+func writeICal(w io.Writer, cal *api.ProvingCalendar, now time.Time) error {
+	var b strings.Builder
+	line := func(format string, args ...interface{}) {
+		b.WriteString(fmt.Sprintf(format, args...))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//lotus//proving calendar//EN")
+	line("X-WR-CALNAME:Window PoSt %s", cal.Miner)
+
+	for _, dl := range cal.Deadlines {
+		if dl.Sectors == 0 {
+			continue
+		}
+
+		line("BEGIN:VEVENT")
+		line("UID:%s-%d-%d@lotus", cal.Miner, dl.PeriodStart, dl.Index)
+		line("DTSTAMP:%s", now.UTC().Format(icalTimeFormat))
+		line("DTSTART:%s", dl.OpenTime.UTC().Format(icalTimeFormat))
+		line("DTEND:%s", dl.CloseTime.UTC().Format(icalTimeFormat))
+		line("SUMMARY:%s deadline %d: %d sectors", cal.Miner, dl.Index, dl.Sectors)
+		line("DESCRIPTION:Epochs %d-%d\\, %d partitions\\, %d sectors (%d faulty)", dl.Open, dl.Close, dl.Partitions, dl.Sectors, dl.Faults)
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
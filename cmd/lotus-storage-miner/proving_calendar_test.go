@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestWriteICal(t *testing.T) {
+	maddr, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+
+	open := time.Date(2020, 11, 1, 10, 0, 0, 0, time.UTC)
+	cal := &api.ProvingCalendar{
+		Miner: maddr,
+		Deadlines: []api.CalendarDeadline{
+			{PeriodStart: 100, Index: 0, Open: 100, Close: 160, OpenTime: open, CloseTime: open.Add(30 * time.Minute), Partitions: 1, Sectors: 10, Faults: 1},
+			{PeriodStart: 100, Index: 1, Open: 160, Close: 220, OpenTime: open.Add(30 * time.Minute), CloseTime: open.Add(time.Hour)},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, writeICal(&buf, cal, open))
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, "BEGIN:VCALENDAR\r\n"))
+	require.True(t, strings.HasSuffix(out, "END:VCALENDAR\r\n"))
+
+	// deadlines without sectors aren't listed
+	require.Equal(t, 1, strings.Count(out, "BEGIN:VEVENT"))
+	require.Contains(t, out, "UID:"+maddr.String()+"-100-0@lotus\r\n")
+	require.Contains(t, out, "DTSTART:20201101T100000Z\r\n")
+	require.Contains(t, out, "DTEND:20201101T103000Z\r\n")
+	require.Contains(t, out, "1 partitions\\, 10 sectors (1 faulty)")
+}
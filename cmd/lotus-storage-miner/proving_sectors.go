@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+// Window PoSt challenges wpostChallengesPerSector leaves of every proven
+// sector. Proving a leaf rebuilds the rows of tree-r-last which aren't cached,
+// reading 64 nodes of the replica, and at least a page from disk.
+const (
+	wpostChallengesPerSector = 10
+	wpostReadPerChallenge    = 4 << 10
+)
+
+var provingDeadlineSectorsCmd = &cli.Command{
+	Name:      "deadline-sectors",
+	Usage:     "List the partitions and sectors of a deadline, with estimated proving costs",
+	ArgsUsage: "<deadlineIdx>",
+	Description: `Lists the partitions of the deadline, and the sectors in them, with estimates of
+   the data read from disk to prove them, and of the gas verifying the
+   submitted proofs costs on chain, which is the bulk of the gas of PoSt
+   messages. Estimates help deciding whether to compact partitions, or to
+   move sectors to other deadlines.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "summary",
+			Usage: "only list partitions, not the sectors in them",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("must pass deadline index")
+		}
+
+		dlIdx, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse deadline index: %w", err)
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := getActorAddress(ctx, nodeApi, cctx.String("actor"))
+		if err != nil {
+			return err
+		}
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting chain head: %w", err)
+		}
+
+		mi, err := api.StateMinerInfo(ctx, maddr, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting miner info: %w", err)
+		}
+
+		partitions, err := api.StateMinerPartitions(ctx, maddr, dlIdx, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting partitions for deadline %d: %w", dlIdx, err)
+		}
+
+		partsPerMsg, err := policy.GetMaxPoStPartitions(mi.WindowPoStProofType)
+		if err != nil {
+			return xerrors.Errorf("getting partitions per message: %w", err)
+		}
+
+		toProve := make([]uint64, len(partitions))
+		all := make([]bitfield.BitField, len(partitions))
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "partition\tsectors\tfaults\trecovering\tto prove\tread\tverify gas")
+
+		pl := vm.PricelistByEpoch(head.Height())
+		for pIdx, partition := range partitions {
+			all[pIdx] = partition.AllSectors
+
+			prove, err := bitfield.SubtractBitField(partition.LiveSectors, partition.FaultySectors)
+			if err != nil {
+				return err
+			}
+			prove, err = bitfield.MultiMerge(prove, partition.RecoveringSectors)
+			if err != nil {
+				return err
+			}
+
+			counts := make([]uint64, 0, 4)
+			for _, bf := range []bitfield.BitField{partition.AllSectors, partition.FaultySectors, partition.RecoveringSectors, prove} {
+				c, err := bf.Count()
+				if err != nil {
+					return err
+				}
+				counts = append(counts, c)
+			}
+			toProve[pIdx] = counts[3]
+
+			_, gas := wpostVerifyGas(pl, mi.WindowPoStProofType, []uint64{counts[3]}, 1)
+			_, _ = fmt.Fprintf(tw, "%d\t%d\t%d\t%d\t%d\t%s\t%d\n", pIdx, counts[0], counts[1], counts[2], counts[3],
+				types.SizeStr(types.NewInt(wpostReadBytes(counts[3]))), gas)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		var proven uint64
+		for _, n := range toProve {
+			proven += n
+		}
+
+		msgs, gas := wpostVerifyGas(pl, mi.WindowPoStProofType, toProve, partsPerMsg)
+		fee := types.BigMul(head.MinTicketBlock().ParentBaseFee, types.NewInt(uint64(gas)))
+
+		fmt.Println()
+		fmt.Printf("Sector Size:       %s\n", types.SizeStr(types.NewInt(uint64(mi.SectorSize))))
+		fmt.Printf("Proving Read:      %s\n", types.SizeStr(types.NewInt(wpostReadBytes(proven))))
+		fmt.Printf("PoSt Messages:     %d (%d partitions per message)\n", msgs, partsPerMsg)
+		fmt.Printf("Verify Gas:        %d\n", gas)
+		fmt.Printf("Base Fee Cost:     %s (at current base fee)\n", types.FIL(fee).Short())
+
+		if cctx.Bool("summary") || len(partitions) == 0 {
+			return nil
+		}
+
+		union, err := bitfield.MultiMerge(all...)
+		if err != nil {
+			return err
+		}
+
+		sectors, err := api.StateMinerSectors(ctx, maddr, &union, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting sectors: %w", err)
+		}
+
+		states := map[abi.SectorNumber]string{}
+		parts := map[abi.SectorNumber]int{}
+		for pIdx, partition := range partitions {
+			for _, s := range []struct {
+				bf    bitfield.BitField
+				state string
+			}{
+				{partition.AllSectors, "terminated"},
+				{partition.LiveSectors, "unproven"},
+				{partition.ActiveSectors, "active"},
+				{partition.FaultySectors, "faulty"},
+				{partition.RecoveringSectors, "recovering"},
+			} {
+				if err := s.bf.ForEach(func(n uint64) error {
+					states[abi.SectorNumber(n)] = s.state
+					parts[abi.SectorNumber(n)] = pIdx
+					return nil
+				}); err != nil {
+					return err
+				}
+			}
+		}
+
+		fmt.Println()
+		tw = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "sector\tpartition\tstate\tactivation\texpiration\tdeals")
+		for _, s := range sectors {
+			_, _ = fmt.Fprintf(tw, "%d\t%d\t%s\t%d\t%d\t%d\n", s.SectorNumber, parts[s.SectorNumber], states[s.SectorNumber], s.Activation, s.Expiration, len(s.DealIDs))
+		}
+		return tw.Flush()
+	},
+}
+
+// wpostReadBytes estimates the data read from disk to prove the sectors
+func wpostReadBytes(sectors uint64) uint64 {
+	return sectors * wpostChallengesPerSector * wpostReadPerChallenge
+}
+
+// wpostVerifyGas returns how many messages prove the partitions, with
+// toProve sectors each, and the gas verifying their proofs costs
+func wpostVerifyGas(pl vm.Pricelist, ppt abi.RegisteredPoStProof, toProve []uint64, partsPerMsg int) (int, int64) {
+	if partsPerMsg < 1 {
+		partsPerMsg = 1
+	}
+
+	var msgs int
+	var gas int64
+	for start := 0; start < len(toProve); start += partsPerMsg {
+		end := start + partsPerMsg
+		if end > len(toProve) {
+			end = len(toProve)
+		}
+
+		var n uint64
+		for _, c := range toProve[start:end] {
+			n += c
+		}
+
+		msgs++
+		gas += pl.OnVerifyPost(proof2.WindowPoStVerifyInfo{
+			Proofs:            []proof2.PoStProof{{PoStProof: ppt}},
+			ChallengedSectors: make([]proof2.SectorInfo, n),
+		}).Total()
+	}
+	return msgs, gas
+}
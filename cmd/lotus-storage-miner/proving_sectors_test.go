@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+
+	"github.com/filecoin-project/lotus/chain/vm"
+)
+
+func TestWpostVerifyGas(t *testing.T) {
+	pl := vm.PricelistByEpoch(0)
+	ppt := abi.RegisteredPoStProof_StackedDrgWindow32GiBV1
+
+	verify := func(n int) int64 {
+		return pl.OnVerifyPost(proof2.WindowPoStVerifyInfo{
+			Proofs:            []proof2.PoStProof{{PoStProof: ppt}},
+			ChallengedSectors: make([]proof2.SectorInfo, n),
+		}).Total()
+	}
+
+	// partitions are proven three to a message
+	msgs, gas := wpostVerifyGas(pl, ppt, []uint64{2349, 2349, 100, 10}, 3)
+	require.Equal(t, 2, msgs)
+	require.Equal(t, verify(2349+2349+100)+verify(10), gas)
+
+	msgs, gas = wpostVerifyGas(pl, ppt, nil, 3)
+	require.Equal(t, 0, msgs)
+	require.Equal(t, int64(0), gas)
+
+	require.Equal(t, uint64(2*10*4<<10), wpostReadBytes(2))
+}
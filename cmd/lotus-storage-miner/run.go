@@ -162,6 +162,11 @@ var runCmd = &cli.Command{
 
 		mux.Handle("/rpc/v0", rpcServer)
 		mux.PathPrefix("/remote").HandlerFunc(minerapi.(*impl.StorageMinerAPI).ServeRemote)
+		mux.HandleFunc("/dispatch/health", minerapi.(*impl.StorageMinerAPI).ServeDispatchHealth)
+		mux.PathPrefix("/params").HandlerFunc(minerapi.(*impl.StorageMinerAPI).ServeParams)
+		mux.PathPrefix("/unsealed").HandlerFunc(minerapi.(*impl.StorageMinerAPI).ServeUnsealed)
+		mux.HandleFunc("/healthz", minerapi.(*impl.StorageMinerAPI).ServeHealthz)
+		mux.HandleFunc("/readyz", minerapi.(*impl.StorageMinerAPI).ServeReadyz)
 		mux.PathPrefix("/").Handler(http.DefaultServeMux) // pprof
 
 		ah := &auth.Handler{
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"sort"
+	"strconv"
 	"strings"
 	"text/tabwriter"
 	"time"
@@ -15,6 +16,8 @@ import (
 	"github.com/urfave/cli/v2"
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-state-types/abi"
+
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 
 	"github.com/filecoin-project/lotus/chain/types"
@@ -29,6 +32,9 @@ var sealingCmd = &cli.Command{
 		sealingWorkersCmd,
 		sealingSchedDiagCmd,
 		sealingAbortCmd,
+		sealingLogsCmd,
+		sealingThrottleCmd,
+		sealingStatsCmd,
 	},
 }
 
@@ -117,6 +123,17 @@ var sealingWorkersCmd = &cli.Command{
 			for _, gpu := range stat.Info.Resources.GPUs {
 				fmt.Printf("\tGPU: %s\n", color.New(gpuCol).Sprintf("%s, %sused", gpu, gpuUse))
 			}
+			for _, n := range stat.Info.Resources.NUMANodes {
+				fmt.Printf("\tNUMA: node %d, %d core(s), %s\n", n.ID, n.CPUs, types.SizeStr(types.NewInt(n.MemPhysical)))
+			}
+			if len(stat.Info.Benchmark) > 0 {
+				var scores []string
+				for task, score := range stat.Info.Benchmark {
+					scores = append(scores, fmt.Sprintf("%s %.2f", task.Short(), score))
+				}
+				sort.Strings(scores)
+				fmt.Printf("\tBench: %s\n", strings.Join(scores, ", "))
+			}
 		}
 
 		return nil
@@ -188,7 +205,7 @@ var sealingJobsCmd = &cli.Command{
 		}
 
 		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
-		_, _ = fmt.Fprintf(tw, "ID\tSector\tWorker\tHostname\tTask\tState\tTime\n")
+		_, _ = fmt.Fprintf(tw, "ID\tSector\tWorker\tHostname\tTask\tState\tPhase\tTime\n")
 
 		for _, l := range lines {
 			state := "running"
@@ -214,14 +231,24 @@ var sealingJobsCmd = &cli.Command{
 			if !ok {
 				hostname = l.Hostname
 			}
+			if l.NUMANode != nil {
+				hostname = fmt.Sprintf("%s@numa%d", hostname, *l.NUMANode)
+			}
+
+			// how long the job has been in its current phase
+			phase := "-"
+			if n := len(l.History); n > 0 {
+				phase = fmt.Sprintf("%s(%s)", l.State, time.Now().Sub(l.History[n-1].Time).Truncate(time.Second))
+			}
 
-			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\n",
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
 				hex.EncodeToString(l.ID.ID[:4]),
 				l.Sector.Number,
 				hex.EncodeToString(l.wid[:4]),
 				hostname,
 				l.Task.Short(),
 				state,
+				phase,
 				dur)
 		}
 
@@ -266,6 +293,12 @@ var sealingAbortCmd = &cli.Command{
 	Name:      "abort",
 	Usage:     "Abort a running job",
 	ArgsUsage: "[callid]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "remote",
+			Usage: "the job runs on an external sealing appliance; ask it to cancel the job, and discard its late result",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		if cctx.Args().Len() != 1 {
 			return xerrors.Errorf("expected 1 argument")
@@ -302,6 +335,97 @@ var sealingAbortCmd = &cli.Command{
 
 		fmt.Printf("aborting job %s, task %s, sector %d, running on host %s\n", job.ID.String(), job.Task.Short(), job.Sector.Number, job.Hostname)
 
+		if cctx.Bool("remote") {
+			return nodeApi.SealingAbortRemote(ctx, job.ID)
+		}
 		return nodeApi.SealingAbort(ctx, job.ID)
 	},
 }
+
+var sealingLogsCmd = &cli.Command{
+	Name:      "logs",
+	Usage:     "Print the worker output written while a job was running",
+	ArgsUsage: "[callid]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "timestamps",
+			Usage: "prefix lines with the time they were written",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return xerrors.Errorf("expected 1 argument")
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		call, err := parseCallID(cctx.Args().First())
+		if err != nil {
+			// not a full call ID, look for a running job
+			jobs, err := nodeApi.WorkerJobs(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting worker jobs: %w", err)
+			}
+
+			var found bool
+		outer:
+			for _, workerJobs := range jobs {
+				for _, j := range workerJobs {
+					if j.ID != storiface.UndefCall && strings.HasPrefix(j.ID.ID.String(), cctx.Args().First()) {
+						call, found = j.ID, true
+						break outer
+					}
+				}
+			}
+			if !found {
+				return xerrors.Errorf("job with specified id prefix not found; finished jobs need the full call id")
+			}
+		}
+
+		lines, err := nodeApi.SealingCallLogs(ctx, call)
+		if err != nil {
+			return xerrors.Errorf("getting call logs: %w", err)
+		}
+
+		for line := range lines {
+			if cctx.Bool("timestamps") {
+				fmt.Printf("%s %s\n", line.Time.Format(time.RFC3339Nano), line.Line)
+			} else {
+				fmt.Println(line.Line)
+			}
+		}
+		return nil
+	},
+}
+
+// parseCallID parses call IDs in the format of CallID.String()
+func parseCallID(s string) (storiface.CallID, error) {
+	parts := strings.SplitN(s, "-", 3)
+	if len(parts) != 3 {
+		return storiface.UndefCall, xerrors.Errorf("expected <miner>-<sector>-<uuid>")
+	}
+
+	miner, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return storiface.UndefCall, xerrors.Errorf("parsing miner id: %w", err)
+	}
+	num, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return storiface.UndefCall, xerrors.Errorf("parsing sector number: %w", err)
+	}
+	id, err := uuid.Parse(parts[2])
+	if err != nil {
+		return storiface.UndefCall, xerrors.Errorf("parsing call uuid: %w", err)
+	}
+
+	return storiface.CallID{
+		Sector: abi.SectorID{Miner: abi.ActorID(miner), Number: abi.SectorNumber(num)},
+		ID:     id,
+	}, nil
+}
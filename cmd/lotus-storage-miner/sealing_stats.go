@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var sealingStatsCmd = &cli.Command{
+	Name:  "stats",
+	Usage: "Print long-term sealing statistics, kept across restarts",
+	Flags: []cli.Flag{
+		&cli.DurationFlag{
+			Name:  "throughput-window",
+			Usage: "how far back to sum throughput",
+			Value: 24 * time.Hour,
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the raw stats as JSON",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		st, err := nodeApi.SealingStats(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting sealing stats: %w", err)
+		}
+
+		if cctx.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(st)
+		}
+
+		fmt.Printf("Collected since %s\n\n", st.Since.Format(time.RFC3339))
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Task\tPhase\tJobs\tMean\tMedian\n")
+		for _, tt := range sortedTasks(st.Phases) {
+			for _, state := range []storiface.JobState{storiface.JobQueued, storiface.JobTransferringIn, storiface.JobComputing, storiface.JobTransferringOut} {
+				h, ok := st.Phases[tt][state]
+				if !ok {
+					continue
+				}
+				n := histCount(h)
+				if n == 0 {
+					continue
+				}
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\n", tt.Short(), state, n, (h.Sum / time.Duration(n)).Truncate(time.Second), histMedian(h))
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Println()
+
+		hosts := make([]string, 0, len(st.Workers))
+		for host := range st.Workers {
+			hosts = append(hosts, host)
+		}
+		sort.Strings(hosts)
+
+		tw = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Worker\tTask\tDone\tFailed\tSuccess\n")
+		for _, host := range hosts {
+			for _, tt := range sortedTasks(st.Workers[host]) {
+				o := st.Workers[host][tt]
+				_, _ = fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%.1f%%\n", host, tt.Short(), o.Done, o.Failed, 100*float64(o.Done)/float64(o.Done+o.Failed))
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Println()
+
+		window := cctx.Duration("throughput-window")
+		done := map[sealtasks.TaskType]uint64{}
+		for _, s := range st.Throughput {
+			if time.Since(s.Hour) > window+time.Hour {
+				continue
+			}
+			for tt, n := range s.Done {
+				done[tt] += n
+			}
+		}
+
+		tw = tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Task\tDone in last %s\n", window)
+		for _, tt := range sortedTasks(done) {
+			_, _ = fmt.Fprintf(tw, "%s\t%d\n", tt.Short(), done[tt])
+		}
+		return tw.Flush()
+	},
+}
+
+// sortedTasks returns the task type keys of a map, in scheduling order
+func sortedTasks(m interface{}) []sealtasks.TaskType {
+	var out []sealtasks.TaskType
+	switch m := m.(type) {
+	case map[sealtasks.TaskType]map[storiface.JobState]storiface.DurationHistogram:
+		for tt := range m {
+			out = append(out, tt)
+		}
+	case map[sealtasks.TaskType]storiface.CallOutcomes:
+		for tt := range m {
+			out = append(out, tt)
+		}
+	case map[sealtasks.TaskType]uint64:
+		for tt := range m {
+			out = append(out, tt)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Less(out[j])
+	})
+	return out
+}
+
+func histCount(h storiface.DurationHistogram) uint64 {
+	var n uint64
+	for _, c := range h.Counts {
+		n += c
+	}
+	return n
+}
+
+// histMedian returns the upper bound of the bucket holding the median
+func histMedian(h storiface.DurationHistogram) string {
+	if len(h.Bounds) == 0 {
+		return "-"
+	}
+
+	half := (histCount(h) + 1) / 2
+
+	var n uint64
+	for i, c := range h.Counts {
+		n += c
+		if n < half {
+			continue
+		}
+		if i == len(h.Bounds) {
+			return fmt.Sprintf(">%s", h.Bounds[len(h.Bounds)-1])
+		}
+		return fmt.Sprintf("<=%s", h.Bounds[i])
+	}
+	return "-"
+}
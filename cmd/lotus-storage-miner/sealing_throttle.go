@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+// throttleTasks are the task types which can be throttled from the command
+// line, flags are named after the lowercase short task names
+var throttleTasks = []sealtasks.TaskType{
+	sealtasks.TTAddPiece,
+	sealtasks.TTPreCommit1,
+	sealtasks.TTPreCommit2,
+	sealtasks.TTCommit1,
+	sealtasks.TTCommit2,
+	sealtasks.TTFinalize,
+	sealtasks.TTFetch,
+	sealtasks.TTUnseal,
+}
+
+func throttleFlagName(tt sealtasks.TaskType) string {
+	return strings.ToLower(tt.Short())
+}
+
+var sealingThrottleCmd = &cli.Command{
+	Name:  "throttle",
+	Usage: "Cap task starts across all workers",
+	Description: `Limits are applied on top of worker resource limits, and are kept
+until changed or until the miner is restarted. Tasks which are already
+running are not interrupted, held back tasks stay queued.
+
+Setting a limit to 0 removes it, limits which aren't passed are kept.
+Without any flags the current limits are printed.
+
+Example: lotus-miner sealing throttle --pc1 4 --c2 1 --dispatch 10`,
+	Flags: append(throttleTaskFlags(),
+		&cli.IntFlag{
+			Name:  "dispatch",
+			Usage: "max tasks started per minute",
+		},
+		&cli.BoolFlag{
+			Name:  "clear",
+			Usage: "remove all limits",
+		},
+	),
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		limits, err := nodeApi.SealingGetThrottle(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting throttle: %w", err)
+		}
+
+		changed := cctx.Bool("clear")
+		if changed {
+			limits = storiface.SchedThrottle{}
+		}
+		if cctx.IsSet("dispatch") {
+			limits.Dispatch = cctx.Int("dispatch")
+			changed = true
+		}
+		for _, tt := range throttleTasks {
+			name := throttleFlagName(tt)
+			if !cctx.IsSet(name) {
+				continue
+			}
+
+			if limits.Tasks == nil {
+				limits.Tasks = map[sealtasks.TaskType]int{}
+			}
+			limits.Tasks[tt] = cctx.Int(name)
+			changed = true
+		}
+
+		if changed {
+			if err := checkThrottle(limits); err != nil {
+				return err
+			}
+			if err := nodeApi.SealingSetThrottle(ctx, limits); err != nil {
+				return xerrors.Errorf("setting throttle: %w", err)
+			}
+
+			limits, err = nodeApi.SealingGetThrottle(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting throttle: %w", err)
+			}
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Task\tLimit\n")
+		for _, tt := range throttleTasks {
+			if n, ok := limits.Tasks[tt]; ok {
+				_, _ = fmt.Fprintf(tw, "%s\t%d running\n", tt.Short(), n)
+			}
+		}
+		if limits.Dispatch > 0 {
+			_, _ = fmt.Fprintf(tw, "*\t%d starts/min\n", limits.Dispatch)
+		}
+		if len(limits.Tasks) == 0 && limits.Dispatch <= 0 {
+			_, _ = fmt.Fprintf(tw, "*\tnone\n")
+		}
+
+		return tw.Flush()
+	},
+}
+
+func throttleTaskFlags() []cli.Flag {
+	out := make([]cli.Flag, 0, len(throttleTasks))
+	for _, tt := range throttleTasks {
+		out = append(out, &cli.IntFlag{
+			Name:  throttleFlagName(tt),
+			Usage: fmt.Sprintf("max running %s tasks", tt.Short()),
+		})
+	}
+	return out
+}
+
+func checkThrottle(limits storiface.SchedThrottle) error {
+	if limits.Dispatch < 0 {
+		return xerrors.Errorf("dispatch limit can't be negative")
+	}
+	for tt, n := range limits.Tasks {
+		if n < 0 {
+			return xerrors.Errorf("%s limit can't be negative", tt.Short())
+		}
+	}
+	return nil
+}
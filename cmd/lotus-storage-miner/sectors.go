@@ -32,6 +32,7 @@ var sectorsCmd = &cli.Command{
 	Subcommands: []*cli.Command{
 		sectorsStatusCmd,
 		sectorsListCmd,
+		sectorsExportCmd,
 		sectorsRefsCmd,
 		sectorsUpdateCmd,
 		sectorsPledgeCmd,
@@ -40,6 +41,11 @@ var sectorsCmd = &cli.Command{
 		sectorsStartSealCmd,
 		sectorsSealDelayCmd,
 		sectorsCapacityCollateralCmd,
+		sectorsRenewCmd,
+		sectorsCommitCmd,
+		sectorsPackingCmd,
+		sectorsTerminationEstimateCmd,
+		sectorsSealRecordCmd,
 	},
 }
 
@@ -599,3 +605,171 @@ func yesno(b bool) string {
 	}
 	return color.RedString("NO")
 }
+
+var sectorsCommitCmd = &cli.Command{
+	Name:  "commit",
+	Usage: "Manage ProveCommit messages waiting for approval (see the ApproveCommits sealing option)",
+	Subcommands: []*cli.Command{
+		sectorsCommitListCmd,
+		sectorsCommitApproveCmd,
+		sectorsCommitRejectCmd,
+	},
+}
+
+var sectorsCommitListCmd = &cli.Command{
+	Name:  "list",
+	Usage: "List ProveCommit messages waiting for approval",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		pending, err := nodeApi.SectorCommitPending(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("ID"),
+			tablewriter.Col("From"),
+			tablewriter.Col("Collateral"),
+			tablewriter.Col("ProofSize"),
+			tablewriter.Col("GasLimit"),
+			tablewriter.Col("GasFeeCap"),
+			tablewriter.Col("GasPremium"))
+
+		for _, pc := range pending {
+			tw.Write(map[string]interface{}{
+				"ID":         pc.Sector,
+				"From":       pc.From,
+				"Collateral": types.FIL(pc.Collateral),
+				"ProofSize":  pc.ProofSize,
+				"GasLimit":   pc.GasLimit,
+				"GasFeeCap":  types.FIL(pc.GasFeeCap),
+				"GasPremium": types.FIL(pc.GasPremium),
+			})
+		}
+
+		return tw.Flush(os.Stdout)
+	},
+}
+
+var sectorsCommitApproveCmd = &cli.Command{
+	Name:      "approve",
+	Usage:     "Send the pending ProveCommit message of a sector",
+	ArgsUsage: "<sectorNum>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return lcli.ShowHelp(cctx, xerrors.Errorf("must pass sector number"))
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		id, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector number: %w", err)
+		}
+
+		return nodeApi.SectorCommitApprove(ctx, abi.SectorNumber(id))
+	},
+}
+
+var sectorsCommitRejectCmd = &cli.Command{
+	Name:      "reject",
+	Usage:     "Drop the pending ProveCommit message of a sector, and compute the proof again",
+	ArgsUsage: "<sectorNum>",
+	Action: func(cctx *cli.Context) error {
+		if cctx.Args().Len() != 1 {
+			return lcli.ShowHelp(cctx, xerrors.Errorf("must pass sector number"))
+		}
+
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		id, err := strconv.ParseUint(cctx.Args().Get(0), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector number: %w", err)
+		}
+
+		return nodeApi.SectorCommitReject(ctx, abi.SectorNumber(id))
+	},
+}
+
+var sectorsPackingCmd = &cli.Command{
+	Name:  "packing",
+	Usage: "Show the sectors open for deals, and where new deal pieces would go",
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		report, err := nodeApi.SectorsPackingReport(ctx)
+		if err != nil {
+			return err
+		}
+
+		if len(report.Open) == 0 {
+			fmt.Println("No sectors open for deals; new pieces will go to a new sector")
+			return nil
+		}
+
+		tw := tablewriter.New(
+			tablewriter.Col("ID"),
+			tablewriter.Col("Deals"),
+			tablewriter.Col("Stored"),
+			tablewriter.Col("Padding"),
+			tablewriter.Col("Free"),
+			tablewriter.Col("EarliestStart"))
+		for _, s := range report.Open {
+			start := "-"
+			if s.EarliestDealStart != 0 {
+				start = fmt.Sprint(s.EarliestDealStart)
+			}
+			tw.Write(map[string]interface{}{
+				"ID":            s.Number,
+				"Deals":         s.Deals,
+				"Stored":        types.SizeStr(types.NewInt(uint64(s.Stored))),
+				"Padding":       types.SizeStr(types.NewInt(uint64(s.Padding))),
+				"Free":          types.SizeStr(types.NewInt(uint64(s.Free))),
+				"EarliestStart": start,
+			})
+		}
+		if err := tw.Flush(os.Stdout); err != nil {
+			return err
+		}
+
+		fmt.Println()
+		fmt.Println("Placement of new pieces:")
+		tw = tablewriter.New(
+			tablewriter.Col("PieceSize"),
+			tablewriter.Col("Sector"),
+			tablewriter.Col("Padding"))
+		for _, p := range report.Placements {
+			sector := "new"
+			if !p.NewSector {
+				sector = fmt.Sprint(p.Sector)
+			}
+			tw.Write(map[string]interface{}{
+				"PieceSize": types.SizeStr(types.NewInt(uint64(p.Size))),
+				"Sector":    sector,
+				"Padding":   types.SizeStr(types.NewInt(uint64(p.Padding))),
+			})
+		}
+		return tw.Flush(os.Stdout)
+	},
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+// exportPhases are the sealing phases exported with their start time, in
+// pipeline order. A phase starts with the last event of its kind in the sector
+// log, so that retried phases are timed from their last attempt. Packing starts
+// with the first log entry.
+var exportPhases = []struct {
+	Name  string
+	Event string
+}{
+	{"Packing", ""},
+	{"PreCommit1", "event;sealing.SectorTicket"},
+	{"PreCommit2", "event;sealing.SectorPreCommit1"},
+	{"PreCommitting", "event;sealing.SectorPreCommit2"},
+	{"PreCommitWait", "event;sealing.SectorPreCommitted"},
+	{"WaitSeed", "event;sealing.SectorPreCommitLanded"},
+	{"Committing", "event;sealing.SectorSeedReady"},
+	{"CommitWait", "event;sealing.SectorCommitSubmitted"},
+	{"FinalizeSector", "event;sealing.SectorProving"},
+	{"Proving", "event;sealing.SectorFinalized"},
+}
+
+var sectorsExportCmd = &cli.Command{
+	Name:  "export",
+	Usage: "Export sealing history of all sectors as CSV, for offline analysis",
+	Description: `Writes one row per sector, with its state, deal and piece counts, the start
+   time of each sealing phase, and how long each phase and the whole sealing
+   took, in seconds. Phase times are taken from the sector log; phases the
+   sector didn't reach are left empty.`,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "output",
+			Usage: "file to write to, stdout if not set",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		ctx := lcli.ReqContext(cctx)
+
+		list, err := nodeApi.SectorsList(ctx)
+		if err != nil {
+			return xerrors.Errorf("listing sectors: %w", err)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			return list[i] < list[j]
+		})
+
+		var out io.Writer = os.Stdout
+		if path := cctx.String("output"); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close() // nolint
+			out = f
+		}
+
+		w := csv.NewWriter(out)
+		if err := w.Write(exportHeader()); err != nil {
+			return err
+		}
+
+		for _, s := range list {
+			st, err := nodeApi.SectorsStatus(ctx, s, false)
+			if err != nil {
+				return xerrors.Errorf("getting status of sector %d: %w", s, err)
+			}
+
+			if err := w.Write(exportRow(st)); err != nil {
+				return err
+			}
+		}
+
+		w.Flush()
+		return w.Error()
+	},
+}
+
+func exportHeader() []string {
+	header := []string{"Sector", "State", "SealProof", "Deals", "Pieces", "Retries"}
+	for _, p := range exportPhases {
+		header = append(header, p.Name+"Start")
+	}
+	for _, p := range exportPhases[:len(exportPhases)-1] {
+		header = append(header, p.Name+"Secs")
+	}
+	return append(header, "SealSecs", "LastErr")
+}
+
+func exportRow(st api.SectorInfo) []string {
+	var deals int
+	for _, deal := range st.Deals {
+		if deal != 0 {
+			deals++
+		}
+	}
+
+	starts := make([]time.Time, len(exportPhases))
+	for i, p := range exportPhases {
+		for _, l := range st.Log {
+			if (p.Event == "" && starts[i].IsZero()) || (p.Event != "" && l.Kind == p.Event) {
+				starts[i] = time.Unix(int64(l.Timestamp), 0)
+			}
+		}
+	}
+
+	row := []string{
+		strconv.FormatUint(uint64(st.SectorID), 10),
+		string(st.State),
+		strconv.FormatInt(int64(st.SealProof), 10),
+		strconv.Itoa(deals),
+		strconv.Itoa(len(st.Pieces)),
+		strconv.FormatUint(st.Retries, 10),
+	}
+
+	for _, t := range starts {
+		if t.IsZero() {
+			row = append(row, "")
+			continue
+		}
+		row = append(row, t.UTC().Format(time.RFC3339))
+	}
+
+	secs := func(from, to time.Time) string {
+		if from.IsZero() || to.IsZero() || to.Before(from) {
+			return ""
+		}
+		return strconv.FormatInt(int64(to.Sub(from)/time.Second), 10)
+	}
+
+	for i := range exportPhases[:len(exportPhases)-1] {
+		row = append(row, secs(starts[i], starts[i+1]))
+	}
+
+	return append(row,
+		secs(starts[0], starts[len(starts)-1]),
+		strings.ReplaceAll(st.LastErr, "\n", " "))
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+)
+
+func TestExportRow(t *testing.T) {
+	st := api.SectorInfo{
+		SectorID:  7,
+		State:     "PreCommit2",
+		SealProof: abi.RegisteredSealProof_StackedDrg32GiBV1_1,
+		Deals:     []abi.DealID{0, 12},
+		Pieces:    make([]abi.PieceInfo, 2),
+		Retries:   1,
+		LastErr:   "some\nerror",
+		Log: []api.SectorLog{
+			{Kind: "event;sealing.SectorStart", Timestamp: 1000},
+			{Kind: "event;sealing.SectorPacked", Timestamp: 1010},
+			{Kind: "event;sealing.SectorTicket", Timestamp: 1020},
+			// PreCommit1 retried, the last attempt counts
+			{Kind: "event;sealing.SectorSealPreCommit1Failed", Timestamp: 2000},
+			{Kind: "event;sealing.SectorTicket", Timestamp: 3000},
+			{Kind: "event;sealing.SectorPreCommit1", Timestamp: 3600},
+		},
+	}
+
+	header := exportHeader()
+	row := exportRow(st)
+	require.Len(t, row, len(header))
+
+	col := map[string]string{}
+	for i, h := range header {
+		col[h] = row[i]
+	}
+
+	require.Equal(t, "7", col["Sector"])
+	require.Equal(t, "1", col["Deals"])
+	require.Equal(t, "2", col["Pieces"])
+	require.Equal(t, "1970-01-01T00:16:40Z", col["PackingStart"])
+	require.Equal(t, "1970-01-01T00:50:00Z", col["PreCommit1Start"])
+	require.Equal(t, "2000", col["PackingSecs"])
+	require.Equal(t, "600", col["PreCommit1Secs"])
+	require.Equal(t, "", col["PreCommit2Secs"])
+	require.Equal(t, "", col["ProvingStart"])
+	require.Equal(t, "", col["SealSecs"])
+	require.Equal(t, "some error", col["LastErr"])
+}
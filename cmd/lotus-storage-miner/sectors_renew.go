@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+	miner2 "github.com/filecoin-project/specs-actors/v2/actors/builtin/miner"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var sectorsRenewCmd = &cli.Command{
+	Name:  "renew",
+	Usage: "Extend expiration of sectors expiring soon, in batched messages",
+	Description: `Finds active sectors expiring within the cutoff, groups them by deadline,
+   partition and expiration bucket, and sends ExtendSectorExpiration messages
+   in batches of at most --max-sectors sectors. Batches with a gas estimate
+   over the block gas target are split further.
+
+   The new expiration of each bucket is the requested extension, capped so
+   that no sector in the bucket goes over its maximum lifetime.
+
+   Without --really-do-it, only the planned messages and their gas estimates
+   are printed.`,
+	Flags: []cli.Flag{
+		&cli.Int64Flag{
+			Name:  "cutoff",
+			Usage: "only renew sectors expiring within this many epochs from now",
+			Value: int64(30 * 2880), // ~30 days
+		},
+		&cli.Int64Flag{
+			Name:  "extension",
+			Usage: "number of epochs from now the sectors should expire at",
+			Value: int64(policy.GetMaxSectorExpirationExtension()),
+		},
+		&cli.Int64Flag{
+			Name:  "bucket",
+			Usage: "expiration bucket size in epochs; sectors with expirations in the same bucket get the same new expiration",
+			Value: int64(2880), // 1 day
+		},
+		&cli.IntFlag{
+			Name:  "max-sectors",
+			Usage: "maximum number of sectors to extend in a single message",
+			Value: defaultRenewBatchSectors,
+		},
+		&cli.BoolFlag{
+			Name:  "really-do-it",
+			Usage: "send the messages, instead of only printing them",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		mi, err := api.StateMinerInfo(ctx, maddr, head.Key())
+		if err != nil {
+			return err
+		}
+
+		sectors, err := api.StateMinerActiveSectors(ctx, maddr, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting active sectors: %w", err)
+		}
+
+		cutoff := head.Height() + abi.ChainEpoch(cctx.Int64("cutoff"))
+		expiring := map[abi.SectorNumber]*miner.SectorOnChainInfo{}
+		for _, si := range sectors {
+			if si.Expiration <= cutoff {
+				expiring[si.SectorNumber] = si
+			}
+		}
+
+		if len(expiring) == 0 {
+			fmt.Println("No sectors expiring before the cutoff")
+			return nil
+		}
+
+		dls, err := api.StateMinerDeadlines(ctx, maddr, head.Key())
+		if err != nil {
+			return xerrors.Errorf("getting deadlines: %w", err)
+		}
+
+		var cands []renewCandidate
+		for dlIdx := range dls {
+			parts, err := api.StateMinerPartitions(ctx, maddr, uint64(dlIdx), head.Key())
+			if err != nil {
+				return xerrors.Errorf("getting partitions for deadline %d: %w", dlIdx, err)
+			}
+
+			for pIdx, part := range parts {
+				err := part.ActiveSectors.ForEach(func(s uint64) error {
+					si, ok := expiring[abi.SectorNumber(s)]
+					if !ok {
+						return nil
+					}
+
+					maxLifetime, err := si.SealProof.SectorMaximumLifetime()
+					if err != nil {
+						return xerrors.Errorf("sector %d: %w", s, err)
+					}
+
+					cands = append(cands, renewCandidate{
+						Number:     si.SectorNumber,
+						Deadline:   uint64(dlIdx),
+						Partition:  uint64(pIdx),
+						Expiration: si.Expiration,
+						MaxExpiry:  si.Activation + maxLifetime,
+					})
+					return nil
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		target := head.Height() + abi.ChainEpoch(cctx.Int64("extension"))
+		batches, skipped := planRenewals(cands, target, abi.ChainEpoch(cctx.Int64("bucket")), cctx.Int("max-sectors"))
+
+		for _, s := range skipped {
+			fmt.Printf("Skipping sector %d: can't be extended past its current expiration %d\n", s.Number, s.Expiration)
+		}
+
+		if len(batches) == 0 {
+			fmt.Println("Nothing to renew")
+			return nil
+		}
+
+		dryRun := !cctx.Bool("really-do-it")
+		totalFee := big.Zero()
+
+		for i := 0; len(batches) > 0; i++ {
+			batch := batches[0]
+			batches = batches[1:]
+
+			params := &miner2.ExtendSectorExpirationParams{}
+			for _, ext := range batch.Extensions {
+				params.Extensions = append(params.Extensions, miner2.ExpirationExtension{
+					Deadline:      ext.Deadline,
+					Partition:     ext.Partition,
+					Sectors:       bitfield.NewFromSet(ext.sectorsU64()),
+					NewExpiration: ext.NewExpiration,
+				})
+			}
+
+			sp, err := actors.SerializeParams(params)
+			if err != nil {
+				return xerrors.Errorf("serializing params: %w", err)
+			}
+
+			msg := &types.Message{
+				From:   mi.Worker,
+				To:     maddr,
+				Method: miner.Methods.ExtendSectorExpiration,
+				Value:  big.Zero(),
+				Params: sp,
+			}
+
+			est, err := api.GasEstimateMessageGas(ctx, msg, nil, head.Key())
+			if err != nil {
+				return xerrors.Errorf("estimating gas for batch %d: %w", i, err)
+			}
+
+			if est.GasLimit > build.BlockGasTarget && batch.Sectors() > 1 {
+				fmt.Printf("Batch %d: gas limit %d of %d sectors is over the block gas target, splitting\n", i, est.GasLimit, batch.Sectors())
+				first, second := batch.split()
+				batches = append([]renewBatch{first, second}, batches...)
+				i--
+				continue
+			}
+
+			fee := big.Mul(est.GasFeeCap, big.NewInt(est.GasLimit))
+			totalFee = big.Add(totalFee, fee)
+
+			fmt.Printf("Batch %d: %d sectors in %d extensions, gas limit %d, max fee %s\n", i, batch.Sectors(), len(batch.Extensions), est.GasLimit, types.FIL(fee))
+			for _, ext := range batch.Extensions {
+				fmt.Printf("\tdeadline %d partition %d: %d sectors -> %d\n", ext.Deadline, ext.Partition, len(ext.Sectors), ext.NewExpiration)
+			}
+
+			if dryRun {
+				continue
+			}
+
+			smsg, err := api.MpoolPushMessage(ctx, msg, nil)
+			if err != nil {
+				return xerrors.Errorf("pushing message for batch %d: %w", i, err)
+			}
+
+			fmt.Printf("\tsent in message %s\n", smsg.Cid())
+		}
+
+		fmt.Printf("Total max fee: %s\n", types.FIL(totalFee))
+		if dryRun {
+			fmt.Println("Pass --really-do-it to send the messages")
+		}
+		return nil
+	},
+}
+
+// defaultRenewBatchSectors keeps ExtendSectorExpiration messages well below
+// the block gas limit; AddressedSectorsMax sectors may not fit in a block
+const defaultRenewBatchSectors = 1000
+
+type renewCandidate struct {
+	Number     abi.SectorNumber
+	Deadline   uint64
+	Partition  uint64
+	Expiration abi.ChainEpoch
+	MaxExpiry  abi.ChainEpoch // activation + max sector lifetime
+}
+
+type renewExtension struct {
+	Deadline      uint64
+	Partition     uint64
+	NewExpiration abi.ChainEpoch
+	Sectors       []abi.SectorNumber
+}
+
+func (e renewExtension) sectorsU64() []uint64 {
+	out := make([]uint64, len(e.Sectors))
+	for i, s := range e.Sectors {
+		out[i] = uint64(s)
+	}
+	return out
+}
+
+type renewBatch struct {
+	Extensions []renewExtension
+}
+
+func (b renewBatch) Sectors() int {
+	var n int
+	for _, e := range b.Extensions {
+		n += len(e.Sectors)
+	}
+	return n
+}
+
+// split splits the batch into two batches with half of the sectors each
+func (b renewBatch) split() (renewBatch, renewBatch) {
+	var first, second renewBatch
+
+	room := b.Sectors() / 2
+	for _, ext := range b.Extensions {
+		if room >= len(ext.Sectors) {
+			first.Extensions = append(first.Extensions, ext)
+			room -= len(ext.Sectors)
+			continue
+		}
+
+		if room > 0 {
+			part := ext
+			part.Sectors = ext.Sectors[:room]
+			first.Extensions = append(first.Extensions, part)
+			ext.Sectors = ext.Sectors[room:]
+			room = 0
+		}
+		second.Extensions = append(second.Extensions, ext)
+	}
+
+	return first, second
+}
+
+// planRenewals groups candidates by deadline, partition and expiration bucket,
+// picks a new expiration for every group, and packs the groups into batches of
+// at most maxSectors sectors. Groups are split across batches when needed.
+// Candidates which can't be extended at all are returned as skipped.
+func planRenewals(cands []renewCandidate, target, bucket abi.ChainEpoch, maxSectors int) (batches []renewBatch, skipped []renewCandidate) {
+	if bucket <= 0 {
+		bucket = 1
+	}
+	if maxSectors <= 0 {
+		maxSectors = len(cands)
+	}
+
+	type groupKey struct {
+		deadline, partition uint64
+		bucket              abi.ChainEpoch
+	}
+
+	groups := map[groupKey][]renewCandidate{}
+	for _, c := range cands {
+		if c.MaxExpiry <= c.Expiration {
+			skipped = append(skipped, c)
+			continue
+		}
+
+		k := groupKey{c.Deadline, c.Partition, c.Expiration / bucket}
+		groups[k] = append(groups[k], c)
+	}
+
+	keys := make([]groupKey, 0, len(groups))
+	for k := range groups {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].deadline != keys[j].deadline {
+			return keys[i].deadline < keys[j].deadline
+		}
+		if keys[i].partition != keys[j].partition {
+			return keys[i].partition < keys[j].partition
+		}
+		return keys[i].bucket < keys[j].bucket
+	})
+
+	var exts []renewExtension
+	for _, k := range keys {
+		group := groups[k]
+
+		newExp := target
+		for _, c := range group {
+			if c.MaxExpiry < newExp {
+				newExp = c.MaxExpiry
+			}
+		}
+
+		ext := renewExtension{
+			Deadline:      k.deadline,
+			Partition:     k.partition,
+			NewExpiration: newExp,
+		}
+		for _, c := range group {
+			if c.Expiration >= newExp {
+				skipped = append(skipped, c)
+				continue
+			}
+			ext.Sectors = append(ext.Sectors, c.Number)
+		}
+		if len(ext.Sectors) == 0 {
+			continue
+		}
+		sort.Slice(ext.Sectors, func(i, j int) bool {
+			return ext.Sectors[i] < ext.Sectors[j]
+		})
+
+		exts = append(exts, ext)
+	}
+
+	var cur renewBatch
+	for _, ext := range exts {
+		for len(ext.Sectors) > 0 {
+			room := maxSectors - cur.Sectors()
+			if room <= 0 {
+				batches = append(batches, cur)
+				cur = renewBatch{}
+				room = maxSectors
+			}
+
+			part := ext
+			if len(part.Sectors) > room {
+				part.Sectors = ext.Sectors[:room]
+			}
+			ext.Sectors = ext.Sectors[len(part.Sectors):]
+
+			cur.Extensions = append(cur.Extensions, part)
+		}
+	}
+	if len(cur.Extensions) > 0 {
+		batches = append(batches, cur)
+	}
+
+	return batches, skipped
+}
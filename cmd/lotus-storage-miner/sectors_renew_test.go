@@ -0,0 +1,64 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+func TestPlanRenewals(t *testing.T) {
+	cands := []renewCandidate{
+		// deadline 0, partition 0, same bucket
+		{Number: 1, Deadline: 0, Partition: 0, Expiration: 1000, MaxExpiry: 100000},
+		{Number: 2, Deadline: 0, Partition: 0, Expiration: 1010, MaxExpiry: 50000},
+		// same partition, different bucket
+		{Number: 3, Deadline: 0, Partition: 0, Expiration: 5000, MaxExpiry: 100000},
+		// different deadline
+		{Number: 4, Deadline: 3, Partition: 1, Expiration: 1000, MaxExpiry: 100000},
+		{Number: 5, Deadline: 3, Partition: 1, Expiration: 1001, MaxExpiry: 100000},
+		// at its max lifetime already
+		{Number: 6, Deadline: 3, Partition: 1, Expiration: 1002, MaxExpiry: 1002},
+	}
+
+	batches, skipped := planRenewals(cands, 80000, 100, 2)
+
+	require.Len(t, skipped, 1)
+	require.Equal(t, abi.SectorNumber(6), skipped[0].Number)
+
+	require.Len(t, batches, 3)
+
+	// the lowest max expiry in the bucket caps the new expiration
+	require.Equal(t, []renewExtension{
+		{Deadline: 0, Partition: 0, NewExpiration: 50000, Sectors: []abi.SectorNumber{1, 2}},
+	}, batches[0].Extensions)
+	require.Equal(t, []renewExtension{
+		{Deadline: 0, Partition: 0, NewExpiration: 80000, Sectors: []abi.SectorNumber{3}},
+		{Deadline: 3, Partition: 1, NewExpiration: 80000, Sectors: []abi.SectorNumber{4}},
+	}, batches[1].Extensions)
+	require.Equal(t, []renewExtension{
+		{Deadline: 3, Partition: 1, NewExpiration: 80000, Sectors: []abi.SectorNumber{5}},
+	}, batches[2].Extensions)
+}
+
+func TestRenewBatchSplit(t *testing.T) {
+	b := renewBatch{Extensions: []renewExtension{
+		{Deadline: 0, Partition: 0, NewExpiration: 1000, Sectors: []abi.SectorNumber{1, 2}},
+		{Deadline: 1, Partition: 0, NewExpiration: 1000, Sectors: []abi.SectorNumber{3, 4, 5}},
+	}}
+
+	first, second := b.split()
+	require.Equal(t, []renewExtension{
+		{Deadline: 0, Partition: 0, NewExpiration: 1000, Sectors: []abi.SectorNumber{1, 2}},
+	}, first.Extensions)
+	require.Equal(t, []renewExtension{
+		{Deadline: 1, Partition: 0, NewExpiration: 1000, Sectors: []abi.SectorNumber{3, 4, 5}},
+	}, second.Extensions)
+
+	first, second = second.split()
+	require.Equal(t, 1, first.Sectors())
+	require.Equal(t, 2, second.Sectors())
+	require.Equal(t, []abi.SectorNumber{3}, first.Extensions[0].Sectors)
+	require.Equal(t, []abi.SectorNumber{4, 5}, second.Extensions[0].Sectors)
+}
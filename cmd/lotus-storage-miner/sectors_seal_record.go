@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/docker/go-units"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	lcli "github.com/filecoin-project/lotus/cli"
+)
+
+var sectorsSealRecordCmd = &cli.Command{
+	Name:      "seal-record",
+	Usage:     "Print the proof parameters a sector was sealed with, and the workers which sealed it",
+	ArgsUsage: "<sectorNum>",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the raw record as JSON",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+		ctx := lcli.ReqContext(cctx)
+
+		if !cctx.Args().Present() {
+			return xerrors.Errorf("must specify sector number")
+		}
+
+		id, err := strconv.ParseUint(cctx.Args().First(), 10, 64)
+		if err != nil {
+			return xerrors.Errorf("could not parse sector number: %w", err)
+		}
+
+		rec, err := nodeApi.SectorSealRecord(ctx, abi.SectorNumber(id))
+		if err != nil {
+			return err
+		}
+
+		if cctx.Bool("json") {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(rec)
+		}
+
+		fmt.Printf("Sector:\t\t%d\n", rec.Sector.Number)
+		fmt.Printf("Seal proof:\t%d\n", rec.ProofType)
+		fmt.Printf("Sector size:\t%s\n", units.BytesSize(float64(rec.SectorSize)))
+		fmt.Printf("WindowPoSt:\t%d\n", rec.WindowPoStProof)
+		fmt.Printf("WinningPoSt:\t%d\n", rec.WinningPoStProof)
+		fmt.Println()
+
+		tw := tabwriter.NewWriter(os.Stdout, 2, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintf(tw, "Task\tWorker\tVersion\tStarted\n")
+		for _, c := range rec.Calls {
+			version := c.Version
+			if version == "" {
+				version = "-"
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", c.Task.Short(), c.Worker, version, c.Started.Format(time.RFC3339))
+		}
+		return tw.Flush()
+	},
+}
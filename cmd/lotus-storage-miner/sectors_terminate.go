@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/urfave/cli/v2"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-bitfield"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	lcli "github.com/filecoin-project/lotus/cli"
+	"github.com/filecoin-project/lotus/lib/tablewriter"
+)
+
+var sectorsTerminationEstimateCmd = &cli.Command{
+	Name:      "termination-estimate",
+	Usage:     "Compare terminating sectors now with letting them expire",
+	ArgsUsage: "[sectorNum ...]",
+	Description: `Estimates, for each sector, the termination fee burned when terminating it
+   now, the pledge locked for it, and the block rewards it is expected to earn
+   until its expiration at current network conditions.
+
+   Terminating a sector now burns the fee and forgoes the expected rewards, the
+   rest of the pledge is released right away. Letting it expire costs nothing,
+   and releases the whole pledge at expiration.
+
+   Without arguments, all active sectors are included.`,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "verbose",
+			Usage: "show the estimate of each sector",
+		},
+	},
+	Action: func(cctx *cli.Context) error {
+		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer closer()
+
+		api, acloser, err := lcli.GetFullNodeAPI(cctx)
+		if err != nil {
+			return err
+		}
+		defer acloser()
+
+		ctx := lcli.ReqContext(cctx)
+
+		maddr, err := nodeApi.ActorAddress(ctx)
+		if err != nil {
+			return err
+		}
+
+		head, err := api.ChainHead(ctx)
+		if err != nil {
+			return err
+		}
+
+		var nums []uint64
+		if cctx.Args().Present() {
+			for _, arg := range cctx.Args().Slice() {
+				n, err := strconv.ParseUint(arg, 10, 64)
+				if err != nil {
+					return xerrors.Errorf("could not parse sector number %q: %w", arg, err)
+				}
+				nums = append(nums, n)
+			}
+		} else {
+			sectors, err := api.StateMinerActiveSectors(ctx, maddr, head.Key())
+			if err != nil {
+				return xerrors.Errorf("getting active sectors: %w", err)
+			}
+			for _, si := range sectors {
+				nums = append(nums, uint64(si.SectorNumber))
+			}
+		}
+
+		if len(nums) == 0 {
+			fmt.Println("No sectors")
+			return nil
+		}
+
+		bf := bitfield.NewFromSet(nums)
+		est, err := api.StateMinerTerminationEstimate(ctx, maddr, &bf, head.Key())
+		if err != nil {
+			return err
+		}
+
+		if len(est.Sectors) == 0 {
+			fmt.Println("No live sectors to terminate")
+			return nil
+		}
+
+		if cctx.Bool("verbose") {
+			tw := tablewriter.New(
+				tablewriter.Col("ID"),
+				tablewriter.Col("Expiration"),
+				tablewriter.Col("TerminationFee"),
+				tablewriter.Col("Pledge"),
+				tablewriter.Col("ExpectedReward"))
+			for _, s := range est.Sectors {
+				tw.Write(map[string]interface{}{
+					"ID":             s.SectorNumber,
+					"Expiration":     lcli.EpochTime(est.Epoch, s.Expiration),
+					"TerminationFee": types.FIL(s.TerminationFee).Short(),
+					"Pledge":         types.FIL(s.InitialPledge).Short(),
+					"ExpectedReward": types.FIL(s.ExpectedReward).Short(),
+				})
+			}
+			if err := tw.Flush(os.Stdout); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+
+		released := big.Sub(est.InitialPledge, est.TerminationFee)
+		if released.LessThan(big.Zero()) {
+			released = big.Zero()
+		}
+
+		fmt.Printf("Sectors: %d (at epoch %d)\n\n", len(est.Sectors), est.Epoch)
+
+		fmt.Println("Terminate now:")
+		fmt.Printf("  Termination fee:  %s\n", types.FIL(est.TerminationFee))
+		fmt.Printf("  Pledge released:  %s (now)\n", types.FIL(released))
+		fmt.Printf("  Forgone rewards:  %s\n", types.FIL(est.ExpectedReward))
+		fmt.Println("Let expire:")
+		fmt.Printf("  Pledge released:  %s (at expiration)\n", types.FIL(est.InitialPledge))
+		fmt.Printf("  Expected rewards: %s\n\n", types.FIL(est.ExpectedReward))
+
+		fmt.Printf("Cost of terminating now: %s\n", types.FIL(big.Add(est.TerminationFee, est.ExpectedReward)))
+		fmt.Println("(fault penalties, and the time value of the pledge released earlier, are not included)")
+
+		return nil
+	},
+}
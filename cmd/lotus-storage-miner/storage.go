@@ -294,6 +294,12 @@ var storageFindCmd = &cli.Command{
 	Name:      "find",
 	Usage:     "find sector in the storage system",
 	ArgsUsage: "[sector number]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "all-phases",
+			Usage: "show every file of the sector in every storage path in one table, with declaration times and path health",
+		},
+	},
 	Action: func(cctx *cli.Context) error {
 		nodeApi, closer, err := lcli.GetStorageMinerAPI(cctx)
 		if err != nil {
@@ -326,6 +332,10 @@ var storageFindCmd = &cli.Command{
 			Number: abi.SectorNumber(snum),
 		}
 
+		if cctx.Bool("all-phases") {
+			return printSectorFiles(ctx, nodeApi, sid)
+		}
+
 		u, err := nodeApi.StorageFindSector(ctx, sid, storiface.FTUnsealed, 0, false)
 		if err != nil {
 			return xerrors.Errorf("finding unsealed: %w", err)
@@ -417,6 +427,78 @@ var storageFindCmd = &cli.Command{
 	},
 }
 
+// printSectorFiles prints a table of where each file of the sector is declared,
+// when it was declared there, and how healthy the storage path is.
+func printSectorFiles(ctx context.Context, nodeApi api.StorageMiner, sid abi.SectorID) error {
+	local, err := nodeApi.StorageLocal(ctx)
+	if err != nil {
+		return err
+	}
+
+	tw := tablewriter.New(
+		tablewriter.Col("Type"),
+		tablewriter.Col("Storage"),
+		tablewriter.Col("Location"),
+		tablewriter.Col("Primary"),
+		tablewriter.Col("Path use"),
+		tablewriter.Col("Declared"),
+		tablewriter.Col("Heartbeat"),
+	)
+
+	now := time.Now()
+	for _, ft := range storiface.PathTypes {
+		infos, err := nodeApi.StorageFindSector(ctx, sid, ft, 0, false)
+		if err != nil {
+			return xerrors.Errorf("finding %s: %w", ft, err)
+		}
+		sort.Slice(infos, func(i, j int) bool {
+			return infos[i].ID < infos[j].ID
+		})
+
+		if len(infos) == 0 {
+			tw.Write(map[string]interface{}{
+				"Type":    ft.String(),
+				"Storage": color.YellowString("not found"),
+			})
+			continue
+		}
+
+		for _, info := range infos {
+			location := "remote"
+			if p, ok := local[info.ID]; ok {
+				location = p
+			} else if len(info.URLs) > 0 {
+				location = info.URLs[0]
+			}
+
+			declared := "-"
+			if !info.Declared.IsZero() {
+				declared = fmt.Sprintf("%s ago", now.Sub(info.Declared).Truncate(time.Second))
+			}
+
+			heartbeat := color.GreenString("%s ago", now.Sub(info.LastHeartbeat).Truncate(time.Second))
+			switch {
+			case info.HeartbeatErr != "":
+				heartbeat = color.RedString("error: %s", info.HeartbeatErr)
+			case now.Sub(info.LastHeartbeat) > stores.SkippedHeartbeatThresh:
+				heartbeat = color.YellowString("stale, %s ago", now.Sub(info.LastHeartbeat).Truncate(time.Second))
+			}
+
+			tw.Write(map[string]interface{}{
+				"Type":      ft.String(),
+				"Storage":   info.ID,
+				"Location":  location,
+				"Primary":   maybeStr(info.Primary, color.FgGreen, "primary"),
+				"Path use":  maybeStr(info.CanSeal, color.FgMagenta, "seal ") + maybeStr(info.CanStore, color.FgCyan, "store"),
+				"Declared":  declared,
+				"Heartbeat": heartbeat,
+			})
+		}
+	}
+
+	return tw.Flush(os.Stdout)
+}
+
 var storageListSectorsCmd = &cli.Command{
 	Name:  "sectors",
 	Usage: "get list of all sector files",
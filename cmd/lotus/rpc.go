@@ -39,7 +39,12 @@ func serveRPC(a api.FullNode, stop node.StopFunc, addr multiaddr.Multiaddr, shut
 		serverOptions = append(serverOptions, jsonrpc.WithMaxRequestSize(maxRequestSize))
 	}
 	rpcServer := jsonrpc.NewServer(serverOptions...)
-	rpcServer.Register("Filecoin", apistruct.PermissionedFullAPI(metrics.MetricedFullAPI(a)))
+
+	served := a
+	if fa, ok := a.(*impl.FullNodeAPI); ok && fa.HeadersOnly {
+		served = impl.HeadersOnlyFullAPI(a)
+	}
+	rpcServer.Register("Filecoin", apistruct.PermissionedFullAPI(metrics.MetricedFullAPI(served)))
 
 	ah := &auth.Handler{
 		Verify: a.AuthVerify,
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/conformance"
+)
+
+var checkLiveFlags struct {
+	from int64
+	to   int64
+}
+
+var checkLiveCmd = &cli.Command{
+	Name: "check-live",
+	Description: `re-execute a range of tipsets against the state of the connected node,
+   and compare the results with the on-chain receipts, without writing vectors.
+
+   Every non-null tipset in [from, to] is executed on top of its parent state,
+   and the resulting state root and receipts root are checked against those
+   committed by its child. When they don't match, the individual receipts are
+   compared, and the differing ones reported. Exits with a non-zero status if
+   any tipset mismatched.
+
+   This is meant for quick soak-testing of local VM changes; use tvx extract to
+   capture a failing tipset as a vector.`,
+	Action: runCheckLive,
+	Before: initialize,
+	After:  destroy,
+	Flags: []cli.Flag{
+		&repoFlag,
+		&cli.Int64Flag{
+			Name:        "from",
+			Usage:       "first epoch to execute",
+			Required:    true,
+			Destination: &checkLiveFlags.from,
+		},
+		&cli.Int64Flag{
+			Name:        "to",
+			Usage:       "last epoch to execute, inclusive; must be below the chain head",
+			Required:    true,
+			Destination: &checkLiveFlags.to,
+		},
+	},
+}
+
+func runCheckLive(_ *cli.Context) error {
+	ctx := context.Background()
+
+	from, to := abi.ChainEpoch(checkLiveFlags.from), abi.ChainEpoch(checkLiveFlags.to)
+	if from < 1 || to < from {
+		return fmt.Errorf("invalid epoch range [%d, %d]", from, to)
+	}
+
+	head, err := FullAPI.ChainHead(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get chain head: %w", err)
+	}
+	if to >= head.Height() {
+		return fmt.Errorf("last epoch %d must be below the chain head %d, as results are read from the child tipset", to, head.Height())
+	}
+
+	var (
+		pst    = NewProxyingStores(ctx, FullAPI)
+		driver = conformance.NewDriver(ctx, schema.Selector{}, conformance.DriverOpts{
+			DisableVMFlush: true,
+		})
+
+		checked, failed int
+	)
+
+	ts, err := FullAPI.ChainGetTipSetByHeight(ctx, from, head.Key())
+	if err != nil {
+		return fmt.Errorf("failed to get tipset at epoch %d: %w", from, err)
+	}
+
+	for ts.Height() <= to {
+		// the child tipset carries the results of executing ts.
+		next, err := nextTipset(ctx, ts, head)
+		if err != nil {
+			return err
+		}
+
+		ok, err := checkLiveTipset(ctx, driver, pst, ts, next)
+		if err != nil {
+			return fmt.Errorf("failed to check tipset %s (epoch: %d): %w", ts.Key(), ts.Height(), err)
+		}
+
+		checked++
+		if !ok {
+			failed++
+		}
+		ts = next
+	}
+
+	log.Printf("checked %d tipsets in [%d, %d]: %d ok, %d failed", checked, from, to, checked-failed, failed)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d tipsets mismatched the chain", failed, checked)
+	}
+	return nil
+}
+
+// nextTipset returns the first non-null tipset after ts, on the chain of head.
+func nextTipset(ctx context.Context, ts, head *types.TipSet) (*types.TipSet, error) {
+	for h := ts.Height() + 1; h <= head.Height(); h++ {
+		next, err := FullAPI.ChainGetTipSetByHeight(ctx, h, head.Key())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tipset at epoch %d: %w", h, err)
+		}
+		if next.Height() == h {
+			return next, nil
+		}
+		// null round; ChainGetTipSetByHeight returned the previous tipset.
+	}
+	return nil, fmt.Errorf("no tipset after epoch %d", ts.Height())
+}
+
+// checkLiveTipset executes ts on top of its parent state, and compares the
+// results with those committed in next. It returns false if they mismatch.
+func checkLiveTipset(ctx context.Context, driver *conformance.Driver, pst *Stores, ts, next *types.TipSet) (bool, error) {
+	parent, err := FullAPI.ChainGetTipSet(ctx, ts.Parents())
+	if err != nil {
+		return false, fmt.Errorf("failed to get parent tipset: %w", err)
+	}
+
+	tipset, err := schemaTipset(ctx, ts, ts.Blocks()[0].ParentBaseFee, 0)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := driver.ExecuteTipset(pst.Blockstore, pst.Datastore, conformance.ExecuteTipsetParams{
+		Preroot:     ts.ParentState(),
+		ParentEpoch: parent.Height(),
+		Tipset:      tipset,
+		ExecEpoch:   ts.Height(),
+		Rand:        conformance.NewRecordingRand(new(conformance.LogReporter), FullAPI),
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to execute tipset: %w", err)
+	}
+
+	expected := next.Blocks()[0]
+	if result.PostStateRoot == expected.ParentState && result.ReceiptsRoot == expected.ParentMessageReceipts {
+		fmt.Printf("%s epoch %d: %s\n", color.GreenString("OK  "), ts.Height(), ts.Key())
+		return true, nil
+	}
+
+	fmt.Printf("%s epoch %d: %s\n", color.RedString("FAIL"), ts.Height(), ts.Key())
+	fmt.Printf("\tstate root:    expected %s, got %s\n", expected.ParentState, result.PostStateRoot)
+	fmt.Printf("\treceipts root: expected %s, got %s\n", expected.ParentMessageReceipts, result.ReceiptsRoot)
+
+	// the chain only holds receipts of the explicit messages.
+	origins, err := conformance.ReceiptOrigins(tipset, result.AppliedMessages)
+	if err != nil {
+		return false, fmt.Errorf("failed to map receipts to messages: %w", err)
+	}
+
+	receipts, err := FullAPI.ChainGetParentReceipts(ctx, expected.Cid())
+	if err != nil {
+		return false, fmt.Errorf("failed to get on-chain receipts: %w", err)
+	}
+
+	var i int
+	for j, o := range origins {
+		if o.Implicit() {
+			continue
+		}
+		if i >= len(receipts) {
+			fmt.Printf("\tmessage %s (%s): no on-chain receipt\n", result.AppliedMessages[j].Cid(), o)
+			i++
+			continue
+		}
+
+		got, want := result.AppliedResults[j], receipts[i]
+		if got.ExitCode != want.ExitCode || !bytes.Equal(got.Return, want.Return) || got.GasUsed != want.GasUsed {
+			fmt.Printf("\tmessage %s (%s): expected exit code %d, gas used %d, return %x; got exit code %d, gas used %d, return %x\n",
+				result.AppliedMessages[j].Cid(), o, want.ExitCode, want.GasUsed, want.Return, got.ExitCode, got.GasUsed, got.Return)
+		}
+		i++
+	}
+	if i != len(receipts) {
+		fmt.Printf("\texpected %d explicit receipts, got %d\n", len(receipts), i)
+	}
+
+	return false, nil
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"log"
+	gobig "math/big"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+var corpusDiffFlags struct {
+	onlyChanged bool
+}
+
+var corpusDiffCmd = &cli.Command{
+	Name: "corpus-diff",
+	Description: `compare two corpus snapshots, reporting added, removed and changed vectors.
+
+   Vectors are matched by ID, falling back to their path relative to the
+   corpus root when they have no ID. Vectors are considered changed when their
+   content hashes differ; for those, the differences in selectors, variants,
+   preconditions, applied messages and postconditions are reported.`,
+	ArgsUsage: "<old corpus dir> <new corpus dir>",
+	Action:    runCorpusDiff,
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:        "only-changed",
+			Usage:       "don't list added and removed vectors",
+			Destination: &corpusDiffFlags.onlyChanged,
+		},
+	},
+}
+
+type corpusEntry struct {
+	path   string
+	vector *schema.TestVector
+	hash   string
+}
+
+func runCorpusDiff(c *cli.Context) error {
+	if c.NArg() != 2 {
+		return fmt.Errorf("expected two arguments: old and new corpus directories")
+	}
+
+	older, err := loadCorpus(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	newer, err := loadCorpus(c.Args().Get(1))
+	if err != nil {
+		return err
+	}
+
+	var added, removed, changed, unchanged int
+
+	for _, id := range sortedKeys(older) {
+		o := older[id]
+		n, ok := newer[id]
+		if !ok {
+			removed++
+			if !corpusDiffFlags.onlyChanged {
+				fmt.Println(color.RedString("- %s (%s)", id, o.path))
+			}
+			continue
+		}
+
+		if o.hash == n.hash {
+			unchanged++
+			continue
+		}
+
+		changed++
+		fmt.Println(color.YellowString("~ %s (%s -> %s)", id, o.path, n.path))
+		for _, d := range diffVectors(o.vector, n.vector) {
+			fmt.Printf("    %s\n", d)
+		}
+	}
+
+	for _, id := range sortedKeys(newer) {
+		if _, ok := older[id]; ok {
+			continue
+		}
+		added++
+		if !corpusDiffFlags.onlyChanged {
+			fmt.Println(color.GreenString("+ %s (%s)", id, newer[id].path))
+		}
+	}
+
+	log.Printf("%d added, %d removed, %d changed, %d unchanged", added, removed, changed, unchanged)
+	return nil
+}
+
+func loadCorpus(dir string) (map[string]corpusEntry, error) {
+	files, err := vectorFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]corpusEntry, len(files))
+	for _, path := range files {
+		tv, err := loadVector(path)
+		if err != nil {
+			log.Printf("skipping %s: %s", path, err)
+			continue
+		}
+
+		hash, err := VectorContentHash(tv)
+		if err != nil {
+			log.Printf("skipping %s: %s", path, err)
+			continue
+		}
+
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to relativize %s: %w", path, err)
+		}
+
+		id := rel
+		if tv.Meta != nil && tv.Meta.ID != "" {
+			id = tv.Meta.ID
+		}
+
+		if prev, ok := out[id]; ok {
+			log.Println(color.YellowString("vector %s appears twice in %s (%s and %s); using the latter", id, dir, prev.path, rel))
+		}
+		out[id] = corpusEntry{path: rel, vector: tv, hash: hash}
+	}
+	return out, nil
+}
+
+func sortedKeys(m map[string]corpusEntry) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// diffVectors returns a human readable list of the semantic differences
+// between two versions of a vector, ignoring the metadata.
+func diffVectors(a, b *schema.TestVector) []string {
+	var diffs []string
+	add := func(format string, args ...interface{}) {
+		diffs = append(diffs, fmt.Sprintf(format, args...))
+	}
+
+	if a.Class != b.Class {
+		add("class: %s -> %s", a.Class, b.Class)
+	}
+	if !reflect.DeepEqual(a.Selector, b.Selector) {
+		add("selector: %v -> %v", a.Selector, b.Selector)
+	}
+
+	var apre, bpre schema.Preconditions
+	if a.Pre != nil {
+		apre = *a.Pre
+	}
+	if b.Pre != nil {
+		bpre = *b.Pre
+	}
+	if !reflect.DeepEqual(apre.Variants, bpre.Variants) {
+		add("variants: %+v -> %+v", apre.Variants, bpre.Variants)
+	}
+	if ar, br := stateRoot(apre.StateTree), stateRoot(bpre.StateTree); ar != br {
+		add("pre state root: %s -> %s", ar, br)
+	}
+	if !bigEqual(apre.BaseFee, bpre.BaseFee) {
+		add("pre base fee: %s -> %s", apre.BaseFee, bpre.BaseFee)
+	}
+	if !bigEqual(apre.CircSupply, bpre.CircSupply) {
+		add("pre circulating supply: %s -> %s", apre.CircSupply, bpre.CircSupply)
+	}
+
+	if !reflect.DeepEqual(a.ApplyMessages, b.ApplyMessages) {
+		add("applied messages changed (%d -> %d messages)", len(a.ApplyMessages), len(b.ApplyMessages))
+	}
+	if !reflect.DeepEqual(a.ApplyTipsets, b.ApplyTipsets) {
+		add("applied tipsets changed (%d -> %d tipsets)", len(a.ApplyTipsets), len(b.ApplyTipsets))
+	}
+	if !reflect.DeepEqual(a.Randomness, b.Randomness) {
+		add("randomness changed (%d -> %d entries)", len(a.Randomness), len(b.Randomness))
+	}
+
+	var apost, bpost schema.Postconditions
+	if a.Post != nil {
+		apost = *a.Post
+	}
+	if b.Post != nil {
+		bpost = *b.Post
+	}
+	if ar, br := stateRoot(apost.StateTree), stateRoot(bpost.StateTree); ar != br {
+		add("post state root: %s -> %s", ar, br)
+	}
+	if len(apost.Receipts) != len(bpost.Receipts) {
+		add("receipts: %d -> %d", len(apost.Receipts), len(bpost.Receipts))
+	}
+	for i := 0; i < len(apost.Receipts) && i < len(bpost.Receipts); i++ {
+		ar, br := apost.Receipts[i], bpost.Receipts[i]
+		if ar == nil || br == nil {
+			if ar != br {
+				add("receipt %d: %v -> %v", i, ar, br)
+			}
+			continue
+		}
+		if ar.ExitCode != br.ExitCode {
+			add("receipt %d exit code: %d -> %d", i, ar.ExitCode, br.ExitCode)
+		}
+		if ar.GasUsed != br.GasUsed {
+			add("receipt %d gas used: %d -> %d", i, ar.GasUsed, br.GasUsed)
+		}
+		if !bytes.Equal(ar.ReturnValue, br.ReturnValue) {
+			add("receipt %d return value: %s -> %s", i, base64.StdEncoding.EncodeToString(ar.ReturnValue), base64.StdEncoding.EncodeToString(br.ReturnValue))
+		}
+	}
+	if !reflect.DeepEqual(apost.ReceiptsRoots, bpost.ReceiptsRoots) {
+		add("receipts roots: %v -> %v", apost.ReceiptsRoots, bpost.ReceiptsRoots)
+	}
+
+	if !bytes.Equal(a.CAR, b.CAR) {
+		add("CAR: %d -> %d bytes", len(a.CAR), len(b.CAR))
+	}
+
+	if len(diffs) == 0 {
+		add("no semantic differences (hashes differ in fields not compared)")
+	}
+	return diffs
+}
+
+func stateRoot(st *schema.StateTree) string {
+	if st == nil {
+		return "<none>"
+	}
+	return st.RootCID.String()
+}
+
+func bigEqual(a, b *gobig.Int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(b) == 0
+}
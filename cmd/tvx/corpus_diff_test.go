@@ -0,0 +1,36 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+func TestDiffVectors(t *testing.T) {
+	mk := func(exit int64, gas int64) *schema.TestVector {
+		return &schema.TestVector{
+			Class: schema.ClassMessage,
+			Post: &schema.Postconditions{
+				Receipts: []*schema.Receipt{{ExitCode: exit, GasUsed: gas}},
+			},
+		}
+	}
+
+	if d := diffVectors(mk(0, 10), mk(0, 10)); len(d) != 1 || !strings.HasPrefix(d[0], "no semantic differences") {
+		t.Fatalf("expected no differences, got %v", d)
+	}
+
+	d := diffVectors(mk(0, 10), mk(16, 12))
+	if len(d) != 2 {
+		t.Fatalf("expected two differences, got %v", d)
+	}
+	if d[0] != "receipt 0 exit code: 0 -> 16" || d[1] != "receipt 0 gas used: 10 -> 12" {
+		t.Fatalf("unexpected differences: %v", d)
+	}
+
+	d = diffVectors(mk(0, 10), &schema.TestVector{Class: schema.ClassMessage})
+	if len(d) != 1 || d[0] != "receipts: 1 -> 0" {
+		t.Fatalf("unexpected differences: %v", d)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+var dedupeFlags struct {
+	dir    string
+	dryRun bool
+}
+
+var dedupeCmd = &cli.Command{
+	Name: "dedupe",
+	Description: `remove duplicate test vectors from a corpus directory.
+
+   Vectors are considered duplicates when their content hashes match; the hash
+   covers everything but the vector metadata, and is recomputed rather than read
+   from the vector. Out of every set of duplicates, the vector with the
+   lexicographically smallest path is kept.`,
+	Action: runDedupe,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "dir",
+			Usage:       "corpus directory; walked recursively",
+			Required:    true,
+			TakesFile:   true,
+			Destination: &dedupeFlags.dir,
+		},
+		&cli.BoolFlag{
+			Name:        "dry-run",
+			Usage:       "only print the duplicates that would be removed",
+			Destination: &dedupeFlags.dryRun,
+		},
+	},
+}
+
+func runDedupe(_ *cli.Context) error {
+	files, err := vectorFiles(dedupeFlags.dir)
+	if err != nil {
+		return err
+	}
+
+	var (
+		kept    = make(map[string]string) // content hash => path
+		removed int
+	)
+	for _, path := range files {
+		hash, err := vectorFileHash(path)
+		if err != nil {
+			log.Printf("skipping %s: %s", path, err)
+			continue
+		}
+
+		orig, ok := kept[hash]
+		if !ok {
+			kept[hash] = path
+			continue
+		}
+
+		removed++
+		if dedupeFlags.dryRun {
+			log.Printf("would remove %s (duplicate of %s)", path, orig)
+			continue
+		}
+
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove duplicate vector %s: %w", path, err)
+		}
+		log.Printf("removed %s (duplicate of %s)", path, orig)
+	}
+
+	log.Printf("scanned %d vectors; %d unique, %d duplicates", len(files), len(kept), removed)
+	return nil
+}
+
+// vectorFiles returns the sorted paths of all JSON files under dir.
+func vectorFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(path, ".json") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk corpus directory %s: %w", dir, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+func loadVector(path string) (*schema.TestVector, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close() //nolint:errcheck
+
+	var tv schema.TestVector
+	if err := json.NewDecoder(file).Decode(&tv); err != nil {
+		return nil, fmt.Errorf("failed to decode test vector: %w", err)
+	}
+	return &tv, nil
+}
+
+func vectorFileHash(path string) (string, error) {
+	tv, err := loadVector(path)
+	if err != nil {
+		return "", err
+	}
+	return VectorContentHash(tv)
+}
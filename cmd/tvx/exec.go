@@ -2,6 +2,7 @@ package main
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,9 +10,12 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
+	"github.com/docker/go-units"
 	"github.com/fatih/color"
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/network"
 	cbornode "github.com/ipfs/go-ipld-cbor"
 	"github.com/urfave/cli/v2"
 
@@ -19,7 +23,9 @@ import (
 
 	"github.com/filecoin-project/lotus/chain/state"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
 	"github.com/filecoin-project/lotus/conformance"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
 	"github.com/filecoin-project/lotus/lib/blockstore"
 )
 
@@ -27,16 +33,37 @@ var execFlags struct {
 	file               string
 	out                string
 	driverOpts         cli.StringSlice
+	assertHooks        cli.StringSlice
 	fallbackBlockstore bool
+	strictCAR          bool
+	witnessDir         string
+	skipSigVerify      bool
+	determinismRuns    int
+	maxFailures        int
+	knownFailures      string
+	sweepNV            string
+	bench              int
+	benchOut           string
+	sandbox            bool
+	vectorTimeout      time.Duration
+	vectorCPUTime      time.Duration
+	vectorMaxMemory    string
+	repo               string // passed on to sandboxed executions
 }
 
+// sweepVersions holds the network versions parsed from --sweep-nv.
+var sweepVersions []network.Version
+
+// results tallies the outcome of the vectors executed by tvx exec.
+var results = newExecResults(nil)
+
 const (
 	optSaveBalances = "save-balances"
 )
 
 var execCmd = &cli.Command{
 	Name:        "exec",
-	Description: "execute one or many test vectors against Lotus; supplied as a single JSON file, a directory, or a ndjson stdin stream",
+	Description: "execute one or many test vectors against Lotus; supplied as a single JSON file, a directory, or a ndjson stdin stream. Exits with a non-zero status if any vector fails, subject to --max-failures and --allow-known-failures",
 	Action:      runExec,
 	Flags: []cli.Flag{
 		&repoFlag,
@@ -51,11 +78,84 @@ var execCmd = &cli.Command{
 			Usage:       "sets the full node API as a fallback blockstore; use this if you're transplanting vectors and get block not found errors",
 			Destination: &execFlags.fallbackBlockstore,
 		},
+		&cli.BoolFlag{
+			Name:        "strict-car",
+			Usage:       "fail vectors reading any block missing from their CAR, even if the fallback blockstore resolves it; use this to catch incomplete extractions",
+			Destination: &execFlags.strictCAR,
+		},
+		&cli.StringFlag{
+			Name:        "witness-dir",
+			Usage:       "write the state witness of every variant to this directory, as <vector>-<variant>.witness.car: the pre-state blocks read during execution, rooted at the pre and post state roots, for stateless execution by other implementations",
+			TakesFile:   true,
+			Destination: &execFlags.witnessDir,
+		},
+		&cli.BoolFlag{
+			Name:        "skip-sig-verify",
+			Usage:       "accept all signatures checked by actors without verifying them; speeds up batch runs. Vectors selecting verify_actor_signatures=true are still verified",
+			Destination: &execFlags.skipSigVerify,
+		},
+		&cli.IntFlag{
+			Name:        "check-determinism",
+			Usage:       "re-execute every tipset of tipset-class vectors this many more times, concurrently, and fail if results differ from the serial execution",
+			Destination: &execFlags.determinismRuns,
+		},
+		&cli.IntFlag{
+			Name:        "max-failures",
+			Usage:       "exit with a non-zero status only if more than this many vectors fail, not counting known failures",
+			Destination: &execFlags.maxFailures,
+		},
+		&cli.StringFlag{
+			Name:        "allow-known-failures",
+			Usage:       "file listing the IDs of vectors which are expected to fail, one per line ('#' starts a comment); these don't affect the exit status",
+			TakesFile:   true,
+			Destination: &execFlags.knownFailures,
+		},
+		&cli.StringFlag{
+			Name:        "sweep-nv",
+			Usage:       "execute every vector at each of these network versions, e.g. '4..8' or '3,6..8', and report at which versions it passes, to locate the protocol change that affected it; vectors aren't counted as failed in this mode",
+			Destination: &execFlags.sweepNV,
+		},
+		&cli.IntFlag{
+			Name:        "bench",
+			Usage:       "benchmark the VM: after a vector passes, which warms caches up, execute it this many more times and report the mean, median and p95 execution times per vector",
+			Destination: &execFlags.bench,
+		},
+		&cli.StringFlag{
+			Name:        "bench-out",
+			Usage:       "write the benchmark results of --bench to this file as JSON, to compare VM changes",
+			TakesFile:   true,
+			Destination: &execFlags.benchOut,
+		},
+		&cli.BoolFlag{
+			Name:        "sandbox",
+			Usage:       "execute every vector in a tvx exec subprocess, so that a vector crashing the process only fails itself; implied by the --vector-* limits",
+			Destination: &execFlags.sandbox,
+		},
+		&cli.DurationFlag{
+			Name:        "vector-timeout",
+			Usage:       "fail vectors taking longer than this to execute, e.g. hanging ones",
+			Destination: &execFlags.vectorTimeout,
+		},
+		&cli.DurationFlag{
+			Name:        "vector-cpu-time",
+			Usage:       "fail vectors using more than this CPU time to execute",
+			Destination: &execFlags.vectorCPUTime,
+		},
+		&cli.StringFlag{
+			Name:        "vector-max-memory",
+			Usage:       "fail vectors allocating more heap than this to execute, e.g. '4GiB'",
+			Destination: &execFlags.vectorMaxMemory,
+		},
 		&cli.StringFlag{
 			Name:        "out",
 			Usage:       "output directory where to save the results, only used when the input is a directory",
 			Destination: &execFlags.out,
 		},
+		&cli.StringSliceFlag{
+			Name:        "assert-hook",
+			Usage:       "shell command asserting custom postconditions after each variant, e.g. checking an actor's state; gets the vector on stdin, and the post-state root and a CAR export of the post-state in the TVX_POST_ROOT and TVX_POST_STATE_CAR env vars; a non-zero exit status fails the vector",
+			Destination: &execFlags.assertHooks,
+		},
 		&cli.StringSliceFlag{
 			Name:        "driver-opt",
 			Usage:       "comma-separated list of driver options (EXPERIMENTAL; will change), supported: 'save-balances=<dst>', 'pipeline-basefee' (unimplemented); only available in single-file mode",
@@ -65,6 +165,28 @@ var execCmd = &cli.Command{
 }
 
 func runExec(c *cli.Context) error {
+	limits, sandboxed, err := sandboxedLimits()
+	if err != nil {
+		return err
+	}
+	if sandboxed {
+		startWatchdog(limits)
+	} else {
+		sandboxLimits = vectorLimits{
+			sandbox: execFlags.sandbox,
+			timeout: execFlags.vectorTimeout,
+			cpu:     execFlags.vectorCPUTime,
+		}
+		if execFlags.vectorMaxMemory != "" {
+			mem, err := units.RAMInBytes(execFlags.vectorMaxMemory)
+			if err != nil {
+				return fmt.Errorf("invalid --vector-max-memory: %w", err)
+			}
+			sandboxLimits.memory = uint64(mem)
+		}
+		execFlags.repo = c.String("repo")
+	}
+
 	if execFlags.fallbackBlockstore {
 		if err := initialize(c); err != nil {
 			return fmt.Errorf("fallback blockstore was enabled, but could not resolve lotus API endpoint: %w", err)
@@ -73,6 +195,57 @@ func runExec(c *cli.Context) error {
 		conformance.FallbackBlockstoreGetter = FullAPI
 	}
 
+	conformance.StrictCAR = execFlags.strictCAR
+
+	if dir := execFlags.witnessDir; dir != "" {
+		if err := ensureDir(dir); err != nil {
+			return err
+		}
+		conformance.WitnessHook = writeWitness(dir)
+	}
+
+	for _, hook := range execFlags.assertHooks.Value() {
+		conformance.PostconditionHooks = append(conformance.PostconditionHooks, conformance.SubprocessHook(hook))
+	}
+
+	if execFlags.skipSigVerify {
+		conformance.DriverSyscalls = conformance.SkipSignatureSyscalls(vm.Syscalls(ffiwrapper.ProofVerifier))
+	}
+
+	conformance.TipsetVectorOpts.DeterminismRuns = execFlags.determinismRuns
+
+	if execFlags.sweepNV != "" {
+		versions, err := parseNetworkVersions(execFlags.sweepNV)
+		if err != nil {
+			return err
+		}
+		sweepVersions = versions
+	}
+
+	if execFlags.bench > 0 && (execFlags.sweepNV != "" || sandboxLimits.enabled()) {
+		return fmt.Errorf("--bench can't be combined with --sweep-nv, --sandbox or the --vector-* limits")
+	}
+
+	if execFlags.knownFailures != "" {
+		known, err := loadKnownFailures(execFlags.knownFailures)
+		if err != nil {
+			return err
+		}
+		results = newExecResults(known)
+	}
+
+	if err := execVectors(); err != nil {
+		return err
+	}
+	if execFlags.bench > 0 {
+		if err := reportBench(execFlags.benchOut); err != nil {
+			return err
+		}
+	}
+	return results.check(execFlags.maxFailures)
+}
+
+func execVectors() error {
 	path := execFlags.file
 	if path == "" {
 		return execVectorsStdin()
@@ -100,7 +273,7 @@ func runExec(c *cli.Context) error {
 		return err
 	}
 
-	_, err = execVectorFile(new(conformance.LogReporter), path)
+	_, err = execVectorFile(new(execReporter), path)
 	return err
 }
 
@@ -150,7 +323,7 @@ func execVectorDir(path string, outdir string) error {
 
 		log.Printf("processing vector %s; sending output to %s", f, outpath)
 		log.SetOutput(io.MultiWriter(os.Stderr, outw)) // tee the output.
-		_, _ = execVectorFile(new(conformance.LogReporter), f)
+		_, _ = execVectorFile(new(execReporter), f)
 		log.SetOutput(os.Stderr)
 		_ = outw.Close()
 	}
@@ -158,14 +331,12 @@ func execVectorDir(path string, outdir string) error {
 }
 
 func execVectorsStdin() error {
-	r := new(conformance.LogReporter)
 	for dec := json.NewDecoder(os.Stdin); ; {
 		var tv schema.TestVector
 		switch err := dec.Decode(&tv); err {
 		case nil:
-			if _, err = executeTestVector(r, tv); err != nil {
-				return err
-			}
+			// failures are tallied in results; carry on with the next vector.
+			_, _ = executeTestVector(new(execReporter), tv)
 		case io.EOF:
 			// we're done.
 			return nil
@@ -184,22 +355,52 @@ func execVectorFile(r conformance.Reporter, path string) (diffs []string, error
 
 	var tv schema.TestVector
 	if err = json.NewDecoder(file).Decode(&tv); err != nil {
+		results.record(path, true)
 		return nil, fmt.Errorf("failed to decode test vector: %w", err)
 	}
 	return executeTestVector(r, tv)
 }
 
 func executeTestVector(r conformance.Reporter, tv schema.TestVector) (diffs []string, err error) {
+	if err := checkReference(&tv); err != nil {
+		results.record(tv.Meta.ID, true)
+		return nil, err
+	}
+
+	if reason := conformance.SkipReason(&tv); reason != "" {
+		log.Println(color.YellowString("⏭ skipped: %s: %s", tv.Meta.ID, reason))
+		results.skip(tv.Meta.ID, reason)
+		return nil, nil
+	}
+
+	// differences in proofs parameters or actors versions explain many
+	// otherwise mysterious mismatches.
+	for _, m := range conformance.EnvironmentMismatches(tv.Meta) {
+		log.Println(color.YellowString("⚠ environment differs from extraction: %s: %s", tv.Meta.ID, m))
+	}
+
+	if len(sweepVersions) > 0 {
+		sweepTestVector(tv, sweepVersions)
+		return nil, nil
+	}
+
+	if sandboxLimits.enabled() {
+		log.Println("executing test vector in a sandbox:", tv.Meta.ID)
+		failed, err := executeSandboxed(tv, sandboxLimits)
+		results.record(tv.Meta.ID, failed)
+		return nil, err
+	}
+
 	log.Println("executing test vector:", tv.Meta.ID)
 
+	defer func() {
+		results.record(tv.Meta.ID, err != nil || r.Failed())
+	}()
+
 	for _, v := range tv.Pre.Variants {
-		switch class, v := tv.Class, v; class {
-		case "message":
-			diffs, err = conformance.ExecuteMessageVector(r, &tv, &v)
-		case "tipset":
-			diffs, err = conformance.ExecuteTipsetVector(r, &tv, &v)
-		default:
-			return nil, fmt.Errorf("test vector class %s not supported", class)
+		diffs, err = executeVariant(r, &tv, &v)
+		if err != nil {
+			return nil, err
 		}
 
 		if r.Failed() {
@@ -209,5 +410,74 @@ func executeTestVector(r conformance.Reporter, tv schema.TestVector) (diffs []st
 		}
 	}
 
+	if execFlags.bench > 0 && !r.Failed() {
+		if err := benchTestVector(tv, execFlags.bench); err != nil {
+			return diffs, err
+		}
+	}
+
 	return diffs, err
 }
+
+// checkReference checks that the state of a reference vector can be fetched:
+// the fallback blockstore must be enabled, and backed by a node of the network
+// the state comes from.
+func checkReference(tv *schema.TestVector) error {
+	ntwk, ok := conformance.ReferenceNetwork(tv)
+	if !ok {
+		return nil
+	}
+	if conformance.FallbackBlockstoreGetter == nil {
+		return fmt.Errorf("%s is a reference vector; enable --fallback-blockstore with a %s node to fetch its state", tv.Meta.ID, ntwk)
+	}
+
+	actual, err := FullAPI.StateNetworkName(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to get the network name of the fallback node: %w", err)
+	}
+	if string(actual) != ntwk {
+		return fmt.Errorf("%s references state of network %s, but the fallback node is on %s", tv.Meta.ID, ntwk, actual)
+	}
+	return nil
+}
+
+func executeVariant(r conformance.Reporter, tv *schema.TestVector, v *schema.Variant) (diffs []string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			if _, ok := p.(vectorAborted); !ok {
+				panic(p)
+			}
+			err = fmt.Errorf("execution of variant %s aborted", v.ID)
+		}
+	}()
+
+	switch class := tv.Class; class {
+	case "message":
+		return conformance.ExecuteMessageVector(r, tv, v)
+	case "tipset":
+		return conformance.ExecuteTipsetVector(r, tv, v)
+	default:
+		return nil, fmt.Errorf("test vector class %s not supported", class)
+	}
+}
+
+// writeWitness returns a witness hook writing the witness of every variant to
+// a CAR file under dir.
+func writeWitness(dir string) func(*schema.TestVector, *schema.Variant, *conformance.Witness) error {
+	return func(tv *schema.TestVector, v *schema.Variant, w *conformance.Witness) error {
+		path := filepath.Join(dir, fmt.Sprintf("%s-%s.witness.car", tv.Meta.ID, v.ID))
+		f, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		if err := w.WriteCAR(f); err != nil {
+			_ = f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		log.Printf("wrote state witness of %d blocks to: %s", len(w.Blocks), path)
+		return nil
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/conformance"
+)
+
+// benchStats are the execution times of a vector benchmarked by tvx exec
+// --bench, over all of its variants.
+type benchStats struct {
+	ID     string        `json:"id"`
+	Runs   int           `json:"runs"`
+	Mean   time.Duration `json:"mean_ns"`
+	Median time.Duration `json:"median_ns"`
+	P95    time.Duration `json:"p95_ns"`
+	Min    time.Duration `json:"min_ns"`
+	Max    time.Duration `json:"max_ns"`
+}
+
+// benchResults collects the stats of the benchmarked vectors.
+var benchResults []benchStats
+
+// computeBenchStats summarises the execution times; percentiles use the
+// nearest rank.
+func computeBenchStats(id string, times []time.Duration) benchStats {
+	s := benchStats{ID: id, Runs: len(times)}
+	if len(times) == 0 {
+		return s
+	}
+
+	sorted := append([]time.Duration(nil), times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, t := range sorted {
+		total += t
+	}
+
+	rank := func(p float64) time.Duration {
+		i := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+
+	s.Mean = total / time.Duration(len(sorted))
+	s.Median = rank(0.5)
+	s.P95 = rank(0.95)
+	s.Min = sorted[0]
+	s.Max = sorted[len(sorted)-1]
+	return s
+}
+
+// benchTestVector executes every variant of a vector which passed n more
+// times, the first execution having warmed caches up. Hooks, determinism
+// checks and tipset callbacks don't run, so that only execution is timed.
+func benchTestVector(tv schema.TestVector, n int) error {
+	witness, hooks, opts := conformance.WitnessHook, conformance.PostconditionHooks, conformance.TipsetVectorOpts
+	conformance.WitnessHook, conformance.PostconditionHooks = nil, nil
+	conformance.TipsetVectorOpts.DeterminismRuns = 0
+	conformance.TipsetVectorOpts.OnTipsetApplied = nil
+	defer func() {
+		conformance.WitnessHook, conformance.PostconditionHooks, conformance.TipsetVectorOpts = witness, hooks, opts
+	}()
+
+	log.Printf("benchmarking test vector %s: %d runs", tv.Meta.ID, n)
+
+	times := make([]time.Duration, 0, n)
+	for i := 0; i < n; i++ {
+		start := time.Now()
+		for _, v := range tv.Pre.Variants {
+			r := new(sweepReporter)
+			if _, err := executeVariant(r, &tv, &v); err != nil {
+				return err
+			}
+			if r.Failed() {
+				return fmt.Errorf("variant %s failed in benchmark run %d: %s", v.ID, i+1, r.firstErr)
+			}
+		}
+		times = append(times, time.Since(start))
+	}
+
+	s := computeBenchStats(tv.Meta.ID, times)
+	benchResults = append(benchResults, s)
+	log.Printf("%s: mean %s, median %s, p95 %s", s.ID, s.Mean, s.Median, s.P95)
+	return nil
+}
+
+// reportBench logs the stats of all benchmarked vectors, and writes them to
+// out as JSON if set.
+func reportBench(out string) error {
+	log.Printf("benchmarked %d vectors:", len(benchResults))
+	for _, s := range benchResults {
+		log.Printf("%s: runs %d, mean %s, median %s, p95 %s, min %s, max %s", s.ID, s.Runs, s.Mean, s.Median, s.P95, s.Min, s.Max)
+	}
+
+	if out == "" {
+		return nil
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return fmt.Errorf("failed to create benchmark output file: %w", err)
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(benchResults); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write benchmark results: %w", err)
+	}
+	return f.Close()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeBenchStats(t *testing.T) {
+	var times []time.Duration
+	for i := 20; i >= 1; i-- {
+		times = append(times, time.Duration(i)*time.Millisecond)
+	}
+
+	s := computeBenchStats("v", times)
+	if s.Runs != 20 {
+		t.Fatalf("expected 20 runs, got %d", s.Runs)
+	}
+	if s.Mean != 10500*time.Microsecond {
+		t.Fatalf("unexpected mean: %s", s.Mean)
+	}
+	if s.Median != 10*time.Millisecond {
+		t.Fatalf("unexpected median: %s", s.Median)
+	}
+	if s.P95 != 19*time.Millisecond {
+		t.Fatalf("unexpected p95: %s", s.P95)
+	}
+	if s.Min != time.Millisecond || s.Max != 20*time.Millisecond {
+		t.Fatalf("unexpected min/max: %s/%s", s.Min, s.Max)
+	}
+	if times[0] != 20*time.Millisecond {
+		t.Fatal("expected the input not to be reordered")
+	}
+
+	if s := computeBenchStats("v", []time.Duration{time.Second}); s.P95 != time.Second || s.Median != time.Second {
+		t.Fatalf("unexpected stats of a single run: %+v", s)
+	}
+}
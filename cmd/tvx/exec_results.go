@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/filecoin-project/lotus/conformance"
+)
+
+// execResults tallies the outcome of the vectors run by tvx exec, so that it
+// can gate CI pipelines through its exit status.
+type execResults struct {
+	// known holds the IDs of vectors which are expected to fail.
+	known map[string]struct{}
+
+	total       int
+	failed      []string // unexpected failures
+	knownFailed []string
+	knownPassed []string
+	skipped     []skippedVector
+}
+
+// skippedVector is a vector which wasn't executed, as its selector or hints
+// require something this implementation doesn't support.
+type skippedVector struct {
+	id     string
+	reason string
+}
+
+func newExecResults(known map[string]struct{}) *execResults {
+	if known == nil {
+		known = map[string]struct{}{}
+	}
+	return &execResults{known: known}
+}
+
+func (r *execResults) record(id string, failed bool) {
+	r.total++
+
+	_, known := r.known[id]
+	switch {
+	case failed && known:
+		r.knownFailed = append(r.knownFailed, id)
+	case failed:
+		r.failed = append(r.failed, id)
+	case known:
+		r.knownPassed = append(r.knownPassed, id)
+	}
+}
+
+// skip records a vector which wasn't executed; skipped vectors don't count as
+// executed, nor as failed.
+func (r *execResults) skip(id, reason string) {
+	r.skipped = append(r.skipped, skippedVector{id: id, reason: reason})
+}
+
+// check logs a summary, and returns an error if there were more unexpected
+// failures than tolerated.
+func (r *execResults) check(maxFailures int) error {
+	log.Printf("executed %d vectors: %d failed, %d known failures, %d skipped", r.total, len(r.failed), len(r.knownFailed), len(r.skipped))
+	for _, id := range r.failed {
+		log.Printf("failed: %s", id)
+	}
+	for _, s := range r.skipped {
+		log.Printf("skipped: %s: %s", s.id, s.reason)
+	}
+	for _, id := range r.knownPassed {
+		log.Printf("known failure %s passed; consider removing it from the list", id)
+	}
+
+	if len(r.failed) > maxFailures {
+		return fmt.Errorf("%d vectors failed, tolerating at most %d", len(r.failed), maxFailures)
+	}
+	return nil
+}
+
+// loadKnownFailures reads a list of vector IDs which are expected to fail, one
+// per line. Empty lines and anything after a '#' are ignored.
+func loadKnownFailures(path string) (map[string]struct{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open known failures file: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	out := map[string]struct{}{}
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		line := s.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			out[line] = struct{}{}
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read known failures file: %w", err)
+	}
+	return out, nil
+}
+
+// vectorAborted is raised by execReporter to abandon the current vector.
+type vectorAborted struct{}
+
+// execReporter is a conformance.LogReporter that abandons the current vector
+// on fatal failures, instead of exiting the process, so that the remaining
+// vectors still get executed and counted.
+type execReporter struct {
+	conformance.LogReporter
+}
+
+var _ conformance.Reporter = (*execReporter)(nil)
+
+func (r *execReporter) FailNow() {
+	panic(vectorAborted{})
+}
+
+func (r *execReporter) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	panic(vectorAborted{})
+}
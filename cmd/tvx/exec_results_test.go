@@ -0,0 +1,46 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tvx-known-failures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	path := filepath.Join(dir, "known")
+	if err := ioutil.WriteFile(path, []byte("# flaky\nvector-a\n\n  vector-b  # slow\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	known, err := loadKnownFailures(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(known) != 2 {
+		t.Fatalf("expected 2 known failures, got %v", known)
+	}
+
+	r := newExecResults(known)
+	r.record("vector-a", true)
+	r.record("vector-b", false)
+	r.record("vector-c", false)
+	r.skip("vector-e", "unsupported selector")
+	if err := r.check(0); err != nil {
+		t.Fatalf("known failures must not fail the run: %s", err)
+	}
+
+	r.record("vector-d", true)
+	if err := r.check(0); err == nil {
+		t.Fatal("expected an unexpected failure to fail the run")
+	}
+	if err := r.check(1); err != nil {
+		t.Fatalf("expected a failure within --max-failures to be tolerated: %s", err)
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+const (
+	// envSandboxed is set on the tvx exec subprocesses vectors are sandboxed
+	// in; envCPULimit and envMemLimit carry their limits.
+	envSandboxed = "TVX_SANDBOXED"
+	envCPULimit  = "TVX_VECTOR_CPU_LIMIT"
+	envMemLimit  = "TVX_VECTOR_MEM_LIMIT"
+
+	// exitLimitExceeded is the exit status of a sandboxed vector execution
+	// which exceeded its CPU time or memory limit.
+	exitLimitExceeded = 3
+)
+
+// watchdogInterval is how often a sandboxed execution checks its resource
+// usage.
+var watchdogInterval = 250 * time.Millisecond
+
+// vectorLimits are the resource limits of a sandboxed vector execution.
+type vectorLimits struct {
+	sandbox bool          // run vectors in subprocesses, even without limits
+	timeout time.Duration // wall clock, enforced by killing the subprocess
+	cpu     time.Duration // CPU time, enforced by the subprocess
+	memory  uint64        // heap bytes, enforced by the subprocess
+}
+
+var sandboxLimits vectorLimits
+
+func (l vectorLimits) enabled() bool {
+	return l.sandbox || l.timeout > 0 || l.cpu > 0 || l.memory > 0
+}
+
+// exceeded returns a description of the limit the usage exceeds, or an empty
+// string.
+func (l vectorLimits) exceeded(cpu time.Duration, heap uint64) string {
+	if l.cpu > 0 && cpu > l.cpu {
+		return fmt.Sprintf("used %s of CPU time, limit is %s", cpu, l.cpu)
+	}
+	if l.memory > 0 && heap > l.memory {
+		return fmt.Sprintf("allocated %d bytes of heap, limit is %d", heap, l.memory)
+	}
+	return ""
+}
+
+// sandboxArgs are the arguments of the tvx exec subprocess executing a vector
+// read from its stdin, with the options of this process.
+func sandboxArgs() []string {
+	args := []string{"exec"}
+	if execFlags.fallbackBlockstore {
+		args = append(args, "--fallback-blockstore", "--repo", execFlags.repo)
+	}
+	if execFlags.strictCAR {
+		args = append(args, "--strict-car")
+	}
+	if execFlags.witnessDir != "" {
+		args = append(args, "--witness-dir", execFlags.witnessDir)
+	}
+	if execFlags.skipSigVerify {
+		args = append(args, "--skip-sig-verify")
+	}
+	if execFlags.determinismRuns > 0 {
+		args = append(args, "--check-determinism", strconv.Itoa(execFlags.determinismRuns))
+	}
+	for _, hook := range execFlags.assertHooks.Value() {
+		args = append(args, "--assert-hook", hook)
+	}
+	return args
+}
+
+// executeSandboxed executes the vector in a tvx exec subprocess, so that a
+// vector hanging, or exhausting the CPU time or memory limits, only fails
+// itself. It returns whether the vector failed.
+func executeSandboxed(tv schema.TestVector, limits vectorLimits) (failed bool, err error) {
+	self, err := os.Executable()
+	if err != nil {
+		return true, fmt.Errorf("failed to locate the tvx executable: %w", err)
+	}
+
+	in, err := json.Marshal(&tv)
+	if err != nil {
+		return true, fmt.Errorf("failed to serialize vector: %w", err)
+	}
+
+	ctx := context.Background()
+	if limits.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, limits.timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, self, sandboxArgs()...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	cmd.Env = append(os.Environ(),
+		envSandboxed+"=1",
+		envCPULimit+"="+limits.cpu.String(),
+		envMemLimit+"="+strconv.FormatUint(limits.memory, 10),
+	)
+
+	err = cmd.Run()
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		log.Printf("❌ test vector %s timed out after %s", tv.Meta.ID, limits.timeout)
+		return true, nil
+	case err == nil:
+		return false, nil
+	case errors.As(err, &exitErr):
+		if exitErr.ExitCode() == exitLimitExceeded {
+			log.Printf("❌ test vector %s exceeded its resource limits", tv.Meta.ID)
+		}
+		return true, nil
+	default:
+		return true, fmt.Errorf("failed to run sandboxed execution of %s: %w", tv.Meta.ID, err)
+	}
+}
+
+// sandboxedLimits returns the limits of this process if it is a sandboxed
+// vector execution.
+func sandboxedLimits() (vectorLimits, bool, error) {
+	if os.Getenv(envSandboxed) == "" {
+		return vectorLimits{}, false, nil
+	}
+
+	var l vectorLimits
+	if s := os.Getenv(envCPULimit); s != "" {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return l, true, fmt.Errorf("invalid %s: %w", envCPULimit, err)
+		}
+		l.cpu = d
+	}
+	if s := os.Getenv(envMemLimit); s != "" {
+		m, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return l, true, fmt.Errorf("invalid %s: %w", envMemLimit, err)
+		}
+		l.memory = m
+	}
+	return l, true, nil
+}
+
+// startWatchdog exits the process once it exceeds the CPU time or memory
+// limits. The VM can't be interrupted, so the process is the unit of
+// isolation.
+func startWatchdog(limits vectorLimits) {
+	if limits.cpu <= 0 && limits.memory <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(watchdogInterval) {
+			var ru syscall.Rusage
+			if err := syscall.Getrusage(syscall.RUSAGE_SELF, &ru); err != nil {
+				log.Printf("failed to get resource usage: %s", err)
+				continue
+			}
+			cpu := time.Duration(ru.Utime.Nano() + ru.Stime.Nano())
+
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+
+			if what := limits.exceeded(cpu, ms.HeapAlloc); what != "" {
+				log.Printf("aborting vector execution: %s", what)
+				os.Exit(exitLimitExceeded)
+			}
+		}
+	}()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestVectorLimits(t *testing.T) {
+	if (vectorLimits{}).enabled() {
+		t.Fatal("expected no sandboxing without limits")
+	}
+	if !(vectorLimits{timeout: time.Minute}).enabled() {
+		t.Fatal("expected limits to imply sandboxing")
+	}
+
+	l := vectorLimits{cpu: time.Minute, memory: 1 << 30}
+	if s := l.exceeded(time.Second, 1<<20); s != "" {
+		t.Fatalf("expected usage within limits, got %q", s)
+	}
+	if s := l.exceeded(2*time.Minute, 1<<20); s == "" {
+		t.Fatal("expected CPU time limit to be exceeded")
+	}
+	if s := l.exceeded(time.Second, 2<<30); s == "" {
+		t.Fatal("expected memory limit to be exceeded")
+	}
+}
+
+func TestSandboxedLimits(t *testing.T) {
+	defer os.Unsetenv(envSandboxed) //nolint:errcheck
+	defer os.Unsetenv(envCPULimit)  //nolint:errcheck
+	defer os.Unsetenv(envMemLimit)  //nolint:errcheck
+
+	if _, sandboxed, err := sandboxedLimits(); err != nil || sandboxed {
+		t.Fatalf("expected not to be sandboxed, got %t, %v", sandboxed, err)
+	}
+
+	_ = os.Setenv(envSandboxed, "1")
+	_ = os.Setenv(envCPULimit, "1m30s")
+	_ = os.Setenv(envMemLimit, "1024")
+
+	l, sandboxed, err := sandboxedLimits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sandboxed || l.cpu != 90*time.Second || l.memory != 1024 {
+		t.Fatalf("unexpected limits %+v (sandboxed: %t)", l, sandboxed)
+	}
+
+	_ = os.Setenv(envMemLimit, "lots")
+	if _, _, err := sandboxedLimits(); err == nil {
+		t.Fatal("expected error parsing invalid memory limit")
+	}
+}
+
+func TestSandboxArgs(t *testing.T) {
+	saved := execFlags
+	defer func() {
+		execFlags = saved
+	}()
+
+	execFlags.fallbackBlockstore = true
+	execFlags.repo = "/repo"
+	execFlags.skipSigVerify = true
+	execFlags.determinismRuns = 2
+	_ = execFlags.assertHooks.Set("check.sh")
+
+	expected := []string{"exec", "--fallback-blockstore", "--repo", "/repo", "--skip-sig-verify", "--check-determinism", "2", "--assert-hook", "check.sh"}
+	if args := sandboxArgs(); !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected args %v, got %v", expected, args)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+
+	"github.com/filecoin-project/go-state-types/network"
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/conformance"
+)
+
+// parseNetworkVersions parses a network version range like "4..8", or a
+// comma-separated list of versions and ranges.
+func parseNetworkVersions(s string) ([]network.Version, error) {
+	var out []network.Version
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+
+		from, to := part, part
+		if i := strings.Index(part, ".."); i >= 0 {
+			from, to = part[:i], part[i+2:]
+		}
+
+		lo, err := strconv.ParseUint(from, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network version %q: %w", from, err)
+		}
+		hi, err := strconv.ParseUint(to, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network version %q: %w", to, err)
+		}
+		if lo > hi {
+			return nil, fmt.Errorf("invalid network version range %q", part)
+		}
+		if hi > uint64(build.NewestNetworkVersion) {
+			return nil, fmt.Errorf("network version %d is not supported; the newest is %d", hi, build.NewestNetworkVersion)
+		}
+
+		for v := lo; v <= hi; v++ {
+			out = append(out, network.Version(v))
+		}
+	}
+	return out, nil
+}
+
+// sweepReporter is an execReporter that only remembers the first error, so
+// that sweeps aren't drowned in the output of failing executions.
+type sweepReporter struct {
+	execReporter
+
+	failed   bool
+	firstErr string
+}
+
+var _ conformance.Reporter = (*sweepReporter)(nil)
+
+func (r *sweepReporter) Log(...interface{})          {}
+func (r *sweepReporter) Logf(string, ...interface{}) {}
+func (r *sweepReporter) Failed() bool                { return r.failed }
+
+func (r *sweepReporter) Errorf(format string, args ...interface{}) {
+	if !r.failed {
+		r.firstErr = fmt.Sprintf(format, args...)
+	}
+	r.failed = true
+}
+
+func (r *sweepReporter) Fatalf(format string, args ...interface{}) {
+	r.Errorf(format, args...)
+	panic(vectorAborted{})
+}
+
+// sweepTestVector executes every variant of the vector at each of the network
+// versions, and reports at which versions it passes.
+func sweepTestVector(tv schema.TestVector, versions []network.Version) {
+	log.Println("sweeping test vector:", tv.Meta.ID)
+
+	defer func() {
+		conformance.DriverNetworkVersion = nil
+	}()
+
+	for _, v := range tv.Pre.Variants {
+		var passed, failed []string
+		for _, nv := range versions {
+			nv := nv
+			conformance.DriverNetworkVersion = &nv
+
+			r := new(sweepReporter)
+			_, err := executeVariant(r, &tv, &v)
+			if err != nil {
+				r.Errorf("%s", err)
+			}
+
+			if r.Failed() {
+				failed = append(failed, strconv.Itoa(int(nv)))
+				log.Println(color.HiRedString("❌ variant %s fails at network version %d: %s", v.ID, nv, r.firstErr))
+			} else {
+				passed = append(passed, strconv.Itoa(int(nv)))
+				log.Println(color.GreenString("✅ variant %s passes at network version %d", v.ID, nv))
+			}
+		}
+
+		log.Printf("variant %s (recorded at network version %d): passes at [%s], fails at [%s]",
+			v.ID, v.NetworkVersion, strings.Join(passed, " "), strings.Join(failed, " "))
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/network"
+)
+
+func TestParseNetworkVersions(t *testing.T) {
+	got, err := parseNetworkVersions("1, 3..5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []network.Version{1, 3, 4, 5}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+
+	for _, bad := range []string{"", "a", "5..3", "1..", "1000"} {
+		if _, err := parseNetworkVersions(bad); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
@@ -1,15 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 
 	"github.com/filecoin-project/test-vectors/schema"
 	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/lotus/conformance"
 )
 
 const (
@@ -17,6 +21,10 @@ const (
 	PrecursorSelectSender = "sender"
 )
 
+// DefaultReorgRetries is how many times an extraction is retried when
+// the chain reorgs while it's in progress.
+const DefaultReorgRetries = 3
+
 type extractOpts struct {
 	id                 string
 	block              string
@@ -28,10 +36,41 @@ type extractOpts struct {
 	precursor          string
 	ignoreSanityChecks bool
 	squash             bool
+	reorgRetries       int
+	cron               string
+	genesisTimestamp   uint64
+	reference          bool
+
+	// sink, when set, receives the vector under the name in file, instead of
+	// it being written to that file.
+	sink vectorSink
 }
 
 var extractFlags extractOpts
 
+// carFlags select the compression of the CAR embedded in extracted vectors.
+var carFlags = struct {
+	codec string
+	level int
+}{
+	codec: string(conformance.CARCodecGzip),
+	level: conformance.DefaultCARLevel,
+}
+
+var carCodecFlag = cli.StringFlag{
+	Name:        "car-codec",
+	Usage:       "compression of the CAR embedded in the vector; values: 'gzip', 'zstd'. zstd is much faster and smaller for big tipset vectors, but only tools built from this tree can read it",
+	Value:       string(conformance.CARCodecGzip),
+	Destination: &carFlags.codec,
+}
+
+var carLevelFlag = cli.IntFlag{
+	Name:        "car-level",
+	Usage:       "compression level of the embedded CAR; 1-9 for gzip, 1-20 for zstd, -1 for the codec's default",
+	Value:       conformance.DefaultCARLevel,
+	Destination: &carFlags.level,
+}
+
 var extractCmd = &cli.Command{
 	Name:        "extract",
 	Description: "generate a test vector by extracting it from a live chain",
@@ -40,6 +79,9 @@ var extractCmd = &cli.Command{
 	After:       destroy,
 	Flags: []cli.Flag{
 		&repoFlag,
+		&fromCarFlag,
+		&carCodecFlag,
+		&carLevelFlag,
 		&cli.StringFlag{
 			Name:        "class",
 			Usage:       "class of vector to extract; values: 'message', 'tipset'",
@@ -48,8 +90,7 @@ var extractCmd = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:        "id",
-			Usage:       "identifier to name this test vector with",
-			Value:       "(undefined)",
+			Usage:       "identifier to name this test vector with; derived from the network and the extracted message or tipsets if not provided",
 			Destination: &extractFlags.id,
 		},
 		&cli.StringFlag{
@@ -75,7 +116,7 @@ var extractCmd = &cli.Command{
 		&cli.StringFlag{
 			Name:        "out",
 			Aliases:     []string{"o"},
-			Usage:       "file to write test vector to, or directory to write the batch to",
+			Usage:       "file to write test vector to, or where to write the batch to: " + sinkUsage,
 			Destination: &extractFlags.file,
 		},
 		&cli.StringFlag{
@@ -100,12 +141,34 @@ var extractCmd = &cli.Command{
 			Value:       false,
 			Destination: &extractFlags.ignoreSanityChecks,
 		},
+		&cli.IntFlag{
+			Name:        "reorg-retries",
+			Usage:       "number of times to retry an extraction if the chain reorgs while extracting; retries resolve tipsets at finality",
+			Value:       DefaultReorgRetries,
+			Destination: &extractFlags.reorgRetries,
+		},
 		&cli.BoolFlag{
 			Name:        "squash",
 			Usage:       "when extracting a tipset range, squash all tipsets into a single vector",
 			Value:       false,
 			Destination: &extractFlags.squash,
 		},
+		&cli.StringFlag{
+			Name:        "cron",
+			Usage:       "when cron is ticked while applying tipsets: every_epoch, exec_epoch (not for null rounds) or never; recorded in the vector",
+			Value:       string(conformance.CronEveryEpoch),
+			Destination: &extractFlags.cron,
+		},
+		&cli.Uint64Flag{
+			Name:        "genesis-timestamp",
+			Usage:       "pin the clock during execution to this genesis timestamp (unix seconds) plus the epoch times the block delay; recorded in the vector",
+			Destination: &extractFlags.genesisTimestamp,
+		},
+		&cli.BoolFlag{
+			Name:        "reference",
+			Usage:       "generate a reference vector, embedding no state; at execution time, state is fetched from a node of the same network with --fallback-blockstore. Use this for tipsets touching too much state to embed",
+			Destination: &extractFlags.reference,
+		},
 	},
 }
 
@@ -120,6 +183,72 @@ func runExtract(_ *cli.Context) error {
 	}
 }
 
+// executionControls returns the driver options controlling cron and the clock
+// during extraction, and the selector entries recording them in the vector.
+func (o extractOpts) executionControls() (conformance.DriverOpts, schema.Selector, error) {
+	cron, err := conformance.ParseCronMode(o.cron)
+	if err != nil {
+		return conformance.DriverOpts{}, nil, err
+	}
+
+	opts := conformance.DriverOpts{
+		DisableVMFlush: true,
+		Cron:           cron,
+	}
+	if o.genesisTimestamp != 0 {
+		ts := o.genesisTimestamp
+		opts.GenesisTimestamp = &ts
+	}
+	return opts, conformance.ExecutionControls(opts.Cron, opts.GenesisTimestamp), nil
+}
+
+// referenceVector turns the vector into a reference vector, if requested: the
+// CAR is dropped, and the network the state comes from is recorded.
+func (o extractOpts) referenceVector(vector *schema.TestVector, ntwkName string) {
+	if !o.reference {
+		return
+	}
+	vector.CAR = nil
+	vector.Selector[conformance.SelectorStateReference] = ntwkName
+}
+
+// checkCARFlags fails if the CAR compression flags are invalid, before any
+// expensive extraction work is done.
+func checkCARFlags() error {
+	codec, err := conformance.ParseCARCodec(carFlags.codec)
+	if err != nil {
+		return err
+	}
+	w, err := conformance.NewCARWriter(ioutil.Discard, codec, carFlags.level)
+	if err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// compressCAR writes a CAR through the compression selected by carFlags, and
+// returns the compressed bytes.
+func compressCAR(write func(w io.Writer) error) ([]byte, error) {
+	codec, err := conformance.ParseCARCodec(carFlags.codec)
+	if err != nil {
+		return nil, err
+	}
+
+	out := new(bytes.Buffer)
+	w, err := conformance.NewCARWriter(out, codec, carFlags.level)
+	if err != nil {
+		return nil, err
+	}
+	if err := write(w); err != nil {
+		_ = w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
 // writeVector writes the vector into the specified file, or to stdout if
 // file is empty.
 func writeVector(vector *schema.TestVector, file string) (err error) {
@@ -143,19 +272,27 @@ func writeVector(vector *schema.TestVector, file string) (err error) {
 }
 
 // writeVectors writes each vector to a different file under the specified
-// directory.
-func writeVectors(dir string, vectors ...*schema.TestVector) error {
-	// verify the output directory exists.
-	if err := ensureDir(dir); err != nil {
+// sink target; see openSink.
+func writeVectors(target string, vectors ...*schema.TestVector) error {
+	sink, err := openSink(target)
+	if err != nil {
 		return err
 	}
 	// write each vector to its file.
 	for _, v := range vectors {
-		id := v.Meta.ID
-		path := filepath.Join(dir, fmt.Sprintf("%s.json", id))
-		if err := writeVector(v, path); err != nil {
+		if err := sink.Put(fmt.Sprintf("%s.json", v.Meta.ID), v); err != nil {
+			_ = sink.Close()
 			return err
 		}
 	}
-	return nil
+	return sink.Close()
+}
+
+// writeVector writes the vector to the sink of the options if there is one,
+// and to the file of the options otherwise.
+func (o *extractOpts) writeVector(vector *schema.TestVector) error {
+	if o.sink != nil {
+		return o.sink.Put(o.file, vector)
+	}
+	return writeVector(vector, o.file)
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/fatih/color"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// errReorg is returned when a tipset used for extraction was reorged out of
+// the canonical chain while the extraction was in progress.
+var errReorg = errors.New("chain reorged during extraction")
+
+// finalitySource is the prefix of the generation data entry recording whether
+// the extracted tipsets were final at extraction time.
+const finalitySource = "finality:"
+
+// checkFinality verifies that the supplied tipsets are still on the canonical
+// chain, returning errReorg if any of them isn't. On success, it returns the
+// generation data entry recording the finality status of the tipsets, taken
+// from the highest one.
+func checkFinality(ctx context.Context, tss ...*types.TipSet) (schema.GenerationData, error) {
+	head, err := FullAPI.ChainHead(ctx)
+	if err != nil {
+		return schema.GenerationData{}, fmt.Errorf("failed to get chain head: %w", err)
+	}
+
+	var top *types.TipSet
+	for _, ts := range tss {
+		canon, err := FullAPI.ChainGetTipSetByHeight(ctx, ts.Height(), head.Key())
+		if err != nil {
+			return schema.GenerationData{}, fmt.Errorf("failed to get canonical tipset at height %d: %w", ts.Height(), err)
+		}
+		if canon.Key() != ts.Key() {
+			log.Println(color.YellowString("tipset %s at height %d is no longer canonical (now %s; head: %d)", ts.Key(), ts.Height(), canon.Key(), head.Height()))
+			return schema.GenerationData{}, errReorg
+		}
+		if top == nil || ts.Height() > top.Height() {
+			top = ts
+		}
+	}
+
+	if head.Height()-top.Height() >= build.Finality {
+		return schema.GenerationData{Source: finalitySource + "final"}, nil
+	}
+
+	log.Println(color.YellowString("extracted tipset at height %d is not final yet (head: %d); the vector may be invalidated by a reorg", top.Height(), head.Height()))
+	return schema.GenerationData{
+		Source:  finalitySource + "unfinalized",
+		Version: fmt.Sprintf("head:%d", head.Height()),
+	}, nil
+}
+
+// withReorgRetries runs the extraction, and runs it again when the chain
+// reorged while it was in progress, up to opts.reorgRetries times. Retries
+// are told to resolve their tipsets at finality, see canonicalFinal.
+func withReorgRetries(opts extractOpts, extract func(final bool) error) error {
+	for attempt := 0; ; attempt++ {
+		err := extract(attempt > 0)
+		if !errors.Is(err, errReorg) || attempt >= opts.reorgRetries {
+			return err
+		}
+		log.Println(color.YellowString("chain reorged during extraction; retrying at finality (attempt %d of %d)", attempt+1, opts.reorgRetries))
+	}
+}
+
+// canonicalFinal returns the canonical tipset at the height, looked up from
+// the tipset at finality rather than from the head, so that a retried
+// extraction doesn't pick the tipset of another fork which may be reorged out
+// too. Heights which aren't final yet can't be resolved.
+func canonicalFinal(ctx context.Context, h abi.ChainEpoch) (*types.TipSet, error) {
+	head, err := FullAPI.ChainHead(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get chain head: %w", err)
+	}
+
+	final := head.Height() - build.Finality
+	if h > final {
+		return nil, fmt.Errorf("tipset at height %d was reorged and isn't final yet (head: %d); retry the extraction after height %d", h, head.Height(), h+build.Finality)
+	}
+
+	anchor, err := FullAPI.ChainGetTipSetByHeight(ctx, final, head.Key())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tipset at finality (height %d): %w", final, err)
+	}
+
+	ts, err := FullAPI.ChainGetTipSetByHeight(ctx, h, anchor.Key())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get final tipset at height %d: %w", h, err)
+	}
+	return ts, nil
+}
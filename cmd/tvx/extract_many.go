@@ -6,7 +6,7 @@ import (
 	"io"
 	"log"
 	"os"
-	"path/filepath"
+	"path"
 	"strconv"
 	"strings"
 
@@ -47,6 +47,9 @@ var extractManyCmd = &cli.Command{
 	After:  destroy,
 	Flags: []cli.Flag{
 		&repoFlag,
+		&fromCarFlag,
+		&carCodecFlag,
+		&carLevelFlag,
 		&cli.StringFlag{
 			Name:        "batch-id",
 			Usage:       "batch id; a four-digit left-zero-padded sequential number (e.g. 0041)",
@@ -60,7 +63,7 @@ var extractManyCmd = &cli.Command{
 		},
 		&cli.StringFlag{
 			Name:        "outdir",
-			Usage:       "output directory",
+			Usage:       "where to write the vectors to: " + sinkUsage,
 			Destination: &extractManyFlags.outdir,
 		},
 	},
@@ -96,10 +99,16 @@ func runExtractMany(c *cli.Context) error {
 		return fmt.Errorf("could not open file %s: %w", in, err)
 	}
 
-	// Ensure the output directory exists.
-	if err := os.MkdirAll(outdir, 0755); err != nil {
-		return fmt.Errorf("could not create output dir %s: %w", outdir, err)
+	// Open the output sink, creating the output directory if needed.
+	sink, err := openSink(outdir)
+	if err != nil {
+		return fmt.Errorf("could not open output %s: %w", outdir, err)
 	}
+	defer func() {
+		if err := sink.Close(); err != nil {
+			log.Println(color.RedString("failed to close output %s: %s", outdir, err))
+		}
+	}()
 
 	// Create a CSV reader and validate the header row.
 	reader := csv.NewReader(f)
@@ -174,8 +183,8 @@ func runExtractMany(c *cli.Context) error {
 
 		// Compute the ID of the vector.
 		id := fmt.Sprintf("ext-%s-%s-%s-%s-%s", extractManyFlags.batchId, actorcodename, methodname, exitcodename, seq)
-		// Vector filename, using a base of outdir.
-		file := filepath.Join(outdir, actorcodename, methodname, exitcodename, id) + ".json"
+		// Vector name, relative to the output sink.
+		file := path.Join(actorcodename, methodname, exitcodename, id) + ".json"
 
 		log.Println(color.YellowString("processing message cid with 'sender' precursor mode: %s", id))
 
@@ -185,8 +194,11 @@ func runExtractMany(c *cli.Context) error {
 			class:     "message",
 			cid:       mcid,
 			file:      file,
+			sink:      sink,
 			retain:    "accessed-cids",
 			precursor: PrecursorSelectSender,
+
+			reorgRetries: DefaultReorgRetries,
 		}
 
 		if err := doExtractMessage(opts); err != nil {
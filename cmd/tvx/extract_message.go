@@ -1,8 +1,6 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -10,6 +8,7 @@ import (
 
 	"github.com/fatih/color"
 	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/chain/actors/builtin"
@@ -25,6 +24,19 @@ import (
 )
 
 func doExtractMessage(opts extractOpts) error {
+	return withReorgRetries(opts, func(final bool) error {
+		if final {
+			// the block hint may point to an orphaned block; let the node
+			// locate the message on the canonical chain.
+			opts.block = ""
+		}
+		return extractMessageOnce(opts, final)
+	})
+}
+
+// extractMessageOnce extracts the message vector; with final set, the message
+// must have been executed in a final tipset
+func extractMessageOnce(opts extractOpts, final bool) error {
 	ctx := context.Background()
 
 	if opts.cid == "" {
@@ -36,10 +48,15 @@ func doExtractMessage(opts extractOpts) error {
 		return err
 	}
 
-	msg, execTs, incTs, err := resolveFromChain(ctx, FullAPI, mcid, opts.block)
+	msg, execTs, incTs, branch, err := resolveFromChain(ctx, FullAPI, mcid, opts.block)
 	if err != nil {
 		return fmt.Errorf("failed to resolve message and tipsets from chain: %w", err)
 	}
+	if final {
+		if _, err := canonicalFinal(ctx, execTs.Height()); err != nil {
+			return err
+		}
+	}
 
 	// get the circulating supply before the message was executed.
 	circSupplyDetail, err := FullAPI.StateVMCirculatingSupplyInternal(ctx, incTs.Key())
@@ -51,6 +68,7 @@ func doExtractMessage(opts extractOpts) error {
 
 	log.Printf("message was executed in tipset: %s", execTs.Key())
 	log.Printf("message was included in tipset: %s", incTs.Key())
+	log.Printf("tipsets are on chain branch: %s", branch)
 	log.Printf("circulating supply at inclusion tipset: %d", circSupply)
 	log.Printf("finding precursor messages using mode: %s", opts.precursor)
 
@@ -86,9 +104,11 @@ func doExtractMessage(opts extractOpts) error {
 		g   = NewSurgeon(ctx, FullAPI, pst)
 	)
 
-	driver := conformance.NewDriver(ctx, schema.Selector{}, conformance.DriverOpts{
-		DisableVMFlush: true,
-	})
+	driverOpts, controls, err := opts.executionControls()
+	if err != nil {
+		return err
+	}
+	driver := conformance.NewDriver(ctx, schema.Selector{}, driverOpts)
 
 	// this is the root of the state tree we start with.
 	root := incTs.ParentState()
@@ -237,18 +257,11 @@ func doExtractMessage(opts extractOpts) error {
 		return err
 	}
 
-	var (
-		out = new(bytes.Buffer)
-		gw  = gzip.NewWriter(out)
-	)
-	if err := carWriter(gw); err != nil {
-		return err
-	}
-	if err = gw.Flush(); err != nil {
-		return err
-	}
-	if err = gw.Close(); err != nil {
-		return err
+	var car []byte
+	if !opts.reference {
+		if car, err = compressCAR(carWriter); err != nil {
+			return err
+		}
 	}
 
 	version, err := FullAPI.Version(ctx)
@@ -266,7 +279,7 @@ func doExtractMessage(opts extractOpts) error {
 		return err
 	}
 
-	codename := GetProtocolCodename(execTs.Height())
+	codename := conformance.GetProtocolCodename(execTs.Height())
 
 	// Write out the test vector.
 	vector := schema.TestVector{
@@ -282,13 +295,15 @@ func doExtractMessage(opts extractOpts) error {
 				{Source: fmt.Sprintf("message:%s", msg.Cid().String())},
 				{Source: fmt.Sprintf("inclusion_tipset:%s", incTs.Key().String())},
 				{Source: fmt.Sprintf("execution_tipset:%s", execTs.Key().String())},
+				{Source: fmt.Sprintf("chain_branch:%s", branch)},
+				{Source: chainValidated()},
 				{Source: "github.com/filecoin-project/lotus", Version: version.String()}},
 		},
 		Selector: schema.Selector{
 			schema.SelectorMinProtocolVersion: codename,
 		},
 		Randomness: recordingRand.Recorded(),
-		CAR:        out.Bytes(),
+		CAR:        car,
 		Pre: &schema.Preconditions{
 			Variants: []schema.Variant{
 				{ID: codename, Epoch: int64(execTs.Height()), NetworkVersion: uint(nv)},
@@ -313,16 +328,37 @@ func doExtractMessage(opts extractOpts) error {
 			},
 		},
 	}
-	return writeVector(&vector, opts.file)
+
+	for k, v := range controls {
+		if k == conformance.SelectorCron {
+			continue // cron isn't ticked for message vectors
+		}
+		vector.Selector[k] = v
+	}
+	opts.referenceVector(&vector, string(ntwkName))
+
+	finality, err := checkFinality(ctx, incTs, execTs)
+	if err != nil {
+		return err
+	}
+	vector.Meta.Gen = append(vector.Meta.Gen, finality)
+	vector.Meta.Gen = append(vector.Meta.Gen, conformance.CaptureEnvironment()...)
+
+	if err := stampVector(&vector, string(ntwkName), msg.Cid().String()); err != nil {
+		return err
+	}
+	return opts.writeVector(&vector)
 }
 
 // resolveFromChain queries the chain for the provided message, using the block CID to
-// speed up the query, if provided
-func resolveFromChain(ctx context.Context, api api.FullNode, mcid cid.Cid, block string) (msg *types.Message, execTs *types.TipSet, incTs *types.TipSet, err error) {
+// speed up the query, if provided. When the block is on a fork, the execution
+// tipset is looked up on the fork heads known to the node. The returned branch
+// is "canonical", or "fork:<head tipset key>".
+func resolveFromChain(ctx context.Context, api api.FullNode, mcid cid.Cid, block string) (msg *types.Message, execTs *types.TipSet, incTs *types.TipSet, branch string, err error) {
 	// Extract the full message.
 	msg, err = api.ChainGetMessage(ctx, mcid)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, "", err
 	}
 
 	log.Printf("found message with CID %s: %+v", mcid, msg)
@@ -333,40 +369,109 @@ func resolveFromChain(ctx context.Context, api api.FullNode, mcid cid.Cid, block
 		// Locate the message.
 		msgInfo, err := api.StateSearchMsg(ctx, mcid)
 		if err != nil {
-			return nil, nil, nil, fmt.Errorf("failed to locate message: %w", err)
+			return nil, nil, nil, "", fmt.Errorf("failed to locate message: %w", err)
+		}
+		if msgInfo == nil {
+			return nil, nil, nil, "", fmt.Errorf("message not found on the canonical chain; if it was included in a fork, supply the inclusion block")
 		}
 
 		log.Printf("located message at tipset %s (height: %d) with exit code: %s", msgInfo.TipSet, msgInfo.Height, msgInfo.Receipt.ExitCode)
 
 		execTs, incTs, err = fetchThisAndPrevTipset(ctx, api, msgInfo.TipSet)
-		return msg, execTs, incTs, err
+		return msg, execTs, incTs, branchCanonical, err
 	}
 
 	bcid, err := cid.Decode(block)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, nil, nil, "", err
 	}
 
 	log.Printf("message inclusion block CID was provided; scanning around it: %s", bcid)
 
 	blk, err := api.ChainGetBlock(ctx, bcid)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to get block: %w", err)
+		return nil, nil, nil, "", fmt.Errorf("failed to get block: %w", err)
+	}
+
+	execTs, branch, err = findExecutionTipset(ctx, api, bcid, blk.Height)
+	if err != nil {
+		return nil, nil, nil, "", err
 	}
 
-	// types.EmptyTSK hints to use the HEAD.
-	execTs, err = api.ChainGetTipSetByHeight(ctx, blk.Height+1, types.EmptyTSK)
+	// the inclusion tipset is the parent of the execution tipset, which also
+	// includes sibling blocks of the one provided.
+	incTs, err = api.ChainGetTipSet(ctx, execTs.Parents())
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to get message execution tipset: %w", err)
+		return nil, nil, nil, "", fmt.Errorf("failed to get message inclusion tipset: %w", err)
 	}
 
-	// walk back from the execTs instead of HEAD, to save time.
-	incTs, err = api.ChainGetTipSetByHeight(ctx, blk.Height, execTs.Key())
+	return msg, execTs, incTs, branch, nil
+}
+
+const branchCanonical = "canonical"
+
+// findExecutionTipset finds the first tipset built on top of the block,
+// searching back from the chain head, and then from the heads of forks the
+// node is syncing (or has tried to sync).
+func findExecutionTipset(ctx context.Context, api api.FullNode, bcid cid.Cid, height abi.ChainEpoch) (*types.TipSet, string, error) {
+	head, err := api.ChainHead(ctx)
 	if err != nil {
-		return nil, nil, nil, fmt.Errorf("failed to get message inclusion tipset: %w", err)
+		return nil, "", fmt.Errorf("failed to get chain head: %w", err)
+	}
+	heads := []*types.TipSet{head}
+
+	ss, err := api.SyncState(ctx)
+	if err != nil {
+		log.Println(color.YellowString("failed to get sync state, only searching the canonical chain: %s", err))
+	} else {
+		for _, as := range ss.ActiveSyncs {
+			if as.Target != nil && as.Target.Key() != head.Key() {
+				heads = append(heads, as.Target)
+			}
+		}
+	}
+
+	for i, h := range heads {
+		child, err := findChild(ctx, api, h, bcid, height)
+		if err != nil {
+			return nil, "", err
+		}
+		if child == nil {
+			continue
+		}
+
+		if i == 0 {
+			return child, branchCanonical, nil
+		}
+
+		log.Println(color.YellowString("block %s is on a fork with head %s", bcid, h.Key()))
+		return child, "fork:" + h.Key().String(), nil
 	}
 
-	return msg, execTs, incTs, nil
+	return nil, "", fmt.Errorf("no tipset known to the node builds on block %s (height %d); it may be on a fork the node hasn't seen, or the message wasn't executed yet", bcid, height)
+}
+
+// findChild walks back from head, returning the first tipset above the given
+// height, if it builds on top of the block.
+func findChild(ctx context.Context, api api.FullNode, head *types.TipSet, bcid cid.Cid, height abi.ChainEpoch) (*types.TipSet, error) {
+	// tipsets may be null, so look for the first non-null one above the block.
+	for h := height + 1; h <= head.Height(); h++ {
+		ts, err := api.ChainGetTipSetByHeight(ctx, h, head.Key())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get tipset at height %d: %w", h, err)
+		}
+		if ts.Height() <= height {
+			continue // null round
+		}
+
+		for _, p := range ts.Parents().Cids() {
+			if p == bcid {
+				return ts, nil
+			}
+		}
+		return nil, nil
+	}
+	return nil, nil
 }
 
 // fetchThisAndPrevTipset returns the full tipset identified by the key, as well
@@ -1,13 +1,13 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 
+	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/test-vectors/schema"
 	"github.com/ipfs/go-cid"
 
@@ -28,26 +28,49 @@ func doExtractTipset(opts extractOpts) error {
 	}
 
 	ss := strings.Split(opts.tsk, "..")
+	if len(ss) > 2 {
+		return fmt.Errorf("unrecognized tipset format")
+	}
+
+	return withReorgRetries(opts, func(final bool) error {
+		return extractTipsetOnce(ctx, opts, ss, final)
+	})
+}
+
+// resolveTipsetRef resolves a tipset reference; with final set, it's
+// resolved to the canonical tipset at its height, as of finality
+func resolveTipsetRef(ctx context.Context, ref string, final bool) (*types.TipSet, error) {
+	ts, err := lcli.ParseTipSetRef(ctx, FullAPI, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch tipset %s: %w", ref, err)
+	}
+	if !final {
+		return ts, nil
+	}
+	return canonicalFinal(ctx, ts.Height())
+}
+
+func extractTipsetOnce(ctx context.Context, opts extractOpts, ss []string, final bool) error {
 	switch len(ss) {
 	case 1: // extracting a single tipset.
-		ts, err := lcli.ParseTipSetRef(ctx, FullAPI, opts.tsk)
+		ts, err := resolveTipsetRef(ctx, ss[0], final)
 		if err != nil {
-			return fmt.Errorf("failed to fetch tipset: %w", err)
+			return err
 		}
-		v, err := extractTipsets(ctx, ts)
+		v, err := extractTipsets(ctx, opts, ts)
 		if err != nil {
 			return err
 		}
 		return writeVector(v, opts.file)
 
 	case 2: // extracting a range of tipsets.
-		left, err := lcli.ParseTipSetRef(ctx, FullAPI, ss[0])
+		left, err := resolveTipsetRef(ctx, ss[0], final)
 		if err != nil {
-			return fmt.Errorf("failed to fetch tipset %s: %w", ss[0], err)
+			return err
 		}
-		right, err := lcli.ParseTipSetRef(ctx, FullAPI, ss[1])
+		right, err := resolveTipsetRef(ctx, ss[1], final)
 		if err != nil {
-			return fmt.Errorf("failed to fetch tipset %s: %w", ss[1], err)
+			return err
 		}
 
 		// resolve the tipset range.
@@ -58,7 +81,7 @@ func doExtractTipset(opts extractOpts) error {
 
 		// are are squashing all tipsets into a single multi-tipset vector?
 		if opts.squash {
-			vector, err := extractTipsets(ctx, tss...)
+			vector, err := extractTipsets(ctx, opts, tss...)
 			if err != nil {
 				return err
 			}
@@ -66,7 +89,7 @@ func doExtractTipset(opts extractOpts) error {
 		}
 
 		// we are generating a single-tipset vector per tipset.
-		vectors, err := extractIndividualTipsets(ctx, tss...)
+		vectors, err := extractIndividualTipsets(ctx, opts, tss...)
 		if err != nil {
 			return err
 		}
@@ -93,9 +116,9 @@ func resolveTipsetRange(ctx context.Context, left *types.TipSet, right *types.Ti
 	return tss, nil
 }
 
-func extractIndividualTipsets(ctx context.Context, tss ...*types.TipSet) (vectors []*schema.TestVector, err error) {
+func extractIndividualTipsets(ctx context.Context, opts extractOpts, tss ...*types.TipSet) (vectors []*schema.TestVector, err error) {
 	for _, ts := range tss {
-		v, err := extractTipsets(ctx, ts)
+		v, err := extractTipsets(ctx, opts, ts)
 		if err != nil {
 			return nil, err
 		}
@@ -104,7 +127,7 @@ func extractIndividualTipsets(ctx context.Context, tss ...*types.TipSet) (vector
 	return vectors, nil
 }
 
-func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVector, error) {
+func extractTipsets(ctx context.Context, opts extractOpts, tss ...*types.TipSet) (*schema.TestVector, error) {
 	var (
 		// create a read-through store that uses ChainGetObject to fetch unknown CIDs.
 		pst = NewProxyingStores(ctx, FullAPI)
@@ -119,18 +142,19 @@ func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVect
 		return nil, fmt.Errorf("requested 'accessed-cids' state retention, but no tracing blockstore was present")
 	}
 
-	driver := conformance.NewDriver(ctx, schema.Selector{}, conformance.DriverOpts{
-		DisableVMFlush: true,
-	})
+	driverOpts, controls, err := opts.executionControls()
+	if err != nil {
+		return nil, err
+	}
+	driver := conformance.NewDriver(ctx, schema.Selector{}, driverOpts)
 
 	base := tss[0]
-	last := tss[len(tss)-1]
 
 	// this is the root of the state tree we start with.
 	root := base.ParentState()
 	log.Printf("base state tree root CID: %s", root)
 
-	codename := GetProtocolCodename(base.Height())
+	codename := conformance.GetProtocolCodename(base.Height())
 	nv, err := FullAPI.StateNetworkVersion(ctx, base.Key())
 	if err != nil {
 		return nil, err
@@ -149,9 +173,9 @@ func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVect
 	vector := schema.TestVector{
 		Class: schema.ClassTipset,
 		Meta: &schema.Metadata{
-			ID: fmt.Sprintf("@%d..@%d", base.Height(), last.Height()),
 			Gen: []schema.GenerationData{
 				{Source: fmt.Sprintf("network:%s", ntwkName)},
+				{Source: chainValidated()},
 				{Source: "github.com/filecoin-project/lotus", Version: version.String()}},
 			// will be completed by extra tipset stamps.
 		},
@@ -170,6 +194,9 @@ func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVect
 			StateTree: new(schema.StateTree),
 		},
 	}
+	for k, v := range controls {
+		vector.Selector[k] = v
+	}
 
 	tbs.StartTracing()
 
@@ -177,50 +204,18 @@ func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVect
 	for i, ts := range tss {
 		log.Printf("tipset %s block count: %d", ts.Key(), len(ts.Blocks()))
 
-		var blocks []schema.Block
-		for _, b := range ts.Blocks() {
-			msgs, err := FullAPI.ChainGetBlockMessages(ctx, b.Cid())
-			if err != nil {
-				return nil, fmt.Errorf("failed to get block messages (cid: %s): %w", b.Cid(), err)
-			}
-
-			log.Printf("block %s has %d messages", b.Cid(), len(msgs.Cids))
-
-			packed := make([]schema.Base64EncodedBytes, 0, len(msgs.Cids))
-			for _, m := range msgs.BlsMessages {
-				b, err := m.Serialize()
-				if err != nil {
-					return nil, fmt.Errorf("failed to serialize message: %w", err)
-				}
-				packed = append(packed, b)
-			}
-			for _, m := range msgs.SecpkMessages {
-				b, err := m.Message.Serialize()
-				if err != nil {
-					return nil, fmt.Errorf("failed to serialize message: %w", err)
-				}
-				packed = append(packed, b)
-			}
-			blocks = append(blocks, schema.Block{
-				MinerAddr: b.Miner,
-				WinCount:  b.ElectionProof.WinCount,
-				Messages:  packed,
-			})
-		}
-
 		basefee := base.Blocks()[0].ParentBaseFee
 		log.Printf("tipset basefee: %s", basefee)
 
-		tipset := schema.Tipset{
-			BaseFee:     *basefee.Int,
-			Blocks:      blocks,
-			EpochOffset: int64(i),
+		tipset, err := schemaTipset(ctx, ts, basefee, int64(i))
+		if err != nil {
+			return nil, err
 		}
 
 		params := conformance.ExecuteTipsetParams{
 			Preroot:     roots[len(roots)-1],
 			ParentEpoch: ts.Height() - 1,
-			Tipset:      &tipset,
+			Tipset:      tipset,
 			ExecEpoch:   ts.Height(),
 			Rand:        recordingRand,
 		}
@@ -233,7 +228,7 @@ func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVect
 		roots = append(roots, result.PostStateRoot)
 
 		// update the vector.
-		vector.ApplyTipsets = append(vector.ApplyTipsets, tipset)
+		vector.ApplyTipsets = append(vector.ApplyTipsets, *tipset)
 		vector.Post.ReceiptsRoots = append(vector.Post.ReceiptsRoots, result.ReceiptsRoot)
 
 		for _, res := range result.AppliedResults {
@@ -244,9 +239,16 @@ func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVect
 			})
 		}
 
+		// map receipts to the block messages they're for, as duplicate
+		// messages are only applied once, and implicit messages are added.
+		origins, err := conformance.ReceiptOrigins(tipset, result.AppliedMessages)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map receipts to messages: %w", err)
+		}
+
 		vector.Meta.Gen = append(vector.Meta.Gen, schema.GenerationData{
 			Source: "tipset:" + ts.Key().String(),
-		})
+		}, conformance.EncodeReceiptOrigins(i, origins))
 	}
 
 	accessed := tbs.FinishTracing()
@@ -254,24 +256,78 @@ func extractTipsets(ctx context.Context, tss ...*types.TipSet) (*schema.TestVect
 	//
 	// ComputeBaseFee(ctx, baseTs)
 
-	// write a CAR with the accessed state into a buffer.
-	var (
-		out = new(bytes.Buffer)
-		gw  = gzip.NewWriter(out)
-	)
-	if err := g.WriteCARIncluding(gw, accessed, roots...); err != nil {
-		return nil, err
+	// write a CAR with the accessed state into a buffer, unless this is a
+	// reference vector.
+	var car []byte
+	if !opts.reference {
+		car, err = compressCAR(func(w io.Writer) error {
+			return g.WriteCARIncluding(w, accessed, roots...)
+		})
+		if err != nil {
+			return nil, err
+		}
 	}
-	if err = gw.Flush(); err != nil {
+
+	vector.Randomness = recordingRand.Recorded()
+	vector.Post.StateTree.RootCID = roots[len(roots)-1]
+	vector.CAR = car
+	opts.referenceVector(&vector, string(ntwkName))
+
+	finality, err := checkFinality(ctx, tss...)
+	if err != nil {
 		return nil, err
 	}
-	if err = gw.Close(); err != nil {
+	vector.Meta.Gen = append(vector.Meta.Gen, finality)
+	vector.Meta.Gen = append(vector.Meta.Gen, conformance.CaptureEnvironment()...)
+
+	var subjects []string
+	for _, ts := range tss {
+		subjects = append(subjects, ts.Key().String())
+	}
+	if err := stampVector(&vector, string(ntwkName), subjects...); err != nil {
 		return nil, err
 	}
 
-	vector.Randomness = recordingRand.Recorded()
-	vector.Post.StateTree.RootCID = roots[len(roots)-1]
-	vector.CAR = out.Bytes()
-
 	return &vector, nil
 }
+
+// schemaTipset packs the blocks of a tipset, with their messages, into a
+// schema.Tipset executing with the supplied basefee.
+func schemaTipset(ctx context.Context, ts *types.TipSet, basefee abi.TokenAmount, epochOffset int64) (*schema.Tipset, error) {
+	var blocks []schema.Block
+	for _, b := range ts.Blocks() {
+		msgs, err := FullAPI.ChainGetBlockMessages(ctx, b.Cid())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get block messages (cid: %s): %w", b.Cid(), err)
+		}
+
+		log.Printf("block %s has %d messages", b.Cid(), len(msgs.Cids))
+
+		packed := make([]schema.Base64EncodedBytes, 0, len(msgs.Cids))
+		for _, m := range msgs.BlsMessages {
+			b, err := m.Serialize()
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize message: %w", err)
+			}
+			packed = append(packed, b)
+		}
+		for _, m := range msgs.SecpkMessages {
+			b, err := m.Message.Serialize()
+			if err != nil {
+				return nil, fmt.Errorf("failed to serialize message: %w", err)
+			}
+			packed = append(packed, b)
+		}
+		blocks = append(blocks, schema.Block{
+			MinerAddr: b.Miner,
+			WinCount:  b.ElectionProof.WinCount,
+			Messages:  packed,
+		})
+	}
+
+	return &schema.Tipset{
+		BaseFee:     *basefee.Int,
+		Blocks:      blocks,
+		EpochOffset: epochOffset,
+	}, nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/conformance"
+)
+
+var fuzzFlags struct {
+	seeds        string
+	iterations   int
+	maxMutations int
+	randSeed     int64
+	crashers     string
+}
+
+var fuzzCmd = &cli.Command{
+	Name: "fuzz",
+	Description: `fuzz the VM with mutations of message test vectors.
+
+   Every iteration overwrites random bytes of the messages and the state CAR of
+   a seed vector, and executes its first variant. Mutated vectors are expected
+   to fail, only panics are reported: the mutated vector is written to the
+   crashers directory with the panic next to it, and can be replayed with
+   tvx exec. Exits with a non-zero status if any iteration panicked.
+
+   The same mutations are available to go-fuzz through conformance.Fuzz (build
+   tag gofuzz), with the seed vectors taken from TVX_FUZZ_SEEDS.`,
+	Action: runFuzz,
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:        "seeds",
+			Usage:       "seed vector file or directory; only message vectors embedding their state are used",
+			Required:    true,
+			TakesFile:   true,
+			Destination: &fuzzFlags.seeds,
+		},
+		&cli.IntFlag{
+			Name:        "iterations",
+			Usage:       "number of mutated vectors to execute",
+			Value:       1000,
+			Destination: &fuzzFlags.iterations,
+		},
+		&cli.IntFlag{
+			Name:        "max-mutations",
+			Usage:       "maximum number of bytes overwritten in each iteration",
+			Value:       8,
+			Destination: &fuzzFlags.maxMutations,
+		},
+		&cli.Int64Flag{
+			Name:        "rand-seed",
+			Usage:       "seed of the mutations, to reproduce a run; random if not set",
+			Destination: &fuzzFlags.randSeed,
+		},
+		&cli.StringFlag{
+			Name:        "crashers",
+			Usage:       "directory where to write vectors that made the VM panic",
+			Value:       "crashers",
+			Destination: &fuzzFlags.crashers,
+		},
+	},
+}
+
+func runFuzz(_ *cli.Context) error {
+	seeds, err := loadFuzzSeeds(fuzzFlags.seeds)
+	if err != nil {
+		return err
+	}
+	if fuzzFlags.maxMutations < 1 {
+		return fmt.Errorf("--max-mutations must be at least 1")
+	}
+
+	randSeed := fuzzFlags.randSeed
+	if randSeed == 0 {
+		randSeed = time.Now().UnixNano()
+	}
+	log.Printf("fuzzing %d seed vectors, rand seed %d", len(seeds), randSeed)
+	rnd := rand.New(rand.NewSource(randSeed))
+
+	var executed, crashers int
+	for i := 0; i < fuzzFlags.iterations; i++ {
+		seed := seeds[rnd.Intn(len(seeds))]
+
+		data := make([]byte, conformance.FuzzMutationLen*(1+rnd.Intn(fuzzFlags.maxMutations)))
+		_, _ = rnd.Read(data)
+
+		vector, err := conformance.MutateVector(seed, data)
+		if err != nil {
+			return fmt.Errorf("failed to mutate vector %s: %w", seed.Meta.ID, err)
+		}
+
+		ok, fp := conformance.FuzzMessageVector(vector)
+		if ok {
+			executed++
+		}
+		if fp == nil {
+			continue
+		}
+
+		crashers++
+		path, err := writeCrasher(vector, i, fp)
+		if err != nil {
+			return err
+		}
+		log.Println(color.HiRedString("💥 VM panicked on a mutation of %s: %v; written to %s", seed.Meta.ID, fp.Value, path))
+	}
+
+	log.Printf("%d iterations, %d executed fully, %d crashers", fuzzFlags.iterations, executed, crashers)
+	if crashers > 0 {
+		return fmt.Errorf("the VM panicked on %d mutated vectors", crashers)
+	}
+	return nil
+}
+
+func loadFuzzSeeds(path string) ([]*schema.TestVector, error) {
+	files, err := vectorFiles(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []*schema.TestVector
+	for _, f := range files {
+		tv, err := loadVector(f)
+		if err != nil {
+			log.Printf("skipping %s: %s", f, err)
+			continue
+		}
+		if tv.Class != schema.ClassMessage || len(tv.CAR) == 0 || len(tv.Pre.Variants) == 0 {
+			continue
+		}
+		if reason := conformance.SkipReason(tv); reason != "" {
+			continue
+		}
+		seeds = append(seeds, tv)
+	}
+
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no message vectors with embedded state in %s", path)
+	}
+	return seeds, nil
+}
+
+func writeCrasher(vector *schema.TestVector, iteration int, fp *conformance.FuzzPanic) (string, error) {
+	if err := ensureDir(fuzzFlags.crashers); err != nil {
+		return "", err
+	}
+
+	mutated := *vector
+	meta := *vector.Meta
+	meta.ID = fmt.Sprintf("%s-fuzz-%d", vector.Meta.ID, iteration)
+	mutated.Meta = &meta
+
+	out, err := json.MarshalIndent(&mutated, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal crasher: %w", err)
+	}
+
+	path := filepath.Join(fuzzFlags.crashers, meta.ID+".json")
+	if err := ioutil.WriteFile(path, out, 0644); err != nil {
+		return "", fmt.Errorf("failed to write crasher: %w", err)
+	}
+	if err := ioutil.WriteFile(path[:len(path)-len(".json")]+".panic", []byte(fp.Error()), 0644); err != nil {
+		return "", fmt.Errorf("failed to write crasher: %w", err)
+	}
+	return path, nil
+}
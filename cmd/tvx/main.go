@@ -13,7 +13,8 @@ import (
 	lcli "github.com/filecoin-project/lotus/cli"
 )
 
-// FullAPI is a JSON-RPC client targeting a full node. It's initialized in a
+// FullAPI is a JSON-RPC client targeting a full node, or an offline node
+// backed by a chain snapshot when --from-car is set. It's initialized in a
 // cli.BeforeFunc.
 var FullAPI api.FullNode
 
@@ -36,7 +37,7 @@ var repoFlag = cli.StringFlag{
 func main() {
 	app := &cli.App{
 		Name: "tvx",
-		Description: `tvx is a tool for extracting and executing test vectors. It has four subcommands.
+		Description: `tvx is a tool for extracting and executing test vectors. It has eight subcommands.
 
    tvx extract extracts a test vector from a live network. It requires access to
    a Filecoin client that exposes the standard JSON-RPC API endpoint. Only
@@ -52,6 +53,19 @@ func main() {
    epoch, reporting the result on stderr and writing a test vector on stdout
    or into the specified file.
 
+   tvx dedupe removes duplicate test vectors from a corpus directory, based on
+   the content hash of the vectors.
+
+   tvx corpus-diff compares two corpus snapshots, listing added, removed and
+   changed vectors, with the semantic differences of the changed ones.
+
+   tvx fuzz executes mutations of message vectors, reporting the ones making
+   the VM panic.
+
+   tvx check-live re-executes a range of tipsets against the state of the
+   connected node, comparing the results with the on-chain receipts, without
+   writing vectors.
+
    SETTING THE JSON-RPC API ENDPOINT
 
    You can set the JSON-RPC API endpoint through one of the following methods.
@@ -75,6 +89,10 @@ func main() {
 			execCmd,
 			extractManyCmd,
 			simulateCmd,
+			dedupeCmd,
+			corpusDiffCmd,
+			fuzzCmd,
+			checkLiveCmd,
 		},
 	}
 
@@ -99,6 +117,17 @@ func initialize(c *cli.Context) error {
 	// to the blockstore) worked.
 	_ = os.Setenv("LOTUS_DISABLE_VM_BUF", "iknowitsabadidea")
 
+	if err := checkCARFlags(); err != nil {
+		return err
+	}
+
+	// Load the chain snapshot instead, if we're running offline.
+	if path := c.String(fromCarFlag.Name); path != "" {
+		var err error
+		FullAPI, err = NewOfflineAPI(path)
+		return err
+	}
+
 	// Make the API client.
 	var err error
 	if FullAPI, Closer, err = lcli.GetFullNodeAPI(c); err != nil {
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
+	"github.com/urfave/cli/v2"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/network"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+	"github.com/filecoin-project/lotus/chain/store"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/lib/blockstore"
+	"github.com/filecoin-project/lotus/node/impl/full"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
+)
+
+var fromCarFlag = cli.StringFlag{
+	Name: "from-car",
+	Usage: "extract from a chain snapshot CAR (as produced by `lotus chain export`) " +
+		"instead of a live node; the snapshot is loaded in memory, and its tipset is used as chain head",
+	TakesFile: true,
+}
+
+// chainValidated returns the generation data recording whether the chain the
+// vector was extracted from was validated, which isn't known for snapshots
+func chainValidated() string {
+	_, offline := FullAPI.(*offlineNode)
+	return fmt.Sprintf("chain_validated:%t", !offline)
+}
+
+// offlineNode serves the subset of the full node API used by tvx extraction
+// from a chain store loaded from a CAR snapshot. Calling any other method
+// panics.
+type offlineNode struct {
+	api.FullNode
+
+	chain *full.ChainAPI
+	state *full.StateAPI
+}
+
+// NewOfflineAPI loads the chain snapshot at path into an in-memory chain
+// store, and returns a full node API backed by it.
+func NewOfflineAPI(path string) (api.FullNode, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	var (
+		bs = blockstore.NewTemporarySync()
+		cs = store.NewChainStore(bs, bs, datastore.NewMapDatastore(), vm.Syscalls(ffiwrapper.ProofVerifier), nil)
+	)
+
+	log.Printf("loading chain snapshot from %s", path)
+	ts, err := cs.Import(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to import snapshot: %w", err)
+	}
+	if err := cs.SetHead(ts); err != nil {
+		return nil, fmt.Errorf("failed to set snapshot head: %w", err)
+	}
+	log.Printf("loaded chain snapshot; head at height %d: %s", ts.Height(), ts.Key())
+
+	sm := stmgr.NewStateManager(cs)
+
+	return &offlineNode{
+		chain: &full.ChainAPI{
+			ChainModuleAPI: &full.ChainModule{Chain: cs},
+			Chain:          cs,
+		},
+		state: &full.StateAPI{
+			StateModuleAPI: &full.StateModule{StateManager: sm, Chain: cs},
+			ProofVerifier:  ffiwrapper.ProofVerifier,
+			StateManager:   sm,
+			Chain:          cs,
+		},
+	}, nil
+}
+
+func (o *offlineNode) Version(context.Context) (api.Version, error) {
+	return api.Version{
+		Version:    build.UserVersion(),
+		APIVersion: build.FullAPIVersion,
+		BlockDelay: build.BlockDelaySecs,
+	}, nil
+}
+
+// SyncState reports no syncs; a snapshot only holds the chain it was taken of.
+func (o *offlineNode) SyncState(context.Context) (*api.SyncState, error) {
+	return &api.SyncState{}, nil
+}
+
+func (o *offlineNode) ChainHead(ctx context.Context) (*types.TipSet, error) {
+	return o.chain.ChainHead(ctx)
+}
+
+func (o *offlineNode) ChainGetRandomnessFromTickets(ctx context.Context, tsk types.TipSetKey, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error) {
+	return o.chain.ChainGetRandomnessFromTickets(ctx, tsk, personalization, randEpoch, entropy)
+}
+
+func (o *offlineNode) ChainGetRandomnessFromBeacon(ctx context.Context, tsk types.TipSetKey, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error) {
+	return o.chain.ChainGetRandomnessFromBeacon(ctx, tsk, personalization, randEpoch, entropy)
+}
+
+func (o *offlineNode) ChainGetBlock(ctx context.Context, c cid.Cid) (*types.BlockHeader, error) {
+	return o.chain.ChainGetBlock(ctx, c)
+}
+
+func (o *offlineNode) ChainGetTipSet(ctx context.Context, tsk types.TipSetKey) (*types.TipSet, error) {
+	return o.chain.ChainGetTipSet(ctx, tsk)
+}
+
+func (o *offlineNode) ChainGetBlockMessages(ctx context.Context, c cid.Cid) (*api.BlockMessages, error) {
+	return o.chain.ChainGetBlockMessages(ctx, c)
+}
+
+func (o *offlineNode) ChainGetParentMessages(ctx context.Context, c cid.Cid) ([]api.Message, error) {
+	return o.chain.ChainGetParentMessages(ctx, c)
+}
+
+func (o *offlineNode) ChainGetTipSetByHeight(ctx context.Context, h abi.ChainEpoch, tsk types.TipSetKey) (*types.TipSet, error) {
+	return o.chain.ChainGetTipSetByHeight(ctx, h, tsk)
+}
+
+func (o *offlineNode) ChainReadObj(ctx context.Context, c cid.Cid) ([]byte, error) {
+	return o.chain.ChainReadObj(ctx, c)
+}
+
+func (o *offlineNode) ChainGetMessage(ctx context.Context, c cid.Cid) (*types.Message, error) {
+	return o.chain.ChainGetMessage(ctx, c)
+}
+
+func (o *offlineNode) StateCall(ctx context.Context, msg *types.Message, tsk types.TipSetKey) (*api.InvocResult, error) {
+	return o.state.StateCall(ctx, msg, tsk)
+}
+
+func (o *offlineNode) StateNetworkName(ctx context.Context) (dtypes.NetworkName, error) {
+	return o.state.StateNetworkName(ctx)
+}
+
+func (o *offlineNode) StateSearchMsg(ctx context.Context, c cid.Cid) (*api.MsgLookup, error) {
+	return o.state.StateSearchMsg(ctx, c)
+}
+
+func (o *offlineNode) StateGetReceipt(ctx context.Context, c cid.Cid, tsk types.TipSetKey) (*types.MessageReceipt, error) {
+	return o.state.StateGetReceipt(ctx, c, tsk)
+}
+
+func (o *offlineNode) StateVMCirculatingSupplyInternal(ctx context.Context, tsk types.TipSetKey) (api.CirculatingSupply, error) {
+	return o.state.StateVMCirculatingSupplyInternal(ctx, tsk)
+}
+
+func (o *offlineNode) StateNetworkVersion(ctx context.Context, tsk types.TipSetKey) (network.Version, error) {
+	return o.state.StateNetworkVersion(ctx, tsk)
+}
+
+func (o *offlineNode) StateDecodeMessages(ctx context.Context, msgs []api.DecodeMessage, tsk types.TipSetKey) ([]api.DecodedMessage, error) {
+	return o.state.StateDecodeMessages(ctx, msgs, tsk)
+}
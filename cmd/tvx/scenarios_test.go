@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	logging "github.com/ipfs/go-log/v2"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/test"
+	builder "github.com/filecoin-project/lotus/node/test"
+)
+
+// scenarioVectorsEnv names the directory to keep scenario vectors in.
+const scenarioVectorsEnv = "TVX_SCENARIO_VECTORS"
+
+// scenarios are the integration test scenarios to extract vectors from.
+var scenarios = map[string]func(t *testing.T, b test.APIBuilder){
+	"paych": func(t *testing.T, b test.APIBuilder) {
+		test.TestPaymentChannels(t, b, 5*time.Millisecond)
+	},
+	"deals": func(t *testing.T, b test.APIBuilder) {
+		test.TestDealFlow(t, b, 10*time.Millisecond, false, false, abi.ChainEpoch(2<<12))
+	},
+}
+
+// TestExtractScenarios runs integration test scenarios on a mock devnet, and
+// extracts a message vector for every message they land on chain, to seed the
+// corpus with well-understood scenarios. Vectors are written to a
+// subdirectory per scenario of TVX_SCENARIO_VECTORS; when it isn't set, they
+// are only checked, and discarded.
+func TestExtractScenarios(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping test in short mode")
+	}
+
+	out := os.Getenv(scenarioVectorsEnv)
+	if out == "" {
+		tmp, err := ioutil.TempDir("", "tvx-scenarios")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer os.RemoveAll(tmp) // nolint
+		out = tmp
+	}
+
+	for _, l := range []string{"miner", "chainstore", "chain", "sub", "pubsub", "storageminer"} {
+		_ = logging.SetLogLevel(l, "ERROR")
+	}
+
+	for name, run := range scenarios {
+		name, run := name, run
+		t.Run(name, func(t *testing.T) {
+			// the nodes are shut down when the subtest ends, so extract before
+			// returning from it.
+			var full api.FullNode
+			b := func(t *testing.T, fullOpts []test.FullNodeOpts, storage []test.StorageMiner) ([]test.TestNode, []test.TestStorageNode) {
+				n, sn := builder.MockSbBuilder(t, fullOpts, storage)
+				full = n[0]
+				return n, sn
+			}
+
+			run(t, b)
+			if t.Failed() {
+				return
+			}
+
+			extractScenario(t, name, full, filepath.Join(out, name))
+		})
+	}
+}
+
+func extractScenario(t *testing.T, name string, node api.FullNode, dir string) {
+	ctx := context.Background()
+
+	FullAPI = node
+	defer func() {
+		FullAPI = nil
+	}()
+
+	msgs, err := executedMessages(ctx, node)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) == 0 {
+		t.Fatalf("scenario %s landed no messages on chain", name)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, m := range msgs {
+		id := fmt.Sprintf("scenario-%s-%03d", name, i)
+		err := doExtractMessage(extractOpts{
+			id:           id,
+			block:        m.block.String(),
+			cid:          m.cid.String(),
+			file:         filepath.Join(dir, id+".json"),
+			retain:       "accessed-cids",
+			precursor:    PrecursorSelectSender,
+			reorgRetries: DefaultReorgRetries,
+		})
+		if err != nil {
+			t.Errorf("extracting message %s: %s", m.cid, err)
+			continue
+		}
+
+		tv, err := loadVector(filepath.Join(dir, id+".json"))
+		if err != nil {
+			t.Errorf("loading vector of message %s: %s", m.cid, err)
+			continue
+		}
+		if tv.Class != schema.ClassMessage || len(tv.ApplyMessages) == 0 {
+			t.Errorf("vector of message %s doesn't apply any message", m.cid)
+		}
+	}
+	t.Logf("extracted %d vectors from scenario %s into %s", len(msgs), name, dir)
+}
+
+type includedMsg struct {
+	cid   cid.Cid
+	block cid.Cid
+}
+
+// executedMessages lists the messages included on the chain, oldest first,
+// leaving out the ones in the head tipset, which haven't been executed yet.
+func executedMessages(ctx context.Context, node api.FullNode) ([]includedMsg, error) {
+	head, err := node.ChainHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		out  []includedMsg
+		seen = map[cid.Cid]struct{}{}
+	)
+	for ts := head; ts.Height() > 0; {
+		ts, err = node.ChainGetTipSet(ctx, ts.Parents())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get parent tipset: %w", err)
+		}
+
+		// walking backwards, so prepend each tipset's messages.
+		var tsMsgs []includedMsg
+		for _, b := range ts.Blocks() {
+			bm, err := node.ChainGetBlockMessages(ctx, b.Cid())
+			if err != nil {
+				return nil, fmt.Errorf("failed to get messages of block %s: %w", b.Cid(), err)
+			}
+			for _, c := range bm.Cids {
+				if _, ok := seen[c]; ok {
+					continue
+				}
+				seen[c] = struct{}{}
+				tsMsgs = append(tsMsgs, includedMsg{cid: c, block: b.Cid()})
+			}
+		}
+		out = append(tsMsgs, out...)
+	}
+
+	return out, nil
+}
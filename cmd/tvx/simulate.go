@@ -1,12 +1,11 @@
 package main
 
 import (
-	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os/exec"
 
@@ -37,6 +36,8 @@ var simulateCmd = &cli.Command{
 	After:  destroy,
 	Flags: []cli.Flag{
 		&repoFlag,
+		&carCodecFlag,
+		&carLevelFlag,
 		&cli.StringFlag{
 			Name:        "msg",
 			Usage:       "base64 cbor-encoded message",
@@ -136,18 +137,27 @@ func runSimulateCmd(_ *cli.Context) error {
 
 	accessed := tbs.FinishTracing()
 
-	var (
-		out = new(bytes.Buffer)
-		gw  = gzip.NewWriter(out)
-		g   = NewSurgeon(ctx, FullAPI, stores)
-	)
-	if err := g.WriteCARIncluding(gw, accessed, preroot, postroot); err != nil {
-		return err
-	}
-	if err = gw.Flush(); err != nil {
-		return err
+	decoded, err := FullAPI.StateDecodeMessages(ctx, []api.DecodeMessage{{
+		To:     msg.To,
+		Method: msg.Method,
+		Params: msg.Params,
+		Return: applyret.Return,
+	}}, ts.Key())
+	if err != nil {
+		log.Printf("failed to decode message: %s", err)
+	} else if d := decoded[0]; d.Error != "" {
+		log.Printf("failed to decode message: %s", d.Error)
+	} else {
+		paramsjson, _ := json.Marshal(d.Params)
+		retjson, _ := json.Marshal(d.Return)
+		log.Printf("applied %s.%s with params %s: exit code %d, return %s", d.Actor, d.Method, paramsjson, applyret.ExitCode, retjson)
 	}
-	if err = gw.Close(); err != nil {
+
+	g := NewSurgeon(ctx, FullAPI, stores)
+	car, err := compressCAR(func(w io.Writer) error {
+		return g.WriteCARIncluding(w, accessed, preroot, postroot)
+	})
+	if err != nil {
 		return err
 	}
 
@@ -162,7 +172,7 @@ func runSimulateCmd(_ *cli.Context) error {
 		return err
 	}
 
-	codename := GetProtocolCodename(epoch)
+	codename := conformance.GetProtocolCodename(epoch)
 
 	// Write out the test vector.
 	vector := schema.TestVector{
@@ -170,13 +180,14 @@ func runSimulateCmd(_ *cli.Context) error {
 		Meta: &schema.Metadata{
 			ID: fmt.Sprintf("simulated-%s", msg.Cid()),
 			Gen: []schema.GenerationData{
+				{Source: "chain_validated:false"},
 				{Source: "github.com/filecoin-project/lotus", Version: version.String()}},
 		},
 		Selector: schema.Selector{
 			schema.SelectorMinProtocolVersion: codename,
 		},
 		Randomness: rand.Recorded(),
-		CAR:        out.Bytes(),
+		CAR:        car,
 		Pre: &schema.Preconditions{
 			Variants: []schema.Variant{
 				{ID: codename, Epoch: int64(epoch), NetworkVersion: uint(nv)},
@@ -202,6 +213,8 @@ func runSimulateCmd(_ *cli.Context) error {
 		},
 	}
 
+	vector.Meta.Gen = append(vector.Meta.Gen, conformance.CaptureEnvironment()...)
+
 	if err := writeVector(&vector, simulateFlags.out); err != nil {
 		return fmt.Errorf("failed to write vector: %w", err)
 	}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+// sinkUsage describes the output targets accepted by openSink, for flag usage.
+const sinkUsage = "a directory, a tar archive (.tar, .tar.gz, .tgz), or an S3-compatible bucket (s3://bucket/prefix)"
+
+// vectorSink stores the vectors of a batch. Vectors are stored under a
+// slash-separated name, relative to the root of the sink.
+type vectorSink interface {
+	Put(name string, vector *schema.TestVector) error
+	Close() error
+}
+
+// openSink opens the sink a batch of vectors is written to:
+//
+//   - s3://bucket/prefix writes each vector as an object to the bucket, using
+//     the credentials in AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and
+//     (optionally) AWS_SESSION_TOKEN, in the region in AWS_REGION. Set
+//     S3_ENDPOINT to use an S3-compatible service other than AWS.
+//   - a path ending in .tar, .tar.gz or .tgz writes all vectors to that archive.
+//   - any other path is a directory, in which each vector is written to a file.
+func openSink(target string) (vectorSink, error) {
+	switch {
+	case strings.HasPrefix(target, "s3://"):
+		return newS3Sink(target)
+	case strings.HasSuffix(target, ".tar"):
+		return newTarSink(target, false)
+	case strings.HasSuffix(target, ".tar.gz"), strings.HasSuffix(target, ".tgz"):
+		return newTarSink(target, true)
+	default:
+		if err := ensureDir(target); err != nil {
+			return nil, err
+		}
+		return dirSink(target), nil
+	}
+}
+
+// encodeVector encodes the vector the same way writeVector does.
+func encodeVector(vector *schema.TestVector) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(vector); err != nil {
+		return nil, fmt.Errorf("failed to encode vector: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// dirSink writes vectors to files under a directory.
+type dirSink string
+
+func (d dirSink) Put(name string, vector *schema.TestVector) error {
+	return writeVector(vector, filepath.Join(string(d), filepath.FromSlash(name)))
+}
+
+func (d dirSink) Close() error {
+	return nil
+}
+
+// tarSink writes vectors to a tar archive, optionally gzipped.
+type tarSink struct {
+	file string
+	f    *os.File
+	gz   *gzip.Writer
+	tw   *tar.Writer
+}
+
+func newTarSink(file string, compress bool) (*tarSink, error) {
+	if err := ensureDir(filepath.Dir(file)); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create archive %s: %w", file, err)
+	}
+
+	s := &tarSink{file: file, f: f}
+	var w io.Writer = f
+	if compress {
+		s.gz = gzip.NewWriter(f)
+		w = s.gz
+	}
+	s.tw = tar.NewWriter(w)
+	return s, nil
+}
+
+func (s *tarSink) Put(name string, vector *schema.TestVector) error {
+	b, err := encodeVector(vector)
+	if err != nil {
+		return err
+	}
+
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0644,
+		Size:    int64(len(b)),
+		ModTime: time.Now(),
+	}
+	if err := s.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	if _, err := s.tw.Write(b); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	// flush the entry, so that an interrupted batch leaves a readable archive
+	// of what was extracted so far.
+	if err := s.tw.Flush(); err != nil {
+		return fmt.Errorf("failed to write %s to archive: %w", name, err)
+	}
+	return nil
+}
+
+func (s *tarSink) Close() error {
+	if err := s.tw.Close(); err != nil {
+		_ = s.f.Close()
+		return fmt.Errorf("failed to finish archive %s: %w", s.file, err)
+	}
+	if s.gz != nil {
+		if err := s.gz.Close(); err != nil {
+			_ = s.f.Close()
+			return fmt.Errorf("failed to finish archive %s: %w", s.file, err)
+		}
+	}
+	return s.f.Close()
+}
+
+// s3Credentials sign requests to S3 with AWS signature version 4.
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string
+	region       string
+}
+
+// s3Sink uploads vectors as objects to an S3-compatible bucket, using
+// path-style requests.
+type s3Sink struct {
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	creds    s3Credentials
+	client   *http.Client
+}
+
+func newS3Sink(target string) (*s3Sink, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse s3 target %s: %w", target, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("s3 target %s has no bucket", target)
+	}
+
+	creds := s3Credentials{
+		accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		region:       os.Getenv("AWS_REGION"),
+	}
+	if creds.accessKey == "" || creds.secretKey == "" {
+		return nil, fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to write to %s", target)
+	}
+	if creds.region == "" {
+		creds.region = "us-east-1"
+	}
+
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", creds.region)
+	}
+	eu, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse S3_ENDPOINT %s: %w", endpoint, err)
+	}
+
+	return &s3Sink{
+		endpoint: eu,
+		bucket:   u.Host,
+		prefix:   strings.Trim(u.Path, "/"),
+		creds:    creds,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *s3Sink) Put(name string, vector *schema.TestVector) error {
+	b, err := encodeVector(vector)
+	if err != nil {
+		return err
+	}
+
+	key := path.Join(s.prefix, name)
+	u := *s.endpoint
+	u.Path = "/" + path.Join(strings.Trim(u.Path, "/"), s.bucket, key)
+	u.RawPath = s3EscapePath(u.Path)
+
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	s.creds.sign(req, b, time.Now())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to upload %s to bucket %s: %w", key, s.bucket, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("failed to upload %s to bucket %s: %s: %s", key, s.bucket, resp.Status, msg)
+	}
+	return nil
+}
+
+func (s *s3Sink) Close() error {
+	return nil
+}
+
+// sign adds the AWS signature version 4 headers to the request. All headers
+// set on the request, plus the host, are signed.
+func (c s3Credentials) sign(req *http.Request, payload []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	sum := sha256.Sum256(payload)
+	payloadHash := hex.EncodeToString(sum[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + c.region + "/s3/aws4_request"
+	crSum := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(crSum[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3EscapePath escapes every byte of the path but unreserved characters and
+// slashes, as AWS signature version 4 requires.
+func s3EscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/':
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
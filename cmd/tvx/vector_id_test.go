@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/conformance"
+)
+
+func compressCAR(t *testing.T, raw []byte, codec conformance.CARCodec, level int) []byte {
+	var buf bytes.Buffer
+	w, err := conformance.NewCARWriter(&buf, codec, level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(raw); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDeterministicVectorID(t *testing.T) {
+	a := DeterministicVectorID("mainnet", "message", "bafy1")
+	if a != DeterministicVectorID("mainnet", "message", "bafy1") {
+		t.Fatal("expected same ID for the same subject")
+	}
+	if a == DeterministicVectorID("calibnet", "message", "bafy1") {
+		t.Fatal("expected different IDs across networks")
+	}
+	if a == DeterministicVectorID("mainnet", "message", "bafy2") {
+		t.Fatal("expected different IDs across subjects")
+	}
+}
+
+func TestVectorContentHashIgnoresMeta(t *testing.T) {
+	v := &schema.TestVector{
+		Class: schema.ClassMessage,
+		Meta:  &schema.Metadata{ID: "a"},
+		CAR:   compressCAR(t, []byte{1, 2, 3}, conformance.CARCodecGzip, conformance.DefaultCARLevel),
+	}
+	if err := stampVector(v, "mainnet", "bafy1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := stampVector(v, "mainnet", "bafy1"); err != nil {
+		t.Fatal(err)
+	}
+	if len(v.Meta.Gen) != 1 {
+		t.Fatalf("expected a single content hash entry, got %d", len(v.Meta.Gen))
+	}
+
+	h1, err := VectorContentHash(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v.Meta = &schema.Metadata{ID: "b"}
+	h2, err := VectorContentHash(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 {
+		t.Fatal("expected metadata not to affect the content hash")
+	}
+
+	v.CAR = compressCAR(t, []byte{1, 2, 3}, conformance.CARCodecZstd, 19)
+	h3, err := VectorContentHash(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h3 {
+		t.Fatal("expected CAR compression not to affect the content hash")
+	}
+
+	v.CAR = compressCAR(t, []byte{4, 5, 6}, conformance.CARCodecGzip, 9)
+	h4, err := VectorContentHash(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 == h4 {
+		t.Fatal("expected content change to change the hash")
+	}
+}
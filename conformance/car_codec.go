@@ -0,0 +1,93 @@
+package conformance
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/DataDog/zstd"
+)
+
+// CARCodec is the compression applied to the state CAR embedded in a vector.
+// Readers detect the codec from the data, so vectors don't record it.
+type CARCodec string
+
+const (
+	// CARCodecGzip is the codec every vector used so far, and the one other
+	// implementations understand.
+	CARCodecGzip CARCodec = "gzip"
+	// CARCodecZstd compresses large tipset vectors faster and smaller, but
+	// is only understood by tools built from this tree.
+	CARCodecZstd CARCodec = "zstd"
+)
+
+// DefaultCARLevel selects the default compression level of the codec.
+const DefaultCARLevel = -1
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// ParseCARCodec parses a codec name, as used in tvx flags.
+func ParseCARCodec(s string) (CARCodec, error) {
+	switch c := CARCodec(s); c {
+	case CARCodecGzip, CARCodecZstd:
+		return c, nil
+	default:
+		return "", fmt.Errorf("unknown CAR codec %q; values: 'gzip', 'zstd'", s)
+	}
+}
+
+// DetectCARCodec returns the codec the CAR was compressed with, judging by
+// its magic bytes. Anything not recognised as zstd is assumed to be gzip.
+func DetectCARCodec(b []byte) CARCodec {
+	if bytes.HasPrefix(b, zstdMagic) {
+		return CARCodecZstd
+	}
+	return CARCodecGzip
+}
+
+// NewCARWriter returns a writer compressing into w with the given codec and
+// level; DefaultCARLevel picks the codec's default. Callers must close the
+// writer to flush it.
+func NewCARWriter(w io.Writer, codec CARCodec, level int) (io.WriteCloser, error) {
+	switch codec {
+	case CARCodecGzip:
+		gw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip level %d: %w", level, err)
+		}
+		return gw, nil
+	case CARCodecZstd:
+		if level == DefaultCARLevel {
+			level = zstd.DefaultCompression
+		}
+		if level < zstd.BestSpeed || level > zstd.BestCompression {
+			return nil, fmt.Errorf("invalid zstd level %d; must be between %d and %d", level, zstd.BestSpeed, zstd.BestCompression)
+		}
+		return zstd.NewWriterLevel(w, level), nil
+	default:
+		return nil, fmt.Errorf("unknown CAR codec %q", codec)
+	}
+}
+
+// NewCARReader returns a reader inflating the CAR read from r, whichever
+// codec it was compressed with.
+func NewCARReader(r io.Reader) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	if DetectCARCodec(magic) == CARCodecZstd {
+		return zstd.NewReader(br), nil
+	}
+	if !bytes.HasPrefix(magic, gzipMagic) {
+		return nil, fmt.Errorf("CAR is neither gzip nor zstd compressed")
+	}
+	return gzip.NewReader(br)
+}
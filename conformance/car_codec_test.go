@@ -0,0 +1,66 @@
+package conformance
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+)
+
+func TestCARCodecRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("not really a car, but compressible "), 100)
+
+	for _, tc := range []struct {
+		codec CARCodec
+		level int
+	}{
+		{CARCodecGzip, DefaultCARLevel},
+		{CARCodecGzip, 1},
+		{CARCodecGzip, 9},
+		{CARCodecZstd, DefaultCARLevel},
+		{CARCodecZstd, 1},
+		{CARCodecZstd, 19},
+	} {
+		var buf bytes.Buffer
+		w, err := NewCARWriter(&buf, tc.codec, tc.level)
+		if err != nil {
+			t.Fatalf("%s/%d: %s", tc.codec, tc.level, err)
+		}
+		if _, err := w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		if got := DetectCARCodec(buf.Bytes()); got != tc.codec {
+			t.Fatalf("%s/%d: detected codec %s", tc.codec, tc.level, got)
+		}
+
+		r, err := NewCARReader(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s/%d: %s", tc.codec, tc.level, err)
+		}
+		out, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, data) {
+			t.Fatalf("%s/%d: round trip mismatch", tc.codec, tc.level)
+		}
+	}
+}
+
+func TestCARCodecInvalid(t *testing.T) {
+	if _, err := ParseCARCodec("brotli"); err == nil {
+		t.Fatal("expected unknown codec to fail")
+	}
+	if _, err := NewCARWriter(ioutil.Discard, CARCodecGzip, 12); err == nil {
+		t.Fatal("expected invalid gzip level to fail")
+	}
+	if _, err := NewCARWriter(ioutil.Discard, CARCodecZstd, 30); err == nil {
+		t.Fatal("expected invalid zstd level to fail")
+	}
+	if _, err := NewCARReader(bytes.NewReader([]byte("plain"))); err == nil {
+		t.Fatal("expected uncompressed data to fail")
+	}
+}
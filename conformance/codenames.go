@@ -1,4 +1,4 @@
-package main
+package conformance
 
 import (
 	"github.com/filecoin-project/go-state-types/abi"
@@ -10,7 +10,7 @@ import (
 // will be set on extracted vectors, depending on the original execution height.
 //
 // Implementers rely on these names to filter the vectors they can run through
-// their implementations, based on their support level
+// their implementations, based on their support level; see SkipReason.
 var ProtocolCodenames = []struct {
 	firstEpoch abi.ChainEpoch
 	name       string
@@ -36,3 +36,14 @@ func GetProtocolCodename(height abi.ChainEpoch) string {
 	}
 	return ProtocolCodenames[len(ProtocolCodenames)-1].name
 }
+
+// protocolCodenameIndex returns the position of the codename in
+// ProtocolCodenames, i.e. the order of the protocol versions.
+func protocolCodenameIndex(name string) (int, bool) {
+	for i, v := range ProtocolCodenames {
+		if v.name == name {
+			return i, true
+		}
+	}
+	return 0, false
+}
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -26,6 +27,11 @@ const (
 	// The default is defaultCorpusRoot.
 	EnvCorpusRootDir = "CORPUS_DIR"
 
+	// EnvDeterminismRuns is the name of the environment variable setting
+	// TipsetVectorOpts.DeterminismRuns, i.e. how many times every tipset is
+	// re-executed to check that results are deterministic.
+	EnvDeterminismRuns = "CONFORMANCE_DETERMINISM_RUNS"
+
 	// defaultCorpusRoot is the directory where the test vector corpus is hosted.
 	// It is mounted on the Lotus repo as a git submodule.
 	//
@@ -56,6 +62,13 @@ func TestConformance(t *testing.T) {
 	if dir := strings.TrimSpace(os.Getenv(EnvCorpusRootDir)); dir != "" {
 		corpusRoot = dir
 	}
+	if runs := strings.TrimSpace(os.Getenv(EnvDeterminismRuns)); runs != "" {
+		n, err := strconv.Atoi(runs)
+		if err != nil {
+			t.Fatalf("invalid %s: %s", EnvDeterminismRuns, err)
+		}
+		TipsetVectorOpts.DeterminismRuns = n
+	}
 
 	var vectors []string
 	err := filepath.Walk(corpusRoot+"/", func(path string, info os.FileInfo, err error) error {
@@ -117,11 +130,8 @@ func TestConformance(t *testing.T) {
 		}
 
 		t.Run(v, func(t *testing.T) {
-			for _, h := range vector.Hints {
-				if h == schema.HintIncorrect {
-					t.Logf("skipping vector marked as incorrect: %s", vector.Meta.ID)
-					t.SkipNow()
-				}
+			if reason := SkipReason(&vector); reason != "" {
+				t.Skipf("skipped: %s: %s", vector.Meta.ID, reason)
 			}
 
 			// dispatch the execution depending on the vector class.
@@ -0,0 +1,107 @@
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	ds "github.com/ipfs/go-datastore"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+	"github.com/filecoin-project/lotus/lib/bufbstore"
+)
+
+// checkTipsetDeterminism re-executes a tipset n times concurrently, and
+// compares the results of every run against the reference result obtained
+// from the serial execution.
+//
+// Every run reads from the vector blockstore, but writes to its own temporary
+// blockstore, so runs can't observe each other's writes. The vector blockstore
+// must not be written to while the check is in progress.
+func (d *Driver) checkTipsetDeterminism(bs blockstore.Blockstore, params ExecuteTipsetParams, ref *ExecuteTipsetResult, n int) error {
+	var (
+		base = &lockedReadStore{Blockstore: bs}
+		wg   sync.WaitGroup
+
+		results = make([]*ExecuteTipsetResult, n)
+		errs    = make([]error, n)
+	)
+
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store := bufbstore.NewTieredBstore(base, blockstore.NewTemporarySync())
+			results[i], errs[i] = d.ExecuteTipset(store, ds.NewMapDatastore(), params)
+		}(i)
+	}
+	wg.Wait()
+
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			return fmt.Errorf("determinism run %d failed: %w", i, errs[i])
+		}
+		if err := compareTipsetResults(ref, results[i]); err != nil {
+			return fmt.Errorf("determinism run %d diverged from serial execution: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// compareTipsetResults returns an error describing the first difference
+// between two executions of the same tipset, or nil if they're identical.
+func compareTipsetResults(a, b *ExecuteTipsetResult) error {
+	if len(a.AppliedMessages) != len(b.AppliedMessages) {
+		return fmt.Errorf("applied %d messages, expected %d", len(b.AppliedMessages), len(a.AppliedMessages))
+	}
+	for i := range a.AppliedMessages {
+		if ac, bc := a.AppliedMessages[i].Cid(), b.AppliedMessages[i].Cid(); ac != bc {
+			return fmt.Errorf("message %d applied out of order; got %s, expected %s", i, bc, ac)
+		}
+		ar, br := a.AppliedResults[i], b.AppliedResults[i]
+		if ar.ExitCode != br.ExitCode {
+			return fmt.Errorf("exit code of message %d: got %s, expected %s", i, br.ExitCode, ar.ExitCode)
+		}
+		if ar.GasUsed != br.GasUsed {
+			return fmt.Errorf("gas used of message %d: got %d, expected %d", i, br.GasUsed, ar.GasUsed)
+		}
+		if !bytes.Equal(ar.Return, br.Return) {
+			return fmt.Errorf("return value of message %d differs", i)
+		}
+	}
+	if a.ReceiptsRoot != b.ReceiptsRoot {
+		return fmt.Errorf("receipts root: got %s, expected %s", b.ReceiptsRoot, a.ReceiptsRoot)
+	}
+	if a.PostStateRoot != b.PostStateRoot {
+		return fmt.Errorf("post state root: got %s, expected %s", b.PostStateRoot, a.PostStateRoot)
+	}
+	return nil
+}
+
+// lockedReadStore serializes reads on a blockstore that isn't safe for
+// concurrent use. Reads may write through a fallback store, so a plain
+// read lock is not enough.
+type lockedReadStore struct {
+	lk sync.Mutex
+	blockstore.Blockstore
+}
+
+func (l *lockedReadStore) Get(c cid.Cid) (blocks.Block, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.Blockstore.Get(c)
+}
+
+func (l *lockedReadStore) GetSize(c cid.Cid) (int, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.Blockstore.GetSize(c)
+}
+
+func (l *lockedReadStore) Has(c cid.Cid) (bool, error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.Blockstore.Has(c)
+}
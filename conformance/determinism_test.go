@@ -0,0 +1,40 @@
+package conformance
+
+import (
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/exitcode"
+
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/chain/vm"
+)
+
+func TestCompareTipsetResults(t *testing.T) {
+	addr, err := address.NewIDAddress(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := &types.Message{To: addr, From: addr, Nonce: 1}
+	result := func(gas int64) *ExecuteTipsetResult {
+		return &ExecuteTipsetResult{
+			AppliedMessages: []*types.Message{msg},
+			AppliedResults: []*vm.ApplyRet{{
+				MessageReceipt: types.MessageReceipt{ExitCode: exitcode.Ok, GasUsed: gas},
+			}},
+		}
+	}
+
+	if err := compareTipsetResults(result(10), result(10)); err != nil {
+		t.Fatalf("identical results reported as different: %s", err)
+	}
+	if err := compareTipsetResults(result(10), result(11)); err == nil {
+		t.Fatal("expected differing gas to be reported")
+	}
+
+	reordered := result(10)
+	reordered.AppliedMessages = []*types.Message{{To: addr, From: addr, Nonce: 2}}
+	if err := compareTipsetResults(result(10), reordered); err == nil {
+		t.Fatal("expected differing message order to be reported")
+	}
+}
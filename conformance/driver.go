@@ -2,8 +2,11 @@ package conformance
 
 import (
 	"context"
+	"fmt"
+	"math"
 	gobig "math/big"
 	"os"
+	"strconv"
 
 	"github.com/filecoin-project/lotus/chain/state"
 	"github.com/filecoin-project/lotus/chain/stmgr"
@@ -20,6 +23,7 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/big"
 	"github.com/filecoin-project/go-state-types/crypto"
+	"github.com/filecoin-project/go-state-types/network"
 
 	"github.com/filecoin-project/test-vectors/schema"
 
@@ -43,6 +47,12 @@ type Driver struct {
 	ctx      context.Context
 	selector schema.Selector
 	vmFlush  bool
+	syscalls vm.SyscallBuilder
+	nv       *network.Version
+
+	cron             CronMode
+	genesisTimestamp *uint64
+	optsErr          error // invalid execution controls in the selector
 }
 
 type DriverOpts struct {
@@ -56,10 +66,82 @@ type DriverOpts struct {
 	// LOTUS_DISABLE_VM_BUF=iknowitsabadidea. That way, state tree writes are
 	// immediately committed to the blockstore.
 	DisableVMFlush bool
+
+	// Syscalls, when not nil, replaces the syscalls implementation handed to
+	// actors. This allows running vectors with e.g. signature verification
+	// skipped, or with recorded syscall results. If nil, the driver uses the
+	// standard syscalls backed by the FFI proof verifier.
+	Syscalls vm.SyscallBuilder
+
+	// NetworkVersion, when not nil, pins the network version the VM runs at,
+	// regardless of the epoch. State migrations of the upgrade schedule are
+	// not run in that case.
+	NetworkVersion *network.Version
+
+	// Cron controls when cron is ticked while applying tipsets; empty means
+	// CronEveryEpoch. The vector selector takes precedence.
+	Cron CronMode
+
+	// GenesisTimestamp, when not nil, pins the clock seen during execution to
+	// genesis timestamp + epoch * block delay, so that execution traces are
+	// reproducible. The vector selector takes precedence.
+	GenesisTimestamp *uint64
 }
 
 func NewDriver(ctx context.Context, selector schema.Selector, opts DriverOpts) *Driver {
-	return &Driver{ctx: ctx, selector: selector, vmFlush: !opts.DisableVMFlush}
+	syscalls := opts.Syscalls
+	if syscalls == nil {
+		syscalls = vm.Syscalls(ffiwrapper.ProofVerifier)
+	}
+	switch selector[SelectorVerifyActorSignatures] {
+	case "true":
+		syscalls = EnforceSignatureSyscalls(syscalls)
+	case "false":
+		syscalls = SkipSignatureSyscalls(syscalls)
+	}
+
+	d := &Driver{ctx: ctx, selector: selector, vmFlush: !opts.DisableVMFlush, syscalls: syscalls, nv: opts.NetworkVersion}
+
+	d.cron, d.optsErr = ParseCronMode(string(opts.Cron))
+	if s, ok := selector[SelectorCron]; ok {
+		d.cron, d.optsErr = ParseCronMode(s)
+	}
+
+	d.genesisTimestamp = opts.GenesisTimestamp
+	if s, ok := selector[SelectorGenesisTimestamp]; ok {
+		ts, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			d.optsErr = fmt.Errorf("invalid %s selector %q: %w", SelectorGenesisTimestamp, s, err)
+		}
+		d.genesisTimestamp = &ts
+	}
+
+	return d
+}
+
+// newStateManager creates a state manager following the default upgrade
+// schedule, or running at the pinned network version.
+func (d *Driver) newStateManager(cs *store.ChainStore) (*stmgr.StateManager, error) {
+	if d.optsErr != nil {
+		return nil, d.optsErr
+	}
+
+	sm := stmgr.NewStateManager(cs)
+	if d.nv != nil {
+		us := stmgr.UpgradeSchedule{{Height: -1, Network: *d.nv}}
+		if *d.nv == network.Version0 {
+			// there is no upgrade to version 0; never upgrade from it instead.
+			us = stmgr.UpgradeSchedule{{Height: math.MaxInt64, Network: network.Version1}}
+		}
+
+		var err error
+		if sm, err = stmgr.NewStateManagerWithUpgradeSchedule(cs, us); err != nil {
+			return nil, err
+		}
+	}
+
+	sm.SetCronSchedule(d.cron.schedule())
+	return sm, nil
 }
 
 type ExecuteTipsetResult struct {
@@ -98,17 +180,22 @@ type ExecuteTipsetParams struct {
 // and reward withdrawal per miner.
 func (d *Driver) ExecuteTipset(bs blockstore.Blockstore, ds ds.Batching, params ExecuteTipsetParams) (*ExecuteTipsetResult, error) {
 	var (
-		tipset   = params.Tipset
-		syscalls = vm.Syscalls(ffiwrapper.ProofVerifier)
+		tipset = params.Tipset
 
-		cs = store.NewChainStore(bs, bs, ds, syscalls, nil)
-		sm = stmgr.NewStateManager(cs)
+		cs = store.NewChainStore(bs, bs, ds, d.syscalls, nil)
 	)
 
+	sm, err := d.newStateManager(cs)
+	if err != nil {
+		return nil, err
+	}
+
 	if params.Rand == nil {
 		params.Rand = NewFixedRand()
 	}
 
+	defer d.pinClock(params.ExecEpoch)()
+
 	if params.BaseFee.NilOrZero() {
 		params.BaseFee = abi.NewTokenAmount(tipset.BaseFee.Int64())
 	}
@@ -201,13 +288,18 @@ func (d *Driver) ExecuteMessage(bs blockstore.Blockstore, params ExecuteMessageP
 
 	// dummy state manager; only to reference the GetNetworkVersion method,
 	// which does not depend on state.
-	sm := stmgr.NewStateManager(nil)
+	sm, err := d.newStateManager(nil)
+	if err != nil {
+		return nil, cid.Undef, err
+	}
+
+	defer d.pinClock(params.Epoch)()
 
 	vmOpts := &vm.VMOpts{
 		StateBase: params.Preroot,
 		Epoch:     params.Epoch,
 		Bstore:    bs,
-		Syscalls:  vm.Syscalls(ffiwrapper.ProofVerifier),
+		Syscalls:  d.syscalls,
 		CircSupplyCalc: func(_ context.Context, _ abi.ChainEpoch, _ *state.StateTree) (abi.TokenAmount, error) {
 			return params.CircSupply, nil
 		},
@@ -224,7 +316,7 @@ func (d *Driver) ExecuteMessage(bs blockstore.Blockstore, params ExecuteMessageP
 	invoker := vm.NewActorRegistry()
 
 	// register the chaos actor if required by the vector.
-	if chaosOn, ok := d.selector["chaos_actor"]; ok && chaosOn == "true" {
+	if chaosOn, ok := d.selector[SelectorChaosActor]; ok && chaosOn == "true" {
 		invoker.Register(nil, chaos.Actor{})
 	}
 
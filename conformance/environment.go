@@ -0,0 +1,143 @@
+package conformance
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"strings"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/build"
+)
+
+// EnvironmentSource is the prefix of the generation data entries recording
+// the environment a vector was extracted in: the proofs parameters, the
+// versions of the modules implementing actors and proofs, and the network the
+// binary was built for. Executing a vector in a different environment may
+// explain otherwise mysterious mismatches.
+const EnvironmentSource = "env:"
+
+// environmentModules are the modules which versions are recorded; any module
+// path starting with one of these matches, so that all major versions of
+// specs-actors are covered.
+var environmentModules = []string{
+	"github.com/filecoin-project/specs-actors",
+	"github.com/filecoin-project/go-state-types",
+	"github.com/filecoin-project/filecoin-ffi",
+}
+
+// CaptureEnvironment returns the generation data entries describing the
+// environment of the running binary.
+func CaptureEnvironment() []schema.GenerationData {
+	out := []schema.GenerationData{
+		{Source: EnvironmentSource + "build", Version: buildNetwork()},
+	}
+	if v := proofsParamsVersion(); v != "" {
+		out = append(out, schema.GenerationData{Source: EnvironmentSource + "proofs_params", Version: v})
+	}
+
+	// module versions are only available in binaries built in module mode.
+	if info, ok := debug.ReadBuildInfo(); ok {
+		var mods []schema.GenerationData
+		for _, dep := range info.Deps {
+			if !environmentModule(dep.Path) {
+				continue
+			}
+			v := dep.Version
+			if dep.Replace != nil {
+				v = dep.Replace.Path + "@" + dep.Replace.Version
+			}
+			mods = append(mods, schema.GenerationData{Source: EnvironmentSource + "module:" + dep.Path, Version: v})
+		}
+		sort.Slice(mods, func(i, j int) bool { return mods[i].Source < mods[j].Source })
+		out = append(out, mods...)
+	}
+
+	return out
+}
+
+// EnvironmentMismatches compares the environment recorded in the vector
+// metadata with the one of the running binary, and describes every
+// difference. Vectors extracted before the environment was recorded have no
+// mismatches.
+func EnvironmentMismatches(meta *schema.Metadata) []string {
+	if meta == nil {
+		return nil
+	}
+
+	current := make(map[string]string)
+	for _, g := range CaptureEnvironment() {
+		current[g.Source] = g.Version
+	}
+
+	var out []string
+	for _, g := range meta.Gen {
+		if !strings.HasPrefix(g.Source, EnvironmentSource) {
+			continue
+		}
+		key := strings.TrimPrefix(g.Source, EnvironmentSource)
+
+		actual, ok := current[g.Source]
+		switch {
+		case !ok:
+			out = append(out, fmt.Sprintf("%s: extracted with %s, unknown when executing", key, g.Version))
+		case actual != g.Version:
+			out = append(out, fmt.Sprintf("%s: extracted with %s, executing with %s", key, g.Version, actual))
+		}
+	}
+	return out
+}
+
+func environmentModule(path string) bool {
+	for _, m := range environmentModules {
+		if path == m || strings.HasPrefix(path, m+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// buildNetwork names the network the binary was built for.
+func buildNetwork() string {
+	switch build.BuildType {
+	case build.BuildDebug:
+		return "debug"
+	case build.Build2k:
+		return "2k"
+	}
+	if build.GenesisFile != "" {
+		return strings.TrimSuffix(build.GenesisFile, ".car")
+	}
+	return "custom"
+}
+
+// proofsParamsVersion returns the versions of the proofs parameters the
+// binary fetches, along with a digest of the full parameters manifest, which
+// changes whenever any parameter file does.
+func proofsParamsVersion() string {
+	manifest := build.ParametersJSON()
+
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(manifest, &params); err != nil {
+		return ""
+	}
+
+	versions := make(map[string]struct{})
+	for name := range params {
+		if i := strings.Index(name, "-"); i > 0 {
+			versions[name[:i]] = struct{}{}
+		}
+	}
+	vs := make([]string, 0, len(versions))
+	for v := range versions {
+		vs = append(vs, v)
+	}
+	sort.Strings(vs)
+
+	h := sha256.Sum256(manifest)
+	return strings.Join(vs, ",") + "/" + hex.EncodeToString(h[:])[:16]
+}
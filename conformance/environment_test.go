@@ -0,0 +1,41 @@
+package conformance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+func TestEnvironmentMismatches(t *testing.T) {
+	env := CaptureEnvironment()
+	if len(env) == 0 {
+		t.Fatal("expected environment to be captured")
+	}
+
+	meta := &schema.Metadata{
+		Gen: append([]schema.GenerationData{{Source: "network:testnetnet"}}, env...),
+	}
+	if m := EnvironmentMismatches(meta); len(m) != 0 {
+		t.Fatalf("expected no mismatches in the same environment, got %v", m)
+	}
+
+	meta.Gen = append(meta.Gen,
+		schema.GenerationData{Source: EnvironmentSource + "module:example.com/gone", Version: "v1.0.0"})
+	meta.Gen[1].Version = "other"
+
+	m := EnvironmentMismatches(meta)
+	if len(m) != 2 {
+		t.Fatalf("expected two mismatches, got %v", m)
+	}
+	if !strings.HasSuffix(m[0], "extracted with other, executing with "+env[0].Version) {
+		t.Fatalf("unexpected mismatch: %s", m[0])
+	}
+	if m[1] != "module:example.com/gone: extracted with v1.0.0, unknown when executing" {
+		t.Fatalf("unexpected mismatch: %s", m[1])
+	}
+
+	if m := EnvironmentMismatches(nil); len(m) != 0 {
+		t.Fatalf("expected no mismatches without metadata, got %v", m)
+	}
+}
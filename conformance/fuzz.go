@@ -0,0 +1,138 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"runtime/debug"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+// FuzzMutationLen is the number of input bytes consumed by a single mutation: a
+// target selector, a big-endian offset, and the byte to write.
+const FuzzMutationLen = 4
+
+// MutateVector returns a copy of a message vector with its message bytes and
+// state CAR mutated as directed by data, so that fuzzers can explore the
+// mutations. Every FuzzMutationLen bytes of data overwrite one byte: even target
+// selectors pick a message, odd ones the (inflated) CAR. The result is
+// deterministic for a given vector and data.
+func MutateVector(vector *schema.TestVector, data []byte) (*schema.TestVector, error) {
+	mutated := *vector
+	mutated.ApplyMessages = make([]schema.Message, len(vector.ApplyMessages))
+	for i, m := range vector.ApplyMessages {
+		m.Bytes = append([]byte(nil), m.Bytes...)
+		mutated.ApplyMessages[i] = m
+	}
+
+	var car []byte
+	if len(vector.CAR) > 0 {
+		r, err := NewCARReader(bytes.NewReader(vector.CAR))
+		if err != nil {
+			return nil, fmt.Errorf("failed to inflate CAR: %w", err)
+		}
+		if car, err = ioutil.ReadAll(r); err != nil {
+			return nil, fmt.Errorf("failed to inflate CAR: %w", err)
+		}
+	}
+
+	carMutated := false
+	for ; len(data) >= FuzzMutationLen; data = data[FuzzMutationLen:] {
+		target, off, val := data[0], int(binary.BigEndian.Uint16(data[1:3])), data[3]
+
+		var buf []byte
+		if target%2 == 0 {
+			if len(mutated.ApplyMessages) == 0 {
+				continue
+			}
+			buf = mutated.ApplyMessages[int(target/2)%len(mutated.ApplyMessages)].Bytes
+		} else {
+			buf = car
+			carMutated = true
+		}
+		if len(buf) == 0 {
+			continue
+		}
+		buf[off%len(buf)] = val
+	}
+
+	if carMutated && len(car) > 0 {
+		var out bytes.Buffer
+		w, err := NewCARWriter(&out, DetectCARCodec(vector.CAR), DefaultCARLevel)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compress CAR: %w", err)
+		}
+		if _, err := w.Write(car); err != nil {
+			return nil, fmt.Errorf("failed to compress CAR: %w", err)
+		}
+		if err := w.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress CAR: %w", err)
+		}
+		mutated.CAR = out.Bytes()
+	}
+
+	return &mutated, nil
+}
+
+// FuzzPanic is a panic raised while executing a fuzzed vector.
+type FuzzPanic struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (p *FuzzPanic) Error() string {
+	return fmt.Sprintf("panic: %v\n%s", p.Value, p.Stack)
+}
+
+// fuzzAborted is raised by fuzzReporter to abandon the current execution.
+type fuzzAborted struct{}
+
+// fuzzReporter is a quiet Reporter that abandons the execution on fatal
+// errors. Mutated vectors are expected to fail, so failures are only recorded.
+type fuzzReporter struct {
+	failed bool
+}
+
+var _ Reporter = (*fuzzReporter)(nil)
+
+func (*fuzzReporter) Helper()                         {}
+func (*fuzzReporter) Log(...interface{})              {}
+func (*fuzzReporter) Logf(string, ...interface{})     {}
+func (r *fuzzReporter) Failed() bool                  { return r.failed }
+func (r *fuzzReporter) Errorf(string, ...interface{}) { r.failed = true }
+
+func (r *fuzzReporter) FailNow() {
+	r.failed = true
+	panic(fuzzAborted{})
+}
+
+func (r *fuzzReporter) Fatalf(string, ...interface{}) {
+	r.FailNow()
+}
+
+// FuzzMessageVector executes the first variant of a mutated message vector,
+// and returns a *FuzzPanic if execution panicked. Executions ending in errors,
+// failed assertions or exit codes are expected with mutated inputs and aren't
+// reported. executed is false when execution was abandoned on a fatal
+// error, e.g. because a message couldn't be decoded.
+func FuzzMessageVector(vector *schema.TestVector) (executed bool, fp *FuzzPanic) {
+	if vector.Class != schema.ClassMessage || len(vector.Pre.Variants) == 0 {
+		return false, nil
+	}
+
+	r := new(fuzzReporter)
+	defer func() {
+		if p := recover(); p != nil {
+			if _, ok := p.(fuzzAborted); ok {
+				executed = false
+				return
+			}
+			executed, fp = true, &FuzzPanic{Value: p, Stack: debug.Stack()}
+		}
+	}()
+
+	_, _ = ExecuteMessageVector(r, vector, &vector.Pre.Variants[0])
+	return true, nil
+}
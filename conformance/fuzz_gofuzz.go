@@ -0,0 +1,73 @@
+//+build gofuzz
+
+package conformance
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+// EnvFuzzSeeds is the environment variable naming the directory of message
+// vectors that Fuzz mutates.
+const EnvFuzzSeeds = "TVX_FUZZ_SEEDS"
+
+var (
+	fuzzSeedsOnce sync.Once
+	fuzzSeeds     []*schema.TestVector
+)
+
+func loadFuzzSeeds() {
+	dir := os.Getenv(EnvFuzzSeeds)
+	if dir == "" {
+		panic(EnvFuzzSeeds + " must be set to a directory of seed vectors") // ok
+	}
+
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close() // nolint
+
+		var tv schema.TestVector
+		if json.NewDecoder(f).Decode(&tv) == nil && tv.Class == schema.ClassMessage && len(tv.CAR) > 0 {
+			fuzzSeeds = append(fuzzSeeds, &tv)
+		}
+		return nil
+	})
+
+	if len(fuzzSeeds) == 0 {
+		panic("no message vectors with embedded state in " + dir) // ok
+	}
+}
+
+// Fuzz is the go-fuzz entry point. The first byte of data picks the seed
+// vector, the rest directs MutateVector. Panics during execution are left to
+// go-fuzz to record as crashers.
+func Fuzz(data []byte) int {
+	fuzzSeedsOnce.Do(loadFuzzSeeds)
+	if len(data) == 0 {
+		return -1
+	}
+
+	vector, err := MutateVector(fuzzSeeds[int(data[0])%len(fuzzSeeds)], data[1:])
+	if err != nil {
+		return -1
+	}
+
+	executed, fp := FuzzMessageVector(vector)
+	if fp != nil {
+		panic(fp.Error()) // ok
+	}
+	if !executed {
+		return 0
+	}
+	return 1
+}
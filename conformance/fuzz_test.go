@@ -0,0 +1,97 @@
+package conformance
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"testing"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+func gzipBytes(t *testing.T, b []byte) []byte {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func gunzipBytes(t *testing.T, b []byte) []byte {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestMutateVector(t *testing.T) {
+	seed := &schema.TestVector{
+		Class: schema.ClassMessage,
+		CAR:   gzipBytes(t, []byte("abcdef")),
+		ApplyMessages: []schema.Message{
+			{Bytes: []byte{0, 0, 0}},
+			{Bytes: []byte{1, 1}},
+		},
+	}
+	origCAR := append([]byte(nil), seed.CAR...)
+
+	data := []byte{
+		0, 0, 1, 0xaa, // message 0, offset 1
+		2, 0, 3, 0xbb, // message 1, offset 3 % 2
+		1, 0, 0, 'X', // CAR, offset 0
+		9, 9, // trailing bytes, ignored
+	}
+
+	m1, err := MutateVector(seed, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m2, err := MutateVector(seed, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(m1.ApplyMessages[0].Bytes, []byte{0, 0xaa, 0}) {
+		t.Fatalf("unexpected message 0: %x", m1.ApplyMessages[0].Bytes)
+	}
+	if !bytes.Equal(m1.ApplyMessages[1].Bytes, []byte{1, 0xbb}) {
+		t.Fatalf("unexpected message 1: %x", m1.ApplyMessages[1].Bytes)
+	}
+	if car := gunzipBytes(t, m1.CAR); string(car) != "Xbcdef" {
+		t.Fatalf("unexpected CAR: %q", car)
+	}
+	if !bytes.Equal(m1.ApplyMessages[0].Bytes, m2.ApplyMessages[0].Bytes) || !bytes.Equal(gunzipBytes(t, m1.CAR), gunzipBytes(t, m2.CAR)) {
+		t.Fatal("mutations aren't deterministic")
+	}
+
+	// the seed is left untouched
+	if !bytes.Equal(seed.ApplyMessages[0].Bytes, []byte{0, 0, 0}) || !bytes.Equal(seed.CAR, origCAR) {
+		t.Fatal("seed vector was mutated")
+	}
+}
+
+func TestFuzzMessageVectorRejected(t *testing.T) {
+	// a CAR that doesn't inflate aborts execution, which isn't a crasher
+	v := &schema.TestVector{
+		Class: schema.ClassMessage,
+		CAR:   []byte("not gzip"),
+		Pre: &schema.Preconditions{
+			Variants:  []schema.Variant{{ID: "v1"}},
+			StateTree: new(schema.StateTree),
+		},
+	}
+
+	executed, fp := FuzzMessageVector(v)
+	if executed || fp != nil {
+		t.Fatalf("expected an aborted execution, got executed=%t, panic=%v", executed, fp)
+	}
+}
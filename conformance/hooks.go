@@ -0,0 +1,82 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+// PostconditionHook runs custom assertions after a variant of a vector has been
+// executed, such as "the power of miner X increased", beyond the comparison of
+// the state and receipts roots. It gets the blockstore holding the post-state,
+// and the post-state root, and reports failures through the Reporter.
+type PostconditionHook func(r Reporter, vector *schema.TestVector, variant *schema.Variant, bs blockstore.Blockstore, postRoot cid.Cid)
+
+// PostconditionHooks are called after every executed variant, whether the
+// roots matched or not.
+var PostconditionHooks []PostconditionHook
+
+func runPostconditionHooks(r Reporter, vector *schema.TestVector, variant *schema.Variant, bs blockstore.Blockstore, postRoot cid.Cid) {
+	for _, hook := range PostconditionHooks {
+		hook(r, vector, variant, bs, postRoot)
+	}
+}
+
+// Environment variables set for subprocess postcondition hooks.
+const (
+	EnvHookVectorID  = "TVX_VECTOR_ID"
+	EnvHookVariantID = "TVX_VARIANT_ID"
+	EnvHookEpoch     = "TVX_VARIANT_EPOCH"
+	EnvHookPostRoot  = "TVX_POST_ROOT"
+	EnvHookStateCAR  = "TVX_POST_STATE_CAR"
+)
+
+// SubprocessHook returns a postcondition hook running the command through the
+// shell. The command gets the vector as JSON on stdin, and the variant and the
+// post-state in the TVX_* environment variables; the post-state is exported as
+// a CAR file, rooted at the post-state root. The assertion fails if the command
+// exits with a non-zero status; its output is logged.
+func SubprocessHook(command string) PostconditionHook {
+	return func(r Reporter, vector *schema.TestVector, variant *schema.Variant, bs blockstore.Blockstore, postRoot cid.Cid) {
+		r.Helper()
+
+		vj, err := json.Marshal(vector)
+		if err != nil {
+			r.Errorf("postcondition hook %q: failed to marshal vector: %s", command, err)
+			return
+		}
+
+		tmpCar, err := writeStateToTempCAR(bs, postRoot)
+		if err != nil {
+			r.Errorf("postcondition hook %q: failed to export post-state: %s", command, err)
+			return
+		}
+		defer os.Remove(tmpCar) //nolint:errcheck
+
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = bytes.NewReader(vj)
+		cmd.Env = append(os.Environ(),
+			EnvHookVectorID+"="+vector.Meta.ID,
+			EnvHookVariantID+"="+variant.ID,
+			fmt.Sprintf("%s=%d", EnvHookEpoch, variant.Epoch),
+			EnvHookPostRoot+"="+postRoot.String(),
+			EnvHookStateCAR+"="+tmpCar,
+		)
+
+		out, err := cmd.CombinedOutput()
+		if len(out) > 0 {
+			r.Logf("postcondition hook %q output:\n%s", command, out)
+		}
+		if err != nil {
+			r.Errorf("postcondition hook %q failed for variant %s: %s", command, variant.ID, err)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package conformance
+
+import (
+	"testing"
+
+	cbornode "github.com/ipfs/go-ipld-cbor"
+	mh "github.com/multiformats/go-multihash"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+func TestSubprocessHook(t *testing.T) {
+	nd, err := cbornode.WrapObject(map[string]string{"power": "up"}, mh.SHA2_256, -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bs := blockstore.NewTemporary()
+	if err := bs.Put(nd); err != nil {
+		t.Fatal(err)
+	}
+
+	vector := &schema.TestVector{Meta: &schema.Metadata{ID: "vector-1"}}
+	variant := &schema.Variant{ID: "variant-1", Epoch: 100}
+
+	ok := SubprocessHook(`test "$TVX_VECTOR_ID" = vector-1 && test "$TVX_VARIANT_EPOCH" = 100 && test "$TVX_POST_ROOT" = ` + nd.Cid().String() + ` && test -s "$TVX_POST_STATE_CAR" && grep -q vector-1`)
+	r := new(LogReporter)
+	ok(r, vector, variant, bs, nd.Cid())
+	if r.Failed() {
+		t.Fatal("expected the hook to pass")
+	}
+
+	fail := SubprocessHook("echo 'power did not increase'; exit 1")
+	fail(r, vector, variant, bs, nd.Cid())
+	if !r.Failed() {
+		t.Fatal("expected the hook to fail the vector")
+	}
+}
@@ -0,0 +1,147 @@
+package conformance
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/filecoin-project/test-vectors/schema"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// ReceiptOriginsSource is the prefix of the generation data entries that map
+// the receipts of a tipset vector to the messages they originate from. There
+// is one entry per applied tipset, formatted as
+//
+//	receipt_origins:<tipset index>:<origin>,<origin>,...
+//
+// with one origin per receipt, either <block index>.<message index>, or
+// "implicit" for receipts of implicit messages (rewards, cron).
+const ReceiptOriginsSource = "receipt_origins:"
+
+const implicitOrigin = "implicit"
+
+// MessageOrigin locates the message a receipt originates from within the
+// blocks of its tipset. Implicit messages have no origin, and have Block and
+// Message set to -1.
+type MessageOrigin struct {
+	Block   int
+	Message int
+}
+
+// Implicit returns whether the receipt is for an implicit message.
+func (o MessageOrigin) Implicit() bool {
+	return o.Block < 0
+}
+
+func (o MessageOrigin) String() string {
+	if o.Implicit() {
+		return implicitOrigin
+	}
+	return fmt.Sprintf("%d.%d", o.Block, o.Message)
+}
+
+// ReceiptOrigins maps the messages applied from a tipset, as returned in
+// ExecuteTipsetResult.AppliedMessages, to the block messages of the tipset.
+// Messages included in several blocks are only applied once; their receipt
+// maps to the first block including them.
+func ReceiptOrigins(tipset *schema.Tipset, applied []*types.Message) ([]MessageOrigin, error) {
+	first := make(map[cid.Cid]MessageOrigin)
+	for bi, b := range tipset.Blocks {
+		for mi, m := range b.Messages {
+			msg, err := types.DecodeMessage(m)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode message %d of block %d: %w", mi, bi, err)
+			}
+			if _, ok := first[msg.Cid()]; !ok {
+				first[msg.Cid()] = MessageOrigin{Block: bi, Message: mi}
+			}
+		}
+	}
+
+	out := make([]MessageOrigin, len(applied))
+	for i, msg := range applied {
+		o, ok := first[msg.Cid()]
+		if !ok {
+			o = MessageOrigin{Block: -1, Message: -1}
+		}
+		out[i] = o
+	}
+	return out, nil
+}
+
+// EncodeReceiptOrigins formats the receipt origins of the tipset at the given
+// index as a generation data entry.
+func EncodeReceiptOrigins(tipset int, origins []MessageOrigin) schema.GenerationData {
+	ss := make([]string, len(origins))
+	for i, o := range origins {
+		ss[i] = o.String()
+	}
+	return schema.GenerationData{
+		Source: fmt.Sprintf("%s%d:%s", ReceiptOriginsSource, tipset, strings.Join(ss, ",")),
+	}
+}
+
+// DecodeReceiptOrigins reads the receipt origins recorded in the vector
+// metadata, keyed by tipset index. Vectors extracted before origins were
+// recorded have none.
+func DecodeReceiptOrigins(meta *schema.Metadata) (map[int][]MessageOrigin, error) {
+	out := make(map[int][]MessageOrigin)
+	if meta == nil {
+		return out, nil
+	}
+
+	for _, g := range meta.Gen {
+		if !strings.HasPrefix(g.Source, ReceiptOriginsSource) {
+			continue
+		}
+
+		parts := strings.SplitN(strings.TrimPrefix(g.Source, ReceiptOriginsSource), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("malformed receipt origins %q", g.Source)
+		}
+		ts, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed tipset index in receipt origins %q: %w", g.Source, err)
+		}
+
+		origins := []MessageOrigin{}
+		if parts[1] != "" {
+			for _, s := range strings.Split(parts[1], ",") {
+				o, err := parseOrigin(s)
+				if err != nil {
+					return nil, fmt.Errorf("malformed receipt origins %q: %w", g.Source, err)
+				}
+				origins = append(origins, o)
+			}
+		}
+		out[ts] = origins
+	}
+
+	return out, nil
+}
+
+func parseOrigin(s string) (MessageOrigin, error) {
+	if s == implicitOrigin {
+		return MessageOrigin{Block: -1, Message: -1}, nil
+	}
+
+	parts := strings.Split(s, ".")
+	if len(parts) != 2 {
+		return MessageOrigin{}, fmt.Errorf("bad origin %q", s)
+	}
+	b, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return MessageOrigin{}, fmt.Errorf("bad block index in %q: %w", s, err)
+	}
+	m, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return MessageOrigin{}, fmt.Errorf("bad message index in %q: %w", s, err)
+	}
+	if b < 0 || m < 0 {
+		return MessageOrigin{}, fmt.Errorf("negative index in %q", s)
+	}
+	return MessageOrigin{Block: b, Message: m}, nil
+}
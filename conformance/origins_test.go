@@ -0,0 +1,80 @@
+package conformance
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestReceiptOrigins(t *testing.T) {
+	msg := func(nonce uint64) *types.Message {
+		from, _ := address.NewIDAddress(100)
+		to, _ := address.NewIDAddress(101)
+		return &types.Message{
+			From:       from,
+			To:         to,
+			Nonce:      nonce,
+			Value:      big.Zero(),
+			GasFeeCap:  big.Zero(),
+			GasPremium: big.Zero(),
+		}
+	}
+	ser := func(m *types.Message) schema.Base64EncodedBytes {
+		b, err := m.Serialize()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return b
+	}
+
+	m0, m1, m2 := msg(0), msg(1), msg(2)
+	tipset := &schema.Tipset{
+		Blocks: []schema.Block{
+			{Messages: []schema.Base64EncodedBytes{ser(m0), ser(m1)}},
+			// m1 is a duplicate and is only applied once
+			{Messages: []schema.Base64EncodedBytes{ser(m1), ser(m2)}},
+		},
+	}
+
+	cron := msg(99)
+	origins, err := ReceiptOrigins(tipset, []*types.Message{m0, m1, m2, cron})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []MessageOrigin{{0, 0}, {0, 1}, {1, 1}, {-1, -1}}
+	if !reflect.DeepEqual(origins, expected) {
+		t.Fatalf("expected origins %v, got %v", expected, origins)
+	}
+
+	gen := EncodeReceiptOrigins(3, origins)
+	if gen.Source != "receipt_origins:3:0.0,0.1,1.1,implicit" {
+		t.Fatalf("unexpected encoding: %s", gen.Source)
+	}
+
+	decoded, err := DecodeReceiptOrigins(&schema.Metadata{Gen: []schema.GenerationData{
+		{Source: "tipset:{}"},
+		gen,
+		EncodeReceiptOrigins(4, nil),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded[3], expected) {
+		t.Fatalf("expected decoded origins %v, got %v", expected, decoded[3])
+	}
+	if o, ok := decoded[4]; !ok || len(o) != 0 {
+		t.Fatalf("expected empty origins for tipset 4, got %v", o)
+	}
+
+	if _, err := DecodeReceiptOrigins(&schema.Metadata{Gen: []schema.GenerationData{
+		{Source: "receipt_origins:0:1.-2"},
+	}}); err == nil {
+		t.Fatal("expected error decoding negative index")
+	}
+}
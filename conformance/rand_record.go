@@ -1,6 +1,7 @@
 package conformance
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"sync"
@@ -11,10 +12,18 @@ import (
 	"github.com/filecoin-project/test-vectors/schema"
 
 	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/chain/vm"
 )
 
+// RandomnessBeaconEntry is the kind of recorded randomness entries holding the
+// drand beacon entry that beacon randomness for an epoch was drawn from: On.Epoch
+// is the epoch randomness was requested for, and Return is the entry data.
+// Replaying such entries allows deriving beacon randomness for any domain
+// separation tag and entropy, not only for the requests seen while recording.
+const RandomnessBeaconEntry = schema.RandomnessKind("beacon_entry")
+
 type RecordingRand struct {
 	reporter Reporter
 	api      api.FullNode
@@ -92,7 +101,77 @@ func (r *RecordingRand) GetBeaconRandomness(ctx context.Context, pers crypto.Dom
 	r.recorded = append(r.recorded, match)
 	r.lk.Unlock()
 
-	return ret, err
+	// the beacon entry is only recorded on a best-effort basis; the randomness
+	// itself has been recorded already.
+	if err := r.recordBeaconEntry(ctx, pers, round, entropy, ret); err != nil {
+		r.reporter.Logf("failed to record beacon entry for epoch %d: %s", round, err)
+	}
+
+	return ret, nil
+}
+
+// recordBeaconEntry records the beacon entry that beacon randomness for the
+// round was drawn from, looking it up the same way the chain store does. The
+// entry is checked against the randomness returned by the node.
+func (r *RecordingRand) recordBeaconEntry(ctx context.Context, pers crypto.DomainSeparationTag, round abi.ChainEpoch, entropy []byte, randomness []byte) error {
+	r.lk.Lock()
+	for _, m := range r.recorded {
+		if m.On.Kind == RandomnessBeaconEntry && m.On.Epoch == int64(round) {
+			r.lk.Unlock()
+			return nil
+		}
+	}
+	r.lk.Unlock()
+
+	searchHeight := round
+	if searchHeight < 0 {
+		searchHeight = 0
+	}
+
+	ts, err := r.api.ChainGetTipSetByHeight(ctx, searchHeight, r.head)
+	if err != nil {
+		return err
+	}
+
+	var entry *types.BeaconEntry
+	for i := 0; i < 20; i++ {
+		if be := ts.Blocks()[0].BeaconEntries; len(be) > 0 {
+			entry = &be[len(be)-1]
+			break
+		}
+		if ts.Height() == 0 {
+			break
+		}
+		if ts, err = r.api.ChainGetTipSet(ctx, ts.Parents()); err != nil {
+			return fmt.Errorf("failed to load parents when searching back for latest beacon entry: %w", err)
+		}
+	}
+	if entry == nil {
+		return fmt.Errorf("no beacon entry found")
+	}
+
+	derived, err := store.DrawRandomness(entry.Data, pers, round, entropy)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(derived, randomness) {
+		return fmt.Errorf("randomness drawn from beacon entry %d doesn't match the node's", entry.Round)
+	}
+
+	r.reporter.Logf("recorded beacon entry for: epoch=%d, drand round=%d", round, entry.Round)
+
+	match := schema.RandomnessMatch{
+		On: schema.RandomnessRule{
+			Kind:  RandomnessBeaconEntry,
+			Epoch: int64(round),
+		},
+		Return: entry.Data,
+	}
+	r.lk.Lock()
+	r.recorded = append(r.recorded, match)
+	r.lk.Unlock()
+
+	return nil
 }
 
 func (r *RecordingRand) Recorded() schema.Randomness {
@@ -9,6 +9,7 @@ import (
 
 	"github.com/filecoin-project/test-vectors/schema"
 
+	"github.com/filecoin-project/lotus/chain/store"
 	"github.com/filecoin-project/lotus/chain/vm"
 )
 
@@ -73,7 +74,15 @@ func (r *ReplayingRand) GetBeaconRandomness(ctx context.Context, pers crypto.Dom
 		return ret, nil
 	}
 
+	if entry, ok := r.match(schema.RandomnessRule{Kind: RandomnessBeaconEntry, Epoch: int64(round)}); ok {
+		ret, err := store.DrawRandomness(entry, pers, round, entropy)
+		if err != nil {
+			return nil, err
+		}
+		r.reporter.Logf("returning beacon randomness drawn from saved beacon entry: dst=%d, epoch=%d, entropy=%x, result=%x", pers, round, entropy, ret)
+		return ret, nil
+	}
+
 	r.reporter.Logf("returning fallback beacon randomness: dst=%d, epoch=%d, entropy=%x", pers, round, entropy)
 	return r.fallback.GetBeaconRandomness(ctx, pers, round, entropy)
-
 }
@@ -0,0 +1,45 @@
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/chain/store"
+)
+
+func TestReplayBeaconEntry(t *testing.T) {
+	entry := []byte("beacon entry data")
+	r := NewReplayingRand(t, schema.Randomness{{
+		On:     schema.RandomnessRule{Kind: RandomnessBeaconEntry, Epoch: 100},
+		Return: entry,
+	}})
+
+	pers := crypto.DomainSeparationTag_SealRandomness
+	entropy := []byte("entropy")
+
+	expected, err := store.DrawRandomness(entry, pers, 100, entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ret, err := r.GetBeaconRandomness(context.Background(), pers, 100, entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(ret, expected) {
+		t.Fatalf("expected randomness drawn from the beacon entry, got %x", ret)
+	}
+
+	// no entry for this epoch; falls back to fixed randomness.
+	ret, err = r.GetBeaconRandomness(context.Background(), pers, 101, entropy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(ret, expected) {
+		t.Fatal("expected fallback randomness for an epoch without a beacon entry")
+	}
+}
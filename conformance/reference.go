@@ -0,0 +1,19 @@
+package conformance
+
+import (
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+// SelectorStateReference is the selector key marking a reference vector: a
+// vector that embeds no CAR, and only references its pre-state by root. The
+// state is fetched lazily at execution time through FallbackBlockstoreGetter,
+// which makes reference vectors practical for tipsets touching too much state
+// to embed. The value is the name of the network the state comes from.
+const SelectorStateReference = "state_reference"
+
+// ReferenceNetwork returns the name of the network the state of a reference
+// vector comes from, and whether the vector is a reference vector.
+func ReferenceNetwork(vector *schema.TestVector) (string, bool) {
+	ntwk, ok := vector.Selector[SelectorStateReference]
+	return ntwk, ok
+}
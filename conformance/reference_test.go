@@ -0,0 +1,59 @@
+package conformance
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+type mapObjGetter map[cid.Cid][]byte
+
+func (m mapObjGetter) ChainReadObj(_ context.Context, c cid.Cid) ([]byte, error) {
+	b, ok := m[c]
+	if !ok {
+		return nil, fmt.Errorf("not found: %s", c)
+	}
+	return b, nil
+}
+
+func TestReferenceVectorBlockstore(t *testing.T) {
+	prev := FallbackBlockstoreGetter
+	defer func() { FallbackBlockstoreGetter = prev }()
+
+	vector := &schema.TestVector{Selector: schema.Selector{SelectorStateReference: "testnet"}}
+	if ntwk, ok := ReferenceNetwork(vector); !ok || ntwk != "testnet" {
+		t.Fatalf("expected a reference vector of testnet, got %q, %t", ntwk, ok)
+	}
+
+	FallbackBlockstoreGetter = nil
+	if _, err := LoadBlockstore(vector.CAR); err == nil {
+		t.Fatal("expected an error loading a vector without state or fallback")
+	}
+
+	blk := blocks.NewBlock([]byte("state"))
+	FallbackBlockstoreGetter = mapObjGetter{blk.Cid(): blk.RawData()}
+
+	bs, err := LoadBlockstore(vector.CAR)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := bs.Get(blk.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got.RawData()) != "state" {
+		t.Fatalf("unexpected block data: %q", got.RawData())
+	}
+
+	// fetched state is kept
+	if has, err := bs.(*blockstore.FallbackStore).Blockstore.Has(blk.Cid()); err != nil || !has {
+		t.Fatalf("expected the fetched block to be kept: %t, %v", has, err)
+	}
+}
@@ -2,7 +2,6 @@ package conformance
 
 import (
 	"bytes"
-	"compress/gzip"
 	"context"
 	"encoding/base64"
 	"fmt"
@@ -14,6 +13,7 @@ import (
 	"github.com/fatih/color"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/go-state-types/exitcode"
+	"github.com/filecoin-project/go-state-types/network"
 	"github.com/hashicorp/go-multierror"
 	blocks "github.com/ipfs/go-block-format"
 	"github.com/ipfs/go-blockservice"
@@ -39,6 +39,14 @@ var FallbackBlockstoreGetter interface {
 	ChainReadObj(context.Context, cid.Cid) ([]byte, error)
 }
 
+// DriverSyscalls, when not nil, replaces the syscalls implementation used by
+// the drivers that execute vectors. See DriverOpts.Syscalls.
+var DriverSyscalls vm.SyscallBuilder
+
+// DriverNetworkVersion, when not nil, pins the network version vectors are
+// executed at. See DriverOpts.NetworkVersion.
+var DriverNetworkVersion *network.Version
+
 var TipsetVectorOpts struct {
 	// PipelineBaseFee pipelines the basefee in multi-tipset vectors from one
 	// tipset to another. Basefees in the vector are ignored, except for that of
@@ -48,6 +56,13 @@ var TipsetVectorOpts struct {
 	// OnTipsetApplied contains callback functions called after a tipset has been
 	// applied.
 	OnTipsetApplied []func(bs blockstore.Blockstore, params *ExecuteTipsetParams, res *ExecuteTipsetResult)
+
+	// DeterminismRuns, when greater than zero, re-executes every tipset that
+	// many more times, concurrently, and fails the vector if any run produces
+	// results that differ from the serial execution. Lotus applies messages
+	// serially, so this catches nondeterminism caused by shared state or map
+	// iteration order rather than by parallel application.
+	DeterminismRuns int
 }
 
 // ExecuteMessageVector executes a message-class test vector.
@@ -63,9 +78,17 @@ func ExecuteMessageVector(r Reporter, vector *schema.TestVector, variant *schema
 	if err != nil {
 		r.Fatalf("failed to load the vector CAR: %w", err)
 	}
+	defer func() {
+		if serr := checkStrictCAR(r, bs); serr != nil {
+			err = multierror.Append(err, serr)
+		}
+	}()
+
+	// Record the pre-state read during execution, if a witness is wanted.
+	bs = withWitness(bs)
 
 	// Create a new Driver.
-	driver := NewDriver(ctx, vector.Selector, DriverOpts{DisableVMFlush: true})
+	driver := NewDriver(ctx, vector.Selector, DriverOpts{DisableVMFlush: true, Syscalls: DriverSyscalls, NetworkVersion: DriverNetworkVersion})
 
 	// Apply every message.
 	for i, m := range vector.ApplyMessages {
@@ -97,14 +120,21 @@ func ExecuteMessageVector(r Reporter, vector *schema.TestVector, variant *schema
 		AssertMsgResult(r, vector.Post.Receipts[i], ret, strconv.Itoa(i))
 	}
 
+	if werr := emitWitness(r, vector, variant, bs, root); werr != nil {
+		err = multierror.Append(err, werr)
+	}
+
 	// Once all messages are applied, assert that the final state root matches
 	// the expected postcondition root.
 	if expected, actual := vector.Post.StateTree.RootCID, root; expected != actual {
 		ierr := fmt.Errorf("wrong post root cid; expected %v, but got %v", expected, actual)
 		r.Errorf(ierr.Error())
 		err = multierror.Append(err, ierr)
+		logStateDiffs(r, bs, expected, actual)
 		diffs = dumpThreeWayStateDiff(r, vector, bs, root)
 	}
+
+	runPostconditionHooks(r, vector, variant, bs, root)
 	return diffs, err
 }
 
@@ -123,9 +153,22 @@ func ExecuteTipsetVector(r Reporter, vector *schema.TestVector, variant *schema.
 		r.Fatalf("failed to load the vector CAR: %w", err)
 		return nil, err
 	}
+	defer func() {
+		if serr := checkStrictCAR(r, bs); serr != nil {
+			err = multierror.Append(err, serr)
+		}
+	}()
+
+	// Record the pre-state read during execution, if a witness is wanted.
+	bs = withWitness(bs)
 
 	// Create a new Driver.
-	driver := NewDriver(ctx, vector.Selector, DriverOpts{})
+	driver := NewDriver(ctx, vector.Selector, DriverOpts{Syscalls: DriverSyscalls, NetworkVersion: DriverNetworkVersion})
+
+	origins, oerr := DecodeReceiptOrigins(vector.Meta)
+	if oerr != nil {
+		r.Logf("ignoring receipt origins: %s", oerr)
+	}
 
 	// Apply every tipset.
 	var receiptsIdx int
@@ -146,13 +189,25 @@ func ExecuteTipsetVector(r Reporter, vector *schema.TestVector, variant *schema.
 			return nil, err
 		}
 
+		if n := TipsetVectorOpts.DeterminismRuns; n > 0 {
+			if derr := driver.checkTipsetDeterminism(bs, params, ret, n); derr != nil {
+				ierr := fmt.Errorf("tipset %d is not deterministic: %w", i, derr)
+				r.Errorf(ierr.Error())
+				err = multierror.Append(err, ierr)
+			}
+		}
+
 		// invoke callbacks.
 		for _, cb := range TipsetVectorOpts.OnTipsetApplied {
 			cb(bs, &params, ret)
 		}
 
 		for j, v := range ret.AppliedResults {
-			AssertMsgResult(r, vector.Post.Receipts[receiptsIdx], v, fmt.Sprintf("%d of tipset %d", j, i))
+			label := fmt.Sprintf("%d of tipset %d", j, i)
+			if o := origins[i]; j < len(o) {
+				label += fmt.Sprintf(" (message %s)", o[j])
+			}
+			AssertMsgResult(r, vector.Post.Receipts[receiptsIdx], v, label)
 			receiptsIdx++
 		}
 
@@ -167,14 +222,21 @@ func ExecuteTipsetVector(r Reporter, vector *schema.TestVector, variant *schema.
 		root = ret.PostStateRoot
 	}
 
+	if werr := emitWitness(r, vector, variant, bs, root); werr != nil {
+		err = multierror.Append(err, werr)
+	}
+
 	// Once all messages are applied, assert that the final state root matches
 	// the expected postcondition root.
 	if expected, actual := vector.Post.StateTree.RootCID, root; expected != actual {
 		ierr := fmt.Errorf("wrong post root cid; expected %v, but got %v", expected, actual)
 		r.Errorf(ierr.Error())
 		err = multierror.Append(err, ierr)
+		logStateDiffs(r, bs, expected, actual)
 		diffs = dumpThreeWayStateDiff(r, vector, bs, root)
 	}
+
+	runPostconditionHooks(r, vector, variant, bs, root)
 	return diffs, err
 }
 
@@ -305,14 +367,28 @@ func writeStateToTempCAR(bs blockstore.Blockstore, roots ...cid.Cid) (string, er
 	return tmp.Name(), nil
 }
 
+// LoadBlockstore loads the CAR embedded in a vector into a new temporary
+// blockstore. Reference vectors embed no CAR; their state is resolved through
+// FallbackBlockstoreGetter, which must be set. With StrictCAR, the blockstore
+// records reads of blocks missing from the CAR.
 func LoadBlockstore(vectorCAR schema.Base64EncodedBytes) (blockstore.Blockstore, error) {
 	bs := blockstore.Blockstore(blockstore.NewTemporary())
 
-	// Read the base64-encoded CAR from the vector, and inflate the gzip.
+	if len(vectorCAR) == 0 {
+		if StrictCAR {
+			return nil, fmt.Errorf("vector embeds no state (reference vector?), which can't be executed with strict CAR checks")
+		}
+		if FallbackBlockstoreGetter == nil {
+			return nil, fmt.Errorf("vector embeds no state (reference vector?), and no fallback blockstore is set to fetch it from")
+		}
+		return withFallback(bs), nil
+	}
+
+	// Read the base64-encoded CAR from the vector, and inflate it.
 	buf := bytes.NewReader(vectorCAR)
-	r, err := gzip.NewReader(buf)
+	r, err := NewCARReader(buf)
 	if err != nil {
-		return nil, fmt.Errorf("failed to inflate gzipped CAR: %s", err)
+		return nil, fmt.Errorf("failed to inflate CAR: %s", err)
 	}
 	defer r.Close() // nolint
 
@@ -322,17 +398,26 @@ func LoadBlockstore(vectorCAR schema.Base64EncodedBytes) (blockstore.Blockstore,
 		return nil, fmt.Errorf("failed to load state tree car from test vector: %s", err)
 	}
 
+	if StrictCAR {
+		bs = newStrictStore(bs)
+	}
 	if FallbackBlockstoreGetter != nil {
-		fbs := &blockstore.FallbackStore{Blockstore: bs}
-		fbs.SetFallback(func(ctx context.Context, c cid.Cid) (blocks.Block, error) {
-			b, err := FallbackBlockstoreGetter.ChainReadObj(ctx, c)
-			if err != nil {
-				return nil, err
-			}
-			return blocks.NewBlockWithCid(b, c)
-		})
-		bs = fbs
+		bs = withFallback(bs)
 	}
 
 	return bs, nil
 }
+
+// withFallback wraps the blockstore so that blocks it doesn't have are read
+// from FallbackBlockstoreGetter, and kept.
+func withFallback(bs blockstore.Blockstore) blockstore.Blockstore {
+	fbs := &blockstore.FallbackStore{Blockstore: bs}
+	fbs.SetFallback(func(ctx context.Context, c cid.Cid) (blocks.Block, error) {
+		b, err := FallbackBlockstoreGetter.ChainReadObj(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return blocks.NewBlockWithCid(b, c)
+	})
+	return fbs
+}
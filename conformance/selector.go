@@ -0,0 +1,88 @@
+package conformance
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+// SelectorChaosActor is the selector key requesting the chaos actor to be
+// deployed while executing the vector; "true" or "false".
+const SelectorChaosActor = "chaos_actor"
+
+// SelectorMaxProtocolVersion is the selector key holding the codename of the
+// last protocol version the vector applies to. The counterpart of
+// schema.SelectorMinProtocolVersion.
+const SelectorMaxProtocolVersion = "max_protocol_version"
+
+// selectorValidators holds the selector keys this implementation understands,
+// with a check of their values, if any. Vectors with other keys are skipped:
+// they may require behaviour that isn't implemented here.
+var selectorValidators = map[string]func(string) string{
+	schema.SelectorMinProtocolVersion: func(v string) string {
+		// all known versions are supported; unknown ones are likely newer.
+		if _, ok := protocolCodenameIndex(v); !ok {
+			return fmt.Sprintf("requires protocol version %q, unknown to this implementation", v)
+		}
+		return ""
+	},
+	SelectorMaxProtocolVersion: func(v string) string {
+		if _, ok := protocolCodenameIndex(v); !ok {
+			return fmt.Sprintf("applies up to protocol version %q, unknown to this implementation", v)
+		}
+		return ""
+	},
+	SelectorChaosActor: func(v string) string {
+		if v != "true" && v != "false" {
+			return fmt.Sprintf("invalid %s selector %q", SelectorChaosActor, v)
+		}
+		return ""
+	},
+
+	// values checked by the driver when executing the vector.
+	SelectorVerifyActorSignatures: nil,
+	SelectorCron:                  nil,
+	SelectorGenesisTimestamp:      nil,
+	SelectorStateReference:        nil,
+}
+
+// SkipReason evaluates the selector and hints of a vector against what this
+// implementation supports, and returns why the vector must be skipped, or an
+// empty string if it can be executed.
+func SkipReason(vector *schema.TestVector) string {
+	for _, h := range vector.Hints {
+		if h == schema.HintIncorrect {
+			return "vector marked as incorrect"
+		}
+	}
+
+	minv, maxv := vector.Selector[schema.SelectorMinProtocolVersion], vector.Selector[SelectorMaxProtocolVersion]
+	if mini, ok := protocolCodenameIndex(minv); ok {
+		if maxi, ok := protocolCodenameIndex(maxv); ok && mini > maxi {
+			return fmt.Sprintf("empty protocol version range %s..%s", minv, maxv)
+		}
+	}
+
+	// evaluate keys in order, for stable reasons.
+	keys := make([]string, 0, len(vector.Selector))
+	for k := range vector.Selector {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		validate, known := selectorValidators[k]
+		if !known {
+			return fmt.Sprintf("unsupported selector %s=%q", k, vector.Selector[k])
+		}
+		if validate == nil {
+			continue
+		}
+		if reason := validate(vector.Selector[k]); reason != "" {
+			return reason
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,37 @@
+package conformance
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/filecoin-project/test-vectors/schema"
+)
+
+func TestSkipReason(t *testing.T) {
+	cases := []struct {
+		selector schema.Selector
+		hints    []string
+		skip     string // substring of the reason, empty if not skipped
+	}{
+		{selector: nil},
+		{selector: schema.Selector{schema.SelectorMinProtocolVersion: "genesis", SelectorChaosActor: "true"}},
+		{selector: schema.Selector{schema.SelectorMinProtocolVersion: "actorsv2", SelectorMaxProtocolVersion: "liftoff"}},
+		{selector: schema.Selector{SelectorVerifyActorSignatures: "true", SelectorCron: "false"}},
+		{selector: schema.Selector{schema.SelectorMinProtocolVersion: "hyperdrive"}, skip: "unknown to this implementation"},
+		{selector: schema.Selector{SelectorMaxProtocolVersion: "hyperdrive"}, skip: "unknown to this implementation"},
+		{selector: schema.Selector{schema.SelectorMinProtocolVersion: "liftoff", SelectorMaxProtocolVersion: "breeze"}, skip: "empty protocol version range"},
+		{selector: schema.Selector{SelectorChaosActor: "yes"}, skip: "invalid chaos_actor selector"},
+		{selector: schema.Selector{"puppet_actor": "true"}, skip: "unsupported selector puppet_actor"},
+		{hints: []string{schema.HintIncorrect}, skip: "marked as incorrect"},
+	}
+
+	for _, c := range cases {
+		reason := SkipReason(&schema.TestVector{Selector: c.selector, Hints: c.hints})
+		switch {
+		case c.skip == "" && reason != "":
+			t.Errorf("%v: expected vector to run, skipped: %s", c.selector, reason)
+		case c.skip != "" && !strings.Contains(reason, c.skip):
+			t.Errorf("%v: expected vector to be skipped with %q, got %q", c.selector, c.skip, reason)
+		}
+	}
+}
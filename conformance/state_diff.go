@@ -0,0 +1,423 @@
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/ipfs/go-cid"
+	cbornode "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+// MaxStateDiffs is the number of differences LocateStateDiffs reports by
+// default. Diverging state trees can differ almost everywhere, and it's the
+// first few differences that point at the culprit.
+const MaxStateDiffs = 10
+
+// errEnoughDiffs stops the walk once enough differences have been reported.
+var errEnoughDiffs = errors.New("enough differences found")
+
+// LocateStateDiffs compares two state trees and describes where they differ,
+// by actor address and by path into the actor state, reporting at most max
+// differences.
+//
+// State trees are hash-consed: equal subtrees have equal CIDs. The walk only
+// descends into links whose CIDs differ, so it touches the branches leading to
+// the differences and nothing else, however large the trees are.
+func LocateStateDiffs(bs blockstore.Blockstore, expected, actual cid.Cid, max int) ([]string, error) {
+	d := &stateDiffer{bs: bs, max: max}
+	if err := d.diffStateRoots(expected, actual); err != nil && err != errEnoughDiffs {
+		return d.out, err
+	}
+	return d.out, nil
+}
+
+// logStateDiffs logs where the expected and actual state trees differ.
+func logStateDiffs(r Reporter, bs blockstore.Blockstore, expected, actual cid.Cid) {
+	diffs, err := LocateStateDiffs(bs, expected, actual, MaxStateDiffs)
+	for _, d := range diffs {
+		r.Logf("state differs: %s", d)
+	}
+	if err != nil {
+		r.Logf("failed to locate all state differences: %s", err)
+	}
+}
+
+type stateDiffer struct {
+	bs  blockstore.Blockstore
+	max int
+	out []string
+}
+
+func (d *stateDiffer) report(format string, args ...interface{}) error {
+	d.out = append(d.out, fmt.Sprintf(format, args...))
+	if len(d.out) >= d.max {
+		return errEnoughDiffs
+	}
+	return nil
+}
+
+// load decodes a block into generic values: links decode to cid.Cid, lists to
+// []interface{} and maps to map[string]interface{}.
+func (d *stateDiffer) load(c cid.Cid) (interface{}, error) {
+	blk, err := d.bs.Get(c)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", c, err)
+	}
+	var out interface{}
+	if err := cbornode.DecodeInto(blk.RawData(), &out); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", c, err)
+	}
+	return out, nil
+}
+
+func (d *stateDiffer) diffStateRoots(expected, actual cid.Cid) error {
+	if expected == actual {
+		return nil
+	}
+
+	en, err := d.load(expected)
+	if err != nil {
+		return err
+	}
+	an, err := d.load(actual)
+	if err != nil {
+		return err
+	}
+
+	eActors, eInfo := stateRootParts(expected, en)
+	aActors, aInfo := stateRootParts(actual, an)
+	if eInfo != aInfo {
+		if err := d.report("state root info: expected %s, got %s", eInfo, aInfo); err != nil {
+			return err
+		}
+	}
+	return d.diffHamt(eActors, aActors, d.diffActor)
+}
+
+// stateRootParts returns the actors HAMT and the info object of a state root.
+// Version 0 state roots are the actors HAMT itself, and have no info.
+func stateRootParts(root cid.Cid, node interface{}) (actors cid.Cid, info cid.Cid) {
+	if l, ok := node.([]interface{}); ok && len(l) == 3 {
+		actors, aok := l[1].(cid.Cid)
+		info, iok := l[2].(cid.Cid)
+		if aok && iok {
+			return actors, info
+		}
+	}
+	return root, cid.Undef
+}
+
+// diffHamt compares two HAMTs, calling onDiff for every key whose values
+// differ; the value is nil on the side that doesn't have the key.
+func (d *stateDiffer) diffHamt(expected, actual cid.Cid, onDiff func(key string, expected, actual interface{}) error) error {
+	if expected == actual {
+		return nil
+	}
+
+	en, err := d.load(expected)
+	if err != nil {
+		return err
+	}
+	an, err := d.load(actual)
+	if err != nil {
+		return err
+	}
+	eptrs, err := hamtPointers(expected, en)
+	if err != nil {
+		return err
+	}
+	aptrs, err := hamtPointers(actual, an)
+	if err != nil {
+		return err
+	}
+
+	for _, idx := range unionKeys(eptrs, aptrs) {
+		el, ekvs, err := hamtPointer(eptrs[idx])
+		if err != nil {
+			return fmt.Errorf("node %s: %w", expected, err)
+		}
+		al, akvs, err := hamtPointer(aptrs[idx])
+		if err != nil {
+			return fmt.Errorf("node %s: %w", actual, err)
+		}
+
+		if el.Defined() && al.Defined() {
+			if err := d.diffHamt(el, al, onDiff); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// a bucket on at least one side; the subtrees are small, compare
+		// their entries.
+		eents, err := d.hamtEntries(el, ekvs)
+		if err != nil {
+			return err
+		}
+		aents, err := d.hamtEntries(al, akvs)
+		if err != nil {
+			return err
+		}
+		for _, k := range unionKeys(eents, aents) {
+			ev, av := eents[k], aents[k]
+			if reflect.DeepEqual(ev, av) {
+				continue
+			}
+			if err := onDiff(k, ev, av); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hamtPointers maps the bit indexes set in a HAMT node's bitfield to the
+// node's pointers.
+func hamtPointers(c cid.Cid, node interface{}) (map[string]interface{}, error) {
+	l, ok := node.([]interface{})
+	if !ok || len(l) != 2 {
+		return nil, fmt.Errorf("node %s is not a HAMT node", c)
+	}
+	bf, ok := l[0].([]byte)
+	if !ok {
+		return nil, fmt.Errorf("node %s has no HAMT bitfield", c)
+	}
+	ptrs, ok := l[1].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("node %s has no HAMT pointers", c)
+	}
+
+	// the bitfield is a big-endian integer; pointers are ordered by bit index.
+	out := map[string]interface{}{}
+	for bit := 0; bit < len(bf)*8; bit++ {
+		if bf[len(bf)-1-bit/8]&(1<<(uint(bit)%8)) == 0 {
+			continue
+		}
+		if len(out) == len(ptrs) {
+			return nil, fmt.Errorf("node %s has fewer pointers than bits set", c)
+		}
+		out[fmt.Sprintf("%03d", bit)] = ptrs[len(out)]
+	}
+	return out, nil
+}
+
+// hamtPointer decodes a HAMT pointer into a link or a bucket of key-value
+// pairs. A nil pointer is an empty bucket.
+func hamtPointer(p interface{}) (cid.Cid, []interface{}, error) {
+	switch v := p.(type) {
+	case nil:
+		return cid.Undef, nil, nil
+	case cid.Cid:
+		return v, nil, nil
+	case []interface{}:
+		return cid.Undef, v, nil
+	case map[string]interface{}:
+		// older HAMTs tag pointers with "0" for links and "1" for buckets.
+		if l, ok := v["0"].(cid.Cid); ok {
+			return l, nil, nil
+		}
+		if kvs, ok := v["1"].([]interface{}); ok {
+			return cid.Undef, kvs, nil
+		}
+	}
+	return cid.Undef, nil, fmt.Errorf("unexpected HAMT pointer %v", p)
+}
+
+// hamtEntries collects the entries under a HAMT pointer.
+func (d *stateDiffer) hamtEntries(link cid.Cid, kvs []interface{}) (map[string]interface{}, error) {
+	out := map[string]interface{}{}
+	if !link.Defined() {
+		for _, kv := range kvs {
+			l, ok := kv.([]interface{})
+			if !ok || len(l) != 2 {
+				return nil, fmt.Errorf("unexpected HAMT entry %v", kv)
+			}
+			k, ok := l[0].([]byte)
+			if !ok {
+				return nil, fmt.Errorf("unexpected HAMT key %v", l[0])
+			}
+			out[string(k)] = l[1]
+		}
+		return out, nil
+	}
+
+	node, err := d.load(link)
+	if err != nil {
+		return nil, err
+	}
+	ptrs, err := hamtPointers(link, node)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range ptrs {
+		l, kvs, err := hamtPointer(p)
+		if err != nil {
+			return nil, fmt.Errorf("node %s: %w", link, err)
+		}
+		ents, err := d.hamtEntries(l, kvs)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range ents {
+			out[k] = v
+		}
+	}
+	return out, nil
+}
+
+// actorFields names the fields of an actor entry in the state tree.
+var actorFields = []string{"code", "head", "nonce", "balance"}
+
+func (d *stateDiffer) diffActor(key string, expected, actual interface{}) error {
+	name := fmt.Sprintf("%x", key)
+	if addr, err := address.NewFromBytes([]byte(key)); err == nil {
+		name = addr.String()
+	}
+
+	switch {
+	case expected == nil:
+		return d.report("actor %s: unexpected actor", name)
+	case actual == nil:
+		return d.report("actor %s: missing actor", name)
+	}
+
+	ea, eok := expected.([]interface{})
+	aa, aok := actual.([]interface{})
+	if !eok || !aok || len(ea) != len(actorFields) || len(aa) != len(actorFields) {
+		return d.report("actor %s: expected %s, got %s", name, formatStateValue(expected), formatStateValue(actual))
+	}
+
+	for i, field := range actorFields {
+		if reflect.DeepEqual(ea[i], aa[i]) {
+			continue
+		}
+
+		var err error
+		switch field {
+		case "head":
+			eh, eok := ea[i].(cid.Cid)
+			ah, aok := aa[i].(cid.Cid)
+			if eok && aok {
+				err = d.diffNode("actor "+name+": state", eh, ah)
+				break
+			}
+			err = d.report("actor %s: head: expected %s, got %s", name, formatStateValue(ea[i]), formatStateValue(aa[i]))
+		case "balance":
+			eb, _ := ea[i].([]byte)
+			ab, _ := aa[i].([]byte)
+			err = d.report("actor %s: balance: expected %s, got %s", name, formatBalance(eb), formatBalance(ab))
+		default:
+			err = d.report("actor %s: %s: expected %s, got %s", name, field, formatStateValue(ea[i]), formatStateValue(aa[i]))
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffNode compares the objects behind two links, descending into the
+// fields and links that differ.
+func (d *stateDiffer) diffNode(path string, expected, actual cid.Cid) error {
+	if expected == actual {
+		return nil
+	}
+	en, err := d.load(expected)
+	if err != nil {
+		return err
+	}
+	an, err := d.load(actual)
+	if err != nil {
+		return err
+	}
+	return d.diffValue(path, en, an)
+}
+
+func (d *stateDiffer) diffValue(path string, expected, actual interface{}) error {
+	switch ev := expected.(type) {
+	case cid.Cid:
+		if av, ok := actual.(cid.Cid); ok {
+			return d.diffNode(path, ev, av)
+		}
+	case []interface{}:
+		if av, ok := actual.([]interface{}); ok && len(ev) == len(av) {
+			for i := range ev {
+				if err := d.diffValue(fmt.Sprintf("%s/%d", path, i), ev[i], av[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	case map[string]interface{}:
+		if av, ok := actual.(map[string]interface{}); ok {
+			for _, k := range unionKeys(ev, av) {
+				e, eok := ev[k]
+				a, aok := av[k]
+				var err error
+				switch {
+				case !eok:
+					err = d.report("%s/%s: unexpected field", path, k)
+				case !aok:
+					err = d.report("%s/%s: missing field", path, k)
+				default:
+					err = d.diffValue(path+"/"+k, e, a)
+				}
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+
+	if reflect.DeepEqual(expected, actual) {
+		return nil
+	}
+	return d.report("%s: expected %s, got %s", path, formatStateValue(expected), formatStateValue(actual))
+}
+
+func formatBalance(b []byte) string {
+	bi, err := big.FromBytes(b)
+	if err != nil {
+		return formatStateValue(b)
+	}
+	return bi.String()
+}
+
+func formatStateValue(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "nothing"
+	case []byte:
+		if len(v) > 32 {
+			return fmt.Sprintf("0x%x... (%d bytes)", v[:32], len(v))
+		}
+		return fmt.Sprintf("0x%x", v)
+	case []interface{}:
+		return fmt.Sprintf("list of %d elements", len(v))
+	case map[string]interface{}:
+		return fmt.Sprintf("map of %d entries", len(v))
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+func unionKeys(a, b map[string]interface{}) []string {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
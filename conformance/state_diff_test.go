@@ -0,0 +1,109 @@
+package conformance
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	cbor "github.com/ipfs/go-ipld-cbor"
+
+	"github.com/filecoin-project/go-address"
+	builtin2 "github.com/filecoin-project/specs-actors/v2/actors/builtin"
+
+	"github.com/filecoin-project/lotus/chain/state"
+	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+func TestLocateStateDiffs(t *testing.T) {
+	ctx := context.Background()
+	bs := blockstore.NewTemporary()
+	cst := cbor.NewCborStore(bs)
+
+	head := func(v uint64) cid.Cid {
+		c, err := cst.Put(ctx, []uint64{v, v + 1})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	// enough actors for the HAMT to grow links below the root.
+	build := func(modify func(addr address.Address, act *types.Actor)) cid.Cid {
+		st, err := state.NewStateTree(cst, types.StateTreeVersion1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := uint64(100); i < 1100; i++ {
+			addr, err := address.NewIDAddress(i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			act := &types.Actor{
+				Code:    builtin2.AccountActorCodeID,
+				Head:    head(i),
+				Nonce:   i,
+				Balance: types.NewInt(i),
+			}
+			modify(addr, act)
+			if err := st.SetActor(addr, act); err != nil {
+				t.Fatal(err)
+			}
+		}
+		root, err := st.Flush(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return root
+	}
+
+	expected := build(func(address.Address, *types.Actor) {})
+	actual := build(func(addr address.Address, act *types.Actor) {
+		switch addr.String() {
+		case "t0200":
+			act.Balance = types.NewInt(1)
+		case "t0700":
+			act.Head = head(1)
+		}
+	})
+
+	diffs, err := LocateStateDiffs(bs, expected, actual, MaxStateDiffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 3 {
+		t.Fatalf("expected 3 differences, got %d: %v", len(diffs), diffs)
+	}
+
+	want := []string{
+		"actor t0200: balance: expected 200, got 1",
+		"actor t0700: state/0: expected 700, got 1",
+		"actor t0700: state/1: expected 701, got 2",
+	}
+	for _, w := range want {
+		var found bool
+		for _, d := range diffs {
+			found = found || strings.Contains(d, w)
+		}
+		if !found {
+			t.Errorf("missing difference %q in %v", w, diffs)
+		}
+	}
+
+	diffs, err = LocateStateDiffs(bs, expected, actual, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 1 {
+		t.Fatalf("expected the walk to stop after 1 difference, got %v", diffs)
+	}
+
+	diffs, err = LocateStateDiffs(bs, expected, expected, MaxStateDiffs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("expected no differences, got %v", diffs)
+	}
+}
@@ -0,0 +1,112 @@
+package conformance
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+// StrictCAR, when true, fails vectors that read any block missing from their
+// CAR, even if FallbackBlockstoreGetter resolves it; this catches incomplete
+// extractions early. When a fallback is set, execution carries on with the
+// fetched blocks, so that all missing blocks are reported at once. Reference
+// vectors embed no CAR, and can't be executed in this mode.
+var StrictCAR bool
+
+// strictStore records the blocks read from it that it doesn't have.
+type strictStore struct {
+	blockstore.Blockstore
+
+	lk     sync.Mutex
+	missed map[cid.Cid]struct{}
+}
+
+func newStrictStore(bs blockstore.Blockstore) *strictStore {
+	return &strictStore{
+		Blockstore: bs,
+		missed:     map[cid.Cid]struct{}{},
+	}
+}
+
+func (s *strictStore) Get(c cid.Cid) (blocks.Block, error) {
+	b, err := s.Blockstore.Get(c)
+	s.record(c, err)
+	return b, err
+}
+
+func (s *strictStore) GetSize(c cid.Cid) (int, error) {
+	sz, err := s.Blockstore.GetSize(c)
+	s.record(c, err)
+	return sz, err
+}
+
+func (s *strictStore) View(c cid.Cid, callback func([]byte) error) error {
+	v, ok := s.Blockstore.(blockstore.Viewer)
+	if !ok {
+		b, err := s.Get(c)
+		if err != nil {
+			return err
+		}
+		return callback(b.RawData())
+	}
+
+	err := v.View(c, callback)
+	s.record(c, err)
+	return err
+}
+
+func (s *strictStore) record(c cid.Cid, err error) {
+	if err != blockstore.ErrNotFound {
+		return
+	}
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	s.missed[c] = struct{}{}
+}
+
+func (s *strictStore) missing() []cid.Cid {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+
+	out := make([]cid.Cid, 0, len(s.missed))
+	for c := range s.missed {
+		out = append(out, c)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].KeyString() < out[j].KeyString()
+	})
+	return out
+}
+
+// checkStrictCAR reports the blocks read during the execution of a vector
+// which are missing from its CAR, when executing with StrictCAR.
+func checkStrictCAR(r Reporter, bs blockstore.Blockstore) error {
+	if ws, ok := bs.(*witnessStore); ok {
+		bs = ws.Blockstore
+	}
+	if fbs, ok := bs.(*blockstore.FallbackStore); ok {
+		bs = fbs.Blockstore
+	}
+	ss, ok := bs.(*strictStore)
+	if !ok {
+		return nil
+	}
+
+	missing := ss.missing()
+	if len(missing) == 0 {
+		return nil
+	}
+
+	for _, c := range missing {
+		r.Logf("block missing from the vector CAR: %s", c)
+	}
+	err := fmt.Errorf("strict CAR: %d block(s) read during execution are missing from the vector CAR", len(missing))
+	r.Errorf(err.Error())
+	return err
+}
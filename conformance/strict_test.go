@@ -0,0 +1,53 @@
+package conformance
+
+import (
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+func TestStrictCARStore(t *testing.T) {
+	prev := FallbackBlockstoreGetter
+	defer func() { FallbackBlockstoreGetter = prev }()
+
+	inCAR := blocks.NewBlock([]byte("in car"))
+	fetched := blocks.NewBlock([]byte("fetched"))
+	FallbackBlockstoreGetter = mapObjGetter{fetched.Cid(): fetched.RawData()}
+
+	car := blockstore.NewTemporary()
+	if err := car.Put(inCAR); err != nil {
+		t.Fatal(err)
+	}
+	bs := withFallback(newStrictStore(car))
+
+	r := new(LogReporter)
+	if err := checkStrictCAR(r, bs); err != nil {
+		t.Fatalf("expected no missing blocks before execution, got %s", err)
+	}
+
+	for _, b := range []blocks.Block{inCAR, fetched, fetched} {
+		if _, err := bs.Get(b.Cid()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the fetched block is missing from the CAR, even if execution could use it
+	missing := bs.(*blockstore.FallbackStore).Blockstore.(*strictStore).missing()
+	if len(missing) != 1 || !missing[0].Equals(fetched.Cid()) {
+		t.Fatalf("expected only %s to be missing, got %v", fetched.Cid(), missing)
+	}
+
+	if err := checkStrictCAR(r, bs); err == nil {
+		t.Fatal("expected strict CAR check to fail")
+	}
+	if !r.Failed() {
+		t.Fatal("expected the reporter to be failed")
+	}
+
+	// without strict mode, nothing is checked
+	if err := checkStrictCAR(r, withFallback(car)); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,58 @@
+package conformance
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/chain/vm"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+
+	runtime2 "github.com/filecoin-project/specs-actors/v2/actors/runtime"
+)
+
+// SelectorVerifyActorSignatures is the selector key controlling verification
+// of the signatures actors check through the VerifySignature syscall when
+// replaying a vector. "false" accepts every signature without verifying it,
+// "true" enforces verification even when the driver was configured to skip it
+// (e.g. tvx exec --skip-sig-verify), for vectors that exercise invalid
+// signatures. When absent, the driver configuration applies. Message
+// signatures aren't covered, vectors carry unsigned messages.
+const SelectorVerifyActorSignatures = "verify_actor_signatures"
+
+// SkipSignatureSyscalls wraps a syscalls implementation, accepting every
+// signature without verifying it. It's useful for faster batch runs of
+// vectors, as long as the vectors don't exercise invalid signatures.
+func SkipSignatureSyscalls(base vm.SyscallBuilder) vm.SyscallBuilder {
+	return func(ctx context.Context, rt *vm.Runtime) runtime2.Syscalls {
+		return &skipSigSyscalls{Syscalls: base(ctx, rt)}
+	}
+}
+
+type skipSigSyscalls struct {
+	runtime2.Syscalls
+}
+
+func (s *skipSigSyscalls) VerifySignature(_ crypto.Signature, _ address.Address, _ []byte) error {
+	return nil
+}
+
+// EnforceSignatureSyscalls wraps a syscalls implementation, verifying
+// signatures with the standard syscalls regardless of what the wrapped
+// implementation does.
+func EnforceSignatureSyscalls(base vm.SyscallBuilder) vm.SyscallBuilder {
+	std := vm.Syscalls(ffiwrapper.ProofVerifier)
+	return func(ctx context.Context, rt *vm.Runtime) runtime2.Syscalls {
+		return &enforceSigSyscalls{Syscalls: base(ctx, rt), std: std(ctx, rt)}
+	}
+}
+
+type enforceSigSyscalls struct {
+	runtime2.Syscalls
+	std runtime2.Syscalls
+}
+
+func (s *enforceSigSyscalls) VerifySignature(sig crypto.Signature, addr address.Address, input []byte) error {
+	return s.std.VerifySignature(sig, addr, input)
+}
@@ -0,0 +1,104 @@
+package conformance
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/raulk/clock"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/stmgr"
+)
+
+// SelectorCron is the selector key recording when cron is ticked while
+// applying the tipsets of a vector; its values are the CronMode constants.
+// When absent, the driver configuration applies.
+const SelectorCron = "cron"
+
+// SelectorGenesisTimestamp is the selector key recording the genesis
+// timestamp (in unix seconds) the clock is pinned to while executing a vector.
+// Epoch e executes at genesis timestamp + e * block delay. When absent, the
+// driver configuration applies.
+const SelectorGenesisTimestamp = "genesis_timestamp"
+
+// CronMode controls when the cron actor is ticked during tipset execution.
+type CronMode string
+
+const (
+	// CronEveryEpoch ticks cron for null rounds, and after the tipset
+	// messages, like the chain does. This is the default.
+	CronEveryEpoch CronMode = "every_epoch"
+	// CronExecEpoch only ticks cron after the tipset messages, skipping the
+	// ticks of null rounds.
+	CronExecEpoch CronMode = "exec_epoch"
+	// CronNever doesn't tick cron at all.
+	CronNever CronMode = "never"
+)
+
+// ParseCronMode parses a cron mode; the empty string is CronEveryEpoch.
+func ParseCronMode(s string) (CronMode, error) {
+	switch m := CronMode(s); m {
+	case "":
+		return CronEveryEpoch, nil
+	case CronEveryEpoch, CronExecEpoch, CronNever:
+		return m, nil
+	default:
+		return "", fmt.Errorf("unknown cron mode %q; expected %s, %s or %s", s, CronEveryEpoch, CronExecEpoch, CronNever)
+	}
+}
+
+// schedule returns the cron schedule for the state manager, nil for the
+// default.
+func (m CronMode) schedule() stmgr.CronSchedule {
+	switch m {
+	case CronExecEpoch:
+		return func(_ abi.ChainEpoch, nullRound bool) bool { return !nullRound }
+	case CronNever:
+		return func(abi.ChainEpoch, bool) bool { return false }
+	default:
+		return nil
+	}
+}
+
+// ExecutionControls returns the selector entries recording the cron mode and
+// genesis timestamp, to be added to vectors executed with them. Defaults are
+// not recorded.
+func ExecutionControls(cron CronMode, genesisTimestamp *uint64) schema.Selector {
+	sel := schema.Selector{}
+	if cron != "" && cron != CronEveryEpoch {
+		sel[SelectorCron] = string(cron)
+	}
+	if genesisTimestamp != nil {
+		sel[SelectorGenesisTimestamp] = strconv.FormatUint(*genesisTimestamp, 10)
+	}
+	return sel
+}
+
+// clockLk serializes executions with a pinned clock, since the clock is
+// global.
+var clockLk sync.Mutex
+
+// pinClock sets the global clock to the time of the epoch, until the returned
+// func is called. Without a genesis timestamp, the clock is left untouched.
+func (d *Driver) pinClock(epoch abi.ChainEpoch) func() {
+	if d.genesisTimestamp == nil {
+		return func() {}
+	}
+
+	clockLk.Lock()
+
+	mock := clock.NewMock()
+	mock.Set(time.Unix(int64(*d.genesisTimestamp)+int64(epoch)*int64(build.BlockDelaySecs), 0))
+
+	prev := build.Clock
+	build.Clock = mock
+	return func() {
+		build.Clock = prev
+		clockLk.Unlock()
+	}
+}
@@ -0,0 +1,57 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/build"
+)
+
+func TestDriverExecutionControls(t *testing.T) {
+	ts := uint64(1598306400)
+
+	sel := ExecutionControls(CronExecEpoch, &ts)
+	if sel[SelectorCron] != "exec_epoch" || sel[SelectorGenesisTimestamp] != "1598306400" {
+		t.Fatalf("unexpected selector: %v", sel)
+	}
+	if sel := ExecutionControls(CronEveryEpoch, nil); len(sel) != 0 {
+		t.Fatalf("defaults shouldn't be recorded: %v", sel)
+	}
+
+	// the vector selector takes precedence over the driver options
+	d := NewDriver(context.Background(), sel, DriverOpts{Cron: CronNever})
+	if d.optsErr != nil {
+		t.Fatal(d.optsErr)
+	}
+	if d.cron != CronExecEpoch {
+		t.Fatalf("expected cron mode %s, got %s", CronExecEpoch, d.cron)
+	}
+	if d.genesisTimestamp == nil || *d.genesisTimestamp != ts {
+		t.Fatalf("expected genesis timestamp %d", ts)
+	}
+
+	sched := d.cron.schedule()
+	if sched(10, true) || !sched(10, false) {
+		t.Fatal("exec_epoch cron should only tick outside of null rounds")
+	}
+
+	prev := build.Clock
+	done := d.pinClock(100)
+	if now, expected := build.Clock.Now(), time.Unix(int64(ts)+100*int64(build.BlockDelaySecs), 0); !now.Equal(expected) {
+		t.Fatalf("expected clock pinned at %s, got %s", expected, now)
+	}
+	done()
+	if build.Clock != prev {
+		t.Fatal("clock wasn't restored")
+	}
+
+	if d := NewDriver(context.Background(), schema.Selector{SelectorCron: "sometimes"}, DriverOpts{}); d.optsErr == nil {
+		t.Fatal("expected an invalid cron mode to be rejected")
+	}
+	if _, err := d.newStateManager(nil); err != nil {
+		t.Fatal(err)
+	}
+}
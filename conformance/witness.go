@@ -0,0 +1,185 @@
+package conformance
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+	carutil "github.com/ipld/go-car/util"
+
+	"github.com/filecoin-project/test-vectors/schema"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+// Witness is the part of the pre-state a vector variant needs to execute: the
+// blocks read during execution that it didn't write itself. An implementation
+// holding only the witness can execute the variant statelessly, and check that
+// it reaches the post-state root.
+type Witness struct {
+	PreRoot  cid.Cid
+	PostRoot cid.Cid
+
+	// Blocks is sorted by CID.
+	Blocks []blocks.Block
+}
+
+// WitnessHook gets the witness of every executed variant, when set. Returning
+// an error fails the vector.
+var WitnessHook func(vector *schema.TestVector, variant *schema.Variant, w *Witness) error
+
+// WriteCAR writes the witness as an uncompressed CAR, rooted at the pre and
+// post state roots. Compressed like a vector CAR, it can replace the CAR of the
+// vector; executing that with StrictCAR checks the witness is complete.
+func (w *Witness) WriteCAR(out io.Writer) error {
+	h := &car.CarHeader{
+		Roots:   []cid.Cid{w.PreRoot, w.PostRoot},
+		Version: 1,
+	}
+	if err := car.WriteHeader(h, out); err != nil {
+		return fmt.Errorf("failed to write witness CAR header: %w", err)
+	}
+	for _, b := range w.Blocks {
+		if err := carutil.LdWrite(out, b.Cid().Bytes(), b.RawData()); err != nil {
+			return fmt.Errorf("failed to write witness block %s: %w", b.Cid(), err)
+		}
+	}
+	return nil
+}
+
+// witnessStore records the blocks read from the pre-state during execution.
+// Blocks are only witnessed if they were read before execution wrote them.
+type witnessStore struct {
+	blockstore.Blockstore
+
+	lk      sync.Mutex
+	read    map[cid.Cid]struct{}
+	written map[cid.Cid]struct{}
+}
+
+// withWitness wraps the blockstore to record the witness, if anyone is
+// interested in it.
+func withWitness(bs blockstore.Blockstore) blockstore.Blockstore {
+	if WitnessHook == nil {
+		return bs
+	}
+	return &witnessStore{
+		Blockstore: bs,
+		read:       map[cid.Cid]struct{}{},
+		written:    map[cid.Cid]struct{}{},
+	}
+}
+
+func (ws *witnessStore) Has(c cid.Cid) (bool, error) {
+	has, err := ws.Blockstore.Has(c)
+	if has {
+		ws.recordRead(c)
+	}
+	return has, err
+}
+
+func (ws *witnessStore) Get(c cid.Cid) (blocks.Block, error) {
+	b, err := ws.Blockstore.Get(c)
+	if err == nil {
+		ws.recordRead(c)
+	}
+	return b, err
+}
+
+func (ws *witnessStore) GetSize(c cid.Cid) (int, error) {
+	sz, err := ws.Blockstore.GetSize(c)
+	if err == nil {
+		ws.recordRead(c)
+	}
+	return sz, err
+}
+
+func (ws *witnessStore) View(c cid.Cid, callback func([]byte) error) error {
+	v, ok := ws.Blockstore.(blockstore.Viewer)
+	if !ok {
+		b, err := ws.Get(c)
+		if err != nil {
+			return err
+		}
+		return callback(b.RawData())
+	}
+
+	err := v.View(c, callback)
+	if err == nil {
+		ws.recordRead(c)
+	}
+	return err
+}
+
+func (ws *witnessStore) Put(b blocks.Block) error {
+	ws.recordWritten(b.Cid())
+	return ws.Blockstore.Put(b)
+}
+
+func (ws *witnessStore) PutMany(bs []blocks.Block) error {
+	for _, b := range bs {
+		ws.recordWritten(b.Cid())
+	}
+	return ws.Blockstore.PutMany(bs)
+}
+
+func (ws *witnessStore) recordRead(c cid.Cid) {
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+	if _, ok := ws.written[c]; !ok {
+		ws.read[c] = struct{}{}
+	}
+}
+
+func (ws *witnessStore) recordWritten(c cid.Cid) {
+	ws.lk.Lock()
+	defer ws.lk.Unlock()
+	ws.written[c] = struct{}{}
+}
+
+// witness returns the witness recorded so far.
+func (ws *witnessStore) witness(preRoot, postRoot cid.Cid) (*Witness, error) {
+	ws.lk.Lock()
+	cids := make([]cid.Cid, 0, len(ws.read))
+	for c := range ws.read {
+		cids = append(cids, c)
+	}
+	ws.lk.Unlock()
+
+	sort.Slice(cids, func(i, j int) bool {
+		return cids[i].KeyString() < cids[j].KeyString()
+	})
+
+	w := &Witness{PreRoot: preRoot, PostRoot: postRoot}
+	for _, c := range cids {
+		b, err := ws.Blockstore.Get(c)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read witnessed block %s: %w", c, err)
+		}
+		w.Blocks = append(w.Blocks, b)
+	}
+	return w, nil
+}
+
+// emitWitness passes the witness of the executed variant to WitnessHook, if
+// the blockstore recorded one.
+func emitWitness(r Reporter, vector *schema.TestVector, variant *schema.Variant, bs blockstore.Blockstore, postRoot cid.Cid) error {
+	ws, ok := bs.(*witnessStore)
+	if !ok {
+		return nil
+	}
+
+	w, err := ws.witness(vector.Pre.StateTree.RootCID, postRoot)
+	if err == nil {
+		err = WitnessHook(vector, variant, w)
+	}
+	if err != nil {
+		err = fmt.Errorf("failed to produce state witness: %w", err)
+		r.Errorf(err.Error())
+	}
+	return err
+}
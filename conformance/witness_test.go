@@ -0,0 +1,74 @@
+package conformance
+
+import (
+	"bytes"
+	"testing"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
+)
+
+func TestWitnessRecordsPreStateReads(t *testing.T) {
+	var (
+		pre     = blocks.NewBlock([]byte("pre-state"))
+		unread  = blocks.NewBlock([]byte("unread"))
+		written = blocks.NewBlock([]byte("written"))
+	)
+
+	base := blockstore.NewTemporary()
+	for _, b := range []blocks.Block{pre, unread} {
+		if err := base.Put(b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ws := &witnessStore{
+		Blockstore: base,
+		read:       map[cid.Cid]struct{}{},
+		written:    map[cid.Cid]struct{}{},
+	}
+
+	if _, err := ws.Get(pre.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if err := ws.Put(written); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.Get(written.Cid()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ws.Get(blocks.NewBlock([]byte("missing")).Cid()); err == nil {
+		t.Fatal("expected missing block to fail")
+	}
+
+	w, err := ws.witness(pre.Cid(), written.Cid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(w.Blocks) != 1 || !w.Blocks[0].Cid().Equals(pre.Cid()) {
+		t.Fatalf("expected only the pre-state block to be witnessed, got %v", w.Blocks)
+	}
+
+	var buf bytes.Buffer
+	if err := w.WriteCAR(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded := blockstore.NewTemporary()
+	h, err := car.LoadCar(loaded, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h.Roots) != 2 || !h.Roots[0].Equals(pre.Cid()) || !h.Roots[1].Equals(written.Cid()) {
+		t.Fatalf("unexpected witness roots %v", h.Roots)
+	}
+	if has, err := loaded.Has(pre.Cid()); err != nil || !has {
+		t.Fatalf("witness CAR is missing the pre-state block: %v", err)
+	}
+	if has, _ := loaded.Has(unread.Cid()); has {
+		t.Fatal("witness CAR contains an unread block")
+	}
+}
@@ -0,0 +1,125 @@
+package sectorstorage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/lib/nullreader"
+)
+
+// dispatchStagingDir is the directory under storage paths where the data of
+// dispatched pieces is staged
+const dispatchStagingDir = "dispatch-staging"
+
+// dispatchPieceData is the last param of dispatched AddPiece calls, telling
+// the appliance where the data of the piece is
+type dispatchPieceData struct {
+	// Zero is set for pieces of zeroes, e.g. of CC sectors, which appliances
+	// generate themselves
+	Zero bool `json:",omitempty"`
+
+	// Path of the piece data, staged on storage shared with the appliance
+	Path string `json:",omitempty"`
+}
+
+func (w *DispatchWorker) AddPiece(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (storiface.CallID, error) {
+	files := dispatchFileTypes{existing: storiface.FTUnsealed}
+	if len(pieceSizes) == 0 {
+		files = dispatchFileTypes{allocate: storiface.FTUnsealed}
+	}
+
+	p := &dispatchPending{Method: "AddPiece"}
+	data := dispatchPieceData{Zero: isNullReader(pieceData)}
+
+	if !data.Zero {
+		staged, err := w.stagePiece(ctx, sector, pieceData)
+		if err != nil {
+			return storiface.UndefCall, xerrors.Errorf("staging piece data: %w", err)
+		}
+		p.Staged = staged
+		data.Path = rewritePathPrefix(staged, w.cfg.PathPrefixes)
+	}
+
+	ci, err := w.dispatch(ctx, p, files, sector, pieceSizes, newPieceSize, data)
+	if err != nil && p.Staged != "" {
+		_ = os.Remove(p.Staged)
+	}
+	return ci, err
+}
+
+// isNullReader returns whether the reader only returns zeroes, as readers of
+// CC pieces do
+func isNullReader(r io.Reader) bool {
+	switch r := r.(type) {
+	case interface{ NullBytes() int64 }:
+		return true
+	case *io.LimitedReader:
+		switch r.R.(type) {
+		case nullreader.Reader, *nullreader.Reader:
+			return true
+		}
+	}
+	return false
+}
+
+// stagePiece writes the piece data to the first sealing path among the
+// appliance storage which is local to the miner, returning the local path of
+// the file
+func (w *DispatchWorker) stagePiece(ctx context.Context, sector storage.SectorRef, data io.Reader) (string, error) {
+	switch w.cfg.PathMode {
+	case "", DispatchLocalPaths:
+	default:
+		return "", xerrors.Errorf("piece data can only be dispatched to appliances sharing storage with the miner, in %q path mode", DispatchLocalPaths)
+	}
+	if w.local == nil || len(w.cfg.StorageIDs) == 0 {
+		return "", xerrors.Errorf("no storage shared with the appliance to stage piece data on")
+	}
+
+	paths, err := w.local.Local(ctx)
+	if err != nil {
+		return "", xerrors.Errorf("getting local storage paths: %w", err)
+	}
+
+	var dir string
+	for _, id := range w.cfg.StorageIDs {
+		for _, p := range paths {
+			if p.ID == id && p.CanSeal && dir == "" {
+				dir = filepath.Join(p.LocalPath, dispatchStagingDir)
+			}
+		}
+	}
+	if dir == "" {
+		return "", xerrors.Errorf("none of the storage shared with the appliance is a local sealing path")
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", xerrors.Errorf("creating staging dir: %w", err)
+	}
+
+	file := filepath.Join(dir, storiface.SectorName(sector.ID)+"-"+uuid.New().String()+".piece")
+	f, err := os.Create(file)
+	if err != nil {
+		return "", xerrors.Errorf("creating staged piece file: %w", err)
+	}
+
+	if _, err := io.Copy(f, data); err != nil {
+		_ = f.Close()
+		_ = os.Remove(file)
+		return "", xerrors.Errorf("writing staged piece file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(file)
+		return "", xerrors.Errorf("closing staged piece file: %w", err)
+	}
+
+	return file, nil
+}
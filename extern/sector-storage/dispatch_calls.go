@@ -0,0 +1,236 @@
+package sectorstorage
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// dispatchPending is a call sent to an appliance, which result wasn't received
+// yet. Pending calls are kept in the datastore once
+// Manager.PersistDispatchCalls is called, so that the files allocated for
+// calls are still declared when their results come in after a restart. The
+// manager keeps track of the work itself.
+type dispatchPending struct {
+	ID       storiface.CallID
+	Method   string
+	Started  time.Time
+	Endpoint string
+
+	// files allocated for the call, declared when it succeeds
+	Decls []dispatchDecl
+	// files the call removes, dropped from the index when it succeeds
+	Drops []dispatchDecl `json:",omitempty"`
+
+	// piece data staged for the call, removed once it's done
+	Staged string `json:",omitempty"`
+
+	// token in the URLs of the files the call reads, revoked once it's done
+	Token storiface.FetchToken `json:",omitempty"`
+
+	// call payload, kept to send the call again when it times out
+	Payload  json.RawMessage
+	Attempts int
+	Sent     time.Time
+
+	timer *time.Timer
+}
+
+// dispatchMaxPersistedPayload is the size of the largest call payload kept in
+// the datastore. Larger ones, e.g. of Commit2 calls carrying the Commit1
+// output, are only kept in memory.
+const dispatchMaxPersistedPayload = 1 << 20
+
+func dispatchPendingKey(ci storiface.CallID) datastore.Key {
+	return datastore.NewKey(ci.String())
+}
+
+// track records a call sent to the appliance
+func (w *DispatchWorker) track(p *dispatchPending) {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	w.pending[p.ID] = p
+	if err := w.putPending(p); err != nil {
+		log.Errorf("dispatch worker %s: %+v", w.cfg.Hostname, err)
+	}
+}
+
+// untrack removes a call which result was received, or which couldn't be
+// sent, returning it if it was tracked
+func (w *DispatchWorker) untrack(ci storiface.CallID) *dispatchPending {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	p, ok := w.pending[ci]
+	if !ok {
+		return nil
+	}
+	delete(w.pending, ci)
+	delete(w.verifying, ci)
+	if p.timer != nil {
+		p.timer.Stop()
+	}
+	if p.Token != "" && w.tokens != nil {
+		w.tokens.revoke(p.Token)
+	}
+	if p.Staged != "" {
+		if err := os.Remove(p.Staged); err != nil && !os.IsNotExist(err) {
+			log.Errorf("dispatch worker %s: removing staged piece data: %+v", w.cfg.Hostname, err)
+		}
+	}
+
+	if w.pendingDS != nil {
+		if err := w.pendingDS.Delete(dispatchPendingKey(ci)); err != nil {
+			log.Errorf("dispatch worker %s: removing pending call %s: %+v", w.cfg.Hostname, ci, err)
+		}
+	}
+	return p
+}
+
+// putPending writes the call to the datastore, if pending calls are
+// persisted; called with pendingLk held
+func (w *DispatchWorker) putPending(p *dispatchPending) error {
+	if w.pendingDS == nil {
+		return nil
+	}
+
+	rec := *p
+	if len(rec.Payload) > dispatchMaxPersistedPayload {
+		// calls restored without their payload fail when they time out,
+		// instead of being sent again
+		rec.Payload = nil
+	}
+
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		return xerrors.Errorf("encoding pending call: %w", err)
+	}
+	if err := w.pendingDS.Put(dispatchPendingKey(p.ID), b); err != nil {
+		return xerrors.Errorf("writing pending call %s: %w", p.ID, err)
+	}
+	return nil
+}
+
+// persistPending restores the calls to this worker's endpoint which were
+// pending when the miner stopped, and keeps pending calls in the datastore
+// from now on
+func (w *DispatchWorker) persistPending(ds datastore.Datastore) error {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	res, err := ds.Query(query.Query{})
+	if err != nil {
+		return xerrors.Errorf("querying pending dispatch calls: %w", err)
+	}
+	defer res.Close() // nolint
+
+	var restored int
+	for r := range res.Next() {
+		if r.Error != nil {
+			return xerrors.Errorf("reading pending dispatch calls: %w", r.Error)
+		}
+
+		var p dispatchPending
+		if err := json.Unmarshal(r.Value, &p); err != nil {
+			return xerrors.Errorf("decoding pending dispatch call %s: %w", r.Key, err)
+		}
+		if p.Endpoint != w.cfg.Endpoint {
+			continue
+		}
+		if _, ok := w.pending[p.ID]; ok {
+			continue
+		}
+
+		w.pending[p.ID] = &p
+		w.arm(&p)
+		restored++
+	}
+
+	w.pendingDS = ds
+	for _, p := range w.pending {
+		if err := w.putPending(p); err != nil {
+			return err
+		}
+	}
+
+	if restored > 0 {
+		log.Infow("restored pending dispatch calls", "endpoint", w.cfg.Endpoint, "calls", restored)
+	}
+	return nil
+}
+
+// PersistDispatchCalls keeps the calls sent to dispatch workers, which results
+// weren't received yet, in the datastore, and restores the ones pending when
+// the miner was stopped. It should be called right after New.
+func (m *Manager) PersistDispatchCalls(ds datastore.Datastore) error {
+	m.dispatchLk.Lock()
+	defer m.dispatchLk.Unlock()
+
+	m.dispatchDS = ds
+	for _, w := range m.dispatch {
+		if err := w.persistPending(ds); err != nil {
+			return xerrors.Errorf("dispatch worker %s: %w", w.cfg.Endpoint, err)
+		}
+	}
+	return nil
+}
+
+// dispatchCancel is the payload of the Cancel message, asking the appliance
+// to stop working on a call
+type dispatchCancel struct {
+	CallID storiface.CallID
+}
+
+// abort stops waiting for the result of the call, and asks the appliance to
+// cancel it. It returns false if the call isn't pending on this worker.
+func (w *DispatchWorker) abort(ctx context.Context, ci storiface.CallID) (bool, error) {
+	p := w.untrack(ci)
+	if p == nil {
+		return false, nil
+	}
+
+	w.pendingLk.Lock()
+	w.failed[ci] = struct{}{}
+	w.pendingLk.Unlock()
+
+	payload, err := json.Marshal(&dispatchCancel{CallID: ci})
+	if err != nil {
+		return true, xerrors.Errorf("encoding cancel message: %w", err)
+	}
+	if err := w.tr.Send(ctx, "Cancel", payload); err != nil {
+		return true, xerrors.Errorf("sending cancel message: %w", err)
+	}
+	return true, nil
+}
+
+// SealingAbortRemote aborts a call pending on a dispatch worker: the appliance
+// is asked to cancel it, and the call fails with an abort error. A result the
+// appliance still sends for the call is dropped, so that it can't reach the
+// sector after it moved on.
+func (m *Manager) SealingAbortRemote(ctx context.Context, call storiface.CallID) error {
+	for _, w := range m.dispatchWorkers() {
+		ok, err := w.abort(ctx, call)
+		if !ok {
+			continue
+		}
+
+		if aerr := m.Abort(ctx, call); aerr != nil {
+			return xerrors.Errorf("aborting call: %w", aerr)
+		}
+		if err != nil {
+			// the call is aborted on the miner either way
+			return xerrors.Errorf("call aborted, but the appliance at %s may still be running it: %w", w.cfg.Endpoint, err)
+		}
+		return nil
+	}
+
+	return xerrors.Errorf("call %s isn't pending on any dispatch worker", call)
+}
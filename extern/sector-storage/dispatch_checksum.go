@@ -0,0 +1,180 @@
+package sectorstorage
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/minio/blake2b-simd"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// dispatchChecksums are reported by appliances with the results of
+// PreCommit2 calls, when the config requires it. Both are hex encoded
+// blake2b-256 checksums: Sealed of the sealed file, Cache of the manifest of
+// the cache directory, which has a "<name> <size> <checksum>\n" line for every
+// file in it, sorted by name, with the blake2b-256 checksum of the file.
+type dispatchChecksums struct {
+	Sealed string
+	Cache  string
+}
+
+type dispatchVerify struct {
+	done chan struct{}
+	err  error
+}
+
+// verified checks the sealed output of a PreCommit2 call against the
+// checksums the appliance reported with its result, when the config requires
+// it. The files are hashed in the background; until that's done the result
+// isn't taken, so the appliance sends it again later, and other results
+// aren't held up meanwhile. It returns whether the check is done, and the
+// mismatch it found.
+func (w *DispatchWorker) verified(p *dispatchPending, msg []byte) (bool, error) {
+	if !w.cfg.VerifyChecksums || p.Method != "SealPreCommit2" {
+		return true, nil
+	}
+	if _, ok, err := dispatchOutcome(msg); err != nil || !ok {
+		return true, nil // failed calls have no output to check
+	}
+
+	w.pendingLk.Lock()
+	v, started := w.verifying[p.ID]
+	if !started {
+		v = &dispatchVerify{done: make(chan struct{})}
+		w.verifying[p.ID] = v
+	}
+	w.pendingLk.Unlock()
+
+	if !started {
+		var dr dispatchReturn
+		if err := json.Unmarshal(msg, &dr); err != nil {
+			return true, xerrors.Errorf("decoding result: %w", err)
+		}
+
+		go func() {
+			v.err = w.verifyChecksums(w.ctx, p.ID.Sector, dr.Checksums)
+			close(v.done)
+		}()
+	}
+
+	select {
+	case <-v.done:
+		return true, v.err
+	default:
+		return false, nil
+	}
+}
+
+// verifyChecksums hashes the sealed file and cache of the sector on storage
+// local to the miner. Output which isn't on local storage can't be checked.
+func (w *DispatchWorker) verifyChecksums(ctx context.Context, sector abi.SectorID, sums *dispatchChecksums) error {
+	if sums == nil {
+		return xerrors.Errorf("appliance didn't report checksums of the sealed output")
+	}
+
+	sealed, cache, err := w.localOutput(ctx, sector)
+	if err != nil {
+		return err
+	}
+	if sealed == "" || cache == "" {
+		log.Warnf("dispatch worker %s: sealed output of %s isn't on storage local to the miner, not verifying its checksums", w.cfg.Hostname, storiface.SectorName(sector))
+		return nil
+	}
+
+	got, err := fileChecksum(sealed)
+	if err != nil {
+		return err
+	}
+	if got != sums.Sealed {
+		return xerrors.Errorf("checksum of sealed file %s is %s, the appliance reported %s", sealed, got, sums.Sealed)
+	}
+
+	got, err = cacheChecksum(cache)
+	if err != nil {
+		return err
+	}
+	if got != sums.Cache {
+		return xerrors.Errorf("checksum of cache %s is %s, the appliance reported %s", cache, got, sums.Cache)
+	}
+	return nil
+}
+
+// localOutput returns the paths of the sealed file and cache of the sector on
+// storage local to the miner, empty for those which aren't
+func (w *DispatchWorker) localOutput(ctx context.Context, sector abi.SectorID) (sealed, cache string, err error) {
+	if w.local == nil {
+		return "", "", nil
+	}
+
+	local, err := w.local.Local(ctx)
+	if err != nil {
+		return "", "", xerrors.Errorf("getting local storage paths: %w", err)
+	}
+
+	find := func(ft storiface.SectorFileType) (string, error) {
+		infos, err := w.index.StorageFindSector(ctx, sector, ft, 0, false)
+		if err != nil {
+			return "", xerrors.Errorf("finding %s of %s: %w", ft, storiface.SectorName(sector), err)
+		}
+		for _, info := range infos {
+			for _, p := range local {
+				if p.ID == info.ID {
+					return filepath.Join(p.LocalPath, ft.String(), storiface.SectorName(sector)), nil
+				}
+			}
+		}
+		return "", nil
+	}
+
+	if sealed, err = find(storiface.FTSealed); err != nil {
+		return "", "", err
+	}
+	if cache, err = find(storiface.FTCache); err != nil {
+		return "", "", err
+	}
+	return sealed, cache, nil
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", xerrors.Errorf("opening %s: %w", path, err)
+	}
+	defer f.Close() // nolint
+
+	h := blake2b.New256()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", xerrors.Errorf("hashing %s: %w", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func cacheChecksum(dir string) (string, error) {
+	files, err := ioutil.ReadDir(dir) // sorted by name
+	if err != nil {
+		return "", xerrors.Errorf("listing cache %s: %w", dir, err)
+	}
+
+	h := blake2b.New256()
+	for _, fi := range files {
+		if !fi.Mode().IsRegular() {
+			continue
+		}
+		sum, err := fileChecksum(filepath.Join(dir, fi.Name()))
+		if err != nil {
+			return "", err
+		}
+		_, _ = fmt.Fprintf(h, "%s %d %s\n", fi.Name(), fi.Size(), sum)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,342 @@
+package sectorstorage
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+)
+
+// DefaultDispatchDiscoverInterval is how often appliances are discovered
+// again when the config doesn't set an interval
+var DefaultDispatchDiscoverInterval = time.Minute
+
+// DefaultDispatchUnhealthy is how long an unhealthy appliance isn't sent new
+// calls when the config doesn't set a duration
+var DefaultDispatchUnhealthy = 5 * time.Minute
+
+// dispatchLookupSRV resolves the SRV records appliances are discovered with
+var dispatchLookupSRV = net.DefaultResolver.LookupSRV
+
+// strike records a failed send or a timeout; the appliance is unhealthy once
+// it had UnhealthyAfter of them in a row
+func (w *DispatchWorker) strike() {
+	if w.cfg.UnhealthyAfter <= 0 {
+		return
+	}
+
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	w.strikes++
+	if w.strikes < w.cfg.UnhealthyAfter {
+		return
+	}
+
+	d := time.Duration(w.cfg.UnhealthySecs) * time.Second
+	if d == 0 {
+		d = DefaultDispatchUnhealthy
+	}
+	w.strikes = 0
+	w.unhealthyUntil = time.Now().Add(d)
+	log.Warnw("dispatch appliance is unhealthy, not sending it new calls", "worker", w.cfg.Hostname, "endpoint", w.cfg.Endpoint, "for", d)
+}
+
+// healthy records a result received from the appliance
+func (w *DispatchWorker) healthy() {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	w.strikes = 0
+}
+
+// available returns whether the appliance takes new calls; calls already
+// sent to unavailable appliances still complete
+func (w *DispatchWorker) available() bool {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	return !w.draining && !time.Now().Before(w.unhealthyUntil)
+}
+
+func (w *DispatchWorker) setDraining(draining bool) {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	w.draining = draining
+}
+
+// drained returns whether the appliance left, and all calls sent to it are
+// done
+func (w *DispatchWorker) drained() bool {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	return w.draining && len(w.pending) == 0
+}
+
+// dispatchSRV is the SRV record a discovered appliance was resolved from
+type dispatchSRV struct {
+	name     string
+	priority uint16
+	weight   uint16
+}
+
+// dispatchZeroWeight stands for a weight of 0, so that such appliances are
+// picked rarely when others have a weight, and evenly when none has
+const dispatchZeroWeight = 0.01
+
+func (w *DispatchWorker) setSRV(srv *dispatchSRV) {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	w.srv = srv
+}
+
+// srvWeight returns the SRV record of the appliance, and its weight scaled
+// down by the failed sends and timeouts it had in a row, so that failing
+// appliances get fewer calls before they're considered unhealthy
+func (w *DispatchWorker) srvWeight() (*dispatchSRV, float64) {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	if w.srv == nil {
+		return nil, 0
+	}
+	weight := float64(w.srv.weight)
+	if weight == 0 {
+		weight = dispatchZeroWeight
+	}
+	return w.srv, weight / float64(1+w.strikes)
+}
+
+// dispatchOrder orders appliances discovered with the same name the way SRV
+// clients pick targets: lower priorities first, then at random in proportion
+// to their weight. The random keys are drawn once per scheduling pass, with
+// the Efraimidis-Spirakis method, so sorting by them is a weighted shuffle.
+type dispatchOrder map[WorkerID]float64
+
+// less returns whether the appliance of worker a is picked before the one of
+// worker b; ok is false unless both are appliances discovered with the same
+// name, which the task selector orders instead
+func (o dispatchOrder) less(aid, bid WorkerID, a, b *workerHandle) (less bool, ok bool) {
+	da, ok := a.workerRpc.(*DispatchWorker)
+	if !ok {
+		return false, false
+	}
+	db, ok := b.workerRpc.(*DispatchWorker)
+	if !ok {
+		return false, false
+	}
+
+	sa, _ := da.srvWeight()
+	sb, _ := db.srvWeight()
+	if sa == nil || sb == nil || sa.name != sb.name {
+		return false, false
+	}
+	if sa.priority != sb.priority {
+		return sa.priority < sb.priority, true
+	}
+	return o.key(aid, da) < o.key(bid, db), true
+}
+
+func (o dispatchOrder) key(wid WorkerID, w *DispatchWorker) float64 {
+	if k, ok := o[wid]; ok {
+		return k
+	}
+	_, weight := w.srvWeight()
+	k := -math.Log(1-rand.Float64()) / weight
+	o[wid] = k
+	return k
+}
+
+// dispatchDiscovery keeps a worker for every appliance the SRV records of a
+// config point to
+type dispatchDiscovery struct {
+	cfg DispatchConfig
+
+	newWorker func(cfg DispatchConfig) (*DispatchWorker, error)
+	add       func(w *DispatchWorker) error
+	remove    func(w *DispatchWorker)
+
+	workers map[string]*DispatchWorker // by endpoint
+}
+
+func checkDispatchDiscovery(cfg DispatchConfig) error {
+	if cfg.Endpoint != "" {
+		return xerrors.Errorf("dispatch config can't set both an endpoint and a name to discover endpoints with")
+	}
+	if cfg.Transport == DispatchJob {
+		return xerrors.Errorf("job dispatch transports submit calls to a cluster scheduler, there are no appliances to discover")
+	}
+	if cfg.Transport == DispatchHTTP && cfg.ResultMode != DispatchPoll {
+		return xerrors.Errorf("discovered http appliances can't share a listen address for pushed results, use the %q result mode", DispatchPoll)
+	}
+	return nil
+}
+
+// discoverDispatch resolves the appliances of the config periodically, until
+// ctx is done
+func (m *Manager) discoverDispatch(ctx context.Context, cfg DispatchConfig, local *stores.Local, index stores.SectorIndex) {
+	d := &dispatchDiscovery{
+		cfg: cfg,
+		newWorker: func(cfg DispatchConfig) (*DispatchWorker, error) {
+			tr, err := NewDispatchTransport(cfg)
+			if err != nil {
+				return nil, err
+			}
+			return NewDispatchWorker(ctx, cfg, tr, local, index, m)
+		},
+		add: func(w *DispatchWorker) error {
+			return m.addDispatchWorker(ctx, w)
+		},
+		remove:  m.removeDispatchWorker,
+		workers: map[string]*DispatchWorker{},
+	}
+
+	interval := time.Duration(cfg.DiscoverIntervalSecs) * time.Second
+	if interval == 0 {
+		interval = DefaultDispatchDiscoverInterval
+	}
+
+	for {
+		if err := d.resolve(ctx); err != nil {
+			log.Errorw("discovering dispatch appliances", "name", cfg.Discover, "error", err)
+		}
+		d.reap()
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// resolve adds workers for new appliances, and drains the ones of appliances
+// which left; they're removed once their calls are done
+func (d *dispatchDiscovery) resolve(ctx context.Context) error {
+	_, srvs, err := dispatchLookupSRV(ctx, "", "", d.cfg.Discover)
+	if err != nil {
+		return xerrors.Errorf("resolving %s: %w", d.cfg.Discover, err)
+	}
+
+	seen := map[string]struct{}{}
+	for _, srv := range srvs {
+		host := strings.TrimSuffix(srv.Target, ".")
+		ep := net.JoinHostPort(host, strconv.Itoa(int(srv.Port)))
+		if d.cfg.Transport == DispatchHTTP {
+			ep = "http://" + ep
+		}
+		seen[ep] = struct{}{}
+		rec := &dispatchSRV{name: d.cfg.Discover, priority: srv.Priority, weight: srv.Weight}
+
+		if w, ok := d.workers[ep]; ok {
+			w.setDraining(false)
+			w.setSRV(rec)
+			continue
+		}
+
+		cfg := d.cfg
+		cfg.Endpoint = ep
+		cfg.Discover = ""
+		if cfg.Hostname == "" {
+			cfg.Hostname = host
+		} else {
+			cfg.Hostname += "/" + host
+		}
+
+		w, err := d.newWorker(cfg)
+		if err != nil {
+			log.Errorw("creating worker for discovered dispatch appliance", "endpoint", ep, "error", err)
+			continue
+		}
+		w.setSRV(rec)
+		if err := d.add(w); err != nil {
+			_ = w.Close()
+			log.Errorw("adding worker for discovered dispatch appliance", "endpoint", ep, "error", err)
+			continue
+		}
+
+		log.Infow("discovered dispatch appliance", "name", d.cfg.Discover, "endpoint", ep)
+		d.workers[ep] = w
+	}
+
+	for ep, w := range d.workers {
+		if _, ok := seen[ep]; !ok {
+			w.setDraining(true)
+		}
+	}
+	return nil
+}
+
+// reap removes the workers of appliances which left, once their calls are
+// done
+func (d *dispatchDiscovery) reap() {
+	for ep, w := range d.workers {
+		if !w.drained() {
+			continue
+		}
+
+		log.Infow("dispatch appliance left", "name", d.cfg.Discover, "endpoint", ep)
+		d.remove(w)
+		if err := w.Close(); err != nil {
+			log.Errorw("closing worker of dispatch appliance", "endpoint", ep, "error", err)
+		}
+		delete(d.workers, ep)
+	}
+}
+
+// addDispatchWorker adds the worker of an appliance to the scheduler
+func (m *Manager) addDispatchWorker(ctx context.Context, w *DispatchWorker) error {
+	w.tokens = m.fetchTokens
+
+	m.dispatchLk.Lock()
+	ds := m.dispatchDS
+	m.dispatchLk.Unlock()
+
+	if ds != nil {
+		if err := w.persistPending(ds); err != nil {
+			return err
+		}
+	}
+
+	// validating the worker may take a while
+	if err := m.AddWorker(ctx, w); err != nil {
+		return err
+	}
+
+	m.dispatchLk.Lock()
+	m.dispatch = append(m.dispatch, w)
+	m.dispatchLk.Unlock()
+	return nil
+}
+
+// removeDispatchWorker forgets the worker of an appliance; the scheduler drops
+// it once it's closed
+func (m *Manager) removeDispatchWorker(w *DispatchWorker) {
+	m.dispatchLk.Lock()
+	defer m.dispatchLk.Unlock()
+
+	for i, dw := range m.dispatch {
+		if dw == w {
+			m.dispatch = append(m.dispatch[:i], m.dispatch[i+1:]...)
+			return
+		}
+	}
+}
+
+// dispatchWorkers returns the workers of the appliances
+func (m *Manager) dispatchWorkers() []*DispatchWorker {
+	m.dispatchLk.Lock()
+	defer m.dispatchLk.Unlock()
+
+	return append([]*DispatchWorker(nil), m.dispatch...)
+}
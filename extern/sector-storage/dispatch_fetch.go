@@ -0,0 +1,98 @@
+package sectorstorage
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// dispatchFetchSources is the last param of dispatched Fetch calls, telling
+// the appliance where to copy the sector files from. Paths of the call are
+// where to copy them to.
+//
+// This is what lets sealing phases run on different appliances, e.g.
+// PreCommit1 on CPU appliances and PreCommit2 on GPU appliances: the manager
+// fetches the files PreCommit1 created to the appliance PreCommit2 is
+// scheduled on, and the PreCommit1 output is carried in the PreCommit2 call.
+type dispatchFetchSources struct {
+	// URLs the files are served from, for files which aren't on storage
+	// reachable by the appliance; the FetchToken param authorizes reading
+	// them
+	Sources *storiface.SectorPaths `json:",omitempty"`
+}
+
+// Fetch has the appliance copy sector files from storage it can't reach to
+// its own. Sources are left in place, even with AcquireMove.
+func (w *DispatchWorker) Fetch(ctx context.Context, sector storage.SectorRef, fileType storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode, token storiface.FetchToken) (storiface.CallID, error) {
+	src, missing, err := w.fetchSources(ctx, sector, fileType)
+	if err != nil {
+		return storiface.UndefCall, xerrors.Errorf("finding fetch sources: %w", err)
+	}
+
+	files := dispatchFileTypes{allocate: missing, pathType: ptype}
+	return w.dispatch(ctx, &dispatchPending{Method: "Fetch"}, files, sector, fileType, ptype, am, token, dispatchFetchSources{Sources: src})
+}
+
+// fetchSources returns the URLs of the files which aren't on storage
+// reachable by the appliance, and their types. Without storage configured
+// for the appliance it finds the files itself, so there are none.
+func (w *DispatchWorker) fetchSources(ctx context.Context, sector storage.SectorRef, fileType storiface.SectorFileType) (*storiface.SectorPaths, storiface.SectorFileType, error) {
+	if len(w.cfg.StorageIDs) == 0 {
+		return nil, storiface.FTNone, nil
+	}
+
+	ssize, err := sector.ProofType.SectorSize()
+	if err != nil {
+		return nil, storiface.FTNone, err
+	}
+
+	allowed := map[stores.ID]struct{}{}
+	for _, id := range w.cfg.StorageIDs {
+		allowed[id] = struct{}{}
+	}
+
+	src := &storiface.SectorPaths{ID: sector.ID}
+	missing := storiface.FTNone
+
+	for _, ft := range storiface.PathTypes {
+		if fileType&ft == 0 {
+			continue
+		}
+
+		infos, err := w.index.StorageFindSector(ctx, sector.ID, ft, ssize, false)
+		if err != nil {
+			return nil, storiface.FTNone, xerrors.Errorf("finding existing sector: %w", err)
+		}
+
+		var reachable bool
+		var from string
+		for _, info := range infos {
+			if _, ok := allowed[info.ID]; ok {
+				reachable = true
+				break
+			}
+			if from == "" && len(info.URLs) > 0 {
+				from = info.URLs[0]
+			}
+		}
+		if reachable {
+			continue
+		}
+		if from == "" {
+			return nil, storiface.FTNone, xerrors.Errorf("no %s file of %s to fetch", ft, storiface.SectorName(sector.ID))
+		}
+
+		storiface.SetPathByType(src, ft, from)
+		missing |= ft
+	}
+
+	if missing == storiface.FTNone {
+		return nil, storiface.FTNone, nil
+	}
+	return src, missing, nil
+}
@@ -0,0 +1,116 @@
+package sectorstorage
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// FinalizeSector has the appliance trim the sector cache where it lives. The
+// unsealed file, when it's on the appliance storage, is sent along; without
+// ranges to keep the appliance removes it, and it's dropped from the index
+// once the call succeeds.
+func (w *DispatchWorker) FinalizeSector(ctx context.Context, sector storage.SectorRef, keepUnsealed []storage.Range) (storiface.CallID, error) {
+	files := dispatchFiles["FinalizeSector"]
+	if len(keepUnsealed) == 0 {
+		files.remove = storiface.FTUnsealed
+	}
+	return w.dispatch(ctx, &dispatchPending{Method: "FinalizeSector"}, files, sector, keepUnsealed)
+}
+
+// ReleaseUnsealed has the appliance free the ranges of the unsealed file. The
+// file is dropped from the index once the call succeeds, unless the appliance
+// reports it kept it, e.g. because other ranges are still needed.
+func (w *DispatchWorker) ReleaseUnsealed(ctx context.Context, sector storage.SectorRef, safeToFree []storage.Range) (storiface.CallID, error) {
+	files := dispatchFiles["ReleaseUnsealed"]
+	files.remove = storiface.FTUnsealed
+	return w.dispatch(ctx, &dispatchPending{Method: "ReleaseUnsealed"}, files, sector, safeToFree)
+}
+
+// holds returns whether the files of the sector are on the appliance storage
+func (w *DispatchWorker) holds(ctx context.Context, sector abi.SectorID, ft storiface.SectorFileType) (bool, error) {
+	for _, t := range storiface.PathTypes {
+		if ft&t == 0 {
+			continue
+		}
+
+		infos, err := w.index.StorageFindSector(ctx, sector, t, 0, false)
+		if err != nil {
+			return false, xerrors.Errorf("finding %s of %s: %w", t, storiface.SectorName(sector), err)
+		}
+
+		var found bool
+		for _, info := range infos {
+			for _, id := range w.cfg.StorageIDs {
+				if info.ID == id {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// dispatchHolder returns a dispatch worker running the task, which holds the
+// files of the sector on its storage; nil if there's none
+func (m *Manager) dispatchHolder(ctx context.Context, sector abi.SectorID, ft storiface.SectorFileType, task sealtasks.TaskType) (*DispatchWorker, error) {
+	for _, w := range m.dispatchWorkers() {
+		tasks, err := w.TaskTypes(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := tasks[task]; !ok {
+			continue
+		}
+
+		ok, err := w.holds(ctx, sector, ft)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return w, nil
+		}
+	}
+	return nil, nil
+}
+
+// dispatchSelector only accepts dispatch workers, for calls local workers
+// can't run
+type dispatchSelector struct {
+	WorkerSelector
+}
+
+func (s *dispatchSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, whnd *workerHandle) (bool, error) {
+	if _, ok := whnd.workerRpc.(*DispatchWorker); !ok {
+		return false, nil
+	}
+	return s.WorkerSelector.Ok(ctx, task, spt, whnd)
+}
+
+var _ WorkerSelector = &dispatchSelector{}
+
+// releaseUnsealedRemote has an appliance holding the unsealed file of the
+// sector release the ranges. It returns false if no appliance holds the file.
+func (m *Manager) releaseUnsealedRemote(ctx context.Context, sector storage.SectorRef, safeToFree []storage.Range) (bool, error) {
+	holder, err := m.dispatchHolder(ctx, sector.ID, storiface.FTUnsealed, sealtasks.TTFinalize)
+	if err != nil || holder == nil {
+		return false, err
+	}
+
+	selector := &dispatchSelector{newExistingSelector(m.index, sector.ID, storiface.FTUnsealed, false)}
+
+	err = m.sched.Schedule(ctx, sector, sealtasks.TTFinalize, selector, schedNop, func(ctx context.Context, w Worker) error {
+		_, err := m.waitSimpleCall(ctx)(w.ReleaseUnsealed(ctx, sector, safeToFree))
+		return err
+	})
+	return true, err
+}
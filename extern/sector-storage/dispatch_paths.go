@@ -0,0 +1,291 @@
+package sectorstorage
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	gopath "path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+const (
+	// DispatchLocalPaths sends local paths of the storage the appliance
+	// shares with the miner
+	DispatchLocalPaths = "path"
+	// DispatchURLPaths sends the URLs the sector files are served from
+	DispatchURLPaths = "url"
+)
+
+// dispatchFileTypes are the sector files a call works on: existing ones,
+// which must be on storage reachable by the appliance, optional ones, sent
+// when they exist there, and ones the call creates, on paths of pathType;
+// sealing paths if unset. Of the existing and optional files, the ones in
+// remove are removed by the call.
+type dispatchFileTypes struct {
+	existing storiface.SectorFileType
+	optional storiface.SectorFileType
+	allocate storiface.SectorFileType
+	pathType storiface.PathType
+	remove   storiface.SectorFileType
+}
+
+// dispatchFiles are the sector files of calls; AddPiece creates the unsealed
+// file for the first piece only, so its files are picked per call.
+// FinalizeSector gets the unsealed file when there's one, so the appliance can
+// remove it where it lives.
+var dispatchFiles = map[string]dispatchFileTypes{
+	"SealPreCommit1":  {existing: storiface.FTUnsealed, allocate: storiface.FTSealed | storiface.FTCache},
+	"SealPreCommit2":  {existing: storiface.FTSealed | storiface.FTCache},
+	"SealCommit1":     {existing: storiface.FTSealed | storiface.FTCache},
+	"FinalizeSector":  {existing: storiface.FTSealed | storiface.FTCache, optional: storiface.FTUnsealed},
+	"ReleaseUnsealed": {existing: storiface.FTUnsealed},
+	"UnsealPiece":     {existing: storiface.FTSealed | storiface.FTCache, allocate: storiface.FTUnsealed},
+}
+
+// dispatchDecl is a sector file allocated or removed by a call, declared in
+// or dropped from the index once the call succeeds
+type dispatchDecl struct {
+	Storage  stores.ID
+	FileType storiface.SectorFileType
+	Location string // sent to the appliance
+}
+
+// sectorPaths resolves the locations of the sector files the call works on,
+// on the storage paths configured for the appliance. It returns nil if the
+// config has no storage paths, in which case the appliance finds the files
+// itself, and removed files can't be dropped from the index. URLs of the
+// files read by the call carry tok, when set.
+func (w *DispatchWorker) sectorPaths(ctx context.Context, files dispatchFileTypes, sector storage.SectorRef, tok storiface.FetchToken) (*storiface.SectorPaths, []dispatchDecl, []dispatchDecl, error) {
+	if files == (dispatchFileTypes{}) || len(w.cfg.StorageIDs) == 0 {
+		return nil, nil, nil, nil
+	}
+
+	ssize, err := sector.ProofType.SectorSize()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	allowed := map[stores.ID]struct{}{}
+	for _, id := range w.cfg.StorageIDs {
+		allowed[id] = struct{}{}
+	}
+
+	out := &storiface.SectorPaths{ID: sector.ID}
+	var decls, drops []dispatchDecl
+
+	for _, ft := range storiface.PathTypes {
+		if (files.existing|files.optional)&ft == 0 {
+			continue
+		}
+
+		infos, err := w.index.StorageFindSector(ctx, sector.ID, ft, ssize, false)
+		if err != nil {
+			return nil, nil, nil, xerrors.Errorf("finding existing sector: %w", err)
+		}
+
+		var found bool
+		for _, info := range infos {
+			if _, ok := allowed[info.ID]; !ok {
+				continue
+			}
+
+			loc, err := w.location(ctx, info.ID, sector, ft, tok)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			storiface.SetPathByType(out, ft, loc)
+			if files.remove&ft != 0 {
+				drops = append(drops, dispatchDecl{Storage: info.ID, FileType: ft, Location: loc})
+			}
+			found = true
+			break
+		}
+		if !found && files.existing&ft != 0 {
+			return nil, nil, nil, xerrors.Errorf("no %s file of %s on storage reachable by the appliance", ft, storiface.SectorName(sector.ID))
+		}
+	}
+
+	for _, ft := range storiface.PathTypes {
+		if files.allocate&ft == 0 {
+			continue
+		}
+
+		ptype := files.pathType
+		if ptype == "" {
+			ptype = storiface.PathSealing
+		}
+
+		best, err := w.index.StorageBestAlloc(ctx, ft, ssize, ptype)
+		if err != nil {
+			return nil, nil, nil, xerrors.Errorf("finding best storage for allocating: %w", err)
+		}
+
+		var found bool
+		for _, si := range best {
+			if _, ok := allowed[si.ID]; !ok {
+				continue
+			}
+
+			loc, err := w.location(ctx, si.ID, sector, ft, "")
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			storiface.SetPathByType(out, ft, loc)
+			decls = append(decls, dispatchDecl{Storage: si.ID, FileType: ft, Location: loc})
+			found = true
+			break
+		}
+		if !found {
+			return nil, nil, nil, xerrors.Errorf("no storage reachable by the appliance to allocate %s of %s", ft, storiface.SectorName(sector.ID))
+		}
+	}
+
+	return out, decls, drops, nil
+}
+
+// location returns the path or URL, depending on the config, of a sector file
+// on the storage; URLs carry tok in their query, when set
+func (w *DispatchWorker) location(ctx context.Context, id stores.ID, sector storage.SectorRef, ft storiface.SectorFileType, tok storiface.FetchToken) (string, error) {
+	switch w.cfg.PathMode {
+	case "", DispatchLocalPaths:
+		if w.local == nil {
+			return "", xerrors.Errorf("no local storage to resolve paths with")
+		}
+		paths, err := w.local.Local(ctx)
+		if err != nil {
+			return "", xerrors.Errorf("getting local storage paths: %w", err)
+		}
+		for _, p := range paths {
+			if p.ID == id {
+				return rewritePathPrefix(filepath.Join(p.LocalPath, ft.String(), storiface.SectorName(sector.ID)), w.cfg.PathPrefixes), nil
+			}
+		}
+		return "", xerrors.Errorf("storage %s isn't local to the miner, its files can only be addressed by url", id)
+	case DispatchURLPaths:
+		si, err := w.index.StorageInfo(ctx, id)
+		if err != nil {
+			return "", xerrors.Errorf("getting storage info for %s: %w", id, err)
+		}
+		if len(si.URLs) == 0 {
+			return "", xerrors.Errorf("storage %s has no urls", id)
+		}
+
+		u, err := url.Parse(si.URLs[0])
+		if err != nil {
+			return "", xerrors.Errorf("parsing url of storage %s: %w", id, err)
+		}
+		u.Path = gopath.Join(u.Path, ft.String(), storiface.SectorName(sector.ID))
+		if tok != "" {
+			q := u.Query()
+			q.Set(stores.FetchTokenParam, string(tok))
+			u.RawQuery = q.Encode()
+		}
+		return u.String(), nil
+	default:
+		return "", xerrors.Errorf("unknown dispatch path mode %q", w.cfg.PathMode)
+	}
+}
+
+// callToken issues a fetch token for the files a call reads, scoped to its
+// sector, when they're sent as URLs. Appliances read the files with it instead
+// of the miner API token.
+func (w *DispatchWorker) callToken(sector abi.SectorID, ci storiface.CallID, files dispatchFileTypes) (storiface.FetchToken, error) {
+	read := files.existing | files.optional
+	if w.cfg.PathMode != DispatchURLPaths || w.tokens == nil || len(w.cfg.StorageIDs) == 0 || read == storiface.FTNone {
+		return "", nil
+	}
+
+	tok, err := w.tokens.issue(sector, read, time.Now())
+	if err != nil {
+		return "", err
+	}
+	w.tokens.bind(tok, ci)
+	return tok, nil
+}
+
+// rewritePathPrefix replaces the longest matching prefix of the path
+func rewritePathPrefix(p string, prefixes map[string]string) string {
+	var match string
+	for prefix := range prefixes {
+		if (p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")) && len(prefix) > len(match) {
+			match = prefix
+		}
+	}
+	if match == "" {
+		return p
+	}
+	return prefixes[match] + strings.TrimPrefix(p, strings.TrimSuffix(match, "/"))
+}
+
+// dispatchOutcome returns the call a result is for, and whether the call
+// succeeded
+func dispatchOutcome(msg []byte) (storiface.CallID, bool, error) {
+	var dr dispatchReturn
+	if err := json.Unmarshal(msg, &dr); err != nil {
+		return storiface.UndefCall, false, xerrors.Errorf("decoding result: %w", err)
+	}
+	if len(dr.Params) < 2 {
+		return storiface.UndefCall, false, xerrors.Errorf("%s: expected call id and error params", dr.Method)
+	}
+
+	var ci storiface.CallID
+	if err := json.Unmarshal(dr.Params[0], &ci); err != nil {
+		return storiface.UndefCall, false, xerrors.Errorf("%s: decoding call id: %w", dr.Method, err)
+	}
+
+	var cerr *storiface.CallError
+	if err := json.Unmarshal(dr.Params[len(dr.Params)-1], &cerr); err != nil {
+		return storiface.UndefCall, false, xerrors.Errorf("%s: decoding call error: %w", dr.Method, err)
+	}
+	return ci, cerr == nil, nil
+}
+
+// declareAllocated declares in the index the files allocated for the call p,
+// if its result reports it succeeded, and drops the files it removed. Files
+// the appliance reports it created elsewhere than where they were allocated
+// aren't declared, and files it reports it kept aren't dropped. It's done
+// before the result is delivered, so that the files are found by the next
+// calls on the sector; declaring them again when a result is resent is
+// harmless.
+func (w *DispatchWorker) declareAllocated(ctx context.Context, p *dispatchPending, msg []byte) {
+	ci, ok, err := dispatchOutcome(msg)
+	if err != nil || !ok {
+		return
+	}
+
+	var dr dispatchReturn
+	if err := json.Unmarshal(msg, &dr); err != nil {
+		return
+	}
+
+	for _, d := range p.Decls {
+		if dr.Paths != nil {
+			if reported := storiface.PathByType(*dr.Paths, d.FileType); reported != "" && reported != d.Location {
+				log.Warnf("dispatch worker %s: %s of %s created at %s, but was allocated at %s; not declaring it", w.cfg.Hostname, d.FileType, storiface.SectorName(ci.Sector), reported, d.Location)
+				continue
+			}
+		}
+		if err := w.index.StorageDeclareSector(ctx, d.Storage, ci.Sector, d.FileType, true); err != nil {
+			log.Errorf("dispatch worker %s: declaring %s of %s: %+v", w.cfg.Hostname, d.FileType, storiface.SectorName(ci.Sector), err)
+		}
+	}
+
+	for _, d := range p.Drops {
+		if dr.Paths != nil && storiface.PathByType(*dr.Paths, d.FileType) != "" {
+			continue
+		}
+		if err := w.index.StorageDropSector(ctx, d.Storage, ci.Sector, d.FileType); err != nil {
+			log.Errorf("dispatch worker %s: dropping %s of %s: %+v", w.cfg.Hostname, d.FileType, storiface.SectorName(ci.Sector), err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package sectorstorage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+const (
+	dispatchWindowPoSt  = "GenerateWindowPoSt"
+	dispatchWinningPoSt = "GenerateWinningPoSt"
+)
+
+// dispatchPoStCall is the payload of GenerateWindowPoSt and
+// GenerateWinningPoSt messages, sent to appliances configured for proving.
+// PoSt calls aren't for a sector, so the Sector of their CallID is unset.
+//
+// The appliance reports the result with a ReturnGenerateWindowPoSt message,
+// which Params are the CallID, the proofs, the sectors it skipped because
+// they are faulty, and the CallError; or with a ReturnGenerateWinningPoSt
+// message, without the skipped sectors.
+type dispatchPoStCall struct {
+	CallID  storiface.CallID
+	MinerID abi.ActorID
+
+	// Sectors challenged by the call: all sectors of the partitions proven
+	// for WindowPoSt, the challenged sectors for WinningPoSt
+	Sectors    []proof2.SectorInfo
+	Randomness abi.PoStRandomness
+
+	// Paths of the sealed and cache files of Sectors, in the same order, when
+	// storage paths are configured for the appliance
+	Paths []storiface.SectorPaths `json:",omitempty"`
+}
+
+// postResult hands a PoSt result to the proving call waiting for it. It
+// returns false for results of other calls.
+func (w *DispatchWorker) postResult(msg []byte) bool {
+	var dr dispatchReturn
+	if err := json.Unmarshal(msg, &dr); err != nil {
+		return false
+	}
+	if dr.Method != "Return"+dispatchWindowPoSt && dr.Method != "Return"+dispatchWinningPoSt {
+		return false
+	}
+
+	w.healthy()
+
+	var ci storiface.CallID
+	if len(dr.Params) == 0 || json.Unmarshal(dr.Params[0], &ci) != nil {
+		log.Errorf("dispatch worker %s: %s result without a call id", w.cfg.Hostname, dr.Method)
+		return true
+	}
+
+	w.pendingLk.Lock()
+	ch, ok := w.posts[ci]
+	delete(w.posts, ci)
+	w.pendingLk.Unlock()
+
+	if !ok {
+		log.Warnf("dispatch worker %s: dropping result of PoSt call %s which isn't waited for", w.cfg.Hostname, ci)
+		return true
+	}
+	ch <- dr
+	return true
+}
+
+// prove sends a PoSt call to the appliance, and waits for its result, which
+// params are decoded into out, after the CallID and before the CallError
+func (w *DispatchWorker) prove(ctx context.Context, method string, minerID abi.ActorID, sectors []proof2.SectorInfo, randomness abi.PoStRandomness, out ...interface{}) error {
+	call := dispatchPoStCall{
+		CallID:     storiface.CallID{ID: uuid.New()},
+		MinerID:    minerID,
+		Sectors:    sectors,
+		Randomness: randomness,
+	}
+
+	if len(w.cfg.StorageIDs) > 0 {
+		for _, s := range sectors {
+			ref := storage.SectorRef{ID: abi.SectorID{Miner: minerID, Number: s.SectorNumber}, ProofType: s.SealProof}
+			files := dispatchFileTypes{existing: storiface.FTSealed | storiface.FTCache}
+
+			tok, err := w.callToken(ref.ID, call.CallID, files)
+			if err != nil {
+				return err
+			}
+			if tok != "" {
+				defer w.tokens.revoke(tok)
+			}
+
+			paths, _, _, err := w.sectorPaths(ctx, files, ref, tok)
+			if err != nil {
+				return xerrors.Errorf("resolving sector paths: %w", err)
+			}
+			call.Paths = append(call.Paths, *paths)
+		}
+	}
+
+	payload, err := json.Marshal(&call)
+	if err != nil {
+		return xerrors.Errorf("encoding %s call: %w", method, err)
+	}
+
+	ch := make(chan dispatchReturn, 1)
+	w.pendingLk.Lock()
+	w.posts[call.CallID] = ch
+	w.pendingLk.Unlock()
+
+	defer func() {
+		w.pendingLk.Lock()
+		delete(w.posts, call.CallID)
+		w.pendingLk.Unlock()
+	}()
+
+	if err := w.tr.Send(ctx, method, payload); err != nil {
+		w.strike()
+		return xerrors.Errorf("sending %s call: %w", method, err)
+	}
+
+	var dr dispatchReturn
+	select {
+	case dr = <-ch:
+	case <-ctx.Done():
+		cancel, err := json.Marshal(&dispatchCancel{CallID: call.CallID})
+		if err == nil {
+			_ = w.tr.Send(context.Background(), "Cancel", cancel)
+		}
+		return xerrors.Errorf("waiting for %s result: %w", method, ctx.Err())
+	case <-w.closing:
+		return xerrors.Errorf("waiting for %s result: dispatch worker closed", method)
+	}
+
+	if n := len(out) + 2; len(dr.Params) != n {
+		return xerrors.Errorf("%s: expected %d params, got %d", dr.Method, n, len(dr.Params))
+	}
+
+	var cerr *storiface.CallError
+	if err := json.Unmarshal(dr.Params[len(dr.Params)-1], &cerr); err != nil {
+		return xerrors.Errorf("%s: decoding call error: %w", dr.Method, err)
+	}
+	if cerr != nil {
+		return xerrors.Errorf("%s on the appliance: %w", method, cerr)
+	}
+
+	for i, o := range out {
+		if err := json.Unmarshal(dr.Params[i+1], o); err != nil {
+			return xerrors.Errorf("%s: decoding param %d: %w", dr.Method, i+1, err)
+		}
+	}
+	return nil
+}
+
+func (w *DispatchWorker) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, []abi.SectorID, error) {
+	var proofs []proof2.PoStProof
+	var skipped []abi.SectorID
+	if err := w.prove(ctx, dispatchWindowPoSt, minerID, sectorInfo, randomness, &proofs, &skipped); err != nil {
+		return nil, nil, err
+	}
+	return proofs, skipped, nil
+}
+
+func (w *DispatchWorker) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, error) {
+	var proofs []proof2.PoStProof
+	if err := w.prove(ctx, dispatchWinningPoSt, minerID, sectorInfo, randomness, &proofs); err != nil {
+		return nil, err
+	}
+	return proofs, nil
+}
+
+// provers returns the dispatch workers of appliances configured for proving
+// which take new calls
+func (m *Manager) provers() []*DispatchWorker {
+	var out []*DispatchWorker
+	for _, w := range m.dispatchWorkers() {
+		if w.cfg.PoSt && w.available() {
+			out = append(out, w)
+		}
+	}
+	return out
+}
+
+// dispatchWindowPoSt has an appliance configured for proving generate the
+// WindowPoSt. It returns false if no appliance generated it, in which case
+// the miner proves itself.
+func (m *Manager) dispatchWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, []abi.SectorID, bool) {
+	for _, w := range m.provers() {
+		proofs, skipped, err := w.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness)
+		if err != nil {
+			log.Warnw("generating WindowPoSt on appliance failed", "worker", w.cfg.Hostname, "error", err)
+			continue
+		}
+		return proofs, skipped, true
+	}
+	return nil, nil, false
+}
+
+// dispatchWinningPoSt has an appliance configured for proving generate the
+// WinningPoSt. It returns false if no appliance generated it.
+func (m *Manager) dispatchWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, bool) {
+	for _, w := range m.provers() {
+		proofs, err := w.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness)
+		if err != nil {
+			log.Warnw("generating WinningPoSt on appliance failed", "worker", w.cfg.Hostname, "error", err)
+			continue
+		}
+		return proofs, true
+	}
+	return nil, false
+}
@@ -0,0 +1,220 @@
+package sectorstorage
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// DefaultDispatchRetryBackoff is how long to wait before sending a call again
+// when the config doesn't set a backoff
+var DefaultDispatchRetryBackoff = 30 * time.Second
+
+// dispatchTasks are the task types of dispatched calls, which timeouts are
+// configured by
+var dispatchTasks = map[string]sealtasks.TaskType{
+	"AddPiece":        sealtasks.TTAddPiece,
+	"SealPreCommit1":  sealtasks.TTPreCommit1,
+	"SealPreCommit2":  sealtasks.TTPreCommit2,
+	"SealCommit1":     sealtasks.TTCommit1,
+	"SealCommit2":     sealtasks.TTCommit2,
+	"FinalizeSector":  sealtasks.TTFinalize,
+	"ReleaseUnsealed": sealtasks.TTFinalize,
+	"MoveStorage":     sealtasks.TTFinalize,
+	"UnsealPiece":     sealtasks.TTUnseal,
+	"Fetch":           sealtasks.TTFetch,
+}
+
+func (w *DispatchWorker) timeout(method string) time.Duration {
+	tt, ok := dispatchTasks[method]
+	if !ok {
+		return 0
+	}
+	return time.Duration(w.cfg.TimeoutSecs[tt]) * time.Second
+}
+
+func (w *DispatchWorker) maxAttempts() int {
+	if w.cfg.MaxAttempts < 1 {
+		return 1
+	}
+	return w.cfg.MaxAttempts
+}
+
+// backoff returns how long to wait before sending a call again after the
+// attempt failed; it doubles with every attempt
+func (w *DispatchWorker) backoff(attempt int) time.Duration {
+	b := time.Duration(w.cfg.RetryBackoffSecs) * time.Second
+	if b == 0 {
+		b = DefaultDispatchRetryBackoff
+	}
+	for i := 1; i < attempt && i < 16; i++ {
+		b *= 2
+	}
+	return b
+}
+
+// send sends a tracked call to the appliance, and arms its timeout. Failed
+// sends are retried until the call runs out of attempts.
+func (w *DispatchWorker) send(ctx context.Context, ci storiface.CallID) error {
+	for {
+		w.pendingLk.Lock()
+		p, ok := w.pending[ci]
+		if !ok {
+			// the result came in while waiting to send the call again
+			w.pendingLk.Unlock()
+			return nil
+		}
+		p.Attempts++
+		attempt, method, payload := p.Attempts, p.Method, p.Payload
+		w.pendingLk.Unlock()
+
+		err := w.tr.Send(ctx, method, payload)
+		if err == nil {
+			w.pendingLk.Lock()
+			if p, ok := w.pending[ci]; ok {
+				p.Sent = time.Now()
+				w.arm(p)
+				if err := w.putPending(p); err != nil {
+					log.Errorf("dispatch worker %s: %+v", w.cfg.Hostname, err)
+				}
+			}
+			w.pendingLk.Unlock()
+			return nil
+		}
+
+		if attempt >= w.maxAttempts() {
+			return err
+		}
+		log.Warnw("sending dispatched call failed, retrying", "worker", w.cfg.Hostname, "call", ci, "method", method, "attempt", attempt, "error", err)
+
+		select {
+		case <-time.After(w.backoff(attempt)):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// arm starts the timer failing or resending the call when the appliance
+// doesn't return its result in time; called with pendingLk held
+func (w *DispatchWorker) arm(p *dispatchPending) {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+
+	timeout := w.timeout(p.Method)
+	if timeout == 0 {
+		return
+	}
+
+	sent := p.Sent
+	if sent.IsZero() {
+		sent = p.Started
+	}
+
+	ci := p.ID
+	p.timer = time.AfterFunc(time.Until(sent.Add(timeout)), func() {
+		w.expired(ci)
+	})
+}
+
+// expired sends a call which timed out again, or fails it once it ran out of
+// attempts
+func (w *DispatchWorker) expired(ci storiface.CallID) {
+	w.pendingLk.Lock()
+	p, ok := w.pending[ci]
+	if !ok {
+		w.pendingLk.Unlock()
+		return
+	}
+	p.timer = nil
+	method, attempt := p.Method, p.Attempts
+	// calls restored from before payloads were kept can't be sent again
+	retry := attempt < w.maxAttempts() && len(p.Payload) > 0
+	w.pendingLk.Unlock()
+
+	w.strike()
+
+	if !retry {
+		w.fail(ci, storiface.ErrTempTimeout, xerrors.Errorf("%s call timed out on the appliance after %d attempt(s)", method, attempt))
+		return
+	}
+
+	log.Warnw("dispatched call timed out, sending it again", "worker", w.cfg.Hostname, "call", ci, "method", method, "attempt", attempt)
+
+	go func() {
+		select {
+		case <-time.After(w.backoff(attempt)):
+		case <-w.closing:
+			return
+		}
+		if err := w.send(w.ctx, ci); err != nil {
+			w.fail(ci, storiface.ErrTempUnknown, xerrors.Errorf("sending %s call again: %w", method, err))
+		}
+	}()
+}
+
+// fail reports a retryable error as the result of the call. A result the
+// appliance sends for it later is dropped.
+func (w *DispatchWorker) fail(ci storiface.CallID, code storiface.ErrorCode, err error) {
+	p := w.untrack(ci)
+	if p == nil {
+		return
+	}
+
+	w.pendingLk.Lock()
+	w.failed[ci] = struct{}{}
+	w.pendingLk.Unlock()
+
+	log.Errorw("failing dispatched call", "worker", w.cfg.Hostname, "call", ci, "method", p.Method, "error", err)
+	if err := deliverDispatchError(w.ctx, w.ret, p.Method, ci, storiface.Err(code, err)); err != nil {
+		log.Errorf("dispatch worker %s: delivering error: %+v", w.cfg.Hostname, err)
+	}
+}
+
+// late returns whether a result is for a call which was already failed or
+// aborted, and should be dropped
+func (w *DispatchWorker) late(msg []byte) bool {
+	ci, _, err := dispatchOutcome(msg)
+	if err != nil {
+		return false
+	}
+
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+
+	if _, ok := w.failed[ci]; !ok {
+		return false
+	}
+	delete(w.failed, ci)
+	return true
+}
+
+// deliverDispatchError reports the error through the WorkerReturn method of
+// the call, with zero values for its results
+func deliverDispatchError(ctx context.Context, ret storiface.WorkerReturn, method string, ci storiface.CallID, cerr *storiface.CallError) error {
+	name := "Return" + method
+	mt, ok := returnType.MethodByName(name)
+	if !ok {
+		return xerrors.Errorf("unknown return method %q", name)
+	}
+
+	n := mt.Type.NumIn()
+	args := []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(ci)}
+	for i := 2; i < n-1; i++ {
+		args = append(args, reflect.Zero(mt.Type.In(i)))
+	}
+	args = append(args, reflect.ValueOf(cerr))
+
+	out := reflect.ValueOf(ret).MethodByName(name).Call(args)
+	if err, _ := out[0].Interface().(error); err != nil {
+		return xerrors.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,1450 @@
+package sectorstorage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/lib/nullreader"
+)
+
+type pc2Return struct {
+	storiface.WorkerReturn
+
+	calls chan storiface.CallID
+}
+
+func (r *pc2Return) ReturnSealPreCommit2(ctx context.Context, callID storiface.CallID, sealed storage.SectorCids, err *storiface.CallError) error {
+	r.calls <- callID
+	return nil
+}
+
+type pc1Return struct {
+	storiface.WorkerReturn
+
+	calls chan storiface.CallID
+}
+
+func (r *pc1Return) ReturnSealPreCommit1(ctx context.Context, callID storiface.CallID, p1o storage.PreCommit1Out, err *storiface.CallError) error {
+	r.calls <- callID
+	return nil
+}
+
+func pc2ReturnMsg(t *testing.T, ci storiface.CallID) []byte {
+	ciJSON, err := json.Marshal(ci)
+	require.NoError(t, err)
+
+	msg, err := json.Marshal(&dispatchReturn{
+		Method: "ReturnSealPreCommit2",
+		Params: []json.RawMessage{ciJSON, json.RawMessage(`{}`), json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+	return msg
+}
+
+func TestDeliverDispatchReturn(t *testing.T) {
+	ctx := context.Background()
+	ret := &pc2Return{calls: make(chan storiface.CallID, 1)}
+	ci := storiface.CallID{Sector: abi.SectorID{Miner: 1000, Number: 1}, ID: uuid.New()}
+
+	require.NoError(t, deliverDispatchReturn(ctx, ret, pc2ReturnMsg(t, ci)))
+	require.Equal(t, ci, <-ret.calls)
+
+	require.Error(t, deliverDispatchReturn(ctx, ret, []byte(`{"Method":"Close","Params":[]}`)))
+	require.Error(t, deliverDispatchReturn(ctx, ret, []byte(`{"Method":"ReturnSealPreCommit2","Params":[]}`)))
+}
+
+func TestDispatchWorkerHTTP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	resultsAddr := freeAddr(t)
+	ret := &pc2Return{calls: make(chan storiface.CallID, 1)}
+	unauthorized := make(chan int, 1)
+
+	// the appliance answers every call by posting the result back
+	appliance := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/SealPreCommit2", r.URL.Path)
+
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		var call dispatchCall
+		require.NoError(t, json.Unmarshal(body, &call))
+
+		require.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+
+		go func() {
+			// results without the token are rejected
+			resp, err := http.Post("http://"+resultsAddr, "application/json", bytes.NewReader(pc2ReturnMsg(t, call.CallID)))
+			if err != nil {
+				return
+			}
+			_ = resp.Body.Close()
+			unauthorized <- resp.StatusCode
+
+			resp, err = postDispatchResult(resultsAddr, "secret", pc2ReturnMsg(t, call.CallID))
+			if err == nil {
+				_ = resp.Body.Close()
+			}
+		}()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer appliance.Close()
+
+	cfg := DispatchConfig{
+		Transport:     DispatchHTTP,
+		Endpoint:      appliance.URL,
+		ListenAddress: resultsAddr,
+		Token:         "secret",
+		TaskTypes:     []sealtasks.TaskType{sealtasks.TTPreCommit2},
+	}
+	tr, err := NewDispatchTransport(cfg)
+	require.NoError(t, err)
+
+	w, err := NewDispatchWorker(ctx, cfg, tr, nil, nil, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 2}}
+	ci, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+	require.Equal(t, sector.ID, ci.Sector)
+
+	select {
+	case got := <-ret.calls:
+		require.Equal(t, ci, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("result not delivered")
+	}
+	require.Equal(t, http.StatusUnauthorized, <-unauthorized)
+}
+
+func postDispatchResult(addr, token string, msg []byte) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodPost, "http://"+addr, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return http.DefaultClient.Do(req)
+}
+
+func TestDispatchWorkerHTTPPoll(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ret := &pc2Return{calls: make(chan storiface.CallID, 1)}
+
+	// the appliance queues results until the miner acknowledges them
+	var (
+		lk      sync.Mutex
+		pending [][]byte
+		acked   int
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/SealPreCommit2", func(w http.ResponseWriter, r *http.Request) {
+		var call dispatchCall
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&call))
+
+		lk.Lock()
+		pending = append(pending, pc2ReturnMsg(t, call.CallID))
+		lk.Unlock()
+		w.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("/results", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodGet, r.Method)
+
+		lk.Lock()
+		defer lk.Unlock()
+
+		if after := r.URL.Query().Get("after"); after != "" {
+			n, err := strconv.Atoi(after)
+			require.NoError(t, err)
+			pending = pending[n-acked:]
+			acked = n
+		}
+
+		res := dispatchPollResponse{Cursor: strconv.Itoa(acked + len(pending))}
+		for _, p := range pending {
+			res.Results = append(res.Results, p)
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(&res))
+	})
+	appliance := httptest.NewServer(mux)
+	defer appliance.Close()
+
+	cfg := DispatchConfig{
+		Transport:        DispatchHTTP,
+		ResultMode:       DispatchPoll,
+		Endpoint:         appliance.URL,
+		PollIntervalSecs: 1,
+		TaskTypes:        []sealtasks.TaskType{sealtasks.TTPreCommit2},
+	}
+	tr, err := NewDispatchTransport(cfg)
+	require.NoError(t, err)
+
+	w, err := NewDispatchWorker(ctx, cfg, tr, nil, nil, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 4}}
+	ci, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+
+	select {
+	case got := <-ret.calls:
+		require.Equal(t, ci, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("result not delivered")
+	}
+
+	// the next poll acknowledges the result
+	require.Eventually(t, func() bool {
+		lk.Lock()
+		defer lk.Unlock()
+		return acked == 1 && len(pending) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestDispatchWorkerTCP(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer nl.Close() // nolint
+
+	// the appliance echoes a result for every call frame
+	acks := make(chan string, 1)
+	go func() {
+		conn, err := nl.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() // nolint
+
+		br := bufio.NewReader(conn)
+
+		// the miner authenticates the appliance first
+		msgType, nonce, err := readDispatchFrame(br)
+		if err != nil || msgType != "Auth" {
+			return
+		}
+		if err := writeDispatchFrame(conn, "Auth", dispatchAuthMAC("secret", nonce)); err != nil {
+			return
+		}
+
+		for {
+			msgType, payload, err := readDispatchFrame(br)
+			if err != nil {
+				return
+			}
+			if msgType == "Ack" || msgType == "Nack" {
+				acks <- msgType
+				continue
+			}
+			if msgType != "SealPreCommit2" {
+				return
+			}
+
+			var call dispatchCall
+			if err := json.Unmarshal(payload, &call); err != nil {
+				return
+			}
+			if err := writeDispatchFrame(conn, "return", pc2ReturnMsg(t, call.CallID)); err != nil {
+				return
+			}
+		}
+	}()
+
+	cfg := DispatchConfig{
+		Transport: DispatchTCP,
+		Endpoint:  nl.Addr().String(),
+		Token:     "secret",
+		TaskTypes: []sealtasks.TaskType{sealtasks.TTPreCommit2},
+	}
+	tr, err := NewDispatchTransport(cfg)
+	require.NoError(t, err)
+
+	ret := &pc2Return{calls: make(chan storiface.CallID, 1)}
+	w, err := NewDispatchWorker(ctx, cfg, tr, nil, nil, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 3}}
+	ci, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+
+	select {
+	case got := <-ret.calls:
+		require.Equal(t, ci, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("result not delivered")
+	}
+
+	// the result is acknowledged once taken
+	select {
+	case ack := <-acks:
+		require.Equal(t, "Ack", ack)
+	case <-time.After(5 * time.Second):
+		t.Fatal("result not acknowledged")
+	}
+}
+
+func TestDispatchTCPAuth(t *testing.T) {
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer nl.Close() // nolint
+
+	// the appliance doesn't know the token
+	go func() {
+		for {
+			conn, err := nl.Accept()
+			if err != nil {
+				return
+			}
+			_, nonce, err := readDispatchFrame(bufio.NewReader(conn))
+			if err == nil {
+				_ = writeDispatchFrame(conn, "Auth", dispatchAuthMAC("guess", nonce))
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	tr := newTCPDispatch(nl.Addr().String(), "secret")
+	defer tr.Close() // nolint
+
+	require.Error(t, tr.Send(context.Background(), "SealPreCommit2", []byte("{}")))
+}
+
+func TestDispatchHTTPPushNack(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	addr := freeAddr(t)
+	tr := newHTTPDispatch("http://appliance", addr, "secret", 0)
+	defer tr.Close() // nolint
+
+	msgs, err := tr.Receive(ctx)
+	require.NoError(t, err)
+	go func() {
+		for msg := range msgs {
+			msg.Ack(string(msg.Payload) == "taken")
+		}
+	}()
+
+	post := func(body string) int {
+		resp, err := postDispatchResult(addr, "secret", []byte(body))
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// results which weren't taken are answered with an error, so that the
+	// appliance posts them again
+	require.Equal(t, http.StatusAccepted, post("taken"))
+	require.Equal(t, http.StatusServiceUnavailable, post("rejected"))
+}
+
+type captureTransport struct {
+	sent    chan []byte
+	methods chan string // optional
+}
+
+func (c *captureTransport) Send(ctx context.Context, msgType string, payload []byte) error {
+	if c.methods != nil {
+		c.methods <- msgType
+	}
+	c.sent <- payload
+	return nil
+}
+
+func (c *captureTransport) Receive(ctx context.Context) (<-chan DispatchMessage, error) {
+	return make(chan DispatchMessage), nil
+}
+
+func (c *captureTransport) Close() error {
+	return nil
+}
+
+func TestDispatchCallProofType(t *testing.T) {
+	ctx := context.Background()
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+
+	w, err := NewDispatchWorker(ctx, DispatchConfig{}, tr, nil, nil, nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	// appliances get the seal proof of the sector, whatever its size
+	for _, spt := range []abi.RegisteredSealProof{
+		abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+		abi.RegisteredSealProof_StackedDrg512MiBV1_1,
+		abi.RegisteredSealProof_StackedDrg32GiBV1_1,
+		abi.RegisteredSealProof_StackedDrg64GiBV1_1,
+	} {
+		sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 5}, ProofType: spt}
+		_, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+		require.NoError(t, err)
+
+		var call struct {
+			Params []json.RawMessage
+		}
+		require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+
+		var got storage.SectorRef
+		require.NoError(t, json.Unmarshal(call.Params[0], &got))
+		require.Equal(t, spt, got.ProofType)
+	}
+}
+
+func TestDispatchSectorPaths(t *testing.T) {
+	ctx := context.Background()
+
+	idx := stores.NewIndex()
+	fsStat := fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "shared", URLs: []string{"http://miner/remote"}, CanSeal: true}, fsStat))
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "private", URLs: []string{"http://other/remote"}, CanSeal: true, Weight: 10}, fsStat))
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 6}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, "shared", sector.ID, storiface.FTUnsealed, true))
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	ret := &pc1Return{calls: make(chan storiface.CallID, 1)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		StorageIDs: []stores.ID{"shared"},
+		PathMode:   DispatchURLPaths,
+	}, tr, nil, idx, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	ci, err := w.SealPreCommit1(ctx, sector, abi.SealRandomness{}, nil, nil, storiface.NoNUMANode)
+	require.NoError(t, err)
+
+	var call dispatchCall
+	require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+	require.Equal(t, &storiface.SectorPaths{
+		ID:       sector.ID,
+		Unsealed: "http://miner/remote/unsealed/s-t01000-6",
+		Sealed:   "http://miner/remote/sealed/s-t01000-6",
+		Cache:    "http://miner/remote/cache/s-t01000-6",
+	}, call.Paths)
+
+	// the allocated files are declared once the call succeeds
+	ciJSON, err := json.Marshal(ci)
+	require.NoError(t, err)
+	msg, err := json.Marshal(&dispatchReturn{
+		Method: "ReturnSealPreCommit1",
+		Params: []json.RawMessage{ciJSON, json.RawMessage(`"cDFv"`), json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+
+	require.True(t, w.handle(ctx, msg))
+	require.Equal(t, ci, <-ret.calls)
+
+	found, err := idx.StorageFindSector(ctx, sector.ID, storiface.FTSealed|storiface.FTCache, 0, false)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, stores.ID("shared"), found[0].ID)
+
+	// files which aren't on the appliance storage can't be worked on
+	other := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 7}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, "private", other.ID, storiface.FTSealed|storiface.FTCache, true))
+	_, err = w.SealPreCommit2(ctx, other, storage.PreCommit1Out("pc1"))
+	require.Error(t, err)
+}
+
+func TestDispatchPendingPersisted(t *testing.T) {
+	ctx := context.Background()
+
+	idx := stores.NewIndex()
+	fsStat := fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "shared", URLs: []string{"http://miner/remote"}, CanSeal: true}, fsStat))
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 8}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, "shared", sector.ID, storiface.FTUnsealed, true))
+
+	cfg := DispatchConfig{
+		Endpoint:   "appliance:1234",
+		StorageIDs: []stores.ID{"shared"},
+		PathMode:   DispatchURLPaths,
+	}
+	ds := datastore.NewMapDatastore()
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	w, err := NewDispatchWorker(ctx, cfg, tr, nil, idx, nil)
+	require.NoError(t, err)
+	require.NoError(t, w.persistPending(ds))
+
+	ci, err := w.SealPreCommit1(ctx, sector, abi.SealRandomness{}, nil, nil, storiface.NoNUMANode)
+	require.NoError(t, err)
+	<-tr.sent
+	require.NoError(t, w.Close())
+
+	// the miner restarts, and the result comes in
+	ret := &pc1Return{calls: make(chan storiface.CallID, 1)}
+	w, err = NewDispatchWorker(ctx, cfg, &captureTransport{}, nil, idx, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+	require.NoError(t, w.persistPending(ds))
+
+	// calls of other endpoints aren't picked up
+	other, err := NewDispatchWorker(ctx, DispatchConfig{Endpoint: "other:1234"}, &captureTransport{}, nil, idx, nil)
+	require.NoError(t, err)
+	defer other.Close() // nolint
+	require.NoError(t, other.persistPending(ds))
+	require.Empty(t, other.pending)
+
+	ciJSON, err := json.Marshal(ci)
+	require.NoError(t, err)
+	msg, err := json.Marshal(&dispatchReturn{
+		Method: "ReturnSealPreCommit1",
+		Params: []json.RawMessage{ciJSON, json.RawMessage(`"cDFv"`), json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+	require.True(t, w.handle(ctx, msg))
+	require.Equal(t, ci, <-ret.calls)
+
+	found, err := idx.StorageFindSector(ctx, sector.ID, storiface.FTSealed, 0, false)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	has, err := ds.Has(dispatchPendingKey(ci))
+	require.NoError(t, err)
+	require.False(t, has)
+}
+
+func TestRewritePathPrefix(t *testing.T) {
+	prefixes := map[string]string{
+		"/mnt/sealing":      "/shared/sealing",
+		"/mnt/sealing/fast": "nfs://filer/fast",
+	}
+
+	require.Equal(t, "/shared/sealing/sealed/s-t01000-1", rewritePathPrefix("/mnt/sealing/sealed/s-t01000-1", prefixes))
+	require.Equal(t, "nfs://filer/fast/cache/s-t01000-1", rewritePathPrefix("/mnt/sealing/fast/cache/s-t01000-1", prefixes))
+	require.Equal(t, "/mnt/sealingx/sealed/s-t01000-1", rewritePathPrefix("/mnt/sealingx/sealed/s-t01000-1", prefixes))
+}
+
+func TestDispatchConfigInvalid(t *testing.T) {
+	_, err := NewDispatchTransport(DispatchConfig{Transport: "carrier-pigeon", Endpoint: "x"})
+	require.Error(t, err)
+
+	_, err = NewDispatchTransport(DispatchConfig{Transport: DispatchHTTP, Endpoint: "http://x"})
+	require.Error(t, err, "http needs a listen address")
+
+	_, err = NewDispatchTransport(DispatchConfig{Transport: DispatchHTTP, Endpoint: "http://x", ListenAddress: "127.0.0.1:0"})
+	require.Error(t, err, "pushed results need a token")
+
+	_, err = NewDispatchTransport(DispatchConfig{Transport: DispatchTCP, Endpoint: "x:1"})
+	require.Error(t, err, "tcp needs a token")
+
+	_, err = NewDispatchTransport(DispatchConfig{Transport: DispatchHTTP, ResultMode: DispatchPoll, Endpoint: "http://x"})
+	require.NoError(t, err, "polling doesn't need a listen address")
+
+	_, err = NewDispatchTransport(DispatchConfig{Transport: DispatchHTTP, ResultMode: "carrier-pigeon", Endpoint: "http://x"})
+	require.Error(t, err)
+
+	_, err = NewDispatchTransport(DispatchConfig{Transport: DispatchTCP, ResultMode: DispatchPoll, Endpoint: "x:1"})
+	require.Error(t, err)
+
+	_, err = NewDispatchWorker(context.Background(), DispatchConfig{TaskTypes: []sealtasks.TaskType{sealtasks.TTReadUnsealed}}, nil, nil, nil, nil)
+	require.Error(t, err)
+}
+
+func freeAddr(t *testing.T) string {
+	nl, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := nl.Addr().String()
+	require.NoError(t, nl.Close())
+	return addr
+}
+
+type errReturn struct {
+	storiface.WorkerReturn
+
+	errs chan *storiface.CallError
+}
+
+func (r *errReturn) ReturnSealPreCommit1(ctx context.Context, callID storiface.CallID, p1o storage.PreCommit1Out, err *storiface.CallError) error {
+	r.errs <- err
+	return nil
+}
+
+func (r *errReturn) ReturnSealPreCommit2(ctx context.Context, callID storiface.CallID, sealed storage.SectorCids, err *storiface.CallError) error {
+	r.errs <- err
+	return nil
+}
+
+// flakyTransport fails the first sends
+type flakyTransport struct {
+	captureTransport
+	fails int
+}
+
+func (f *flakyTransport) Send(ctx context.Context, msgType string, payload []byte) error {
+	if f.fails > 0 {
+		f.fails--
+		return xerrors.New("connection refused")
+	}
+	return f.captureTransport.Send(ctx, msgType, payload)
+}
+
+func TestDispatchCallRetries(t *testing.T) {
+	ctx := context.Background()
+
+	backoff := DefaultDispatchRetryBackoff
+	DefaultDispatchRetryBackoff = time.Millisecond
+	defer func() {
+		DefaultDispatchRetryBackoff = backoff
+	}()
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 9}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	cfg := DispatchConfig{
+		TimeoutSecs: map[sealtasks.TaskType]uint64{sealtasks.TTPreCommit1: 3600},
+		MaxAttempts: 2,
+	}
+
+	// failed sends are retried
+	tr := &flakyTransport{captureTransport: captureTransport{sent: make(chan []byte, 2)}, fails: 1}
+	ret := &errReturn{errs: make(chan *storiface.CallError, 1)}
+	w, err := NewDispatchWorker(ctx, cfg, tr, nil, nil, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	ci, err := w.SealPreCommit1(ctx, sector, abi.SealRandomness{}, nil, nil, storiface.NoNUMANode)
+	require.NoError(t, err)
+	first := <-tr.sent
+	require.NotNil(t, w.pending[ci].timer)
+
+	// the call times out, and is sent again
+	w.expired(ci)
+	select {
+	case again := <-tr.sent:
+		require.Equal(t, first, again)
+	case <-time.After(5 * time.Second):
+		t.Fatal("call not sent again")
+	}
+
+	// it runs out of attempts
+	w.expired(ci)
+	select {
+	case cerr := <-ret.errs:
+		require.NotNil(t, cerr)
+		require.Equal(t, storiface.ErrTempTimeout, cerr.Code)
+		require.True(t, cerr.Retryable)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout not reported")
+	}
+	require.Empty(t, w.pending)
+
+	// the result the appliance sends eventually is dropped
+	ciJSON, err := json.Marshal(ci)
+	require.NoError(t, err)
+	msg, err := json.Marshal(&dispatchReturn{
+		Method: "ReturnSealPreCommit1",
+		Params: []json.RawMessage{ciJSON, json.RawMessage(`"cDFv"`), json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+	require.True(t, w.late(msg))
+	require.False(t, w.late(msg))
+
+	// sends failing on every attempt fail the call
+	tr.fails = 2
+	_, err = w.SealPreCommit1(ctx, sector, abi.SealRandomness{}, nil, nil, storiface.NoNUMANode)
+	require.Error(t, err)
+	require.Empty(t, w.pending)
+}
+
+func TestDispatchCallTokens(t *testing.T) {
+	ctx := context.Background()
+
+	idx := stores.NewIndex()
+	fsStat := fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "shared", URLs: []string{"http://miner/remote"}, CanSeal: true}, fsStat))
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 6}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, "shared", sector.ID, storiface.FTUnsealed, true))
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	ret := &pc1Return{calls: make(chan storiface.CallID, 1)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		StorageIDs: []stores.ID{"shared"},
+		PathMode:   DispatchURLPaths,
+	}, tr, nil, idx, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+	w.tokens = newFetchTokens()
+
+	ci, err := w.SealPreCommit1(ctx, sector, abi.SealRandomness{}, nil, nil, storiface.NoNUMANode)
+	require.NoError(t, err)
+
+	var call dispatchCall
+	require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+
+	// only the files the call reads get a token, scoped to them
+	require.Equal(t, "http://miner/remote/sealed/s-t01000-6", call.Paths.Sealed)
+	read := httptest.NewRequest("GET", call.Paths.Unsealed, nil)
+	require.NotEmpty(t, read.URL.Query().Get(stores.FetchTokenParam))
+	require.True(t, w.tokens.allows(read, time.Now()))
+
+	tok := read.URL.Query().Get(stores.FetchTokenParam)
+	other := httptest.NewRequest("GET", "http://miner/remote/sealed/s-t01000-6?"+stores.FetchTokenParam+"="+tok, nil)
+	require.False(t, w.tokens.allows(other, time.Now()))
+
+	// the token is revoked once the call returns
+	ciJSON, err := json.Marshal(ci)
+	require.NoError(t, err)
+	msg, err := json.Marshal(&dispatchReturn{
+		Method: "ReturnSealPreCommit1",
+		Params: []json.RawMessage{ciJSON, json.RawMessage(`"cDFv"`), json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+	require.True(t, w.handle(ctx, msg))
+	require.Equal(t, ci, <-ret.calls)
+
+	require.False(t, w.tokens.allows(read, time.Now()))
+}
+
+func TestDispatchAbort(t *testing.T) {
+	ctx := context.Background()
+	tr := &captureTransport{sent: make(chan []byte, 2)}
+
+	w, err := NewDispatchWorker(ctx, DispatchConfig{}, tr, nil, nil, nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 10}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	ci, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+	<-tr.sent
+
+	ok, err := w.abort(ctx, ci)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Empty(t, w.pending)
+
+	// the appliance is asked to cancel the call
+	var cancel dispatchCancel
+	require.NoError(t, json.Unmarshal(<-tr.sent, &cancel))
+	require.Equal(t, ci, cancel.CallID)
+
+	// its late result is discarded
+	require.True(t, w.late(pc2ReturnMsg(t, ci)))
+
+	// calls which aren't pending can't be aborted
+	ok, err = w.abort(ctx, ci)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestDispatchManagerShutdown(t *testing.T) {
+	ctx := context.Background()
+	tr := &captureTransport{sent: make(chan []byte, 1), methods: make(chan string, 1)}
+
+	w, err := NewDispatchWorker(ctx, DispatchConfig{}, tr, nil, nil, nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	require.NoError(t, w.ManagerShutdown(ctx))
+	require.Equal(t, "ManagerShutdown", <-tr.methods)
+	require.JSONEq(t, "{}", string(<-tr.sent))
+}
+
+func TestDispatchAddPiece(t *testing.T) {
+	ctx := context.Background()
+
+	idx := stores.NewIndex()
+	fsStat := fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "shared", URLs: []string{"http://miner/remote"}, CanSeal: true}, fsStat))
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		StorageIDs: []stores.ID{"shared"},
+		PathMode:   DispatchURLPaths,
+	}, tr, nil, idx, nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	addCC := func(number abi.SectorNumber) (storiface.CallID, dispatchCall) {
+		sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: number}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+		ci, err := w.AddPiece(ctx, sector, nil, 1016, io.LimitReader(&nullreader.Reader{}, 1016))
+		require.NoError(t, err)
+
+		var call dispatchCall
+		require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+		return ci, call
+	}
+	result := func(ci storiface.CallID, unsealed string) []byte {
+		ciJSON, err := json.Marshal(ci)
+		require.NoError(t, err)
+		msg, err := json.Marshal(&dispatchReturn{
+			Method: "ReturnAddPiece",
+			Params: []json.RawMessage{ciJSON, json.RawMessage(`{}`), json.RawMessage(`null`)},
+			Paths:  &storiface.SectorPaths{ID: ci.Sector, Unsealed: unsealed},
+		})
+		require.NoError(t, err)
+		return msg
+	}
+
+	// pieces of zeroes are generated by the appliance
+	ci, call := addCC(11)
+	require.Len(t, call.Params, 4)
+	var data dispatchPieceData
+	require.NoError(t, json.Unmarshal(call.Params[3], &data))
+	require.Equal(t, dispatchPieceData{Zero: true}, data)
+	require.Equal(t, "http://miner/remote/unsealed/s-t01000-11", call.Paths.Unsealed)
+
+	// the unsealed file is declared where the appliance reports creating it
+	w.declareAllocated(ctx, pendingOf(w, ci), result(ci, call.Paths.Unsealed))
+	found, err := idx.StorageFindSector(ctx, ci.Sector, storiface.FTUnsealed, 0, false)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+
+	// but not if it was created elsewhere
+	ci, _ = addCC(12)
+	w.declareAllocated(ctx, pendingOf(w, ci), result(ci, "http://appliance/scratch/s-t01000-12"))
+	found, err = idx.StorageFindSector(ctx, ci.Sector, storiface.FTUnsealed, 0, false)
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	// piece data can only be staged on storage shared with the miner
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 13}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	_, err = w.AddPiece(ctx, sector, nil, 4, bytes.NewReader([]byte("data")))
+	require.Error(t, err)
+	for ci := range w.pending {
+		require.NotEqual(t, sector.ID, ci.Sector)
+	}
+}
+
+func TestDispatchFinalizeRemote(t *testing.T) {
+	ctx := context.Background()
+
+	idx := stores.NewIndex()
+	fsStat := fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "shared", URLs: []string{"http://miner/remote"}, CanSeal: true}, fsStat))
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		StorageIDs: []stores.ID{"shared"},
+		PathMode:   DispatchURLPaths,
+	}, tr, nil, idx, nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	result := func(method string, ci storiface.CallID, paths *storiface.SectorPaths) []byte {
+		ciJSON, err := json.Marshal(ci)
+		require.NoError(t, err)
+		msg, err := json.Marshal(&dispatchReturn{
+			Method: method,
+			Params: []json.RawMessage{ciJSON, json.RawMessage(`null`)},
+			Paths:  paths,
+		})
+		require.NoError(t, err)
+		return msg
+	}
+	unsealedOn := func(sector abi.SectorID) int {
+		found, err := idx.StorageFindSector(ctx, sector, storiface.FTUnsealed, 0, false)
+		require.NoError(t, err)
+		return len(found)
+	}
+
+	// finalizing without ranges to keep removes the unsealed file where it is
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 21}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, "shared", sector.ID, storiface.FTSealed|storiface.FTCache|storiface.FTUnsealed, true))
+
+	ci, err := w.FinalizeSector(ctx, sector, nil)
+	require.NoError(t, err)
+	var call dispatchCall
+	require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+	require.Equal(t, "http://miner/remote/unsealed/s-t01000-21", call.Paths.Unsealed)
+	require.Equal(t, "http://miner/remote/cache/s-t01000-21", call.Paths.Cache)
+
+	w.declareAllocated(ctx, pendingOf(w, ci), result("ReturnFinalizeSector", ci, nil))
+	require.Equal(t, 0, unsealedOn(sector.ID))
+
+	// sectors without an unsealed file can be finalized too
+	ci, err = w.FinalizeSector(ctx, sector, nil)
+	require.NoError(t, err)
+	call = dispatchCall{}
+	require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+	require.Empty(t, call.Paths.Unsealed)
+	w.declareAllocated(ctx, pendingOf(w, ci), result("ReturnFinalizeSector", ci, nil))
+
+	// the unsealed file stays declared when the appliance reports keeping it
+	sector.ID.Number = 22
+	require.NoError(t, idx.StorageDeclareSector(ctx, "shared", sector.ID, storiface.FTUnsealed, true))
+
+	ci, err = w.ReleaseUnsealed(ctx, sector, []storage.Range{{Offset: 0, Size: 1016}})
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+	w.declareAllocated(ctx, pendingOf(w, ci), result("ReturnReleaseUnsealed", ci, &storiface.SectorPaths{ID: sector.ID, Unsealed: call.Paths.Unsealed}))
+	require.Equal(t, 1, unsealedOn(sector.ID))
+
+	ci, err = w.ReleaseUnsealed(ctx, sector, nil)
+	require.NoError(t, err)
+	<-tr.sent
+	w.declareAllocated(ctx, pendingOf(w, ci), result("ReturnReleaseUnsealed", ci, nil))
+	require.Equal(t, 0, unsealedOn(sector.ID))
+}
+
+func TestDispatchSplitPreCommit(t *testing.T) {
+	ctx := context.Background()
+
+	idx := stores.NewIndex()
+	fsStat := fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "cpu", URLs: []string{"http://cpu/remote"}, CanSeal: true}, fsStat))
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "gpu", URLs: []string{"http://gpu/remote"}, CanSeal: true}, fsStat))
+
+	// PreCommit1 ran on the cpu appliance
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 8}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, "cpu", sector.ID, storiface.FTSealed|storiface.FTCache, true))
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		TaskTypes:  []sealtasks.TaskType{sealtasks.TTPreCommit2, sealtasks.TTFetch},
+		StorageIDs: []stores.ID{"gpu"},
+		PathMode:   DispatchURLPaths,
+	}, tr, nil, idx, &pc2Return{calls: make(chan storiface.CallID, 1)})
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	// the files aren't on the gpu appliance storage yet
+	_, err = w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.Error(t, err)
+
+	ci, err := w.Fetch(ctx, sector, storiface.FTSealed|storiface.FTCache, storiface.PathSealing, storiface.AcquireMove, "tok")
+	require.NoError(t, err)
+
+	var call struct {
+		Params []json.RawMessage
+		Paths  *storiface.SectorPaths
+	}
+	require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+	require.Len(t, call.Params, 6)
+
+	var src dispatchFetchSources
+	require.NoError(t, json.Unmarshal(call.Params[5], &src))
+	require.Equal(t, &storiface.SectorPaths{
+		ID:     sector.ID,
+		Sealed: "http://cpu/remote/sealed/s-t01000-8",
+		Cache:  "http://cpu/remote/cache/s-t01000-8",
+	}, src.Sources)
+	require.Equal(t, &storiface.SectorPaths{
+		ID:     sector.ID,
+		Sealed: "http://gpu/remote/sealed/s-t01000-8",
+		Cache:  "http://gpu/remote/cache/s-t01000-8",
+	}, call.Paths)
+
+	// the copies are declared once the fetch succeeds
+	ciJSON, err := json.Marshal(ci)
+	require.NoError(t, err)
+	msg, err := json.Marshal(&dispatchReturn{
+		Method: "ReturnFetch",
+		Params: []json.RawMessage{ciJSON, json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+	w.declareAllocated(ctx, pendingOf(w, ci), msg)
+
+	_, err = w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+
+	var pc2 dispatchCall
+	require.NoError(t, json.Unmarshal(<-tr.sent, &pc2))
+	require.Equal(t, "http://gpu/remote/sealed/s-t01000-8", pc2.Paths.Sealed)
+	require.Equal(t, "cGMx", pc2.Params[1]) // the PreCommit1 output
+
+	// files already on the appliance storage aren't fetched again
+	_, err = w.Fetch(ctx, sector, storiface.FTSealed|storiface.FTCache, storiface.PathSealing, storiface.AcquireMove, "tok")
+	require.NoError(t, err)
+	var again dispatchCall
+	require.NoError(t, json.Unmarshal(<-tr.sent, &again))
+	require.Nil(t, again.Paths)
+	require.Equal(t, map[string]interface{}{}, again.Params[5])
+}
+
+func TestDispatchSplitCommit(t *testing.T) {
+	ctx := context.Background()
+
+	idx := stores.NewIndex()
+	fsStat := fsutil.FsStat{Capacity: 1 << 30, Available: 1 << 30}
+	require.NoError(t, idx.StorageAttach(ctx, stores.StorageInfo{ID: "gpu", URLs: []string{"http://gpu/remote"}, CanSeal: true}, fsStat))
+
+	// Commit1 ran near the storage, the gpu appliance doesn't have the files
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 9}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+
+	ds := datastore.NewMapDatastore()
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		Endpoint:   "gpu:1234",
+		TaskTypes:  []sealtasks.TaskType{sealtasks.TTCommit2},
+		StorageIDs: []stores.ID{"gpu"},
+		PathMode:   DispatchURLPaths,
+	}, tr, nil, idx, nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+	require.NoError(t, w.persistPending(ds))
+
+	c1o := make(storage.Commit1Out, 2<<20)
+	for i := range c1o {
+		c1o[i] = byte(i)
+	}
+
+	ci, err := w.SealCommit2(ctx, sector, c1o)
+	require.NoError(t, err)
+
+	var call struct {
+		Params []json.RawMessage
+		Paths  *storiface.SectorPaths
+	}
+	require.NoError(t, json.Unmarshal(<-tr.sent, &call))
+	require.Nil(t, call.Paths)
+
+	var sent storage.Commit1Out
+	require.NoError(t, json.Unmarshal(call.Params[1], &sent))
+	require.Equal(t, c1o, sent)
+
+	// the payload is too large to persist, but is kept to send it again
+	b, err := ds.Get(dispatchPendingKey(ci))
+	require.NoError(t, err)
+	var rec dispatchPending
+	require.NoError(t, json.Unmarshal(b, &rec))
+	require.Empty(t, rec.Payload)
+
+	w.pendingLk.Lock()
+	require.NotEmpty(t, w.pending[ci].Payload)
+	w.pendingLk.Unlock()
+}
+
+func TestDispatchDiscovery(t *testing.T) {
+	ctx := context.Background()
+
+	var srvs []*net.SRV
+	dispatchLookupSRV = func(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+		require.Equal(t, "_sealer._tcp.sealers.example", name)
+		return name, srvs, nil
+	}
+	defer func() {
+		dispatchLookupSRV = net.DefaultResolver.LookupSRV
+	}()
+
+	added := map[string]*DispatchWorker{}
+	d := &dispatchDiscovery{
+		cfg: DispatchConfig{Transport: DispatchTCP, Discover: "_sealer._tcp.sealers.example", TaskTypes: []sealtasks.TaskType{sealtasks.TTPreCommit1}},
+		newWorker: func(cfg DispatchConfig) (*DispatchWorker, error) {
+			return NewDispatchWorker(ctx, cfg, &captureTransport{sent: make(chan []byte, 1)}, nil, stores.NewIndex(), nil)
+		},
+		add: func(w *DispatchWorker) error {
+			added[w.cfg.Endpoint] = w
+			return nil
+		},
+		remove: func(w *DispatchWorker) {
+			delete(added, w.cfg.Endpoint)
+		},
+		workers: map[string]*DispatchWorker{},
+	}
+
+	srvs = []*net.SRV{{Target: "a.sealers.example.", Port: 1234}, {Target: "b.sealers.example.", Port: 1234}}
+	require.NoError(t, d.resolve(ctx))
+	require.Len(t, added, 2)
+	a := added["a.sealers.example:1234"]
+	require.NotNil(t, a)
+	require.Equal(t, "a.sealers.example", a.cfg.Hostname)
+
+	// b leaves while a call is pending on it
+	b := added["b.sealers.example:1234"]
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 10}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	ci, err := b.SealPreCommit1(ctx, sector, abi.SealRandomness{}, nil, nil, storiface.NoNUMANode)
+	require.NoError(t, err)
+
+	srvs = srvs[:1]
+	require.NoError(t, d.resolve(ctx))
+	d.reap()
+	require.Len(t, added, 2)
+
+	tasks, err := b.TaskTypes(ctx)
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+	tasks, err = a.TaskTypes(ctx)
+	require.NoError(t, err)
+	require.Len(t, tasks, 1)
+
+	// it's removed once the call is done
+	b.untrack(ci)
+	d.reap()
+	require.Len(t, added, 1)
+	require.NotNil(t, added["a.sealers.example:1234"])
+
+	// endpoints can't be set along with discovery, nor http push results
+	require.Error(t, checkDispatchDiscovery(DispatchConfig{Discover: "x", Endpoint: "y"}))
+	require.Error(t, checkDispatchDiscovery(DispatchConfig{Discover: "x", Transport: DispatchHTTP}))
+	require.NoError(t, checkDispatchDiscovery(DispatchConfig{Discover: "x", Transport: DispatchHTTP, ResultMode: DispatchPoll}))
+}
+
+func TestDispatchOrder(t *testing.T) {
+	ctx := context.Background()
+
+	worker := func(srv *dispatchSRV) *workerHandle {
+		w, err := NewDispatchWorker(ctx, DispatchConfig{UnhealthyAfter: 100}, &captureTransport{}, nil, stores.NewIndex(), nil)
+		require.NoError(t, err)
+		w.setSRV(srv)
+		return &workerHandle{workerRpc: w}
+	}
+
+	primary := worker(&dispatchSRV{name: "sealers", priority: 10, weight: 1})
+	heavy := worker(&dispatchSRV{name: "sealers", priority: 20, weight: 3})
+	light := worker(&dispatchSRV{name: "sealers", priority: 20, weight: 1})
+	other := worker(&dispatchSRV{name: "others", priority: 0, weight: 1})
+
+	// lower priorities are picked first
+	less, ok := dispatchOrder{}.less(WorkerID{1}, WorkerID{2}, primary, heavy)
+	require.True(t, ok)
+	require.True(t, less)
+
+	// appliances discovered with other names, and other workers, are left to
+	// the task selector
+	_, ok = dispatchOrder{}.less(WorkerID{1}, WorkerID{4}, primary, other)
+	require.False(t, ok)
+	_, ok = dispatchOrder{}.less(WorkerID{1}, WorkerID{5}, primary, &workerHandle{workerRpc: &testWorker{}})
+	require.False(t, ok)
+
+	// appliances of the same priority are picked in proportion to their weight
+	picked := func() int {
+		var n int
+		for i := 0; i < 2000; i++ {
+			if less, _ := (dispatchOrder{}).less(WorkerID{2}, WorkerID{3}, heavy, light); less {
+				n++
+			}
+		}
+		return n
+	}
+	require.InDelta(t, 1500, picked(), 150)
+
+	// and failing ones are picked less
+	for i := 0; i < 5; i++ {
+		heavy.workerRpc.(*DispatchWorker).strike()
+	}
+	require.InDelta(t, 667, picked(), 150)
+}
+
+func TestDispatchUnhealthy(t *testing.T) {
+	ctx := context.Background()
+
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		Hostname:       "appliance",
+		TaskTypes:      []sealtasks.TaskType{sealtasks.TTPreCommit1},
+		UnhealthyAfter: 2,
+		UnhealthySecs:  3600,
+	}, &captureTransport{}, nil, stores.NewIndex(), nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	// a result in between resets the count
+	w.strike()
+	w.healthy()
+	w.strike()
+	require.True(t, w.available())
+
+	w.strike()
+	require.False(t, w.available())
+
+	tasks, err := w.TaskTypes(ctx)
+	require.NoError(t, err)
+	require.Empty(t, tasks)
+
+	w.pendingLk.Lock()
+	w.unhealthyUntil = time.Now()
+	w.pendingLk.Unlock()
+	require.True(t, w.available())
+}
+
+type loopTransport struct {
+	captureTransport
+	recv chan DispatchMessage
+}
+
+func (l *loopTransport) Receive(ctx context.Context) (<-chan DispatchMessage, error) {
+	return l.recv, nil
+}
+
+func TestDispatchPoSt(t *testing.T) {
+	ctx := context.Background()
+
+	tr := &loopTransport{captureTransport: captureTransport{sent: make(chan []byte, 1)}, recv: make(chan DispatchMessage)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{PoSt: true}, tr, nil, nil, nil)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	sectors := []proof2.SectorInfo{{SealProof: abi.RegisteredSealProof_StackedDrg2KiBV1_1, SectorNumber: 3}}
+
+	go func() {
+		var call dispatchPoStCall
+		if err := json.Unmarshal(<-tr.sent, &call); err != nil {
+			return
+		}
+		ciJSON, _ := json.Marshal(call.CallID)
+		msg, _ := json.Marshal(&dispatchReturn{
+			Method: "ReturnGenerateWindowPoSt",
+			Params: []json.RawMessage{ciJSON, json.RawMessage(`[{"PoStProof":5,"ProofBytes":"cHJvb2Y="}]`), json.RawMessage(`[{"Miner":1000,"Number":3}]`), json.RawMessage(`null`)},
+		})
+		tr.recv <- DispatchMessage{Payload: msg, Ack: func(bool) {}}
+	}()
+
+	proofs, skipped, err := w.GenerateWindowPoSt(ctx, 1000, sectors, abi.PoStRandomness{1})
+	require.NoError(t, err)
+	require.Len(t, proofs, 1)
+	require.Equal(t, []byte("proof"), proofs[0].ProofBytes)
+	require.Equal(t, []abi.SectorID{{Miner: 1000, Number: 3}}, skipped)
+
+	// errors reported by the appliance fail the call
+	go func() {
+		var call dispatchPoStCall
+		if err := json.Unmarshal(<-tr.sent, &call); err != nil {
+			return
+		}
+		ciJSON, _ := json.Marshal(call.CallID)
+		msg, _ := json.Marshal(&dispatchReturn{
+			Method: "ReturnGenerateWinningPoSt",
+			Params: []json.RawMessage{ciJSON, json.RawMessage(`null`), json.RawMessage(`{"Code":0,"Message":"no gpu"}`)},
+		})
+		tr.recv <- DispatchMessage{Payload: msg, Ack: func(bool) {}}
+	}()
+
+	_, err = w.GenerateWinningPoSt(ctx, 1000, sectors, abi.PoStRandomness{1})
+	require.Error(t, err)
+	require.Empty(t, w.posts)
+}
+
+func pendingOf(w *DispatchWorker, ci storiface.CallID) *dispatchPending {
+	w.pendingLk.Lock()
+	defer w.pendingLk.Unlock()
+	return w.pending[ci]
+}
+
+type rejectingReturn struct {
+	pc2Return
+
+	reject bool
+}
+
+func (r *rejectingReturn) ReturnSealPreCommit2(ctx context.Context, callID storiface.CallID, sealed storage.SectorCids, err *storiface.CallError) error {
+	if r.reject {
+		return xerrors.Errorf("miner shutting down; return after restart")
+	}
+	return r.pc2Return.ReturnSealPreCommit2(ctx, callID, sealed, err)
+}
+
+func TestDispatchHandleResult(t *testing.T) {
+	ctx := context.Background()
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	ret := &rejectingReturn{pc2Return: pc2Return{calls: make(chan storiface.CallID, 1)}}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{}, tr, nil, nil, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 30}}
+	ci, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+	<-tr.sent
+
+	// results of calls which aren't pending on the worker are dropped
+	require.True(t, w.handle(ctx, pc2ReturnMsg(t, storiface.CallID{Sector: sector.ID, ID: uuid.New()})))
+
+	// and so are results reported through the return method of another call
+	ciJSON, err := json.Marshal(ci)
+	require.NoError(t, err)
+	pc1, err := json.Marshal(&dispatchReturn{
+		Method: "ReturnSealPreCommit1",
+		Params: []json.RawMessage{ciJSON, json.RawMessage(`"cDFv"`), json.RawMessage(`null`)},
+	})
+	require.NoError(t, err)
+	require.True(t, w.handle(ctx, pc1))
+	require.Empty(t, ret.calls)
+
+	// results the miner doesn't accept yet aren't taken, and the call stays
+	// pending until the appliance sends the result again
+	ret.reject = true
+	require.False(t, w.handle(ctx, pc2ReturnMsg(t, ci)))
+	require.NotNil(t, pendingOf(w, ci))
+
+	ret.reject = false
+	require.True(t, w.handle(ctx, pc2ReturnMsg(t, ci)))
+	require.Equal(t, ci, <-ret.calls)
+	require.Nil(t, pendingOf(w, ci))
+}
+
+func TestDispatchJobTransport(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir, err := ioutil.TempDir("", "dispatch-jobs")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	tmpl := filepath.Join(dir, "job.tmpl")
+	require.NoError(t, ioutil.WriteFile(tmpl, []byte("{{.Method}} {{.Sector}} {{.CallFile}} {{.ResultFile}}"), 0644))
+
+	cfg := DispatchConfig{
+		Transport:        DispatchJob,
+		Endpoint:         "cluster",
+		PollIntervalSecs: 1,
+		JobDir:           filepath.Join(dir, "jobs"),
+		JobTemplate:      tmpl,
+		SubmitCommand:    []string{"sh", "-c", "cat > " + filepath.Join(dir, "submitted")},
+		TaskTypes:        []sealtasks.TaskType{sealtasks.TTPreCommit2},
+	}
+	tr, err := NewDispatchTransport(cfg)
+	require.NoError(t, err)
+
+	ret := &pc2Return{calls: make(chan storiface.CallID, 1)}
+	w, err := NewDispatchWorker(ctx, cfg, tr, nil, nil, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 5}}
+	ci, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+
+	// the job manifest is rendered from the template and submitted
+	manifest, err := ioutil.ReadFile(filepath.Join(dir, "submitted"))
+	require.NoError(t, err)
+	fields := strings.Fields(string(manifest))
+	require.Len(t, fields, 4)
+	require.Equal(t, "SealPreCommit2", fields[0])
+	require.Equal(t, "s-t01000-5", fields[1])
+
+	var call dispatchCall
+	b, err := ioutil.ReadFile(fields[2])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(b, &call))
+	require.Equal(t, ci, call.CallID)
+
+	// the job writes its result, which is taken and removed
+	require.NoError(t, ioutil.WriteFile(fields[3], pc2ReturnMsg(t, ci), 0644))
+	select {
+	case got := <-ret.calls:
+		require.Equal(t, ci, got)
+	case <-time.After(5 * time.Second):
+		t.Fatal("result not delivered")
+	}
+	require.Eventually(t, func() bool {
+		_, rerr := os.Stat(fields[3])
+		_, cerr := os.Stat(fields[2])
+		return os.IsNotExist(rerr) && os.IsNotExist(cerr)
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// failed submissions fail the send
+	cfg.SubmitCommand = []string{"false"}
+	tr, err = NewDispatchTransport(cfg)
+	require.NoError(t, err)
+	require.Error(t, tr.Send(ctx, "SealPreCommit2", b))
+
+	_, err = NewDispatchTransport(DispatchConfig{Transport: DispatchJob, Endpoint: "cluster"})
+	require.Error(t, err, "jobs need a dir, a template and a submit command")
+	require.Error(t, checkDispatchDiscovery(DispatchConfig{Discover: "x", Transport: DispatchJob}))
+}
+
+func TestDispatchChecksums(t *testing.T) {
+	ctx := context.Background()
+
+	st := newTestStorage(t)
+	defer st.cleanup()
+	idx := stores.NewIndex()
+	lstor, err := stores.NewLocal(ctx, st, idx, nil)
+	require.NoError(t, err)
+
+	local, err := lstor.Local(ctx)
+	require.NoError(t, err)
+	require.Len(t, local, 1)
+
+	// the appliance sealed onto storage shared with the miner
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 40}, ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1}
+	name := storiface.SectorName(sector.ID)
+	sealed := filepath.Join(local[0].LocalPath, storiface.FTSealed.String(), name)
+	cache := filepath.Join(local[0].LocalPath, storiface.FTCache.String(), name)
+	require.NoError(t, os.MkdirAll(cache, 0755))
+	require.NoError(t, ioutil.WriteFile(sealed, []byte("sealed"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(cache, "p_aux"), []byte("paux"), 0644))
+	require.NoError(t, idx.StorageDeclareSector(ctx, local[0].ID, sector.ID, storiface.FTSealed|storiface.FTCache, true))
+
+	sums := &dispatchChecksums{}
+	sums.Sealed, err = fileChecksum(sealed)
+	require.NoError(t, err)
+	sums.Cache, err = cacheChecksum(cache)
+	require.NoError(t, err)
+
+	tr := &captureTransport{sent: make(chan []byte, 1)}
+	ret := &errReturn{errs: make(chan *storiface.CallError, 1)}
+	w, err := NewDispatchWorker(ctx, DispatchConfig{
+		StorageIDs:      []stores.ID{local[0].ID},
+		VerifyChecksums: true,
+	}, tr, lstor, idx, ret)
+	require.NoError(t, err)
+	defer w.Close() // nolint
+
+	result := func(ci storiface.CallID, sums *dispatchChecksums) []byte {
+		ciJSON, err := json.Marshal(ci)
+		require.NoError(t, err)
+		msg, err := json.Marshal(&dispatchReturn{
+			Method:    "ReturnSealPreCommit2",
+			Params:    []json.RawMessage{ciJSON, json.RawMessage(`{}`), json.RawMessage(`null`)},
+			Checksums: sums,
+		})
+		require.NoError(t, err)
+		return msg
+	}
+	// results aren't taken until the files are hashed, the appliance sends
+	// them again meanwhile
+	handled := func(msg []byte) {
+		require.Eventually(t, func() bool {
+			return w.handle(ctx, msg)
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	ci, err := w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+	<-tr.sent
+	handled(result(ci, sums))
+	require.Nil(t, <-ret.errs)
+
+	// corrupted output fails the call with a retryable error
+	ci, err = w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+	<-tr.sent
+	handled(result(ci, &dispatchChecksums{Sealed: sums.Sealed, Cache: "00"}))
+	cerr := <-ret.errs
+	require.NotNil(t, cerr)
+	require.Equal(t, storiface.ErrTempUnknown, cerr.Code)
+
+	// and so do results without checksums
+	ci, err = w.SealPreCommit2(ctx, sector, storage.PreCommit1Out("pc1"))
+	require.NoError(t, err)
+	<-tr.sent
+	handled(result(ci, nil))
+	require.NotNil(t, <-ret.errs)
+	require.Empty(t, w.verifying)
+}
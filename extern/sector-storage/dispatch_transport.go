@@ -0,0 +1,238 @@
+package sectorstorage
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// DispatchTransport carries calls to an external sealing appliance, such as an
+// ASIC or FPGA sealer, and the results it reports back. Calls are sent with the
+// WorkerCalls method name as the message type; payloads are JSON, as described
+// by the dispatchschema package. Aborted calls are cancelled with a Cancel
+// message carrying their CallID. A ManagerShutdown message with an empty
+// payload is sent when the miner shuts down. Appliances can fetch the proof parameters
+// the miner uses from its /params HTTP endpoint.
+type DispatchTransport interface {
+	// Send delivers a message to the appliance
+	Send(ctx context.Context, msgType string, payload []byte) error
+
+	// Receive returns the messages the appliance sends back, until ctx is
+	// done or the transport is closed
+	Receive(ctx context.Context) (<-chan DispatchMessage, error)
+
+	Close() error
+}
+
+// DispatchMessage is a message received from the appliance. Ack must be
+// called once it's handled, telling whether the miner took it; the appliance
+// sends messages which weren't taken again later, e.g. results the miner
+// couldn't accept while shutting down.
+type DispatchMessage struct {
+	Payload []byte
+	Ack     func(taken bool)
+}
+
+const (
+	DispatchHTTP = "http"
+	DispatchTCP  = "tcp"
+	// DispatchJob submits every call as a job of a cluster scheduler
+	DispatchJob = "job"
+)
+
+const (
+	// DispatchPush has the appliance POST results to the miner
+	DispatchPush = "push"
+	// DispatchPoll has the miner poll the appliance for results, for sealing
+	// clusters which can't open connections to the miner
+	DispatchPoll = "poll"
+)
+
+// DefaultDispatchPollInterval is how often results are polled for when the
+// config doesn't set an interval
+var DefaultDispatchPollInterval = 2 * time.Second
+
+// DispatchConfig configures an external sealing appliance, which is added as a
+// worker driven through a DispatchTransport.
+type DispatchConfig struct {
+	// Transport is "http", "tcp" or "job"
+	Transport string
+
+	// Endpoint calls are sent to; a base URL for http, host:port for tcp,
+	// and a name identifying the cluster for job
+	Endpoint string
+
+	// Name of SRV records to discover appliances with instead of setting an
+	// Endpoint, e.g. "_sealer._tcp.sealers.example", so that appliances of
+	// autoscaled fleets join and leave without config changes. Every target
+	// gets a worker with this config; http ones are sent calls at
+	// http://<target>:<port>, and must use the poll result mode. Workers of
+	// targets which leave are removed once their calls are done. The
+	// scheduler picks among targets as SRV clients do, lower priorities
+	// first, then at random in proportion to their weight, scaled down by
+	// the failed sends and timeouts the appliance had in a row.
+	Discover string
+
+	// Seconds between resolutions of Discover; 0 = default
+	DiscoverIntervalSecs uint64
+
+	// How the miner receives results over http; "push" (default), the
+	// appliance POSTs them to ListenAddress, or "poll", the miner fetches them
+	// from <Endpoint>/results. The tcp transport receives results on the
+	// connection it dials, so it doesn't need either.
+	ResultMode string
+
+	// Address the appliance posts results to; http push only
+	ListenAddress string
+
+	// Secret shared with the appliance, required for http push and for tcp.
+	// The miner sends it as a bearer token with its http requests, and
+	// rejects pushed results which don't carry it. Over tcp, the miner opens
+	// every connection with an Auth frame carrying a random nonce, which the
+	// appliance must answer with an Auth frame carrying the HMAC-SHA256 of
+	// the nonce keyed with the secret.
+	Token string
+
+	// Seconds between polls for results; http poll and job, 0 = default
+	PollIntervalSecs uint64
+
+	// Directory shared with the jobs of the job transport, which submits
+	// every call as a batch job of a cluster scheduler, e.g. a Kubernetes Job
+	// or a Slurm batch job, instead of sending it to a long-running
+	// appliance. Calls are written to <JobDir>/calls/<id>.json, and jobs write
+	// their results to <JobDir>/results/<id>.json.
+	JobDir string
+
+	// text/template file rendering the job manifest of a call, with the
+	// fields of dispatchJob: Method, ID, Sector, CallFile and ResultFile
+	JobTemplate string
+
+	// Command submitting a job, which gets the manifest on stdin, e.g.
+	// ["kubectl", "create", "-f", "-"] or ["sbatch"]
+	SubmitCommand []string
+
+	// Command cancelling the job of an aborted call, which arguments are
+	// templates like JobTemplate, e.g. ["scancel", "--name", "seal-{{.ID}}"];
+	// when unset, the jobs of aborted calls run to the end
+	CancelCommand []string
+
+	Hostname string
+
+	// Sealing software version of the appliance, recorded with the sectors
+	// it seals
+	Version string
+
+	// Task types the appliance runs. Reads stream data through the worker,
+	// which transports can't carry, so they can't be dispatched. The data of
+	// AddPiece is staged on StorageIDs shared with the appliance, except for
+	// pieces of zeroes, which the appliance generates. Phases can be split
+	// across appliances, e.g. PreCommit1 on CPU appliances and PreCommit2 on
+	// GPU appliances, with Fetch having the files copied between them.
+	// Commit2 only needs the Commit1 output sent with it, not the sector
+	// files, so it can run on appliances without storage.
+	TaskTypes []sealtasks.TaskType
+
+	// Generate WindowPoSt and WinningPoSt on the appliance, e.g. a dedicated
+	// GPU box, instead of in the miner process. The miner proves itself when
+	// no appliance configured for proving is available, or proving fails on
+	// it.
+	PoSt bool
+
+	// Storage paths the appliance has access to. When set, calls carry the
+	// locations of the sector files they work on, on these paths, and files
+	// created by calls are declared on them.
+	StorageIDs []stores.ID
+
+	// How sector file locations are sent; "path" (default), the local paths
+	// on the miner, for storage shared with the appliance, or "url", the
+	// urls the files are served from by the miner. URLs of files read by a
+	// call carry a fetch token only valid for them until the call returns.
+	PathMode string
+
+	// Prefixes of local paths replaced before paths are sent, e.g. with the
+	// mount points of shared storage on the appliance, or with NFS urls
+	PathPrefixes map[string]string
+
+	// Require the appliance to report blake2b checksums of the sealed file
+	// and cache with PreCommit2 results, and check them against the files on
+	// storage local to the miner before the sector advances, to catch
+	// corruption in transfers. Mismatches fail the call with a retryable
+	// error, so PreCommit2 runs again.
+	VerifyChecksums bool
+
+	// Seconds a call of each task type may run on the appliance before it's
+	// considered hung, e.g. {"seal/v0/precommit/1": 36000}. Calls of task
+	// types without a timeout wait for their result indefinitely.
+	TimeoutSecs map[sealtasks.TaskType]uint64
+
+	// Times a call is sent, counting the first, before a send failure or a
+	// timeout fails it with a retryable error; 0 = 1
+	MaxAttempts int
+
+	// Seconds to wait before sending a call again, doubled on every further
+	// attempt; 0 = default
+	RetryBackoffSecs uint64
+
+	// Failed sends and timeouts in a row after which the appliance is
+	// considered unhealthy, and isn't sent new calls for UnhealthySecs, so
+	// that the scheduler picks healthy appliances; 0 = never
+	UnhealthyAfter int
+
+	// Seconds an unhealthy appliance isn't sent new calls; 0 = default
+	UnhealthySecs uint64
+
+	Resources storiface.WorkerResources
+}
+
+// NewDispatchTransport creates the transport selected in the config.
+func NewDispatchTransport(cfg DispatchConfig) (DispatchTransport, error) {
+	if cfg.Endpoint == "" {
+		return nil, xerrors.Errorf("no dispatch endpoint configured")
+	}
+
+	switch cfg.Transport {
+	case DispatchHTTP:
+		switch cfg.ResultMode {
+		case "", DispatchPush:
+			if cfg.ListenAddress == "" {
+				return nil, xerrors.Errorf("http dispatch transport needs a listen address for pushed results")
+			}
+			if cfg.Token == "" {
+				return nil, xerrors.Errorf("http dispatch transport needs a token to authenticate pushed results")
+			}
+			return newHTTPDispatch(cfg.Endpoint, cfg.ListenAddress, cfg.Token, 0), nil
+		case DispatchPoll:
+			interval := time.Duration(cfg.PollIntervalSecs) * time.Second
+			if interval == 0 {
+				interval = DefaultDispatchPollInterval
+			}
+			return newHTTPDispatch(cfg.Endpoint, "", cfg.Token, interval), nil
+		default:
+			return nil, xerrors.Errorf("unknown dispatch result mode %q", cfg.ResultMode)
+		}
+	case DispatchTCP:
+		if cfg.ResultMode != "" {
+			return nil, xerrors.Errorf("tcp dispatch transport receives results on its connection, result mode can't be set")
+		}
+		if cfg.Token == "" {
+			return nil, xerrors.Errorf("tcp dispatch transport needs a token to authenticate the appliance")
+		}
+		return newTCPDispatch(cfg.Endpoint, cfg.Token), nil
+	case DispatchJob:
+		if cfg.ResultMode != "" {
+			return nil, xerrors.Errorf("job dispatch transport polls the job dir for results, result mode can't be set")
+		}
+		interval := time.Duration(cfg.PollIntervalSecs) * time.Second
+		if interval == 0 {
+			interval = DefaultDispatchPollInterval
+		}
+		return newJobDispatch(cfg, interval)
+	default:
+		return nil, xerrors.Errorf("unknown dispatch transport %q", cfg.Transport)
+	}
+}
@@ -0,0 +1,267 @@
+package sectorstorage
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// httpDispatch POSTs messages to <endpoint>/<msgType>. It receives messages
+// POSTed by the appliance to the listen address, answering 202 once the miner
+// took them and 503 otherwise, or, when a poll interval is set, polls
+// <endpoint>/results for them. Requests in both directions carry the token
+// as a bearer token; pushed messages without it are answered 401.
+type httpDispatch struct {
+	endpoint     string
+	listen       string
+	token        string
+	pollInterval time.Duration
+	client       *http.Client
+
+	lk        sync.Mutex
+	srv       *http.Server
+	stopPoll  context.CancelFunc
+	receiving bool
+}
+
+// dispatchPollResponse is the body returned by the appliance to a poll of
+// <endpoint>/results?after=<cursor>. The cursor is passed in the next poll,
+// acknowledging the results returned with it; results which weren't
+// acknowledged must be returned again.
+type dispatchPollResponse struct {
+	Cursor  string
+	Results []json.RawMessage
+}
+
+func newHTTPDispatch(endpoint, listen, token string, pollInterval time.Duration) *httpDispatch {
+	return &httpDispatch{
+		endpoint:     strings.TrimSuffix(endpoint, "/"),
+		listen:       listen,
+		token:        token,
+		pollInterval: pollInterval,
+		client:       &http.Client{},
+	}
+}
+
+func (h *httpDispatch) Send(ctx context.Context, msgType string, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.endpoint+"/"+msgType, bytes.NewReader(payload))
+	if err != nil {
+		return xerrors.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	h.authorize(req)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return xerrors.Errorf("sending %s: %w", msgType, err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return xerrors.Errorf("sending %s: status %d: %s", msgType, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+func (h *httpDispatch) Receive(ctx context.Context) (<-chan DispatchMessage, error) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if h.receiving {
+		return nil, xerrors.Errorf("already receiving")
+	}
+
+	if h.pollInterval > 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		out := make(chan DispatchMessage)
+		go h.poll(ctx, out)
+
+		h.stopPoll = cancel
+		h.receiving = true
+		return out, nil
+	}
+
+	nl, err := net.Listen("tcp", h.listen)
+	if err != nil {
+		return nil, xerrors.Errorf("listening for results: %w", err)
+	}
+
+	out := make(chan DispatchMessage)
+	done := make(chan struct{})
+	h.srv = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if !h.authorized(r) {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			body, err := ioutil.ReadAll(r.Body)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+
+			taken, _ := handOver(r.Context(), done, out, body)
+			if r.Context().Err() != nil {
+				return
+			}
+			if !taken {
+				// the appliance posts it again
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusAccepted)
+		}),
+	}
+
+	h.receiving = true
+
+	srv := h.srv
+	go func() {
+		if err := srv.Serve(nl); err != http.ErrServerClosed {
+			log.Errorf("dispatch result server: %+v", err)
+		}
+	}()
+	go func() {
+		<-ctx.Done()
+		close(done)
+		_ = srv.Close()
+	}()
+
+	return out, nil
+}
+
+func (h *httpDispatch) poll(ctx context.Context, out chan<- DispatchMessage) {
+	defer close(out)
+
+	var cursor string
+	for {
+		taken := true
+		res, err := h.fetchResults(ctx, cursor)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warnf("polling %s for dispatch results: %+v", h.endpoint, err)
+		} else {
+			for _, r := range res.Results {
+				var ok bool
+				taken, ok = handOver(ctx, nil, out, r)
+				if !ok {
+					return
+				}
+				if !taken {
+					break
+				}
+			}
+
+			// results which weren't taken are returned again by the next
+			// polls, as long as the cursor isn't advanced past them
+			if taken {
+				cursor = res.Cursor
+			}
+		}
+
+		// drain all pending results before waiting
+		if err == nil && taken && len(res.Results) > 0 {
+			continue
+		}
+
+		select {
+		case <-time.After(h.pollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handOver passes a message received from the appliance to the worker, and
+// waits for it to be handled. It returns whether the worker took it, and false
+// if ctx is done or done is closed before that.
+func handOver(ctx context.Context, done <-chan struct{}, out chan<- DispatchMessage, payload []byte) (taken bool, ok bool) {
+	ack := make(chan bool, 1)
+	select {
+	case out <- DispatchMessage{Payload: payload, Ack: func(taken bool) { ack <- taken }}:
+	case <-ctx.Done():
+		return false, false
+	case <-done:
+		return false, false
+	}
+
+	select {
+	case taken := <-ack:
+		return taken, true
+	case <-ctx.Done():
+		return false, false
+	case <-done:
+		return false, false
+	}
+}
+
+func (h *httpDispatch) fetchResults(ctx context.Context, cursor string) (*dispatchPollResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.endpoint+"/results?after="+url.QueryEscape(cursor), nil)
+	if err != nil {
+		return nil, xerrors.Errorf("creating request: %w", err)
+	}
+	h.authorize(req)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return nil, xerrors.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var res dispatchPollResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, xerrors.Errorf("decoding results: %w", err)
+	}
+	return &res, nil
+}
+
+func (h *httpDispatch) authorize(req *http.Request) {
+	if h.token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.token)
+	}
+}
+
+// authorized checks that a message pushed by the appliance carries the token
+func (h *httpDispatch) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if h.token == "" || !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, prefix)), []byte(h.token)) == 1
+}
+
+func (h *httpDispatch) Close() error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if h.stopPoll != nil {
+		h.stopPoll()
+	}
+	if h.srv == nil {
+		return nil
+	}
+	return h.srv.Close()
+}
@@ -0,0 +1,238 @@
+package sectorstorage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// dispatchJob is what job templates, and the arguments of the cancel command,
+// are rendered with
+type dispatchJob struct {
+	// Method of the call, e.g. SealPreCommit1
+	Method string
+	// ID of the call, a uuid usable in job names
+	ID string
+	// Sector of the call, e.g. s-t01000-1; empty for PoSt calls
+	Sector string
+
+	// CallFile is where the payload of the call was written, on JobDir
+	CallFile string
+	// ResultFile is where the job must write the result of the call
+	ResultFile string
+}
+
+// jobDispatch submits every call as a batch job of a cluster scheduler, such
+// as a Kubernetes Job or a Slurm batch job, instead of sending it to a
+// long-running appliance. The call payload is written to
+// <JobDir>/calls/<id>.json, and the job manifest rendered from the template is
+// piped to the submit command. Jobs write their result to
+// <JobDir>/results/<id>.json, which is polled for; a result file is removed
+// once the miner took it, so jobs must create it atomically, e.g. by renaming
+// a temporary file.
+type jobDispatch struct {
+	dir          string
+	tmpl         *template.Template
+	submit       []string
+	cancel       []string
+	pollInterval time.Duration
+
+	lk        sync.Mutex
+	stopPoll  context.CancelFunc
+	receiving bool
+}
+
+func newJobDispatch(cfg DispatchConfig, pollInterval time.Duration) (*jobDispatch, error) {
+	if cfg.JobDir == "" || cfg.JobTemplate == "" || len(cfg.SubmitCommand) == 0 {
+		return nil, xerrors.Errorf("job dispatch transport needs a job dir, a job template and a submit command")
+	}
+
+	tmpl, err := template.ParseFiles(cfg.JobTemplate)
+	if err != nil {
+		return nil, xerrors.Errorf("parsing job template: %w", err)
+	}
+
+	for _, sub := range []string{"calls", "results"} {
+		if err := os.MkdirAll(filepath.Join(cfg.JobDir, sub), 0755); err != nil {
+			return nil, xerrors.Errorf("creating job dir: %w", err)
+		}
+	}
+
+	return &jobDispatch{
+		dir:          cfg.JobDir,
+		tmpl:         tmpl,
+		submit:       cfg.SubmitCommand,
+		cancel:       cfg.CancelCommand,
+		pollInterval: pollInterval,
+	}, nil
+}
+
+func (j *jobDispatch) Send(ctx context.Context, msgType string, payload []byte) error {
+	if msgType == "ManagerShutdown" {
+		// results of jobs are picked up from the job dir after restart
+		return nil
+	}
+
+	var call struct {
+		CallID storiface.CallID
+	}
+	if err := json.Unmarshal(payload, &call); err != nil {
+		return xerrors.Errorf("decoding call id of %s: %w", msgType, err)
+	}
+
+	job := dispatchJob{
+		Method:     msgType,
+		ID:         call.CallID.ID.String(),
+		CallFile:   filepath.Join(j.dir, "calls", call.CallID.ID.String()+".json"),
+		ResultFile: filepath.Join(j.dir, "results", call.CallID.ID.String()+".json"),
+	}
+	if call.CallID.Sector.Miner != 0 {
+		job.Sector = storiface.SectorName(call.CallID.Sector)
+	}
+
+	if msgType == "Cancel" {
+		return j.cancelJob(ctx, job)
+	}
+
+	if err := ioutil.WriteFile(job.CallFile, payload, 0644); err != nil {
+		return xerrors.Errorf("writing %s call: %w", msgType, err)
+	}
+
+	var manifest bytes.Buffer
+	if err := j.tmpl.Execute(&manifest, &job); err != nil {
+		return xerrors.Errorf("rendering job manifest for %s: %w", msgType, err)
+	}
+
+	cmd := exec.CommandContext(ctx, j.submit[0], j.submit[1:]...)
+	cmd.Stdin = &manifest
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return xerrors.Errorf("submitting %s job: %w: %s", msgType, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// cancelJob runs the cancel command, which arguments are rendered with the job
+// of the call; without a cancel command, jobs of aborted calls run to the end
+// and their result is dropped
+func (j *jobDispatch) cancelJob(ctx context.Context, job dispatchJob) error {
+	if len(j.cancel) == 0 {
+		return nil
+	}
+
+	args := make([]string, len(j.cancel))
+	for i, a := range j.cancel {
+		t, err := template.New("arg").Parse(a)
+		if err != nil {
+			return xerrors.Errorf("parsing cancel command: %w", err)
+		}
+		var sb strings.Builder
+		if err := t.Execute(&sb, &job); err != nil {
+			return xerrors.Errorf("rendering cancel command: %w", err)
+		}
+		args[i] = sb.String()
+	}
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return xerrors.Errorf("cancelling job %s: %w: %s", job.ID, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func (j *jobDispatch) Receive(ctx context.Context) (<-chan DispatchMessage, error) {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	if j.receiving {
+		return nil, xerrors.Errorf("already receiving")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	out := make(chan DispatchMessage)
+	go j.poll(ctx, out)
+
+	j.stopPoll = cancel
+	j.receiving = true
+	return out, nil
+}
+
+func (j *jobDispatch) poll(ctx context.Context, out chan<- DispatchMessage) {
+	defer close(out)
+
+	for {
+		if !j.collect(ctx, out) {
+			return
+		}
+
+		select {
+		case <-time.After(j.pollInterval):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// collect hands the result files written by jobs to the worker, removing
+// those it took along with their call file. It returns false once ctx is done.
+func (j *jobDispatch) collect(ctx context.Context, out chan<- DispatchMessage) bool {
+	files, err := ioutil.ReadDir(filepath.Join(j.dir, "results"))
+	if err != nil {
+		log.Warnf("listing dispatch job results in %s: %+v", j.dir, err)
+		return true
+	}
+	sort.Slice(files, func(i, k int) bool {
+		return files[i].ModTime().Before(files[k].ModTime())
+	})
+
+	for _, fi := range files {
+		if fi.IsDir() || filepath.Ext(fi.Name()) != ".json" {
+			continue
+		}
+
+		res := filepath.Join(j.dir, "results", fi.Name())
+		payload, err := ioutil.ReadFile(res)
+		if err != nil {
+			log.Warnf("reading dispatch job result: %+v", err)
+			continue
+		}
+
+		taken, ok := handOver(ctx, nil, out, payload)
+		if !ok {
+			return false
+		}
+		if !taken {
+			// picked up again by the next poll
+			return true
+		}
+
+		for _, f := range []string{res, filepath.Join(j.dir, "calls", fi.Name())} {
+			if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+				log.Warnf("removing dispatch job file: %+v", err)
+			}
+		}
+	}
+	return true
+}
+
+func (j *jobDispatch) Close() error {
+	j.lk.Lock()
+	defer j.lk.Unlock()
+
+	if j.stopPoll != nil {
+		j.stopPoll()
+	}
+	return nil
+}
@@ -0,0 +1,299 @@
+package sectorstorage
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+)
+
+// DispatchRedialInterval is how long the tcp dispatch transport waits before
+// reconnecting to an appliance it lost the connection to
+var DispatchRedialInterval = 5 * time.Second
+
+// DispatchAuthTimeout bounds the handshake authenticating the appliance on
+// new tcp dispatch connections
+var DispatchAuthTimeout = 30 * time.Second
+
+// maxDispatchFrame bounds the frames read from appliances; the largest
+// payloads are Commit1 outputs and proofs, well under this
+const maxDispatchFrame = 64 << 20
+
+// tcpDispatch exchanges frames with the appliance over a single connection,
+// which is redialed when lost. Each frame is the uvarint-prefixed message type
+// followed by the uvarint-prefixed payload, in both directions. Every frame
+// received is answered, in order, with an empty Ack frame once the miner took
+// it, or a Nack frame when it didn't, in which case the appliance sends it
+// again later. New connections are authenticated first, see
+// DispatchConfig.Token.
+type tcpDispatch struct {
+	endpoint string
+	token    string
+
+	lk      sync.Mutex // guards conn, serializes writes
+	conn    net.Conn
+	closing chan struct{}
+	closed  bool
+}
+
+func newTCPDispatch(endpoint, token string) *tcpDispatch {
+	return &tcpDispatch{
+		endpoint: endpoint,
+		token:    token,
+		closing:  make(chan struct{}),
+	}
+}
+
+// getConn returns the current connection, dialing one if needed; called with
+// lk held
+func (t *tcpDispatch) getConn(ctx context.Context) (net.Conn, error) {
+	if t.closed {
+		return nil, xerrors.Errorf("dispatch transport closed")
+	}
+	if t.conn != nil {
+		return t.conn, nil
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", t.endpoint)
+	if err != nil {
+		return nil, xerrors.Errorf("dialing %s: %w", t.endpoint, err)
+	}
+	if err := t.authenticate(conn); err != nil {
+		_ = conn.Close()
+		return nil, xerrors.Errorf("authenticating %s: %w", t.endpoint, err)
+	}
+	t.conn = conn
+	return conn, nil
+}
+
+// authenticate has the appliance prove it knows the token, by answering an
+// Auth frame carrying a random nonce with the HMAC of the nonce
+func (t *tcpDispatch) authenticate(conn net.Conn) error {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return xerrors.Errorf("generating nonce: %w", err)
+	}
+
+	_ = conn.SetDeadline(time.Now().Add(DispatchAuthTimeout))
+	defer conn.SetDeadline(time.Time{}) // nolint
+
+	if err := writeDispatchFrame(conn, "Auth", nonce); err != nil {
+		return xerrors.Errorf("sending nonce: %w", err)
+	}
+
+	// read byte by byte, so that frames sent after the answer are left to
+	// readFrames
+	msgType, mac, err := readDispatchFrame(connByteReader{conn})
+	if err != nil {
+		return xerrors.Errorf("reading answer: %w", err)
+	}
+	if msgType != "Auth" || !hmac.Equal(mac, dispatchAuthMAC(t.token, nonce)) {
+		return xerrors.Errorf("appliance didn't prove it has the dispatch token")
+	}
+	return nil
+}
+
+func dispatchAuthMAC(token string, nonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(token))
+	_, _ = mac.Write(nonce)
+	return mac.Sum(nil)
+}
+
+type connByteReader struct {
+	net.Conn
+}
+
+func (c connByteReader) ReadByte() (byte, error) {
+	var b [1]byte
+	_, err := io.ReadFull(c.Conn, b[:])
+	return b[0], err
+}
+
+// dropConn closes the connection if it's still the current one
+func (t *tcpDispatch) dropConn(conn net.Conn) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if t.conn == conn {
+		_ = conn.Close()
+		t.conn = nil
+	}
+}
+
+func (t *tcpDispatch) Send(ctx context.Context, msgType string, payload []byte) error {
+	t.lk.Lock()
+	conn, err := t.getConn(ctx)
+	if err != nil {
+		t.lk.Unlock()
+		return err
+	}
+
+	if dl, ok := ctx.Deadline(); ok {
+		_ = conn.SetWriteDeadline(dl)
+	} else {
+		_ = conn.SetWriteDeadline(time.Time{})
+	}
+	err = writeDispatchFrame(conn, msgType, payload)
+	t.lk.Unlock()
+
+	if err != nil {
+		t.dropConn(conn)
+		return xerrors.Errorf("sending %s: %w", msgType, err)
+	}
+	return nil
+}
+
+func (t *tcpDispatch) Receive(ctx context.Context) (<-chan DispatchMessage, error) {
+	out := make(chan DispatchMessage)
+
+	go func() {
+		defer close(out)
+
+		for {
+			t.lk.Lock()
+			conn, err := t.getConn(ctx)
+			t.lk.Unlock()
+
+			if err == nil {
+				err = t.readFrames(ctx, conn, out)
+				t.dropConn(conn)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.closing:
+				return
+			default:
+			}
+
+			log.Warnf("dispatch connection to %s lost, redialing in %s: %+v", t.endpoint, DispatchRedialInterval, err)
+
+			select {
+			case <-time.After(DispatchRedialInterval):
+			case <-ctx.Done():
+				return
+			case <-t.closing:
+				return
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (t *tcpDispatch) readFrames(ctx context.Context, conn net.Conn, out chan<- DispatchMessage) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = conn.Close()
+		case <-stop:
+		}
+	}()
+
+	br := bufio.NewReader(conn)
+	for {
+		_, payload, err := readDispatchFrame(br)
+		if err != nil {
+			return err
+		}
+
+		taken, ok := handOver(ctx, t.closing, out, payload)
+		if !ok {
+			return ctx.Err()
+		}
+
+		ack := "Ack"
+		if !taken {
+			ack = "Nack"
+		}
+
+		t.lk.Lock()
+		_ = conn.SetWriteDeadline(time.Time{})
+		err = writeDispatchFrame(conn, ack, nil)
+		t.lk.Unlock()
+		if err != nil {
+			return xerrors.Errorf("sending %s: %w", ack, err)
+		}
+	}
+}
+
+func (t *tcpDispatch) Close() error {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if t.closed {
+		return nil
+	}
+	t.closed = true
+	close(t.closing)
+
+	if t.conn != nil {
+		err := t.conn.Close()
+		t.conn = nil
+		return err
+	}
+	return nil
+}
+
+func writeDispatchFrame(w io.Writer, msgType string, payload []byte) error {
+	buf := make([]byte, 0, 2*binary.MaxVarintLen64+len(msgType)+len(payload))
+	buf = appendUvarint(buf, uint64(len(msgType)))
+	buf = append(buf, msgType...)
+	buf = appendUvarint(buf, uint64(len(payload)))
+	buf = append(buf, payload...)
+
+	_, err := w.Write(buf)
+	return err
+}
+
+// dispatchReader is what frames are read from
+type dispatchReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func readDispatchFrame(br dispatchReader) (string, []byte, error) {
+	msgType, err := readDispatchField(br)
+	if err != nil {
+		return "", nil, err
+	}
+	payload, err := readDispatchField(br)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(msgType), payload, nil
+}
+
+func readDispatchField(br dispatchReader) ([]byte, error) {
+	l, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, err
+	}
+	if l > maxDispatchFrame {
+		return nil, xerrors.Errorf("dispatch frame too large: %d bytes", l)
+	}
+
+	out := make([]byte, l)
+	if _, err := io.ReadFull(br, out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	return append(buf, tmp[:n]...)
+}
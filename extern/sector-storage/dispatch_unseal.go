@@ -0,0 +1,104 @@
+package sectorstorage
+
+import (
+	"context"
+	"io"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fr32"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// UnsealPiece has the appliance unseal the range where the sealed replica is.
+// The unsealed file is created on the appliance storage, unless the sector
+// has one there already, which the range is unsealed into.
+func (w *DispatchWorker) UnsealPiece(ctx context.Context, sector storage.SectorRef, index storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, randomness abi.SealRandomness, pieceCid cid.Cid) (storiface.CallID, error) {
+	files := dispatchFiles["UnsealPiece"]
+	if len(w.cfg.StorageIDs) > 0 {
+		has, err := w.holds(ctx, sector.ID, storiface.FTUnsealed)
+		if err != nil {
+			return storiface.UndefCall, err
+		}
+		if has {
+			files.existing |= storiface.FTUnsealed
+			files.allocate = storiface.FTNone
+		}
+	}
+	return w.dispatch(ctx, &dispatchPending{Method: "UnsealPiece"}, files, sector, index, size, randomness, pieceCid)
+}
+
+// dispatchUnsealSelector returns a selector of the appliance holding the
+// sealed replica of the sector, so that the sector is unsealed where the
+// replica is instead of copying it to a worker; nil if no appliance holds it,
+// or if the existing unsealed file is elsewhere.
+func (m *Manager) dispatchUnsealSelector(ctx context.Context, sector abi.SectorID, foundUnsealed bool) (WorkerSelector, error) {
+	ft := storiface.FTSealed | storiface.FTCache
+	if foundUnsealed {
+		ft |= storiface.FTUnsealed
+	}
+
+	holder, err := m.dispatchHolder(ctx, sector, ft, sealtasks.TTUnseal)
+	if err != nil || holder == nil {
+		return nil, err
+	}
+	return &dispatchSelector{newExistingSelector(m.index, sector, storiface.FTSealed|storiface.FTCache, false)}, nil
+}
+
+// unsealedOnAppliance returns the appliance holding the unsealed file of the
+// sector on storage which isn't local to the miner; nil if there's none.
+// Workers can't read such files in place, so they are read from the storage
+// fetch endpoint.
+func (m *Manager) unsealedOnAppliance(ctx context.Context, sector abi.SectorID) (*DispatchWorker, error) {
+	holder, err := m.dispatchHolder(ctx, sector, storiface.FTUnsealed, sealtasks.TTUnseal)
+	if err != nil || holder == nil {
+		return nil, err
+	}
+
+	local, err := m.localStore.Local(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting local storage paths: %w", err)
+	}
+	infos, err := m.index.StorageFindSector(ctx, sector, storiface.FTUnsealed, 0, false)
+	if err != nil {
+		return nil, xerrors.Errorf("finding unsealed sector: %w", err)
+	}
+	for _, info := range infos {
+		for _, p := range local {
+			if p.ID == info.ID {
+				return nil, nil
+			}
+		}
+	}
+	return holder, nil
+}
+
+// readUnsealedOnAppliance reads the piece from the unsealed file on the
+// appliance storage through the storage fetch endpoint, only transferring the
+// range of the piece. The range must be unsealed.
+func (m *Manager) readUnsealedOnAppliance(ctx context.Context, sink io.Writer, holder *DispatchWorker, sector storage.SectorRef, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := m.storage.ReadRemoteRange(ctx, sector.ID, storiface.FTUnsealed, uint64(offset.Padded()), uint64(size.Padded()), holder.cfg.StorageIDs, pw)
+		_ = pw.CloseWithError(err)
+	}()
+	defer pr.Close() // nolint
+
+	upr, err := fr32.NewUnpadReader(pr, size.Padded())
+	if err != nil {
+		return xerrors.Errorf("creating unpadded reader: %w", err)
+	}
+
+	if _, err := io.CopyN(sink, upr, int64(size)); err != nil {
+		return xerrors.Errorf("reading piece from appliance storage: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,398 @@
+package sectorstorage
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+var returnType = reflect.TypeOf((*storiface.WorkerReturn)(nil)).Elem()
+
+// dispatchCall is the payload of a call sent to an appliance; Params are the
+// positional call parameters, without the context. Paths are the locations
+// of the sector files the call works on, when storage paths are configured
+// for the appliance.
+type dispatchCall struct {
+	CallID storiface.CallID
+	Params []interface{}
+	Paths  *storiface.SectorPaths `json:",omitempty"`
+}
+
+// dispatchReturn is the payload of a result sent by an appliance; Method is a
+// WorkerReturn method, Params its positional parameters without the context,
+// starting with the CallID. Paths optionally report where the appliance
+// created sector files, e.g. the unsealed file of AddPiece. Checksums of the
+// sealed output come with PreCommit2 results, when the config requires them.
+type dispatchReturn struct {
+	Method    string
+	Params    []json.RawMessage
+	Paths     *storiface.SectorPaths `json:",omitempty"`
+	Checksums *dispatchChecksums     `json:",omitempty"`
+}
+
+// DispatchWorker is a worker running its calls on an external appliance,
+// through a DispatchTransport.
+type DispatchWorker struct {
+	cfg   DispatchConfig
+	tr    DispatchTransport
+	local *stores.Local
+	index stores.SectorIndex
+	ret   storiface.WorkerReturn
+
+	pendingLk sync.Mutex
+	pending   map[storiface.CallID]*dispatchPending
+	pendingDS datastore.Datastore                      // nil until persisted
+	failed    map[storiface.CallID]struct{}            // failed or aborted by the miner
+	posts     map[storiface.CallID]chan dispatchReturn // PoSt calls waiting for their result
+	verifying map[storiface.CallID]*dispatchVerify     // PreCommit2 outputs being checked
+
+	strikes        int          // failed sends and timeouts in a row
+	unhealthyUntil time.Time    // no new calls are sent until then
+	draining       bool         // the appliance left, no new calls are sent
+	srv            *dispatchSRV // record the appliance was discovered with
+
+	tokens *fetchTokens // of the manager, scope the URLs of calls in url path mode
+
+	session uuid.UUID
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	closeOnce sync.Once
+	closing   chan struct{}
+}
+
+func NewDispatchWorker(ctx context.Context, cfg DispatchConfig, tr DispatchTransport, local *stores.Local, index stores.SectorIndex, ret storiface.WorkerReturn) (*DispatchWorker, error) {
+	for _, tt := range cfg.TaskTypes {
+		if tt == sealtasks.TTReadUnsealed {
+			return nil, xerrors.Errorf("task type %s can't be dispatched", tt)
+		}
+	}
+
+	switch cfg.PathMode {
+	case "", DispatchLocalPaths, DispatchURLPaths:
+	default:
+		return nil, xerrors.Errorf("unknown dispatch path mode %q", cfg.PathMode)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	msgs, err := tr.Receive(ctx)
+	if err != nil {
+		cancel()
+		return nil, xerrors.Errorf("receiving from dispatch transport: %w", err)
+	}
+
+	w := &DispatchWorker{
+		cfg:   cfg,
+		tr:    tr,
+		local: local,
+		index: index,
+		ret:   ret,
+
+		pending:   map[storiface.CallID]*dispatchPending{},
+		failed:    map[storiface.CallID]struct{}{},
+		posts:     map[storiface.CallID]chan dispatchReturn{},
+		verifying: map[storiface.CallID]*dispatchVerify{},
+
+		session: uuid.New(),
+		ctx:     ctx,
+		cancel:  cancel,
+		closing: make(chan struct{}),
+	}
+
+	go w.receive(ctx, msgs)
+
+	return w, nil
+}
+
+func (w *DispatchWorker) receive(ctx context.Context, msgs <-chan DispatchMessage) {
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			msg.Ack(w.handle(ctx, msg.Payload))
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handle processes a message sent by the appliance, and returns whether it
+// was taken. A result the miner doesn't accept yet leaves its call pending,
+// and is sent again by the appliance.
+func (w *DispatchWorker) handle(ctx context.Context, msg []byte) bool {
+	if w.postResult(msg) {
+		return true
+	}
+	if w.late(msg) {
+		log.Warnf("dispatch worker %s: dropping result of a call which was failed", w.cfg.Hostname)
+		return true
+	}
+
+	p, err := w.pendingCall(msg)
+	if err != nil {
+		log.Warnf("dispatch worker %s: dropping result: %+v", w.cfg.Hostname, err)
+		return true
+	}
+
+	w.healthy()
+
+	done, err := w.verified(p, msg)
+	if !done {
+		return false
+	}
+	if err != nil {
+		w.fail(p.ID, storiface.ErrTempUnknown, xerrors.Errorf("verifying sealed output: %w", err))
+		return true
+	}
+
+	w.declareAllocated(ctx, p, msg)
+	if err := deliverDispatchReturn(ctx, w.ret, msg); err != nil {
+		var rejected rejectedReturn
+		if xerrors.As(err, &rejected) {
+			log.Warnf("dispatch worker %s: result not taken, the appliance sends it again: %+v", w.cfg.Hostname, err)
+			return false
+		}
+		w.fail(p.ID, storiface.ErrTempUnknown, xerrors.Errorf("delivering result: %w", err))
+		return true
+	}
+
+	w.untrack(p.ID)
+	return true
+}
+
+// pendingCall returns the call pending on this worker which the result is for.
+// Results of calls which aren't pending here, or which aren't reported through
+// the return method of the call, are rejected.
+func (w *DispatchWorker) pendingCall(msg []byte) (*dispatchPending, error) {
+	ci, _, err := dispatchOutcome(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var dr dispatchReturn
+	if err := json.Unmarshal(msg, &dr); err != nil {
+		return nil, xerrors.Errorf("decoding result: %w", err)
+	}
+
+	w.pendingLk.Lock()
+	p, ok := w.pending[ci]
+	w.pendingLk.Unlock()
+
+	if !ok {
+		return nil, xerrors.Errorf("%s for call %s, which isn't pending on this worker", dr.Method, ci)
+	}
+	if dr.Method != "Return"+p.Method {
+		return nil, xerrors.Errorf("%s for %s call %s", dr.Method, p.Method, ci)
+	}
+	return p, nil
+}
+
+// rejectedReturn is the error of the WorkerReturn method a result was
+// delivered to, e.g. because the miner is shutting down
+type rejectedReturn struct {
+	error
+}
+
+// deliverDispatchReturn decodes a result sent by an appliance, and reports it
+// through the matching WorkerReturn method.
+func deliverDispatchReturn(ctx context.Context, ret storiface.WorkerReturn, msg []byte) error {
+	var dr dispatchReturn
+	if err := json.Unmarshal(msg, &dr); err != nil {
+		return xerrors.Errorf("decoding result: %w", err)
+	}
+
+	mt, ok := returnType.MethodByName(dr.Method)
+	if !ok {
+		return xerrors.Errorf("unknown return method %q", dr.Method)
+	}
+	if n := mt.Type.NumIn() - 1; len(dr.Params) != n {
+		return xerrors.Errorf("%s: expected %d params, got %d", dr.Method, n, len(dr.Params))
+	}
+
+	args := []reflect.Value{reflect.ValueOf(ctx)}
+	for i, p := range dr.Params {
+		v := reflect.New(mt.Type.In(i + 1))
+		if err := json.Unmarshal(p, v.Interface()); err != nil {
+			return xerrors.Errorf("%s: decoding param %d: %w", dr.Method, i, err)
+		}
+		args = append(args, v.Elem())
+	}
+
+	out := reflect.ValueOf(ret).MethodByName(dr.Method).Call(args)
+	if err, _ := out[0].Interface().(error); err != nil {
+		return xerrors.Errorf("%s: %w", dr.Method, rejectedReturn{err})
+	}
+	return nil
+}
+
+func (w *DispatchWorker) call(ctx context.Context, method string, sector storage.SectorRef, params ...interface{}) (storiface.CallID, error) {
+	return w.dispatch(ctx, &dispatchPending{Method: method}, dispatchFiles[method], sector, params...)
+}
+
+// dispatch sends the call described by p, which only needs its method set,
+// or the files staged for it
+func (w *DispatchWorker) dispatch(ctx context.Context, p *dispatchPending, files dispatchFileTypes, sector storage.SectorRef, params ...interface{}) (storiface.CallID, error) {
+	ci := storiface.CallID{
+		Sector: sector.ID,
+		ID:     uuid.New(),
+	}
+
+	tok, err := w.callToken(sector.ID, ci, files)
+	if err != nil {
+		return storiface.UndefCall, err
+	}
+
+	paths, decls, drops, err := w.sectorPaths(ctx, files, sector, tok)
+	if err != nil {
+		if tok != "" {
+			w.tokens.revoke(tok)
+		}
+		return storiface.UndefCall, xerrors.Errorf("resolving sector paths for %s: %w", p.Method, err)
+	}
+
+	payload, err := json.Marshal(&dispatchCall{CallID: ci, Params: append([]interface{}{sector}, params...), Paths: paths})
+	if err != nil {
+		if tok != "" {
+			w.tokens.revoke(tok)
+		}
+		return storiface.UndefCall, xerrors.Errorf("encoding %s call: %w", p.Method, err)
+	}
+
+	p.ID = ci
+	p.Started = time.Now()
+	p.Endpoint = w.cfg.Endpoint
+	p.Decls = decls
+	p.Drops = drops
+	p.Payload = payload
+	p.Token = tok
+	w.track(p)
+
+	if err := w.send(ctx, ci); err != nil {
+		w.untrack(ci)
+		w.strike()
+		return storiface.UndefCall, err
+	}
+	return ci, nil
+}
+
+func (w *DispatchWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, meta []storiface.PieceMeta, numaNode int) (storiface.CallID, error) {
+	return w.call(ctx, "SealPreCommit1", sector, ticket, pieces, meta, numaNode)
+}
+
+func (w *DispatchWorker) SealPreCommit2(ctx context.Context, sector storage.SectorRef, pc1o storage.PreCommit1Out) (storiface.CallID, error) {
+	return w.call(ctx, "SealPreCommit2", sector, pc1o)
+}
+
+func (w *DispatchWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids, meta []storiface.PieceMeta) (storiface.CallID, error) {
+	return w.call(ctx, "SealCommit1", sector, ticket, seed, pieces, cids, meta)
+}
+
+func (w *DispatchWorker) SealCommit2(ctx context.Context, sector storage.SectorRef, c1o storage.Commit1Out) (storiface.CallID, error) {
+	return w.call(ctx, "SealCommit2", sector, c1o)
+}
+
+func (w *DispatchWorker) MoveStorage(ctx context.Context, sector storage.SectorRef, types storiface.SectorFileType) (storiface.CallID, error) {
+	return w.call(ctx, "MoveStorage", sector, types)
+}
+
+func (w *DispatchWorker) ReadPiece(ctx context.Context, sink io.Writer, sector storage.SectorRef, index storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (storiface.CallID, error) {
+	return storiface.UndefCall, xerrors.Errorf("ReadPiece can't be dispatched")
+}
+
+func (w *DispatchWorker) TaskTypes(context.Context) (map[sealtasks.TaskType]struct{}, error) {
+	if !w.available() {
+		// the scheduler doesn't assign new work to workers without tasks
+		return map[sealtasks.TaskType]struct{}{}, nil
+	}
+
+	out := make(map[sealtasks.TaskType]struct{}, len(w.cfg.TaskTypes))
+	for _, tt := range w.cfg.TaskTypes {
+		out[tt] = struct{}{}
+	}
+	return out, nil
+}
+
+func (w *DispatchWorker) Paths(ctx context.Context) ([]stores.StoragePath, error) {
+	out := make([]stores.StoragePath, 0, len(w.cfg.StorageIDs))
+	for _, id := range w.cfg.StorageIDs {
+		si, err := w.index.StorageInfo(ctx, id)
+		if err != nil {
+			return nil, xerrors.Errorf("getting info for storage %s: %w", id, err)
+		}
+
+		out = append(out, stores.StoragePath{
+			ID:       si.ID,
+			Weight:   si.Weight,
+			CanSeal:  si.CanSeal,
+			CanStore: si.CanStore,
+		})
+	}
+	return out, nil
+}
+
+func (w *DispatchWorker) Info(context.Context) (storiface.WorkerInfo, error) {
+	return storiface.WorkerInfo{
+		Hostname:  w.cfg.Hostname,
+		Resources: w.cfg.Resources,
+		Version:   w.cfg.Version,
+	}, nil
+}
+
+func (w *DispatchWorker) Session(context.Context) (uuid.UUID, error) {
+	select {
+	case <-w.closing:
+		return ClosedWorkerID, nil
+	default:
+		return w.session, nil
+	}
+}
+
+func (w *DispatchWorker) CallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) {
+	return nil, xerrors.Errorf("dispatch workers don't keep call logs")
+}
+
+// ManagerShutdown tells the appliance that the miner is shutting down, so that
+// it holds results until the miner is back instead of sending them right away
+func (w *DispatchWorker) ManagerShutdown(ctx context.Context) error {
+	if err := w.tr.Send(ctx, "ManagerShutdown", []byte("{}")); err != nil {
+		return xerrors.Errorf("sending shutdown notice: %w", err)
+	}
+	return nil
+}
+
+func (w *DispatchWorker) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.closing)
+		w.cancel()
+
+		w.pendingLk.Lock()
+		for _, p := range w.pending {
+			if p.timer != nil {
+				p.timer.Stop()
+			}
+		}
+		w.pendingLk.Unlock()
+
+		err = w.tr.Close()
+	})
+	return err
+}
+
+var _ Worker = &DispatchWorker{}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/dispatchschema"
+)
+
+// usage: gen <json schema out> <proto out>
+func main() {
+	js, err := dispatchschema.JSONSchema()
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(os.Args[1], append(js, '\n'), 0644); err != nil {
+		panic(err)
+	}
+
+	proto, err := dispatchschema.Proto()
+	if err != nil {
+		panic(err)
+	}
+	if err := ioutil.WriteFile(os.Args[2], proto, 0644); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,175 @@
+package dispatchschema
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Proto returns proto3 definitions of the dispatched calls and their results.
+// Workers are driven over JSON-RPC, so these describe the shape of the data
+// for code generation; messages aren't exchanged in protobuf encoding.
+func Proto() ([]byte, error) {
+	methods, err := Methods()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &protoGen{messages: map[string]string{}}
+
+	var worker, returns strings.Builder
+	for _, m := range methods {
+		req := g.paramsMessage(m.Name+"Request", m.Request)
+		res := g.paramsMessage(m.Name+"Result", m.Result)
+
+		callRet := "Empty"
+		if m.Returns != nil {
+			callRet = g.fieldType(m.Returns)
+		}
+		fmt.Fprintf(&worker, "  rpc %s(%s) returns (%s);\n", m.Name, req, callRet)
+		fmt.Fprintf(&returns, "  rpc %s(%s) returns (Empty);\n", m.ReturnMethod, res)
+	}
+	g.messages["Empty"] = "message Empty {}\n"
+
+	var out strings.Builder
+	out.WriteString("// Code generated by dispatchschema. DO NOT EDIT.\n\n")
+	out.WriteString("syntax = \"proto3\";\n\npackage lotus.dispatch;\n\n")
+	out.WriteString("// Calls the miner dispatches to workers.\n")
+	fmt.Fprintf(&out, "service Worker {\n%s}\n\n", worker.String())
+	out.WriteString("// Calls workers report results of dispatched calls with.\n")
+	fmt.Fprintf(&out, "service WorkerReturn {\n%s}\n", returns.String())
+
+	names := make([]string, 0, len(g.messages))
+	for name := range g.messages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		out.WriteString("\n")
+		out.WriteString(g.messages[name])
+	}
+
+	return []byte(out.String()), nil
+}
+
+type protoGen struct {
+	messages map[string]string
+}
+
+func (g *protoGen) paramsMessage(name string, ps []Param) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "message %s {\n", name)
+	for i, p := range ps {
+		fmt.Fprintf(&b, "  %s %s = %d;\n", g.fieldType(p.Type), p.Name, i+1)
+	}
+	b.WriteString("}\n")
+	g.messages[name] = b.String()
+	return name
+}
+
+// fieldType returns the proto type of a field holding values of the type,
+// defining messages as needed.
+func (g *protoGen) fieldType(t reflect.Type) string {
+	switch {
+	case t == cidType, isText(t):
+		return "string"
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return "int32"
+	case reflect.Int, reflect.Int64:
+		return "int64"
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "uint32"
+	case reflect.Uint, reflect.Uint64:
+		return "uint64"
+	case reflect.Float32:
+		return "float"
+	case reflect.Float64:
+		return "double"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "bytes"
+		}
+		elem := g.fieldType(t.Elem())
+		if strings.HasPrefix(elem, "repeated ") || strings.HasPrefix(elem, "map<") {
+			// proto can't nest repeated fields; wrap the element
+			elem = g.wrapperMessage(t.Elem(), elem)
+		}
+		return "repeated " + elem
+	case reflect.Map:
+		elem := g.fieldType(t.Elem())
+		if strings.HasPrefix(elem, "repeated ") || strings.HasPrefix(elem, "map<") {
+			elem = g.wrapperMessage(t.Elem(), elem)
+		}
+		return fmt.Sprintf("map<string, %s>", elem)
+	case reflect.Ptr:
+		return g.fieldType(t.Elem())
+	case reflect.Interface:
+		// streams are passed out of band, see lib/rpcenc
+		return "bytes"
+	case reflect.Struct:
+		return g.structMessage(t)
+	}
+
+	return "bytes"
+}
+
+func (g *protoGen) structMessage(t reflect.Type) string {
+	name := messageName(t)
+	if _, ok := g.messages[name]; ok {
+		return name
+	}
+	g.messages[name] = "" // break cycles
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s\nmessage %s {\n", typeName(t), name)
+	for i, f := range jsonFields(t) {
+		fmt.Fprintf(&b, "  %s %s = %d [json_name = %q];\n", g.fieldType(f.Type), protoFieldName(f.Name), i+1, f.Name)
+	}
+	b.WriteString("}\n")
+	g.messages[name] = b.String()
+	return name
+}
+
+func (g *protoGen) wrapperMessage(t reflect.Type, elem string) string {
+	name := messageName(t) + "Value"
+	g.messages[name] = fmt.Sprintf("message %s {\n  %s value = 1;\n}\n", name, elem)
+	return name
+}
+
+// messageName turns a package-qualified Go type name into a message name,
+// e.g. "AbiSectorID" for abi.SectorID.
+func messageName(t reflect.Type) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range typeName(t) {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// protoFieldName makes a valid proto field name out of a JSON field name.
+func protoFieldName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
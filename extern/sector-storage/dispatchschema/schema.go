@@ -0,0 +1,289 @@
+// Package dispatchschema describes the calls the miner dispatches to workers
+// (storiface.WorkerCalls), and the results workers report back
+// (storiface.WorkerReturn), as JSON Schema and protobuf definitions. It gives
+// authors of third-party sealers a machine-readable contract.
+package dispatchschema
+
+//go:generate go run ./gen ../../../documentation/en/dispatch-schema.json ../../../documentation/en/dispatch.proto
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+var (
+	callsType  = reflect.TypeOf((*storiface.WorkerCalls)(nil)).Elem()
+	returnType = reflect.TypeOf((*storiface.WorkerReturn)(nil)).Elem()
+
+	contextType       = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType         = reflect.TypeOf((*error)(nil)).Elem()
+	cidType           = reflect.TypeOf(cid.Undef)
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// Param is a positional parameter of a dispatched call or of its result.
+// Names are derived from the parameter types, as Go doesn't retain parameter
+// names at runtime.
+type Param struct {
+	Name string
+	Type reflect.Type
+}
+
+// Method is a call dispatched to workers, along with the WorkerReturn method
+// the worker reports its result with.
+type Method struct {
+	Name         string
+	Request      []Param
+	Returns      reflect.Type
+	ReturnMethod string
+	Result       []Param
+}
+
+// Methods lists the dispatched calls, sorted by name.
+func Methods() ([]Method, error) {
+	out := make([]Method, 0, callsType.NumMethod())
+	for i := 0; i < callsType.NumMethod(); i++ {
+		call := callsType.Method(i)
+
+		retName := "Return" + call.Name
+		ret, ok := returnType.MethodByName(retName)
+		if !ok {
+			return nil, xerrors.Errorf("no %s method for dispatched call %s", retName, call.Name)
+		}
+
+		m := Method{
+			Name:         call.Name,
+			Request:      params(call.Type),
+			ReturnMethod: retName,
+			Result:       params(ret.Type),
+		}
+		for o := 0; o < call.Type.NumOut(); o++ {
+			if t := call.Type.Out(o); t != errorType {
+				m.Returns = t
+			}
+		}
+
+		out = append(out, m)
+	}
+	return out, nil
+}
+
+func params(ft reflect.Type) []Param {
+	var (
+		out  []Param
+		used = map[string]int{}
+	)
+	for i := 0; i < ft.NumIn(); i++ {
+		t := ft.In(i)
+		if t == contextType {
+			continue
+		}
+
+		name := paramName(t)
+		used[name]++
+		if n := used[name]; n > 1 {
+			name += strconv.Itoa(n)
+		}
+		out = append(out, Param{Name: name, Type: t})
+	}
+	return out
+}
+
+// paramName derives a lowerCamelCase name from a parameter type, e.g.
+// "sectorRef" for storage.SectorRef, or "pieceInfos" for []abi.PieceInfo.
+func paramName(t reflect.Type) string {
+	suffix := ""
+	for t.Name() == "" {
+		switch t.Kind() {
+		case reflect.Ptr:
+			t = t.Elem()
+			continue
+		case reflect.Slice, reflect.Array:
+			t = t.Elem()
+			suffix = "s"
+			continue
+		}
+		return "arg"
+	}
+
+	r := []rune(t.Name() + suffix)
+	for i := 0; i < len(r) && unicode.IsUpper(r[i]); i++ {
+		// lower the whole leading acronym, but keep the start of the next word
+		if i > 0 && i+1 < len(r) && unicode.IsLower(r[i+1]) {
+			break
+		}
+		r[i] = unicode.ToLower(r[i])
+	}
+	return string(r)
+}
+
+// typeName returns a package-qualified name for a named type, e.g.
+// "abi.SectorID".
+func typeName(t reflect.Type) string {
+	return t.String()
+}
+
+// isText returns whether values of the type are encoded as JSON strings
+// through encoding.TextMarshaler.
+func isText(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+func isJSONMarshaler(t reflect.Type) bool {
+	return t.Implements(jsonMarshalerType) || reflect.PtrTo(t).Implements(jsonMarshalerType)
+}
+
+// jsonFields returns the exported fields of a struct as encoding/json sees
+// them, with the fields of embedded structs promoted.
+func jsonFields(t reflect.Type) []reflect.StructField {
+	var out []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		if name := strings.Split(tag, ",")[0]; name != "" {
+			f.Name = name
+		} else if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			out = append(out, jsonFields(f.Type)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		out = append(out, f)
+	}
+	return out
+}
+
+// JSONSchema returns a JSON Schema (draft-07) document describing the
+// JSON-RPC parameters of every dispatched call and of its result.
+func JSONSchema() ([]byte, error) {
+	methods, err := Methods()
+	if err != nil {
+		return nil, err
+	}
+
+	g := &jsonSchemaGen{defs: map[string]interface{}{}}
+
+	calls := map[string]interface{}{}
+	for _, m := range methods {
+		call := map[string]interface{}{
+			"request":      g.paramsSchema(m.Request),
+			"returnMethod": m.ReturnMethod,
+			"result":       g.paramsSchema(m.Result),
+		}
+		if m.Returns != nil {
+			call["returns"] = g.typeSchema(m.Returns)
+		}
+		calls[m.Name] = call
+	}
+
+	doc := map[string]interface{}{
+		"$schema":     "http://json-schema.org/draft-07/schema#",
+		"title":       "Lotus worker dispatch",
+		"description": "JSON-RPC parameters of the calls the miner dispatches to workers (request), and of the WorkerReturn calls workers report results with (result). Parameters are positional.",
+		"definitions": g.defs,
+		"methods":     calls,
+	}
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+type jsonSchemaGen struct {
+	defs map[string]interface{}
+}
+
+func (g *jsonSchemaGen) paramsSchema(ps []Param) map[string]interface{} {
+	items := make([]interface{}, 0, len(ps))
+	for _, p := range ps {
+		s := g.typeSchema(p.Type)
+		s["title"] = p.Name
+		items = append(items, s)
+	}
+	return map[string]interface{}{
+		"type":     "array",
+		"items":    items,
+		"minItems": len(ps),
+		"maxItems": len(ps),
+	}
+}
+
+func (g *jsonSchemaGen) typeSchema(t reflect.Type) map[string]interface{} {
+	s := g.baseSchema(t)
+	if t.Name() != "" && t.Kind() != reflect.Struct {
+		s["description"] = typeName(t)
+	}
+	return s
+}
+
+func (g *jsonSchemaGen) baseSchema(t reflect.Type) map[string]interface{} {
+	switch {
+	case t == cidType:
+		return map[string]interface{}{
+			"type":       "object",
+			"properties": map[string]interface{}{"/": map[string]interface{}{"type": "string"}},
+			"required":   []string{"/"},
+		}
+	case isText(t):
+		return map[string]interface{}{"type": "string"}
+	case isJSONMarshaler(t):
+		return map[string]interface{}{"$comment": "custom JSON encoding"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "contentEncoding": "base64"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem())}
+	case reflect.Array:
+		return map[string]interface{}{"type": "array", "items": g.typeSchema(t.Elem()), "minItems": t.Len(), "maxItems": t.Len()}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.typeSchema(t.Elem())}
+	case reflect.Ptr:
+		return map[string]interface{}{"anyOf": []interface{}{map[string]interface{}{"type": "null"}, g.typeSchema(t.Elem())}}
+	case reflect.Interface:
+		return map[string]interface{}{"$comment": "stream, passed out of band; see lib/rpcenc"}
+	case reflect.Struct:
+		name := typeName(t)
+		if _, ok := g.defs[name]; !ok {
+			g.defs[name] = nil // break cycles
+			props := map[string]interface{}{}
+			required := []string{}
+			for _, f := range jsonFields(t) {
+				props[f.Name] = g.typeSchema(f.Type)
+				required = append(required, f.Name)
+			}
+			g.defs[name] = map[string]interface{}{
+				"type":       "object",
+				"properties": props,
+				"required":   required,
+			}
+		}
+		return map[string]interface{}{"$ref": "#/definitions/" + name}
+	}
+
+	return map[string]interface{}{}
+}
@@ -0,0 +1,58 @@
+package dispatchschema
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMethods(t *testing.T) {
+	methods, err := Methods()
+	require.NoError(t, err)
+	require.Equal(t, callsType.NumMethod(), len(methods))
+
+	for _, m := range methods {
+		if m.Name != "AddPiece" {
+			continue
+		}
+		var names []string
+		for _, p := range m.Request {
+			names = append(names, p.Name)
+		}
+		require.Equal(t, []string{"sectorRef", "unpaddedPieceSizes", "unpaddedPieceSize", "data"}, names)
+		require.Equal(t, "ReturnAddPiece", m.ReturnMethod)
+		require.Equal(t, "callID", m.Result[0].Name)
+	}
+}
+
+func TestJSONSchema(t *testing.T) {
+	js, err := JSONSchema()
+	require.NoError(t, err)
+
+	var doc struct {
+		Definitions map[string]json.RawMessage
+		Methods     map[string]struct {
+			Request struct {
+				Items []json.RawMessage
+			}
+			ReturnMethod string
+		}
+	}
+	require.NoError(t, json.Unmarshal(js, &doc))
+	require.Contains(t, doc.Definitions, "storage.SectorRef")
+	require.Contains(t, doc.Definitions, "storiface.CallID")
+	require.Len(t, doc.Methods["SealPreCommit2"].Request.Items, 2)
+	require.Equal(t, "ReturnSealPreCommit2", doc.Methods["SealPreCommit2"].ReturnMethod)
+}
+
+func TestProto(t *testing.T) {
+	proto, err := Proto()
+	require.NoError(t, err)
+
+	s := string(proto)
+	require.Contains(t, s, "rpc SealCommit2(SealCommit2Request) returns (StorifaceCallID);")
+	require.Contains(t, s, "rpc ReturnSealCommit2(SealCommit2Result) returns (Empty);")
+	require.True(t, strings.Contains(s, "message StorageSectorRef {"))
+}
@@ -0,0 +1,147 @@
+package sectorstorage
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// FetchTokenTTL is how long a fetch token is valid for. Tokens are also
+// revoked as soon as the Fetch or dispatched call they were issued for
+// returns. The token is
+// only checked when a transfer starts, so this doesn't limit transfer time.
+var FetchTokenTTL = 30 * time.Minute
+
+type fetchGrant struct {
+	sector abi.SectorID
+	types  storiface.SectorFileType
+	expiry time.Time
+
+	call storiface.CallID // set once the worker started the call
+}
+
+// fetchTokens keeps the fetch tokens issued to workers. Tokens are random and
+// only kept in memory, tokens issued before a restart are no longer valid, and
+// the Fetch calls using them fail and get retried with new ones.
+type fetchTokens struct {
+	lk     sync.Mutex
+	grants map[storiface.FetchToken]*fetchGrant
+}
+
+func newFetchTokens() *fetchTokens {
+	return &fetchTokens{
+		grants: map[storiface.FetchToken]*fetchGrant{},
+	}
+}
+
+func (ft *fetchTokens) issue(sector abi.SectorID, types storiface.SectorFileType, now time.Time) (storiface.FetchToken, error) {
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", xerrors.Errorf("generating fetch token: %w", err)
+	}
+	tok := storiface.FetchToken(hex.EncodeToString(b[:]))
+
+	ft.lk.Lock()
+	defer ft.lk.Unlock()
+
+	for t, g := range ft.grants {
+		if now.After(g.expiry) {
+			delete(ft.grants, t)
+		}
+	}
+
+	ft.grants[tok] = &fetchGrant{
+		sector: sector,
+		types:  types,
+		expiry: now.Add(FetchTokenTTL),
+	}
+
+	return tok, nil
+}
+
+func (ft *fetchTokens) bind(tok storiface.FetchToken, call storiface.CallID) {
+	ft.lk.Lock()
+	defer ft.lk.Unlock()
+
+	if g, ok := ft.grants[tok]; ok {
+		g.call = call
+	}
+}
+
+func (ft *fetchTokens) revoke(tok storiface.FetchToken) {
+	ft.lk.Lock()
+	defer ft.lk.Unlock()
+
+	delete(ft.grants, tok)
+}
+
+// allows checks that the request only reads files the token was issued for
+func (ft *fetchTokens) allows(r *http.Request, now time.Time) bool {
+	tok := storiface.FetchToken(r.Header.Get(stores.FetchTokenHeader))
+	if tok == "" {
+		tok = storiface.FetchToken(r.URL.Query().Get(stores.FetchTokenParam))
+	}
+	if tok == "" {
+		return false
+	}
+
+	sector, typ, ok := stores.ParseSectorRequest(r)
+	if !ok {
+		log.Warnw("fetch token used for a request other than a sector read", "method", r.Method, "path", r.URL.Path)
+		return false
+	}
+
+	ft.lk.Lock()
+	g, ok := ft.grants[tok]
+	ft.lk.Unlock()
+
+	switch {
+	case !ok:
+		log.Warnw("unknown or revoked fetch token", "sector", sector, "type", typ)
+		return false
+	case now.After(g.expiry):
+		log.Warnw("expired fetch token", "call", g.call, "sector", sector, "type", typ)
+		return false
+	case g.sector != sector || g.types&typ != typ:
+		log.Warnw("fetch token used outside of its scope", "call", g.call, "sector", sector, "type", typ, "allowedSector", g.sector, "allowedTypes", g.types)
+		return false
+	}
+
+	log.Debugw("serving fetch with a call token", "call", g.call, "sector", sector, "type", typ)
+	return true
+}
+
+// FetchTokenAllows returns whether the storage request carries a fetch token
+// allowing it. Callers still have to accept requests authenticated otherwise.
+func (m *Manager) FetchTokenAllows(r *http.Request) bool {
+	return m.fetchTokens.allows(r, time.Now())
+}
+
+// fetch runs a Fetch call on the worker, with a token scoped to the call's
+// sector files
+func (m *Manager) fetch(ctx context.Context, worker Worker, sector storage.SectorRef, ft storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode) error {
+	tok, err := m.fetchTokens.issue(sector.ID, ft, time.Now())
+	if err != nil {
+		return err
+	}
+	defer m.fetchTokens.revoke(tok)
+
+	call, err := worker.Fetch(ctx, sector, ft, ptype, am, tok)
+	if err == nil {
+		m.fetchTokens.bind(tok, call)
+	}
+
+	_, err = m.waitSimpleCall(ctx)(call, err)
+	return err
+}
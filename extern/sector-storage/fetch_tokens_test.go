@@ -0,0 +1,63 @@
+package sectorstorage
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestFetchTokens(t *testing.T) {
+	now := time.Now()
+	sector := abi.SectorID{Miner: 1000, Number: 9}
+	other := abi.SectorID{Miner: 1000, Number: 10}
+
+	ft := newFetchTokens()
+	tok, err := ft.issue(sector, storiface.FTSealed|storiface.FTCache, now)
+	require.NoError(t, err)
+
+	req := func(method string, typ storiface.SectorFileType, id abi.SectorID, tok storiface.FetchToken) *http.Request {
+		r := httptest.NewRequest(method, "/remote/"+typ.String()+"/"+storiface.SectorName(id), nil)
+		if tok != "" {
+			r.Header.Set(stores.FetchTokenHeader, string(tok))
+		}
+		return r
+	}
+
+	require.True(t, ft.allows(req("GET", storiface.FTSealed, sector, tok), now))
+	require.True(t, ft.allows(req("GET", storiface.FTCache, sector, tok), now))
+
+	require.False(t, ft.allows(req("GET", storiface.FTSealed, sector, ""), now), "no token")
+	require.False(t, ft.allows(req("GET", storiface.FTSealed, sector, "nope"), now), "unknown token")
+	require.False(t, ft.allows(req("GET", storiface.FTUnsealed, sector, tok), now), "other file type")
+	require.False(t, ft.allows(req("GET", storiface.FTSealed, other, tok), now), "other sector")
+	require.False(t, ft.allows(req("DELETE", storiface.FTSealed, sector, tok), now), "delete")
+	require.False(t, ft.allows(req("GET", storiface.FTSealed, sector, tok), now.Add(FetchTokenTTL+time.Second)), "expired")
+
+	stat := httptest.NewRequest("GET", "/remote/stat/1234", nil)
+	stat.Header.Set(stores.FetchTokenHeader, string(tok))
+	require.False(t, ft.allows(stat, now), "stat")
+
+	ft.revoke(tok)
+	require.False(t, ft.allows(req("GET", storiface.FTSealed, sector, tok), now), "revoked")
+}
+
+func TestFetchTokensPruneExpired(t *testing.T) {
+	now := time.Now()
+	sector := abi.SectorID{Miner: 1000, Number: 9}
+
+	ft := newFetchTokens()
+	_, err := ft.issue(sector, storiface.FTUnsealed, now)
+	require.NoError(t, err)
+	_, err = ft.issue(sector, storiface.FTUnsealed, now.Add(FetchTokenTTL+time.Second))
+	require.NoError(t, err)
+
+	require.Len(t, ft.grants, 1)
+}
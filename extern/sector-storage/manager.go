@@ -6,10 +6,12 @@ import (
 	"io"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/hashicorp/go-multierror"
 	"github.com/ipfs/go-cid"
+	"github.com/ipfs/go-datastore"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/mitchellh/go-homedir"
 	"golang.org/x/xerrors"
@@ -43,6 +45,12 @@ type Worker interface {
 
 	Session(context.Context) (uuid.UUID, error)
 
+	// CallLogs streams the output the worker wrote while running the call
+	CallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error)
+
+	// ManagerShutdown tells the worker that the manager is going away
+	ManagerShutdown(ctx context.Context) error
+
 	Close() error // TODO: do we need this?
 }
 
@@ -69,8 +77,13 @@ type Manager struct {
 	remoteHnd  *stores.FetchHandler
 	index      stores.SectorIndex
 
+	fetchTokens *fetchTokens
+
 	sched *scheduler
 
+	unsealed   *unsealedLRU
+	unsealJobs *unsealJobs
+
 	storage.Prover
 
 	workLk sync.Mutex
@@ -82,6 +95,24 @@ type Manager struct {
 
 	results map[WorkID]result
 	waitRes map[WorkID]chan struct{}
+
+	validateWorkers bool
+	canaryLk        sync.Mutex
+	canaries        map[WorkerID]struct{} // workers running a canary sector
+
+	verifyPC2 bool
+
+	dispatchLk sync.Mutex
+	dispatch   []*DispatchWorker   // external sealing appliances
+	dispatchDS datastore.Datastore // pending calls, once persisted
+
+	shutdownGrace time.Duration
+	draining      bool          // shutting down, only results something waits for are accepted
+	closing       chan struct{} // closed once result listeners are flushed on shutdown
+
+	// for work running in the background, e.g. unseal jobs; cancelled on Close
+	bgCtx    context.Context
+	bgCancel context.CancelFunc
 }
 
 type result struct {
@@ -98,6 +129,41 @@ type SealerConfig struct {
 	AllowPreCommit2 bool
 	AllowCommit     bool
 	AllowUnseal     bool
+
+	// Total size of sector copies unsealed for retrievals which are kept
+	// around, least recently read copies are removed above this size.
+	// 0 = keep all
+	UnsealedCacheSize uint64
+
+	// Recall tasks which were assigned to busy workers, but didn't start yet,
+	// when other workers able to run them have spare capacity
+	StealTasks bool
+
+	// Have newly connected workers seal a 2KiB canary sector, and check the
+	// results, before scheduling any work on them
+	ValidateNewWorkers bool
+
+	// Also run PreCommit2 on a second worker, on a copy of the PreCommit1
+	// output, and only accept the result when both workers computed the same
+	// CommR and CommD. This transfers the sector cache to the second worker;
+	// both runs then compute concurrently. Sectors whose results differ are
+	// moved to the PreCommit2Mismatch state for the operator to check.
+	VerifyPreCommit2 bool
+
+	// Limit sector transfers to and from storage paths holding sectors which
+	// are being proven to this many bytes per second, so that sealing data
+	// movement doesn't starve PoSt reads; -1 = pause transfers until proving
+	// is done, 0 = don't throttle
+	ProvingTransferLimit int64
+
+	// On shutdown, wait up to this many seconds for results of calls which
+	// are still awaited. Calls which don't return in time are picked up
+	// again after restart.
+	ShutdownGraceSecs uint64
+
+	// External sealing appliances, added as workers driven through a
+	// dispatch transport
+	Dispatch []DispatchConfig
 }
 
 type StorageAuth http.Header
@@ -116,8 +182,12 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 		return nil, xerrors.Errorf("creating prover instance: %w", err)
 	}
 
+	lstor.SetProvingThrottle(stores.NewProvingThrottle(sc.ProvingTransferLimit))
+
 	stor := stores.NewRemote(lstor, si, http.Header(sa), sc.ParallelFetchLimit)
 
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+
 	m := &Manager{
 		ls:         ls,
 		storage:    stor,
@@ -125,8 +195,13 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 		remoteHnd:  &stores.FetchHandler{Local: lstor},
 		index:      si,
 
+		fetchTokens: newFetchTokens(),
+
 		sched: newScheduler(),
 
+		unsealed:   newUnsealedLRU(sc.UnsealedCacheSize),
+		unsealJobs: newUnsealJobs(),
+
 		Prover: prover,
 
 		work:       mss,
@@ -134,8 +209,21 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 		callRes:    map[storiface.CallID]chan result{},
 		results:    map[WorkID]result{},
 		waitRes:    map[WorkID]chan struct{}{},
+
+		validateWorkers: sc.ValidateNewWorkers,
+		canaries:        map[WorkerID]struct{}{},
+
+		verifyPC2: sc.VerifyPreCommit2,
+
+		shutdownGrace: time.Duration(sc.ShutdownGraceSecs) * time.Second,
+		closing:       make(chan struct{}),
+
+		bgCtx:    bgCtx,
+		bgCancel: bgCancel,
 	}
 
+	m.sched.steal = sc.StealTasks
+
 	m.setupWorkTracker()
 
 	go m.sched.runSched()
@@ -159,13 +247,38 @@ func New(ctx context.Context, ls stores.LocalStorage, si stores.SectorIndex, sc
 		localTasks = append(localTasks, sealtasks.TTUnseal)
 	}
 
-	err = m.AddWorker(ctx, NewLocalWorker(WorkerConfig{
+	// the local worker isn't validated, it's part of the miner
+	err = m.sched.runWorker(ctx, NewLocalWorker(WorkerConfig{
 		TaskTypes: localTasks,
 	}, stor, lstor, si, m, wss))
 	if err != nil {
 		return nil, xerrors.Errorf("adding local worker: %w", err)
 	}
 
+	for _, dc := range sc.Dispatch {
+		if dc.Discover != "" {
+			if err := checkDispatchDiscovery(dc); err != nil {
+				return nil, xerrors.Errorf("dispatch discovery of %s: %w", dc.Discover, err)
+			}
+			go m.discoverDispatch(ctx, dc, lstor, si)
+			continue
+		}
+
+		tr, err := NewDispatchTransport(dc)
+		if err != nil {
+			return nil, xerrors.Errorf("creating dispatch transport for %s: %w", dc.Endpoint, err)
+		}
+
+		w, err := NewDispatchWorker(ctx, dc, tr, lstor, si, m)
+		if err != nil {
+			return nil, xerrors.Errorf("creating dispatch worker for %s: %w", dc.Endpoint, err)
+		}
+
+		if err := m.addDispatchWorker(ctx, w); err != nil {
+			return nil, xerrors.Errorf("adding dispatch worker for %s: %w", dc.Endpoint, err)
+		}
+	}
+
 	return m, nil
 }
 
@@ -187,7 +300,18 @@ func (m *Manager) AddLocalStorage(ctx context.Context, path string) error {
 	return nil
 }
 
+// PersistTransfers keeps records of sector files being fetched to the miner
+// in the datastore, and finishes or cleans up the fetches a restart
+// interrupted. It should be called right after New, before any work is
+// scheduled.
+func (m *Manager) PersistTransfers(ctx context.Context, ds datastore.Datastore) error {
+	return m.storage.PersistTransfers(ctx, ds)
+}
+
 func (m *Manager) AddWorker(ctx context.Context, w Worker) error {
+	if m.validateWorkers {
+		return m.addValidatedWorker(ctx, w)
+	}
 	return m.sched.runWorker(ctx, w)
 }
 
@@ -201,8 +325,7 @@ func schedNop(context.Context, Worker) error {
 
 func (m *Manager) schedFetch(sector storage.SectorRef, ft storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode) func(context.Context, Worker) error {
 	return func(ctx context.Context, worker Worker) error {
-		_, err := m.waitSimpleCall(ctx)(worker.Fetch(ctx, sector, ft, ptype, am))
-		return err
+		return m.fetch(ctx, worker, sector, ft, ptype, am)
 	}
 }
 
@@ -239,6 +362,17 @@ func (m *Manager) tryReadUnsealedPiece(ctx context.Context, sink io.Writer, sect
 
 	foundUnsealed = len(best) > 0
 	if foundUnsealed { // append to existing
+		holder, err := m.unsealedOnAppliance(ctx, sector.ID)
+		if err != nil {
+			returnErr = xerrors.Errorf("read piece: checking for unsealed sector on appliances: %w", err)
+			return
+		}
+		if holder != nil {
+			// the appliance makes sure the range is unsealed before it's read
+			selector = newExistingSelector(m.index, sector.ID, storiface.FTUnsealed, false)
+			return
+		}
+
 		// There is unsealed sector, see if we can read from it
 
 		selector = newExistingSelector(m.index, sector.ID, storiface.FTUnsealed, false)
@@ -260,6 +394,7 @@ func (m *Manager) ReadPiece(ctx context.Context, sink io.Writer, sector storage.
 		return err
 	}
 	if readOk {
+		m.unsealed.touch(sector.ID)
 		return nil
 	}
 	ctx, cancel := context.WithCancel(ctx)
@@ -269,13 +404,70 @@ func (m *Manager) ReadPiece(ctx context.Context, sink io.Writer, sector storage.
 		return xerrors.Errorf("acquiring unseal sector lock: %w", err)
 	}
 
+	if err := m.unsealPiece(ctx, sector, offset, size, ticket, unsealed, foundUnsealed, selector, nil); err != nil {
+		return err
+	}
+
+	holder, err := m.unsealedOnAppliance(ctx, sector.ID)
+	if err != nil {
+		return xerrors.Errorf("checking for unsealed sector on appliances: %w", err)
+	}
+	if holder != nil {
+		return m.readUnsealedOnAppliance(ctx, sink, holder, sector, offset, size)
+	}
+
+	selector = newExistingSelector(m.index, sector.ID, storiface.FTUnsealed, false)
+
+	err = m.sched.Schedule(ctx, sector, sealtasks.TTReadUnsealed, selector, m.schedFetch(sector, storiface.FTUnsealed, storiface.PathSealing, storiface.AcquireMove),
+		m.readPiece(sink, sector, offset, size, &readOk))
+	if err != nil {
+		return xerrors.Errorf("reading piece from sealed sector: %w", err)
+	}
+
+	if !readOk {
+		return xerrors.Errorf("failed to read unsealed piece")
+	}
+
+	return nil
+}
+
+// UnsealPiece makes sure that the specified range of the sector is unsealed,
+// without reading it. onStart, if not nil, is called when a worker starts
+// unsealing.
+func (m *Manager) UnsealPiece(ctx context.Context, sector storage.SectorRef, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, ticket abi.SealRandomness, unsealed cid.Cid, onStart func()) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := m.index.StorageLock(ctx, sector.ID, storiface.FTSealed|storiface.FTCache, storiface.FTUnsealed); err != nil {
+		return xerrors.Errorf("acquiring unseal sector lock: %w", err)
+	}
+
+	best, err := m.index.StorageFindSector(ctx, sector.ID, storiface.FTUnsealed, 0, false)
+	if err != nil {
+		return xerrors.Errorf("checking for already existing unsealed sector: %w", err)
+	}
+
+	foundUnsealed := len(best) > 0
+
+	var selector WorkerSelector
+	if foundUnsealed {
+		selector = newExistingSelector(m.index, sector.ID, storiface.FTUnsealed, false)
+	} else {
+		selector = newAllocSelector(m.index, storiface.FTUnsealed, storiface.PathSealing)
+	}
+
+	return m.unsealPiece(ctx, sector, offset, size, ticket, unsealed, foundUnsealed, selector, onStart)
+}
+
+// caller must hold the unseal sector lock
+func (m *Manager) unsealPiece(ctx context.Context, sector storage.SectorRef, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, ticket abi.SealRandomness, unsealed cid.Cid, foundUnsealed bool, selector WorkerSelector, onStart func()) error {
 	unsealFetch := func(ctx context.Context, worker Worker) error {
-		if _, err := m.waitSimpleCall(ctx)(worker.Fetch(ctx, sector, storiface.FTSealed|storiface.FTCache, storiface.PathSealing, storiface.AcquireCopy)); err != nil {
+		if err := m.fetch(ctx, worker, sector, storiface.FTSealed|storiface.FTCache, storiface.PathSealing, storiface.AcquireCopy); err != nil {
 			return xerrors.Errorf("copy sealed/cache sector data: %w", err)
 		}
 
 		if foundUnsealed {
-			if _, err := m.waitSimpleCall(ctx)(worker.Fetch(ctx, sector, storiface.FTUnsealed, storiface.PathSealing, storiface.AcquireMove)); err != nil {
+			if err := m.fetch(ctx, worker, sector, storiface.FTUnsealed, storiface.PathSealing, storiface.AcquireMove); err != nil {
 				return xerrors.Errorf("copy unsealed sector data: %w", err)
 			}
 		}
@@ -285,7 +477,21 @@ func (m *Manager) ReadPiece(ctx context.Context, sink io.Writer, sector storage.
 	if unsealed == cid.Undef {
 		return xerrors.Errorf("cannot unseal piece (sector: %d, offset: %d size: %d) - unsealed cid is undefined", sector, offset, size)
 	}
+
+	// unseal on the appliance holding the sealed replica, if there's one
+	dsel, err := m.dispatchUnsealSelector(ctx, sector.ID, foundUnsealed)
+	if err != nil {
+		return xerrors.Errorf("checking for sealed sector on appliances: %w", err)
+	}
+	if dsel != nil {
+		selector = dsel
+	}
+
 	err = m.sched.Schedule(ctx, sector, sealtasks.TTUnseal, selector, unsealFetch, func(ctx context.Context, w Worker) error {
+		if onStart != nil {
+			onStart()
+		}
+
 		// TODO: make restartable
 		_, err := m.waitSimpleCall(ctx)(w.UnsealPiece(ctx, sector, offset, size, ticket, unsealed))
 		return err
@@ -294,16 +500,14 @@ func (m *Manager) ReadPiece(ctx context.Context, sink io.Writer, sector storage.
 		return err
 	}
 
-	selector = newExistingSelector(m.index, sector.ID, storiface.FTUnsealed, false)
-
-	err = m.sched.Schedule(ctx, sector, sealtasks.TTReadUnsealed, selector, m.schedFetch(sector, storiface.FTUnsealed, storiface.PathSealing, storiface.AcquireMove),
-		m.readPiece(sink, sector, offset, size, &readOk))
+	ssize, err := sector.ProofType.SectorSize()
 	if err != nil {
-		return xerrors.Errorf("reading piece from sealed sector: %w", err)
+		log.Errorf("unseal piece: getting sector size: %+v", err)
+		return nil
 	}
 
-	if !readOk {
-		return xerrors.Errorf("failed to read unsealed piece")
+	if evict := m.unsealed.add(sector, uint64(ssize)); len(evict) > 0 {
+		go m.evictUnsealed(context.Background(), evict)
 	}
 
 	return nil
@@ -355,6 +559,8 @@ func (m *Manager) SealPreCommit1(ctx context.Context, sector storage.SectorRef,
 	}
 	defer cancel()
 
+	meta := pieceMeta(ctx, pieces)
+
 	var waitErr error
 	waitRes := func() {
 		p, werr := m.waitWork(ctx, wk)
@@ -381,7 +587,8 @@ func (m *Manager) SealPreCommit1(ctx context.Context, sector storage.SectorRef,
 	selector := newAllocSelector(m.index, storiface.FTCache|storiface.FTSealed, storiface.PathSealing)
 
 	err = m.sched.Schedule(ctx, sector, sealtasks.TTPreCommit1, selector, m.schedFetch(sector, storiface.FTUnsealed, storiface.PathSealing, storiface.AcquireMove), func(ctx context.Context, w Worker) error {
-		err := m.startWork(ctx, w, wk)(w.SealPreCommit1(ctx, sector, ticket, pieces))
+		// the NUMA node is picked by the tracked worker, which knows what else runs there
+		err := m.startWork(ctx, w, wk)(w.SealPreCommit1(ctx, sector, ticket, pieces, meta, storiface.NoNUMANode))
 		if err != nil {
 			return err
 		}
@@ -419,17 +626,42 @@ func (m *Manager) SealPreCommit2(ctx context.Context, sector storage.SectorRef,
 	}
 
 	if wait { // already in progress
+		if m.verifyPC2 {
+			log.Warnw("PreCommit2 already in progress, not verifying the result", "sector", sector.ID)
+		}
 		waitRes()
 		return out, waitErr
 	}
 
+	var selector WorkerSelector = newExistingSelector(m.index, sector.ID, storiface.FTCache|storiface.FTSealed, true)
+
+	// PreCommit2 updates the sealed replica in place, so the verification
+	// has to copy the PreCommit1 output before the actual run starts; both
+	// then run concurrently.
+	var verified *pc2Verification
+	if m.verifyPC2 {
+		verified = m.verifyPreCommit2(ctx, sector, phase1Out)
+		verifier, err := verified.waitCopied(ctx)
+		if err != nil {
+			return storage.SectorCids{}, xerrors.Errorf("verification run: %w", err)
+		}
+		selector = &excludeSelector{WorkerSelector: selector, exclude: verifier}
+	}
+
 	if err := m.index.StorageLock(ctx, sector.ID, storiface.FTSealed, storiface.FTCache); err != nil {
 		return storage.SectorCids{}, xerrors.Errorf("acquiring sector lock: %w", err)
 	}
 
-	selector := newExistingSelector(m.index, sector.ID, storiface.FTCache|storiface.FTSealed, true)
-
+	var worker WorkerID
 	err = m.sched.Schedule(ctx, sector, sealtasks.TTPreCommit2, selector, m.schedFetch(sector, storiface.FTCache|storiface.FTSealed, storiface.PathSealing, storiface.AcquireMove), func(ctx context.Context, w Worker) error {
+		if verified != nil {
+			sessID, err := w.Session(ctx)
+			if err != nil {
+				return xerrors.Errorf("getting worker session: %w", err)
+			}
+			worker = WorkerID(sessID)
+		}
+
 		err := m.startWork(ctx, w, wk)(w.SealPreCommit2(ctx, sector, phase1Out))
 		if err != nil {
 			return err
@@ -442,6 +674,15 @@ func (m *Manager) SealPreCommit2(ctx context.Context, sector storage.SectorRef,
 		return storage.SectorCids{}, err
 	}
 
+	if verified != nil && waitErr == nil {
+		if err := verified.wait(ctx); err != nil {
+			return storage.SectorCids{}, xerrors.Errorf("verification run: %w", err)
+		}
+		if err := verified.check(sector, worker, out); err != nil {
+			return storage.SectorCids{}, err
+		}
+	}
+
 	return out, waitErr
 }
 
@@ -455,6 +696,8 @@ func (m *Manager) SealCommit1(ctx context.Context, sector storage.SectorRef, tic
 	}
 	defer cancel()
 
+	meta := pieceMeta(ctx, pieces)
+
 	var waitErr error
 	waitRes := func() {
 		p, werr := m.waitWork(ctx, wk)
@@ -482,7 +725,7 @@ func (m *Manager) SealCommit1(ctx context.Context, sector storage.SectorRef, tic
 	selector := newExistingSelector(m.index, sector.ID, storiface.FTCache|storiface.FTSealed, false)
 
 	err = m.sched.Schedule(ctx, sector, sealtasks.TTCommit1, selector, m.schedFetch(sector, storiface.FTCache|storiface.FTSealed, storiface.PathSealing, storiface.AcquireMove), func(ctx context.Context, w Worker) error {
-		err := m.startWork(ctx, w, wk)(w.SealCommit1(ctx, sector, ticket, seed, pieces, cids))
+		err := m.startWork(ctx, w, wk)(w.SealCommit1(ctx, sector, ticket, seed, pieces, cids, meta))
 		if err != nil {
 			return err
 		}
@@ -594,7 +837,22 @@ func (m *Manager) FinalizeSector(ctx context.Context, sector storage.SectorRef,
 }
 
 func (m *Manager) ReleaseUnsealed(ctx context.Context, sector storage.SectorRef, safeToFree []storage.Range) error {
-	log.Warnw("ReleaseUnsealed todo")
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := m.index.StorageLock(ctx, sector.ID, storiface.FTNone, storiface.FTUnsealed); err != nil {
+		return xerrors.Errorf("acquiring sector lock: %w", err)
+	}
+
+	// unsealed files on appliance storage are released by the appliance;
+	// local workers don't release unsealed files yet
+	ok, err := m.releaseUnsealedRemote(ctx, sector, safeToFree)
+	if err != nil {
+		return xerrors.Errorf("releasing unsealed file on appliance: %w", err)
+	}
+	if !ok {
+		log.Warnw("ReleaseUnsealed todo")
+	}
 	return nil
 }
 
@@ -606,6 +864,8 @@ func (m *Manager) Remove(ctx context.Context, sector storage.SectorRef) error {
 		return xerrors.Errorf("acquiring sector lock: %w", err)
 	}
 
+	m.unsealed.remove(sector.ID)
+
 	var err error
 
 	if rerr := m.storage.Remove(ctx, sector.ID, storiface.FTSealed, true); rerr != nil {
@@ -683,6 +943,15 @@ func (m *Manager) FsStat(ctx context.Context, id stores.ID) (fsutil.FsStat, erro
 	return m.storage.FsStat(ctx, id)
 }
 
+// WorkerTasksChanged re-runs scheduling, so that changes to the task types
+// accepted by workers, which selectors query on every pass, are picked up
+func (m *Manager) WorkerTasksChanged() {
+	select {
+	case m.sched.workerChange <- struct{}{}:
+	default: // workerChange is buffered, a pass is already due
+	}
+}
+
 func (m *Manager) SchedDiag(ctx context.Context, doSched bool) (interface{}, error) {
 	if doSched {
 		select {
@@ -736,8 +1005,17 @@ func (m *Manager) SchedDiag(ctx context.Context, doSched bool) (interface{}, err
 	return i, nil
 }
 
+// Close shuts the manager down, see drain
 func (m *Manager) Close(ctx context.Context) error {
-	return m.sched.Close(ctx)
+	m.bgCancel()
+	m.drain(ctx)
+	err := m.sched.Close(ctx)
+
+	if ferr := m.sched.history.flush(); ferr != nil {
+		log.Errorf("%+v", ferr)
+	}
+
+	return err
 }
 
 var _ SectorManager = &Manager{}
@@ -11,6 +11,8 @@ import (
 
 	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/go-state-types/abi"
+
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
@@ -96,6 +98,7 @@ func (m *Manager) setupWorkTracker() {
 			}
 		case wsRunning:
 			m.callToWork[st.WorkerCall] = wid
+			m.sched.workTracker.restore(st.WorkerCall, wid.Method, time.Unix(st.StartTime, 0), st.WorkerHostname)
 		}
 	}
 }
@@ -254,6 +257,7 @@ func (m *Manager) waitWork(ctx context.Context, wid WorkID) (interface{}, error)
 
 			res := <-cr
 			delete(m.callRes, ws.WorkerCall)
+			m.sched.workTracker.onCollected(ws.WorkerCall)
 
 			m.workLk.Unlock()
 			return res.r, res.err
@@ -265,6 +269,7 @@ func (m *Manager) waitWork(ctx context.Context, wid WorkID) (interface{}, error)
 
 	done := func() {
 		delete(m.results, wid)
+		m.sched.workTracker.onCollected(ws.WorkerCall)
 
 		_, ok := m.callToWork[ws.WorkerCall]
 		if ok {
@@ -304,6 +309,9 @@ func (m *Manager) waitWork(ctx context.Context, wid WorkID) (interface{}, error)
 		done()
 
 		return res.r, res.err
+	case <-m.closing:
+		// the work stays in the running state, and is picked up again after restart
+		return nil, xerrors.Errorf("waiting for work result: manager shutting down")
 	case <-ctx.Done():
 		return nil, xerrors.Errorf("waiting for work result: %w", ctx.Err())
 	}
@@ -343,7 +351,10 @@ func (m *Manager) waitCall(ctx context.Context, callID storiface.CallID) (interf
 
 	select {
 	case res := <-ch:
+		m.sched.workTracker.onCollected(callID)
 		return res.r, res.err
+	case <-m.closing:
+		return nil, xerrors.Errorf("waiting for call result: manager shutting down")
 	case <-ctx.Done():
 		return nil, xerrors.Errorf("waiting for call result: %w", ctx.Err())
 	}
@@ -357,11 +368,20 @@ func (m *Manager) returnResult(callID storiface.CallID, r interface{}, cerr *sto
 		res.err = cerr
 	}
 
-	m.sched.workTracker.onDone(callID)
-
 	m.workLk.Lock()
 	defer m.workLk.Unlock()
 
+	if err := m.canAcceptResult(callID); err != nil {
+		return err
+	}
+
+	return m.deliverResult(callID, res, cerr)
+}
+
+// caller must hold m.workLk
+func (m *Manager) deliverResult(callID storiface.CallID, res result, cerr *storiface.CallError) error {
+	m.sched.workTracker.onDone(callID, cerr)
+
 	wid, ok := m.callToWork[callID]
 	if !ok {
 		rch, ok := m.callRes[callID]
@@ -388,6 +408,10 @@ func (m *Manager) returnResult(callID storiface.CallID, r interface{}, cerr *sto
 
 	m.results[wid] = res
 
+	// the result is kept with the work until it's collected, and listed from
+	// the work state
+	m.sched.workTracker.onCollected(callID)
+
 	err := m.work.Get(wid).Mutate(func(ws *WorkState) error {
 		ws.Status = wsDone
 		return nil
@@ -413,5 +437,30 @@ func (m *Manager) returnResult(callID storiface.CallID, r interface{}, cerr *sto
 
 func (m *Manager) Abort(ctx context.Context, call storiface.CallID) error {
 	// TODO: Allow temp error
-	return m.returnResult(call, nil, storiface.Err(storiface.ErrUnknown, xerrors.New("task aborted")))
+	cerr := storiface.Err(storiface.ErrUnknown, xerrors.New("task aborted"))
+
+	m.workLk.Lock()
+	defer m.workLk.Unlock()
+
+	// aborts are requested by the user, deliver them right away
+	return m.deliverResult(call, result{err: cerr}, cerr)
+}
+
+// AbortSectorCalls aborts the calls running on workers for the sector, e.g.
+// when it's stuck, so that the sealing state machine can retry them. It
+// returns the number of aborted calls.
+func (m *Manager) AbortSectorCalls(ctx context.Context, sector abi.SectorID) (int, error) {
+	var n int
+	for _, t := range m.sched.workTracker.jobs() {
+		if t.job.Sector != sector || t.job.RunWait != 0 {
+			continue
+		}
+
+		if err := m.Abort(ctx, t.job.ID); err != nil {
+			return n, xerrors.Errorf("aborting call %s: %w", t.job.ID, err)
+		}
+		n++
+	}
+
+	return n, nil
 }
@@ -0,0 +1,155 @@
+package sectorstorage
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-commp-utils/zerocomm"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/lib/nullreader"
+)
+
+// CanaryTimeout is how long a new worker has to seal its canary sector
+var CanaryTimeout = 10 * time.Minute
+
+// canary sectors are 2KiB, so they seal in seconds regardless of the sector
+// size the miner uses. They belong to the (non-miner) actor 0, and are
+// numbered from the startup time, so that they don't clash with leftovers
+// from before a restart.
+var (
+	canaryProof       = abi.RegisteredSealProof_StackedDrg2KiBV1_1
+	canarySectorCount = uint64(time.Now().Unix()) << 16
+	canaryTicket      = abi.SealRandomness{1, 2, 3, 4, 5, 6, 7, 8}
+)
+
+// addValidatedWorker starts scheduling work on a newly connected worker once
+// it has sealed a canary sector correctly.
+func (m *Manager) addValidatedWorker(ctx context.Context, w Worker) error {
+	sessID, err := w.Session(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting worker session: %w", err)
+	}
+	wid := WorkerID(sessID)
+
+	m.sched.workersLk.RLock()
+	_, known := m.sched.workers[wid]
+	m.sched.workersLk.RUnlock()
+	if known {
+		// reconnecting worker, already validated
+		return m.sched.runWorker(ctx, w)
+	}
+
+	m.canaryLk.Lock()
+	if _, running := m.canaries[wid]; running {
+		m.canaryLk.Unlock()
+		return nil
+	}
+	m.canaries[wid] = struct{}{}
+	m.canaryLk.Unlock()
+
+	go func() {
+		defer func() {
+			m.canaryLk.Lock()
+			delete(m.canaries, wid)
+			m.canaryLk.Unlock()
+		}()
+
+		cctx, cancel := context.WithTimeout(context.Background(), CanaryTimeout)
+		defer cancel()
+
+		if err := m.runCanary(cctx, w); err != nil {
+			log.Errorw("worker failed canary sector validation, not scheduling work on it", "worker", wid, "error", err)
+			return
+		}
+
+		log.Infow("worker passed canary sector validation", "worker", wid)
+		if err := m.sched.runWorker(cctx, w); err != nil {
+			log.Errorw("adding validated worker", "worker", wid, "error", err)
+		}
+	}()
+
+	return nil
+}
+
+// runCanary has the worker seal a 2KiB sector of zeros, as far as the worker
+// supports the sealing tasks, and checks the returned commitments against the
+// known ones. Workers which don't add pieces pass without a canary.
+func (m *Manager) runCanary(ctx context.Context, w Worker) error {
+	tasks, err := w.TaskTypes(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting supported task types: %w", err)
+	}
+	if _, ok := tasks[sealtasks.TTAddPiece]; !ok {
+		return nil
+	}
+
+	sector := storage.SectorRef{
+		ID: abi.SectorID{
+			Miner:  0,
+			Number: abi.SectorNumber(atomic.AddUint64(&canarySectorCount, 1)),
+		},
+		ProofType: canaryProof,
+	}
+	defer func() {
+		if err := m.Remove(context.TODO(), sector); err != nil {
+			log.Warnw("removing canary sector", "sector", sector.ID, "error", err)
+		}
+	}()
+
+	ssize, err := canaryProof.SectorSize()
+	if err != nil {
+		return err
+	}
+	pieceSize := abi.PaddedPieceSize(ssize).Unpadded()
+
+	// the CommD of a sector filled with a single zero piece is the piece's commP
+	expected := zerocomm.ZeroPieceCommitment(pieceSize)
+
+	r, err := m.waitSimpleCall(ctx)(w.AddPiece(ctx, sector, nil, pieceSize, io.LimitReader(&nullreader.Reader{}, int64(pieceSize))))
+	if err != nil {
+		return xerrors.Errorf("adding canary piece: %w", err)
+	}
+	pi, ok := r.(abi.PieceInfo)
+	if !ok {
+		return xerrors.Errorf("unexpected AddPiece result type %T", r)
+	}
+	if !pi.PieceCID.Equals(expected) {
+		return xerrors.Errorf("canary piece commitment mismatch: got %s, expected %s", pi.PieceCID, expected)
+	}
+
+	_, pc1 := tasks[sealtasks.TTPreCommit1]
+	_, pc2 := tasks[sealtasks.TTPreCommit2]
+	if !pc1 || !pc2 {
+		return nil
+	}
+
+	r, err = m.waitSimpleCall(ctx)(w.SealPreCommit1(ctx, sector, canaryTicket, []abi.PieceInfo{pi}, nil, storiface.NoNUMANode))
+	if err != nil {
+		return xerrors.Errorf("canary precommit1: %w", err)
+	}
+	p1o, ok := r.(storage.PreCommit1Out)
+	if !ok {
+		return xerrors.Errorf("unexpected SealPreCommit1 result type %T", r)
+	}
+
+	r, err = m.waitSimpleCall(ctx)(w.SealPreCommit2(ctx, sector, p1o))
+	if err != nil {
+		return xerrors.Errorf("canary precommit2: %w", err)
+	}
+	cids, ok := r.(storage.SectorCids)
+	if !ok {
+		return xerrors.Errorf("unexpected SealPreCommit2 result type %T", r)
+	}
+	if !cids.Unsealed.Equals(expected) {
+		return xerrors.Errorf("canary unsealed CID mismatch: got %s, expected %s", cids.Unsealed, expected)
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package sectorstorage
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// corruptingWorker adds pieces of ones instead of the data it's given
+type corruptingWorker struct {
+	*testWorker
+}
+
+func (c *corruptingWorker) AddPiece(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (storiface.CallID, error) {
+	return c.testWorker.AddPiece(ctx, sector, pieceSizes, newPieceSize, bytes.NewReader(bytes.Repeat([]byte{1}, int(newPieceSize))))
+}
+
+func TestCanary(t *testing.T) {
+	ctx := context.Background()
+	m, lstor, _, _, cleanup := newTestMgr(ctx, t, datastore.NewMapDatastore())
+	defer cleanup()
+
+	wcfg := WorkerConfig{
+		TaskTypes: []sealtasks.TaskType{sealtasks.TTAddPiece},
+	}
+
+	require.NoError(t, m.runCanary(ctx, newTestWorker(wcfg, lstor, m)))
+	require.Error(t, m.runCanary(ctx, &corruptingWorker{newTestWorker(wcfg, lstor, m)}))
+}
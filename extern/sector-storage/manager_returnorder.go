@@ -0,0 +1,102 @@
+package sectorstorage
+
+import (
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// MaxUnclaimedResults is the number of results returned by workers which can
+// be buffered while nothing waits for them. Above that, returns are rejected,
+// and workers retry them later.
+var MaxUnclaimedResults = 256
+
+// sealing phases in the order sector-storage FSM goes through them
+var phaseOrder = map[sealtasks.TaskType]int{
+	sealtasks.TTAddPiece:   0,
+	sealtasks.TTPreCommit1: 1,
+	sealtasks.TTPreCommit2: 2,
+	sealtasks.TTCommit1:    3,
+	sealtasks.TTCommit2:    4,
+	sealtasks.TTFinalize:   5,
+}
+
+// canAcceptResult checks whether a result for the call can be accepted now.
+// Results which something is already waiting for are always accepted. Other
+// results are rejected when too many results are waiting to be claimed, or
+// when work from an earlier phase of the same sector is still running, so
+// that results for a sector are delivered in phase order.
+//
+// While the manager shuts down, results nothing waits for are rejected, as
+// they would be lost; workers return them again after restart.
+//
+// Caller must hold m.workLk
+func (m *Manager) canAcceptResult(callID storiface.CallID) error {
+	if m.isClosing() {
+		return xerrors.Errorf("miner shutting down; return after restart")
+	}
+
+	wid, tracked := m.callToWork[callID]
+	if tracked {
+		if _, waiting := m.waitRes[wid]; waiting {
+			return nil
+		}
+	} else if ch, ok := m.callRes[callID]; ok && len(ch) == 0 {
+		return nil
+	}
+
+	if m.draining {
+		return xerrors.Errorf("miner shutting down; return after restart")
+	}
+
+	if n := m.unclaimedResults(); n >= MaxUnclaimedResults {
+		return storiface.Err(storiface.ErrTempReturnLater, xerrors.Errorf("%d results are waiting to be claimed", n))
+	}
+
+	if tracked {
+		if prev, ok := m.pendingEarlierPhase(callID, wid.Method); ok {
+			return storiface.Err(storiface.ErrTempReturnLater, xerrors.Errorf("result for %s held back until %s for the same sector returns", wid, prev))
+		}
+	}
+
+	return nil
+}
+
+// caller must hold m.workLk
+func (m *Manager) unclaimedResults() int {
+	n := len(m.results)
+	for _, ch := range m.callRes {
+		n += len(ch)
+	}
+	return n
+}
+
+// pendingEarlierPhase returns the work of an earlier phase of the sector which
+// didn't return yet. Calls which aren't running on a connected worker are
+// ignored, as the worker they were made on may never return them.
+//
+// caller must hold m.workLk
+func (m *Manager) pendingEarlierPhase(callID storiface.CallID, method sealtasks.TaskType) (WorkID, bool) {
+	phase, ok := phaseOrder[method]
+	if !ok {
+		return WorkID{}, false
+	}
+
+	for c, w := range m.callToWork {
+		if c.Sector != callID.Sector || c == callID {
+			continue
+		}
+		if p, ok := phaseOrder[w.Method]; !ok || p >= phase {
+			continue
+		}
+		if _, returned := m.results[w]; returned {
+			continue
+		}
+		if !m.sched.workTracker.live(c) {
+			continue
+		}
+		return w, true
+	}
+	return WorkID{}, false
+}
@@ -0,0 +1,125 @@
+package sectorstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestCanAcceptResult(t *testing.T) {
+	m := &Manager{
+		sched: newScheduler(),
+
+		callToWork: map[storiface.CallID]WorkID{},
+		callRes:    map[storiface.CallID]chan result{},
+		results:    map[WorkID]result{},
+		waitRes:    map[WorkID]chan struct{}{},
+	}
+
+	wid := WorkerID(uuid.New())
+	m.sched.workTracker.workerConnected(wid, "worker")
+
+	sector := abi.SectorID{Miner: 1000, Number: 1}
+	newCall := func(sector abi.SectorID, tt sealtasks.TaskType) (storiface.CallID, WorkID) {
+		c := storiface.CallID{Sector: sector, ID: uuid.New()}
+		w := WorkID{Method: tt, Params: c.ID.String()}
+		m.callToWork[c] = w
+		_, _ = m.sched.workTracker.track(wid, nil, storage.SectorRef{ID: sector}, tt)(c, nil)
+		return c, w
+	}
+
+	p1c, p1w := newCall(sector, sealtasks.TTPreCommit1)
+	p2c, p2w := newCall(sector, sealtasks.TTPreCommit2)
+	otherc, _ := newCall(abi.SectorID{Miner: 1000, Number: 2}, sealtasks.TTCommit2)
+
+	// PC2 result is held back while PC1 of the same sector is running
+	err := m.canAcceptResult(p2c)
+	require.Error(t, err)
+	code, ok := storiface.CallErrorCode(err)
+	require.True(t, ok)
+	require.Equal(t, storiface.ErrTempReturnLater, code)
+
+	// also when only the message made it over RPC
+	code, ok = storiface.CallErrorCode(xerrors.New(err.Error()))
+	require.True(t, ok)
+	require.Equal(t, storiface.ErrTempReturnLater, code)
+
+	require.NoError(t, m.canAcceptResult(p1c))
+	require.NoError(t, m.canAcceptResult(otherc))
+
+	// unless something already waits for it
+	m.waitRes[p2w] = make(chan struct{})
+	require.NoError(t, m.canAcceptResult(p2c))
+	delete(m.waitRes, p2w)
+
+	// or the worker running PC1 is gone
+	m.sched.workTracker.workerDisconnected(wid)
+	require.NoError(t, m.canAcceptResult(p2c))
+	m.sched.workTracker.workerConnected(wid, "worker")
+
+	// PC1 restored after a restart is matched by the hostname of its worker
+	m.sched.workTracker.restore(p1c, sealtasks.TTPreCommit1, time.Now(), "worker")
+	require.Error(t, m.canAcceptResult(p2c))
+	m.sched.workTracker.restore(p1c, sealtasks.TTPreCommit1, time.Now(), "gone")
+	require.NoError(t, m.canAcceptResult(p2c))
+
+	// accepted once PC1 returned
+	m.sched.workTracker.restore(p1c, sealtasks.TTPreCommit1, time.Now(), "worker")
+	m.results[p1w] = result{}
+	require.NoError(t, m.canAcceptResult(p2c))
+
+	// backpressure
+	old := MaxUnclaimedResults
+	defer func() { MaxUnclaimedResults = old }()
+	MaxUnclaimedResults = 1
+
+	require.Error(t, m.canAcceptResult(otherc))
+	m.waitRes[p2w] = make(chan struct{})
+	require.NoError(t, m.canAcceptResult(p2c))
+}
+
+func TestCanAcceptResultDraining(t *testing.T) {
+	m := &Manager{
+		callToWork: map[storiface.CallID]WorkID{},
+		callRes:    map[storiface.CallID]chan result{},
+		results:    map[WorkID]result{},
+		waitRes:    map[WorkID]chan struct{}{},
+
+		closing: make(chan struct{}),
+	}
+
+	sector := abi.SectorID{Miner: 1000, Number: 1}
+	awaited := storiface.CallID{Sector: sector, ID: uuid.New()}
+	aw := WorkID{Method: sealtasks.TTPreCommit1, Params: awaited.ID.String()}
+	m.callToWork[awaited] = aw
+	m.waitRes[aw] = make(chan struct{})
+
+	unclaimed := storiface.CallID{Sector: abi.SectorID{Miner: 1000, Number: 2}, ID: uuid.New()}
+	m.callToWork[unclaimed] = WorkID{Method: sealtasks.TTCommit2, Params: unclaimed.ID.String()}
+
+	simple := storiface.CallID{Sector: sector, ID: uuid.New()}
+	m.callRes[simple] = make(chan result, 1)
+
+	require.NoError(t, m.canAcceptResult(unclaimed))
+
+	// while draining, only results something waits for are accepted
+	m.draining = true
+	require.NoError(t, m.canAcceptResult(awaited))
+	require.NoError(t, m.canAcceptResult(simple))
+	require.Error(t, m.canAcceptResult(unclaimed))
+	require.Equal(t, 2, m.awaitedResults())
+
+	// after listeners are flushed, nothing is accepted
+	close(m.closing)
+	require.Error(t, m.canAcceptResult(awaited))
+	require.Error(t, m.canAcceptResult(simple))
+}
@@ -0,0 +1,122 @@
+package sectorstorage
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// how long workers are given to acknowledge the shutdown notification
+const shutdownNotifyTimeout = 5 * time.Second
+
+// how often drain checks whether awaited results were returned
+const drainPollInterval = 100 * time.Millisecond
+
+// drain prepares the manager for shutdown:
+//   - from now on, only results something is waiting for are accepted, other
+//     results are rejected, and returned by workers again after restart
+//   - workers are notified that the manager is going away
+//   - for up to the configured grace period, awaited results are given a
+//     chance to be returned
+//   - result listeners are flushed; callers waiting for work get an error, and
+//     the work is picked up again after restart, as its persisted state is
+//     still 'running'
+func (m *Manager) drain(ctx context.Context) {
+	m.workLk.Lock()
+	if m.isClosing() {
+		m.workLk.Unlock()
+		return
+	}
+	m.draining = true
+	m.workLk.Unlock()
+
+	m.notifyShutdown(ctx)
+
+	if m.shutdownGrace > 0 {
+		m.waitAwaited(ctx, m.shutdownGrace)
+	}
+
+	m.workLk.Lock()
+	defer m.workLk.Unlock()
+
+	for c, wid := range m.callToWork {
+		if _, done := m.results[wid]; done {
+			continue
+		}
+		log.Infow("work still running on shutdown, will be recovered on restart", "call", c, "work", wid)
+	}
+	for c, ch := range m.callRes {
+		if len(ch) == 0 {
+			log.Warnw("abandoning call on shutdown", "call", c)
+		}
+	}
+
+	close(m.closing)
+}
+
+// caller must hold m.workLk
+func (m *Manager) isClosing() bool {
+	select {
+	case <-m.closing:
+		return true
+	default:
+		return false
+	}
+}
+
+func (m *Manager) notifyShutdown(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, shutdownNotifyTimeout)
+	defer cancel()
+
+	m.sched.workersLk.RLock()
+	defer m.sched.workersLk.RUnlock()
+
+	var wg sync.WaitGroup
+	for id, w := range m.sched.workers {
+		wg.Add(1)
+		go func(id WorkerID, w *workerHandle) {
+			defer wg.Done()
+
+			if err := w.workerRpc.ManagerShutdown(ctx); err != nil {
+				log.Warnw("notifying worker about shutdown", "worker", id, "error", err)
+			}
+		}(id, w)
+	}
+	wg.Wait()
+}
+
+// waitAwaited waits until no results are awaited, or the timeout passes
+func (m *Manager) waitAwaited(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tick := time.NewTicker(drainPollInterval)
+	defer tick.Stop()
+
+	for {
+		n := m.awaitedResults()
+		if n == 0 {
+			return
+		}
+
+		select {
+		case <-tick.C:
+		case <-ctx.Done():
+			log.Infow("shutdown grace period passed", "awaited", n)
+			return
+		}
+	}
+}
+
+func (m *Manager) awaitedResults() int {
+	m.workLk.Lock()
+	defer m.workLk.Unlock()
+
+	n := len(m.waitRes)
+	for _, ch := range m.callRes {
+		if len(ch) == 0 {
+			n++
+		}
+	}
+	return n
+}
@@ -100,6 +100,8 @@ func newTestMgr(ctx context.Context, t *testing.T, ds datastore.Datastore) (*Man
 
 	stor := stores.NewRemote(lstor, si, nil, 6000)
 
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+
 	m := &Manager{
 		ls:         st,
 		storage:    stor,
@@ -107,8 +109,13 @@ func newTestMgr(ctx context.Context, t *testing.T, ds datastore.Datastore) (*Man
 		remoteHnd:  &stores.FetchHandler{Local: lstor},
 		index:      si,
 
+		fetchTokens: newFetchTokens(),
+
 		sched: newScheduler(),
 
+		unsealed:   newUnsealedLRU(0),
+		unsealJobs: newUnsealJobs(),
+
 		Prover: prover,
 
 		work:       statestore.New(ds),
@@ -116,6 +123,11 @@ func newTestMgr(ctx context.Context, t *testing.T, ds datastore.Datastore) (*Man
 		callRes:    map[storiface.CallID]chan result{},
 		results:    map[WorkID]result{},
 		waitRes:    map[WorkID]chan struct{}{},
+
+		closing: make(chan struct{}),
+
+		bgCtx:    bgCtx,
+		bgCancel: bgCancel,
 	}
 
 	m.setupWorkTracker()
@@ -0,0 +1,105 @@
+package sectorstorage
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// finished unseal jobs are forgotten after this long
+var UnsealJobRetention = time.Hour
+
+type unsealJobs struct {
+	lk   sync.Mutex
+	jobs map[uuid.UUID]*storiface.UnsealJob
+}
+
+func newUnsealJobs() *unsealJobs {
+	return &unsealJobs{
+		jobs: map[uuid.UUID]*storiface.UnsealJob{},
+	}
+}
+
+func (u *unsealJobs) update(id uuid.UUID, cb func(job *storiface.UnsealJob)) {
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	if job, ok := u.jobs[id]; ok {
+		cb(job)
+	}
+}
+
+// caller must hold u.lk
+func (u *unsealJobs) gc(now time.Time) {
+	for id, job := range u.jobs {
+		if !job.Finished.IsZero() && now.Sub(job.Finished) > UnsealJobRetention {
+			delete(u.jobs, id)
+		}
+	}
+}
+
+// StartUnseal starts unsealing the specified range of the sector in the
+// background. The returned job ID can be used to track progress with
+// UnsealStatus. Jobs outlive the request starting them, and are cancelled
+// when the manager is closed.
+func (m *Manager) StartUnseal(sector storage.SectorRef, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, ticket abi.SealRandomness, unsealed cid.Cid) uuid.UUID {
+	now := time.Now()
+	job := &storiface.UnsealJob{
+		ID:     uuid.New(),
+		Sector: sector.ID,
+		Offset: offset,
+		Size:   size,
+		State:  storiface.UnsealQueued,
+		Queued: now,
+	}
+
+	m.unsealJobs.lk.Lock()
+	m.unsealJobs.gc(now)
+	m.unsealJobs.jobs[job.ID] = job
+	m.unsealJobs.lk.Unlock()
+
+	go func() {
+		err := m.UnsealPiece(m.bgCtx, sector, offset, size, ticket, unsealed, func() {
+			m.unsealJobs.update(job.ID, func(job *storiface.UnsealJob) {
+				job.State = storiface.UnsealUnsealing
+				job.Started = time.Now()
+			})
+		})
+		if err != nil {
+			log.Errorf("unsealing sector %d (offset %d, size %d): %+v", sector.ID, offset, size, err)
+		}
+
+		m.unsealJobs.update(job.ID, func(job *storiface.UnsealJob) {
+			job.Finished = time.Now()
+			if err != nil {
+				job.State = storiface.UnsealFailed
+				job.Error = err.Error()
+				return
+			}
+			job.State = storiface.UnsealDone
+		})
+	}()
+
+	return job.ID
+}
+
+func (m *Manager) UnsealStatus(ctx context.Context, id uuid.UUID) (storiface.UnsealJob, error) {
+	m.unsealJobs.lk.Lock()
+	defer m.unsealJobs.lk.Unlock()
+
+	job, ok := m.unsealJobs.jobs[id]
+	if !ok {
+		return storiface.UnsealJob{}, xerrors.Errorf("unseal job %s not found", id)
+	}
+
+	return *job, nil
+}
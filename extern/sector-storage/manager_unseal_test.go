@@ -0,0 +1,35 @@
+package sectorstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestUnsealJobsGC(t *testing.T) {
+	u := newUnsealJobs()
+	now := time.Now()
+
+	running := &storiface.UnsealJob{ID: uuid.New(), State: storiface.UnsealUnsealing, Queued: now.Add(-2 * UnsealJobRetention)}
+	recent := &storiface.UnsealJob{ID: uuid.New(), State: storiface.UnsealDone, Finished: now.Add(-UnsealJobRetention / 2)}
+	old := &storiface.UnsealJob{ID: uuid.New(), State: storiface.UnsealFailed, Finished: now.Add(-2 * UnsealJobRetention)}
+
+	for _, j := range []*storiface.UnsealJob{running, recent, old} {
+		u.jobs[j.ID] = j
+	}
+
+	u.gc(now)
+
+	require.Contains(t, u.jobs, running.ID)
+	require.Contains(t, u.jobs, recent.ID)
+	require.NotContains(t, u.jobs, old.ID)
+
+	u.update(running.ID, func(job *storiface.UnsealJob) {
+		job.State = storiface.UnsealDone
+	})
+	require.Equal(t, storiface.UnsealDone, u.jobs[running.ID].State)
+}
@@ -0,0 +1,187 @@
+package sectorstorage
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// pc2Verification is a PreCommit2 verification run, started on a copy of the
+// PreCommit1 output and running alongside the actual PreCommit2
+type pc2Verification struct {
+	copied chan struct{} // closed once the copy was made, or the run failed
+	done   chan struct{}
+
+	// set before copied is closed
+	worker   WorkerID
+	copiedOk bool
+
+	// set before done is closed
+	out storage.SectorCids
+	err error
+}
+
+// check compares the commitments computed by the verifying worker with the
+// ones computed by the sealing worker.
+func (v *pc2Verification) check(sector storage.SectorRef, worker WorkerID, out storage.SectorCids) error {
+	if out.Sealed.Equals(v.out.Sealed) && out.Unsealed.Equals(v.out.Unsealed) {
+		return nil
+	}
+
+	log.Errorw("PreCommit2 results of independent workers differ, check the workers for faulty hardware",
+		"sector", sector.ID,
+		"worker", worker, "sealed", out.Sealed, "unsealed", out.Unsealed,
+		"verifier", v.worker, "verifierSealed", v.out.Sealed, "verifierUnsealed", v.out.Unsealed)
+
+	return xerrors.Errorf("worker %s computed CommR %s CommD %s, verifier %s computed CommR %s CommD %s: %w",
+		worker, out.Sealed, out.Unsealed, v.worker, v.out.Sealed, v.out.Unsealed, storiface.ErrPreCommit2Mismatch)
+}
+
+// waitCopied waits until the verifying worker has its copy of the sector
+// files, and returns it, so the actual run can go ahead on another worker
+func (v *pc2Verification) waitCopied(ctx context.Context) (WorkerID, error) {
+	select {
+	case <-v.copied:
+	case <-ctx.Done():
+		return WorkerID{}, ctx.Err()
+	}
+
+	if v.copiedOk {
+		return v.worker, nil
+	}
+	<-v.done
+	return WorkerID{}, v.err
+}
+
+// wait waits for the result of the verification run
+func (v *pc2Verification) wait(ctx context.Context) error {
+	select {
+	case <-v.done:
+		return v.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// verifyPreCommit2 starts PreCommit2 on a worker which doesn't have access to
+// the sector files yet, on a copy of them, and removes the copy afterwards.
+// The sector files are locked until the copy is made, after which the actual
+// PreCommit2 can update them; see pc2Verification.waitCopied. The run is
+// stopped when ctx is cancelled.
+func (m *Manager) verifyPreCommit2(ctx context.Context, sector storage.SectorRef, phase1Out storage.PreCommit1Out) *pc2Verification {
+	v := &pc2Verification{
+		copied: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		var copiedOnce sync.Once
+		copied := func(ok bool) {
+			copiedOnce.Do(func() {
+				v.copiedOk = ok
+				close(v.copied)
+			})
+		}
+
+		v.err = m.runVerifyPreCommit2(ctx, sector, phase1Out, v, copied)
+		copied(false)
+		close(v.done)
+	}()
+
+	return v
+}
+
+func (m *Manager) runVerifyPreCommit2(ctx context.Context, sector storage.SectorRef, phase1Out storage.PreCommit1Out, v *pc2Verification, copied func(ok bool)) error {
+	before, err := m.sectorStorages(ctx, sector.ID)
+	if err != nil {
+		return err
+	}
+	// the copy is removed even when the run was cancelled
+	defer m.removeNewCopies(context.Background(), sector.ID, before)
+
+	// released when the copy was made, or when the run ends
+	lctx, unlock := context.WithCancel(ctx)
+	defer unlock()
+
+	if err := m.index.StorageLock(lctx, sector.ID, storiface.FTSealed|storiface.FTCache, storiface.FTNone); err != nil {
+		return xerrors.Errorf("acquiring sector lock: %w", err)
+	}
+
+	selector := newCopySelector(m.index, sector.ID, storiface.FTCache|storiface.FTSealed)
+	fetch := m.schedFetch(sector, storiface.FTCache|storiface.FTSealed, storiface.PathSealing, storiface.AcquireCopy)
+
+	prepare := func(ctx context.Context, w Worker) error {
+		sessID, err := w.Session(ctx)
+		if err != nil {
+			return xerrors.Errorf("getting worker session: %w", err)
+		}
+		if err := fetch(ctx, w); err != nil {
+			return err
+		}
+
+		v.worker = WorkerID(sessID)
+		unlock()
+		copied(true)
+		return nil
+	}
+
+	return m.sched.Schedule(ctx, sector, sealtasks.TTPreCommit2, selector, prepare, func(ctx context.Context, w Worker) error {
+		r, err := m.waitSimpleCall(ctx)(w.SealPreCommit2(ctx, sector, phase1Out))
+		if err != nil {
+			return err
+		}
+		cids, ok := r.(storage.SectorCids)
+		if !ok {
+			return xerrors.Errorf("unexpected SealPreCommit2 result type %T", r)
+		}
+		v.out = cids
+		return nil
+	})
+}
+
+// sectorStorages returns the storage paths holding the sealed and cache files
+// of the sector
+func (m *Manager) sectorStorages(ctx context.Context, sid abi.SectorID) (map[storiface.SectorFileType]map[stores.ID]struct{}, error) {
+	out := map[storiface.SectorFileType]map[stores.ID]struct{}{}
+	for _, ft := range []storiface.SectorFileType{storiface.FTSealed, storiface.FTCache} {
+		si, err := m.index.StorageFindSector(ctx, sid, ft, 0, false)
+		if err != nil {
+			return nil, xerrors.Errorf("finding existing sector %d(t:%d): %w", sid, ft, err)
+		}
+
+		out[ft] = map[stores.ID]struct{}{}
+		for _, info := range si {
+			out[ft][info.ID] = struct{}{}
+		}
+	}
+	return out, nil
+}
+
+// removeNewCopies removes the sealed and cache files of the sector from the
+// storage paths which didn't hold them before.
+func (m *Manager) removeNewCopies(ctx context.Context, sid abi.SectorID, before map[storiface.SectorFileType]map[stores.ID]struct{}) {
+	after, err := m.sectorStorages(ctx, sid)
+	if err != nil {
+		log.Errorw("finding verification copies", "sector", sid, "error", err)
+		return
+	}
+
+	for ft, ids := range after {
+		for id := range ids {
+			if _, existed := before[ft][id]; existed {
+				continue
+			}
+			if err := m.storage.RemoveFrom(ctx, sid, ft, id); err != nil {
+				log.Errorw("removing verification copy", "sector", sid, "type", ft, "storage", id, "error", err)
+			}
+		}
+	}
+}
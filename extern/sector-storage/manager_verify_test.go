@@ -0,0 +1,29 @@
+package sectorstorage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-commp-utils/zerocomm"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestPC2VerificationCheck(t *testing.T) {
+	a := zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(2048).Unpadded())
+	b := zerocomm.ZeroPieceCommitment(abi.PaddedPieceSize(4096).Unpadded())
+
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 1}}
+	v := &pc2Verification{
+		worker: WorkerID{1},
+		out:    storage.SectorCids{Sealed: a, Unsealed: b},
+	}
+
+	require.NoError(t, v.check(sector, WorkerID{2}, storage.SectorCids{Sealed: a, Unsealed: b}))
+	require.True(t, xerrors.Is(v.check(sector, WorkerID{2}, storage.SectorCids{Sealed: b, Unsealed: b}), storiface.ErrPreCommit2Mismatch))
+	require.Error(t, v.check(sector, WorkerID{2}, storage.SectorCids{Sealed: a, Unsealed: a}))
+}
@@ -0,0 +1,172 @@
+package sectorstorage
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// where the kernel exposes the NUMA topology
+const numaSysfsPath = "/sys/devices/system/node"
+
+// numaNodes reads the NUMA topology of the machine. Machines with a single
+// node, or which don't expose the topology, return no nodes, as there is
+// nothing to pin calls to.
+func numaNodes(root string) ([]storiface.NUMANode, error) {
+	ents, err := ioutil.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("listing numa nodes: %w", err)
+	}
+
+	var out []storiface.NUMANode
+	for _, ent := range ents {
+		if !ent.IsDir() || !strings.HasPrefix(ent.Name(), "node") {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(ent.Name(), "node"))
+		if err != nil {
+			continue
+		}
+
+		cpus, err := readCPUList(filepath.Join(root, ent.Name(), "cpulist"))
+		if err != nil {
+			return nil, xerrors.Errorf("reading cpus of numa node %d: %w", id, err)
+		}
+		if len(cpus) == 0 {
+			continue // memory-only node
+		}
+
+		mem, err := readNodeMemTotal(filepath.Join(root, ent.Name(), "meminfo"))
+		if err != nil {
+			return nil, xerrors.Errorf("reading memory of numa node %d: %w", id, err)
+		}
+
+		out = append(out, storiface.NUMANode{
+			ID:          id,
+			CPUs:        uint64(len(cpus)),
+			MemPhysical: mem,
+		})
+	}
+
+	if len(out) < 2 {
+		return nil, nil
+	}
+
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].ID < out[j].ID
+	})
+
+	return out, nil
+}
+
+func readCPUList(path string) ([]int, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return parseCPUList(string(b))
+}
+
+// parseCPUList parses the kernel cpulist format, e.g. "0-15,32-47"
+func parseCPUList(s string) ([]int, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	var out []int
+	for _, r := range strings.Split(s, ",") {
+		bounds := strings.SplitN(r, "-", 2)
+
+		from, err := strconv.Atoi(bounds[0])
+		if err != nil {
+			return nil, xerrors.Errorf("parsing cpu range %q: %w", r, err)
+		}
+		to := from
+		if len(bounds) == 2 {
+			to, err = strconv.Atoi(bounds[1])
+			if err != nil {
+				return nil, xerrors.Errorf("parsing cpu range %q: %w", r, err)
+			}
+		}
+		if to < from {
+			return nil, xerrors.Errorf("invalid cpu range %q", r)
+		}
+
+		for c := from; c <= to; c++ {
+			out = append(out, c)
+		}
+	}
+
+	return out, nil
+}
+
+// readNodeMemTotal reads MemTotal from a per-node meminfo file, which has
+// lines like "Node 0 MemTotal:       65842836 kB"
+func readNodeMemTotal(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close() // nolint
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 4 || fields[2] != "MemTotal:" {
+			continue
+		}
+
+		kb, err := strconv.ParseUint(fields[3], 10, 64)
+		if err != nil {
+			return 0, xerrors.Errorf("parsing MemTotal: %w", err)
+		}
+		return kb << 10, nil
+	}
+	if err := sc.Err(); err != nil {
+		return 0, err
+	}
+
+	return 0, xerrors.Errorf("MemTotal not found")
+}
+
+// onNUMANode runs the function on an OS thread pinned to the CPUs and memory
+// of the NUMA node; threads started from it, like the ones the proofs library
+// starts to do the work, inherit the pinning. If pinning fails, the function
+// still runs, unpinned.
+func onNUMANode(node int, f func() (interface{}, error)) (interface{}, error) {
+	type result struct {
+		out interface{}
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		// The thread isn't unlocked, so that it exits with this goroutine
+		// instead of taking the pinning to other goroutines.
+		runtime.LockOSThread()
+
+		if err := pinToNUMANode(node); err != nil {
+			log.Warnw("pinning to numa node failed, running unpinned", "node", node, "error", err)
+		}
+
+		out, err := f()
+		done <- result{out: out, err: err}
+	}()
+
+	r := <-done
+	return r.out, r.err
+}
@@ -0,0 +1,43 @@
+package sectorstorage
+
+import (
+	"fmt"
+	"path/filepath"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/xerrors"
+)
+
+const mpolPreferred = 1 // linux/mempolicy.h
+
+// pinToNUMANode sets the CPU affinity of the calling thread to the CPUs of
+// the node, and makes it prefer allocating memory on the node. The caller
+// must have locked the goroutine to its thread.
+func pinToNUMANode(node int) error {
+	if node < 0 || node >= 64 {
+		return xerrors.Errorf("numa node %d out of range", node)
+	}
+
+	cpus, err := readCPUList(filepath.Join(numaSysfsPath, fmt.Sprintf("node%d", node), "cpulist"))
+	if err != nil {
+		return xerrors.Errorf("reading node cpus: %w", err)
+	}
+
+	var set unix.CPUSet
+	for _, c := range cpus {
+		set.Set(c)
+	}
+	if err := unix.SchedSetaffinity(0, &set); err != nil {
+		return xerrors.Errorf("setting cpu affinity: %w", err)
+	}
+
+	// preferred, not bound, so that allocations fall back to other nodes
+	// instead of failing when the node runs out of memory
+	mask := uint64(1) << uint(node)
+	if _, _, errno := unix.Syscall(unix.SYS_SET_MEMPOLICY, mpolPreferred, uintptr(unsafe.Pointer(&mask)), 64+1); errno != 0 {
+		return xerrors.Errorf("setting memory policy: %w", errno)
+	}
+
+	return nil
+}
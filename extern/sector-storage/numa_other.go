@@ -0,0 +1,9 @@
+// +build !linux
+
+package sectorstorage
+
+import "golang.org/x/xerrors"
+
+func pinToNUMANode(node int) error {
+	return xerrors.Errorf("numa pinning is only supported on linux")
+}
@@ -0,0 +1,99 @@
+package sectorstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestParseCPUList(t *testing.T) {
+	cpus, err := parseCPUList("0-3,8,10-11\n")
+	require.NoError(t, err)
+	require.Equal(t, []int{0, 1, 2, 3, 8, 10, 11}, cpus)
+
+	cpus, err = parseCPUList("\n")
+	require.NoError(t, err)
+	require.Empty(t, cpus)
+
+	_, err = parseCPUList("3-1")
+	require.Error(t, err)
+}
+
+func TestNUMANodes(t *testing.T) {
+	root, err := ioutil.TempDir("", "numa")
+	require.NoError(t, err)
+	defer os.RemoveAll(root) // nolint
+
+	node := func(name, cpus, mem string) {
+		dir := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(dir, 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "cpulist"), []byte(cpus), 0644))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "meminfo"), []byte(mem), 0644))
+	}
+
+	node("node0", "0-3\n", "Node 0 MemTotal:       1024 kB\nNode 0 MemFree:        512 kB\n")
+
+	nodes, err := numaNodes(root)
+	require.NoError(t, err)
+	require.Empty(t, nodes, "single node machines have nothing to pin to")
+
+	node("node1", "4-7\n", "Node 1 MemTotal:       2048 kB\n")
+	node("node2", "\n", "Node 2 MemTotal:       4096 kB\n") // memory-only
+
+	nodes, err = numaNodes(root)
+	require.NoError(t, err)
+	require.Equal(t, []storiface.NUMANode{
+		{ID: 0, CPUs: 4, MemPhysical: 1024 << 10},
+		{ID: 1, CPUs: 4, MemPhysical: 2048 << 10},
+	}, nodes)
+
+	nodes, err = numaNodes(filepath.Join(root, "missing"))
+	require.NoError(t, err)
+	require.Empty(t, nodes)
+}
+
+func TestPickNUMANode(t *testing.T) {
+	wt := newWorkTracker()
+	wid := WorkerID(uuid.New())
+	nodes := []storiface.NUMANode{{ID: 0}, {ID: 1}}
+
+	start := func() storiface.CallID {
+		n, release := wt.pickNUMANode(wid, nodes)
+		defer release()
+
+		sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: abi.SectorNumber(len(wt.calls))}}
+		c := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+		_, err := wt.trackOn(wid, nil, sector, sealtasks.TTPreCommit1, n)(c, nil)
+		require.NoError(t, err)
+		return c
+	}
+
+	c1 := start()
+	c2 := start()
+	require.Equal(t, 0, *wt.calls[c1].job.NUMANode)
+	require.Equal(t, 1, *wt.calls[c2].job.NUMANode)
+
+	// calls picked but not tracked yet count as running on the node
+	n, release := wt.pickNUMANode(wid, nodes)
+	require.Equal(t, 0, n)
+	n2, release2 := wt.pickNUMANode(wid, nodes)
+	require.Equal(t, 1, n2)
+	release()
+	release2()
+	require.Empty(t, wt.numaPending)
+
+	// returned calls don't count
+	wt.onDone(c1, nil)
+	c3 := start()
+	require.Equal(t, 0, *wt.calls[c3].job.NUMANode)
+}
@@ -0,0 +1,44 @@
+package sectorstorage
+
+import (
+	"context"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+type pieceMetaKey struct{}
+
+// WithPieceMeta attaches provenance of the pieces passed to a sealing call to
+// the context, one entry per piece. The manager passes it on to the worker
+// running the call.
+func WithPieceMeta(ctx context.Context, meta []storiface.PieceMeta) context.Context {
+	return context.WithValue(ctx, pieceMetaKey{}, meta)
+}
+
+// pieceMeta returns the piece provenance attached to the context, or nil if
+// there is none, or it doesn't match the pieces
+func pieceMeta(ctx context.Context, pieces []abi.PieceInfo) []storiface.PieceMeta {
+	meta, _ := ctx.Value(pieceMetaKey{}).([]storiface.PieceMeta)
+	if meta != nil && len(meta) != len(pieces) {
+		log.Warnw("piece metadata doesn't match the pieces, not passing it to the worker", "meta", len(meta), "pieces", len(pieces))
+		return nil
+	}
+	return meta
+}
+
+// logPieceMeta logs the deals sealed by a call, for auditing
+func logPieceMeta(ci storiface.CallID, pieces []abi.PieceInfo, meta []storiface.PieceMeta) {
+	for i, pm := range meta {
+		if i >= len(pieces) {
+			break
+		}
+		if pm.DealID == 0 && pm.Client == address.Undef {
+			continue // filler piece
+		}
+
+		log.Infow("sealing deal piece", "call", ci, "piece", pieces[i].PieceCID, "size", pieces[i].Size, "deal", pm.DealID, "client", pm.Client)
+	}
+}
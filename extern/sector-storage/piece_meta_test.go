@@ -0,0 +1,29 @@
+package sectorstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestPieceMeta(t *testing.T) {
+	client, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	pieces := []abi.PieceInfo{{Size: 1024}, {Size: 1024}}
+	meta := []storiface.PieceMeta{{DealID: 5, Client: client}, {}}
+
+	require.Nil(t, pieceMeta(context.Background(), pieces))
+
+	ctx := WithPieceMeta(context.Background(), meta)
+	require.Equal(t, meta, pieceMeta(ctx, pieces))
+
+	// metadata not matching the pieces isn't passed on
+	require.Nil(t, pieceMeta(ctx, pieces[:1]))
+}
@@ -20,6 +20,10 @@ func (q requestQueue) Less(i, j int) bool {
 		return q[i].taskType.Less(q[j].taskType)
 	}
 
+	if q[i].retries != q[j].retries {
+		return q[i].retries < q[j].retries // retries go a round back, see retryTracker
+	}
+
 	return q[i].sector.ID.Number < q[j].sector.ID.Number // optimize minerActor.NewSectors bitfield
 }
 
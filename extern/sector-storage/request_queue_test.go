@@ -4,6 +4,12 @@ import (
 	"fmt"
 	"testing"
 
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
 	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 )
 
@@ -60,3 +66,42 @@ func TestRequestQueue(t *testing.T) {
 		t.Error("expected precommit1, got", pt.taskType)
 	}
 }
+
+func TestRequestQueueRetriesFairShare(t *testing.T) {
+	rq := &requestQueue{}
+
+	sector := func(n abi.SectorNumber) storage.SectorRef {
+		return storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: n}}
+	}
+
+	// sector 1 keeps failing; without retry counts its low number would put
+	// it at the front every time
+	rq.Push(&workerRequest{taskType: sealtasks.TTPreCommit1, sector: sector(1), retries: 3})
+	rq.Push(&workerRequest{taskType: sealtasks.TTPreCommit1, sector: sector(3)})
+	rq.Push(&workerRequest{taskType: sealtasks.TTPreCommit1, sector: sector(2), retries: 1})
+	rq.Push(&workerRequest{taskType: sealtasks.TTPreCommit2, sector: sector(4), retries: 5})
+
+	expect := []abi.SectorNumber{4, 3, 2, 1}
+	for i, n := range expect {
+		if got := rq.Remove(0).sector.ID.Number; got != n {
+			t.Fatalf("pop %d: expected sector %d, got %d", i, n, got)
+		}
+	}
+}
+
+func TestRetryTracker(t *testing.T) {
+	rt := newRetryTracker()
+	s1 := abi.SectorID{Miner: 1000, Number: 1}
+
+	require.Equal(t, 0, rt.failed(s1, sealtasks.TTPreCommit1))
+
+	rt.done(s1, sealtasks.TTPreCommit1, xerrors.New("fail"))
+	rt.done(s1, sealtasks.TTPreCommit1, xerrors.New("fail"))
+	rt.done(s1, sealtasks.TTPreCommit2, xerrors.New("fail"))
+	require.Equal(t, 2, rt.failed(s1, sealtasks.TTPreCommit1))
+	require.Equal(t, 1, rt.failed(s1, sealtasks.TTPreCommit2))
+
+	rt.done(s1, sealtasks.TTPreCommit1, nil)
+	require.Equal(t, 0, rt.failed(s1, sealtasks.TTPreCommit1))
+	require.Equal(t, 0, rt.failed(s1, sealtasks.TTPreCommit2))
+}
@@ -66,6 +66,17 @@ type scheduler struct {
 
 	workTracker *workTracker
 
+	urgent urgentTracker
+
+	throttle *schedThrottle
+	retries  *retryTracker
+
+	history *sealingHistory
+	records *sealRecords
+
+	// recall not yet started tasks from busy workers when others are idle
+	steal bool
+
 	info chan func(interface{})
 
 	closing  chan struct{}
@@ -124,6 +135,7 @@ type workerRequest struct {
 	sector   storage.SectorRef
 	taskType sealtasks.TaskType
 	priority int // larger values more important
+	retries  int // failed attempts of the task on this sector so far
 	sel      WorkerSelector
 
 	prepare WorkerAction
@@ -131,6 +143,10 @@ type workerRequest struct {
 
 	start time.Time
 
+	stateLk sync.Mutex
+	states  []storiface.JobStateTime
+	history *sealingHistory
+
 	index int // The index of the item in the heap.
 
 	indexHeap int
@@ -143,6 +159,11 @@ type workerResponse struct {
 }
 
 func newScheduler() *scheduler {
+	history := newSealingHistory()
+
+	wt := newWorkTracker()
+	wt.history = history
+
 	return &scheduler{
 		workers: map[WorkerID]*workerHandle{},
 
@@ -153,10 +174,12 @@ func newScheduler() *scheduler {
 
 		schedQueue: &requestQueue{},
 
-		workTracker: &workTracker{
-			done:    map[storiface.CallID]struct{}{},
-			running: map[storiface.CallID]trackedWork{},
-		},
+		workTracker: wt,
+
+		throttle: newSchedThrottle(),
+		retries:  newRetryTracker(),
+		history:  history,
+		records:  newSealRecords(),
 
 		info: make(chan func(interface{})),
 
@@ -167,18 +190,22 @@ func newScheduler() *scheduler {
 
 func (sh *scheduler) Schedule(ctx context.Context, sector storage.SectorRef, taskType sealtasks.TaskType, sel WorkerSelector, prepare WorkerAction, work WorkerAction) error {
 	ret := make(chan workerResponse)
+	now := time.Now()
 
 	select {
 	case sh.schedule <- &workerRequest{
 		sector:   sector,
 		taskType: taskType,
 		priority: getPriority(ctx),
+		retries:  sh.retries.failed(sector.ID, taskType),
 		sel:      sel,
 
 		prepare: prepare,
 		work:    work,
 
-		start: time.Now(),
+		start:   now,
+		states:  []storiface.JobStateTime{{State: storiface.JobQueued, Time: now}},
+		history: sh.history,
 
 		ret: ret,
 		ctx: ctx,
@@ -191,6 +218,7 @@ func (sh *scheduler) Schedule(ctx context.Context, sector storage.SectorRef, tas
 
 	select {
 	case resp := <-ret:
+		sh.retries.done(sector.ID, taskType, resp.err)
 		return resp.err
 	case <-sh.closing:
 		return xerrors.New("closing")
@@ -231,6 +259,8 @@ func (sh *scheduler) runSched() {
 		select {
 		case <-sh.workerChange:
 			doSched = true
+		case <-sh.throttle.wake:
+			doSched = true
 		case dreq := <-sh.workerDisable:
 			toDisable = append(toDisable, dreq)
 			doSched = true
@@ -263,6 +293,7 @@ func (sh *scheduler) runSched() {
 			for {
 				select {
 				case <-sh.workerChange:
+				case <-sh.throttle.wake:
 				case dreq := <-sh.workerDisable:
 					toDisable = append(toDisable, dreq)
 				case req := <-sh.schedule:
@@ -280,6 +311,7 @@ func (sh *scheduler) runSched() {
 			for _, req := range toDisable {
 				for _, window := range req.activeWindows {
 					for _, request := range window.todo {
+						sh.throttle.done(request)
 						sh.schedQueue.Push(request)
 					}
 				}
@@ -299,6 +331,10 @@ func (sh *scheduler) runSched() {
 				req.done()
 			}
 
+			if sh.steal {
+				sh.stealTasks()
+			}
+
 			sh.trySched()
 		}
 
@@ -420,6 +456,7 @@ func (sh *scheduler) trySched() {
 			rand.Shuffle(len(acceptableWindows[sqi]), func(i, j int) {
 				acceptableWindows[sqi][i], acceptableWindows[sqi][j] = acceptableWindows[sqi][j], acceptableWindows[sqi][i] // nolint:scopelint
 			})
+			srvOrder := dispatchOrder{}
 			sort.SliceStable(acceptableWindows[sqi], func(i, j int) bool {
 				wii := sh.openWindows[acceptableWindows[sqi][i]].worker // nolint:scopelint
 				wji := sh.openWindows[acceptableWindows[sqi][j]].worker // nolint:scopelint
@@ -432,6 +469,11 @@ func (sh *scheduler) trySched() {
 				wi := sh.workers[wii]
 				wj := sh.workers[wji]
 
+				// discovered appliances are picked by their SRV records
+				if r, ok := srvOrder.less(wii, wji, wi, wj); ok {
+					return r
+				}
+
 				rpcCtx, cancel := context.WithTimeout(task.ctx, SelectorTimeout)
 				defer cancel()
 
@@ -452,11 +494,16 @@ func (sh *scheduler) trySched() {
 	// Step 2
 	scheduled := 0
 	rmQueue := make([]int, 0, queuneLen)
+	now := time.Now()
 
 	for sqi := 0; sqi < queuneLen; sqi++ {
 		task := (*sh.schedQueue)[sqi]
 		needRes := ResourceTable[task.taskType][task.sector.ProofType]
 
+		if !sh.throttle.allow(task.taskType, now) {
+			continue
+		}
+
 		selectedWindow := -1
 		for _, wnd := range acceptableWindows[task.indexHeap] {
 			wid := sh.openWindows[wnd].worker
@@ -487,6 +534,7 @@ func (sh *scheduler) trySched() {
 		}
 
 		windows[selectedWindow].todo = append(windows[selectedWindow].todo, task)
+		sh.throttle.start(task, now)
 
 		rmQueue = append(rmQueue, sqi)
 		scheduled++
@@ -0,0 +1,58 @@
+package sectorstorage
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+// workState is the state of a job while its work step runs. Fetch jobs only
+// move sector data around, their work step moves it to long-term storage.
+func workState(task sealtasks.TaskType) storiface.JobState {
+	if task == sealtasks.TTFetch {
+		return storiface.JobTransferringOut
+	}
+	return storiface.JobComputing
+}
+
+// setState moves the job to a new state, recording how long it spent in the
+// previous one
+func (r *workerRequest) setState(st storiface.JobState) {
+	now := time.Now()
+
+	r.stateLk.Lock()
+	prev := storiface.JobStateTime{State: storiface.JobQueued, Time: r.start}
+	if len(r.states) > 0 {
+		prev = r.states[len(r.states)-1]
+	}
+	r.states = append(r.states, storiface.JobStateTime{State: st, Time: now})
+	r.stateLk.Unlock()
+
+	ctx, _ := tag.New(context.Background(),
+		tag.Upsert(metrics.TaskType, string(r.taskType)),
+		tag.Upsert(metrics.JobState, string(prev.State)))
+	stats.Record(ctx, metrics.SchedJobStateDuration.M(now.Sub(prev.Time).Seconds()))
+
+	r.history.phase(r.taskType, prev.State, now.Sub(prev.Time))
+}
+
+// jobState returns the current state of the job, and when it entered each
+func (r *workerRequest) jobState() (storiface.JobState, []storiface.JobStateTime) {
+	r.stateLk.Lock()
+	defer r.stateLk.Unlock()
+
+	if len(r.states) == 0 {
+		return storiface.JobQueued, nil
+	}
+
+	history := make([]storiface.JobStateTime, len(r.states))
+	copy(history, r.states)
+
+	return history[len(history)-1].State, history
+}
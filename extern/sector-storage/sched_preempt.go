@@ -0,0 +1,124 @@
+package sectorstorage
+
+import (
+	"context"
+	"sync"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	proof2 "github.com/filecoin-project/specs-actors/v2/actors/runtime/proof"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// PreemptibleTasks are held back on the miner-local worker while urgent proving
+// work (WindowPoSt / WinningPoSt) is computed in the miner process, so that
+// they don't compete with it for resources. Tasks which were already started
+// have their sector transfers suspended between reads instead; steps which
+// aren't transfers, like clearing the cache or moving files between local
+// paths, run to the end. Everything resumes once proving is done.
+var PreemptibleTasks = map[sealtasks.TaskType]struct{}{
+	sealtasks.TTFetch:    {},
+	sealtasks.TTFinalize: {},
+}
+
+type urgentTracker struct {
+	lk sync.Mutex
+
+	running int
+	resume  chan struct{} // closed when running drops to 0
+}
+
+// begin marks the start of urgent work, the returned func marks its end
+func (u *urgentTracker) begin() func() {
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	if u.running == 0 {
+		u.resume = make(chan struct{})
+	}
+	u.running++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			u.lk.Lock()
+			defer u.lk.Unlock()
+
+			u.running--
+			if u.running == 0 {
+				close(u.resume)
+				u.resume = nil
+			}
+		})
+	}
+}
+
+// preempting returns a channel which is closed when currently running urgent
+// work is done, or nil if there is no urgent work running
+func (u *urgentTracker) preempting() <-chan struct{} {
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	return u.resume
+}
+
+func (w *workerHandle) preemptible(task sealtasks.TaskType) bool {
+	if _, local := w.workerRpc.(*LocalWorker); !local {
+		return false
+	}
+
+	_, ok := PreemptibleTasks[task]
+	return ok
+}
+
+// throttleProvingPaths slows down transfers on the storage paths holding the
+// proven sectors, until the returned func is called
+func (m *Manager) throttleProvingPaths(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo) func() {
+	throttle := m.localStore.ProvingThrottle()
+	if throttle == nil {
+		return func() {}
+	}
+
+	seen := map[stores.ID]struct{}{}
+	var ids []stores.ID
+	for _, s := range sectorInfo {
+		sid := abi.SectorID{Miner: minerID, Number: s.SectorNumber}
+		for _, ft := range []storiface.SectorFileType{storiface.FTSealed, storiface.FTCache} {
+			si, err := m.index.StorageFindSector(ctx, sid, ft, 0, false)
+			if err != nil {
+				log.Warnw("finding proven sector storage", "sector", sid, "type", ft, "error", err)
+				continue
+			}
+			for _, info := range si {
+				if _, ok := seen[info.ID]; !ok {
+					seen[info.ID] = struct{}{}
+					ids = append(ids, info.ID)
+				}
+			}
+		}
+	}
+
+	return throttle.Begin(ids)
+}
+
+func (m *Manager) GenerateWinningPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, error) {
+	defer m.sched.urgent.begin()()
+	defer m.throttleProvingPaths(ctx, minerID, sectorInfo)()
+
+	if proofs, ok := m.dispatchWinningPoSt(ctx, minerID, sectorInfo, randomness); ok {
+		return proofs, nil
+	}
+	return m.Prover.GenerateWinningPoSt(ctx, minerID, sectorInfo, randomness)
+}
+
+func (m *Manager) GenerateWindowPoSt(ctx context.Context, minerID abi.ActorID, sectorInfo []proof2.SectorInfo, randomness abi.PoStRandomness) ([]proof2.PoStProof, []abi.SectorID, error) {
+	defer m.sched.urgent.begin()()
+	defer m.throttleProvingPaths(ctx, minerID, sectorInfo)()
+
+	if proofs, skipped, ok := m.dispatchWindowPoSt(ctx, minerID, sectorInfo, randomness); ok {
+		return proofs, skipped, nil
+	}
+	return m.Prover.GenerateWindowPoSt(ctx, minerID, sectorInfo, randomness)
+}
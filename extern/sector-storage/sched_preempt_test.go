@@ -0,0 +1,35 @@
+package sectorstorage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUrgentTracker(t *testing.T) {
+	var u urgentTracker
+	require.Nil(t, u.preempting())
+
+	end1 := u.begin()
+	resume := u.preempting()
+	require.NotNil(t, resume)
+
+	end2 := u.begin()
+	require.Equal(t, resume, u.preempting())
+
+	end1()
+	end1() // calling end twice must not end other urgent work
+	select {
+	case <-resume:
+		t.Fatal("resumed with urgent work still running")
+	default:
+	}
+
+	end2()
+	select {
+	case <-resume:
+	default:
+		t.Fatal("expected resume after all urgent work is done")
+	}
+	require.Nil(t, u.preempting())
+}
@@ -3,6 +3,7 @@ package sectorstorage
 import (
 	"sync"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
@@ -107,3 +108,18 @@ func (wh *workerHandle) utilization() float64 {
 
 	return u
 }
+
+// lessUtilized returns whether worker a is less utilized than b. When both
+// workers ran the preflight benchmark for the task, utilization is weighted by
+// their scores, so faster workers get proportionally more work.
+func lessUtilized(task sealtasks.TaskType, a, b *workerHandle) bool {
+	ua, ub := a.utilization(), b.utilization()
+
+	sa, sb := a.info.Benchmark[task], b.info.Benchmark[task]
+	if sa > 0 && sb > 0 {
+		// +1 so that idle workers are still ordered by score
+		return (ua+1)/sa < (ub+1)/sb
+	}
+
+	return ua < ub
+}
@@ -0,0 +1,49 @@
+package sectorstorage
+
+import (
+	"sync"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+)
+
+// retryTracker counts the failed attempts of each sector's tasks. The queue
+// orders requests of a phase by failed attempts, so every retry of a sector
+// sends it a round back behind fresh work and other sectors' earlier retries,
+// and a flapping sector can't monopolize workers by failing repeatedly.
+type retryTracker struct {
+	lk       sync.Mutex
+	attempts map[abi.SectorID]map[sealtasks.TaskType]int
+}
+
+func newRetryTracker() *retryTracker {
+	return &retryTracker{
+		attempts: map[abi.SectorID]map[sealtasks.TaskType]int{},
+	}
+}
+
+// failed returns the number of failed attempts of the sector's task so far
+func (rt *retryTracker) failed(sector abi.SectorID, tt sealtasks.TaskType) int {
+	rt.lk.Lock()
+	defer rt.lk.Unlock()
+
+	return rt.attempts[sector][tt]
+}
+
+// done records the outcome of an attempt. A success forgets all failures of
+// the sector, as it has moved on.
+func (rt *retryTracker) done(sector abi.SectorID, tt sealtasks.TaskType, err error) {
+	rt.lk.Lock()
+	defer rt.lk.Unlock()
+
+	if err == nil {
+		delete(rt.attempts, sector)
+		return
+	}
+
+	if rt.attempts[sector] == nil {
+		rt.attempts[sector] = map[sealtasks.TaskType]int{}
+	}
+	rt.attempts[sector][tt]++
+}
@@ -0,0 +1,151 @@
+package sectorstorage
+
+import (
+	"context"
+)
+
+// stealCandidate is a task waiting in a window of a busy worker
+type stealCandidate struct {
+	wid    WorkerID
+	worker *workerHandle
+	req    *workerRequest
+}
+
+// stealTasks moves tasks waiting in windows of workers without open windows
+// back to the scheduling queue, when an idle worker (one with open windows)
+// could run them. Tasks are started by workers only while holding wndLk, and
+// only when still present in a window, so a task removed from a window here
+// is never also started by the worker it was recalled from.
+//
+// Selectors may call into workers, so they're checked without holding
+// workersLk or any wndLk; tasks are recalled only if they're still waiting in
+// the windows of the same worker afterwards.
+//
+// Must be called from the runSched goroutine
+func (sh *scheduler) stealTasks() int {
+	if len(sh.openWindows) == 0 {
+		return 0
+	}
+
+	idle := map[WorkerID]*workerHandle{}
+	var candidates []stealCandidate
+
+	sh.workersLk.RLock()
+	for _, window := range sh.openWindows {
+		if worker, ok := sh.workers[window.worker]; ok && worker.enabled {
+			idle[window.worker] = worker
+		}
+	}
+	for wid, worker := range sh.workers {
+		if _, ok := idle[wid]; ok || !worker.enabled {
+			continue
+		}
+
+		worker.wndLk.Lock()
+		for _, window := range worker.activeWindows {
+			for _, todo := range window.todo {
+				candidates = append(candidates, stealCandidate{wid: wid, worker: worker, req: todo})
+			}
+		}
+		worker.wndLk.Unlock()
+	}
+	sh.workersLk.RUnlock()
+
+	if len(idle) == 0 {
+		return 0
+	}
+
+	steal := map[*workerRequest]struct{}{}
+	for _, c := range candidates {
+		if canRunElsewhere(idle, c.req) {
+			steal[c.req] = struct{}{}
+		}
+	}
+	if len(steal) == 0 {
+		return 0
+	}
+
+	sh.workersLk.RLock()
+	defer sh.workersLk.RUnlock()
+
+	var stolen int
+	for _, c := range candidates {
+		if _, ok := steal[c.req]; !ok {
+			continue
+		}
+		if worker, ok := sh.workers[c.wid]; !ok || worker != c.worker {
+			continue // the worker left, its tasks were rescheduled already
+		}
+		if sh.recall(c) {
+			stolen++
+		}
+	}
+
+	if stolen > 0 {
+		log.Infow("recalled tasks from busy workers", "tasks", stolen)
+	}
+
+	return stolen
+}
+
+// recall removes the task from the windows of the worker, and queues it for
+// scheduling again; it returns false if the task isn't waiting there anymore,
+// e.g. because the worker started it
+//
+// caller must hold sh.workersLk
+func (sh *scheduler) recall(c stealCandidate) bool {
+	c.worker.wndLk.Lock()
+	defer c.worker.wndLk.Unlock()
+
+	for _, window := range c.worker.activeWindows {
+		for i, todo := range window.todo {
+			if todo != c.req {
+				continue
+			}
+
+			log.Debugw("recalling task from busy worker", "worker", c.wid, "sector", todo.sector.ID, "task", todo.taskType)
+
+			window.allocated.free(c.worker.info.Resources, ResourceTable[todo.taskType][todo.sector.ProofType])
+			copy(window.todo[i:], window.todo[i+1:])
+			window.todo[len(window.todo)-1] = nil
+			window.todo = window.todo[:len(window.todo)-1]
+
+			sh.throttle.done(todo)
+			sh.schedQueue.Push(todo)
+			return true
+		}
+	}
+	return false
+}
+
+// canRunElsewhere returns whether one of the idle workers has spare capacity
+// for the task, and is accepted by its selector
+func canRunElsewhere(idle map[WorkerID]*workerHandle, req *workerRequest) bool {
+	if req.ctx.Err() != nil {
+		return false
+	}
+
+	needRes := ResourceTable[req.taskType][req.sector.ProofType]
+
+	for wid, worker := range idle {
+		worker.lk.Lock()
+		fits := worker.active.canHandleRequest(needRes, wid, "steal", worker.info.Resources)
+		worker.lk.Unlock()
+		if !fits {
+			continue
+		}
+
+		rpcCtx, cancel := context.WithTimeout(req.ctx, SelectorTimeout)
+		ok, err := req.sel.Ok(rpcCtx, req.taskType, req.sector.ProofType, worker)
+		cancel()
+		if err != nil {
+			log.Debugw("steal: selector error", "worker", wid, "error", err)
+			continue
+		}
+		if ok {
+			return true
+		}
+	}
+
+	return false
+}
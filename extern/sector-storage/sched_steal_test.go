@@ -0,0 +1,96 @@
+package sectorstorage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestStealTasks(t *testing.T) {
+	sh := newScheduler()
+
+	mkWorker := func() (WorkerID, *workerHandle) {
+		return WorkerID(uuid.New()), &workerHandle{
+			info: storiface.WorkerInfo{
+				Resources: storiface.WorkerResources{
+					MemPhysical: 128 << 30,
+					MemSwap:     200 << 30,
+					CPUs:        32,
+				},
+			},
+			preparing: &activeResources{},
+			active:    &activeResources{},
+			enabled:   true,
+		}
+	}
+
+	busyID, busy := mkWorker()
+	idleID, idle := mkWorker()
+	sh.workers[busyID] = busy
+	sh.workers[idleID] = idle
+
+	mkReq := func(n abi.SectorNumber, sel WorkerSelector) *workerRequest {
+		return &workerRequest{
+			sector: storage.SectorRef{
+				ID:        abi.SectorID{Miner: 1000, Number: n},
+				ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1,
+			},
+			taskType: sealtasks.TTPreCommit1,
+			sel:      sel,
+			ctx:      context.Background(),
+		}
+	}
+
+	movable := mkReq(1, slowishSelector(true))
+	pinned := mkReq(2, slowishSelector(false))
+	busy.activeWindows = []*schedWindow{{todo: []*workerRequest{movable, pinned}}}
+
+	// no idle workers, nothing to steal
+	require.Equal(t, 0, sh.stealTasks())
+
+	sh.openWindows = []*schedWindowRequest{{worker: idleID}}
+	require.Equal(t, 1, sh.stealTasks())
+
+	require.Equal(t, []*workerRequest{pinned}, busy.activeWindows[0].todo)
+	require.Equal(t, 1, sh.schedQueue.Len())
+	require.Equal(t, movable, (*sh.schedQueue)[0])
+
+	// workers with open windows aren't stolen from
+	sh.openWindows = append(sh.openWindows, &schedWindowRequest{worker: busyID})
+	busy.activeWindows[0].todo = append(busy.activeWindows[0].todo, mkReq(3, slowishSelector(true)))
+	require.Equal(t, 0, sh.stealTasks())
+
+	// tasks the busy worker starts while selectors are checked stay with it
+	sh.openWindows = sh.openWindows[:1]
+	busy.activeWindows[0].todo = []*workerRequest{pinned}
+	started := mkReq(4, nil)
+	started.sel = startingSelector(func() {
+		busy.wndLk.Lock()
+		defer busy.wndLk.Unlock()
+		busy.activeWindows[0].todo = []*workerRequest{pinned}
+	})
+	busy.activeWindows[0].todo = append(busy.activeWindows[0].todo, started)
+	require.Equal(t, 0, sh.stealTasks())
+	require.Equal(t, 1, sh.schedQueue.Len())
+}
+
+// startingSelector accepts any worker, after running start, which stands for
+// the busy worker starting the task meanwhile
+type startingSelector func()
+
+func (s startingSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, a *workerHandle) (bool, error) {
+	s()
+	return true, nil
+}
+
+func (s startingSelector) Cmp(ctx context.Context, task sealtasks.TaskType, a, b *workerHandle) (bool, error) {
+	return true, nil
+}
@@ -47,7 +47,7 @@ type schedTestWorker struct {
 	session uuid.UUID
 }
 
-func (s *schedTestWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storiface.CallID, error) {
+func (s *schedTestWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, meta []storiface.PieceMeta, numaNode int) (storiface.CallID, error) {
 	panic("implement me")
 }
 
@@ -55,7 +55,7 @@ func (s *schedTestWorker) SealPreCommit2(ctx context.Context, sector storage.Sec
 	panic("implement me")
 }
 
-func (s *schedTestWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storiface.CallID, error) {
+func (s *schedTestWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids, meta []storiface.PieceMeta) (storiface.CallID, error) {
 	panic("implement me")
 }
 
@@ -87,7 +87,7 @@ func (s *schedTestWorker) MoveStorage(ctx context.Context, sector storage.Sector
 	panic("implement me")
 }
 
-func (s *schedTestWorker) Fetch(ctx context.Context, id storage.SectorRef, ft storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode) (storiface.CallID, error) {
+func (s *schedTestWorker) Fetch(ctx context.Context, id storage.SectorRef, ft storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode, token storiface.FetchToken) (storiface.CallID, error) {
 	panic("implement me")
 }
 
@@ -126,6 +126,14 @@ func (s *schedTestWorker) Session(context.Context) (uuid.UUID, error) {
 	return s.session, nil
 }
 
+func (s *schedTestWorker) CallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) {
+	panic("implement me")
+}
+
+func (s *schedTestWorker) ManagerShutdown(ctx context.Context) error {
+	return nil
+}
+
 func (s *schedTestWorker) Close() error {
 	if !s.closed {
 		log.Info("close schedTestWorker")
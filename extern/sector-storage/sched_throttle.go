@@ -0,0 +1,145 @@
+package sectorstorage
+
+import (
+	"sync"
+	"time"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// ThrottleDispatchPeriod is the period SchedThrottle.Dispatch is counted over
+var ThrottleDispatchPeriod = time.Minute
+
+// schedThrottle holds tasks in the scheduler queue when the operator caps task
+// starts at runtime, e.g. when the datacenter is short on power or cooling.
+// Tasks count as running from being assigned to a worker until they are done.
+type schedThrottle struct {
+	lk sync.Mutex
+
+	limits storiface.SchedThrottle
+
+	running map[*workerRequest]struct{}
+	byType  map[sealtasks.TaskType]int
+	starts  []time.Time // task starts within the last ThrottleDispatchPeriod
+	timer   *time.Timer
+
+	wake chan struct{} // poked when held back tasks may be able to start
+}
+
+func newSchedThrottle() *schedThrottle {
+	return &schedThrottle{
+		running: map[*workerRequest]struct{}{},
+		byType:  map[sealtasks.TaskType]int{},
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+func (t *schedThrottle) set(limits storiface.SchedThrottle) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	t.limits = storiface.SchedThrottle{
+		Tasks:    map[sealtasks.TaskType]int{},
+		Dispatch: limits.Dispatch,
+	}
+	for tt, n := range limits.Tasks {
+		if n > 0 {
+			t.limits.Tasks[tt] = n
+		}
+	}
+
+	t.poke()
+}
+
+func (t *schedThrottle) get() storiface.SchedThrottle {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	out := storiface.SchedThrottle{
+		Tasks:    map[sealtasks.TaskType]int{},
+		Dispatch: t.limits.Dispatch,
+	}
+	for tt, n := range t.limits.Tasks {
+		out.Tasks[tt] = n
+	}
+	return out
+}
+
+// allow returns whether a task of the given type can be started now
+func (t *schedThrottle) allow(task sealtasks.TaskType, now time.Time) bool {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if t.limits.Dispatch > 0 {
+		cutoff := now.Add(-ThrottleDispatchPeriod)
+		for len(t.starts) > 0 && !t.starts[0].After(cutoff) {
+			t.starts = t.starts[1:]
+		}
+
+		if len(t.starts) >= t.limits.Dispatch {
+			// wake the scheduler when the oldest start leaves the period
+			if t.timer == nil {
+				t.timer = time.AfterFunc(t.starts[0].Sub(cutoff), func() {
+					t.lk.Lock()
+					defer t.lk.Unlock()
+
+					t.timer = nil
+					t.poke()
+				})
+			}
+			return false
+		}
+	}
+
+	if max, ok := t.limits.Tasks[task]; ok && t.byType[task] >= max {
+		return false
+	}
+
+	return true
+}
+
+// start is called when a task is assigned to a worker
+func (t *schedThrottle) start(req *workerRequest, now time.Time) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if _, ok := t.running[req]; ok {
+		return
+	}
+
+	t.running[req] = struct{}{}
+	t.byType[req.taskType]++
+	t.starts = append(t.starts, now)
+}
+
+// done is called when a task is finished, or moved back to the scheduler queue
+func (t *schedThrottle) done(req *workerRequest) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	if _, ok := t.running[req]; !ok {
+		return
+	}
+
+	delete(t.running, req)
+	t.byType[req.taskType]--
+	t.poke()
+}
+
+func (t *schedThrottle) poke() {
+	select {
+	case t.wake <- struct{}{}:
+	default:
+	}
+}
+
+// SetSchedThrottle replaces the limits on task starts across all workers.
+// Tasks which were already started are not interrupted.
+func (m *Manager) SetSchedThrottle(limits storiface.SchedThrottle) {
+	m.sched.throttle.set(limits)
+}
+
+func (m *Manager) SchedThrottle() storiface.SchedThrottle {
+	return m.sched.throttle.get()
+}
@@ -0,0 +1,64 @@
+package sectorstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestSchedThrottle(t *testing.T) {
+	th := newSchedThrottle()
+	now := time.Now()
+
+	pc1a := &workerRequest{taskType: sealtasks.TTPreCommit1}
+	pc1b := &workerRequest{taskType: sealtasks.TTPreCommit1}
+
+	// tasks started before limits were set still count
+	require.True(t, th.allow(sealtasks.TTPreCommit1, now))
+	th.start(pc1a, now)
+
+	th.set(storiface.SchedThrottle{
+		Tasks: map[sealtasks.TaskType]int{
+			sealtasks.TTPreCommit1: 1,
+			sealtasks.TTCommit2:    0, // no limit
+		},
+	})
+	require.False(t, th.allow(sealtasks.TTPreCommit1, now))
+	require.True(t, th.allow(sealtasks.TTCommit2, now))
+	require.Equal(t, map[sealtasks.TaskType]int{sealtasks.TTPreCommit1: 1}, th.get().Tasks)
+
+	<-th.wake
+	th.done(pc1a)
+	th.done(pc1a) // done must be idempotent
+	select {
+	case <-th.wake:
+	default:
+		t.Fatal("expected the scheduler to be woken")
+	}
+	require.True(t, th.allow(sealtasks.TTPreCommit1, now))
+	th.start(pc1b, now)
+	require.False(t, th.allow(sealtasks.TTPreCommit1, now))
+}
+
+func TestSchedThrottleDispatch(t *testing.T) {
+	th := newSchedThrottle()
+	th.set(storiface.SchedThrottle{Dispatch: 2})
+	<-th.wake
+
+	start := time.Now().Add(-ThrottleDispatchPeriod + 50*time.Millisecond)
+	th.start(&workerRequest{taskType: sealtasks.TTFetch}, start)
+	th.start(&workerRequest{taskType: sealtasks.TTFetch}, start.Add(time.Millisecond))
+
+	require.False(t, th.allow(sealtasks.TTCommit2, time.Now()))
+
+	select {
+	case <-th.wake:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the scheduler to be woken once a start leaves the dispatch period")
+	}
+	require.True(t, th.allow(sealtasks.TTCommit2, time.Now()))
+}
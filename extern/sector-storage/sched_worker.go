@@ -7,6 +7,7 @@ import (
 	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
 type schedWorker struct {
@@ -20,6 +21,9 @@ type schedWorker struct {
 	taskDone         chan struct{}
 
 	windowsRequested int
+
+	// set when tasks were held back for urgent work, closed when it's done
+	resume <-chan struct{}
 }
 
 // context only used for startup
@@ -56,14 +60,24 @@ func (sh *scheduler) runWorker(ctx context.Context, w Worker) error {
 	if exist {
 		log.Warnw("duplicated worker added", "id", wid)
 
-		// this is ok, we're already handling this worker in a different goroutine
+		// this is ok, we're already handling this worker in a different
+		// goroutine; the connection of the duplicate isn't used
+		dup := sh.workers[wid].workerRpc != w
 		sh.workersLk.Unlock()
+
+		if dup {
+			if err := w.Close(); err != nil {
+				log.Warnw("closing duplicated worker", "id", wid, "error", err)
+			}
+		}
 		return nil
 	}
 
 	sh.workers[wid] = worker
 	sh.workersLk.Unlock()
 
+	sh.workTracker.workerConnected(wid, worker.info.Hostname)
+
 	sw := &schedWorker{
 		sched:  sh,
 		worker: worker,
@@ -100,6 +114,8 @@ func (sw *schedWorker) handleWorker() {
 		sched.workersLk.Lock()
 		delete(sched.workers, sw.wid)
 		sched.workersLk.Unlock()
+
+		sched.workTracker.workerDisconnected(sw.wid)
 	}()
 
 	defer sw.heartbeatTimer.Stop()
@@ -277,6 +293,10 @@ func (sw *schedWorker) waitForUpdates() (update bool, sched bool, ok bool) {
 	case <-sw.taskDone:
 		log.Debugw("task done", "workerid", sw.wid)
 		return true, true, true
+	case <-sw.resume:
+		log.Debugw("urgent work done, resuming preempted tasks", "workerid", sw.wid)
+		sw.resume = nil
+		return true, false, true
 	case <-sw.sched.closing:
 	case <-sw.worker.closingMgr:
 	}
@@ -339,6 +359,7 @@ func (sw *schedWorker) workerCompactWindows() {
 
 func (sw *schedWorker) processAssignedWindows() {
 	worker := sw.worker
+	preempt := sw.sched.urgent.preempting()
 
 assignLoop:
 	// process windows in order
@@ -351,6 +372,11 @@ assignLoop:
 
 			worker.lk.Lock()
 			for t, todo := range firstWindow.todo {
+				if preempt != nil && worker.preemptible(todo.taskType) {
+					sw.resume = preempt
+					continue
+				}
+
 				needRes := ResourceTable[todo.taskType][todo.sector.ProofType]
 				if worker.preparing.canHandleRequest(needRes, sw.wid, "startPreparing", worker.info.Resources) {
 					tidx = t
@@ -370,6 +396,7 @@ assignLoop:
 
 			if err != nil {
 				log.Errorf("startProcessingTask error: %+v", err)
+				sw.sched.throttle.done(todo)
 				go todo.respond(xerrors.Errorf("startProcessingTask error: %w", err))
 			}
 
@@ -396,12 +423,22 @@ func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRe
 	w.preparing.add(w.info.Resources, needRes)
 	w.lk.Unlock()
 
+	ctx := req.ctx
+	if w.preemptible(req.taskType) {
+		// transfers of the task are suspended while urgent work runs
+		ctx = stores.WithPause(ctx, sh.urgent.preempting)
+	}
+
 	go func() {
 		// first run the prepare step (e.g. fetching sector data from other worker)
-		err := req.prepare(req.ctx, sh.workTracker.worker(sw.wid, w.workerRpc))
+		req.setState(storiface.JobTransferringIn)
+		err := req.prepare(ctx, sh.workTracker.worker(sw.wid, w.info, w.workerRpc, req))
 		sh.workersLk.Lock()
 
 		if err != nil {
+			req.setState(storiface.JobDone)
+			sh.throttle.done(req)
+
 			w.lk.Lock()
 			w.preparing.free(w.info.Resources, needRes)
 			w.lk.Unlock()
@@ -436,8 +473,15 @@ func (sw *schedWorker) startProcessingTask(taskDone chan struct{}, req *workerRe
 			case <-sh.closing:
 			}
 
+			if err := sh.records.started(req.sector, req.taskType, w.info); err != nil {
+				log.Errorf("recording %s of %s: %+v", req.taskType, storiface.SectorName(req.sector.ID), err)
+			}
+
 			// Do the work!
-			err = req.work(req.ctx, sh.workTracker.worker(sw.wid, w.workerRpc))
+			req.setState(workState(req.taskType))
+			err = req.work(ctx, sh.workTracker.worker(sw.wid, w.info, w.workerRpc, req))
+			req.setState(storiface.JobDone)
+			sh.throttle.done(req)
 
 			select {
 			case req.ret <- workerResponse{err: err}:
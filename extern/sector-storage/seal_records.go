@@ -0,0 +1,195 @@
+package sectorstorage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// WorkerVersion is the sealing software version local workers report in their
+// info, and which is recorded with the sectors they seal; it's set by the
+// binaries running local workers
+var WorkerVersion string
+
+// sealRecordTasks are the tasks recorded in seal records
+var sealRecordTasks = map[sealtasks.TaskType]struct{}{
+	sealtasks.TTPreCommit1: {},
+	sealtasks.TTPreCommit2: {},
+	sealtasks.TTCommit1:    {},
+	sealtasks.TTCommit2:    {},
+}
+
+// sealRecords keeps the seal record of each sector. Records are kept in
+// memory until Manager.PersistSealRecords is called, and are written to the
+// datastore as calls start after that. A nil sealRecords ignores all records.
+type sealRecords struct {
+	lk sync.Mutex
+
+	ds  datastore.Datastore
+	mem map[abi.SectorID]*storiface.SealRecord
+}
+
+func newSealRecords() *sealRecords {
+	return &sealRecords{
+		mem: map[abi.SectorID]*storiface.SealRecord{},
+	}
+}
+
+func sealRecordKey(id abi.SectorID) datastore.Key {
+	return datastore.NewKey(storiface.SectorName(id))
+}
+
+// persist moves the records kept so far to the datastore, and has all later
+// records written there
+func (r *sealRecords) persist(ds datastore.Datastore) error {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	r.ds = ds
+	for id, rec := range r.mem {
+		prev, err := r.getLocked(id)
+		if err != nil {
+			return err
+		}
+		if prev != nil {
+			rec.Calls = append(prev.Calls, rec.Calls...)
+		}
+		if err := r.putLocked(rec); err != nil {
+			return err
+		}
+	}
+	r.mem = nil
+
+	return nil
+}
+
+// started records a sealing call of the sector starting on the worker
+func (r *sealRecords) started(sector storage.SectorRef, task sealtasks.TaskType, info storiface.WorkerInfo) error {
+	if r == nil {
+		return nil
+	}
+	if _, ok := sealRecordTasks[task]; !ok {
+		return nil
+	}
+
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	rec, err := r.getLocked(sector.ID)
+	if err != nil {
+		return err
+	}
+	if rec == nil {
+		rec = &storiface.SealRecord{Sector: sector.ID}
+	}
+
+	if err := setSealProof(rec, sector.ProofType); err != nil {
+		return err
+	}
+	rec.Calls = append(rec.Calls, storiface.SealCall{
+		Task:    task,
+		Worker:  info.Hostname,
+		Version: info.Version,
+		Started: time.Now(),
+	})
+
+	return r.putLocked(rec)
+}
+
+func (r *sealRecords) get(id abi.SectorID) (*storiface.SealRecord, error) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	return r.getLocked(id)
+}
+
+// getLocked returns the record of the sector, or nil if there is none; called
+// with lk held
+func (r *sealRecords) getLocked(id abi.SectorID) (*storiface.SealRecord, error) {
+	if r.ds == nil {
+		return r.mem[id], nil
+	}
+
+	b, err := r.ds.Get(sealRecordKey(id))
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		return nil, nil
+	default:
+		return nil, xerrors.Errorf("reading seal record of %s: %w", storiface.SectorName(id), err)
+	}
+
+	var rec storiface.SealRecord
+	if err := json.Unmarshal(b, &rec); err != nil {
+		return nil, xerrors.Errorf("decoding seal record of %s: %w", storiface.SectorName(id), err)
+	}
+	return &rec, nil
+}
+
+// putLocked stores the record; called with lk held
+func (r *sealRecords) putLocked(rec *storiface.SealRecord) error {
+	if r.ds == nil {
+		r.mem[rec.Sector] = rec
+		return nil
+	}
+
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return xerrors.Errorf("encoding seal record: %w", err)
+	}
+	if err := r.ds.Put(sealRecordKey(rec.Sector), b); err != nil {
+		return xerrors.Errorf("writing seal record of %s: %w", storiface.SectorName(rec.Sector), err)
+	}
+	return nil
+}
+
+// setSealProof sets the proof parameters of the record from the seal proof
+func setSealProof(rec *storiface.SealRecord, spt abi.RegisteredSealProof) error {
+	ssize, err := spt.SectorSize()
+	if err != nil {
+		return xerrors.Errorf("getting sector size: %w", err)
+	}
+	wdpost, err := spt.RegisteredWindowPoStProof()
+	if err != nil {
+		return xerrors.Errorf("getting window post proof: %w", err)
+	}
+	wpost, err := spt.RegisteredWinningPoStProof()
+	if err != nil {
+		return xerrors.Errorf("getting winning post proof: %w", err)
+	}
+
+	rec.ProofType = spt
+	rec.SectorSize = ssize
+	rec.WindowPoStProof = wdpost
+	rec.WinningPoStProof = wpost
+	return nil
+}
+
+// PersistSealRecords keeps the seal records of sectors in the datastore, so
+// that they are kept across restarts. It should be called right after New.
+func (m *Manager) PersistSealRecords(ds datastore.Datastore) error {
+	return m.sched.records.persist(ds)
+}
+
+// SealRecord returns the proof parameters a sector was sealed with, and the
+// workers which ran its sealing calls
+func (m *Manager) SealRecord(ctx context.Context, id abi.SectorID) (storiface.SealRecord, error) {
+	rec, err := m.sched.records.get(id)
+	if err != nil {
+		return storiface.SealRecord{}, err
+	}
+	if rec == nil {
+		return storiface.SealRecord{}, xerrors.Errorf("no seal record for %s", storiface.SectorName(id))
+	}
+	return *rec, nil
+}
@@ -0,0 +1,55 @@
+package sectorstorage
+
+import (
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestSealRecords(t *testing.T) {
+	r := newSealRecords()
+	sector := storage.SectorRef{
+		ID:        abi.SectorID{Miner: 1000, Number: 1},
+		ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1_1,
+	}
+	w1 := storiface.WorkerInfo{Hostname: "w1", Version: "1.0"}
+	w2 := storiface.WorkerInfo{Hostname: "w2"}
+
+	require.NoError(t, r.started(sector, sealtasks.TTPreCommit1, w1))
+	require.NoError(t, r.started(sector, sealtasks.TTFetch, w1)) // not recorded
+
+	ds := datastore.NewMapDatastore()
+	require.NoError(t, r.persist(ds))
+
+	require.NoError(t, r.started(sector, sealtasks.TTPreCommit2, w2))
+
+	// read back from the datastore, as after a restart
+	r = newSealRecords()
+	require.NoError(t, r.persist(ds))
+
+	rec, err := r.get(sector.ID)
+	require.NoError(t, err)
+	require.NotNil(t, rec)
+	require.Equal(t, abi.RegisteredSealProof_StackedDrg2KiBV1_1, rec.ProofType)
+	require.Equal(t, abi.SectorSize(2048), rec.SectorSize)
+	require.Equal(t, abi.RegisteredPoStProof_StackedDrgWindow2KiBV1, rec.WindowPoStProof)
+	require.Equal(t, abi.RegisteredPoStProof_StackedDrgWinning2KiBV1, rec.WinningPoStProof)
+
+	require.Len(t, rec.Calls, 2)
+	require.Equal(t, sealtasks.TTPreCommit1, rec.Calls[0].Task)
+	require.Equal(t, "w1", rec.Calls[0].Worker)
+	require.Equal(t, "1.0", rec.Calls[0].Version)
+	require.Equal(t, sealtasks.TTPreCommit2, rec.Calls[1].Task)
+	require.Equal(t, "w2", rec.Calls[1].Worker)
+
+	rec, err = r.get(abi.SectorID{Miner: 1000, Number: 2})
+	require.NoError(t, err)
+	require.Nil(t, rec)
+}
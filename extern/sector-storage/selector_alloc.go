@@ -65,7 +65,7 @@ func (s *allocSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi
 }
 
 func (s *allocSelector) Cmp(ctx context.Context, task sealtasks.TaskType, a, b *workerHandle) (bool, error) {
-	return a.utilization() < b.utilization(), nil
+	return lessUtilized(task, a, b), nil
 }
 
 var _ WorkerSelector = &allocSelector{}
@@ -67,7 +67,7 @@ func (s *existingSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt
 }
 
 func (s *existingSelector) Cmp(ctx context.Context, task sealtasks.TaskType, a, b *workerHandle) (bool, error) {
-	return a.utilization() < b.utilization(), nil
+	return lessUtilized(task, a, b), nil
 }
 
 var _ WorkerSelector = &existingSelector{}
@@ -0,0 +1,78 @@
+package sectorstorage
+
+import (
+	"context"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// copySelector selects workers which can fetch the sector files, but don't
+// have access to any existing copy of them, so that they compute on their own
+// copy.
+type copySelector struct {
+	*existingSelector
+}
+
+func newCopySelector(index stores.SectorIndex, sector abi.SectorID, alloc storiface.SectorFileType) *copySelector {
+	return &copySelector{
+		existingSelector: newExistingSelector(index, sector, alloc, true),
+	}
+}
+
+func (s *copySelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, whnd *workerHandle) (bool, error) {
+	ok, err := s.existingSelector.Ok(ctx, task, spt, whnd)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	paths, err := whnd.workerRpc.Paths(ctx)
+	if err != nil {
+		return false, xerrors.Errorf("getting worker paths: %w", err)
+	}
+
+	have := map[stores.ID]struct{}{}
+	for _, path := range paths {
+		have[path.ID] = struct{}{}
+	}
+
+	existing, err := s.index.StorageFindSector(ctx, s.sector, s.alloc, 0, false)
+	if err != nil {
+		return false, xerrors.Errorf("finding existing sector: %w", err)
+	}
+
+	for _, info := range existing {
+		if _, ok := have[info.ID]; ok {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+var _ WorkerSelector = &copySelector{}
+
+// excludeSelector wraps another selector, rejecting one worker
+type excludeSelector struct {
+	WorkerSelector
+	exclude WorkerID
+}
+
+func (s *excludeSelector) Ok(ctx context.Context, task sealtasks.TaskType, spt abi.RegisteredSealProof, whnd *workerHandle) (bool, error) {
+	sessID, err := whnd.workerRpc.Session(ctx)
+	if err != nil {
+		return false, xerrors.Errorf("getting worker session: %w", err)
+	}
+	if WorkerID(sessID) == s.exclude {
+		return false, nil
+	}
+
+	return s.WorkerSelector.Ok(ctx, task, spt, whnd)
+}
+
+var _ WorkerSelector = &excludeSelector{}
@@ -1,10 +1,13 @@
 package sectorstorage
 
 import (
+	"context"
 	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/xerrors"
 
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 )
 
@@ -33,7 +36,9 @@ func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 	out := map[uuid.UUID][]storiface.WorkerJob{}
 	calls := map[storiface.CallID]struct{}{}
 
-	for _, t := range m.sched.workTracker.Running() {
+	// calls which are running or waiting to be collected; calls restored
+	// after a restart are listed under the zero worker ID
+	for _, t := range m.sched.workTracker.jobs() {
 		out[uuid.UUID(t.worker)] = append(out[uuid.UUID(t.worker)], t.job)
 		calls[t.job.ID] = struct{}{}
 	}
@@ -44,12 +49,15 @@ func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 		handle.wndLk.Lock()
 		for wi, window := range handle.activeWindows {
 			for _, request := range window.todo {
+				state, history := request.jobState()
 				out[uuid.UUID(id)] = append(out[uuid.UUID(id)], storiface.WorkerJob{
 					ID:      storiface.UndefCall,
 					Sector:  request.sector.ID,
 					Task:    request.taskType,
 					RunWait: wi + 1,
 					Start:   request.start,
+					State:   state,
+					History: history,
 				})
 			}
 		}
@@ -61,9 +69,9 @@ func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 	m.workLk.Lock()
 	defer m.workLk.Unlock()
 
+	// work which returned and waits to be collected
 	for id, work := range m.callToWork {
-		_, found := calls[id]
-		if found {
+		if _, found := calls[id]; found {
 			continue
 		}
 
@@ -92,3 +100,57 @@ func (m *Manager) WorkerJobs() map[uuid.UUID][]storiface.WorkerJob {
 
 	return out
 }
+
+func (m *Manager) DispatchHealth(ctx context.Context) (storiface.DispatchHealth, error) {
+	out := storiface.DispatchHealth{
+		Queued:  map[sealtasks.TaskType]int{},
+		Workers: map[uuid.UUID]storiface.WorkerHealth{},
+	}
+
+	si, err := m.sched.Info(ctx)
+	if err != nil {
+		return storiface.DispatchHealth{}, xerrors.Errorf("getting scheduler info: %w", err)
+	}
+	for _, req := range si.(SchedDiagInfo).Requests {
+		out.Queued[req.TaskType]++
+	}
+
+	m.sched.workersLk.RLock()
+	for id, handle := range m.sched.workers {
+		wh := storiface.WorkerHealth{
+			Hostname: handle.info.Hostname,
+			Enabled:  handle.enabled,
+		}
+
+		handle.wndLk.Lock()
+		for _, window := range handle.activeWindows {
+			wh.Assigned += len(window.todo)
+		}
+		handle.wndLk.Unlock()
+
+		out.Workers[uuid.UUID(id)] = wh
+	}
+	m.sched.workersLk.RUnlock()
+
+	m.sched.workTracker.health(&out)
+
+	return out, nil
+}
+
+// CallLogs streams the output written by the worker a call was dispatched to
+// while the call was running
+func (m *Manager) CallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) {
+	wid, ok := m.sched.workTracker.callWorker(call)
+	if !ok {
+		return nil, xerrors.Errorf("call %s not found", call)
+	}
+
+	m.sched.workersLk.RLock()
+	handle, ok := m.sched.workers[wid]
+	m.sched.workersLk.RUnlock()
+	if !ok {
+		return nil, xerrors.Errorf("worker %s which ran call %s is not connected", wid, call)
+	}
+
+	return handle.workerRpc.CallLogs(ctx, call)
+}
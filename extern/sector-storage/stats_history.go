@@ -0,0 +1,246 @@
+package sectorstorage
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// StatsPhaseBounds are the upper bounds of the job phase duration histogram
+// buckets. Persisted histograms with different bounds are reset.
+var StatsPhaseBounds = []time.Duration{
+	time.Second,
+	10 * time.Second,
+	time.Minute,
+	5 * time.Minute,
+	15 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	2 * time.Hour,
+	4 * time.Hour,
+	8 * time.Hour,
+}
+
+// StatsRetention is how long hourly throughput samples are kept
+var StatsRetention = 30 * 24 * time.Hour
+
+// StatsFlushInterval is how often stats are written to the datastore
+var StatsFlushInterval = time.Minute
+
+var statsKey = datastore.NewKey("/stats")
+
+// sealingHistory collects long-term sealing stats. Unlike metrics, which
+// reset when the miner restarts, they are kept in the datastore once
+// Manager.PersistStats is called. A nil sealingHistory ignores all records.
+type sealingHistory struct {
+	lk sync.Mutex
+
+	ds    datastore.Datastore
+	stats storiface.SealingStats
+	dirty bool
+}
+
+func newSealingHistory() *sealingHistory {
+	return &sealingHistory{
+		stats: storiface.SealingStats{Since: time.Now()},
+	}
+}
+
+func (h *sealingHistory) load(ds datastore.Datastore) error {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	h.ds = ds
+
+	b, err := ds.Get(statsKey)
+	switch err {
+	case nil:
+	case datastore.ErrNotFound:
+		h.dirty = true
+		return nil
+	default:
+		return xerrors.Errorf("reading sealing stats: %w", err)
+	}
+
+	var st storiface.SealingStats
+	if err := json.Unmarshal(b, &st); err != nil {
+		return xerrors.Errorf("decoding sealing stats: %w", err)
+	}
+	h.stats = st
+	return nil
+}
+
+func (h *sealingHistory) flush() error {
+	if h == nil {
+		return nil
+	}
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if h.ds == nil || !h.dirty {
+		return nil
+	}
+
+	b, err := json.Marshal(&h.stats)
+	if err != nil {
+		return xerrors.Errorf("encoding sealing stats: %w", err)
+	}
+	if err := h.ds.Put(statsKey, b); err != nil {
+		return xerrors.Errorf("writing sealing stats: %w", err)
+	}
+
+	h.dirty = false
+	return nil
+}
+
+// phase records the time a job of the task type spent in a state
+func (h *sealingHistory) phase(task sealtasks.TaskType, st storiface.JobState, d time.Duration) {
+	if h == nil {
+		return
+	}
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if h.stats.Phases == nil {
+		h.stats.Phases = map[sealtasks.TaskType]map[storiface.JobState]storiface.DurationHistogram{}
+	}
+	if h.stats.Phases[task] == nil {
+		h.stats.Phases[task] = map[storiface.JobState]storiface.DurationHistogram{}
+	}
+
+	hist := h.stats.Phases[task][st]
+	if !sameBounds(hist.Bounds, StatsPhaseBounds) {
+		hist = storiface.DurationHistogram{
+			Bounds: append([]time.Duration(nil), StatsPhaseBounds...),
+			Counts: make([]uint64, len(StatsPhaseBounds)+1),
+		}
+	}
+
+	bucket := len(hist.Bounds)
+	for i, b := range hist.Bounds {
+		if d <= b {
+			bucket = i
+			break
+		}
+	}
+	hist.Counts[bucket]++
+	hist.Sum += d
+
+	h.stats.Phases[task][st] = hist
+	h.dirty = true
+}
+
+// callDone records the outcome of a call completed on a worker
+func (h *sealingHistory) callDone(hostname string, task sealtasks.TaskType, failed bool, now time.Time) {
+	if h == nil {
+		return
+	}
+
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	if h.stats.Workers == nil {
+		h.stats.Workers = map[string]map[sealtasks.TaskType]storiface.CallOutcomes{}
+	}
+	if h.stats.Workers[hostname] == nil {
+		h.stats.Workers[hostname] = map[sealtasks.TaskType]storiface.CallOutcomes{}
+	}
+
+	out := h.stats.Workers[hostname][task]
+	if failed {
+		out.Failed++
+	} else {
+		out.Done++
+	}
+	h.stats.Workers[hostname][task] = out
+
+	if !failed {
+		hour := now.Truncate(time.Hour)
+		if n := len(h.stats.Throughput); n == 0 || !h.stats.Throughput[n-1].Hour.Equal(hour) {
+			h.stats.Throughput = append(h.stats.Throughput, storiface.ThroughputSample{
+				Hour: hour,
+				Done: map[sealtasks.TaskType]uint64{},
+			})
+		}
+		h.stats.Throughput[len(h.stats.Throughput)-1].Done[task]++
+
+		cutoff := hour.Add(-StatsRetention)
+		drop := 0
+		for drop < len(h.stats.Throughput) && !h.stats.Throughput[drop].Hour.After(cutoff) {
+			drop++
+		}
+		h.stats.Throughput = h.stats.Throughput[drop:]
+	}
+
+	h.dirty = true
+}
+
+func (h *sealingHistory) snapshot() (storiface.SealingStats, error) {
+	h.lk.Lock()
+	defer h.lk.Unlock()
+
+	// deep copy, the stats are plain data
+	b, err := json.Marshal(&h.stats)
+	if err != nil {
+		return storiface.SealingStats{}, err
+	}
+
+	var out storiface.SealingStats
+	if err := json.Unmarshal(b, &out); err != nil {
+		return storiface.SealingStats{}, err
+	}
+	return out, nil
+}
+
+func sameBounds(a, b []time.Duration) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// PersistStats keeps the long-term sealing stats in the datastore, so that
+// they are kept across restarts. Stats stored by a previous run replace the
+// ones collected so far, so it should be called right after New.
+func (m *Manager) PersistStats(ds datastore.Datastore) error {
+	if err := m.sched.history.load(ds); err != nil {
+		return err
+	}
+
+	go func() {
+		t := time.NewTicker(StatsFlushInterval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-t.C:
+				if err := m.sched.history.flush(); err != nil {
+					log.Errorf("%+v", err)
+				}
+			case <-m.closing:
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// SealingStats returns the long-term sealing stats
+func (m *Manager) SealingStats(ctx context.Context) (storiface.SealingStats, error) {
+	return m.sched.history.snapshot()
+}
@@ -0,0 +1,67 @@
+package sectorstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestSealingHistory(t *testing.T) {
+	ds := datastore.NewMapDatastore()
+
+	h := newSealingHistory()
+	require.NoError(t, h.load(ds))
+
+	h.phase(sealtasks.TTPreCommit2, storiface.JobComputing, 20*time.Minute)
+	h.phase(sealtasks.TTPreCommit2, storiface.JobComputing, 40*time.Minute)
+	h.phase(sealtasks.TTPreCommit2, storiface.JobComputing, 24*time.Hour)
+
+	now := time.Date(2020, 11, 12, 9, 30, 0, 0, time.UTC)
+	old := now.Add(-StatsRetention - time.Hour)
+	h.callDone("host", sealtasks.TTPreCommit2, false, old)
+	h.callDone("host", sealtasks.TTPreCommit2, false, now)
+	h.callDone("host", sealtasks.TTPreCommit2, true, now)
+	h.callDone("host", sealtasks.TTCommit2, false, now.Add(time.Minute))
+
+	require.NoError(t, h.flush())
+
+	// stats are loaded back after a restart
+	h = newSealingHistory()
+	require.NoError(t, h.load(ds))
+
+	st, err := h.snapshot()
+	require.NoError(t, err)
+
+	hist := st.Phases[sealtasks.TTPreCommit2][storiface.JobComputing]
+	require.Equal(t, StatsPhaseBounds, hist.Bounds)
+	require.Equal(t, []uint64{0, 0, 0, 0, 0, 1, 1, 0, 0, 0, 1}, hist.Counts)
+	require.Equal(t, 25*time.Hour, hist.Sum)
+
+	require.Equal(t, storiface.CallOutcomes{Done: 2, Failed: 1}, st.Workers["host"][sealtasks.TTPreCommit2])
+	require.Equal(t, storiface.CallOutcomes{Done: 1}, st.Workers["host"][sealtasks.TTCommit2])
+
+	// the sample older than the retention period was dropped
+	require.Len(t, st.Throughput, 1)
+	require.True(t, st.Throughput[0].Hour.Equal(now.Truncate(time.Hour)))
+	require.Equal(t, map[sealtasks.TaskType]uint64{
+		sealtasks.TTPreCommit2: 1,
+		sealtasks.TTCommit2:    1,
+	}, st.Throughput[0].Done)
+
+	// snapshots don't share state with the history
+	st.Workers["host"][sealtasks.TTCommit2] = storiface.CallOutcomes{}
+	st2, err := h.snapshot()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), st2.Workers["host"][sealtasks.TTCommit2].Done)
+
+	// a nil history ignores records
+	var nh *sealingHistory
+	nh.phase(sealtasks.TTCommit2, storiface.JobQueued, time.Second)
+	nh.callDone("host", sealtasks.TTCommit2, false, now)
+	require.NoError(t, nh.flush())
+}
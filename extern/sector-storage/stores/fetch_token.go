@@ -0,0 +1,58 @@
+package stores
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// FetchTokenHeader carries a fetch token in requests to the storage HTTP
+// handler, next to (or instead of) the regular Authorization header
+const FetchTokenHeader = "X-Lotus-Fetch-Token"
+
+// FetchTokenParam carries a fetch token in the query of sector file URLs, for
+// clients which are handed URLs and can't set headers, e.g. appliances of
+// dispatched calls
+const FetchTokenParam = "fetch-token"
+
+type fetchTokenKey struct{}
+
+// WithFetchToken makes sector fetches done with the returned context send the
+// token to the remote storage handler
+func WithFetchToken(ctx context.Context, token storiface.FetchToken) context.Context {
+	return context.WithValue(ctx, fetchTokenKey{}, token)
+}
+
+func fetchToken(ctx context.Context) (storiface.FetchToken, bool) {
+	tok, ok := ctx.Value(fetchTokenKey{}).(storiface.FetchToken)
+	return tok, ok && tok != ""
+}
+
+// ParseSectorRequest returns the sector and file type of a
+// GET /remote/{type}/{id} request, ok is false for any other request
+func ParseSectorRequest(r *http.Request) (id abi.SectorID, ft storiface.SectorFileType, ok bool) {
+	if r.Method != http.MethodGet {
+		return abi.SectorID{}, 0, false
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/remote/"), "/")
+	if len(parts) != 2 {
+		return abi.SectorID{}, 0, false
+	}
+
+	ft, err := ftFromString(parts[0])
+	if err != nil {
+		return abi.SectorID{}, 0, false
+	}
+
+	id, err = storiface.ParseSectorID(parts[1])
+	if err != nil {
+		return abi.SectorID{}, 0, false
+	}
+
+	return id, ft, true
+}
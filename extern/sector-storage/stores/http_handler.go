@@ -80,7 +80,7 @@ func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Requ
 		ProofType: 0,
 	}
 
-	paths, _, err := handler.Local.AcquireSector(r.Context(), si, ft, storiface.FTNone, storiface.PathStorage, storiface.AcquireMove)
+	paths, ids, err := handler.Local.AcquireSector(r.Context(), si, ft, storiface.FTNone, storiface.PathStorage, storiface.AcquireMove)
 	if err != nil {
 		log.Errorf("%+v", err)
 		w.WriteHeader(500)
@@ -103,6 +103,22 @@ func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	if !stat.IsDir() && r.Header.Get("Range") != "" {
+		// ranges of files, e.g. of unsealed pieces read for retrievals, are
+		// small enough not to be throttled
+		f, err := os.OpenFile(path, os.O_RDONLY, 0644) // nolint
+		if err != nil {
+			log.Errorf("%+v", err)
+			w.WriteHeader(500)
+			return
+		}
+		defer f.Close() // nolint
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", stat.ModTime(), f)
+		return
+	}
+
 	var rd io.Reader
 	if stat.IsDir() {
 		rd, err = tarutil.TarDirectory(path)
@@ -117,6 +133,9 @@ func (handler *FetchHandler) remoteGetSector(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	// slow down while the path is being proven
+	rd = handler.Local.throttle.Reader(r.Context(), ID(storiface.PathByType(ids, ft)), rd)
+
 	w.WriteHeader(200)
 	if _, err := io.Copy(w, rd); err != nil { // TODO: default 32k buf may be too small
 		log.Errorf("%+v", err)
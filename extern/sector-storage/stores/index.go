@@ -48,6 +48,14 @@ type SectorStorageInfo struct {
 	CanStore bool
 
 	Primary bool
+
+	// When the sector files were last (re)declared in this storage, e.g. by a
+	// storage scan on startup; zero for storage which could only fetch them
+	Declared time.Time
+
+	// Last health report of the storage
+	LastHeartbeat time.Time
+	HeartbeatErr  string `json:",omitempty"`
 }
 
 type SectorIndex interface { // part of storage-miner api
@@ -72,8 +80,9 @@ type Decl struct {
 }
 
 type declMeta struct {
-	storage ID
-	primary bool
+	storage  ID
+	primary  bool
+	declared time.Time
 }
 
 type storageEntry struct {
@@ -192,6 +201,8 @@ func (i *Index) StorageDeclareSector(ctx context.Context, storageID ID, s abi.Se
 	i.lk.Lock()
 	defer i.lk.Unlock()
 
+	now := time.Now()
+
 loop:
 	for _, fileType := range storiface.PathTypes {
 		if fileType&ft == 0 {
@@ -207,13 +218,15 @@ loop:
 				} else {
 					log.Warnf("sector %v redeclared in %s", s, storageID)
 				}
+				sid.declared = now
 				continue loop
 			}
 		}
 
 		i.sectors[d] = append(i.sectors[d], &declMeta{
-			storage: storageID,
-			primary: primary,
+			storage:  storageID,
+			primary:  primary,
+			declared: now,
 		})
 	}
 
@@ -260,6 +273,7 @@ func (i *Index) StorageFindSector(ctx context.Context, s abi.SectorID, ft storif
 
 	storageIDs := map[ID]uint64{}
 	isprimary := map[ID]bool{}
+	declared := map[ID]time.Time{}
 
 	for _, pathType := range storiface.PathTypes {
 		if ft&pathType == 0 {
@@ -269,6 +283,9 @@ func (i *Index) StorageFindSector(ctx context.Context, s abi.SectorID, ft storif
 		for _, id := range i.sectors[Decl{s, pathType}] {
 			storageIDs[id.storage]++
 			isprimary[id.storage] = isprimary[id.storage] || id.primary
+			if id.declared.After(declared[id.storage]) {
+				declared[id.storage] = id.declared
+			}
 		}
 	}
 
@@ -301,6 +318,10 @@ func (i *Index) StorageFindSector(ctx context.Context, s abi.SectorID, ft storif
 			CanStore: st.info.CanStore,
 
 			Primary: isprimary[id],
+
+			Declared:      declared[id],
+			LastHeartbeat: st.lastHeartbeat,
+			HeartbeatErr:  errStr(st.heartbeatErr),
 		})
 	}
 
@@ -354,6 +375,8 @@ func (i *Index) StorageFindSector(ctx context.Context, s abi.SectorID, ft storif
 				CanStore: st.info.CanStore,
 
 				Primary: false,
+
+				LastHeartbeat: st.lastHeartbeat,
 			})
 		}
 	}
@@ -449,3 +472,10 @@ func (i *Index) FindSector(id abi.SectorID, typ storiface.SectorFileType) ([]ID,
 }
 
 var _ SectorIndex = &Index{}
+
+func errStr(err error) string {
+	if err == nil {
+		return ""
+	}
+	return err.Error()
+}
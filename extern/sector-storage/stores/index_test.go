@@ -0,0 +1,53 @@
+package stores
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestFindSectorDeclared(t *testing.T) {
+	ctx := context.Background()
+
+	id := ID(uuid.New().String())
+	idx := NewIndex()
+	require.NoError(t, idx.StorageAttach(ctx, StorageInfo{ID: id, URLs: []string{"http://miner/remote"}, CanSeal: true}, fsutil.FsStat{Capacity: pathSize, Available: pathSize}))
+
+	sid := abi.SectorID{Miner: 1000, Number: 1}
+	before := time.Now()
+	require.NoError(t, idx.StorageDeclareSector(ctx, id, sid, storiface.FTSealed, true))
+
+	found, err := idx.StorageFindSector(ctx, sid, storiface.FTSealed, 0, false)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.False(t, found[0].Declared.Before(before))
+	require.False(t, found[0].LastHeartbeat.IsZero())
+	require.Empty(t, found[0].HeartbeatErr)
+
+	// redeclaring refreshes the declaration time
+	first := found[0].Declared
+	time.Sleep(time.Millisecond)
+	require.NoError(t, idx.StorageDeclareSector(ctx, id, sid, storiface.FTSealed, true))
+
+	found, err = idx.StorageFindSector(ctx, sid, storiface.FTSealed, 0, false)
+	require.NoError(t, err)
+	require.True(t, found[0].Declared.After(first))
+
+	require.NoError(t, idx.StorageReportHealth(ctx, id, HealthReport{Err: "disk on fire"}))
+	found, err = idx.StorageFindSector(ctx, sid, storiface.FTSealed, 0, false)
+	require.NoError(t, err)
+	require.Equal(t, "disk on fire", found[0].HeartbeatErr)
+
+	// not declared in any storage
+	found, err = idx.StorageFindSector(ctx, sid, storiface.FTCache, 0, false)
+	require.NoError(t, err)
+	require.Empty(t, found)
+}
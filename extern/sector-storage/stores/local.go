@@ -74,6 +74,8 @@ type Local struct {
 	paths map[ID]*path
 
 	localLk sync.RWMutex
+
+	throttle *ProvingThrottle
 }
 
 type path struct {
@@ -23,6 +23,7 @@ import (
 	"github.com/filecoin-project/specs-storage/storage"
 
 	"github.com/hashicorp/go-multierror"
+	"github.com/ipfs/go-datastore"
 	"golang.org/x/xerrors"
 )
 
@@ -39,6 +40,12 @@ type Remote struct {
 
 	fetchLk  sync.Mutex
 	fetching map[abi.SectorID]chan struct{}
+
+	sharedLk sync.Mutex
+	shared   map[ID]string // storage ID -> local mount
+
+	transfersLk sync.Mutex
+	transfers   datastore.Datastore // fetches in progress, once persisted
 }
 
 func (r *Remote) RemoveCopies(ctx context.Context, s abi.SectorID, types storiface.SectorFileType) error {
@@ -104,9 +111,24 @@ func (r *Remote) AcquireSector(ctx context.Context, s storage.SectorRef, existin
 			continue
 		}
 
-		if storiface.PathByType(paths, fileType) == "" {
-			toFetch |= fileType
+		if storiface.PathByType(paths, fileType) != "" {
+			continue
+		}
+
+		if sharedInPlace(pathType, op) {
+			sp, sid, err := r.findShared(ctx, s.ID, fileType)
+			if err != nil {
+				return storiface.SectorPaths{}, storiface.SectorPaths{}, xerrors.Errorf("finding sector in shared storage: %w", err)
+			}
+			if sp != "" {
+				// files on shared storage are used in place, no transfer needed
+				storiface.SetPathByType(&paths, fileType, sp)
+				storiface.SetPathByType(&stores, fileType, string(sid))
+				continue
+			}
 		}
+
+		toFetch |= fileType
 	}
 
 	apaths, ids, err := r.local.AcquireSector(ctx, s, storiface.FTNone, toFetch, pathType, op)
@@ -137,15 +159,35 @@ func (r *Remote) AcquireSector(ctx context.Context, s storage.SectorRef, existin
 		dest := storiface.PathByType(apaths, fileType)
 		storageID := storiface.PathByType(ids, fileType)
 
-		url, err := r.acquireFromRemote(ctx, s.ID, fileType, dest)
+		temp, err := tempFetchDest(dest, false)
 		if err != nil {
 			return storiface.SectorPaths{}, storiface.SectorPaths{}, err
 		}
+		rec := transferRecord{
+			Sector:   s.ID,
+			FileType: fileType,
+			Dest:     dest,
+			Temp:     temp,
+			Storage:  ID(storageID),
+			Move:     op == storiface.AcquireMove,
+		}
+		r.putTransfer(rec)
+
+		url, err := r.acquireFromRemote(ctx, s.ID, fileType, dest, ID(storageID))
+		if err != nil {
+			r.cleanupTransfer(rec)
+			return storiface.SectorPaths{}, storiface.SectorPaths{}, err
+		}
+
+		rec.Source = url
+		rec.Fetched = true
+		r.putTransfer(rec)
 
 		storiface.SetPathByType(&paths, fileType, dest)
 		storiface.SetPathByType(&stores, fileType, storageID)
 
 		if err := r.index.StorageDeclareSector(ctx, ID(storageID), s.ID, fileType, op == storiface.AcquireMove); err != nil {
+			// declared again on restart
 			log.Warnf("declaring sector %v in %s failed: %+v", s, storageID, err)
 			continue
 		}
@@ -155,6 +197,7 @@ func (r *Remote) AcquireSector(ctx context.Context, s storage.SectorRef, existin
 				log.Warnf("deleting sector %v from %s (delete %s): %+v", s, storageID, url, err)
 			}
 		}
+		r.endTransfer(rec)
 	}
 
 	return paths, stores, nil
@@ -172,7 +215,7 @@ func tempFetchDest(spath string, create bool) (string, error) {
 	return filepath.Join(tempdir, b), nil
 }
 
-func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType storiface.SectorFileType, dest string) (string, error) {
+func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType storiface.SectorFileType, dest string, destID ID) (string, error) {
 	si, err := r.index.StorageFindSector(ctx, s, fileType, 0, false)
 	if err != nil {
 		return "", err
@@ -200,7 +243,7 @@ func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType
 				return "", xerrors.Errorf("removing dest: %w", err)
 			}
 
-			err = r.fetch(ctx, url, tempDest)
+			err = r.fetch(ctx, url, tempDest, destID)
 			if err != nil {
 				merr = multierror.Append(merr, xerrors.Errorf("fetch error %s (storage %s) -> %s: %w", url, info.ID, tempDest, err))
 				continue
@@ -220,7 +263,7 @@ func (r *Remote) acquireFromRemote(ctx context.Context, s abi.SectorID, fileType
 	return "", xerrors.Errorf("failed to acquire sector %v from remote (tried %v): %w", s, si, merr)
 }
 
-func (r *Remote) fetch(ctx context.Context, url, outname string) error {
+func (r *Remote) fetch(ctx context.Context, url, outname string, destID ID) error {
 	log.Infof("Fetch %s -> %s", url, outname)
 
 	if len(r.limit) >= cap(r.limit) {
@@ -243,6 +286,13 @@ func (r *Remote) fetch(ctx context.Context, url, outname string) error {
 		return xerrors.Errorf("request: %w", err)
 	}
 	req.Header = r.auth
+	if tok, ok := fetchToken(ctx); ok {
+		req.Header = r.auth.Clone()
+		if req.Header == nil {
+			req.Header = http.Header{}
+		}
+		req.Header.Set(FetchTokenHeader, string(tok))
+	}
 	req = req.WithContext(ctx)
 
 	resp, err := http.DefaultClient.Do(req)
@@ -274,15 +324,17 @@ func (r *Remote) fetch(ctx context.Context, url, outname string) error {
 		return xerrors.Errorf("removing dest: %w", err)
 	}
 
+	body := PauseReader(ctx, r.local.throttle.Reader(ctx, destID, resp.Body))
+
 	switch mediatype {
 	case "application/x-tar":
-		return tarutil.ExtractTar(resp.Body, outname)
+		return tarutil.ExtractTar(body, outname)
 	case "application/octet-stream":
 		f, err := os.Create(outname)
 		if err != nil {
 			return err
 		}
-		_, err = io.CopyBuffer(f, resp.Body, make([]byte, CopyBuf))
+		_, err = io.CopyBuffer(f, body, make([]byte, CopyBuf))
 		if err != nil {
 			f.Close() // nolint
 			return err
@@ -330,6 +382,39 @@ func (r *Remote) Remove(ctx context.Context, sid abi.SectorID, typ storiface.Sec
 	return nil
 }
 
+// RemoveFrom removes the sector files from one storage path, leaving the other
+// copies in place. The primary copy can't be removed this way.
+func (r *Remote) RemoveFrom(ctx context.Context, sid abi.SectorID, typ storiface.SectorFileType, storage ID) error {
+	if bits.OnesCount(uint(typ)) != 1 {
+		return xerrors.New("delete expects one file type")
+	}
+
+	si, err := r.index.StorageFindSector(ctx, sid, typ, 0, false)
+	if err != nil {
+		return xerrors.Errorf("finding existing sector %d(t:%d) failed: %w", sid, typ, err)
+	}
+
+	for _, info := range si {
+		if info.ID != storage {
+			continue
+		}
+		if info.Primary {
+			return xerrors.Errorf("storage %s holds the primary copy of sector %d(t:%d)", storage, sid, typ)
+		}
+
+		for _, url := range info.URLs {
+			if err := r.deleteFromRemote(ctx, url); err != nil {
+				log.Warnf("remove %s: %+v", url, err)
+				continue
+			}
+			return nil
+		}
+		return xerrors.Errorf("couldn't remove sector %d(t:%d) from storage %s", sid, typ, storage)
+	}
+
+	return nil
+}
+
 func (r *Remote) deleteFromRemote(ctx context.Context, url string) error {
 	log.Infof("Delete %s", url)
 
@@ -0,0 +1,87 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/hashicorp/go-multierror"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// ReadRemoteRange copies a byte range of a sector file from the fetch
+// endpoint of storage holding it to the writer, without transferring the
+// whole file. The storage must be in allowed, if it's not empty.
+func (r *Remote) ReadRemoteRange(ctx context.Context, s abi.SectorID, ft storiface.SectorFileType, offset, size uint64, allowed []ID, sink io.Writer) error {
+	si, err := r.index.StorageFindSector(ctx, s, ft, 0, false)
+	if err != nil {
+		return xerrors.Errorf("finding %s of %s: %w", ft, storiface.SectorName(s), err)
+	}
+
+	var merr error
+	for _, info := range si {
+		if len(allowed) > 0 && !containsID(allowed, info.ID) {
+			continue
+		}
+
+		for _, url := range info.URLs {
+			n, err := r.readRange(ctx, url, offset, size, sink)
+			if err == nil {
+				return nil
+			}
+			if n > 0 {
+				// part of the range was written already
+				return xerrors.Errorf("reading range from %s: %w", url, err)
+			}
+			merr = multierror.Append(merr, xerrors.Errorf("reading range from %s: %w", url, err))
+		}
+	}
+
+	if merr == nil {
+		return xerrors.Errorf("no %s of %s to read from: %w", ft, storiface.SectorName(s), storiface.ErrSectorNotFound)
+	}
+	return merr
+}
+
+func (r *Remote) readRange(ctx context.Context, url string, offset, size uint64, sink io.Writer) (int64, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return 0, xerrors.Errorf("request: %w", err)
+	}
+	req.Header = r.auth.Clone()
+	if req.Header == nil {
+		req.Header = http.Header{}
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+size-1))
+	req = req.WithContext(ctx)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, xerrors.Errorf("do request: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, xerrors.Errorf("non-206 code: %d", resp.StatusCode)
+	}
+
+	n, err := io.CopyN(sink, resp.Body, int64(size))
+	if err != nil {
+		return n, xerrors.Errorf("copying range: %w", err)
+	}
+	return n, nil
+}
+
+func containsID(ids []ID, id ID) bool {
+	for _, i := range ids {
+		if i == id {
+			return true
+		}
+	}
+	return false
+}
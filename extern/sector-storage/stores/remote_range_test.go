@@ -0,0 +1,49 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestReadRemoteRange(t *testing.T) {
+	ctx := context.Background()
+
+	data := []byte("0123456789abcdef")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasSuffix(r.URL.Path, "/unsealed/s-t01000-1") {
+			w.WriteHeader(404)
+			return
+		}
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	idx := NewIndex()
+	require.NoError(t, idx.StorageAttach(ctx, StorageInfo{ID: "appliance", URLs: []string{srv.URL + "/remote"}, CanSeal: true}, fsutil.FsStat{Capacity: pathSize, Available: pathSize}))
+
+	sid := abi.SectorID{Miner: 1000, Number: 1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, "appliance", sid, storiface.FTUnsealed, true))
+
+	r := &Remote{index: idx}
+
+	var out bytes.Buffer
+	require.NoError(t, r.ReadRemoteRange(ctx, sid, storiface.FTUnsealed, 4, 6, nil, &out))
+	require.Equal(t, "456789", out.String())
+
+	// storage which isn't allowed isn't read from
+	out.Reset()
+	require.Error(t, r.ReadRemoteRange(ctx, sid, storiface.FTUnsealed, 4, 6, []ID{"other"}, &out))
+	require.Empty(t, out.Bytes())
+}
@@ -0,0 +1,84 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// SetSharedPaths registers storage paths of other nodes which are also
+// mounted locally, e.g. through a network filesystem. Each path must contain
+// the sectorstore.json of the storage it mounts. Sector files found on shared
+// paths are used in place, without being transferred, when they're only
+// copied to long-term storage; they are fetched as usual when moved, or when
+// acquired for sealing, which modifies them.
+func (r *Remote) SetSharedPaths(paths []string) error {
+	shared := map[ID]string{}
+	for _, p := range paths {
+		mb, err := ioutil.ReadFile(filepath.Join(p, MetaFile))
+		if err != nil {
+			return xerrors.Errorf("reading storage metadata for shared path %s: %w", p, err)
+		}
+
+		var meta LocalStorageMeta
+		if err := json.Unmarshal(mb, &meta); err != nil {
+			return xerrors.Errorf("unmarshalling storage metadata for shared path %s: %w", p, err)
+		}
+
+		shared[meta.ID] = p
+	}
+
+	r.sharedLk.Lock()
+	r.shared = shared
+	r.sharedLk.Unlock()
+
+	return nil
+}
+
+// sharedInPlace returns whether files on shared paths can be used in place
+// for the acquire
+func sharedInPlace(pathType storiface.PathType, op storiface.AcquireMode) bool {
+	return op == storiface.AcquireCopy && pathType == storiface.PathStorage
+}
+
+// findShared looks for the sector file on storage mounted as a shared path,
+// returning the local path to the file and the ID of the storage holding it
+func (r *Remote) findShared(ctx context.Context, s abi.SectorID, fileType storiface.SectorFileType) (string, ID, error) {
+	r.sharedLk.Lock()
+	shared := r.shared
+	r.sharedLk.Unlock()
+
+	if len(shared) == 0 {
+		return "", "", nil
+	}
+
+	si, err := r.index.StorageFindSector(ctx, s, fileType, 0, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, info := range si {
+		mount, ok := shared[info.ID]
+		if !ok {
+			continue
+		}
+
+		p := filepath.Join(mount, fileType.String(), storiface.SectorName(s))
+		if _, err := os.Stat(p); err != nil {
+			log.Warnf("sector %v (%s) is indexed in shared storage %s, but not accessible at %s: %+v", s, fileType, info.ID, p, err)
+			continue
+		}
+
+		return p, info.ID, nil
+	}
+
+	return "", "", nil
+}
@@ -0,0 +1,72 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestFindShared(t *testing.T) {
+	ctx := context.Background()
+
+	mount, err := ioutil.TempDir("", "lotus-shared-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(mount) // nolint
+
+	id := ID(uuid.New().String())
+	b, err := json.Marshal(&LocalStorageMeta{ID: id, CanSeal: true})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(mount, MetaFile), b, 0644))
+
+	idx := NewIndex()
+	require.NoError(t, idx.StorageAttach(ctx, StorageInfo{ID: id, URLs: []string{"http://miner/remote"}, CanSeal: true}, fsutil.FsStat{Capacity: pathSize, Available: pathSize}))
+
+	sid := abi.SectorID{Miner: 1000, Number: 1}
+	require.NoError(t, idx.StorageDeclareSector(ctx, id, sid, storiface.FTSealed, true))
+
+	r := &Remote{index: idx}
+
+	// no shared paths configured
+	p, _, err := r.findShared(ctx, sid, storiface.FTSealed)
+	require.NoError(t, err)
+	require.Empty(t, p)
+
+	require.NoError(t, r.SetSharedPaths([]string{mount}))
+
+	// indexed, but the file isn't there
+	p, _, err = r.findShared(ctx, sid, storiface.FTSealed)
+	require.NoError(t, err)
+	require.Empty(t, p)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(mount, storiface.FTSealed.String()), 0755))
+	expect := filepath.Join(mount, storiface.FTSealed.String(), storiface.SectorName(sid))
+	require.NoError(t, ioutil.WriteFile(expect, []byte("sealed"), 0644))
+
+	p, foundID, err := r.findShared(ctx, sid, storiface.FTSealed)
+	require.NoError(t, err)
+	require.Equal(t, expect, p)
+	require.Equal(t, id, foundID)
+
+	// not indexed
+	p, _, err = r.findShared(ctx, sid, storiface.FTCache)
+	require.NoError(t, err)
+	require.Empty(t, p)
+}
+
+func TestSharedInPlace(t *testing.T) {
+	require.True(t, sharedInPlace(storiface.PathStorage, storiface.AcquireCopy))
+	require.False(t, sharedInPlace(storiface.PathStorage, storiface.AcquireMove))
+	require.False(t, sharedInPlace(storiface.PathSealing, storiface.AcquireCopy))
+	require.False(t, sharedInPlace(storiface.PathSealing, storiface.AcquireMove))
+}
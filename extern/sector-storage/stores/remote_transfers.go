@@ -0,0 +1,162 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// transferRecord is a sector file fetch in progress. Records are kept in the
+// datastore once PersistTransfers is called, so that fetches interrupted by a
+// restart are finished or cleaned up, instead of leaving partial files around.
+type transferRecord struct {
+	Sector   abi.SectorID
+	FileType storiface.SectorFileType
+
+	Dest    string // where the file is fetched to
+	Temp    string // where the file is downloaded before it's moved to Dest
+	Storage ID     // storage of Dest
+
+	// Source is the URL the file was fetched from, removed there once the
+	// file is declared when Move is set
+	Source string
+	Move   bool
+
+	// Fetched is set once the file is complete at Dest; only declaring it
+	// and removing the source are left
+	Fetched bool
+}
+
+func transferKey(s abi.SectorID, ft storiface.SectorFileType) datastore.Key {
+	return datastore.NewKey(storiface.SectorName(s)).ChildString(ft.String())
+}
+
+// PersistTransfers keeps records of sector file fetches in progress in the
+// datastore. Fetches which were interrupted by a restart are dealt with first:
+// files which were completely fetched are declared and their source removed,
+// as the fetch would have; partially fetched files are removed, and fetched
+// again when the sector needs them.
+func (r *Remote) PersistTransfers(ctx context.Context, ds datastore.Datastore) error {
+	res, err := ds.Query(query.Query{})
+	if err != nil {
+		return xerrors.Errorf("querying sector transfers: %w", err)
+	}
+
+	var recs []transferRecord
+	for e := range res.Next() {
+		if e.Error != nil {
+			_ = res.Close()
+			return xerrors.Errorf("reading sector transfers: %w", e.Error)
+		}
+
+		var rec transferRecord
+		if err := json.Unmarshal(e.Value, &rec); err != nil {
+			_ = res.Close()
+			return xerrors.Errorf("decoding sector transfer %s: %w", e.Key, err)
+		}
+		recs = append(recs, rec)
+	}
+	if err := res.Close(); err != nil {
+		return xerrors.Errorf("closing sector transfers query: %w", err)
+	}
+
+	r.transfersLk.Lock()
+	r.transfers = ds
+	r.transfersLk.Unlock()
+
+	for _, rec := range recs {
+		r.recoverTransfer(ctx, rec)
+	}
+
+	if len(recs) > 0 {
+		log.Infow("recovered interrupted sector transfers", "transfers", len(recs))
+	}
+	return nil
+}
+
+// recoverTransfer finishes or cleans up a fetch interrupted by a restart
+func (r *Remote) recoverTransfer(ctx context.Context, rec transferRecord) {
+	name := storiface.SectorName(rec.Sector)
+
+	if !rec.Fetched {
+		log.Warnw("removing partially fetched sector file", "sector", name, "type", rec.FileType, "path", rec.Dest)
+		r.cleanupTransfer(rec)
+		return
+	}
+
+	if _, err := os.Stat(rec.Dest); err != nil {
+		log.Errorw("fetched sector file is gone", "sector", name, "type", rec.FileType, "path", rec.Dest, "error", err)
+		r.endTransfer(rec)
+		return
+	}
+
+	if err := r.index.StorageDeclareSector(ctx, rec.Storage, rec.Sector, rec.FileType, rec.Move); err != nil {
+		// kept for the next restart
+		log.Errorw("declaring fetched sector file", "sector", name, "type", rec.FileType, "storage", rec.Storage, "error", err)
+		return
+	}
+
+	if rec.Move && rec.Source != "" {
+		if err := r.deleteFromRemote(ctx, rec.Source); err != nil {
+			log.Warnf("deleting sector %s from %s after restart: %+v", name, rec.Source, err)
+		}
+	}
+
+	r.endTransfer(rec)
+}
+
+// putTransfer records the progress of a fetch, if transfers are persisted
+func (r *Remote) putTransfer(rec transferRecord) {
+	r.transfersLk.Lock()
+	defer r.transfersLk.Unlock()
+
+	if r.transfers == nil {
+		return
+	}
+
+	b, err := json.Marshal(&rec)
+	if err != nil {
+		log.Errorf("encoding sector transfer: %+v", err)
+		return
+	}
+	if err := r.transfers.Put(transferKey(rec.Sector, rec.FileType), b); err != nil {
+		log.Errorf("writing sector transfer of %s: %+v", storiface.SectorName(rec.Sector), err)
+	}
+}
+
+// endTransfer removes the record of a fetch which is done
+func (r *Remote) endTransfer(rec transferRecord) {
+	r.transfersLk.Lock()
+	defer r.transfersLk.Unlock()
+
+	if r.transfers == nil {
+		return
+	}
+
+	if err := r.transfers.Delete(transferKey(rec.Sector, rec.FileType)); err != nil {
+		log.Errorf("removing sector transfer of %s: %+v", storiface.SectorName(rec.Sector), err)
+	}
+}
+
+// cleanupTransfer removes the partial files of a fetch which didn't complete
+func (r *Remote) cleanupTransfer(rec transferRecord) {
+	for _, p := range []string{rec.Temp, rec.Dest} {
+		if p == "" {
+			continue
+		}
+		if err := os.RemoveAll(p); err != nil {
+			log.Errorf("removing partially fetched %s: %+v", p, err)
+			return // kept for the next restart
+		}
+	}
+
+	r.endTransfer(rec)
+}
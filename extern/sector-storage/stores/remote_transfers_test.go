@@ -0,0 +1,89 @@
+package stores
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ipfs/go-datastore"
+	"github.com/ipfs/go-datastore/query"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/fsutil"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestRecoverTransfers(t *testing.T) {
+	ctx := context.Background()
+
+	dir, err := ioutil.TempDir("", "lotus-transfers-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	idx := NewIndex()
+	require.NoError(t, idx.StorageAttach(ctx, StorageInfo{ID: "local", URLs: []string{"http://worker/remote"}, CanSeal: true}, fsutil.FsStat{Capacity: pathSize, Available: pathSize}))
+
+	file := func(name string) string {
+		p := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte("data"), 0644))
+		return p
+	}
+	put := func(ds datastore.Datastore, rec transferRecord) {
+		b, err := json.Marshal(&rec)
+		require.NoError(t, err)
+		require.NoError(t, ds.Put(transferKey(rec.Sector, rec.FileType), b))
+	}
+
+	ds := datastore.NewMapDatastore()
+
+	// interrupted while downloading
+	partial := transferRecord{
+		Sector:   abi.SectorID{Miner: 1000, Number: 1},
+		FileType: storiface.FTSealed,
+		Dest:     filepath.Join(dir, "sealed", "s-t01000-1"),
+		Temp:     file("sealed/fetching/s-t01000-1"),
+		Storage:  "local",
+	}
+	put(ds, partial)
+
+	// interrupted after the file was moved in place
+	fetched := transferRecord{
+		Sector:   abi.SectorID{Miner: 1000, Number: 2},
+		FileType: storiface.FTCache,
+		Dest:     file("cache/s-t01000-2"),
+		Temp:     filepath.Join(dir, "cache", "fetching", "s-t01000-2"),
+		Storage:  "local",
+		Fetched:  true,
+	}
+	put(ds, fetched)
+
+	r := &Remote{index: idx}
+	require.NoError(t, r.PersistTransfers(ctx, ds))
+
+	// partial downloads are removed
+	_, err = os.Stat(partial.Temp)
+	require.True(t, os.IsNotExist(err))
+	found, err := idx.StorageFindSector(ctx, partial.Sector, storiface.FTSealed, 0, false)
+	require.NoError(t, err)
+	require.Empty(t, found)
+
+	// complete files are kept and declared
+	_, err = os.Stat(fetched.Dest)
+	require.NoError(t, err)
+	found, err = idx.StorageFindSector(ctx, fetched.Sector, storiface.FTCache, 0, false)
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	require.Equal(t, ID("local"), found[0].ID)
+
+	res, err := ds.Query(query.Query{KeysOnly: true})
+	require.NoError(t, err)
+	left, err := res.Rest()
+	require.NoError(t, err)
+	require.Empty(t, left)
+}
@@ -0,0 +1,188 @@
+package stores
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// minThrottleBurst keeps reads through a throttled reader reasonably sized
+// with very low limits
+const minThrottleBurst = 64 << 10
+
+// ProvingThrottle slows down sector transfers to and from storage paths which
+// are being read by a running PoSt, so that sealing data movement doesn't
+// starve proving reads. Transfers continue at full speed once proving is done.
+//
+// A nil *ProvingThrottle doesn't throttle anything.
+type ProvingThrottle struct {
+	limiter *rate.Limiter // nil = pause transfers while proving
+
+	lk      sync.Mutex
+	proving map[ID]int
+	changed chan struct{} // closed when the set of proving paths changes
+}
+
+// NewProvingThrottle creates a throttle limiting transfers on proving paths
+// to bytesPerSec; with a negative limit, transfers are paused until proving
+// is done, with 0 they're not throttled (and nil is returned).
+func NewProvingThrottle(bytesPerSec int64) *ProvingThrottle {
+	if bytesPerSec == 0 {
+		return nil
+	}
+
+	t := &ProvingThrottle{
+		proving: map[ID]int{},
+		changed: make(chan struct{}),
+	}
+	if bytesPerSec > 0 {
+		burst := int(bytesPerSec)
+		if burst < minThrottleBurst {
+			burst = minThrottleBurst
+		}
+		t.limiter = rate.NewLimiter(rate.Limit(bytesPerSec), burst)
+	}
+	return t
+}
+
+// Begin marks the storage paths as being proven, until the returned func is
+// called
+func (t *ProvingThrottle) Begin(ids []ID) func() {
+	if t == nil || len(ids) == 0 {
+		return func() {}
+	}
+
+	t.lk.Lock()
+	for _, id := range ids {
+		t.proving[id]++
+	}
+	t.notifyLocked()
+	t.lk.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			t.lk.Lock()
+			defer t.lk.Unlock()
+
+			for _, id := range ids {
+				t.proving[id]--
+				if t.proving[id] <= 0 {
+					delete(t.proving, id)
+				}
+			}
+			t.notifyLocked()
+		})
+	}
+}
+
+func (t *ProvingThrottle) notifyLocked() {
+	close(t.changed)
+	t.changed = make(chan struct{})
+}
+
+// state returns whether the path is being proven, and a channel which is
+// closed when that may have changed
+func (t *ProvingThrottle) state(id ID) (bool, <-chan struct{}) {
+	t.lk.Lock()
+	defer t.lk.Unlock()
+
+	return t.proving[id] > 0, t.changed
+}
+
+// Reader throttles reads from r while the storage path is being proven
+func (t *ProvingThrottle) Reader(ctx context.Context, id ID, r io.Reader) io.Reader {
+	if t == nil {
+		return r
+	}
+	return &throttledReader{ctx: ctx, t: t, id: id, r: r}
+}
+
+type throttledReader struct {
+	ctx context.Context
+	t   *ProvingThrottle
+	id  ID
+	r   io.Reader
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	for {
+		proving, changed := tr.t.state(tr.id)
+		if !proving {
+			return tr.r.Read(p)
+		}
+
+		if tr.t.limiter == nil {
+			select {
+			case <-changed:
+				continue
+			case <-tr.ctx.Done():
+				return 0, tr.ctx.Err()
+			}
+		}
+
+		if len(p) > tr.t.limiter.Burst() {
+			p = p[:tr.t.limiter.Burst()]
+		}
+		n, err := tr.r.Read(p)
+		if n > 0 {
+			if werr := tr.t.limiter.WaitN(tr.ctx, n); werr != nil {
+				return n, werr
+			}
+		}
+		return n, err
+	}
+}
+
+type pauseKey struct{}
+
+// WithPause makes sector transfers done with the returned context wait, between
+// reads, while paused returns a channel, until that channel is closed. The
+// scheduler uses it to suspend running low-priority tasks during urgent work.
+func WithPause(ctx context.Context, paused func() <-chan struct{}) context.Context {
+	return context.WithValue(ctx, pauseKey{}, paused)
+}
+
+// PauseReader has reads from r wait while the transfer is paused with
+// WithPause
+func PauseReader(ctx context.Context, r io.Reader) io.Reader {
+	paused, ok := ctx.Value(pauseKey{}).(func() <-chan struct{})
+	if !ok {
+		return r
+	}
+	return &pausedReader{ctx: ctx, paused: paused, r: r}
+}
+
+type pausedReader struct {
+	ctx    context.Context
+	paused func() <-chan struct{}
+	r      io.Reader
+}
+
+func (pr *pausedReader) Read(p []byte) (int, error) {
+	for {
+		resume := pr.paused()
+		if resume == nil {
+			return pr.r.Read(p)
+		}
+
+		select {
+		case <-resume:
+		case <-pr.ctx.Done():
+			return 0, pr.ctx.Err()
+		}
+	}
+}
+
+// SetProvingThrottle sets the throttle applied to sector transfers to and from
+// the local paths; it must be set before transfers start
+func (st *Local) SetProvingThrottle(t *ProvingThrottle) {
+	st.throttle = t
+}
+
+// ProvingThrottle returns the throttle set with SetProvingThrottle, if any
+func (st *Local) ProvingThrottle() *ProvingThrottle {
+	return st.throttle
+}
@@ -0,0 +1,123 @@
+package stores
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvingThrottlePause(t *testing.T) {
+	ctx := context.Background()
+	th := NewProvingThrottle(-1)
+	data := []byte("sector data")
+
+	// other paths aren't affected
+	done := th.Begin([]ID{"proving"})
+	b, err := ioutil.ReadAll(th.Reader(ctx, "other", bytes.NewReader(data)))
+	require.NoError(t, err)
+	require.Equal(t, data, b)
+
+	read := make(chan []byte)
+	go func() {
+		b, err := ioutil.ReadAll(th.Reader(ctx, "proving", bytes.NewReader(data)))
+		require.NoError(t, err)
+		read <- b
+	}()
+
+	select {
+	case <-read:
+		t.Fatal("read from a path being proven")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	done()
+	select {
+	case b := <-read:
+		require.Equal(t, data, b)
+	case <-time.After(time.Second):
+		t.Fatal("read didn't resume after proving")
+	}
+}
+
+func TestProvingThrottleLimit(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	th := NewProvingThrottle(minThrottleBurst)
+	defer th.Begin([]ID{"proving"})()
+
+	// the first burst is let through right away, the rest at the limit
+	rd := th.Reader(ctx, "proving", bytes.NewReader(make([]byte, 3*minThrottleBurst)))
+	buf := make([]byte, 4*minThrottleBurst)
+	n, err := rd.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, minThrottleBurst, n)
+
+	start := time.Now()
+	_, err = rd.Read(buf)
+	require.NoError(t, err)
+	require.True(t, time.Since(start) > 500*time.Millisecond, "read wasn't throttled")
+}
+
+func TestProvingThrottleNil(t *testing.T) {
+	th := NewProvingThrottle(0)
+	require.Nil(t, th)
+
+	th.Begin([]ID{"proving"})()
+	rd := bytes.NewReader(nil)
+	require.Equal(t, rd, th.Reader(context.Background(), "proving", rd))
+}
+
+func TestPauseReader(t *testing.T) {
+	data := []byte("sector data")
+
+	var lk sync.Mutex
+	var resume chan struct{}
+	ctx := WithPause(context.Background(), func() <-chan struct{} {
+		lk.Lock()
+		defer lk.Unlock()
+		return resume
+	})
+
+	// not paused
+	b, err := ioutil.ReadAll(PauseReader(ctx, bytes.NewReader(data)))
+	require.NoError(t, err)
+	require.Equal(t, data, b)
+
+	lk.Lock()
+	resume = make(chan struct{})
+	lk.Unlock()
+
+	read := make(chan []byte)
+	go func() {
+		b, err := ioutil.ReadAll(PauseReader(ctx, bytes.NewReader(data)))
+		require.NoError(t, err)
+		read <- b
+	}()
+
+	select {
+	case <-read:
+		t.Fatal("read from a paused transfer")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	lk.Lock()
+	close(resume)
+	resume = nil
+	lk.Unlock()
+	select {
+	case b := <-read:
+		require.Equal(t, data, b)
+	case <-time.After(time.Second):
+		t.Fatal("read didn't resume")
+	}
+
+	// contexts without a pause aren't wrapped
+	r := bytes.NewReader(data)
+	require.Equal(t, r, PauseReader(context.Background(), r))
+}
@@ -5,11 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
 
+	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
 	"github.com/filecoin-project/specs-storage/storage"
 
@@ -20,6 +22,13 @@ type WorkerInfo struct {
 	Hostname string
 
 	Resources WorkerResources
+
+	// Benchmark has the scores of the worker's preflight benchmark, higher
+	// is faster; empty if the worker didn't run it
+	Benchmark map[sealtasks.TaskType]float64 `json:",omitempty"`
+
+	// Version of the sealing software, if the worker reports it
+	Version string `json:",omitempty"`
 }
 
 type WorkerResources struct {
@@ -30,8 +39,20 @@ type WorkerResources struct {
 
 	CPUs uint64 // Logical cores
 	GPUs []string
+
+	NUMANodes []NUMANode `json:",omitempty"` // empty if the topology isn't known
 }
 
+// NUMANode is a NUMA node of a worker machine
+type NUMANode struct {
+	ID          int
+	CPUs        uint64 // Logical cores
+	MemPhysical uint64
+}
+
+// NoNUMANode is passed as the NUMA node for calls which aren't pinned to one
+const NoNUMANode = -1
+
 type WorkerStats struct {
 	Info    WorkerInfo
 	Enabled bool
@@ -62,6 +83,121 @@ type WorkerJob struct {
 	Start   time.Time
 
 	Hostname string `json:",omitempty"` // optional, set for ret-wait jobs
+
+	NUMANode *int `json:",omitempty"` // NUMA node the job is pinned to, if any
+
+	// State is the phase of the scheduled job the call was made for, and
+	// History lists when the job entered each phase; both are empty for calls
+	// not made by the scheduler, e.g. restored after a restart
+	State   JobState       `json:",omitempty"`
+	History []JobStateTime `json:",omitempty"`
+}
+
+// JobState is the phase of a job scheduled on a worker, telling time spent
+// moving data apart from time spent computing
+type JobState string
+
+const (
+	JobQueued          JobState = "queued"           // waiting for a worker
+	JobTransferringIn  JobState = "transferring-in"  // fetching its inputs to the worker
+	JobComputing       JobState = "computing"        // running the task
+	JobTransferringOut JobState = "transferring-out" // moving its outputs to storage
+	JobDone            JobState = "done"
+)
+
+// JobStateTime is the time a job entered a state
+type JobStateTime struct {
+	State JobState
+	Time  time.Time
+}
+
+// DispatchHealth is a point-in-time summary of the manager's call dispatch to
+// workers, meant for operators who don't run a metrics stack
+type DispatchHealth struct {
+	Since time.Time // start of the stats collection period
+
+	Queued  map[sealtasks.TaskType]int // scheduler queue depth by task type
+	Workers map[uuid.UUID]WorkerHealth
+
+	RecentFailures []DispatchFailure // newest first
+}
+
+type WorkerHealth struct {
+	Hostname string
+	Enabled  bool
+
+	Running  int // calls in progress on the worker
+	Assigned int // calls assigned to the worker but not started yet
+
+	Done   map[sealtasks.TaskType]uint64
+	Failed map[sealtasks.TaskType]uint64
+}
+
+type DispatchFailure struct {
+	Call   CallID
+	Worker uuid.UUID
+	Task   sealtasks.TaskType
+	Time   time.Time
+	Error  string
+}
+
+// SealingStats are long-term sealing statistics, kept in the miner datastore
+// across restarts
+type SealingStats struct {
+	Since time.Time // start of the stats collection
+
+	// Phases are histograms of the time jobs spent in each state, by task type
+	Phases map[sealtasks.TaskType]map[JobState]DurationHistogram
+	// Workers are call outcomes by worker hostname and task type
+	Workers map[string]map[sealtasks.TaskType]CallOutcomes
+	// Throughput is the number of calls completed per hour, oldest first
+	Throughput []ThroughputSample
+}
+
+// SealRecord holds the proof parameters a sector was sealed with, and the
+// workers which ran its sealing calls, kept in the miner datastore for audits
+// and for later operations on the sector, such as upgrades
+type SealRecord struct {
+	Sector abi.SectorID
+
+	ProofType        abi.RegisteredSealProof
+	SectorSize       abi.SectorSize
+	WindowPoStProof  abi.RegisteredPoStProof
+	WinningPoStProof abi.RegisteredPoStProof
+
+	// Calls are the sealing calls started for the sector, oldest first; a
+	// task retried after a failure is listed once per attempt
+	Calls []SealCall
+}
+
+type SealCall struct {
+	Task    sealtasks.TaskType
+	Worker  string // hostname
+	Version string // sealing software version reported by the worker, if any
+	Started time.Time
+}
+
+type DurationHistogram struct {
+	Bounds []time.Duration // upper bounds of the buckets, the last bucket is unbounded
+	Counts []uint64        // one more than Bounds
+	Sum    time.Duration
+}
+
+type CallOutcomes struct {
+	Done   uint64
+	Failed uint64
+}
+
+type ThroughputSample struct {
+	Hour time.Time
+	Done map[sealtasks.TaskType]uint64
+}
+
+// SchedThrottle caps task starts across all workers, on top of worker
+// resource limits. Zero values mean no limit.
+type SchedThrottle struct {
+	Tasks    map[sealtasks.TaskType]int // max running tasks by task type
+	Dispatch int                        // max tasks started per minute
 }
 
 type CallID struct {
@@ -77,20 +213,42 @@ var _ fmt.Stringer = &CallID{}
 
 var UndefCall CallID
 
+// CallLogLine is a line of output written by a worker process while a call was
+// running on it. The output isn't attributed to calls more precisely, so when
+// calls run concurrently, lines will show up in the logs of all of them.
+type CallLogLine struct {
+	Time   time.Time
+	Stream string // "stdout" or "stderr"
+	Line   string
+}
+
+// PieceMeta is optional provenance of a piece passed to a sealing call, at the
+// index of the piece. It isn't used for sealing; workers log it, so that
+// sealers can report which deals they processed.
+type PieceMeta struct {
+	DealID abi.DealID      // 0 for pieces which aren't in a deal, e.g. filler pieces
+	Client address.Address // address.Undef if not known
+}
+
 type WorkerCalls interface {
 	AddPiece(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (CallID, error)
-	SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (CallID, error)
+	SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, meta []PieceMeta, numaNode int) (CallID, error)
 	SealPreCommit2(ctx context.Context, sector storage.SectorRef, pc1o storage.PreCommit1Out) (CallID, error)
-	SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (CallID, error)
+	SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids, meta []PieceMeta) (CallID, error)
 	SealCommit2(ctx context.Context, sector storage.SectorRef, c1o storage.Commit1Out) (CallID, error)
 	FinalizeSector(ctx context.Context, sector storage.SectorRef, keepUnsealed []storage.Range) (CallID, error)
 	ReleaseUnsealed(ctx context.Context, sector storage.SectorRef, safeToFree []storage.Range) (CallID, error)
 	MoveStorage(ctx context.Context, sector storage.SectorRef, types SectorFileType) (CallID, error)
 	UnsealPiece(context.Context, storage.SectorRef, UnpaddedByteIndex, abi.UnpaddedPieceSize, abi.SealRandomness, cid.Cid) (CallID, error)
 	ReadPiece(context.Context, io.Writer, storage.SectorRef, UnpaddedByteIndex, abi.UnpaddedPieceSize) (CallID, error)
-	Fetch(context.Context, storage.SectorRef, SectorFileType, PathType, AcquireMode) (CallID, error)
+	Fetch(context.Context, storage.SectorRef, SectorFileType, PathType, AcquireMode, FetchToken) (CallID, error)
 }
 
+// FetchToken is a short-lived credential issued by the miner for a single
+// Fetch call. It only allows reading that call's sector files from the
+// miner's storage HTTP handler. Empty when the miner didn't issue one.
+type FetchToken string
+
 type ErrorCode int
 
 const (
@@ -102,16 +260,29 @@ const (
 	ErrTempUnknown ErrorCode = iota + 100
 	ErrTempWorkerRestart
 	ErrTempAllocateSpace
+	ErrTempTimeout
+
+	// ErrTempReturnLater is returned by the manager for results it can't
+	// accept yet, e.g. while results of earlier phases of the sector are
+	// outstanding; workers return them again later
+	ErrTempReturnLater
 )
 
+const callErrorPrefix = "storage call error "
+
 type CallError struct {
 	Code    ErrorCode
 	Message string
-	sub     error
+
+	// Retryable is set for temporary errors, after which the call may succeed
+	// when made again
+	Retryable bool
+
+	sub error
 }
 
 func (c *CallError) Error() string {
-	return fmt.Sprintf("storage call error %d: %s", c.Code, c.Message)
+	return fmt.Sprintf(callErrorPrefix+"%d: %s", c.Code, c.Message)
 }
 
 func (c *CallError) Unwrap() error {
@@ -122,15 +293,43 @@ func (c *CallError) Unwrap() error {
 	return errors.New(c.Message)
 }
 
+// CallErrorCode returns the code of the CallError in the error chain. Errors
+// returned over RPC only keep their message, so the code is parsed from it
+// when the chain doesn't hold a CallError.
+func CallErrorCode(err error) (ErrorCode, bool) {
+	var cerr *CallError
+	if errors.As(err, &cerr) {
+		return cerr.Code, true
+	}
+
+	msg := err.Error()
+	i := strings.Index(msg, callErrorPrefix)
+	if i < 0 {
+		return ErrUnknown, false
+	}
+
+	var code ErrorCode
+	if _, err := fmt.Sscanf(msg[i+len(callErrorPrefix):], "%d:", &code); err != nil {
+		return ErrUnknown, false
+	}
+	return code, true
+}
+
 func Err(code ErrorCode, sub error) *CallError {
 	return &CallError{
-		Code:    code,
-		Message: sub.Error(),
+		Code:      code,
+		Message:   sub.Error(),
+		Retryable: code >= ErrTempUnknown,
 
 		sub: sub,
 	}
 }
 
+// ErrPreCommit2Mismatch is returned by the manager when a PreCommit2
+// verification run computed other commitments than the actual run. Retrying
+// doesn't help, one of the workers needs to be checked.
+var ErrPreCommit2Mismatch = errors.New("PreCommit2 results of independent workers differ")
+
 type WorkerReturn interface {
 	ReturnAddPiece(ctx context.Context, callID CallID, pi abi.PieceInfo, err *CallError) error
 	ReturnSealPreCommit1(ctx context.Context, callID CallID, p1o storage.PreCommit1Out, err *CallError) error
@@ -144,3 +343,27 @@ type WorkerReturn interface {
 	ReturnReadPiece(ctx context.Context, callID CallID, ok bool, err *CallError) error
 	ReturnFetch(ctx context.Context, callID CallID, err *CallError) error
 }
+
+type UnsealState string
+
+const (
+	UnsealQueued    UnsealState = "queued"
+	UnsealUnsealing UnsealState = "unsealing"
+	UnsealDone      UnsealState = "done"
+	UnsealFailed    UnsealState = "failed"
+)
+
+// UnsealJob tracks an unseal request for a byte range of a sector
+type UnsealJob struct {
+	ID     uuid.UUID
+	Sector abi.SectorID
+	Offset UnpaddedByteIndex
+	Size   abi.UnpaddedPieceSize
+
+	State UnsealState
+	Error string `json:",omitempty"`
+
+	Queued   time.Time
+	Started  time.Time
+	Finished time.Time
+}
@@ -0,0 +1,125 @@
+package sectorstorage
+
+import (
+	"container/list"
+	"context"
+	"sync"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// unsealedLRU tracks unsealed sector copies created on demand for retrievals,
+// and picks the least recently read ones for eviction once their total size
+// goes over the budget. Evicted sectors are simply unsealed again on the next
+// read.
+//
+// Only copies unsealed by ReadPiece are tracked, unsealed data kept after
+// sealing is never evicted. The state is in-memory, copies unsealed before a
+// restart are not tracked.
+type unsealedLRU struct {
+	lk sync.Mutex
+
+	budget uint64 // bytes, 0 = unlimited
+	used   uint64
+
+	order   *list.List // *unsealedEntry, front is most recently read
+	entries map[abi.SectorID]*list.Element
+}
+
+type unsealedEntry struct {
+	sector storage.SectorRef
+	size   uint64
+}
+
+func newUnsealedLRU(budget uint64) *unsealedLRU {
+	return &unsealedLRU{
+		budget: budget,
+
+		order:   list.New(),
+		entries: map[abi.SectorID]*list.Element{},
+	}
+}
+
+// touch marks a tracked sector as recently read
+func (u *unsealedLRU) touch(id abi.SectorID) {
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	if e, ok := u.entries[id]; ok {
+		u.order.MoveToFront(e)
+	}
+}
+
+// add starts tracking a freshly unsealed sector, and returns sectors which
+// should be evicted to get back under the budget. The sector being added is
+// never returned.
+func (u *unsealedLRU) add(sector storage.SectorRef, size uint64) []storage.SectorRef {
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	if e, ok := u.entries[sector.ID]; ok {
+		u.order.MoveToFront(e)
+	} else {
+		u.entries[sector.ID] = u.order.PushFront(&unsealedEntry{
+			sector: sector,
+			size:   size,
+		})
+		u.used += size
+	}
+
+	if u.budget == 0 {
+		return nil
+	}
+
+	var evict []storage.SectorRef
+	for u.used > u.budget && u.order.Len() > 1 {
+		ent := u.order.Remove(u.order.Back()).(*unsealedEntry)
+		delete(u.entries, ent.sector.ID)
+		u.used -= ent.size
+
+		evict = append(evict, ent.sector)
+	}
+
+	return evict
+}
+
+// remove stops tracking a sector, e.g. when it was removed from storage
+func (u *unsealedLRU) remove(id abi.SectorID) {
+	u.lk.Lock()
+	defer u.lk.Unlock()
+
+	e, ok := u.entries[id]
+	if !ok {
+		return
+	}
+
+	u.used -= u.order.Remove(e).(*unsealedEntry).size
+	delete(u.entries, id)
+}
+
+func (m *Manager) evictUnsealed(ctx context.Context, sectors []storage.SectorRef) {
+	for _, sector := range sectors {
+		if err := m.removeUnsealedCopy(ctx, sector); err != nil {
+			log.Errorf("evicting unsealed copy of sector %d: %+v", sector.ID.Number, err)
+			continue
+		}
+
+		log.Infow("evicted cold unsealed sector copy", "sector", sector.ID)
+	}
+}
+
+func (m *Manager) removeUnsealedCopy(ctx context.Context, sector storage.SectorRef) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err := m.index.StorageLock(ctx, sector.ID, storiface.FTNone, storiface.FTUnsealed); err != nil {
+		return xerrors.Errorf("acquiring sector lock: %w", err)
+	}
+
+	return m.storage.Remove(ctx, sector.ID, storiface.FTUnsealed, true)
+}
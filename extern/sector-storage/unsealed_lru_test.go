@@ -0,0 +1,34 @@
+package sectorstorage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+)
+
+func TestUnsealedLRU(t *testing.T) {
+	sector := func(n abi.SectorNumber) storage.SectorRef {
+		return storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: n}}
+	}
+
+	u := newUnsealedLRU(3 << 10)
+
+	require.Empty(t, u.add(sector(1), 1<<10))
+	require.Empty(t, u.add(sector(2), 1<<10))
+	require.Empty(t, u.add(sector(3), 1<<10))
+
+	// 1 becomes the most recently read, 2 is now the coldest
+	u.touch(sector(1).ID)
+
+	require.Equal(t, []storage.SectorRef{sector(2)}, u.add(sector(4), 1<<10))
+
+	u.remove(sector(3).ID)
+	require.Empty(t, u.add(sector(5), 1<<10))
+
+	// a single copy over the budget is kept, everything else goes
+	require.Equal(t, []storage.SectorRef{sector(1), sector(4), sector(5)}, u.add(sector(6), 4<<10))
+	require.Equal(t, uint64(4<<10), u.used)
+}
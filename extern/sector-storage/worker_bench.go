@@ -0,0 +1,85 @@
+package sectorstorage
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper/basicfs"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/lib/nullreader"
+)
+
+// BenchmarkProofType is the proof type of the sector sealed by the preflight
+// benchmark; its parameters have to be fetched before running it
+var BenchmarkProofType = abi.RegisteredSealProof_StackedDrg2KiBV1_1
+
+// PreflightBenchmark seals a small sector in dir, timing PreCommit2 and
+// Commit2. The scores are runs per second on that sector, they are only
+// meaningful when compared with scores of other workers.
+func PreflightBenchmark(ctx context.Context, dir string) (map[sealtasks.TaskType]float64, error) {
+	sb, err := ffiwrapper.New(&basicfs.Provider{Root: dir})
+	if err != nil {
+		return nil, xerrors.Errorf("creating sealer: %w", err)
+	}
+
+	ssize, err := BenchmarkProofType.SectorSize()
+	if err != nil {
+		return nil, err
+	}
+
+	sector := storage.SectorRef{
+		ID:        abi.SectorID{Miner: 1000, Number: 1},
+		ProofType: BenchmarkProofType,
+	}
+	ticket := abi.SealRandomness(make([]byte, 32))
+	seed := abi.InteractiveSealRandomness(make([]byte, 32))
+
+	size := abi.PaddedPieceSize(ssize).Unpadded()
+	pi, err := sb.AddPiece(ctx, sector, nil, size, io.LimitReader(&nullreader.Reader{}, int64(size)))
+	if err != nil {
+		return nil, xerrors.Errorf("add piece: %w", err)
+	}
+	pieces := []abi.PieceInfo{pi}
+
+	p1o, err := sb.SealPreCommit1(ctx, sector, ticket, pieces)
+	if err != nil {
+		return nil, xerrors.Errorf("precommit1: %w", err)
+	}
+
+	start := time.Now()
+	cids, err := sb.SealPreCommit2(ctx, sector, p1o)
+	if err != nil {
+		return nil, xerrors.Errorf("precommit2: %w", err)
+	}
+	pc2 := time.Since(start)
+
+	c1o, err := sb.SealCommit1(ctx, sector, ticket, seed, pieces, cids)
+	if err != nil {
+		return nil, xerrors.Errorf("commit1: %w", err)
+	}
+
+	start = time.Now()
+	if _, err := sb.SealCommit2(ctx, sector, c1o); err != nil {
+		return nil, xerrors.Errorf("commit2: %w", err)
+	}
+	c2 := time.Since(start)
+
+	return map[sealtasks.TaskType]float64{
+		sealtasks.TTPreCommit2: benchScore(pc2),
+		sealtasks.TTCommit2:    benchScore(c2),
+	}, nil
+}
+
+func benchScore(d time.Duration) float64 {
+	if d <= 0 {
+		d = time.Millisecond
+	}
+	return float64(time.Second) / float64(d)
+}
@@ -0,0 +1,42 @@
+package sectorstorage
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestLessUtilizedBenchmark(t *testing.T) {
+	handle := func(cpuUse uint64, bench map[sealtasks.TaskType]float64) *workerHandle {
+		return &workerHandle{
+			info: storiface.WorkerInfo{
+				Resources: storiface.WorkerResources{
+					MemPhysical: 64 << 30,
+					CPUs:        8,
+				},
+				Benchmark: bench,
+			},
+			preparing: &activeResources{},
+			active:    &activeResources{cpuUse: cpuUse},
+		}
+	}
+
+	fast := handle(4, map[sealtasks.TaskType]float64{sealtasks.TTPreCommit2: 4})
+	slow := handle(0, map[sealtasks.TaskType]float64{sealtasks.TTPreCommit2: 1})
+	unscored := handle(0, nil)
+
+	// half-busy but 4x faster wins over idle
+	require.True(t, lessUtilized(sealtasks.TTPreCommit2, fast, slow))
+	require.False(t, lessUtilized(sealtasks.TTPreCommit2, slow, fast))
+
+	// without scores for the task, only utilization counts
+	require.True(t, lessUtilized(sealtasks.TTCommit2, slow, fast))
+	require.True(t, lessUtilized(sealtasks.TTPreCommit2, unscored, fast))
+
+	// idle workers are ordered by score
+	idleFast := handle(0, map[sealtasks.TaskType]float64{sealtasks.TTPreCommit2: 4})
+	require.True(t, lessUtilized(sealtasks.TTPreCommit2, idleFast, slow))
+}
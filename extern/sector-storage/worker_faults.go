@@ -0,0 +1,110 @@
+package sectorstorage
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// FaultConfig injects failures into the results a worker returns to the
+// manager, for chaos testing the return retry and result dedup logic. Rates
+// are fractions of returns, from 0 to 1. For tests only, production workers
+// must leave it unset.
+type FaultConfig struct {
+	// Drop fails the return before it is sent, the worker retries it
+	Drop float64
+	// Delay holds the return back for a random duration up to MaxDelay
+	Delay    float64
+	MaxDelay time.Duration
+	// Duplicate sends the return a second time after it was accepted
+	Duplicate float64
+	// Corrupt flips bits in the call ID of the return, so that the manager
+	// gets a result for an unknown call, and the real result is lost
+	Corrupt float64
+
+	// Seed makes the injected faults reproducible
+	Seed int64
+}
+
+var errFaultDropped = xerrors.New("injected fault: return dropped")
+
+type faultInjector struct {
+	cfg FaultConfig
+
+	lk  sync.Mutex
+	rnd *rand.Rand
+}
+
+func newFaultInjector(cfg *FaultConfig) *faultInjector {
+	if cfg == nil {
+		return nil
+	}
+
+	return &faultInjector{
+		cfg: *cfg,
+		rnd: rand.New(rand.NewSource(cfg.Seed)),
+	}
+}
+
+func (f *faultInjector) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return f.rnd.Float64() < rate
+}
+
+func (f *faultInjector) delay() time.Duration {
+	if f.cfg.MaxDelay <= 0 {
+		return 0
+	}
+
+	f.lk.Lock()
+	defer f.lk.Unlock()
+	return time.Duration(f.rnd.Int63n(int64(f.cfg.MaxDelay)))
+}
+
+// send calls send with the call ID, injecting faults. A nil injector sends
+// the return as is.
+func (f *faultInjector) send(ctx context.Context, ci storiface.CallID, send func(storiface.CallID) error) error {
+	if f == nil {
+		return send(ci)
+	}
+
+	if f.roll(f.cfg.Drop) {
+		return errFaultDropped
+	}
+
+	if f.roll(f.cfg.Delay) {
+		select {
+		case <-time.After(f.delay()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if f.roll(f.cfg.Corrupt) {
+		log.Warnw("injected fault: corrupting return call ID", "call", ci)
+		ci.ID[0] ^= 0xff
+	}
+
+	if err := send(ci); err != nil {
+		return err
+	}
+
+	if f.roll(f.cfg.Duplicate) {
+		// the manager is expected to reject it, the first return was accepted
+		if err := send(ci); err != nil {
+			log.Debugw("injected fault: duplicate return rejected", "call", ci, "error", err)
+		}
+	}
+
+	return nil
+}
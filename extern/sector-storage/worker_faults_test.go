@@ -0,0 +1,118 @@
+package sectorstorage
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-datastore"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-statestore"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestFaultInjector(t *testing.T) {
+	ctx := context.Background()
+	ci := storiface.CallID{ID: uuid.New()}
+
+	var nilInjector *faultInjector
+	var sent []storiface.CallID
+	send := func(ci storiface.CallID) error {
+		sent = append(sent, ci)
+		return nil
+	}
+
+	require.NoError(t, nilInjector.send(ctx, ci, send))
+	require.Equal(t, []storiface.CallID{ci}, sent)
+
+	sent = nil
+	f := newFaultInjector(&FaultConfig{Drop: 1})
+	require.Equal(t, errFaultDropped, f.send(ctx, ci, send))
+	require.Empty(t, sent)
+
+	f = newFaultInjector(&FaultConfig{Duplicate: 1})
+	require.NoError(t, f.send(ctx, ci, send))
+	require.Equal(t, []storiface.CallID{ci, ci}, sent)
+
+	sent = nil
+	f = newFaultInjector(&FaultConfig{Corrupt: 1})
+	require.NoError(t, f.send(ctx, ci, send))
+	require.Len(t, sent, 1)
+	require.Equal(t, ci.Sector, sent[0].Sector)
+	require.NotEqual(t, ci.ID, sent[0].ID)
+
+	sent = nil
+	f = newFaultInjector(&FaultConfig{Delay: 1, MaxDelay: time.Hour})
+	cctx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.Error(t, f.send(cctx, ci, send))
+	require.Empty(t, sent)
+}
+
+func TestReturnFaults(t *testing.T) {
+	retry := ReturnRetryInterval
+	ReturnRetryInterval = 5 * time.Millisecond
+	defer func() {
+		ReturnRetryInterval = retry
+	}()
+
+	ctx, done := context.WithCancel(context.Background())
+	defer done()
+
+	m, lstor, stor, idx, cleanup := newTestMgr(ctx, t, datastore.NewMapDatastore())
+	defer cleanup()
+
+	arch := make(chan chan apres)
+	w := newLocalWorker(func() (ffiwrapper.Storage, error) {
+		return &testExec{apch: arch}, nil
+	}, WorkerConfig{
+		TaskTypes: []sealtasks.TaskType{sealtasks.TTAddPiece},
+		Faults: &FaultConfig{
+			Drop:      0.5,
+			Delay:     0.5,
+			MaxDelay:  20 * time.Millisecond,
+			Duplicate: 0.5,
+			Seed:      1,
+		},
+	}, stor, lstor, idx, m, statestore.New(datastore.NewMapDatastore()))
+	require.NoError(t, m.AddWorker(ctx, w))
+
+	go func() {
+		for {
+			select {
+			case res := <-arch:
+				res <- apres{pi: abi.PieceInfo{Size: 1024}}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// every call must complete exactly once, despite dropped, delayed and
+	// duplicated returns
+	for i := 1; i <= 10; i++ {
+		sid := storage.SectorRef{
+			ID:        abi.SectorID{Miner: 1000, Number: abi.SectorNumber(i)},
+			ProofType: abi.RegisteredSealProof_StackedDrg2KiBV1,
+		}
+
+		pi, err := m.AddPiece(ctx, sid, nil, 1016, strings.NewReader(strings.Repeat("testthis", 127)))
+		require.NoError(t, err)
+		require.Equal(t, abi.PaddedPieceSize(1024), pi.Size)
+	}
+
+	// calls are marked as returned after the manager accepted the result
+	require.Eventually(t, func() bool {
+		uf, err := w.ct.unfinished()
+		require.NoError(t, err)
+		return len(uf) == 0
+	}, time.Second, 5*time.Millisecond)
+}
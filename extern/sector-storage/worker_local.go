@@ -33,6 +33,22 @@ var pathTypes = []storiface.SectorFileType{storiface.FTUnsealed, storiface.FTSea
 type WorkerConfig struct {
 	TaskTypes []sealtasks.TaskType
 	NoSwap    bool
+
+	// NoNUMA hides the NUMA topology of the machine from the manager, so that
+	// calls aren't pinned to NUMA nodes; for workers pinned externally
+	NoNUMA bool
+
+	// Benchmark are the scores of the preflight benchmark, reported to the
+	// manager with the worker info
+	Benchmark map[sealtasks.TaskType]float64
+
+	// ScratchCleanupGrace enables removing PreCommit1 files of sectors without
+	// live calls on the worker, once they weren't modified for this long;
+	// 0 = don't remove
+	ScratchCleanupGrace time.Duration
+
+	// Faults injects failures into returns to the manager, test only
+	Faults *FaultConfig
 }
 
 // used do provide custom proofs impl (mostly used in testing)
@@ -45,6 +61,9 @@ type LocalWorker struct {
 	ret        storiface.WorkerReturn
 	executor   ExecutorFunc
 	noSwap     bool
+	noNUMA     bool
+	benchmark  map[sealtasks.TaskType]float64
+	faults     *faultInjector
 
 	ct          *workerCallTracker
 	acceptTasks map[sealtasks.TaskType]struct{}
@@ -54,6 +73,14 @@ type LocalWorker struct {
 	session     uuid.UUID
 	testDisable int64
 	closing     chan struct{}
+	managerAway int64 // set when the manager shuts down, until a result is returned
+
+	statusLk    sync.Mutex
+	active      map[storiface.CallID]LocalCall
+	recentErrs  []LocalCallError
+	recentCalls []finishedCall
+
+	outputLog *OutputLog
 }
 
 func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store, local *stores.Local, sindex stores.SectorIndex, ret storiface.WorkerReturn, cst *statestore.StateStore) *LocalWorker {
@@ -74,15 +101,24 @@ func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store
 		acceptTasks: acceptTasks,
 		executor:    executor,
 		noSwap:      wcfg.NoSwap,
+		noNUMA:      wcfg.NoNUMA,
+		benchmark:   wcfg.Benchmark,
+		faults:      newFaultInjector(wcfg.Faults),
 
 		session: uuid.New(),
 		closing: make(chan struct{}),
+
+		active: map[storiface.CallID]LocalCall{},
 	}
 
 	if w.executor == nil {
 		w.executor = w.ffiExec
 	}
 
+	if wcfg.ScratchCleanupGrace > 0 {
+		go w.cleanScratch(wcfg.ScratchCleanupGrace)
+	}
+
 	unfinished, err := w.ct.unfinished()
 	if err != nil {
 		log.Errorf("reading unfinished tasks: %+v", err)
@@ -95,7 +131,7 @@ func newLocalWorker(executor ExecutorFunc, wcfg WorkerConfig, store stores.Store
 
 			// TODO: Handle restarting PC1 once support is merged
 
-			if doReturn(context.TODO(), call.RetType, call.ID, ret, nil, err) {
+			if w.doReturn(context.TODO(), call.RetType, call.ID, nil, err) {
 				if err := w.ct.onReturned(call.ID); err != nil {
 					log.Errorf("marking call as returned failed: %s: %+v", call.RetType, err)
 				}
@@ -224,6 +260,7 @@ func (l *LocalWorker) asyncCall(ctx context.Context, sector storage.SectorRef, r
 	}
 
 	l.running.Add(1)
+	l.callStarted(ci, rt)
 
 	go func() {
 		defer l.running.Done()
@@ -234,6 +271,7 @@ func (l *LocalWorker) asyncCall(ctx context.Context, sector storage.SectorRef, r
 		}
 
 		res, err := work(ctx, ci)
+		l.callFinished(ci, err)
 
 		if err != nil {
 			rb, err := json.Marshal(res)
@@ -246,7 +284,7 @@ func (l *LocalWorker) asyncCall(ctx context.Context, sector storage.SectorRef, r
 			}
 		}
 
-		if doReturn(ctx, rt, ci, l.ret, res, toCallError(err)) {
+		if l.doReturn(ctx, rt, ci, res, toCallError(err)) {
 			if err := l.ct.onReturned(ci); err != nil {
 				log.Errorf("tracking call (done): %+v", err)
 			}
@@ -265,17 +303,36 @@ func toCallError(err error) *storiface.CallError {
 	return serr
 }
 
+// how often returns are retried while the manager is shut down
+const managerAwayRetryInterval = 30 * time.Second
+
+// how often failed returns are retried
+var ReturnRetryInterval = 5 * time.Second
+
 // doReturn tries to send the result to manager, returns true if successful
-func doReturn(ctx context.Context, rt ReturnType, ci storiface.CallID, ret storiface.WorkerReturn, res interface{}, rerr *storiface.CallError) bool {
+func (l *LocalWorker) doReturn(ctx context.Context, rt ReturnType, ci storiface.CallID, res interface{}, rerr *storiface.CallError) bool {
 	for {
-		err := returnFunc[rt](ctx, ci, ret, res, rerr)
+		err := l.faults.send(ctx, ci, func(ci storiface.CallID) error {
+			return returnFunc[rt](ctx, ci, l.ret, res, rerr)
+		})
 		if err == nil {
+			atomic.StoreInt64(&l.managerAway, 0)
 			break
 		}
 
-		log.Errorf("return error, will retry in 5s: %s: %+v", rt, err)
+		retry := ReturnRetryInterval
+		if atomic.LoadInt64(&l.managerAway) == 1 {
+			// expected, the result will be accepted when the manager is back
+			retry = managerAwayRetryInterval
+			log.Infof("manager is shut down, will retry return in %s: %s: %s", retry, rt, err)
+		} else if code, ok := storiface.CallErrorCode(err); ok && code == storiface.ErrTempReturnLater {
+			log.Infof("manager can't accept the result yet, will retry return in %s: %s: %s", retry, rt, err)
+		} else {
+			log.Errorf("return error, will retry in %s: %s: %+v", retry, rt, err)
+		}
+
 		select {
-		case <-time.After(5 * time.Second):
+		case <-time.After(retry):
 		case <-ctx.Done():
 			log.Errorf("failed to return results: %s", ctx.Err())
 
@@ -309,8 +366,12 @@ func (l *LocalWorker) AddPiece(ctx context.Context, sector storage.SectorRef, ep
 	})
 }
 
-func (l *LocalWorker) Fetch(ctx context.Context, sector storage.SectorRef, fileType storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode) (storiface.CallID, error) {
+func (l *LocalWorker) Fetch(ctx context.Context, sector storage.SectorRef, fileType storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode, token storiface.FetchToken) (storiface.CallID, error) {
 	return l.asyncCall(ctx, sector, Fetch, func(ctx context.Context, ci storiface.CallID) (interface{}, error) {
+		if token != "" {
+			ctx = stores.WithFetchToken(ctx, token)
+		}
+
 		_, done, err := (&localWorkerPathProvider{w: l, op: am}).AcquireSector(ctx, sector, fileType, storiface.FTNone, ptype)
 		if err == nil {
 			done()
@@ -320,8 +381,9 @@ func (l *LocalWorker) Fetch(ctx context.Context, sector storage.SectorRef, fileT
 	})
 }
 
-func (l *LocalWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storiface.CallID, error) {
+func (l *LocalWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, meta []storiface.PieceMeta, numaNode int) (storiface.CallID, error) {
 	return l.asyncCall(ctx, sector, SealPreCommit1, func(ctx context.Context, ci storiface.CallID) (interface{}, error) {
+		logPieceMeta(ci, pieces, meta)
 
 		{
 			// cleanup previous failed attempts if they exist
@@ -339,7 +401,14 @@ func (l *LocalWorker) SealPreCommit1(ctx context.Context, sector storage.SectorR
 			return nil, err
 		}
 
-		return sb.SealPreCommit1(ctx, sector, ticket, pieces)
+		if numaNode == storiface.NoNUMANode {
+			return sb.SealPreCommit1(ctx, sector, ticket, pieces)
+		}
+
+		log.Infow("running PC1 on numa node", "call", ci, "sector", sector.ID, "node", numaNode)
+		return onNUMANode(numaNode, func() (interface{}, error) {
+			return sb.SealPreCommit1(ctx, sector, ticket, pieces)
+		})
 	})
 }
 
@@ -354,13 +423,14 @@ func (l *LocalWorker) SealPreCommit2(ctx context.Context, sector storage.SectorR
 	})
 }
 
-func (l *LocalWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storiface.CallID, error) {
+func (l *LocalWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids, meta []storiface.PieceMeta) (storiface.CallID, error) {
 	sb, err := l.executor()
 	if err != nil {
 		return storiface.UndefCall, err
 	}
 
 	return l.asyncCall(ctx, sector, SealCommit1, func(ctx context.Context, ci storiface.CallID) (interface{}, error) {
+		logPieceMeta(ci, pieces, meta)
 		return sb.SealCommit1(ctx, sector, ticket, seed, pieces, cids)
 	})
 }
@@ -461,7 +531,12 @@ func (l *LocalWorker) TaskTypes(context.Context) (map[sealtasks.TaskType]struct{
 	l.taskLk.Lock()
 	defer l.taskLk.Unlock()
 
-	return l.acceptTasks, nil
+	out := make(map[sealtasks.TaskType]struct{}, len(l.acceptTasks))
+	for tt := range l.acceptTasks {
+		out[tt] = struct{}{}
+	}
+
+	return out, nil
 }
 
 func (l *LocalWorker) TaskDisable(ctx context.Context, tt sealtasks.TaskType) error {
@@ -510,6 +585,14 @@ func (l *LocalWorker) Info(context.Context) (storiface.WorkerInfo, error) {
 		memSwap = 0
 	}
 
+	var nodes []storiface.NUMANode
+	if !l.noNUMA {
+		nodes, err = numaNodes(numaSysfsPath)
+		if err != nil {
+			log.Errorf("getting numa topology failed: %+v", err)
+		}
+	}
+
 	return storiface.WorkerInfo{
 		Hostname: hostname,
 		Resources: storiface.WorkerResources{
@@ -518,7 +601,10 @@ func (l *LocalWorker) Info(context.Context) (storiface.WorkerInfo, error) {
 			MemReserved: mem.VirtualUsed + mem.Total - mem.Available, // TODO: sub this process
 			CPUs:        uint64(runtime.NumCPU()),
 			GPUs:        gpus,
+			NUMANodes:   nodes,
 		},
+		Benchmark: l.benchmark,
+		Version:   WorkerVersion,
 	}, nil
 }
 
@@ -535,6 +621,15 @@ func (l *LocalWorker) Session(ctx context.Context) (uuid.UUID, error) {
 	}
 }
 
+// ManagerShutdown is called by the manager when it shuts down. Results which
+// are returned after that are rejected until the manager is back, so they are
+// retried less often.
+func (l *LocalWorker) ManagerShutdown(ctx context.Context) error {
+	log.Info("manager is shutting down")
+	atomic.StoreInt64(&l.managerAway, 1)
+	return nil
+}
+
 func (l *LocalWorker) Close() error {
 	close(l.closing)
 	return nil
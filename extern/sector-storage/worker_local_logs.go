@@ -0,0 +1,186 @@
+package sectorstorage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// DefaultOutputLogLines is the number of output lines kept by OutputLog
+const DefaultOutputLogLines = 100000
+
+// longest line kept, longer lines are split
+const maxOutputLineLen = 4 << 10
+
+// how often CallLogs checks whether a followed call has finished
+const callLogsPollInterval = time.Second
+
+// OutputLog keeps the most recent output of the worker process, most
+// importantly the output of the proofs library, so that the output written
+// while a call was running can be retrieved with CallLogs.
+type OutputLog struct {
+	lk      sync.Mutex
+	lines   []storiface.CallLogLine // ring buffer
+	next    int
+	full    bool
+	changed chan struct{} // closed when lines are added
+}
+
+func NewOutputLog(maxLines int) *OutputLog {
+	return &OutputLog{
+		lines:   make([]storiface.CallLogLine, maxLines),
+		changed: make(chan struct{}),
+	}
+}
+
+func (o *OutputLog) add(stream string, line string) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+
+	o.lines[o.next] = storiface.CallLogLine{
+		Time:   time.Now(),
+		Stream: stream,
+		Line:   line,
+	}
+	o.next++
+	if o.next == len(o.lines) {
+		o.next = 0
+		o.full = true
+	}
+
+	close(o.changed)
+	o.changed = make(chan struct{})
+}
+
+// between returns the lines written after from and not after to, with to
+// being zero meaning now. It also returns a channel closed when more lines
+// are written.
+func (o *OutputLog) between(from, to time.Time) ([]storiface.CallLogLine, <-chan struct{}) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+
+	var out []storiface.CallLogLine
+	add := func(lines []storiface.CallLogLine) {
+		for _, l := range lines {
+			if !l.Time.After(from) || (!to.IsZero() && l.Time.After(to)) {
+				continue
+			}
+			out = append(out, l)
+		}
+	}
+	if o.full {
+		add(o.lines[o.next:])
+	}
+	add(o.lines[:o.next])
+
+	return out, o.changed
+}
+
+// Writer returns a writer adding everything written to it to the log, line by
+// line, as the given stream
+func (o *OutputLog) Writer(stream string) io.Writer {
+	return &outputLogWriter{log: o, stream: stream}
+}
+
+type outputLogWriter struct {
+	log    *OutputLog
+	stream string
+
+	lk  sync.Mutex
+	buf []byte
+}
+
+func (w *outputLogWriter) Write(p []byte) (int, error) {
+	w.lk.Lock()
+	defer w.lk.Unlock()
+
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			if len(w.buf) < maxOutputLineLen {
+				break
+			}
+			i = maxOutputLineLen
+		}
+
+		w.log.add(w.stream, string(bytes.TrimSuffix(w.buf[:i], []byte{'\r'})))
+		if i < len(w.buf) && w.buf[i] == '\n' {
+			i++
+		}
+		w.buf = w.buf[i:]
+	}
+
+	if len(w.buf) == 0 {
+		w.buf = nil // don't keep the backing array of large writes around
+	}
+	return len(p), nil
+}
+
+// SetOutputLog sets the log holding the worker process output; it must be set
+// before any calls are started
+func (l *LocalWorker) SetOutputLog(o *OutputLog) {
+	l.outputLog = o
+}
+
+// CallLogs sends the output the worker process wrote while the call was
+// running. For running calls, new output is sent until the call finishes. The
+// channel is closed when there is no more output to send, or when ctx is
+// cancelled.
+func (l *LocalWorker) CallLogs(ctx context.Context, ci storiface.CallID) (<-chan storiface.CallLogLine, error) {
+	if l.outputLog == nil {
+		return nil, xerrors.Errorf("worker process output isn't being captured")
+	}
+
+	start, end, ok := l.callSpan(ci)
+	if !ok {
+		return nil, xerrors.Errorf("call %s didn't run on this worker recently", ci)
+	}
+
+	out := make(chan storiface.CallLogLine, 16)
+	go func() {
+		defer close(out)
+
+		poll := time.NewTicker(callLogsPollInterval)
+		defer poll.Stop()
+
+		from := start
+		for {
+			lines, changed := l.outputLog.between(from, end)
+			for _, line := range lines {
+				select {
+				case out <- line:
+				case <-ctx.Done():
+					return
+				}
+				from = line.Time
+			}
+
+			if !end.IsZero() {
+				return
+			}
+
+			select {
+			case <-changed:
+			case <-poll.C:
+			case <-ctx.Done():
+				return
+			}
+
+			// pick up the remaining output once the call is done
+			_, e, ok := l.callSpan(ci)
+			if !ok {
+				return
+			}
+			end = e
+		}
+	}()
+
+	return out, nil
+}
@@ -0,0 +1,9 @@
+// +build !linux,!darwin
+
+package sectorstorage
+
+import "golang.org/x/xerrors"
+
+func CaptureOutput(o *OutputLog) error {
+	return xerrors.Errorf("capturing process output not supported")
+}
@@ -0,0 +1,90 @@
+package sectorstorage
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func collectLines(t *testing.T, ch <-chan storiface.CallLogLine) []string {
+	var out []string
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case l, ok := <-ch:
+			if !ok {
+				return out
+			}
+			out = append(out, l.Line)
+		case <-timeout:
+			t.Fatal("timed out waiting for call logs")
+		}
+	}
+}
+
+func TestOutputLogWriter(t *testing.T) {
+	o := NewOutputLog(3)
+	w := o.Writer("stderr")
+
+	_, err := fmt.Fprint(w, "a\nb")
+	require.NoError(t, err)
+	_, err = fmt.Fprint(w, "c\r\nd\ne\n")
+	require.NoError(t, err)
+
+	lines, _ := o.between(time.Time{}, time.Time{})
+	require.Len(t, lines, 3)
+	for i, line := range []string{"bc", "d", "e"} {
+		require.Equal(t, line, lines[i].Line)
+		require.Equal(t, "stderr", lines[i].Stream)
+	}
+}
+
+func TestLocalWorkerCallLogs(t *testing.T) {
+	o := NewOutputLog(DefaultOutputLogLines)
+	w := o.Writer("stdout")
+	l := &LocalWorker{
+		active:    map[storiface.CallID]LocalCall{},
+		outputLog: o,
+	}
+
+	call := func(n abi.SectorNumber) storiface.CallID {
+		return storiface.CallID{Sector: abi.SectorID{Miner: 1000, Number: n}, ID: uuid.New()}
+	}
+
+	_, err := l.CallLogs(context.Background(), call(1))
+	require.Error(t, err)
+
+	_, _ = fmt.Fprintln(w, "before")
+	done := call(2)
+	l.callStarted(done, SealPreCommit1)
+	_, _ = fmt.Fprintln(w, "pc1 output")
+	l.callFinished(done, nil)
+	_, _ = fmt.Fprintln(w, "after")
+
+	ch, err := l.CallLogs(context.Background(), done)
+	require.NoError(t, err)
+	require.Equal(t, []string{"pc1 output"}, collectLines(t, ch))
+
+	// running calls are followed until they finish
+	running := call(3)
+	l.callStarted(running, SealPreCommit2)
+	_, _ = fmt.Fprintln(w, "pc2 started")
+
+	ch, err = l.CallLogs(context.Background(), running)
+	require.NoError(t, err)
+
+	go func() {
+		_, _ = fmt.Fprintln(w, "pc2 done")
+		l.callFinished(running, nil)
+		_, _ = fmt.Fprintln(w, "after pc2")
+	}()
+	require.Equal(t, []string{"pc2 started", "pc2 done"}, collectLines(t, ch))
+}
@@ -0,0 +1,42 @@
+// +build linux darwin
+
+package sectorstorage
+
+import (
+	"io"
+	"os"
+
+	"golang.org/x/sys/unix"
+	"golang.org/x/xerrors"
+)
+
+// CaptureOutput redirects the stdout and stderr of the process, including the
+// output of native code like the proofs library, into the log. The output is
+// still written to the original stdout / stderr.
+func CaptureOutput(o *OutputLog) error {
+	for _, s := range []struct {
+		fd   int
+		name string
+	}{{1, "stdout"}, {2, "stderr"}} {
+		orig, err := unix.Dup(s.fd)
+		if err != nil {
+			return xerrors.Errorf("duplicating %s: %w", s.name, err)
+		}
+
+		r, w, err := os.Pipe()
+		if err != nil {
+			return xerrors.Errorf("creating %s pipe: %w", s.name, err)
+		}
+		if err := unix.Dup2(int(w.Fd()), s.fd); err != nil {
+			return xerrors.Errorf("redirecting %s: %w", s.name, err)
+		}
+		_ = w.Close() // the fd now refers to the pipe
+
+		dest := io.MultiWriter(os.NewFile(uintptr(orig), s.name), o.Writer(s.name))
+		go func() {
+			_, _ = io.Copy(dest, r)
+		}()
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package sectorstorage
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// ScratchCleanupInterval is how often workers scan their sealing paths for
+// orphaned PreCommit1 files, after the scan on startup
+var ScratchCleanupInterval = time.Hour
+
+// scratchPatterns match the files PreCommit1 leaves in sector cache
+// directories: the layers, and the tree built over the unsealed data. They
+// are only used until Commit1, and removed when the sector is finalized.
+var scratchPatterns = []string{
+	"sc-02-data-layer-*.dat",
+	"sc-02-data-tree-d.dat",
+}
+
+// cleanScratch periodically removes PreCommit1 files of sectors which have no
+// live call on the worker, once they weren't modified for the grace period.
+// Those are left behind by calls which crashed, or by sectors which were
+// moved on to other workers.
+func (l *LocalWorker) cleanScratch(grace time.Duration) {
+	t := time.NewTimer(0)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-l.closing:
+			return
+		}
+
+		if err := l.cleanScratchOnce(context.TODO(), grace); err != nil {
+			log.Errorf("cleaning up scratch files: %+v", err)
+		}
+		t.Reset(ScratchCleanupInterval)
+	}
+}
+
+func (l *LocalWorker) cleanScratchOnce(ctx context.Context, grace time.Duration) error {
+	live, err := l.liveSectors()
+	if err != nil {
+		return err
+	}
+
+	paths, err := l.localStore.Local(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting local storage paths: %w", err)
+	}
+
+	for _, p := range paths {
+		if !p.CanSeal {
+			continue
+		}
+
+		orphans, err := orphanedScratch(filepath.Join(p.LocalPath, storiface.FTCache.String()), live, grace, time.Now())
+		if err != nil {
+			log.Errorf("scanning %s for scratch files: %+v", p.LocalPath, err)
+			continue
+		}
+
+		var freed int64
+		for _, f := range orphans {
+			fi, err := os.Stat(f)
+			if err != nil {
+				continue
+			}
+			if err := os.Remove(f); err != nil {
+				log.Errorf("removing orphaned scratch file: %+v", err)
+				continue
+			}
+			freed += fi.Size()
+		}
+		if len(orphans) > 0 {
+			log.Infow("removed orphaned scratch files", "path", p.LocalPath, "files", len(orphans), "freed", freed)
+		}
+	}
+
+	return nil
+}
+
+// liveSectors returns the sectors with calls running on the worker, or which
+// results weren't returned yet
+func (l *LocalWorker) liveSectors() (map[abi.SectorID]struct{}, error) {
+	live := map[abi.SectorID]struct{}{}
+
+	l.statusLk.Lock()
+	for ci := range l.active {
+		live[ci.Sector] = struct{}{}
+	}
+	l.statusLk.Unlock()
+
+	unfinished, err := l.ct.unfinished()
+	if err != nil {
+		return nil, xerrors.Errorf("reading unfinished calls: %w", err)
+	}
+	for _, c := range unfinished {
+		live[c.ID.Sector] = struct{}{}
+	}
+
+	return live, nil
+}
+
+// orphanedScratch lists the PreCommit1 files in the sector cache directories
+// under cacheDir which belong to sectors without live calls, and of which no
+// file was modified for the grace period
+func orphanedScratch(cacheDir string, live map[abi.SectorID]struct{}, grace time.Duration, now time.Time) ([]string, error) {
+	ents, err := ioutil.ReadDir(cacheDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, xerrors.Errorf("listing cache dir: %w", err)
+	}
+
+	var out []string
+	for _, ent := range ents {
+		if !ent.IsDir() {
+			continue
+		}
+
+		sid, err := storiface.ParseSectorID(ent.Name())
+		if err != nil {
+			continue
+		}
+		if _, ok := live[sid]; ok {
+			continue
+		}
+
+		var files []string
+		var lastMod time.Time
+		for _, pattern := range scratchPatterns {
+			matches, err := filepath.Glob(filepath.Join(cacheDir, ent.Name(), pattern))
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range matches {
+				fi, err := os.Stat(m)
+				if err != nil {
+					continue
+				}
+				if fi.ModTime().After(lastMod) {
+					lastMod = fi.ModTime()
+				}
+				files = append(files, m)
+			}
+		}
+
+		if len(files) == 0 || now.Sub(lastMod) < grace {
+			continue
+		}
+		out = append(out, files...)
+	}
+
+	return out, nil
+}
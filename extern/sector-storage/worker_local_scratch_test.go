@@ -0,0 +1,59 @@
+package sectorstorage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+func TestOrphanedScratch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scratch")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir) // nolint
+
+	old := time.Now().Add(-2 * time.Hour)
+	write := func(sector, name string, mod time.Time) string {
+		p := filepath.Join(dir, sector, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, ioutil.WriteFile(p, []byte("data"), 0644))
+		require.NoError(t, os.Chtimes(p, mod, mod))
+		return p
+	}
+
+	// orphaned
+	l1 := write("s-t01000-1", "sc-02-data-layer-1.dat", old)
+	l2 := write("s-t01000-1", "sc-02-data-layer-2.dat", old)
+	td := write("s-t01000-1", "sc-02-data-tree-d.dat", old)
+	write("s-t01000-1", "sc-02-data-tree-r-last.dat", old) // needed for proving
+	write("s-t01000-1", "p_aux", old)
+
+	// live call
+	write("s-t01000-2", "sc-02-data-layer-1.dat", old)
+
+	// written recently
+	write("s-t01000-3", "sc-02-data-layer-1.dat", old)
+	write("s-t01000-3", "sc-02-data-layer-2.dat", time.Now())
+
+	// not a sector
+	write("tmp", "sc-02-data-layer-1.dat", old)
+
+	live := map[abi.SectorID]struct{}{
+		{Miner: 1000, Number: 2}: {},
+	}
+
+	orphans, err := orphanedScratch(dir, live, time.Hour, time.Now())
+	require.NoError(t, err)
+	sort.Strings(orphans)
+	require.Equal(t, []string{l1, l2, td}, orphans)
+
+	orphans, err = orphanedScratch(filepath.Join(dir, "missing"), live, time.Hour, time.Now())
+	require.NoError(t, err)
+	require.Empty(t, orphans)
+}
@@ -0,0 +1,118 @@
+package sectorstorage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// recentErrorsKept is the number of failed calls LocalWorker remembers
+const recentErrorsKept = 20
+
+// recentCallsKept is the number of finished calls LocalWorker remembers, so
+// that their output can still be retrieved
+const recentCallsKept = 100
+
+// LocalCall is a call running on a LocalWorker
+type LocalCall struct {
+	ID    storiface.CallID
+	Task  ReturnType
+	Start time.Time
+}
+
+// LocalCallError is a failed LocalWorker call
+type LocalCallError struct {
+	LocalCall
+	End   time.Time
+	Error string
+}
+
+type finishedCall struct {
+	LocalCall
+	End time.Time
+}
+
+func (l *LocalWorker) callStarted(ci storiface.CallID, rt ReturnType) {
+	l.statusLk.Lock()
+	defer l.statusLk.Unlock()
+
+	l.active[ci] = LocalCall{
+		ID:    ci,
+		Task:  rt,
+		Start: time.Now(),
+	}
+}
+
+func (l *LocalWorker) callFinished(ci storiface.CallID, err error) {
+	l.statusLk.Lock()
+	defer l.statusLk.Unlock()
+
+	call := l.active[ci]
+	delete(l.active, ci)
+
+	l.recentCalls = append(l.recentCalls, finishedCall{
+		LocalCall: call,
+		End:       time.Now(),
+	})
+	if len(l.recentCalls) > recentCallsKept {
+		l.recentCalls = l.recentCalls[len(l.recentCalls)-recentCallsKept:]
+	}
+
+	if err == nil {
+		return
+	}
+
+	l.recentErrs = append(l.recentErrs, LocalCallError{
+		LocalCall: call,
+		End:       time.Now(),
+		Error:     err.Error(),
+	})
+	if len(l.recentErrs) > recentErrorsKept {
+		l.recentErrs = l.recentErrs[len(l.recentErrs)-recentErrorsKept:]
+	}
+}
+
+// ActiveCalls returns the calls currently running on the worker, oldest first
+func (l *LocalWorker) ActiveCalls() []LocalCall {
+	l.statusLk.Lock()
+	defer l.statusLk.Unlock()
+
+	out := make([]LocalCall, 0, len(l.active))
+	for _, call := range l.active {
+		out = append(out, call)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Start.Before(out[j].Start)
+	})
+	return out
+}
+
+// RecentErrors returns the last failed calls, most recent first
+func (l *LocalWorker) RecentErrors() []LocalCallError {
+	l.statusLk.Lock()
+	defer l.statusLk.Unlock()
+
+	out := make([]LocalCallError, len(l.recentErrs))
+	for i, e := range l.recentErrs {
+		out[len(out)-1-i] = e
+	}
+	return out
+}
+
+// callSpan returns when the call started, and when it finished; end is zero
+// for calls which are still running
+func (l *LocalWorker) callSpan(ci storiface.CallID) (start, end time.Time, ok bool) {
+	l.statusLk.Lock()
+	defer l.statusLk.Unlock()
+
+	if call, ok := l.active[ci]; ok {
+		return call.Start, time.Time{}, true
+	}
+	for _, call := range l.recentCalls {
+		if call.ID == ci {
+			return call.Start, call.End, true
+		}
+	}
+	return time.Time{}, time.Time{}, false
+}
@@ -0,0 +1,42 @@
+package sectorstorage
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestLocalWorkerStatus(t *testing.T) {
+	l := &LocalWorker{
+		active: map[storiface.CallID]LocalCall{},
+	}
+
+	calls := make([]storiface.CallID, recentErrorsKept+5)
+	for i := range calls {
+		calls[i] = storiface.CallID{Sector: abi.SectorID{Miner: 1000, Number: abi.SectorNumber(i)}, ID: uuid.New()}
+		l.callStarted(calls[i], SealPreCommit1)
+	}
+	require.Len(t, l.ActiveCalls(), len(calls))
+
+	l.callFinished(calls[0], nil)
+	require.Len(t, l.ActiveCalls(), len(calls)-1)
+	require.Empty(t, l.RecentErrors())
+
+	for i, ci := range calls[1:] {
+		l.callFinished(ci, xerrors.New(fmt.Sprint("error ", i+1)))
+	}
+	require.Empty(t, l.ActiveCalls())
+
+	errs := l.RecentErrors()
+	require.Len(t, errs, recentErrorsKept)
+	require.Equal(t, calls[len(calls)-1], errs[0].ID)
+	require.Equal(t, fmt.Sprint("error ", len(calls)-1), errs[0].Error)
+	require.Equal(t, SealPreCommit1, errs[0].Task)
+}
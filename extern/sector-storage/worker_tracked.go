@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/ipfs/go-cid"
 
 	"github.com/filecoin-project/go-state-types/abi"
@@ -17,32 +18,134 @@ import (
 
 type trackedWork struct {
 	job    storiface.WorkerJob
+	worker WorkerID       // zero if unknown, for calls restored after a restart
+	req    *workerRequest // scheduled job the call was made for, if any
+
+	returned time.Time // when the result came back, zero while running
+}
+
+// how many failed calls are kept for the health summary
+const maxRecentFailures = 32
+
+// how many collected calls are remembered, for retrieving their logs
+const maxRecentCalls = 256
+
+// ReturnedCallTTL is how long returned calls are listed while nobody collects
+// their result, e.g. because the caller gave up waiting. Results of work are
+// listed from the work state instead, until they're collected.
+var ReturnedCallTTL = time.Hour
+
+type recentCall struct {
+	call   storiface.CallID
 	worker WorkerID
 }
 
+type workerCallStats struct {
+	done   map[sealtasks.TaskType]uint64
+	failed map[sealtasks.TaskType]uint64
+}
+
+// workTracker keeps track of all calls dispatched to workers, from the time
+// they are started until their result is collected by the manager. Calls
+// which were running before a manager restart are restored into it from the
+// persisted work state, so that all jobs can be listed the same way.
 type workTracker struct {
 	lk sync.Mutex
 
-	done    map[storiface.CallID]struct{}
-	running map[storiface.CallID]trackedWork
+	done  map[storiface.CallID]struct{} // returned before being tracked
+	calls map[storiface.CallID]trackedWork
+
+	since    time.Time
+	stats    map[WorkerID]*workerCallStats
+	failures []storiface.DispatchFailure // oldest first
+	recent   []recentCall                // collected calls, oldest first
+
+	numaPending map[WorkerID]map[int]int // NUMA nodes picked for calls not tracked yet
+
+	hostnames map[WorkerID]string // workers calls were made on, for history
+	history   *sealingHistory
+
+	connected map[WorkerID]string // hostnames of workers connected to the scheduler
+	hostConns map[string]int      // number of connected workers per hostname
+
+	// TODO: queue stats, scheduler feedback
+}
+
+func newWorkTracker() *workTracker {
+	return &workTracker{
+		done:  map[storiface.CallID]struct{}{},
+		calls: map[storiface.CallID]trackedWork{},
+
+		since: time.Now(),
+		stats: map[WorkerID]*workerCallStats{},
 
-	// TODO: done, aggregate stats, queue stats, scheduler feedback
+		numaPending: map[WorkerID]map[int]int{},
+
+		hostnames: map[WorkerID]string{},
+
+		connected: map[WorkerID]string{},
+		hostConns: map[string]int{},
+	}
 }
 
-func (wt *workTracker) onDone(callID storiface.CallID) {
+func (wt *workTracker) onDone(callID storiface.CallID, cerr *storiface.CallError) {
 	wt.lk.Lock()
 	defer wt.lk.Unlock()
 
-	_, ok := wt.running[callID]
+	t, ok := wt.calls[callID]
 	if !ok {
 		wt.done[callID] = struct{}{}
 		return
 	}
 
-	delete(wt.running, callID)
+	// keep listing the call until the result is collected, or for
+	// ReturnedCallTTL
+	t.job.RunWait = storiface.RWReturned
+	t.returned = time.Now()
+	wt.calls[callID] = t
+	wt.pruneReturned(t.returned)
+
+	if t.worker != (WorkerID{}) {
+		st, ok := wt.stats[t.worker]
+		if !ok {
+			st = &workerCallStats{
+				done:   map[sealtasks.TaskType]uint64{},
+				failed: map[sealtasks.TaskType]uint64{},
+			}
+			wt.stats[t.worker] = st
+		}
+
+		if cerr == nil {
+			st.done[t.job.Task]++
+		} else {
+			st.failed[t.job.Task]++
+		}
+
+		wt.history.callDone(wt.hostnames[t.worker], t.job.Task, cerr != nil, time.Now())
+	}
+
+	if cerr == nil {
+		return
+	}
+
+	wt.failures = append(wt.failures, storiface.DispatchFailure{
+		Call:   callID,
+		Worker: uuid.UUID(t.worker),
+		Task:   t.job.Task,
+		Time:   time.Now(),
+		Error:  cerr.Error(),
+	})
+	if len(wt.failures) > maxRecentFailures {
+		wt.failures = wt.failures[len(wt.failures)-maxRecentFailures:]
+	}
+}
+
+func (wt *workTracker) track(wid WorkerID, req *workerRequest, sid storage.SectorRef, task sealtasks.TaskType) func(storiface.CallID, error) (storiface.CallID, error) {
+	return wt.trackOn(wid, req, sid, task, storiface.NoNUMANode)
 }
 
-func (wt *workTracker) track(wid WorkerID, sid storage.SectorRef, task sealtasks.TaskType) func(storiface.CallID, error) (storiface.CallID, error) {
+// trackOn is like track, for calls pinned to a NUMA node of the worker
+func (wt *workTracker) trackOn(wid WorkerID, req *workerRequest, sid storage.SectorRef, task sealtasks.TaskType, numaNode int) func(storiface.CallID, error) (storiface.CallID, error) {
 	return func(callID storiface.CallID, err error) (storiface.CallID, error) {
 		if err != nil {
 			return callID, err
@@ -57,82 +160,303 @@ func (wt *workTracker) track(wid WorkerID, sid storage.SectorRef, task sealtasks
 			return callID, err
 		}
 
-		wt.running[callID] = trackedWork{
-			job: storiface.WorkerJob{
-				ID:     callID,
-				Sector: sid.ID,
-				Task:   task,
-				Start:  time.Now(),
-			},
+		job := storiface.WorkerJob{
+			ID:     callID,
+			Sector: sid.ID,
+			Task:   task,
+			Start:  time.Now(),
+		}
+		if numaNode != storiface.NoNUMANode {
+			node := numaNode
+			job.NUMANode = &node
+		}
+
+		wt.calls[callID] = trackedWork{
+			job:    job,
 			worker: wid,
+			req:    req,
 		}
 
 		return callID, err
 	}
 }
 
-func (wt *workTracker) worker(wid WorkerID, w Worker) Worker {
+// pickNUMANode picks the NUMA node of the worker running the fewest PC1
+// calls. The node counts as used by one more call until release is called,
+// which should happen once the call is tracked.
+func (wt *workTracker) pickNUMANode(wid WorkerID, nodes []storiface.NUMANode) (node int, release func()) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	load := map[int]int{}
+	for n, cnt := range wt.numaPending[wid] {
+		load[n] += cnt
+	}
+	for _, t := range wt.calls {
+		if t.worker != wid || t.job.NUMANode == nil || t.job.RunWait != 0 {
+			continue
+		}
+		load[*t.job.NUMANode]++
+	}
+
+	node = nodes[0].ID
+	for _, n := range nodes[1:] {
+		if load[n.ID] < load[node] {
+			node = n.ID
+		}
+	}
+
+	if wt.numaPending[wid] == nil {
+		wt.numaPending[wid] = map[int]int{}
+	}
+	wt.numaPending[wid][node]++
+
+	return node, func() {
+		wt.lk.Lock()
+		defer wt.lk.Unlock()
+
+		wt.numaPending[wid][node]--
+		if wt.numaPending[wid][node] == 0 {
+			delete(wt.numaPending[wid], node)
+		}
+		if len(wt.numaPending[wid]) == 0 {
+			delete(wt.numaPending, wid)
+		}
+	}
+}
+
+func (wt *workTracker) worker(wid WorkerID, info storiface.WorkerInfo, w Worker, req *workerRequest) Worker {
+	wt.lk.Lock()
+	wt.hostnames[wid] = info.Hostname
+	wt.lk.Unlock()
+
 	return &trackedWorker{
 		Worker: w,
 		wid:    wid,
+		req:    req,
+
+		numaNodes: info.Resources.NUMANodes,
 
 		tracker: wt,
 	}
 }
 
-func (wt *workTracker) Running() []trackedWork {
+// restore tracks a call which was running on a worker before the manager
+// restarted, and whose result is still awaited.
+func (wt *workTracker) restore(callID storiface.CallID, task sealtasks.TaskType, start time.Time, hostname string) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	wt.calls[callID] = trackedWork{
+		job: storiface.WorkerJob{
+			ID:       callID,
+			Sector:   callID.Sector,
+			Task:     task,
+			RunWait:  storiface.RWRetWait,
+			Start:    start,
+			Hostname: hostname,
+		},
+	}
+}
+
+// onCollected stops tracking a call once its result was handed to the caller.
+func (wt *workTracker) onCollected(callID storiface.CallID) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	if t, ok := wt.calls[callID]; ok && t.worker != (WorkerID{}) {
+		wt.recent = append(wt.recent, recentCall{call: callID, worker: t.worker})
+		if len(wt.recent) > maxRecentCalls {
+			wt.recent = wt.recent[len(wt.recent)-maxRecentCalls:]
+		}
+	}
+
+	delete(wt.calls, callID)
+}
+
+// pruneReturned stops tracking calls returned more than ReturnedCallTTL ago;
+// caller must hold wt.lk
+func (wt *workTracker) pruneReturned(now time.Time) {
+	for id, t := range wt.calls {
+		if !t.returned.IsZero() && now.Sub(t.returned) > ReturnedCallTTL {
+			log.Debugw("dropping returned call nobody collected", "call", id, "task", t.job.Task)
+			delete(wt.calls, id)
+		}
+	}
+}
+
+// callWorker returns the worker a tracked or recently collected call was
+// dispatched to
+func (wt *workTracker) callWorker(callID storiface.CallID) (WorkerID, bool) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	if t, ok := wt.calls[callID]; ok && t.worker != (WorkerID{}) {
+		return t.worker, true
+	}
+	for _, rc := range wt.recent {
+		if rc.call == callID {
+			return rc.worker, true
+		}
+	}
+	return WorkerID{}, false
+}
+
+// workerConnected is called by the scheduler when a worker is added
+func (wt *workTracker) workerConnected(wid WorkerID, hostname string) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	if _, ok := wt.connected[wid]; ok {
+		return
+	}
+	wt.connected[wid] = hostname
+	wt.hostConns[hostname]++
+}
+
+// workerDisconnected is called by the scheduler when a worker is removed
+func (wt *workTracker) workerDisconnected(wid WorkerID) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	hostname, ok := wt.connected[wid]
+	if !ok {
+		return
+	}
+	delete(wt.connected, wid)
+	if wt.hostConns[hostname]--; wt.hostConns[hostname] <= 0 {
+		delete(wt.hostConns, hostname)
+	}
+}
+
+// live returns whether the call is running on a connected worker. Calls
+// restored after a restart are matched by the hostname of the worker they ran
+// on, which reconnects with a new session.
+func (wt *workTracker) live(callID storiface.CallID) bool {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	t, ok := wt.calls[callID]
+	if !ok || !t.returned.IsZero() {
+		return false
+	}
+	if _, ok := wt.connected[t.worker]; ok {
+		return true
+	}
+	return t.job.Hostname != "" && wt.hostConns[t.job.Hostname] > 0
+}
+
+// jobs lists all tracked calls
+func (wt *workTracker) jobs() []trackedWork {
 	wt.lk.Lock()
 	defer wt.lk.Unlock()
 
-	out := make([]trackedWork, 0, len(wt.running))
-	for _, job := range wt.running {
-		out = append(out, job)
+	wt.pruneReturned(time.Now())
+
+	out := make([]trackedWork, 0, len(wt.calls))
+	for _, t := range wt.calls {
+		if t.req != nil {
+			t.job.State, t.job.History = t.req.jobState()
+		}
+		out = append(out, t)
 	}
 
 	return out
 }
 
+// fills call counters and recent failures in the health summary
+func (wt *workTracker) health(out *storiface.DispatchHealth) {
+	wt.lk.Lock()
+	defer wt.lk.Unlock()
+
+	out.Since = wt.since
+
+	for _, t := range wt.calls {
+		if t.job.RunWait != 0 || t.worker == (WorkerID{}) {
+			continue
+		}
+		wh := out.Workers[uuid.UUID(t.worker)]
+		wh.Running++
+		out.Workers[uuid.UUID(t.worker)] = wh
+	}
+
+	for wid, st := range wt.stats {
+		wh := out.Workers[uuid.UUID(wid)]
+		wh.Done = map[sealtasks.TaskType]uint64{}
+		wh.Failed = map[sealtasks.TaskType]uint64{}
+		for tt, n := range st.done {
+			wh.Done[tt] = n
+		}
+		for tt, n := range st.failed {
+			wh.Failed[tt] = n
+		}
+		out.Workers[uuid.UUID(wid)] = wh
+	}
+
+	// workers which disconnected are only known from their calls
+	for id, wh := range out.Workers {
+		if wh.Hostname == "" {
+			wh.Hostname = wt.hostnames[WorkerID(id)]
+			out.Workers[id] = wh
+		}
+	}
+
+	out.RecentFailures = make([]storiface.DispatchFailure, 0, len(wt.failures))
+	for i := len(wt.failures) - 1; i >= 0; i-- {
+		out.RecentFailures = append(out.RecentFailures, wt.failures[i])
+	}
+}
+
 type trackedWorker struct {
 	Worker
 	wid WorkerID
+	req *workerRequest
+
+	numaNodes []storiface.NUMANode
 
 	tracker *workTracker
 }
 
-func (t *trackedWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTPreCommit1)(t.Worker.SealPreCommit1(ctx, sector, ticket, pieces))
+func (t *trackedWorker) SealPreCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, pieces []abi.PieceInfo, meta []storiface.PieceMeta, numaNode int) (storiface.CallID, error) {
+	if numaNode == storiface.NoNUMANode && len(t.numaNodes) > 1 {
+		var release func()
+		numaNode, release = t.tracker.pickNUMANode(t.wid, t.numaNodes)
+		defer release()
+	}
+
+	return t.tracker.trackOn(t.wid, t.req, sector, sealtasks.TTPreCommit1, numaNode)(t.Worker.SealPreCommit1(ctx, sector, ticket, pieces, meta, numaNode))
 }
 
 func (t *trackedWorker) SealPreCommit2(ctx context.Context, sector storage.SectorRef, pc1o storage.PreCommit1Out) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTPreCommit2)(t.Worker.SealPreCommit2(ctx, sector, pc1o))
+	return t.tracker.track(t.wid, t.req, sector, sealtasks.TTPreCommit2)(t.Worker.SealPreCommit2(ctx, sector, pc1o))
 }
 
-func (t *trackedWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTCommit1)(t.Worker.SealCommit1(ctx, sector, ticket, seed, pieces, cids))
+func (t *trackedWorker) SealCommit1(ctx context.Context, sector storage.SectorRef, ticket abi.SealRandomness, seed abi.InteractiveSealRandomness, pieces []abi.PieceInfo, cids storage.SectorCids, meta []storiface.PieceMeta) (storiface.CallID, error) {
+	return t.tracker.track(t.wid, t.req, sector, sealtasks.TTCommit1)(t.Worker.SealCommit1(ctx, sector, ticket, seed, pieces, cids, meta))
 }
 
 func (t *trackedWorker) SealCommit2(ctx context.Context, sector storage.SectorRef, c1o storage.Commit1Out) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTCommit2)(t.Worker.SealCommit2(ctx, sector, c1o))
+	return t.tracker.track(t.wid, t.req, sector, sealtasks.TTCommit2)(t.Worker.SealCommit2(ctx, sector, c1o))
 }
 
 func (t *trackedWorker) FinalizeSector(ctx context.Context, sector storage.SectorRef, keepUnsealed []storage.Range) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTFinalize)(t.Worker.FinalizeSector(ctx, sector, keepUnsealed))
+	return t.tracker.track(t.wid, t.req, sector, sealtasks.TTFinalize)(t.Worker.FinalizeSector(ctx, sector, keepUnsealed))
 }
 
 func (t *trackedWorker) AddPiece(ctx context.Context, sector storage.SectorRef, pieceSizes []abi.UnpaddedPieceSize, newPieceSize abi.UnpaddedPieceSize, pieceData storage.Data) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, sector, sealtasks.TTAddPiece)(t.Worker.AddPiece(ctx, sector, pieceSizes, newPieceSize, pieceData))
+	return t.tracker.track(t.wid, t.req, sector, sealtasks.TTAddPiece)(t.Worker.AddPiece(ctx, sector, pieceSizes, newPieceSize, pieceData))
 }
 
-func (t *trackedWorker) Fetch(ctx context.Context, s storage.SectorRef, ft storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, s, sealtasks.TTFetch)(t.Worker.Fetch(ctx, s, ft, ptype, am))
+func (t *trackedWorker) Fetch(ctx context.Context, s storage.SectorRef, ft storiface.SectorFileType, ptype storiface.PathType, am storiface.AcquireMode, token storiface.FetchToken) (storiface.CallID, error) {
+	return t.tracker.track(t.wid, t.req, s, sealtasks.TTFetch)(t.Worker.Fetch(ctx, s, ft, ptype, am, token))
 }
 
 func (t *trackedWorker) UnsealPiece(ctx context.Context, id storage.SectorRef, index storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize, randomness abi.SealRandomness, cid cid.Cid) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, id, sealtasks.TTUnseal)(t.Worker.UnsealPiece(ctx, id, index, size, randomness, cid))
+	return t.tracker.track(t.wid, t.req, id, sealtasks.TTUnseal)(t.Worker.UnsealPiece(ctx, id, index, size, randomness, cid))
 }
 
 func (t *trackedWorker) ReadPiece(ctx context.Context, writer io.Writer, id storage.SectorRef, index storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (storiface.CallID, error) {
-	return t.tracker.track(t.wid, id, sealtasks.TTReadUnsealed)(t.Worker.ReadPiece(ctx, writer, id, index, size))
+	return t.tracker.track(t.wid, t.req, id, sealtasks.TTReadUnsealed)(t.Worker.ReadPiece(ctx, writer, id, index, size))
 }
 
 var _ Worker = &trackedWorker{}
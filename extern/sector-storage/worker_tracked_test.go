@@ -0,0 +1,130 @@
+package sectorstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/specs-storage/storage"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+func TestWorkTrackerHealth(t *testing.T) {
+	wt := newWorkTracker()
+	wid := WorkerID(uuid.New())
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 1}}
+
+	call := func() storiface.CallID {
+		return storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+	}
+
+	c1, c2, c3 := call(), call(), call()
+	wt.worker(wid, storiface.WorkerInfo{Hostname: "worker"}, nil, nil)
+	for _, c := range []storiface.CallID{c1, c2, c3} {
+		_, err := wt.track(wid, nil, sector, sealtasks.TTPreCommit1)(c, nil)
+		require.NoError(t, err)
+	}
+
+	wt.onDone(c1, nil)
+	wt.onDone(c2, storiface.Err(storiface.ErrUnknown, xerrors.New("boom")))
+
+	out := storiface.DispatchHealth{Workers: map[uuid.UUID]storiface.WorkerHealth{}}
+	wt.health(&out)
+
+	// the worker isn't connected, it's listed with the hostname it had
+	wh := out.Workers[uuid.UUID(wid)]
+	require.Equal(t, "worker", wh.Hostname)
+	require.Equal(t, 1, wh.Running)
+	require.Equal(t, uint64(1), wh.Done[sealtasks.TTPreCommit1])
+	require.Equal(t, uint64(1), wh.Failed[sealtasks.TTPreCommit1])
+
+	require.Len(t, out.RecentFailures, 1)
+	require.Equal(t, c2, out.RecentFailures[0].Call)
+	require.Contains(t, out.RecentFailures[0].Error, "boom")
+}
+
+func TestWorkTrackerLifecycle(t *testing.T) {
+	wt := newWorkTracker()
+	wid := WorkerID(uuid.New())
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 1}}
+
+	running := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+	restored := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+
+	_, err := wt.track(wid, nil, sector, sealtasks.TTPreCommit1)(running, nil)
+	require.NoError(t, err)
+	wt.restore(restored, sealtasks.TTCommit1, time.Now(), "host")
+
+	states := func() map[storiface.CallID]int {
+		out := map[storiface.CallID]int{}
+		for _, j := range wt.jobs() {
+			out[j.job.ID] = j.job.RunWait
+		}
+		return out
+	}
+
+	require.Equal(t, map[storiface.CallID]int{running: 0, restored: storiface.RWRetWait}, states())
+
+	wt.onDone(running, nil)
+	wt.onDone(restored, nil)
+	require.Equal(t, map[storiface.CallID]int{running: storiface.RWReturned, restored: storiface.RWReturned}, states())
+
+	wt.onCollected(running)
+	wt.onCollected(restored)
+	require.Empty(t, wt.jobs())
+
+	// results nobody collects stop being listed after a while
+	abandoned := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+	_, err = wt.track(wid, nil, sector, sealtasks.TTFetch)(abandoned, nil)
+	require.NoError(t, err)
+	wt.onDone(abandoned, nil)
+	require.Len(t, wt.jobs(), 1)
+
+	tw := wt.calls[abandoned]
+	tw.returned = time.Now().Add(-ReturnedCallTTL - time.Second)
+	wt.calls[abandoned] = tw
+	require.Empty(t, wt.jobs())
+}
+
+func TestWorkTrackerJobState(t *testing.T) {
+	wt := newWorkTracker()
+	wid := WorkerID(uuid.New())
+	sector := storage.SectorRef{ID: abi.SectorID{Miner: 1000, Number: 1}}
+
+	start := time.Now()
+	req := &workerRequest{
+		taskType: sealtasks.TTPreCommit2,
+		start:    start,
+		states:   []storiface.JobStateTime{{State: storiface.JobQueued, Time: start}},
+	}
+
+	fetch := storiface.CallID{Sector: sector.ID, ID: uuid.New()}
+	req.setState(storiface.JobTransferringIn)
+	_, err := wt.track(wid, req, sector, sealtasks.TTFetch)(fetch, nil)
+	require.NoError(t, err)
+
+	require.Equal(t, storiface.JobComputing, workState(sealtasks.TTPreCommit2))
+	require.Equal(t, storiface.JobTransferringOut, workState(sealtasks.TTFetch))
+	req.setState(workState(req.taskType))
+
+	// listed calls report the current state of their job
+	jobs := wt.jobs()
+	require.Len(t, jobs, 1)
+	require.Equal(t, storiface.JobComputing, jobs[0].job.State)
+
+	var states []storiface.JobState
+	for _, h := range jobs[0].job.History {
+		states = append(states, h.State)
+	}
+	require.Equal(t, []storiface.JobState{storiface.JobQueued, storiface.JobTransferringIn, storiface.JobComputing}, states)
+
+	// the listing is a copy
+	req.setState(storiface.JobDone)
+	require.Len(t, jobs[0].job.History, 3)
+}
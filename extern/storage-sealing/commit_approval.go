@@ -0,0 +1,156 @@
+package sealing
+
+import (
+	"context"
+	"sort"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-statemachine"
+
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+func (m *Sealing) handleWaitCommitApproval(ctx statemachine.Context, sector SectorInfo) error {
+	m.commitApprovalLk.Lock()
+	_, pending := m.pendingCommits[sector.SectorNumber]
+	_, approved := m.approvedCommits[sector.SectorNumber]
+	m.commitApprovalLk.Unlock()
+
+	if approved { // approved before the sector got here
+		return ctx.Send(SectorCommitApproved{})
+	}
+	if !pending { // pending commits aren't persisted, assemble the message again
+		return ctx.Send(SectorRetrySubmitCommit{})
+	}
+
+	tok, height, err := m.api.ChainHead(ctx.Context())
+	if err != nil {
+		log.Errorf("handleWaitCommitApproval: api error, not proceeding: %+v", err)
+		return nil
+	}
+
+	deadline, err := m.commitApprovalDeadline(ctx.Context(), sector, tok)
+	if err != nil {
+		return ctx.Send(SectorCommitFailed{xerrors.Errorf("getting commit approval deadline: %w", err)})
+	}
+
+	timeout := func() error {
+		if !m.dropPendingCommit(sector.SectorNumber) {
+			return nil // approved or rejected meanwhile
+		}
+		log.Warnw("commit message wasn't approved in time", "sector", sector.SectorNumber, "deadline", deadline)
+		return ctx.Send(SectorCommitApprovalExpired{xerrors.Errorf("commit message not approved before epoch %d", deadline)})
+	}
+
+	if height >= deadline {
+		return timeout()
+	}
+
+	err = m.events.ChainAt(func(context.Context, TipSetToken, abi.ChainEpoch) error {
+		return timeout()
+	}, func(context.Context, TipSetToken) error {
+		return nil
+	}, InteractivePoRepConfidence, deadline)
+	if err != nil {
+		log.Warnf("handleWaitCommitApproval ChainAt errored: %+v", err)
+	}
+
+	return nil
+}
+
+// commitApprovalDeadline returns the epoch until which the commit message of
+// the sector may wait for approval: CommitApprovalMargin epochs before the
+// precommit expires, past which the ProveCommit message would be rejected
+func (m *Sealing) commitApprovalDeadline(ctx context.Context, sector SectorInfo, tok TipSetToken) (abi.ChainEpoch, error) {
+	pci, err := m.api.StateSectorPreCommitInfo(ctx, m.maddr, sector.SectorNumber, tok)
+	if err != nil {
+		return 0, xerrors.Errorf("getting precommit info: %w", err)
+	}
+	if pci == nil {
+		return 0, xerrors.Errorf("precommit info not found on chain")
+	}
+
+	nv, err := m.api.StateNetworkVersion(ctx, tok)
+	if err != nil {
+		return 0, xerrors.Errorf("getting network version: %w", err)
+	}
+
+	msd := policy.GetMaxProveCommitDuration(actors.VersionForNetwork(nv), sector.SectorType)
+	return pci.PreCommitEpoch + msd - CommitApprovalMargin, nil
+}
+
+// dropPendingCommit forgets the pending commit message of the sector, and
+// returns whether it was still waiting for approval
+func (m *Sealing) dropPendingCommit(sn abi.SectorNumber) bool {
+	m.commitApprovalLk.Lock()
+	defer m.commitApprovalLk.Unlock()
+
+	_, pending := m.pendingCommits[sn]
+	delete(m.pendingCommits, sn)
+	return pending
+}
+
+func (m *Sealing) addPendingCommit(pc sealiface.PendingCommit) {
+	m.commitApprovalLk.Lock()
+	defer m.commitApprovalLk.Unlock()
+
+	m.pendingCommits[pc.Sector] = pc
+}
+
+// takeCommitApproval returns whether the commit message of the sector was
+// approved, consuming the approval
+func (m *Sealing) takeCommitApproval(sn abi.SectorNumber) bool {
+	m.commitApprovalLk.Lock()
+	defer m.commitApprovalLk.Unlock()
+
+	_, approved := m.approvedCommits[sn]
+	delete(m.approvedCommits, sn)
+	return approved
+}
+
+// PendingCommits lists the commit messages waiting for operator approval
+func (m *Sealing) PendingCommits() []sealiface.PendingCommit {
+	m.commitApprovalLk.Lock()
+	defer m.commitApprovalLk.Unlock()
+
+	out := make([]sealiface.PendingCommit, 0, len(m.pendingCommits))
+	for _, pc := range m.pendingCommits {
+		out = append(out, pc)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Sector < out[j].Sector
+	})
+	return out
+}
+
+// ApproveCommit lets a pending commit message be sent to the chain
+func (m *Sealing) ApproveCommit(ctx context.Context, sn abi.SectorNumber) error {
+	m.commitApprovalLk.Lock()
+	if _, ok := m.pendingCommits[sn]; !ok {
+		m.commitApprovalLk.Unlock()
+		return xerrors.Errorf("sector %d has no commit message waiting for approval", sn)
+	}
+	delete(m.pendingCommits, sn)
+	m.approvedCommits[sn] = struct{}{}
+	m.commitApprovalLk.Unlock()
+
+	return m.sectors.Send(uint64(sn), SectorCommitApproved{})
+}
+
+// RejectCommit drops a pending commit message, and has the sector compute
+// its proof again
+func (m *Sealing) RejectCommit(ctx context.Context, sn abi.SectorNumber) error {
+	m.commitApprovalLk.Lock()
+	if _, ok := m.pendingCommits[sn]; !ok {
+		m.commitApprovalLk.Unlock()
+		return xerrors.Errorf("sector %d has no commit message waiting for approval", sn)
+	}
+	delete(m.pendingCommits, sn)
+	m.commitApprovalLk.Unlock()
+
+	return m.sectors.Send(uint64(sn), SectorCommitRejected{})
+}
@@ -2,3 +2,8 @@ package sealing
 
 // Epochs
 const InteractivePoRepConfidence = 6
+
+// Commit messages not approved this many epochs before the ProveCommit
+// deadline aren't waited for anymore, so that they'd still land in time; the
+// sector is held in CommitApprovalExpired for the operator
+const CommitApprovalMargin = 120
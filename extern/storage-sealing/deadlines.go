@@ -0,0 +1,217 @@
+package sealing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+const deadlineCheckInterval = 5 * time.Minute
+
+const (
+	DeadlinePreCommit = "precommit"
+	DeadlineCommit    = "commit"
+)
+
+// DeadlineAlert describes a sector projected to miss the epoch by which its
+// PreCommit or ProveCommit message has to land on chain
+type DeadlineAlert struct {
+	Sector abi.SectorNumber
+	State  SectorState
+
+	Deadline      string // DeadlinePreCommit or DeadlineCommit
+	DeadlineEpoch abi.ChainEpoch
+	DeadlineTime  time.Time // estimated from the current height
+	Projected     time.Time // when the message is expected to land
+}
+
+// The share of the expected PreCommit / ProveCommit duration still ahead of a
+// sector in a given state. The projection is counted from now, so sectors
+// waiting in a state, e.g. queued for a worker, fall further behind.
+var (
+	preCommitRemaining = map[SectorState]float64{
+		PreCommit1:           1,
+		SealPreCommit1Failed: 1,
+		PreCommit2:           0.25,
+		SealPreCommit2Failed: 0.25,
+		PreCommitting:        0.05,
+		PreCommitFailed:      0.05,
+		PreCommitWait:        0.05,
+	}
+	commitRemaining = map[SectorState]float64{
+		WaitSeed:           1,
+		Committing:         1,
+		ComputeProofFailed: 1,
+		SubmitCommit:       0.05,
+		WaitCommitApproval: 0.05,
+		CommitWait:         0.05,
+		CommitFailed:       0.05,
+	}
+)
+
+func (m *Sealing) trackDeadlines(ctx context.Context) {
+	t := time.NewTicker(deadlineCheckInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			if err := m.checkDeadlines(ctx); err != nil {
+				log.Errorf("checking sector deadlines: %+v", err)
+			}
+			if err := m.checkStuck(ctx); err != nil {
+				log.Errorf("checking stuck sectors: %+v", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *Sealing) checkDeadlines(ctx context.Context) error {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting sealing config: %w", err)
+	}
+	if cfg.ExpectedPreCommitDuration == 0 && cfg.ExpectedCommitDuration == 0 {
+		return nil
+	}
+
+	tok, height, err := m.api.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+	nv, err := m.api.StateNetworkVersion(ctx, tok)
+	if err != nil {
+		return xerrors.Errorf("getting network version: %w", err)
+	}
+	sectors, err := m.ListSectors()
+	if err != nil {
+		return xerrors.Errorf("listing sectors: %w", err)
+	}
+
+	now := time.Now()
+	atRisk := map[string]int64{DeadlinePreCommit: 0, DeadlineCommit: 0}
+	alerted := map[abi.SectorNumber]string{}
+
+	for _, si := range sectors {
+		var preCommitEpoch abi.ChainEpoch
+		if _, ok := commitRemaining[si.State]; ok && cfg.ExpectedCommitDuration > 0 {
+			pci, err := m.api.StateSectorPreCommitInfo(ctx, m.maddr, si.SectorNumber, tok)
+			if err != nil {
+				log.Warnw("getting precommit info for deadline check", "sector", si.SectorNumber, "error", err)
+				continue
+			}
+			if pci == nil {
+				continue
+			}
+			preCommitEpoch = pci.PreCommitEpoch
+		}
+
+		msd := policy.GetMaxProveCommitDuration(actors.VersionForNetwork(nv), si.SectorType)
+		alert := projectDeadline(cfg, si, height, msd, preCommitEpoch, now)
+		if alert == nil {
+			continue
+		}
+
+		atRisk[alert.Deadline]++
+		alerted[si.SectorNumber] = alert.Deadline
+		if m.deadlineAlerts[si.SectorNumber] == alert.Deadline {
+			continue // already alerted
+		}
+
+		log.Warnw("sector projected to miss its deadline",
+			"sector", si.SectorNumber, "state", si.State, "deadline", alert.Deadline,
+			"deadlineEpoch", alert.DeadlineEpoch, "deadlineTime", alert.DeadlineTime, "projected", alert.Projected)
+		if cfg.DeadlineAlertWebhook != "" {
+			go postDeadlineAlert(cfg.DeadlineAlertWebhook, *alert)
+		}
+	}
+	m.deadlineAlerts = alerted
+
+	for dl, n := range atRisk {
+		ctx, _ := tag.New(ctx, tag.Upsert(metrics.SealDeadline, dl))
+		stats.Record(ctx, metrics.SectorsAtRisk.M(n))
+	}
+	return nil
+}
+
+// projectDeadline returns an alert if the sector is projected to miss its
+// PreCommit or ProveCommit deadline. preCommitEpoch is the epoch the
+// PreCommit landed at, 0 if it hasn't.
+func projectDeadline(cfg sealiface.Config, si SectorInfo, height abi.ChainEpoch, maxProveCommitDuration abi.ChainEpoch, preCommitEpoch abi.ChainEpoch, now time.Time) *DeadlineAlert {
+	blockDelay := time.Duration(build.BlockDelaySecs) * time.Second
+	epochTime := func(e abi.ChainEpoch) time.Time {
+		return now.Add(time.Duration(e-height) * blockDelay)
+	}
+
+	var (
+		kind      string
+		deadline  abi.ChainEpoch
+		projected time.Time
+	)
+	if share, ok := preCommitRemaining[si.State]; ok && cfg.ExpectedPreCommitDuration > 0 && si.TicketEpoch != 0 {
+		kind = DeadlinePreCommit
+		deadline = si.TicketEpoch + policy.SealRandomnessLookback + maxProveCommitDuration
+		projected = now.Add(time.Duration(share * float64(cfg.ExpectedPreCommitDuration)))
+	} else if share, ok := commitRemaining[si.State]; ok && cfg.ExpectedCommitDuration > 0 && preCommitEpoch != 0 {
+		kind = DeadlineCommit
+		deadline = preCommitEpoch + maxProveCommitDuration
+
+		// nothing happens before the seed is available
+		projected = now
+		if seed := preCommitEpoch + policy.GetPreCommitChallengeDelay(); seed > height {
+			projected = epochTime(seed)
+		}
+		projected = projected.Add(time.Duration(share * float64(cfg.ExpectedCommitDuration)))
+	} else {
+		return nil
+	}
+
+	if projected.Before(epochTime(deadline)) {
+		return nil
+	}
+
+	return &DeadlineAlert{
+		Sector:        si.SectorNumber,
+		State:         si.State,
+		Deadline:      kind,
+		DeadlineEpoch: deadline,
+		DeadlineTime:  epochTime(deadline),
+		Projected:     projected,
+	}
+}
+
+func postDeadlineAlert(url string, alert DeadlineAlert) {
+	b, err := json.Marshal(alert)
+	if err != nil {
+		log.Errorf("marshaling deadline alert: %+v", err)
+		return
+	}
+
+	client := http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		log.Errorw("posting deadline alert", "sector", alert.Sector, "error", err)
+		return
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode/100 != 2 {
+		log.Errorw("posting deadline alert", "sector", alert.Sector, "status", resp.Status)
+	}
+}
@@ -0,0 +1,58 @@
+package sealing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/chain/actors/policy"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+func TestProjectDeadline(t *testing.T) {
+	now := time.Now()
+	blockDelay := time.Duration(build.BlockDelaySecs) * time.Second
+	msd := abi.ChainEpoch(1000)
+	cfg := sealiface.Config{
+		ExpectedPreCommitDuration: 100 * blockDelay,
+		ExpectedCommitDuration:    100 * blockDelay,
+	}
+
+	// the precommit deadline is at epoch 1000+lookback+msd
+	si := SectorInfo{SectorNumber: 1, State: PreCommit1, TicketEpoch: 1000}
+	deadline := si.TicketEpoch + policy.SealRandomnessLookback + msd
+
+	require.Nil(t, projectDeadline(cfg, si, deadline-150, msd, 0, now))
+
+	alert := projectDeadline(cfg, si, deadline-50, msd, 0, now)
+	require.NotNil(t, alert)
+	require.Equal(t, DeadlinePreCommit, alert.Deadline)
+	require.Equal(t, deadline, alert.DeadlineEpoch)
+
+	// most of the work is done in PreCommit2
+	si.State = PreCommit2
+	require.Nil(t, projectDeadline(cfg, si, deadline-50, msd, 0, now))
+
+	// waiting for the seed counts against the commit deadline
+	si.State = WaitSeed
+	preCommitEpoch := abi.ChainEpoch(5000)
+	seed := preCommitEpoch + policy.GetPreCommitChallengeDelay()
+	require.Nil(t, projectDeadline(cfg, si, preCommitEpoch, msd, 0, now), "commit deadline unknown before the precommit lands")
+
+	cmsd := policy.GetPreCommitChallengeDelay() + 150
+	require.Nil(t, projectDeadline(cfg, si, preCommitEpoch, cmsd, preCommitEpoch, now))
+
+	cmsd = policy.GetPreCommitChallengeDelay() + 50
+	alert = projectDeadline(cfg, si, preCommitEpoch, cmsd, preCommitEpoch, now)
+	require.NotNil(t, alert)
+	require.Equal(t, DeadlineCommit, alert.Deadline)
+	require.Equal(t, now.Add(time.Duration(seed-preCommitEpoch)*blockDelay+cfg.ExpectedCommitDuration), alert.Projected)
+
+	// not tracked when disabled
+	cfg.ExpectedCommitDuration = 0
+	require.Nil(t, projectDeadline(cfg, si, preCommitEpoch, cmsd, preCommitEpoch, now))
+}
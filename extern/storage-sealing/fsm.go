@@ -61,6 +61,7 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 		on(SectorPreCommit2{}, PreCommitting),
 		on(SectorSealPreCommit2Failed{}, SealPreCommit2Failed),
 		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
+		on(SectorPreCommit2Mismatch{}, PreCommit2Mismatch),
 	),
 	PreCommitting: planOne(
 		on(SectorSealPreCommit1Failed{}, SealPreCommit1Failed),
@@ -82,8 +83,16 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 	Committing: planCommitting,
 	SubmitCommit: planOne(
 		on(SectorCommitSubmitted{}, CommitWait),
+		on(SectorWaitCommitApproval{}, WaitCommitApproval),
 		on(SectorCommitFailed{}, CommitFailed),
 	),
+	WaitCommitApproval: planOne(
+		on(SectorCommitApproved{}, SubmitCommit),
+		on(SectorCommitRejected{}, Committing),
+		on(SectorRetrySubmitCommit{}, SubmitCommit),
+		on(SectorCommitFailed{}, CommitFailed),
+		on(SectorCommitApprovalExpired{}, CommitApprovalExpired),
+	),
 	CommitWait: planOne(
 		on(SectorProving{}, FinalizeSector),
 		on(SectorCommitFailed{}, CommitFailed),
@@ -104,6 +113,14 @@ var fsmPlanners = map[SectorState]func(events []statemachine.Event, state *Secto
 		on(SectorRetrySealPreCommit1{}, PreCommit1),
 		on(SectorRetrySealPreCommit2{}, PreCommit2),
 	),
+	PreCommit2Mismatch: planOne(
+	// held for the operator, who resumes the sector with sectors update-state,
+	// or removes it; SectorRemove (global)
+	),
+	CommitApprovalExpired: planOne(
+	// held for the operator, who sends the commit with sectors update-state
+	// if there is still time, or removes the sector; SectorRemove (global)
+	),
 	PreCommitFailed: planOne(
 		on(SectorRetryPreCommit{}, PreCommitting),
 		on(SectorRetryPreCommitWait{}, PreCommitWait),
@@ -240,6 +257,8 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 				|   |       *----------++----\
 				|   v       v          ||    |
 				*<- PreCommit2 --------++--> SealPreCommit2Failed
+				|   |  \               ||
+				|   |   \--> PreCommit2Mismatch
 				|   |                  ||
 				|   v          /-------/|
 				*   PreCommitting <-----+---> PreCommitFailed
@@ -299,6 +318,8 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 		return m.handleCommitting, processed, nil
 	case SubmitCommit:
 		return m.handleSubmitCommit, processed, nil
+	case WaitCommitApproval:
+		return m.handleWaitCommitApproval, processed, nil
 	case CommitWait:
 		return m.handleCommitWait, processed, nil
 	case FinalizeSector:
@@ -336,6 +357,11 @@ func (m *Sealing) plan(events []statemachine.Event, state *SectorInfo) (func(sta
 	case RemoveFailed:
 		return m.handleRemoveFailed, processed, nil
 
+	case PreCommit2Mismatch:
+		log.Errorf("sector %d: PreCommit2 results of independent workers differ; check the workers, then resume the sector with 'sectors update-state' or remove it", state.SectorNumber)
+	case CommitApprovalExpired:
+		log.Errorf("sector %d: commit message wasn't approved before the ProveCommit deadline; submit it with 'sectors update-state' if the precommit didn't expire yet, or remove the sector", state.SectorNumber)
+
 		// Faults
 	case Faulty:
 		return m.handleFaulty, processed, nil
@@ -427,11 +453,7 @@ func (m *Sealing) restartSectors(ctx context.Context) error {
 					ssize: ssize,
 				}
 				for _, p := range sector.Pieces {
-					if p.DealInfo != nil {
-						ui.numDeals++
-					}
-					ui.stored += p.Piece.Size
-					ui.pieceSizes = append(ui.pieceSizes, p.Piece.Size.Unpadded())
+					ui.addPiece(p)
 				}
 
 				m.unsealedInfoMap.infos[sector.SectorNumber] = ui
@@ -160,6 +160,13 @@ func (evt SectorSealPreCommit2Failed) apply(si *SectorInfo) {
 	si.PreCommit2Fails++
 }
 
+// SectorPreCommit2Mismatch is sent when independent PreCommit2 runs computed
+// different commitments
+type SectorPreCommit2Mismatch struct{ error }
+
+func (evt SectorPreCommit2Mismatch) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorPreCommit2Mismatch) apply(*SectorInfo)                        {}
+
 type SectorChainPreCommitFailed struct{ error }
 
 func (evt SectorChainPreCommitFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
@@ -197,6 +204,13 @@ type SectorCommitFailed struct{ error }
 func (evt SectorCommitFailed) FormatError(xerrors.Printer) (next error) { return evt.error }
 func (evt SectorCommitFailed) apply(*SectorInfo)                        {}
 
+// SectorCommitApprovalExpired is sent when the commit message of the sector
+// wasn't approved in time to land before the ProveCommit deadline
+type SectorCommitApprovalExpired struct{ error }
+
+func (evt SectorCommitApprovalExpired) FormatError(xerrors.Printer) (next error) { return evt.error }
+func (evt SectorCommitApprovalExpired) apply(*SectorInfo)                        {}
+
 type SectorRetrySubmitCommit struct{}
 
 func (evt SectorRetrySubmitCommit) apply(*SectorInfo) {}
@@ -219,6 +233,20 @@ func (evt SectorCommitted) apply(state *SectorInfo) {
 	state.Proof = evt.Proof
 }
 
+type SectorWaitCommitApproval struct{}
+
+func (evt SectorWaitCommitApproval) apply(*SectorInfo) {}
+
+type SectorCommitApproved struct{}
+
+func (evt SectorCommitApproved) apply(*SectorInfo) {}
+func (evt SectorCommitApproved) Ignore()           {}
+
+type SectorCommitRejected struct{}
+
+func (evt SectorCommitRejected) apply(*SectorInfo) {}
+func (evt SectorCommitRejected) Ignore()           {}
+
 type SectorCommitSubmitted struct {
 	Message cid.Cid
 }
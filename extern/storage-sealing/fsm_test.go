@@ -7,6 +7,7 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-statemachine"
 )
@@ -161,6 +162,31 @@ func TestPlanCommittingHandlesSectorCommitFailed(t *testing.T) {
 	require.Equal(t, CommitFailed, m.state.State)
 }
 
+func TestPreCommit2Mismatch(t *testing.T) {
+	ma, _ := address.NewIDAddress(55151)
+	m := test{
+		s: &Sealing{
+			maddr: ma,
+			stats: SectorStats{
+				bySector: map[abi.SectorID]statSectorState{},
+			},
+		},
+		t:     t,
+		state: &SectorInfo{State: PreCommit2},
+	}
+
+	// the sector is held for the operator instead of being retried
+	m.planSingle(SectorPreCommit2Mismatch{xerrors.New("mismatch")})
+	require.Equal(m.t, m.state.State, PreCommit2Mismatch)
+
+	_, _, err := m.s.plan([]statemachine.Event{{User: SectorRetrySealPreCommit2{}}}, m.state)
+	require.Error(t, err)
+	require.Equal(m.t, m.state.State, PreCommit2Mismatch)
+
+	m.planSingle(SectorRemove{})
+	require.Equal(m.t, m.state.State, Removing)
+}
+
 func TestPlannerList(t *testing.T) {
 	for state := range ExistSectorStateList {
 		_, ok := fsmPlanners[state]
@@ -175,3 +201,55 @@ func TestPlannerList(t *testing.T) {
 		require.True(t, ok, "state %s", state)
 	}
 }
+
+func TestCommitApproval(t *testing.T) {
+	ma, _ := address.NewIDAddress(55151)
+	m := test{
+		s: &Sealing{
+			maddr: ma,
+			stats: SectorStats{
+				bySector: map[abi.SectorID]statSectorState{},
+			},
+		},
+		t:     t,
+		state: &SectorInfo{State: SubmitCommit},
+	}
+
+	// approvals for sectors which aren't waiting for one are ignored
+	m.planSingle(SectorCommitApproved{})
+	require.Equal(m.t, m.state.State, SubmitCommit)
+
+	m.planSingle(SectorWaitCommitApproval{})
+	require.Equal(m.t, m.state.State, WaitCommitApproval)
+
+	m.planSingle(SectorCommitRejected{})
+	require.Equal(m.t, m.state.State, Committing)
+
+	m.planSingle(SectorCommitted{})
+	require.Equal(m.t, m.state.State, SubmitCommit)
+
+	// commits not approved before the deadline are held for the operator
+	// instead of being submitted again
+	m.planSingle(SectorWaitCommitApproval{})
+	require.Equal(m.t, m.state.State, WaitCommitApproval)
+
+	m.planSingle(SectorCommitApprovalExpired{xerrors.New("expired")})
+	require.Equal(m.t, m.state.State, CommitApprovalExpired)
+
+	_, _, err := m.s.plan([]statemachine.Event{{User: SectorRetrySubmitCommit{}}}, m.state)
+	require.Error(t, err)
+	require.Equal(m.t, m.state.State, CommitApprovalExpired)
+
+	// unless the operator sends it anyway
+	m.planSingle(SectorForceState{SubmitCommit})
+	require.Equal(m.t, m.state.State, SubmitCommit)
+
+	m.planSingle(SectorWaitCommitApproval{})
+	require.Equal(m.t, m.state.State, WaitCommitApproval)
+
+	m.planSingle(SectorCommitApproved{})
+	require.Equal(m.t, m.state.State, SubmitCommit)
+
+	m.planSingle(SectorCommitSubmitted{})
+	require.Equal(m.t, m.state.State, CommitWait)
+}
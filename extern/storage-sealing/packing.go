@@ -0,0 +1,116 @@
+package sealing
+
+import (
+	"sort"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
+)
+
+// placementReportSizes is the number of piece sizes, halving from the sector
+// size, listed in the packing report
+const placementReportSizes = 9
+
+type packingCandidate struct {
+	number   abi.SectorNumber
+	pads     []abi.PaddedPieceSize
+	padding  abi.PaddedPieceSize
+	distance abi.ChainEpoch // between the deal start epochs
+	free     abi.PaddedPieceSize
+}
+
+// better orders candidate sectors for a piece by, in order:
+//   - the least padding needed to align the piece, which is wasted space
+//   - deals starting at a similar epoch, so that sectors with deals starting
+//     soon don't wait for deals starting much later to fill them up
+//   - the least space left after the piece (best fit), so that large spaces
+//     stay available for large pieces
+func (c *packingCandidate) better(o *packingCandidate) bool {
+	switch {
+	case c.padding != o.padding:
+		return c.padding < o.padding
+	case c.distance != o.distance:
+		return c.distance < o.distance
+	case c.free != o.free:
+		return c.free < o.free
+	default:
+		return c.number < o.number
+	}
+}
+
+// choosePackingSector picks the open sector to add a deal piece starting at
+// the given epoch to, and the padding pieces needed before it.
+func choosePackingSector(infos map[abi.SectorNumber]UnsealedSectorInfo, size abi.PaddedPieceSize, start abi.ChainEpoch) (abi.SectorNumber, []abi.PaddedPieceSize, bool) {
+	var best *packingCandidate
+	for number, info := range infos {
+		pads, padLength := ffiwrapper.GetRequiredPadding(info.stored, size)
+		if info.stored+size+padLength > abi.PaddedPieceSize(info.ssize) {
+			continue
+		}
+
+		c := &packingCandidate{
+			number:  number,
+			pads:    pads,
+			padding: padLength,
+			free:    abi.PaddedPieceSize(info.ssize) - info.stored - size - padLength,
+		}
+		if info.earliestStart != 0 && start != 0 {
+			c.distance = info.earliestStart - start
+			if c.distance < 0 {
+				c.distance = -c.distance
+			}
+		}
+
+		if best == nil || c.better(best) {
+			best = c
+		}
+	}
+
+	if best == nil {
+		return 0, nil, false
+	}
+	return best.number, best.pads, true
+}
+
+// PackingReport describes the sectors open for deals, and where pieces of
+// various sizes would be placed
+func (m *Sealing) PackingReport() sealiface.PackingReport {
+	m.unsealedInfoMap.lk.Lock()
+	defer m.unsealedInfoMap.lk.Unlock()
+
+	var out sealiface.PackingReport
+	var ssize abi.SectorSize
+	for number, info := range m.unsealedInfoMap.infos {
+		out.Open = append(out.Open, sealiface.OpenSector{
+			Number:            number,
+			Stored:            info.stored,
+			Padding:           info.padding,
+			Free:              abi.PaddedPieceSize(info.ssize) - info.stored,
+			Deals:             info.numDeals,
+			EarliestDealStart: info.earliestStart,
+		})
+		ssize = info.ssize
+	}
+	sort.Slice(out.Open, func(i, j int) bool {
+		return out.Open[i].Number < out.Open[j].Number
+	})
+
+	if ssize == 0 {
+		return out
+	}
+	for i, size := 0, abi.PaddedPieceSize(ssize); i < placementReportSizes; i, size = i+1, size/2 {
+		p := sealiface.PiecePlacement{Size: size, NewSector: true}
+		if number, pads, ok := choosePackingSector(m.unsealedInfoMap.infos, size, 0); ok {
+			p.NewSector = false
+			p.Sector = number
+			for _, pad := range pads {
+				p.Padding += pad
+			}
+		}
+		out.Placements = append(out.Placements, p)
+	}
+
+	return out
+}
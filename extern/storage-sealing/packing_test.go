@@ -0,0 +1,65 @@
+package sealing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+func TestChoosePackingSector(t *testing.T) {
+	const ssize = abi.SectorSize(2048)
+
+	// least padding wins
+	infos := map[abi.SectorNumber]UnsealedSectorInfo{
+		1: {stored: 256, ssize: ssize},
+		2: {stored: 512, ssize: ssize},
+	}
+	sn, pads, ok := choosePackingSector(infos, 512, 0)
+	require.True(t, ok)
+	require.Equal(t, abi.SectorNumber(2), sn)
+	require.Empty(t, pads)
+
+	// then similar deal start epochs
+	infos = map[abi.SectorNumber]UnsealedSectorInfo{
+		1: {stored: 512, ssize: ssize, earliestStart: 1000},
+		2: {stored: 512, ssize: ssize, earliestStart: 5000},
+	}
+	sn, _, ok = choosePackingSector(infos, 512, 4800)
+	require.True(t, ok)
+	require.Equal(t, abi.SectorNumber(2), sn)
+
+	// then the best fit
+	infos = map[abi.SectorNumber]UnsealedSectorInfo{
+		1: {stored: 0, ssize: ssize},
+		2: {stored: 1024, ssize: ssize},
+	}
+	sn, _, ok = choosePackingSector(infos, 1024, 0)
+	require.True(t, ok)
+	require.Equal(t, abi.SectorNumber(2), sn)
+
+	// padding is returned when unavoidable
+	infos = map[abi.SectorNumber]UnsealedSectorInfo{
+		1: {stored: 256, ssize: ssize},
+	}
+	sn, pads, ok = choosePackingSector(infos, 1024, 0)
+	require.True(t, ok)
+	require.Equal(t, abi.SectorNumber(1), sn)
+	require.Equal(t, []abi.PaddedPieceSize{256, 512}, pads)
+
+	_, _, ok = choosePackingSector(infos, 2048, 0)
+	require.False(t, ok)
+}
+
+func TestUnsealedSectorInfoAddPiece(t *testing.T) {
+	var ui UnsealedSectorInfo
+	ui.addPiece(Piece{Piece: abi.PieceInfo{Size: 256}})
+	ui.addPiece(Piece{Piece: abi.PieceInfo{Size: 256}, DealInfo: &DealInfo{DealSchedule: DealSchedule{StartEpoch: 2000}}})
+	ui.addPiece(Piece{Piece: abi.PieceInfo{Size: 512}, DealInfo: &DealInfo{DealSchedule: DealSchedule{StartEpoch: 1000}}})
+
+	require.Equal(t, abi.PaddedPieceSize(1024), ui.stored)
+	require.Equal(t, abi.PaddedPieceSize(256), ui.padding)
+	require.Equal(t, uint64(2), ui.numDeals)
+	require.Equal(t, abi.ChainEpoch(1000), ui.earliestStart)
+}
@@ -0,0 +1,19 @@
+package sealiface
+
+import (
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+// PendingCommit is a ProveCommit message waiting for operator approval
+type PendingCommit struct {
+	Sector abi.SectorNumber
+
+	From       address.Address
+	Collateral abi.TokenAmount
+	ProofSize  int
+
+	GasLimit   int64
+	GasFeeCap  abi.TokenAmount
+	GasPremium abi.TokenAmount
+}
@@ -15,4 +15,24 @@ type Config struct {
 	MaxSealingSectorsForDeals uint64
 
 	WaitDealsDelay time.Duration
+
+	// hold ProveCommit messages until approved by the operator
+	ApproveCommits bool
+
+	// expected time from starting PreCommit1 until the PreCommit message lands,
+	// and from the seed until the ProveCommit message lands; used to alert
+	// about sectors projected to miss their deadlines, 0 = don't track
+	ExpectedPreCommitDuration time.Duration
+	ExpectedCommitDuration    time.Duration
+
+	// also POST deadline alerts as JSON to this URL
+	DeadlineAlertWebhook string
+
+	// time a sector may spend in a state, by state name, before it's reported
+	// as stuck; states not listed aren't checked
+	MaxStateDuration map[string]time.Duration
+
+	// abort the worker calls of sectors stuck in states running on workers,
+	// so that they fail and are retried
+	RetryStuckSectors bool
 }
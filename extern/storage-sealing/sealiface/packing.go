@@ -0,0 +1,31 @@
+package sealiface
+
+import "github.com/filecoin-project/go-state-types/abi"
+
+// PackingReport describes the sectors open for deals, and where the deal
+// packer would place pieces of each size
+type PackingReport struct {
+	Open       []OpenSector
+	Placements []PiecePlacement
+}
+
+// OpenSector is a sector accepting deal pieces
+type OpenSector struct {
+	Number abi.SectorNumber
+
+	Stored  abi.PaddedPieceSize // including padding
+	Padding abi.PaddedPieceSize // wasted on alignment
+	Free    abi.PaddedPieceSize
+
+	Deals             uint64
+	EarliestDealStart abi.ChainEpoch // 0 without deals
+}
+
+// PiecePlacement is where the deal packer would place a piece of a given size
+type PiecePlacement struct {
+	Size abi.PaddedPieceSize
+
+	NewSector bool // no open sector fits the piece
+	Sector    abi.SectorNumber
+	Padding   abi.PaddedPieceSize // needed before the piece
+}
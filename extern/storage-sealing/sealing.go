@@ -28,6 +28,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/extern/sector-storage/ffiwrapper"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 )
 
 const SectorStorePrefix = "/sectors"
@@ -61,6 +62,7 @@ type SealingAPI interface {
 	StateMarketStorageDeal(context.Context, abi.DealID, TipSetToken) (market.DealProposal, error)
 	StateNetworkVersion(ctx context.Context, tok TipSetToken) (network.Version, error)
 	SendMsg(ctx context.Context, from, to address.Address, method abi.MethodNum, value, maxFee abi.TokenAmount, params []byte) (cid.Cid, error)
+	EstimateMsgGas(ctx context.Context, from, to address.Address, method abi.MethodNum, value, maxFee abi.TokenAmount, params []byte) (MsgGasEstimate, error)
 	ChainHead(ctx context.Context) (TipSetToken, abi.ChainEpoch, error)
 	ChainGetRandomnessFromBeacon(ctx context.Context, tok TipSetToken, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error)
 	ChainGetRandomnessFromTickets(ctx context.Context, tok TipSetToken, personalization crypto.DomainSeparationTag, randEpoch abi.ChainEpoch, entropy []byte) (abi.Randomness, error)
@@ -95,6 +97,13 @@ type Sealing struct {
 	stats SectorStats
 
 	getConfig GetSealingConfigFunc
+
+	commitApprovalLk sync.Mutex
+	pendingCommits   map[abi.SectorNumber]sealiface.PendingCommit // waiting for approval
+	approvedCommits  map[abi.SectorNumber]struct{}
+
+	deadlineAlerts map[abi.SectorNumber]string  // sectors already alerted about, by deadline
+	stuckAlerts    map[abi.SectorID]SectorState // sectors already reported stuck, by state
 }
 
 type FeeConfig struct {
@@ -113,6 +122,9 @@ type UnsealedSectorInfo struct {
 	stored     abi.PaddedPieceSize
 	pieceSizes []abi.UnpaddedPieceSize
 	ssize      abi.SectorSize
+
+	padding       abi.PaddedPieceSize // part of stored
+	earliestStart abi.ChainEpoch      // of the deals in the sector, 0 if there are none
 }
 
 func New(api SealingAPI, fc FeeConfig, events Events, maddr address.Address, ds datastore.Batching, sealer sectorstorage.SectorManager, sc SectorIDCounter, verif ffiwrapper.Verifier, pcp PreCommitPolicy, gc GetSealingConfigFunc, notifee SectorStateNotifee, as AddrSel) *Sealing {
@@ -141,6 +153,9 @@ func New(api SealingAPI, fc FeeConfig, events Events, maddr address.Address, ds
 		stats: SectorStats{
 			bySector: map[abi.SectorID]statSectorState{},
 		},
+
+		pendingCommits:  map[abi.SectorNumber]sealiface.PendingCommit{},
+		approvedCommits: map[abi.SectorNumber]struct{}{},
 	}
 
 	s.sectors = statemachine.New(namespace.Wrap(ds, datastore.NewKey(SectorStorePrefix)), s, SectorInfo{})
@@ -154,6 +169,8 @@ func (m *Sealing) Run(ctx context.Context) error {
 		return xerrors.Errorf("failed load sector states: %w", err)
 	}
 
+	go m.trackDeadlines(ctx)
+
 	return nil
 }
 
@@ -183,7 +200,7 @@ func (m *Sealing) AddPieceToAnySector(ctx context.Context, size abi.UnpaddedPiec
 
 	m.unsealedInfoMap.lk.Lock()
 
-	sid, pads, err := m.getSectorAndPadding(ctx, size)
+	sid, pads, err := m.getSectorAndPadding(ctx, size, d.DealSchedule.StartEpoch)
 	if err != nil {
 		m.unsealedInfoMap.lk.Unlock()
 		return 0, 0, xerrors.Errorf("getting available sector: %w", err)
@@ -245,16 +262,9 @@ func (m *Sealing) addPiece(ctx context.Context, sectorID abi.SectorNumber, size
 	}
 
 	ui := m.unsealedInfoMap.infos[sectorID]
-	num := m.unsealedInfoMap.infos[sectorID].numDeals
-	if di != nil {
-		num = num + 1
-	}
-	m.unsealedInfoMap.infos[sectorID] = UnsealedSectorInfo{
-		numDeals:   num,
-		stored:     ui.stored + piece.Piece.Size,
-		pieceSizes: append(ui.pieceSizes, piece.Piece.Size.Unpadded()),
-		ssize:      ssize,
-	}
+	ui.addPiece(piece)
+	ui.ssize = ssize
+	m.unsealedInfoMap.infos[sectorID] = ui
 
 	return nil
 }
@@ -286,15 +296,26 @@ func (m *Sealing) StartPacking(sectorID abi.SectorNumber) error {
 	return nil
 }
 
+func (ui *UnsealedSectorInfo) addPiece(p Piece) {
+	ui.stored += p.Piece.Size
+	ui.pieceSizes = append(ui.pieceSizes, p.Piece.Size.Unpadded())
+
+	if p.DealInfo == nil {
+		ui.padding += p.Piece.Size
+		return
+	}
+
+	ui.numDeals++
+	if start := p.DealInfo.DealSchedule.StartEpoch; ui.earliestStart == 0 || (start != 0 && start < ui.earliestStart) {
+		ui.earliestStart = start
+	}
+}
+
 // Caller should hold m.unsealedInfoMap.lk
-func (m *Sealing) getSectorAndPadding(ctx context.Context, size abi.UnpaddedPieceSize) (abi.SectorNumber, []abi.PaddedPieceSize, error) {
+func (m *Sealing) getSectorAndPadding(ctx context.Context, size abi.UnpaddedPieceSize, start abi.ChainEpoch) (abi.SectorNumber, []abi.PaddedPieceSize, error) {
 	for tries := 0; tries < 100; tries++ {
-		for k, v := range m.unsealedInfoMap.infos {
-			pads, padLength := ffiwrapper.GetRequiredPadding(v.stored, size.Padded())
-
-			if v.stored+size.Padded()+padLength <= abi.PaddedPieceSize(v.ssize) {
-				return k, pads, nil
-			}
+		if sn, pads, ok := choosePackingSector(m.unsealedInfoMap.infos, size.Padded(), start); ok {
+			return sn, pads, nil
 		}
 
 		if len(m.unsealedInfoMap.infos) > 0 {
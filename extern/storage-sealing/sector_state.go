@@ -3,60 +3,65 @@ package sealing
 type SectorState string
 
 var ExistSectorStateList = map[SectorState]struct{}{
-	Empty:                {},
-	WaitDeals:            {},
-	Packing:              {},
-	GetTicket:            {},
-	PreCommit1:           {},
-	PreCommit2:           {},
-	PreCommitting:        {},
-	PreCommitWait:        {},
-	WaitSeed:             {},
-	Committing:           {},
-	SubmitCommit:         {},
-	CommitWait:           {},
-	FinalizeSector:       {},
-	Proving:              {},
-	FailedUnrecoverable:  {},
-	SealPreCommit1Failed: {},
-	SealPreCommit2Failed: {},
-	PreCommitFailed:      {},
-	ComputeProofFailed:   {},
-	CommitFailed:         {},
-	PackingFailed:        {},
-	FinalizeFailed:       {},
-	DealsExpired:         {},
-	RecoverDealIDs:       {},
-	Faulty:               {},
-	FaultReported:        {},
-	FaultedFinal:         {},
-	Removing:             {},
-	RemoveFailed:         {},
-	Removed:              {},
+	Empty:                 {},
+	WaitDeals:             {},
+	Packing:               {},
+	GetTicket:             {},
+	PreCommit1:            {},
+	PreCommit2:            {},
+	PreCommitting:         {},
+	PreCommitWait:         {},
+	WaitSeed:              {},
+	Committing:            {},
+	SubmitCommit:          {},
+	WaitCommitApproval:    {},
+	CommitWait:            {},
+	FinalizeSector:        {},
+	Proving:               {},
+	FailedUnrecoverable:   {},
+	SealPreCommit1Failed:  {},
+	SealPreCommit2Failed:  {},
+	PreCommit2Mismatch:    {},
+	PreCommitFailed:       {},
+	ComputeProofFailed:    {},
+	CommitFailed:          {},
+	CommitApprovalExpired: {},
+	PackingFailed:         {},
+	FinalizeFailed:        {},
+	DealsExpired:          {},
+	RecoverDealIDs:        {},
+	Faulty:                {},
+	FaultReported:         {},
+	FaultedFinal:          {},
+	Removing:              {},
+	RemoveFailed:          {},
+	Removed:               {},
 }
 
 const (
 	UndefinedSectorState SectorState = ""
 
 	// happy path
-	Empty          SectorState = "Empty"
-	WaitDeals      SectorState = "WaitDeals"     // waiting for more pieces (deals) to be added to the sector
-	Packing        SectorState = "Packing"       // sector not in sealStore, and not on chain
-	GetTicket      SectorState = "GetTicket"     // generate ticket
-	PreCommit1     SectorState = "PreCommit1"    // do PreCommit1
-	PreCommit2     SectorState = "PreCommit2"    // do PreCommit2
-	PreCommitting  SectorState = "PreCommitting" // on chain pre-commit
-	PreCommitWait  SectorState = "PreCommitWait" // waiting for precommit to land on chain
-	WaitSeed       SectorState = "WaitSeed"      // waiting for seed
-	Committing     SectorState = "Committing"    // compute PoRep
-	SubmitCommit   SectorState = "SubmitCommit"  // send commit message to the chain
-	CommitWait     SectorState = "CommitWait"    // wait for the commit message to land on chain
-	FinalizeSector SectorState = "FinalizeSector"
-	Proving        SectorState = "Proving"
+	Empty              SectorState = "Empty"
+	WaitDeals          SectorState = "WaitDeals"          // waiting for more pieces (deals) to be added to the sector
+	Packing            SectorState = "Packing"            // sector not in sealStore, and not on chain
+	GetTicket          SectorState = "GetTicket"          // generate ticket
+	PreCommit1         SectorState = "PreCommit1"         // do PreCommit1
+	PreCommit2         SectorState = "PreCommit2"         // do PreCommit2
+	PreCommitting      SectorState = "PreCommitting"      // on chain pre-commit
+	PreCommitWait      SectorState = "PreCommitWait"      // waiting for precommit to land on chain
+	WaitSeed           SectorState = "WaitSeed"           // waiting for seed
+	Committing         SectorState = "Committing"         // compute PoRep
+	SubmitCommit       SectorState = "SubmitCommit"       // send commit message to the chain
+	WaitCommitApproval SectorState = "WaitCommitApproval" // commit message waiting for operator approval
+	CommitWait         SectorState = "CommitWait"         // wait for the commit message to land on chain
+	FinalizeSector     SectorState = "FinalizeSector"
+	Proving            SectorState = "Proving"
 	// error modes
 	FailedUnrecoverable  SectorState = "FailedUnrecoverable"
 	SealPreCommit1Failed SectorState = "SealPreCommit1Failed"
 	SealPreCommit2Failed SectorState = "SealPreCommit2Failed"
+	PreCommit2Mismatch   SectorState = "PreCommit2Mismatch" // verification of PreCommit2 failed, held for operator review
 	PreCommitFailed      SectorState = "PreCommitFailed"
 	ComputeProofFailed   SectorState = "ComputeProofFailed"
 	CommitFailed         SectorState = "CommitFailed"
@@ -65,6 +70,10 @@ const (
 	DealsExpired         SectorState = "DealsExpired"
 	RecoverDealIDs       SectorState = "RecoverDealIDs"
 
+	// commit message not approved before the ProveCommit deadline, held for
+	// operator review
+	CommitApprovalExpired SectorState = "CommitApprovalExpired"
+
 	Faulty        SectorState = "Faulty"        // sector is corrupted or gone for some reason
 	FaultReported SectorState = "FaultReported" // sector has been declared as a fault on chain
 	FaultedFinal  SectorState = "FaultedFinal"  // fault declared on chain
@@ -76,7 +85,7 @@ const (
 
 func toStatState(st SectorState) statSectorState {
 	switch st {
-	case Empty, WaitDeals, Packing, GetTicket, PreCommit1, PreCommit2, PreCommitting, PreCommitWait, WaitSeed, Committing, SubmitCommit, CommitWait, FinalizeSector:
+	case Empty, WaitDeals, Packing, GetTicket, PreCommit1, PreCommit2, PreCommitting, PreCommitWait, WaitSeed, Committing, SubmitCommit, WaitCommitApproval, CommitWait, FinalizeSector:
 		return sstSealing
 	case Proving, Removed, Removing:
 		return sstProving
@@ -19,11 +19,48 @@ import (
 	"github.com/filecoin-project/lotus/chain/actors"
 	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/actors/policy"
+	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 )
 
 var DealSectorPriority = 1024
 var MaxTicketAge = abi.ChainEpoch(builtin0.EpochsInDay * 2)
 
+// callCtx returns the context for sealing calls on the sector, carrying its
+// priority, and the provenance of its pieces, which workers log
+func (m *Sealing) callCtx(ctx context.Context, sector SectorInfo) context.Context {
+	ctx = sector.sealingCtx(ctx)
+	if !sector.hasDeals() {
+		return ctx
+	}
+
+	tok, _, headErr := m.api.ChainHead(ctx)
+	if headErr != nil {
+		log.Warnw("getting chain head, deal clients won't be attached to sealing calls", "sector", sector.SectorNumber, "error", headErr)
+	}
+
+	meta := make([]storiface.PieceMeta, len(sector.Pieces))
+	for i, p := range sector.Pieces {
+		if p.DealInfo == nil {
+			continue
+		}
+		meta[i].DealID = p.DealInfo.DealID
+
+		if headErr != nil {
+			continue
+		}
+		proposal, err := m.api.StateMarketStorageDeal(ctx, p.DealInfo.DealID, tok)
+		if err != nil {
+			log.Warnw("getting deal proposal for piece metadata", "sector", sector.SectorNumber, "deal", p.DealInfo.DealID, "error", err)
+			continue
+		}
+		meta[i].Client = proposal.Client
+	}
+
+	return sectorstorage.WithPieceMeta(ctx, meta)
+}
+
 func (m *Sealing) handlePacking(ctx statemachine.Context, sector SectorInfo) error {
 	log.Infow("performing filling up rest of the sector...", "sector", sector.SectorNumber)
 
@@ -151,7 +188,7 @@ func (m *Sealing) handlePreCommit1(ctx statemachine.Context, sector SectorInfo)
 		return ctx.Send(SectorOldTicket{}) // go get new ticket
 	}
 
-	pc1o, err := m.sealer.SealPreCommit1(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorType, sector.SectorNumber), sector.TicketValue, sector.pieceInfos())
+	pc1o, err := m.sealer.SealPreCommit1(m.callCtx(ctx.Context(), sector), m.minerSector(sector.SectorType, sector.SectorNumber), sector.TicketValue, sector.pieceInfos())
 	if err != nil {
 		return ctx.Send(SectorSealPreCommit1Failed{xerrors.Errorf("seal pre commit(1) failed: %w", err)})
 	}
@@ -163,6 +200,9 @@ func (m *Sealing) handlePreCommit1(ctx statemachine.Context, sector SectorInfo)
 
 func (m *Sealing) handlePreCommit2(ctx statemachine.Context, sector SectorInfo) error {
 	cids, err := m.sealer.SealPreCommit2(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorType, sector.SectorNumber), sector.PreCommit1Out)
+	if xerrors.Is(err, storiface.ErrPreCommit2Mismatch) {
+		return ctx.Send(SectorPreCommit2Mismatch{xerrors.Errorf("seal pre commit(2) verification failed: %w", err)})
+	}
 	if err != nil {
 		return ctx.Send(SectorSealPreCommit2Failed{xerrors.Errorf("seal pre commit(2) failed: %w", err)})
 	}
@@ -397,7 +437,7 @@ func (m *Sealing) handleCommitting(ctx statemachine.Context, sector SectorInfo)
 		Unsealed: *sector.CommD,
 		Sealed:   *sector.CommR,
 	}
-	c2in, err := m.sealer.SealCommit1(sector.sealingCtx(ctx.Context()), m.minerSector(sector.SectorType, sector.SectorNumber), sector.TicketValue, sector.SeedValue, sector.pieceInfos(), cids)
+	c2in, err := m.sealer.SealCommit1(m.callCtx(ctx.Context(), sector), m.minerSector(sector.SectorType, sector.SectorNumber), sector.TicketValue, sector.SeedValue, sector.pieceInfos(), cids)
 	if err != nil {
 		return ctx.Send(SectorComputeProofFailed{xerrors.Errorf("computing seal proof failed(1): %w", err)})
 	}
@@ -464,6 +504,31 @@ func (m *Sealing) handleSubmitCommit(ctx statemachine.Context, sector SectorInfo
 		return ctx.Send(SectorCommitFailed{xerrors.Errorf("no good address to send commit message from: %w", err)})
 	}
 
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting config: %w", err)
+	}
+
+	if cfg.ApproveCommits && !m.takeCommitApproval(sector.SectorNumber) {
+		gas, err := m.api.EstimateMsgGas(ctx.Context(), from, m.maddr, miner.Methods.ProveCommitSector, collateral, m.feeCfg.MaxCommitGasFee, enc.Bytes())
+		if err != nil {
+			return ctx.Send(SectorCommitFailed{xerrors.Errorf("estimating commit message gas: %w", err)})
+		}
+
+		m.addPendingCommit(sealiface.PendingCommit{
+			Sector:     sector.SectorNumber,
+			From:       from,
+			Collateral: collateral,
+			ProofSize:  len(sector.Proof),
+			GasLimit:   gas.GasLimit,
+			GasFeeCap:  gas.GasFeeCap,
+			GasPremium: gas.GasPremium,
+		})
+		log.Infow("commit message waiting for approval", "sector", sector.SectorNumber)
+
+		return ctx.Send(SectorWaitCommitApproval{})
+	}
+
 	// TODO: check seed / ticket / deals are up to date
 	mcid, err := m.api.SendMsg(ctx.Context(), from, m.maddr, miner.Methods.ProveCommitSector, collateral, m.feeCfg.MaxCommitGasFee, enc.Bytes())
 	if err != nil {
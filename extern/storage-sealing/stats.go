@@ -2,6 +2,7 @@ package sealing
 
 import (
 	"sync"
+	"time"
 
 	"github.com/filecoin-project/go-state-types/abi"
 )
@@ -20,6 +21,14 @@ type SectorStats struct {
 
 	bySector map[abi.SectorID]statSectorState
 	totals   [nsst]uint64
+
+	// when sectors entered their current state, or when the miner started
+	entered map[abi.SectorID]stateEntry
+}
+
+type stateEntry struct {
+	state SectorState
+	at    time.Time
 }
 
 func (ss *SectorStats) updateSector(id abi.SectorID, st SectorState) {
@@ -34,6 +43,32 @@ func (ss *SectorStats) updateSector(id abi.SectorID, st SectorState) {
 	sst := toStatState(st)
 	ss.bySector[id] = sst
 	ss.totals[sst]++
+
+	if ss.entered == nil {
+		ss.entered = map[abi.SectorID]stateEntry{}
+	}
+	if ss.entered[id].state != st {
+		ss.entered[id] = stateEntry{state: st, at: time.Now()}
+	}
+}
+
+// stuckSectors returns the sectors which have been in their current state for
+// longer than the maximum duration configured for it
+func (ss *SectorStats) stuckSectors(maxDuration map[string]time.Duration, now time.Time) map[abi.SectorID]stateEntry {
+	ss.lk.Lock()
+	defer ss.lk.Unlock()
+
+	out := map[abi.SectorID]stateEntry{}
+	for id, e := range ss.entered {
+		max, ok := maxDuration[string(e.state)]
+		if !ok || max <= 0 {
+			continue
+		}
+		if now.Sub(e.at) > max {
+			out[id] = e
+		}
+	}
+	return out
 }
 
 // return the number of sectors currently in the sealing pipeline
@@ -0,0 +1,88 @@
+package sealing
+
+import (
+	"context"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/tag"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/metrics"
+)
+
+// SectorCallAborter is implemented by sector managers which can abort the
+// worker calls running for a sector, failing them
+type SectorCallAborter interface {
+	AbortSectorCalls(ctx context.Context, sector abi.SectorID) (int, error)
+}
+
+// States waiting on a worker call; aborting the call fails the sector into a
+// state which retries it.
+var retriableStuckStates = map[SectorState]struct{}{
+	PreCommit1:     {},
+	PreCommit2:     {},
+	Committing:     {},
+	FinalizeSector: {},
+}
+
+func (m *Sealing) checkStuck(ctx context.Context) error {
+	cfg, err := m.getConfig()
+	if err != nil {
+		return xerrors.Errorf("getting sealing config: %w", err)
+	}
+	if len(cfg.MaxStateDuration) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	stuck := m.stats.stuckSectors(cfg.MaxStateDuration, now)
+
+	counts := map[SectorState]int64{}
+	for id, e := range stuck {
+		counts[e.state]++
+		if m.stuckAlerts[id] == e.state {
+			continue // already alerted
+		}
+
+		log.Warnw("sector stuck in state",
+			"sector", id.Number, "state", e.state, "since", e.at, "max", cfg.MaxStateDuration[string(e.state)])
+
+		if cfg.RetryStuckSectors {
+			m.retryStuck(ctx, id, e.state)
+		}
+	}
+
+	alerted := make(map[abi.SectorID]SectorState, len(stuck))
+	for id, e := range stuck {
+		alerted[id] = e.state
+	}
+	m.stuckAlerts = alerted
+
+	// record all checked states, so that the gauge drops back to 0
+	for st := range cfg.MaxStateDuration {
+		ctx, _ := tag.New(ctx, tag.Upsert(metrics.SectorState, st))
+		stats.Record(ctx, metrics.SectorsStuck.M(counts[SectorState(st)]))
+	}
+	return nil
+}
+
+func (m *Sealing) retryStuck(ctx context.Context, id abi.SectorID, st SectorState) {
+	if _, ok := retriableStuckStates[st]; !ok {
+		return
+	}
+
+	aborter, ok := m.sealer.(SectorCallAborter)
+	if !ok {
+		return
+	}
+
+	n, err := aborter.AbortSectorCalls(ctx, id)
+	if err != nil {
+		log.Errorw("aborting calls of stuck sector", "sector", id.Number, "state", st, "error", err)
+		return
+	}
+	log.Warnw("aborted calls of stuck sector, it will be retried", "sector", id.Number, "state", st, "calls", n)
+}
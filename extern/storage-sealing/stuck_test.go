@@ -0,0 +1,39 @@
+package sealing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-state-types/abi"
+)
+
+func TestStuckSectors(t *testing.T) {
+	ss := SectorStats{
+		bySector: map[abi.SectorID]statSectorState{},
+	}
+
+	s1 := abi.SectorID{Miner: 1000, Number: 1}
+	s2 := abi.SectorID{Miner: 1000, Number: 2}
+	ss.updateSector(s1, PreCommit1)
+	ss.updateSector(s2, WaitSeed)
+
+	maxDuration := map[string]time.Duration{
+		string(PreCommit1): time.Hour,
+	}
+
+	require.Empty(t, ss.stuckSectors(maxDuration, time.Now()))
+
+	later := time.Now().Add(2 * time.Hour)
+	stuck := ss.stuckSectors(maxDuration, later)
+	require.Len(t, stuck, 1, "only states with a maximum are checked")
+	require.Equal(t, PreCommit1, stuck[s1].state)
+
+	// events not changing the state don't reset the time
+	ss.updateSector(s1, PreCommit1)
+	require.Len(t, ss.stuckSectors(maxDuration, later), 1)
+
+	ss.updateSector(s1, PreCommit2)
+	require.Empty(t, ss.stuckSectors(maxDuration, later))
+}
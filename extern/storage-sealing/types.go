@@ -195,6 +195,12 @@ type MsgLookup struct {
 	Height    abi.ChainEpoch
 }
 
+type MsgGasEstimate struct {
+	GasLimit   int64
+	GasFeeCap  abi.TokenAmount
+	GasPremium abi.TokenAmount
+}
+
 type MessageReceipt struct {
 	ExitCode exitcode.ExitCode
 	Return   []byte
@@ -0,0 +1,151 @@
+package blockstore
+
+import (
+	"context"
+	"sync/atomic"
+
+	lru "github.com/hashicorp/golang-lru"
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	"go.opencensus.io/stats"
+	"golang.org/x/xerrors"
+)
+
+// Read cache eviction policies
+const (
+	ReadCacheARC = "arc"
+	ReadCache2Q  = "2q"
+)
+
+// Measures of read cache hits and misses; views are in the metrics package,
+// which depends on this one
+var (
+	ReadCacheHit  = stats.Int64("blockstore/read_cache_hit", "Counter for blocks served from the read cache", stats.UnitDimensionless)
+	ReadCacheMiss = stats.Int64("blockstore/read_cache_miss", "Counter for blocks read through the read cache", stats.UnitDimensionless)
+)
+
+// blockCache is the subset of methods shared by the golang-lru caches
+type blockCache interface {
+	Add(key, value interface{})
+	Get(key interface{}) (value interface{}, ok bool)
+	Contains(key interface{}) bool
+	Remove(key interface{})
+	Len() int
+}
+
+// ReadCacheStats are the counters of a ReadCache
+type ReadCacheStats struct {
+	Type string
+	Size int // capacity, in blocks
+	Len  int // blocks currently cached
+
+	Hits   uint64
+	Misses uint64
+}
+
+// ReadCache is a read-through in-memory cache of blocks in front of another
+// blockstore. Blocks are cached when read, writes go straight to the
+// underlying blockstore.
+type ReadCache struct {
+	Blockstore
+
+	typ   string
+	size  int
+	cache blockCache
+
+	hits, misses uint64
+}
+
+var _ Blockstore = (*ReadCache)(nil)
+var _ Viewer = (*ReadCache)(nil)
+
+// NewReadCache wraps the blockstore in a cache holding up to size blocks,
+// evicted with the given policy: ReadCacheARC or ReadCache2Q.
+func NewReadCache(bs Blockstore, typ string, size int) (*ReadCache, error) {
+	var (
+		cache blockCache
+		err   error
+	)
+	switch typ {
+	case ReadCacheARC:
+		cache, err = lru.NewARC(size)
+	case ReadCache2Q:
+		cache, err = lru.New2Q(size)
+	default:
+		return nil, xerrors.Errorf("unknown read cache type %q", typ)
+	}
+	if err != nil {
+		return nil, xerrors.Errorf("creating read cache: %w", err)
+	}
+
+	return &ReadCache{
+		Blockstore: bs,
+		typ:        typ,
+		size:       size,
+		cache:      cache,
+	}, nil
+}
+
+func (c *ReadCache) cached(k cid.Cid) (blocks.Block, bool) {
+	v, ok := c.cache.Get(k)
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		stats.Record(context.Background(), ReadCacheMiss.M(1))
+		return nil, false
+	}
+
+	atomic.AddUint64(&c.hits, 1)
+	stats.Record(context.Background(), ReadCacheHit.M(1))
+	return v.(blocks.Block), true
+}
+
+func (c *ReadCache) Get(k cid.Cid) (blocks.Block, error) {
+	if b, ok := c.cached(k); ok {
+		return b, nil
+	}
+
+	b, err := c.Blockstore.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	c.cache.Add(k, b)
+	return b, nil
+}
+
+func (c *ReadCache) View(k cid.Cid, callback func([]byte) error) error {
+	b, err := c.Get(k)
+	if err != nil {
+		return err
+	}
+	return callback(b.RawData())
+}
+
+func (c *ReadCache) Has(k cid.Cid) (bool, error) {
+	if c.cache.Contains(k) {
+		return true, nil
+	}
+	return c.Blockstore.Has(k)
+}
+
+func (c *ReadCache) GetSize(k cid.Cid) (int, error) {
+	if v, ok := c.cache.Get(k); ok {
+		return len(v.(blocks.Block).RawData()), nil
+	}
+	return c.Blockstore.GetSize(k)
+}
+
+func (c *ReadCache) DeleteBlock(k cid.Cid) error {
+	c.cache.Remove(k)
+	return c.Blockstore.DeleteBlock(k)
+}
+
+// Stats returns the cache counters since it was created
+func (c *ReadCache) Stats() ReadCacheStats {
+	return ReadCacheStats{
+		Type:   c.typ,
+		Size:   c.size,
+		Len:    c.cache.Len(),
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
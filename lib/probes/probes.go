@@ -0,0 +1,89 @@
+// Package probes implements HTTP liveness / readiness probe endpoints, as
+// used by orchestrators like Kubernetes.
+package probes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("probes")
+
+// CheckTimeout bounds the time a single check can take
+var CheckTimeout = 10 * time.Second
+
+// Check returns a non-nil error when the checked component isn't healthy
+type Check func(ctx context.Context) error
+
+type Status struct {
+	OK     bool
+	Checks map[string]string // check name -> "ok" or error
+}
+
+// Run runs all checks in parallel
+func Run(ctx context.Context, checks map[string]Check) Status {
+	st := Status{
+		OK:     true,
+		Checks: make(map[string]string, len(checks)),
+	}
+
+	var lk sync.Mutex
+	var wg sync.WaitGroup
+
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		name, check := name, checks[name]
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			cctx, cancel := context.WithTimeout(ctx, CheckTimeout)
+			defer cancel()
+
+			res := "ok"
+			err := check(cctx)
+			if err != nil {
+				res = err.Error()
+			}
+
+			lk.Lock()
+			defer lk.Unlock()
+
+			st.Checks[name] = res
+			if err != nil {
+				st.OK = false
+			}
+		}()
+	}
+
+	wg.Wait()
+	return st
+}
+
+// Handler returns a handler responding with 200 when all checks pass, and
+// with 503 otherwise. The response body is the JSON encoded Status.
+func Handler(checks map[string]Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		st := Run(r.Context(), checks)
+
+		w.Header().Set("Content-Type", "application/json")
+		if !st.OK {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		if err := json.NewEncoder(w).Encode(st); err != nil {
+			log.Warnf("writing probe response: %+v", err)
+		}
+	}
+}
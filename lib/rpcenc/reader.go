@@ -32,7 +32,9 @@ type StreamType string
 const (
 	Null       StreamType = "null"
 	PushStream StreamType = "push"
-	// TODO: Data transfer handoff to workers?
+	// PullStream streams are fetched by the receiver from the URL in Info,
+	// so the data doesn't flow through the sender
+	PullStream StreamType = "pull"
 )
 
 type ReaderStream struct {
@@ -47,6 +49,9 @@ func ReaderParamEncoder(addr string) jsonrpc.Option {
 		if r, ok := r.(*sealing.NullReader); ok {
 			return reflect.ValueOf(ReaderStream{Type: Null, Info: fmt.Sprint(r.N)}), nil
 		}
+		if r, ok := r.(*URLReader); ok {
+			return reflect.ValueOf(ReaderStream{Type: PullStream, Info: r.URL}), nil
+		}
 
 		reqID := uuid.New()
 		u, err := url.Parse(addr)
@@ -78,6 +83,58 @@ func ReaderParamEncoder(addr string) jsonrpc.Option {
 	})
 }
 
+// URLReader reads data served over HTTP at URL. When passed to a remote
+// worker, only the URL is sent, and the worker downloads the data directly,
+// e.g. from the markets node, instead of it being proxied by the miner. Any
+// credentials must be part of the URL.
+type URLReader struct {
+	URL string
+
+	ctx  context.Context
+	body io.ReadCloser
+	done bool
+}
+
+// NewURLReader creates a reader downloading the data at u; ctx bounds the
+// download
+func NewURLReader(ctx context.Context, u string) *URLReader {
+	return &URLReader{URL: u, ctx: ctx}
+}
+
+func (r *URLReader) Read(p []byte) (int, error) {
+	if r.done {
+		return 0, io.EOF
+	}
+
+	if r.body == nil {
+		req, err := http.NewRequestWithContext(r.ctx, http.MethodGet, r.URL, nil)
+		if err != nil {
+			r.done = true
+			return 0, xerrors.Errorf("creating request for %s: %w", r.URL, err)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			r.done = true
+			return 0, xerrors.Errorf("fetching %s: %w", r.URL, err)
+		}
+		if resp.StatusCode != 200 {
+			b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+			_ = resp.Body.Close()
+			r.done = true
+			return 0, xerrors.Errorf("fetching %s: non-200 status: %s, msg: '%s'", r.URL, resp.Status, string(b))
+		}
+		r.body = resp.Body
+	}
+
+	n, err := r.body.Read(p)
+	if err != nil {
+		r.done = true
+		_ = r.body.Close()
+	}
+	return n, err
+}
+
 type waitReadCloser struct {
 	io.ReadCloser
 	wait chan struct{}
@@ -159,6 +216,12 @@ func ReaderParamDecoder() (http.HandlerFunc, jsonrpc.ServerOption) {
 			return reflect.ValueOf(sealing.NewNullReader(abi.UnpaddedPieceSize(n))), nil
 		}
 
+		if rs.Type == PullStream {
+			// worker calls return before the data is read, which cancels
+			// the call context, so the download isn't bound to it
+			return reflect.ValueOf(NewURLReader(context.Background(), rs.Info)), nil
+		}
+
 		u, err := uuid.Parse(rs.Info)
 		if err != nil {
 			return reflect.Value{}, xerrors.Errorf("parsing reader UUDD: %w", err)
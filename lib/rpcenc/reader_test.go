@@ -4,6 +4,7 @@ import (
 	"context"
 	"io"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
@@ -88,3 +89,53 @@ func TestNullReaderProxy(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, int64(1016), n)
 }
+
+func TestReaderPull(t *testing.T) {
+	var client struct {
+		ReadAll func(ctx context.Context, r io.Reader) ([]byte, error)
+	}
+
+	serverHandler := &ReaderHandler{}
+
+	_, readerServerOpt := ReaderParamDecoder()
+	rpcServer := jsonrpc.NewServer(readerServerOpt)
+	rpcServer.Register("ReaderHandler", serverHandler)
+
+	rpcServ := httptest.NewServer(rpcServer)
+	defer rpcServ.Close()
+
+	// the data is served by a third party, e.g. a markets node; the push
+	// endpoint isn't registered, so the data can only be pulled
+	dataServ := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pooooootato"))
+	}))
+	defer dataServ.Close()
+
+	re := ReaderParamEncoder("http://" + rpcServ.Listener.Addr().String() + "/rpc/streams/v0/push")
+	closer, err := jsonrpc.NewMergeClient(context.Background(), "ws://"+rpcServ.Listener.Addr().String(), "ReaderHandler", []interface{}{&client}, nil, re)
+	require.NoError(t, err)
+
+	defer closer()
+
+	read, err := client.ReadAll(context.TODO(), NewURLReader(context.TODO(), dataServ.URL))
+	require.NoError(t, err)
+	require.Equal(t, "pooooootato", string(read), "potatoes weren't equal")
+}
+
+func TestURLReaderContext(t *testing.T) {
+	dataServ := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("pooooootato"))
+	}))
+	defer dataServ.Close()
+
+	read, err := ioutil.ReadAll(NewURLReader(context.Background(), dataServ.URL))
+	require.NoError(t, err)
+	require.Equal(t, "pooooootato", string(read))
+
+	// the download is bound to the context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = ioutil.ReadAll(NewURLReader(ctx, dataServ.URL))
+	require.Error(t, err)
+}
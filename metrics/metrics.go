@@ -9,6 +9,8 @@ import (
 	"go.opencensus.io/tag"
 
 	rpcmetrics "github.com/filecoin-project/go-jsonrpc/metrics"
+
+	"github.com/filecoin-project/lotus/lib/blockstore"
 )
 
 // Distribution
@@ -28,6 +30,10 @@ var (
 	ReceivedFrom, _ = tag.NewKey("received_from")
 	Endpoint, _     = tag.NewKey("endpoint")
 	APIInterface, _ = tag.NewKey("api") // to distinguish between gateway api and full node api endpoint calls
+	SealDeadline, _ = tag.NewKey("seal_deadline")
+	SectorState, _  = tag.NewKey("sector_state")
+	TaskType, _     = tag.NewKey("task_type")
+	JobState, _     = tag.NewKey("job_state")
 )
 
 // Measures
@@ -57,6 +63,9 @@ var (
 	APIRequestDuration                  = stats.Float64("api/request_duration_ms", "Duration of API requests", stats.UnitMilliseconds)
 	VMFlushCopyDuration                 = stats.Float64("vm/flush_copy_ms", "Time spent in VM Flush Copy", stats.UnitMilliseconds)
 	VMFlushCopyCount                    = stats.Int64("vm/flush_copy_count", "Number of copied objects", stats.UnitDimensionless)
+	SectorsAtRisk                       = stats.Int64("sealing/sectors_at_risk", "Sectors projected to miss a PreCommit or ProveCommit deadline", stats.UnitDimensionless)
+	SectorsStuck                        = stats.Int64("sealing/sectors_stuck", "Sectors in a state for longer than its configured maximum", stats.UnitDimensionless)
+	SchedJobStateDuration               = stats.Float64("sealing/job_state_duration_s", "Time scheduled jobs spent in each state, e.g. transferring or computing", stats.UnitSeconds)
 )
 
 var (
@@ -176,6 +185,38 @@ var (
 		Measure:     VMFlushCopyCount,
 		Aggregation: view.Sum(),
 	}
+	BlockstoreReadCacheHitView = &view.View{
+		Measure:     blockstore.ReadCacheHit,
+		Aggregation: view.Count(),
+	}
+	BlockstoreReadCacheMissView = &view.View{
+		Measure:     blockstore.ReadCacheMiss,
+		Aggregation: view.Count(),
+	}
+	SectorsAtRiskView = &view.View{
+		Measure:     SectorsAtRisk,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{SealDeadline},
+	}
+	SectorsStuckView = &view.View{
+		Measure:     SectorsStuck,
+		Aggregation: view.LastValue(),
+		TagKeys:     []tag.Key{SectorState},
+	}
+	SchedJobStateDurationView = &view.View{
+		Measure: SchedJobStateDuration,
+		TagKeys: []tag.Key{TaskType, JobState},
+		Aggregation: func() *view.Aggregation {
+			var bounds []float64
+			for i := 1; i < 60; i *= 2 { // 1-32s
+				bounds = append(bounds, float64(i))
+			}
+			for i := 1; i <= 48*60; i *= 2 { // 1m-34h
+				bounds = append(bounds, float64(i*60))
+			}
+			return view.Distribution(bounds...)
+		}(),
+	}
 )
 
 // DefaultViews is an array of OpenCensus views for metric gathering purposes
@@ -204,6 +245,11 @@ var DefaultViews = append([]*view.View{
 	APIRequestDurationView,
 	VMFlushCopyCountView,
 	VMFlushCopyDurationView,
+	BlockstoreReadCacheHitView,
+	BlockstoreReadCacheMissView,
+	SectorsAtRiskView,
+	SectorsStuckView,
+	SchedJobStateDurationView,
 },
 	rpcmetrics.DefaultViews...)
 
@@ -58,6 +58,7 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
 	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/lib/blockstore"
 	"github.com/filecoin-project/lotus/lib/peermgr"
 	_ "github.com/filecoin-project/lotus/lib/sigs/bls"
 	_ "github.com/filecoin-project/lotus/lib/sigs/secp"
@@ -186,6 +187,8 @@ func defaults() []Option {
 
 		Override(new(record.Validator), modules.RecordValidator),
 		Override(new(dtypes.Bootstrapper), dtypes.Bootstrapper(false)),
+		Override(new(dtypes.HeadersOnlySync), dtypes.HeadersOnlySync(false)),
+		Override(new(dtypes.TrustedSyncPeers), dtypes.TrustedSyncPeers(nil)),
 		Override(new(dtypes.ShutdownChan), make(chan struct{})),
 
 		// Filecoin modules
@@ -364,6 +367,7 @@ func Online() Option {
 			Override(new(*sectorblocks.SectorBlocks), sectorblocks.NewSectorBlocks),
 			Override(new(*storage.Miner), modules.StorageMiner(config.DefaultStorageMiner().Fees)),
 			Override(new(*storage.AddressSelector), modules.AddressSelector(nil)),
+			Override(new(*storage.MessageSigners), modules.MessageSigners(nil)),
 			Override(new(dtypes.NetworkName), modules.StorageNetworkName),
 
 			Override(new(dtypes.StagingMultiDstore), modules.StagingMultiDatastore),
@@ -401,6 +405,8 @@ func Online() Option {
 			Override(new(dtypes.SetConsiderUnverifiedStorageDealsConfigFunc), modules.NewSetConsideringUnverifiedStorageDealsFunc),
 			Override(new(dtypes.SetSealingConfigFunc), modules.NewSetSealConfigFunc),
 			Override(new(dtypes.GetSealingConfigFunc), modules.NewGetSealConfigFunc),
+			Override(new(dtypes.SetProvingConfigFunc), modules.NewSetProvingConfigFunc),
+			Override(new(dtypes.GetProvingConfigFunc), modules.NewGetProvingConfigFunc),
 			Override(new(dtypes.SetExpectedSealDurationFunc), modules.NewSetExpectedSealDurationFunc),
 			Override(new(dtypes.GetExpectedSealDurationFunc), modules.NewGetExpectedSealDurationFunc),
 		),
@@ -485,6 +491,20 @@ func ConfigFullNode(c interface{}) Option {
 		),
 		Override(new(dtypes.Graphsync), modules.Graphsync(cfg.Client.SimultaneousTransfers)),
 
+		Override(new(*stmgr.StateManager), modules.StateManager(cfg.Chainstore)),
+
+		If(cfg.Chainstore.ReadCacheType != "",
+			Override(new(*blockstore.ReadCache), modules.ChainReadCache(cfg.Chainstore)),
+			Override(new(dtypes.ChainRawBlockstore), From(new(*blockstore.ReadCache))),
+		),
+
+		If(cfg.Sync.HeadersOnly,
+			Override(new(dtypes.HeadersOnlySync), dtypes.HeadersOnlySync(true)),
+			Override(new(dtypes.TrustedSyncPeers), modules.TrustedSyncPeers(cfg.Sync)),
+			// validating messages requires the state the headers-only syncer skips
+			Unset(HandleIncomingMessagesKey),
+		),
+
 		If(cfg.Metrics.HeadNotifs,
 			Override(HeadMetricsKey, metrics.SendHeadNotifs(cfg.Metrics.Nickname)),
 		),
@@ -523,6 +543,7 @@ func ConfigStorageMiner(c interface{}) Option {
 
 		Override(new(sectorstorage.SealerConfig), cfg.Storage),
 		Override(new(*storage.AddressSelector), modules.AddressSelector(&cfg.Addresses)),
+		Override(new(*storage.MessageSigners), modules.MessageSigners(&cfg.Addresses)),
 		Override(new(*storage.Miner), modules.StorageMiner(cfg.Fees)),
 	)
 }
@@ -24,6 +24,9 @@ type FullNode struct {
 	Metrics Metrics
 	Wallet  Wallet
 	Fees    FeeConfig
+
+	Chainstore Chainstore
+	Sync       Sync
 }
 
 // // Common
@@ -34,6 +37,7 @@ type StorageMiner struct {
 
 	Dealmaking DealmakingConfig
 	Sealing    SealingConfig
+	Proving    ProvingConfig
 	Storage    sectorstorage.SealerConfig
 	Fees       MinerFeeConfig
 	Addresses  MinerAddressConfig
@@ -64,6 +68,50 @@ type SealingConfig struct {
 	MaxSealingSectorsForDeals uint64
 
 	WaitDealsDelay Duration
+
+	// Hold ProveCommit messages until approved by the operator with
+	// 'lotus-miner sectors commit approve'. Messages not approved shortly
+	// before the ProveCommit deadline fail the commit.
+	ApproveCommits bool
+
+	// Expected time from starting PreCommit1 until the PreCommit message
+	// lands on chain, and from the seed until the ProveCommit message lands.
+	// Sectors projected to miss their chain deadlines are logged and counted
+	// in the sealing/sectors_at_risk metric; 0 = don't track
+	ExpectedPreCommitDuration Duration
+	ExpectedCommitDuration    Duration
+
+	// When set, deadline alerts are also POSTed as JSON to this URL
+	DeadlineAlertWebhook string
+
+	// Time a sector may spend in a state before it's reported as stuck, by
+	// state name, e.g. PreCommit1 = "12h"; stuck sectors are logged and
+	// counted in the sealing/sectors_stuck metric. States not listed aren't
+	// checked
+	MaxStateDuration map[string]Duration
+
+	// Abort the worker calls of sectors stuck in PreCommit1, PreCommit2,
+	// Committing or FinalizeSector, so that they fail and are retried
+	RetryStuckSectors bool
+}
+
+type ProvingConfig struct {
+	// Epochs before a deadline opens to start generating its proof; 0 = as
+	// soon as the challenge is available
+	GenerateLeadEpochs int64
+
+	// 0 = network limit
+	MaxPartitionsPerMessage int
+
+	// Per-deadline overrides; zero fields fall back to the values above
+	Deadlines []DeadlineProvingConfig
+}
+
+type DeadlineProvingConfig struct {
+	Deadline uint64
+
+	GenerateLeadEpochs      int64
+	MaxPartitionsPerMessage int
 }
 
 type MinerFeeConfig struct {
@@ -77,6 +125,24 @@ type MinerFeeConfig struct {
 type MinerAddressConfig struct {
 	PreCommitControl []string
 	CommitControl    []string
+
+	// Rotation selects how messages are spread across control addresses:
+	// "" uses the first address with enough funds, "round-robin" cycles
+	// through them, "lowest-backlog" picks the one with the fewest pending
+	// messages in the mpool.
+	Rotation string
+
+	// RemoteSigners sign sealing messages, precommits and commits, from the
+	// given public key addresses through a remote signing service, e.g. one
+	// backed by an HSM, rather than with keys in the full node wallet.
+	RemoteSigners map[string]RemoteSignerConfig
+}
+
+type RemoteSignerConfig struct {
+	// URL the messages to sign are POSTed to
+	URL string
+	// Token sent as a bearer token, if set
+	Token string
 }
 
 // API contains configs for API endpoint
@@ -126,6 +192,29 @@ type Wallet struct {
 	DisableLocal  bool
 }
 
+type Chainstore struct {
+	// ReadCacheType selects the eviction policy of the in-memory cache of chain
+	// blocks: "arc" or "2q". Empty disables the cache.
+	ReadCacheType string
+	// ReadCacheSize is the number of blocks the cache holds
+	ReadCacheSize int
+	// ComputeStateCacheSize is the number of StateCompute results kept in
+	// memory, for tools recomputing the same tipsets; 0 disables the cache
+	ComputeStateCacheSize int
+}
+
+type Sync struct {
+	// HeadersOnly follows the chain by header weight without executing
+	// messages. Meant for indexers and other tooling nodes that don't
+	// validate the chain. Headers can't be verified without state, so only
+	// heads announced by TrustedPeers are synced, and State, Gas and Msig
+	// API calls are refused.
+	HeadersOnly bool
+	// TrustedPeers are the peer IDs of validating nodes a headers-only node
+	// follows; required with HeadersOnly
+	TrustedPeers []string
+}
+
 type FeeConfig struct {
 	DefaultMaxFee types.FIL
 }
@@ -159,6 +248,8 @@ func defCommon() Common {
 
 var DefaultDefaultMaxFee = types.MustParseFIL("0.007")
 var DefaultSimultaneousTransfers = uint64(20)
+var DefaultChainReadCacheSize = 1 << 17
+var DefaultComputeStateCacheSize = 64
 
 // DefaultFullNode returns the default config
 func DefaultFullNode() *FullNode {
@@ -170,6 +261,10 @@ func DefaultFullNode() *FullNode {
 		Client: Client{
 			SimultaneousTransfers: DefaultSimultaneousTransfers,
 		},
+		Chainstore: Chainstore{
+			ReadCacheSize:         DefaultChainReadCacheSize,
+			ComputeStateCacheSize: DefaultComputeStateCacheSize,
+		},
 	}
 }
 
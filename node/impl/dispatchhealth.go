@@ -0,0 +1,153 @@
+package impl
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/extern/sector-storage/sealtasks"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+)
+
+// ServeDispatchHealth serves a summary of call dispatch to workers as an HTML
+// page, or as JSON when requested with `Accept: application/json` or `?json`
+func (sm *StorageMinerAPI) ServeDispatchHealth(w http.ResponseWriter, r *http.Request) {
+	if !auth.HasPerm(r.Context(), nil, apistruct.PermRead) {
+		w.WriteHeader(401)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing read permission"})
+		return
+	}
+
+	h, err := sm.StorageMgr.DispatchHealth(r.Context())
+	if err != nil {
+		log.Errorf("getting dispatch health: %+v", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	_, asJSON := r.URL.Query()["json"]
+	if asJSON || strings.Contains(r.Header.Get("Accept"), "application/json") {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(h); err != nil {
+			log.Errorf("writing dispatch health: %+v", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dispatchHealthTpl.Execute(w, newDispatchHealthView(h)); err != nil {
+		log.Errorf("rendering dispatch health: %+v", err)
+	}
+}
+
+type dispatchHealthView struct {
+	storiface.DispatchHealth
+
+	Period  time.Duration
+	Tasks   []sealtasks.TaskType
+	Workers []dispatchHealthWorker
+}
+
+type dispatchHealthWorker struct {
+	ID uuid.UUID
+	storiface.WorkerHealth
+
+	PerHour float64 // completed calls per hour over the stats period
+}
+
+func newDispatchHealthView(h storiface.DispatchHealth) dispatchHealthView {
+	v := dispatchHealthView{
+		DispatchHealth: h,
+		Period:         time.Since(h.Since).Truncate(time.Second),
+	}
+
+	for tt := range h.Queued {
+		v.Tasks = append(v.Tasks, tt)
+	}
+	sort.Slice(v.Tasks, func(i, j int) bool {
+		return v.Tasks[i].Less(v.Tasks[j])
+	})
+
+	for id, wh := range h.Workers {
+		var done uint64
+		for _, n := range wh.Done {
+			done += n
+		}
+
+		dw := dispatchHealthWorker{
+			ID:           id,
+			WorkerHealth: wh,
+		}
+		if v.Period > 0 {
+			dw.PerHour = float64(done) / v.Period.Hours()
+		}
+
+		v.Workers = append(v.Workers, dw)
+	}
+	sort.Slice(v.Workers, func(i, j int) bool {
+		if v.Workers[i].Hostname != v.Workers[j].Hostname {
+			return v.Workers[i].Hostname < v.Workers[j].Hostname
+		}
+		return v.Workers[i].ID.String() < v.Workers[j].ID.String()
+	})
+
+	return v
+}
+
+var dispatchHealthTpl = template.Must(template.New("dispatch_health").Funcs(template.FuncMap{
+	"short": func(tt sealtasks.TaskType) string { return tt.Short() },
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+ <title>Dispatch health</title>
+ <style>
+  body { font-family: monospace; }
+  table { border-collapse: collapse; margin-bottom: 1em; }
+  td, th { border: 1px solid #888; padding: 2px 6px; text-align: left; }
+  .disabled { color: #888; }
+ </style>
+</head>
+<body>
+<h2>Dispatch health</h2>
+<p>Stats collected over {{.Period}}</p>
+
+<h3>Queue</h3>
+<table>
+ <tr><th>Task</th><th>Queued</th></tr>
+ {{range .Tasks}}<tr><td>{{short .}}</td><td>{{index $.Queued .}}</td></tr>
+ {{else}}<tr><td colspan="2">empty</td></tr>
+ {{end}}
+</table>
+
+<h3>Workers</h3>
+<table>
+ <tr><th>Worker</th><th>Host</th><th>Running</th><th>Assigned</th><th>Done</th><th>Failed</th><th>Done/h</th></tr>
+ {{range .Workers}}<tr{{if not .Enabled}} class="disabled"{{end}}>
+  <td>{{.ID}}</td><td>{{.Hostname}}</td><td>{{.Running}}</td><td>{{.Assigned}}</td>
+  <td>{{range $tt, $n := .Done}}{{short $tt}}:{{$n}} {{end}}</td>
+  <td>{{range $tt, $n := .Failed}}{{short $tt}}:{{$n}} {{end}}</td>
+  <td>{{printf "%.2f" .PerHour}}</td>
+ </tr>
+ {{end}}
+</table>
+
+<h3>Recent failures</h3>
+<table>
+ <tr><th>Time</th><th>Worker</th><th>Task</th><th>Sector</th><th>Error</th></tr>
+ {{range .RecentFailures}}<tr>
+  <td>{{.Time.Format "2006-01-02 15:04:05"}}</td><td>{{.Worker}}</td><td>{{short .Task}}</td><td>{{.Call.Sector.Number}}</td><td>{{.Error}}</td>
+ </tr>
+ {{else}}<tr><td colspan="5">none</td></tr>
+ {{end}}
+</table>
+</body>
+</html>
+`))
@@ -30,7 +30,8 @@ type FullNodeAPI struct {
 	full.SyncAPI
 	full.BeaconAPI
 
-	DS dtypes.MetadataDS
+	DS          dtypes.MetadataDS
+	HeadersOnly dtypes.HeadersOnlySync
 }
 
 func (n *FullNodeAPI) CreateBackup(ctx context.Context, fpath string) error {
@@ -57,6 +57,9 @@ type ChainModule struct {
 	fx.In
 
 	Chain *store.ChainStore
+
+	// ReadCache is only set when the chain blockstore read cache is enabled
+	ReadCache *blockstore.ReadCache `optional:"true"`
 }
 
 var _ ChainModuleAPI = (*ChainModule)(nil)
@@ -592,3 +595,10 @@ func (a *ChainAPI) ChainExport(ctx context.Context, nroots abi.ChainEpoch, skipo
 
 	return out, nil
 }
+
+func (a *ChainAPI) ChainBlockstoreCacheStats(context.Context) (blockstore.ReadCacheStats, error) {
+	if a.ReadCache == nil {
+		return blockstore.ReadCacheStats{}, xerrors.Errorf("chain blockstore read cache not enabled")
+	}
+	return a.ReadCache.Stats(), nil
+}
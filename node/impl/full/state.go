@@ -3,10 +3,13 @@ package full
 import (
 	"bytes"
 	"context"
+	"fmt"
+	"reflect"
 	"strconv"
 
 	cid "github.com/ipfs/go-cid"
 	cbor "github.com/ipfs/go-ipld-cbor"
+	cbg "github.com/whyrusleeping/cbor-gen"
 	"go.uber.org/fx"
 	"golang.org/x/xerrors"
 
@@ -524,6 +527,55 @@ func (a *StateAPI) StateDecodeParams(ctx context.Context, toAddr address.Address
 	return paramType, nil
 }
 
+func (a *StateAPI) StateDecodeMessages(ctx context.Context, msgs []api.DecodeMessage, tsk types.TipSetKey) ([]api.DecodedMessage, error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	codes := map[address.Address]cid.Cid{}
+	out := make([]api.DecodedMessage, len(msgs))
+	for i, msg := range msgs {
+		code, ok := codes[msg.To]
+		if !ok {
+			act, err := a.StateManager.LoadActor(ctx, msg.To, ts)
+			if err != nil {
+				out[i].Error = xerrors.Errorf("loading actor: %w", err).Error()
+				continue
+			}
+			code = act.Code
+			codes[msg.To] = code
+		}
+
+		out[i].Actor = builtin.ActorNameByCode(code)
+		m, found := stmgr.MethodsMap[code][msg.Method]
+		if !found {
+			out[i].Error = fmt.Sprintf("unknown method %d for actor %s", msg.Method, out[i].Actor)
+			continue
+		}
+		out[i].Method = m.Name
+
+		params := reflect.New(m.Params.Elem()).Interface().(cbg.CBORUnmarshaler)
+		if err := params.UnmarshalCBOR(bytes.NewReader(msg.Params)); err != nil {
+			out[i].Error = xerrors.Errorf("decoding params: %w", err).Error()
+			continue
+		}
+		out[i].Params = params
+
+		if len(msg.Return) == 0 {
+			continue
+		}
+		ret := reflect.New(m.Ret.Elem()).Interface().(cbg.CBORUnmarshaler)
+		if err := ret.UnmarshalCBOR(bytes.NewReader(msg.Return)); err != nil {
+			out[i].Error = xerrors.Errorf("decoding return: %w", err).Error()
+			continue
+		}
+		out[i].Return = ret
+	}
+
+	return out, nil
+}
+
 // This is on StateAPI because miner.Miner requires this, and MinerAPI requires miner.Miner
 func (a *StateAPI) MinerGetBaseInfo(ctx context.Context, maddr address.Address, epoch abi.ChainEpoch, tsk types.TipSetKey) (*api.MiningBaseInfo, error) {
 	return stmgr.MinerGetBaseInfo(ctx, a.StateManager, a.Beacon, tsk, epoch, maddr, a.ProofVerifier)
@@ -1140,6 +1192,89 @@ func (a *StateAPI) StateMinerInitialPledgeCollateral(ctx context.Context, maddr
 	return types.BigDiv(types.BigMul(initialPledge, initialPledgeNum), initialPledgeDen), nil
 }
 
+func (a *StateAPI) StateMinerTerminationEstimate(ctx context.Context, maddr address.Address, sectorNos *bitfield.BitField, tsk types.TipSetKey) (*api.TerminationEstimate, error) {
+	ts, err := a.Chain.GetTipSetFromKey(tsk)
+	if err != nil {
+		return nil, xerrors.Errorf("loading tipset %s: %w", tsk, err)
+	}
+
+	state, err := a.StateManager.ParentState(ts)
+	if err != nil {
+		return nil, xerrors.Errorf("loading state %s: %w", tsk, err)
+	}
+
+	sectors, err := stmgr.GetMinerSectorSet(ctx, a.StateManager, ts, maddr, sectorNos)
+	if err != nil {
+		return nil, xerrors.Errorf("loading sectors: %w", err)
+	}
+
+	store := a.Chain.Store(ctx)
+
+	var powerSmoothed builtin.FilterEstimate
+	if act, err := state.GetActor(power.Address); err != nil {
+		return nil, xerrors.Errorf("loading power actor: %w", err)
+	} else if s, err := power.Load(store, act); err != nil {
+		return nil, xerrors.Errorf("loading power actor state: %w", err)
+	} else if p, err := s.TotalPowerSmoothed(); err != nil {
+		return nil, xerrors.Errorf("failed to determine total power: %w", err)
+	} else {
+		powerSmoothed = p
+	}
+
+	rewardActor, err := state.GetActor(reward.Address)
+	if err != nil {
+		return nil, xerrors.Errorf("loading reward actor: %w", err)
+	}
+
+	rewardState, err := reward.Load(store, rewardActor)
+	if err != nil {
+		return nil, xerrors.Errorf("loading reward actor state: %w", err)
+	}
+
+	nv := a.StateManager.GetNtwkVersion(ctx, ts.Height())
+
+	out := &api.TerminationEstimate{
+		Epoch:          ts.Height(),
+		Sectors:        make([]api.SectorTerminationEstimate, 0, len(sectors)),
+		TerminationFee: big.Zero(),
+		InitialPledge:  big.Zero(),
+		ExpectedReward: big.Zero(),
+	}
+	for _, sector := range sectors {
+		if sector.Expiration <= ts.Height() {
+			continue
+		}
+
+		ssize, err := sector.SealProof.SectorSize()
+		if err != nil {
+			return nil, xerrors.Errorf("sector %d: getting sector size: %w", sector.SectorNumber, err)
+		}
+		qaPower := builtin.QAPowerForWeight(ssize, sector.Expiration-sector.Activation, sector.DealWeight, sector.VerifiedDealWeight)
+
+		fee, err := rewardState.PledgePenaltyForTermination(sector.ExpectedDayReward, sector.ExpectedStoragePledge, ts.Height()-sector.Activation, powerSmoothed, qaPower, nv)
+		if err != nil {
+			return nil, xerrors.Errorf("sector %d: calculating termination fee: %w", sector.SectorNumber, err)
+		}
+
+		// the day reward recorded on the sector, which the actor also bases
+		// the termination fee on
+		expected := big.Div(big.Mul(sector.ExpectedDayReward, big.NewInt(int64(sector.Expiration-ts.Height()))), big.NewInt(int64(builtin.EpochsInDay)))
+
+		out.Sectors = append(out.Sectors, api.SectorTerminationEstimate{
+			SectorNumber:   sector.SectorNumber,
+			Expiration:     sector.Expiration,
+			TerminationFee: fee,
+			InitialPledge:  sector.InitialPledge,
+			ExpectedReward: expected,
+		})
+		out.TerminationFee = big.Add(out.TerminationFee, fee)
+		out.InitialPledge = big.Add(out.InitialPledge, sector.InitialPledge)
+		out.ExpectedReward = big.Add(out.ExpectedReward, expected)
+	}
+
+	return out, nil
+}
+
 func (a *StateAPI) StateMinerAvailableBalance(ctx context.Context, maddr address.Address, tsk types.TipSetKey) (types.BigInt, error) {
 	ts, err := a.Chain.GetTipSetFromKey(tsk)
 	if err != nil {
@@ -0,0 +1,60 @@
+package impl
+
+import (
+	"reflect"
+	"strings"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/api/apistruct"
+)
+
+// stateMethods are prefixes and names of the methods which need chain state,
+// which nodes syncing headers only don't have
+var stateMethods = []string{"State", "Msig", "Gas", "WalletBalance"}
+
+// HeadersOnlyFullAPI wraps the API of a node syncing headers only so that
+// methods needing chain state return an error instead of answering from
+// state the node never computed or validated
+func HeadersOnlyFullAPI(a api.FullNode) api.FullNode {
+	var out apistruct.FullNodeStruct
+	refuseState(a, &out.Internal)
+	refuseState(a, &out.CommonStruct.Internal)
+	return &out
+}
+
+func refuseState(in interface{}, out interface{}) {
+	rint := reflect.ValueOf(out).Elem()
+	ra := reflect.ValueOf(in)
+
+	for f := 0; f < rint.NumField(); f++ {
+		field := rint.Type().Field(f)
+		fn := ra.MethodByName(field.Name)
+
+		if !needsState(field.Name) {
+			rint.Field(f).Set(fn)
+			continue
+		}
+
+		name := field.Name
+		rint.Field(f).Set(reflect.MakeFunc(field.Type, func(args []reflect.Value) []reflect.Value {
+			out := make([]reflect.Value, field.Type.NumOut())
+			for i := range out {
+				out[i] = reflect.Zero(field.Type.Out(i))
+			}
+			err := xerrors.Errorf("%s: node syncs headers only and has no chain state", name)
+			out[len(out)-1] = reflect.ValueOf(&err).Elem()
+			return out
+		}))
+	}
+}
+
+func needsState(method string) bool {
+	for _, p := range stateMethods {
+		if strings.HasPrefix(method, p) {
+			return true
+		}
+	}
+	return false
+}
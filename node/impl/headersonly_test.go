@@ -0,0 +1,23 @@
+package impl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+func TestHeadersOnlyFullAPI(t *testing.T) {
+	a := HeadersOnlyFullAPI(&FullNodeAPI{})
+
+	_, err := a.StateGetActor(context.Background(), address.Undef, types.EmptyTSK)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "StateGetActor")
+
+	_, err = a.GasEstimateGasPremium(context.Background(), 0, address.Undef, 0, types.EmptyTSK)
+	require.Error(t, err)
+}
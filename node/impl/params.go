@@ -0,0 +1,101 @@
+package impl
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/filecoin-project/go-jsonrpc/auth"
+
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/build"
+)
+
+// paramsDirEnv overrides where proof parameters are kept, as in paramfetch
+const paramsDirEnv = "FIL_PROOFS_PARAMETER_CACHE"
+
+const defaultParamsDir = "/var/tmp/filecoin-proof-parameters"
+
+// paramFile is an entry of the proof parameters manifest; Digest is the one
+// paramfetch checks files with, the first 16 bytes of their blake2b-512 hash
+type paramFile struct {
+	Cid        string `json:"cid"`
+	Digest     string `json:"digest"`
+	SectorSize uint64 `json:"sector_size"`
+
+	// URL the file is served from by the miner, if the miner has it
+	URL string `json:"url,omitempty"`
+}
+
+func paramsDir() string {
+	if dir := os.Getenv(paramsDirEnv); dir != "" {
+		return dir
+	}
+	return defaultParamsDir
+}
+
+// ServeParams serves the Groth16 parameters and verifying keys for the
+// sector size of the miner, so that external sealers use the same ones as the
+// miner without fetching them separately. /params returns the manifest of the
+// files, with their checksums; /params/{file} serves a file.
+func (sm *StorageMinerAPI) ServeParams(w http.ResponseWriter, r *http.Request) {
+	// parameters are public, but only sealers with a token are served
+	if !auth.HasPerm(r.Context(), nil, apistruct.PermRead) {
+		w.WriteHeader(401)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing read permission"})
+		return
+	}
+
+	ssize, err := sm.ActorSectorSize(r.Context(), sm.Miner.Address())
+	if err != nil {
+		log.Errorf("serving params: getting sector size: %+v", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	var all map[string]paramFile
+	if err := json.Unmarshal(build.ParametersJSON(), &all); err != nil {
+		log.Errorf("serving params: decoding parameters manifest: %+v", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	dir := paramsDir()
+	files := map[string]paramFile{}
+	for name, pf := range all {
+		if pf.SectorSize != uint64(ssize) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			pf.URL = "/params/" + name
+		}
+		files[name] = pf
+	}
+
+	name := strings.TrimPrefix(strings.TrimPrefix(r.URL.Path, "/params"), "/")
+	if name == "" {
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(files); err != nil {
+			log.Errorf("writing params manifest: %+v", err)
+		}
+		return
+	}
+
+	// only files in the manifest are served, which keeps requests in the
+	// parameters directory
+	pf, ok := files[name]
+	if !ok {
+		w.WriteHeader(404)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unknown parameter file"})
+		return
+	}
+	if pf.URL == "" {
+		w.WriteHeader(404)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"parameter file wasn't fetched by the miner"})
+		return
+	}
+
+	http.ServeFile(w, r, filepath.Join(dir, name))
+}
@@ -0,0 +1,69 @@
+package impl
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/ipfs/go-datastore"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/lotus/build"
+	"github.com/filecoin-project/lotus/lib/probes"
+)
+
+// ReadyMaxHeadAge is the maximum age of the chain head for the miner to be
+// considered ready
+var ReadyMaxHeadAge = 5 * time.Duration(build.BlockDelaySecs) * time.Second
+
+// ServeHealthz is the liveness probe; it succeeds as long as the API server
+// is serving requests
+func (sm *StorageMinerAPI) ServeHealthz(w http.ResponseWriter, r *http.Request) {
+	probes.Handler(nil)(w, r)
+}
+
+// ServeReadyz is the readiness probe, checking the miner actor, chain sync,
+// the metadata datastore and that at least one worker is connected
+func (sm *StorageMinerAPI) ServeReadyz(w http.ResponseWriter, r *http.Request) {
+	probes.Handler(map[string]probes.Check{
+		"api":       sm.checkActor,
+		"chain":     sm.checkChain,
+		"datastore": sm.checkDatastore,
+		"dispatch":  sm.checkDispatch,
+	})(w, r)
+}
+
+func (sm *StorageMinerAPI) checkActor(ctx context.Context) error {
+	_, err := sm.ActorAddress(ctx)
+	return err
+}
+
+func (sm *StorageMinerAPI) checkChain(ctx context.Context) error {
+	head, err := sm.Full.ChainHead(ctx)
+	if err != nil {
+		return xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	age := time.Since(time.Unix(int64(head.MinTimestamp()), 0))
+	if age > ReadyMaxHeadAge {
+		return xerrors.Errorf("chain head at height %d is %s old, node not in sync", head.Height(), age.Truncate(time.Second))
+	}
+
+	return nil
+}
+
+func (sm *StorageMinerAPI) checkDatastore(ctx context.Context) error {
+	if _, err := sm.DS.Has(datastore.NewKey("/readyz")); err != nil {
+		return xerrors.Errorf("reading metadata datastore: %w", err)
+	}
+	return nil
+}
+
+func (sm *StorageMinerAPI) checkDispatch(ctx context.Context) error {
+	for _, st := range sm.StorageMgr.WorkerStats() {
+		if st.Enabled {
+			return nil
+		}
+	}
+	return xerrors.New("no enabled workers connected")
+}
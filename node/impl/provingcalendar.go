@@ -0,0 +1,106 @@
+package impl
+
+import (
+	"context"
+	"time"
+
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/build"
+	lminer "github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/storage"
+)
+
+// how many proving periods a calendar covers at most
+const maxCalendarPeriods = 32
+
+func (sm *StorageMinerAPI) ProvingCalendar(ctx context.Context, periods uint64) (*api.ProvingCalendar, error) {
+	if periods > maxCalendarPeriods {
+		return nil, xerrors.Errorf("at most %d following periods can be listed", maxCalendarPeriods)
+	}
+
+	maddr := sm.Miner.Address()
+
+	head, err := sm.Full.ChainHead(ctx)
+	if err != nil {
+		return nil, xerrors.Errorf("getting chain head: %w", err)
+	}
+
+	di, err := sm.Full.StateMinerProvingDeadline(ctx, maddr, head.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting proving deadline: %w", err)
+	}
+
+	dls, err := sm.Full.StateMinerDeadlines(ctx, maddr, head.Key())
+	if err != nil {
+		return nil, xerrors.Errorf("getting deadlines: %w", err)
+	}
+
+	type deadlineCounts struct {
+		partitions int
+		sectors    uint64
+		faults     uint64
+	}
+	counts := make([]deadlineCounts, len(dls))
+	for dlIdx := range dls {
+		parts, err := sm.Full.StateMinerPartitions(ctx, maddr, uint64(dlIdx), head.Key())
+		if err != nil {
+			return nil, xerrors.Errorf("getting partitions for deadline %d: %w", dlIdx, err)
+		}
+
+		counts[dlIdx].partitions = len(parts)
+		for _, part := range parts {
+			sc, err := part.LiveSectors.Count()
+			if err != nil {
+				return nil, xerrors.Errorf("counting live sectors: %w", err)
+			}
+			fc, err := part.FaultySectors.Count()
+			if err != nil {
+				return nil, xerrors.Errorf("counting faulty sectors: %w", err)
+			}
+
+			counts[dlIdx].sectors += sc
+			counts[dlIdx].faults += fc
+		}
+	}
+
+	// epochs are converted to time from the head, which is exact as long as
+	// the chain keeps up with the wall clock
+	epochTime := func(e abi.ChainEpoch) time.Time {
+		return time.Unix(int64(head.MinTimestamp())+int64(e-head.Height())*int64(build.BlockDelaySecs), 0)
+	}
+
+	out := &api.ProvingCalendar{
+		Miner:        maddr,
+		CurrentEpoch: head.Height(),
+	}
+	for p := uint64(0); p <= periods; p++ {
+		periodStart := di.PeriodStart + abi.ChainEpoch(p)*lminer.WPoStProvingPeriod
+		for dlIdx := uint64(0); dlIdx < lminer.WPoStPeriodDeadlines; dlIdx++ {
+			dl := storage.NewDeadlineInfo(periodStart, dlIdx, head.Height())
+
+			cd := api.CalendarDeadline{
+				PeriodStart: periodStart,
+				Index:       dlIdx,
+				Open:        dl.Open,
+				Close:       dl.Close,
+				Challenge:   dl.Challenge,
+				FaultCutoff: dl.FaultCutoff,
+				OpenTime:    epochTime(dl.Open),
+				CloseTime:   epochTime(dl.Close),
+			}
+			if dlIdx < uint64(len(counts)) {
+				cd.Partitions = counts[dlIdx].partitions
+				cd.Sectors = counts[dlIdx].sectors
+				cd.Faults = counts[dlIdx].faults
+			}
+
+			out.Deadlines = append(out.Deadlines, cd)
+		}
+	}
+
+	return out, nil
+}
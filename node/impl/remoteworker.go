@@ -12,6 +12,7 @@ import (
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/client"
+	"github.com/filecoin-project/lotus/build"
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 )
 
@@ -38,6 +39,16 @@ func connectRemoteWorker(ctx context.Context, fa api.Common, url string) (*remot
 		return nil, xerrors.Errorf("creating jsonrpc client: %w", err)
 	}
 
+	wver, err := wapi.Version(ctx)
+	if err != nil {
+		closer()
+		return nil, xerrors.Errorf("getting worker api version: %w", err)
+	}
+	if !wver.EqMajorMinor(build.WorkerAPIVersion) {
+		closer()
+		return nil, xerrors.Errorf("unsupported worker api version: %s (expected %s)", wver, build.WorkerAPIVersion)
+	}
+
 	return &remoteWorker{wapi, closer}, nil
 }
 
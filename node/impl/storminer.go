@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"net/url"
 	"os"
 	"strconv"
 	"time"
@@ -28,10 +29,13 @@ import (
 	"github.com/filecoin-project/lotus/extern/sector-storage/stores"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 
 	"github.com/filecoin-project/lotus/api"
 	"github.com/filecoin-project/lotus/api/apistruct"
+	lminer "github.com/filecoin-project/lotus/chain/actors/builtin/miner"
 	"github.com/filecoin-project/lotus/chain/types"
+	"github.com/filecoin-project/lotus/lib/rpcenc"
 	"github.com/filecoin-project/lotus/miner"
 	"github.com/filecoin-project/lotus/node/impl/common"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
@@ -77,12 +81,16 @@ type StorageMinerAPI struct {
 	SetConsiderUnverifiedStorageDealsConfigFunc dtypes.SetConsiderUnverifiedStorageDealsConfigFunc
 	SetSealingConfigFunc                        dtypes.SetSealingConfigFunc
 	GetSealingConfigFunc                        dtypes.GetSealingConfigFunc
+	SetProvingConfigFunc                        dtypes.SetProvingConfigFunc
+	GetProvingConfigFunc                        dtypes.GetProvingConfigFunc
 	GetExpectedSealDurationFunc                 dtypes.GetExpectedSealDurationFunc
 	SetExpectedSealDurationFunc                 dtypes.SetExpectedSealDurationFunc
 }
 
 func (sm *StorageMinerAPI) ServeRemote(w http.ResponseWriter, r *http.Request) {
-	if !auth.HasPerm(r.Context(), nil, apistruct.PermAdmin) {
+	// workers may also read the files of a Fetch call with the token issued
+	// for it, without having an admin token
+	if !auth.HasPerm(r.Context(), nil, apistruct.PermAdmin) && !sm.StorageMgr.FetchTokenAllows(r) {
 		w.WriteHeader(401)
 		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing write permission"})
 		return
@@ -99,6 +107,11 @@ func (sm *StorageMinerAPI) WorkerJobs(ctx context.Context) (map[uuid.UUID][]stor
 	return sm.StorageMgr.WorkerJobs(), nil
 }
 
+func (sm *StorageMinerAPI) WorkerTasksChanged(ctx context.Context) error {
+	sm.StorageMgr.WorkerTasksChanged()
+	return nil
+}
+
 func (sm *StorageMinerAPI) ActorAddress(context.Context) (address.Address, error) {
 	return sm.Miner.Address(), nil
 }
@@ -130,7 +143,9 @@ func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid abi.SectorNumb
 	}
 
 	deals := make([]abi.DealID, len(info.Pieces))
+	pieces := make([]abi.PieceInfo, len(info.Pieces))
 	for i, piece := range info.Pieces {
+		pieces[i] = piece.Piece
 		if piece.DealInfo == nil {
 			continue
 		}
@@ -154,6 +169,7 @@ func (sm *StorageMinerAPI) SectorsStatus(ctx context.Context, sid abi.SectorNumb
 		CommR:    info.CommR,
 		Proof:    info.Proof,
 		Deals:    deals,
+		Pieces:   pieces,
 		Ticket: api.SealTicket{
 			Value: info.TicketValue,
 			Epoch: info.TicketEpoch,
@@ -269,6 +285,10 @@ func (sm *StorageMinerAPI) StorageLocal(ctx context.Context) (map[stores.ID]stri
 	return sm.StorageMgr.StorageLocal(ctx)
 }
 
+func (sm *StorageMinerAPI) SectorsPackingReport(ctx context.Context) (sealiface.PackingReport, error) {
+	return sm.Miner.PackingReport(), nil
+}
+
 func (sm *StorageMinerAPI) SectorsRefs(context.Context) (map[string][]api.SealedRef, error) {
 	// json can't handle cids as map keys
 	out := map[string][]api.SealedRef{}
@@ -312,6 +332,26 @@ func (sm *StorageMinerAPI) SectorGetSealDelay(ctx context.Context) (time.Duratio
 	return cfg.WaitDealsDelay, nil
 }
 
+func (sm *StorageMinerAPI) ProvingGetConfig(ctx context.Context) (dtypes.ProvingConfig, error) {
+	return sm.GetProvingConfigFunc()
+}
+
+func (sm *StorageMinerAPI) ProvingSetConfig(ctx context.Context, cfg dtypes.ProvingConfig) error {
+	for dlIdx, dc := range cfg.Deadlines {
+		if dlIdx >= lminer.WPoStPeriodDeadlines {
+			return xerrors.Errorf("deadline index %d out of range", dlIdx)
+		}
+		if dc.GenerateLeadEpochs < 0 || dc.MaxPartitionsPerMessage < 0 {
+			return xerrors.Errorf("deadline %d: negative values aren't allowed", dlIdx)
+		}
+	}
+	if cfg.Default.GenerateLeadEpochs < 0 || cfg.Default.MaxPartitionsPerMessage < 0 {
+		return xerrors.Errorf("negative values aren't allowed")
+	}
+
+	return sm.SetProvingConfigFunc(cfg)
+}
+
 func (sm *StorageMinerAPI) SectorSetExpectedSealDuration(ctx context.Context, delay time.Duration) error {
 	return sm.SetExpectedSealDurationFunc(delay)
 }
@@ -332,6 +372,46 @@ func (sm *StorageMinerAPI) SectorMarkForUpgrade(ctx context.Context, id abi.Sect
 	return sm.Miner.MarkForUpgrade(id)
 }
 
+func (sm *StorageMinerAPI) SectorCommitPending(ctx context.Context) ([]sealiface.PendingCommit, error) {
+	return sm.Miner.PendingCommits(), nil
+}
+
+func (sm *StorageMinerAPI) SectorCommitApprove(ctx context.Context, id abi.SectorNumber) error {
+	return sm.Miner.ApproveCommit(ctx, id)
+}
+
+func (sm *StorageMinerAPI) SectorCommitReject(ctx context.Context, id abi.SectorNumber) error {
+	return sm.Miner.RejectCommit(ctx, id)
+}
+
+func (sm *StorageMinerAPI) SectorAddPieceToAny(ctx context.Context, size abi.UnpaddedPieceSize, pieceURL string, deal api.PieceDealInfo) (api.SectorOffset, error) {
+	u, err := url.Parse(pieceURL)
+	if err != nil {
+		return api.SectorOffset{}, xerrors.Errorf("parsing piece URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return api.SectorOffset{}, xerrors.Errorf("piece URL must be http(s), got %q", u.Scheme)
+	}
+
+	// The reader is only sent to workers as its URL, so they fetch the piece
+	// directly; only a piece assigned to the miner's local worker is
+	// downloaded by the miner
+	sn, offset, err := sm.SectorBlocks.AddPiece(ctx, size, rpcenc.NewURLReader(ctx, pieceURL), sealing.DealInfo{
+		PublishCid: deal.PublishCid,
+		DealID:     deal.DealID,
+		DealSchedule: sealing.DealSchedule{
+			StartEpoch: deal.StartEpoch,
+			EndEpoch:   deal.EndEpoch,
+		},
+		KeepUnsealed: deal.KeepUnsealed,
+	})
+	if err != nil {
+		return api.SectorOffset{}, err
+	}
+
+	return api.SectorOffset{Sector: sn, Offset: offset}, nil
+}
+
 func (sm *StorageMinerAPI) WorkerConnect(ctx context.Context, url string) error {
 	w, err := connectRemoteWorker(ctx, sm, url)
 	if err != nil {
@@ -351,6 +431,27 @@ func (sm *StorageMinerAPI) SealingAbort(ctx context.Context, call storiface.Call
 	return sm.StorageMgr.Abort(ctx, call)
 }
 
+func (sm *StorageMinerAPI) SealingAbortRemote(ctx context.Context, call storiface.CallID) error {
+	return sm.StorageMgr.SealingAbortRemote(ctx, call)
+}
+
+func (sm *StorageMinerAPI) SealingCallLogs(ctx context.Context, call storiface.CallID) (<-chan storiface.CallLogLine, error) {
+	return sm.StorageMgr.CallLogs(ctx, call)
+}
+
+func (sm *StorageMinerAPI) SealingSetThrottle(ctx context.Context, limits storiface.SchedThrottle) error {
+	sm.StorageMgr.SetSchedThrottle(limits)
+	return nil
+}
+
+func (sm *StorageMinerAPI) SealingGetThrottle(ctx context.Context) (storiface.SchedThrottle, error) {
+	return sm.StorageMgr.SchedThrottle(), nil
+}
+
+func (sm *StorageMinerAPI) SealingStats(ctx context.Context) (storiface.SealingStats, error) {
+	return sm.StorageMgr.SealingStats(ctx)
+}
+
 func (sm *StorageMinerAPI) MarketImportDealData(ctx context.Context, propCid cid.Cid, path string) error {
 	fi, err := os.Open(path)
 	if err != nil {
@@ -0,0 +1,118 @@
+package impl
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-jsonrpc/auth"
+	"github.com/filecoin-project/go-state-types/abi"
+
+	"github.com/filecoin-project/lotus/api/apistruct"
+	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
+	sto "github.com/filecoin-project/specs-storage/storage"
+)
+
+type unsealRequest struct {
+	ref      sto.SectorRef
+	ticket   abi.SealRandomness
+	unsealed cid.Cid
+}
+
+func (sm *StorageMinerAPI) unsealRequest(sid abi.SectorNumber) (unsealRequest, error) {
+	si, err := sm.Miner.GetSectorInfo(sid)
+	if err != nil {
+		return unsealRequest{}, xerrors.Errorf("getting sector info: %w", err)
+	}
+
+	mid, err := address.IDFromAddress(sm.Miner.Address())
+	if err != nil {
+		return unsealRequest{}, err
+	}
+
+	req := unsealRequest{
+		ref: sto.SectorRef{
+			ID: abi.SectorID{
+				Miner:  abi.ActorID(mid),
+				Number: sid,
+			},
+			ProofType: si.SectorType,
+		},
+		ticket: si.TicketValue,
+	}
+	if si.CommD != nil {
+		req.unsealed = *si.CommD
+	}
+
+	return req, nil
+}
+
+func (sm *StorageMinerAPI) SectorUnsealRange(ctx context.Context, sid abi.SectorNumber, offset storiface.UnpaddedByteIndex, size abi.UnpaddedPieceSize) (uuid.UUID, error) {
+	req, err := sm.unsealRequest(sid)
+	if err != nil {
+		return uuid.UUID{}, err
+	}
+
+	return sm.StorageMgr.StartUnseal(req.ref, offset, size, req.ticket, req.unsealed), nil
+}
+
+func (sm *StorageMinerAPI) SectorUnsealStatus(ctx context.Context, id uuid.UUID) (storiface.UnsealJob, error) {
+	return sm.StorageMgr.UnsealStatus(ctx, id)
+}
+
+func (sm *StorageMinerAPI) SectorSealRecord(ctx context.Context, sid abi.SectorNumber) (storiface.SealRecord, error) {
+	mid, err := address.IDFromAddress(sm.Miner.Address())
+	if err != nil {
+		return storiface.SealRecord{}, err
+	}
+
+	return sm.StorageMgr.SealRecord(ctx, abi.SectorID{Miner: abi.ActorID(mid), Number: sid})
+}
+
+// ServeUnsealed streams the range unsealed by a finished SectorUnsealRange
+// job, requested as /unsealed/{job-id}
+func (sm *StorageMinerAPI) ServeUnsealed(w http.ResponseWriter, r *http.Request) {
+	if !auth.HasPerm(r.Context(), nil, apistruct.PermAdmin) {
+		w.WriteHeader(401)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unauthorized: missing admin permission"})
+		return
+	}
+
+	id, err := uuid.Parse(strings.TrimPrefix(r.URL.Path, "/unsealed/"))
+	if err != nil {
+		w.WriteHeader(400)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"invalid unseal job id"})
+		return
+	}
+
+	job, err := sm.StorageMgr.UnsealStatus(r.Context(), id)
+	if err != nil {
+		w.WriteHeader(404)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{err.Error()})
+		return
+	}
+
+	if job.State != storiface.UnsealDone {
+		w.WriteHeader(409)
+		_ = json.NewEncoder(w).Encode(struct{ Error string }{"unseal job is " + string(job.State)})
+		return
+	}
+
+	req, err := sm.unsealRequest(job.Sector.Number)
+	if err != nil {
+		log.Errorf("serving unsealed range: %+v", err)
+		w.WriteHeader(500)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if err := sm.StorageMgr.ReadPiece(r.Context(), w, req.ref, job.Offset, job.Size, req.ticket, req.unsealed); err != nil {
+		log.Errorf("serving unsealed range: %+v", err)
+	}
+}
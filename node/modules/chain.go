@@ -12,6 +12,7 @@ import (
 	"github.com/ipfs/go-datastore"
 	"github.com/ipld/go-car"
 	"github.com/libp2p/go-libp2p-core/host"
+	"github.com/libp2p/go-libp2p-core/peer"
 	"github.com/libp2p/go-libp2p-core/routing"
 	pubsub "github.com/libp2p/go-libp2p-pubsub"
 	"go.uber.org/fx"
@@ -33,6 +34,7 @@ import (
 	"github.com/filecoin-project/lotus/lib/blockstore"
 	"github.com/filecoin-project/lotus/lib/bufbstore"
 	"github.com/filecoin-project/lotus/lib/timedbs"
+	"github.com/filecoin-project/lotus/node/config"
 	"github.com/filecoin-project/lotus/node/modules/dtypes"
 	"github.com/filecoin-project/lotus/node/modules/helpers"
 	"github.com/filecoin-project/lotus/node/repo"
@@ -91,6 +93,44 @@ func ChainRawBlockstore(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedR
 	return cbs, nil
 }
 
+// ChainReadCache puts an in-memory read cache in front of the chain blockstore
+func TrustedSyncPeers(cfg config.Sync) (dtypes.TrustedSyncPeers, error) {
+	if len(cfg.TrustedPeers) == 0 {
+		return nil, xerrors.Errorf("headers-only sync requires Sync.TrustedPeers to be set")
+	}
+
+	var out dtypes.TrustedSyncPeers
+	for _, s := range cfg.TrustedPeers {
+		p, err := peer.Decode(s)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing trusted sync peer %q: %w", s, err)
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+func ChainReadCache(cfg config.Chainstore) func(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo) (*blockstore.ReadCache, error) {
+	return func(lc fx.Lifecycle, mctx helpers.MetricsCtx, r repo.LockedRepo) (*blockstore.ReadCache, error) {
+		bs, err := ChainRawBlockstore(lc, mctx, r)
+		if err != nil {
+			return nil, err
+		}
+
+		rc, err := blockstore.NewReadCache(bs, cfg.ReadCacheType, cfg.ReadCacheSize)
+		if err != nil {
+			return nil, xerrors.Errorf("setting up chain read cache: %w", err)
+		}
+		return rc, nil
+	}
+}
+
+func StateManager(cfg config.Chainstore) func(cs *store.ChainStore, us stmgr.UpgradeSchedule) (*stmgr.StateManager, error) {
+	return func(cs *store.ChainStore, us stmgr.UpgradeSchedule) (*stmgr.StateManager, error) {
+		return stmgr.NewStateManagerWithComputeCache(cs, us, cfg.ComputeStateCacheSize)
+	}
+}
+
 func ChainBlockService(bs dtypes.ChainRawBlockstore, rem dtypes.ChainBitswap) dtypes.ChainBlockService {
 	return blockservice.New(bs, rem)
 }
@@ -213,6 +253,8 @@ type SyncerParams struct {
 	Host         host.Host
 	Beacon       beacon.Schedule
 	Verifier     ffiwrapper.Verifier
+	HeadersOnly  dtypes.HeadersOnlySync
+	TrustedPeers dtypes.TrustedSyncPeers
 }
 
 func NewSyncer(params SyncerParams) (*chain.Syncer, error) {
@@ -230,6 +272,11 @@ func NewSyncer(params SyncerParams) (*chain.Syncer, error) {
 	if err != nil {
 		return nil, err
 	}
+	if params.HeadersOnly {
+		if err := syncer.SetHeadersOnly(params.TrustedPeers); err != nil {
+			return nil, err
+		}
+	}
 
 	lc.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
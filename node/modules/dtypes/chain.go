@@ -1,4 +1,14 @@
 package dtypes
 
+import "github.com/libp2p/go-libp2p-core/peer"
+
 type NetworkName string
 type AfterGenesisSet struct{}
+
+// HeadersOnlySync makes the syncer follow the heaviest chain announced by
+// TrustedSyncPeers, by header weight alone, without executing messages or
+// validating state.
+type HeadersOnlySync bool
+
+// TrustedSyncPeers are the peers whose heads a headers-only node syncs
+type TrustedSyncPeers []peer.ID
@@ -80,6 +80,13 @@ type SetSealingConfigFunc func(sealiface.Config) error
 // GetSealingDelay returns how long a sector waits for more deals before sealing begins.
 type GetSealingConfigFunc func() (sealiface.Config, error)
 
+// SetProvingConfigFunc replaces the window PoSt configuration, including
+// per-deadline overrides.
+type SetProvingConfigFunc func(ProvingConfig) error
+
+// GetProvingConfigFunc returns the window PoSt configuration.
+type GetProvingConfigFunc func() (ProvingConfig, error)
+
 // SetExpectedSealDurationFunc is a function which is used to set how long sealing is expected to take.
 // Deals that would need to start earlier than this duration will be rejected.
 type SetExpectedSealDurationFunc func(time.Duration) error
@@ -90,3 +97,39 @@ type GetExpectedSealDurationFunc func() (time.Duration, error)
 
 type StorageDealFilter func(ctx context.Context, deal storagemarket.MinerDeal) (bool, string, error)
 type RetrievalDealFilter func(ctx context.Context, deal retrievalmarket.ProviderDealState) (bool, string, error)
+
+// DeadlineProvingConfig tunes window PoSt for a deadline. Zero values mean
+// the built-in behaviour.
+type DeadlineProvingConfig struct {
+	// GenerateLeadEpochs is how many epochs before the deadline opens proof
+	// generation starts. Proofs can't be generated before the challenge epoch,
+	// so values larger than the challenge lookback have no effect.
+	GenerateLeadEpochs abi.ChainEpoch
+
+	// MaxPartitionsPerMessage caps the number of partitions proven in a
+	// single SubmitWindowedPoSt message. It can't exceed the network limit.
+	MaxPartitionsPerMessage int
+}
+
+type ProvingConfig struct {
+	// Default applies to deadlines without an override.
+	Default DeadlineProvingConfig
+
+	// Deadlines holds per-deadline overrides, keyed by deadline index. Zero
+	// fields in an override fall back to Default.
+	Deadlines map[uint64]DeadlineProvingConfig
+}
+
+// ForDeadline returns the effective configuration for the given deadline.
+func (c ProvingConfig) ForDeadline(dlIdx uint64) DeadlineProvingConfig {
+	out := c.Default
+	if o, ok := c.Deadlines[dlIdx]; ok {
+		if o.GenerateLeadEpochs != 0 {
+			out.GenerateLeadEpochs = o.GenerateLeadEpochs
+		}
+		if o.MaxPartitionsPerMessage != 0 {
+			out.MaxPartitionsPerMessage = o.MaxPartitionsPerMessage
+		}
+	}
+	return out
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"go.uber.org/fx"
@@ -170,10 +171,44 @@ func AddressSelector(addrConf *config.MinerAddressConfig) func() (*storage.Addre
 			as.CommitControl = append(as.CommitControl, addr)
 		}
 
+		rot, err := storage.ParseAddressRotation(addrConf.Rotation)
+		if err != nil {
+			return nil, xerrors.Errorf("parsing address rotation: %w", err)
+		}
+		as.Rotation = rot
+
 		return as, nil
 	}
 }
 
+func MessageSigners(addrConf *config.MinerAddressConfig) func() (*storage.MessageSigners, error) {
+	return func() (*storage.MessageSigners, error) {
+		signers := map[address.Address]storage.MessageSigner{}
+		if addrConf == nil {
+			return storage.NewMessageSigners(signers), nil
+		}
+
+		for s, sc := range addrConf.RemoteSigners {
+			addr, err := address.NewFromString(s)
+			if err != nil {
+				return nil, xerrors.Errorf("parsing remote signer address: %w", err)
+			}
+			switch addr.Protocol() {
+			case address.SECP256K1, address.BLS:
+			default:
+				return nil, xerrors.Errorf("remote signer address %s must be a public key address", addr)
+			}
+			if sc.URL == "" {
+				return nil, xerrors.Errorf("remote signer for %s has no URL", addr)
+			}
+
+			signers[addr] = storage.NewRemoteSigner(sc.URL, sc.Token)
+		}
+
+		return storage.NewMessageSigners(signers), nil
+	}
+}
+
 type StorageMinerParams struct {
 	fx.In
 
@@ -186,8 +221,10 @@ type StorageMinerParams struct {
 	SectorIDCounter    sealing.SectorIDCounter
 	Verifier           ffiwrapper.Verifier
 	GetSealingConfigFn dtypes.GetSealingConfigFunc
+	GetProvingConfigFn dtypes.GetProvingConfigFunc
 	Journal            journal.Journal
 	AddrSel            *storage.AddressSelector
+	Signers            *storage.MessageSigners
 }
 
 func StorageMiner(fc config.MinerFeeConfig) func(params StorageMinerParams) (*storage.Miner, error) {
@@ -202,8 +239,10 @@ func StorageMiner(fc config.MinerFeeConfig) func(params StorageMinerParams) (*st
 			sc     = params.SectorIDCounter
 			verif  = params.Verifier
 			gsd    = params.GetSealingConfigFn
+			gpc    = params.GetProvingConfigFn
 			j      = params.Journal
 			as     = params.AddrSel
+			ms     = params.Signers
 		)
 
 		maddr, err := minerAddrFromDS(ds)
@@ -213,12 +252,12 @@ func StorageMiner(fc config.MinerFeeConfig) func(params StorageMinerParams) (*st
 
 		ctx := helpers.LifecycleCtx(mctx, lc)
 
-		fps, err := storage.NewWindowedPoStScheduler(api, fc, as, sealer, sealer, j, maddr)
+		fps, err := storage.NewWindowedPoStScheduler(api, fc, gpc, as, sealer, sealer, j, maddr)
 		if err != nil {
 			return nil, err
 		}
 
-		sm, err := storage.NewMiner(api, maddr, h, ds, sealer, sc, verif, gsd, fc, j, as)
+		sm, err := storage.NewMiner(api, maddr, h, ds, sealer, sc, verif, gsd, fc, j, as, ms)
 		if err != nil {
 			return nil, err
 		}
@@ -641,6 +680,10 @@ func RetrievalProvider(h host.Host,
 
 var WorkerCallsPrefix = datastore.NewKey("/worker/calls")
 var ManagerWorkPrefix = datastore.NewKey("/stmgr/calls")
+var SealingStatsPrefix = datastore.NewKey("/stmgr/stats")
+var SealRecordsPrefix = datastore.NewKey("/stmgr/sealrecords")
+var DispatchCallsPrefix = datastore.NewKey("/stmgr/dispatch")
+var SectorTransfersPrefix = datastore.NewKey("/storage/transfers")
 
 func SectorStorage(mctx helpers.MetricsCtx, lc fx.Lifecycle, ls stores.LocalStorage, si stores.SectorIndex, sc sectorstorage.SealerConfig, urls sectorstorage.URLs, sa sectorstorage.StorageAuth, ds dtypes.MetadataDS) (*sectorstorage.Manager, error) {
 	ctx := helpers.LifecycleCtx(mctx, lc)
@@ -648,11 +691,29 @@ func SectorStorage(mctx helpers.MetricsCtx, lc fx.Lifecycle, ls stores.LocalStor
 	wsts := statestore.New(namespace.Wrap(ds, WorkerCallsPrefix))
 	smsts := statestore.New(namespace.Wrap(ds, ManagerWorkPrefix))
 
+	sectorstorage.WorkerVersion = build.UserVersion()
+
 	sst, err := sectorstorage.New(ctx, ls, si, sc, urls, sa, wsts, smsts)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := sst.PersistStats(namespace.Wrap(ds, SealingStatsPrefix)); err != nil {
+		return nil, xerrors.Errorf("loading sealing stats: %w", err)
+	}
+
+	if err := sst.PersistSealRecords(namespace.Wrap(ds, SealRecordsPrefix)); err != nil {
+		return nil, xerrors.Errorf("persisting seal records: %w", err)
+	}
+
+	if err := sst.PersistDispatchCalls(namespace.Wrap(ds, DispatchCallsPrefix)); err != nil {
+		return nil, xerrors.Errorf("restoring pending dispatch calls: %w", err)
+	}
+
+	if err := sst.PersistTransfers(ctx, namespace.Wrap(ds, SectorTransfersPrefix)); err != nil {
+		return nil, xerrors.Errorf("recovering sector transfers: %w", err)
+	}
+
 	lc.Append(fx.Hook{
 		OnStop: sst.Close,
 	})
@@ -805,6 +866,17 @@ func NewSetSealConfigFunc(r repo.LockedRepo) (dtypes.SetSealingConfigFunc, error
 				MaxSealingSectors:         cfg.MaxSealingSectors,
 				MaxSealingSectorsForDeals: cfg.MaxSealingSectorsForDeals,
 				WaitDealsDelay:            config.Duration(cfg.WaitDealsDelay),
+				ApproveCommits:            cfg.ApproveCommits,
+				ExpectedPreCommitDuration: config.Duration(cfg.ExpectedPreCommitDuration),
+				ExpectedCommitDuration:    config.Duration(cfg.ExpectedCommitDuration),
+				DeadlineAlertWebhook:      cfg.DeadlineAlertWebhook,
+				RetryStuckSectors:         cfg.RetryStuckSectors,
+			}
+			if len(cfg.MaxStateDuration) > 0 {
+				c.Sealing.MaxStateDuration = map[string]config.Duration{}
+				for st, d := range cfg.MaxStateDuration {
+					c.Sealing.MaxStateDuration[st] = config.Duration(d)
+				}
 			}
 		})
 		return
@@ -819,6 +891,60 @@ func NewGetSealConfigFunc(r repo.LockedRepo) (dtypes.GetSealingConfigFunc, error
 				MaxSealingSectors:         cfg.Sealing.MaxSealingSectors,
 				MaxSealingSectorsForDeals: cfg.Sealing.MaxSealingSectorsForDeals,
 				WaitDealsDelay:            time.Duration(cfg.Sealing.WaitDealsDelay),
+				ApproveCommits:            cfg.Sealing.ApproveCommits,
+				ExpectedPreCommitDuration: time.Duration(cfg.Sealing.ExpectedPreCommitDuration),
+				ExpectedCommitDuration:    time.Duration(cfg.Sealing.ExpectedCommitDuration),
+				DeadlineAlertWebhook:      cfg.Sealing.DeadlineAlertWebhook,
+				RetryStuckSectors:         cfg.Sealing.RetryStuckSectors,
+			}
+			if len(cfg.Sealing.MaxStateDuration) > 0 {
+				out.MaxStateDuration = map[string]time.Duration{}
+				for st, d := range cfg.Sealing.MaxStateDuration {
+					out.MaxStateDuration[st] = time.Duration(d)
+				}
+			}
+		})
+		return
+	}, nil
+}
+
+func NewSetProvingConfigFunc(r repo.LockedRepo) (dtypes.SetProvingConfigFunc, error) {
+	return func(cfg dtypes.ProvingConfig) (err error) {
+		err = mutateCfg(r, func(c *config.StorageMiner) {
+			c.Proving = config.ProvingConfig{
+				GenerateLeadEpochs:      int64(cfg.Default.GenerateLeadEpochs),
+				MaxPartitionsPerMessage: cfg.Default.MaxPartitionsPerMessage,
+			}
+			for dlIdx, dc := range cfg.Deadlines {
+				c.Proving.Deadlines = append(c.Proving.Deadlines, config.DeadlineProvingConfig{
+					Deadline:                dlIdx,
+					GenerateLeadEpochs:      int64(dc.GenerateLeadEpochs),
+					MaxPartitionsPerMessage: dc.MaxPartitionsPerMessage,
+				})
+			}
+			sort.Slice(c.Proving.Deadlines, func(i, j int) bool {
+				return c.Proving.Deadlines[i].Deadline < c.Proving.Deadlines[j].Deadline
+			})
+		})
+		return
+	}, nil
+}
+
+func NewGetProvingConfigFunc(r repo.LockedRepo) (dtypes.GetProvingConfigFunc, error) {
+	return func() (out dtypes.ProvingConfig, err error) {
+		err = readCfg(r, func(cfg *config.StorageMiner) {
+			out = dtypes.ProvingConfig{
+				Default: dtypes.DeadlineProvingConfig{
+					GenerateLeadEpochs:      abi.ChainEpoch(cfg.Proving.GenerateLeadEpochs),
+					MaxPartitionsPerMessage: cfg.Proving.MaxPartitionsPerMessage,
+				},
+				Deadlines: map[uint64]dtypes.DeadlineProvingConfig{},
+			}
+			for _, dc := range cfg.Proving.Deadlines {
+				out.Deadlines[dc.Deadline] = dtypes.DeadlineProvingConfig{
+					GenerateLeadEpochs:      abi.ChainEpoch(dc.GenerateLeadEpochs),
+					MaxPartitionsPerMessage: dc.MaxPartitionsPerMessage,
+				}
 			}
 		})
 		return
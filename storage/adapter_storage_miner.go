@@ -32,10 +32,11 @@ var _ sealing.SealingAPI = new(SealingAPIAdapter)
 
 type SealingAPIAdapter struct {
 	delegate storageMinerApi
+	signers  *MessageSigners
 }
 
-func NewSealingAPIAdapter(api storageMinerApi) SealingAPIAdapter {
-	return SealingAPIAdapter{delegate: api}
+func NewSealingAPIAdapter(api storageMinerApi, signers *MessageSigners) SealingAPIAdapter {
+	return SealingAPIAdapter{delegate: api, signers: signers}
 }
 
 func (s SealingAPIAdapter) StateMinerSectorSize(ctx context.Context, maddr address.Address, tok sealing.TipSetToken) (abi.SectorSize, error) {
@@ -275,7 +276,17 @@ func (s SealingAPIAdapter) SendMsg(ctx context.Context, from, to address.Address
 		Params: params,
 	}
 
-	smsg, err := s.delegate.MpoolPushMessage(ctx, &msg, &api.MessageSendSpec{MaxFee: maxFee})
+	signer, err := s.signers.signerFor(ctx, s.delegate, from)
+	if err != nil {
+		return cid.Undef, xerrors.Errorf("getting signer: %w", err)
+	}
+
+	var smsg *types.SignedMessage
+	if signer != nil {
+		smsg, err = s.signers.push(ctx, s.delegate, signer, &msg, &api.MessageSendSpec{MaxFee: maxFee})
+	} else {
+		smsg, err = s.delegate.MpoolPushMessage(ctx, &msg, &api.MessageSendSpec{MaxFee: maxFee})
+	}
 	if err != nil {
 		return cid.Undef, err
 	}
@@ -283,6 +294,27 @@ func (s SealingAPIAdapter) SendMsg(ctx context.Context, from, to address.Address
 	return smsg.Cid(), nil
 }
 
+func (s SealingAPIAdapter) EstimateMsgGas(ctx context.Context, from, to address.Address, method abi.MethodNum, value, maxFee abi.TokenAmount, params []byte) (sealing.MsgGasEstimate, error) {
+	msg := types.Message{
+		To:     to,
+		From:   from,
+		Value:  value,
+		Method: method,
+		Params: params,
+	}
+
+	est, err := s.delegate.GasEstimateMessageGas(ctx, &msg, &api.MessageSendSpec{MaxFee: maxFee}, types.EmptyTSK)
+	if err != nil {
+		return sealing.MsgGasEstimate{}, err
+	}
+
+	return sealing.MsgGasEstimate{
+		GasLimit:   est.GasLimit,
+		GasFeeCap:  est.GasFeeCap,
+		GasPremium: est.GasPremium,
+	}, nil
+}
+
 func (s SealingAPIAdapter) ChainHead(ctx context.Context) (sealing.TipSetToken, abi.ChainEpoch, error) {
 	head, err := s.delegate.ChainHead(ctx)
 	if err != nil {
@@ -2,6 +2,11 @@ package storage
 
 import (
 	"context"
+	"math"
+	"sort"
+	"sync"
+
+	"golang.org/x/xerrors"
 
 	"github.com/filecoin-project/go-address"
 	"github.com/filecoin-project/go-state-types/abi"
@@ -17,10 +22,43 @@ type addrSelectApi interface {
 
 	StateAccountKey(context.Context, address.Address, types.TipSetKey) (address.Address, error)
 	StateLookupID(context.Context, address.Address, types.TipSetKey) (address.Address, error)
+	StateGetActor(context.Context, address.Address, types.TipSetKey) (*types.Actor, error)
+
+	MpoolGetNonce(context.Context, address.Address) (uint64, error)
+}
+
+// AddressRotation controls how messages are spread across the control
+// addresses available for a given use.
+type AddressRotation string
+
+const (
+	// RotateNone always tries addresses in order, so the first address with
+	// enough funds sends every message.
+	RotateNone AddressRotation = ""
+	// RotateRoundRobin starts at the next address on every selection.
+	RotateRoundRobin AddressRotation = "round-robin"
+	// RotateLowestBacklog prefers the address with the fewest messages
+	// waiting in the mpool.
+	RotateLowestBacklog AddressRotation = "lowest-backlog"
+)
+
+// ParseAddressRotation validates a rotation policy from the miner config.
+func ParseAddressRotation(s string) (AddressRotation, error) {
+	switch r := AddressRotation(s); r {
+	case RotateNone, RotateRoundRobin, RotateLowestBacklog:
+		return r, nil
+	default:
+		return "", xerrors.Errorf("unknown address rotation %q", s)
+	}
 }
 
 type AddressSelector struct {
 	api.AddressConfig
+
+	Rotation AddressRotation
+
+	lk   sync.Mutex
+	next map[api.AddrUse]int
 }
 
 func (as *AddressSelector) AddressFor(ctx context.Context, a addrSelectApi, mi miner.MinerInfo, use api.AddrUse, goodFunds, minFunds abi.TokenAmount) (address.Address, abi.TokenAmount, error) {
@@ -54,12 +92,75 @@ func (as *AddressSelector) AddressFor(ctx context.Context, a addrSelectApi, mi m
 		for a := range defaultCtl {
 			addrs = append(addrs, a)
 		}
+
+		// map iteration order is random, keep rotation stable
+		sort.Slice(addrs, func(i, j int) bool {
+			return addrs[i].String() < addrs[j].String()
+		})
 	}
+	addrs = as.rotate(ctx, a, use, addrs)
 	addrs = append(addrs, mi.Owner, mi.Worker)
 
 	return pickAddress(ctx, a, mi, goodFunds, minFunds, addrs)
 }
 
+// rotate reorders control addresses according to the configured rotation
+// policy. Owner and worker addresses are always tried last, so they aren't
+// part of the rotation.
+func (as *AddressSelector) rotate(ctx context.Context, a addrSelectApi, use api.AddrUse, addrs []address.Address) []address.Address {
+	if len(addrs) < 2 {
+		return addrs
+	}
+
+	switch as.Rotation {
+	case RotateRoundRobin:
+		as.lk.Lock()
+		if as.next == nil {
+			as.next = map[api.AddrUse]int{}
+		}
+		start := as.next[use] % len(addrs)
+		as.next[use] = start + 1
+		as.lk.Unlock()
+
+		out := make([]address.Address, 0, len(addrs))
+		out = append(out, addrs[start:]...)
+		return append(out, addrs[:start]...)
+	case RotateLowestBacklog:
+		backlog := make(map[address.Address]uint64, len(addrs))
+		for _, addr := range addrs {
+			backlog[addr] = pendingCount(ctx, a, addr)
+		}
+
+		sort.SliceStable(addrs, func(i, j int) bool {
+			return backlog[addrs[i]] < backlog[addrs[j]]
+		})
+		return addrs
+	default:
+		return addrs
+	}
+}
+
+// pendingCount returns the number of messages from addr waiting in the mpool.
+// Addresses which can't be checked sort last.
+func pendingCount(ctx context.Context, a addrSelectApi, addr address.Address) uint64 {
+	next, err := a.MpoolGetNonce(ctx, addr)
+	if err != nil {
+		log.Warnw("getting mpool nonce for control address", "address", addr, "error", err)
+		return math.MaxUint64
+	}
+
+	act, err := a.StateGetActor(ctx, addr, types.EmptyTSK)
+	if err != nil {
+		log.Warnw("getting control address actor", "address", addr, "error", err)
+		return math.MaxUint64
+	}
+
+	if next < act.Nonce {
+		return 0
+	}
+	return next - act.Nonce
+}
+
 func pickAddress(ctx context.Context, a addrSelectApi, mi miner.MinerInfo, goodFunds, minFunds abi.TokenAmount, addrs []address.Address) (address.Address, abi.TokenAmount, error) {
 	leastBad := mi.Worker
 	bestAvail := minFunds
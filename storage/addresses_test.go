@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/abi"
+	"github.com/filecoin-project/go-state-types/big"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/actors/builtin/miner"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type mockAddrSelectApi struct {
+	balance map[address.Address]abi.TokenAmount
+	nonce   map[address.Address]uint64
+	pending map[address.Address]uint64
+}
+
+func (m *mockAddrSelectApi) WalletBalance(ctx context.Context, a address.Address) (types.BigInt, error) {
+	return m.balance[a], nil
+}
+
+func (m *mockAddrSelectApi) WalletHas(ctx context.Context, a address.Address) (bool, error) {
+	return true, nil
+}
+
+func (m *mockAddrSelectApi) StateAccountKey(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	return a, nil
+}
+
+func (m *mockAddrSelectApi) StateLookupID(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	return a, nil
+}
+
+func (m *mockAddrSelectApi) StateGetActor(ctx context.Context, a address.Address, tsk types.TipSetKey) (*types.Actor, error) {
+	return &types.Actor{Nonce: m.nonce[a]}, nil
+}
+
+func (m *mockAddrSelectApi) MpoolGetNonce(ctx context.Context, a address.Address) (uint64, error) {
+	return m.nonce[a] + m.pending[a], nil
+}
+
+func mkAddrs(t *testing.T, ids ...uint64) []address.Address {
+	var out []address.Address
+	for _, id := range ids {
+		a, err := address.NewIDAddress(id)
+		require.NoError(t, err)
+		out = append(out, a)
+	}
+	return out
+}
+
+func TestAddressRotation(t *testing.T) {
+	ctx := context.Background()
+	addrs := mkAddrs(t, 1000, 1001, 1002, 1003, 1004)
+	owner, worker, ctl := addrs[0], addrs[1], addrs[2:]
+
+	mi := miner.MinerInfo{
+		Owner:            owner,
+		Worker:           worker,
+		ControlAddresses: ctl,
+	}
+
+	funds := big.NewInt(100)
+	m := &mockAddrSelectApi{
+		balance: map[address.Address]abi.TokenAmount{},
+		nonce:   map[address.Address]uint64{},
+		pending: map[address.Address]uint64{},
+	}
+	for _, a := range addrs {
+		m.balance[a] = funds
+	}
+
+	pick := func(as *AddressSelector) address.Address {
+		a, _, err := as.AddressFor(ctx, m, mi, api.PoStAddr, funds, big.Zero())
+		require.NoError(t, err)
+		return a
+	}
+
+	t.Run("none", func(t *testing.T) {
+		as := &AddressSelector{}
+		for i := 0; i < 3; i++ {
+			require.Equal(t, ctl[0], pick(as))
+		}
+	})
+
+	t.Run("round-robin", func(t *testing.T) {
+		as := &AddressSelector{Rotation: RotateRoundRobin}
+		for i := 0; i < 6; i++ {
+			require.Equal(t, ctl[i%len(ctl)], pick(as))
+		}
+
+		// addresses without funds are skipped
+		m.balance[ctl[1]] = big.Zero()
+		defer func() { m.balance[ctl[1]] = funds }()
+
+		as = &AddressSelector{Rotation: RotateRoundRobin}
+		require.Equal(t, ctl[0], pick(as))
+		require.Equal(t, ctl[2], pick(as))
+		require.Equal(t, ctl[2], pick(as))
+	})
+
+	t.Run("lowest-backlog", func(t *testing.T) {
+		as := &AddressSelector{Rotation: RotateLowestBacklog}
+
+		m.nonce[ctl[0]] = 10
+		m.pending[ctl[0]] = 3
+		m.nonce[ctl[1]] = 50
+		m.pending[ctl[1]] = 0
+		m.nonce[ctl[2]] = 2
+		m.pending[ctl[2]] = 1
+		require.Equal(t, ctl[1], pick(as))
+
+		m.pending[ctl[1]] = 5
+		require.Equal(t, ctl[2], pick(as))
+	})
+}
+
+func TestParseAddressRotation(t *testing.T) {
+	for _, s := range []string{"", "round-robin", "lowest-backlog"} {
+		r, err := ParseAddressRotation(s)
+		require.NoError(t, err)
+		require.Equal(t, AddressRotation(s), r)
+	}
+
+	_, err := ParseAddressRotation("random")
+	require.Error(t, err)
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/filecoin-project/go-state-types/abi"
 
 	sealing "github.com/filecoin-project/lotus/extern/storage-sealing"
+	"github.com/filecoin-project/lotus/extern/storage-sealing/sealiface"
 )
 
 // TODO: refactor this to be direct somehow
@@ -48,6 +49,22 @@ func (m *Miner) MarkForUpgrade(id abi.SectorNumber) error {
 	return m.sealing.MarkForUpgrade(id)
 }
 
+func (m *Miner) PackingReport() sealiface.PackingReport {
+	return m.sealing.PackingReport()
+}
+
+func (m *Miner) PendingCommits() []sealiface.PendingCommit {
+	return m.sealing.PendingCommits()
+}
+
+func (m *Miner) ApproveCommit(ctx context.Context, id abi.SectorNumber) error {
+	return m.sealing.ApproveCommit(ctx, id)
+}
+
+func (m *Miner) RejectCommit(ctx context.Context, id abi.SectorNumber) error {
+	return m.sealing.RejectCommit(ctx, id)
+}
+
 func (m *Miner) IsMarkedForUpgrade(id abi.SectorNumber) bool {
 	return m.sealing.IsMarkedForUpgrade(id)
 }
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ipfs/go-cid"
+	"golang.org/x/xerrors"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+// MessageSigner signs the messages sealing sends from an address in place of
+// the full node wallet, e.g. with keys kept in an HSM or split across a
+// threshold signing service.
+type MessageSigner interface {
+	SignMessage(ctx context.Context, msg *types.Message) (*crypto.Signature, error)
+}
+
+// MessageSigners are the signers of the addresses sealing messages, such as
+// precommits and commits, are sent from. Messages from other addresses are
+// signed by the full node wallet. A nil MessageSigners has no signers.
+type MessageSigners struct {
+	// held while a message is signed and pushed, so that nonces are used in
+	// order
+	lk sync.Mutex
+
+	// by public key address
+	signers map[address.Address]MessageSigner
+}
+
+type signerApi interface {
+	StateAccountKey(context.Context, address.Address, types.TipSetKey) (address.Address, error)
+	MpoolGetNonce(context.Context, address.Address) (uint64, error)
+	MpoolPush(context.Context, *types.SignedMessage) (cid.Cid, error)
+	GasEstimateMessageGas(context.Context, *types.Message, *api.MessageSendSpec, types.TipSetKey) (*types.Message, error)
+}
+
+func NewMessageSigners(signers map[address.Address]MessageSigner) *MessageSigners {
+	return &MessageSigners{signers: signers}
+}
+
+// signerFor returns the signer of the address, or nil if messages from it are
+// signed by the wallet
+func (ms *MessageSigners) signerFor(ctx context.Context, a signerApi, from address.Address) (MessageSigner, error) {
+	if ms == nil || len(ms.signers) == 0 {
+		return nil, nil
+	}
+
+	key := from
+	if from.Protocol() == address.ID {
+		var err error
+		key, err = a.StateAccountKey(ctx, from, types.EmptyTSK)
+		if err != nil {
+			return nil, xerrors.Errorf("resolving key of %s: %w", from, err)
+		}
+	}
+	return ms.signers[key], nil
+}
+
+// push sets the nonce and gas of the message, has it signed by the signer,
+// and pushes it to the mpool
+func (ms *MessageSigners) push(ctx context.Context, a signerApi, signer MessageSigner, msg *types.Message, spec *api.MessageSendSpec) (*types.SignedMessage, error) {
+	ms.lk.Lock()
+	defer ms.lk.Unlock()
+
+	nonce, err := a.MpoolGetNonce(ctx, msg.From)
+	if err != nil {
+		return nil, xerrors.Errorf("getting nonce: %w", err)
+	}
+	msg.Nonce = nonce
+
+	msg, err = a.GasEstimateMessageGas(ctx, msg, spec, types.EmptyTSK)
+	if err != nil {
+		return nil, xerrors.Errorf("estimating gas: %w", err)
+	}
+
+	sig, err := signer.SignMessage(ctx, msg)
+	if err != nil {
+		return nil, xerrors.Errorf("signing message: %w", err)
+	}
+
+	smsg := &types.SignedMessage{Message: *msg, Signature: *sig}
+	if _, err := a.MpoolPush(ctx, smsg); err != nil {
+		return nil, xerrors.Errorf("pushing message: %w", err)
+	}
+	return smsg, nil
+}
+
+// RemoteSigner signs messages through an HTTP signing service. The service
+// is POSTed a remoteSignRequest, and responds with the signature as JSON.
+type RemoteSigner struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+// remoteSignRequest carries the message to sign, so that the service can
+// apply its policies, and the bytes to sign, the CID of the message
+type remoteSignRequest struct {
+	Message *types.Message
+	Data    []byte
+}
+
+// NewRemoteSigner creates a signer for the service at url; token, when set, is
+// sent as a bearer token
+func NewRemoteSigner(url, token string) *RemoteSigner {
+	return &RemoteSigner{
+		url:    url,
+		token:  token,
+		client: &http.Client{Timeout: time.Minute},
+	}
+}
+
+func (r *RemoteSigner) SignMessage(ctx context.Context, msg *types.Message) (*crypto.Signature, error) {
+	body, err := json.Marshal(&remoteSignRequest{
+		Message: msg,
+		Data:    msg.Cid().Bytes(),
+	})
+	if err != nil {
+		return nil, xerrors.Errorf("encoding sign request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, xerrors.Errorf("creating sign request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if r.token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.token)
+	}
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, xerrors.Errorf("requesting signature: %w", err)
+	}
+	defer resp.Body.Close() // nolint
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return nil, xerrors.Errorf("signing service returned status %d: %s", resp.StatusCode, string(msg))
+	}
+
+	var sig crypto.Signature
+	if err := json.NewDecoder(resp.Body).Decode(&sig); err != nil {
+		return nil, xerrors.Errorf("decoding signature: %w", err)
+	}
+	return &sig, nil
+}
+
+var _ MessageSigner = &RemoteSigner{}
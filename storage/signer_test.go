@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ipfs/go-cid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/filecoin-project/go-address"
+	"github.com/filecoin-project/go-state-types/big"
+	"github.com/filecoin-project/go-state-types/crypto"
+
+	"github.com/filecoin-project/lotus/api"
+	"github.com/filecoin-project/lotus/chain/types"
+)
+
+type mockSignerApi struct {
+	keys   map[address.Address]address.Address
+	nonce  uint64
+	pushed []*types.SignedMessage
+}
+
+func (m *mockSignerApi) StateAccountKey(ctx context.Context, a address.Address, tsk types.TipSetKey) (address.Address, error) {
+	return m.keys[a], nil
+}
+
+func (m *mockSignerApi) MpoolGetNonce(ctx context.Context, a address.Address) (uint64, error) {
+	return m.nonce, nil
+}
+
+func (m *mockSignerApi) MpoolPush(ctx context.Context, smsg *types.SignedMessage) (cid.Cid, error) {
+	m.pushed = append(m.pushed, smsg)
+	m.nonce++
+	return smsg.Cid(), nil
+}
+
+func (m *mockSignerApi) GasEstimateMessageGas(ctx context.Context, msg *types.Message, spec *api.MessageSendSpec, tsk types.TipSetKey) (*types.Message, error) {
+	msg.GasLimit = 1000
+	msg.GasFeeCap = big.NewInt(10)
+	msg.GasPremium = big.NewInt(1)
+	return msg, nil
+}
+
+func TestRemoteSigner(t *testing.T) {
+	ctx := context.Background()
+
+	var got remoteSignRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(401)
+			return
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+		require.NoError(t, json.NewEncoder(w).Encode(&crypto.Signature{Type: crypto.SigTypeSecp256k1, Data: []byte("sig")}))
+	}))
+	defer srv.Close()
+
+	key, err := address.NewSecp256k1Address([]byte("worker key"))
+	require.NoError(t, err)
+	id, err := address.NewIDAddress(1000)
+	require.NoError(t, err)
+	other, err := address.NewIDAddress(1001)
+	require.NoError(t, err)
+
+	a := &mockSignerApi{keys: map[address.Address]address.Address{id: key}, nonce: 5}
+	ms := NewMessageSigners(map[address.Address]MessageSigner{
+		key: NewRemoteSigner(srv.URL, "secret"),
+	})
+
+	signer, err := ms.signerFor(ctx, a, other)
+	require.NoError(t, err)
+	require.Nil(t, signer)
+
+	signer, err = ms.signerFor(ctx, a, id)
+	require.NoError(t, err)
+	require.NotNil(t, signer)
+
+	msg := &types.Message{From: id, To: other, Value: big.Zero(), Method: 6}
+	smsg, err := ms.push(ctx, a, signer, msg, &api.MessageSendSpec{})
+	require.NoError(t, err)
+
+	require.Len(t, a.pushed, 1)
+	require.Equal(t, uint64(5), smsg.Message.Nonce)
+	require.Equal(t, int64(1000), smsg.Message.GasLimit)
+	require.Equal(t, []byte("sig"), smsg.Signature.Data)
+	require.Equal(t, smsg.Message.Cid().Bytes(), got.Data)
+
+	_, err = NewRemoteSigner(srv.URL, "wrong").SignMessage(ctx, msg)
+	require.Error(t, err)
+
+	// no signers configured
+	var none *MessageSigners
+	signer, err = none.signerFor(ctx, a, id)
+	require.NoError(t, err)
+	require.Nil(t, signer)
+}
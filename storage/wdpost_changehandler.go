@@ -24,6 +24,7 @@ type changeHandlerAPI interface {
 	startSubmitPoST(ctx context.Context, ts *types.TipSet, deadline *dline.Info, posts []miner.SubmitWindowedPoStParams, onComplete CompleteSubmitPoSTCb) context.CancelFunc
 	onAbort(ts *types.TipSet, deadline *dline.Info)
 	failPost(err error, ts *types.TipSet, deadline *dline.Info)
+	generateStartEpoch(deadline *dline.Info) abi.ChainEpoch
 }
 
 type changeHandler struct {
@@ -229,8 +230,9 @@ func (p *proveHandler) processHeadChange(ctx context.Context, newTS *types.TipSe
 		_, complete = p.posts.get(di)
 	}
 
-	// Check if the chain is above the Challenge height for the post window
-	if newTS.Height() < di.Challenge {
+	// Check if the chain is above the Challenge height for the post window,
+	// and past the configured lead time
+	if newTS.Height() < p.api.generateStartEpoch(di) {
 		return
 	}
 
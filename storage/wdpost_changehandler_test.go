@@ -194,6 +194,10 @@ func (m *mockAPI) wasAbortCalled() bool {
 func (m *mockAPI) failPost(err error, ts *types.TipSet, deadline *dline.Info) {
 }
 
+func (m *mockAPI) generateStartEpoch(di *dline.Info) abi.ChainEpoch {
+	return di.Challenge
+}
+
 func (m *mockAPI) setChangeHandler(ch *changeHandler) {
 	m.ch = ch
 }
@@ -29,6 +29,7 @@ import (
 	"github.com/filecoin-project/lotus/chain/types"
 	"github.com/filecoin-project/lotus/extern/sector-storage/storiface"
 	"github.com/filecoin-project/lotus/journal"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
 )
 
 type mockStorageMinerAPI struct {
@@ -214,6 +215,47 @@ func TestWDPostDoPost(t *testing.T) {
 	}
 }
 
+// TestWDPostDeadlineConfig verifies that per-deadline proving overrides
+// apply to their deadline only
+func TestWDPostDeadlineConfig(t *testing.T) {
+	proofType := abi.RegisteredPoStProof_StackedDrgWindow2KiBV1
+	sectorsPerPartition, err := builtin2.PoStProofWindowPoStPartitionSectors(proofType)
+	require.NoError(t, err)
+	partitionsPerMsg := int(miner2.AddressedSectorsMax / sectorsPerPartition)
+
+	scheduler := &WindowPoStScheduler{
+		proofType: proofType,
+		getProvingCfg: func() (dtypes.ProvingConfig, error) {
+			return dtypes.ProvingConfig{
+				Default: dtypes.DeadlineProvingConfig{GenerateLeadEpochs: 5},
+				Deadlines: map[uint64]dtypes.DeadlineProvingConfig{
+					3: {MaxPartitionsPerMessage: 2},
+				},
+			}, nil
+		},
+	}
+
+	partitions := make([]api.Partition, partitionsPerMsg+1)
+
+	batches, err := scheduler.batchPartitions(partitions, 0)
+	require.NoError(t, err)
+	require.Len(t, batches, 2)
+
+	batches, err = scheduler.batchPartitions(partitions, 3)
+	require.NoError(t, err)
+	require.Len(t, batches, (partitionsPerMsg+2)/2)
+	require.Len(t, batches[0], 2)
+
+	di := NewDeadlineInfo(0, 3, 0)
+	require.Equal(t, di.Open-5, scheduler.generateStartEpoch(di))
+
+	// lead times beyond the challenge lookback can't start proving earlier
+	scheduler.getProvingCfg = func() (dtypes.ProvingConfig, error) {
+		return dtypes.ProvingConfig{Default: dtypes.DeadlineProvingConfig{GenerateLeadEpochs: 10000}}, nil
+	}
+	require.Equal(t, di.Challenge, scheduler.generateStartEpoch(di))
+}
+
 func mockTipSet(t *testing.T) *types.TipSet {
 	minerAct := tutils.NewActorAddr(t, "miner")
 	c, err := cid.Decode("QmbFMke1KXqnYyBBWxB74N4c5SBnJMVAiMNRcGu6x1AwQH")
@@ -18,6 +18,7 @@ import (
 	sectorstorage "github.com/filecoin-project/lotus/extern/sector-storage"
 	"github.com/filecoin-project/lotus/journal"
 	"github.com/filecoin-project/lotus/node/config"
+	"github.com/filecoin-project/lotus/node/modules/dtypes"
 
 	"go.opencensus.io/trace"
 )
@@ -30,6 +31,7 @@ type WindowPoStScheduler struct {
 	faultTracker     sectorstorage.FaultTracker
 	proofType        abi.RegisteredPoStProof
 	partitionSectors uint64
+	getProvingCfg    dtypes.GetProvingConfigFunc
 	ch               *changeHandler
 
 	actor address.Address
@@ -41,7 +43,7 @@ type WindowPoStScheduler struct {
 	// failLk sync.Mutex
 }
 
-func NewWindowedPoStScheduler(api storageMinerApi, fc config.MinerFeeConfig, as *AddressSelector, sb storage.Prover, ft sectorstorage.FaultTracker, j journal.Journal, actor address.Address) (*WindowPoStScheduler, error) {
+func NewWindowedPoStScheduler(api storageMinerApi, fc config.MinerFeeConfig, gpc dtypes.GetProvingConfigFunc, as *AddressSelector, sb storage.Prover, ft sectorstorage.FaultTracker, j journal.Journal, actor address.Address) (*WindowPoStScheduler, error) {
 	mi, err := api.StateMinerInfo(context.TODO(), actor, types.EmptyTSK)
 	if err != nil {
 		return nil, xerrors.Errorf("getting sector size: %w", err)
@@ -60,6 +62,7 @@ func NewWindowedPoStScheduler(api storageMinerApi, fc config.MinerFeeConfig, as
 		faultTracker:     ft,
 		proofType:        rt,
 		partitionSectors: mi.WindowPoStPartitionSectors,
+		getProvingCfg:    gpc,
 
 		actor: actor,
 		evtTypes: [...]journal.EventType{
@@ -72,6 +75,33 @@ func NewWindowedPoStScheduler(api storageMinerApi, fc config.MinerFeeConfig, as
 	}, nil
 }
 
+// deadlineConfig returns the proving configuration in effect for a deadline.
+// Errors reading the configuration are logged, and the defaults are used.
+func (s *WindowPoStScheduler) deadlineConfig(dlIdx uint64) dtypes.DeadlineProvingConfig {
+	if s.getProvingCfg == nil {
+		return dtypes.DeadlineProvingConfig{}
+	}
+
+	cfg, err := s.getProvingCfg()
+	if err != nil {
+		log.Warnf("reading proving config: %+v", err)
+		return dtypes.DeadlineProvingConfig{}
+	}
+
+	return cfg.ForDeadline(dlIdx)
+}
+
+// generateStartEpoch returns the epoch from which proof generation for the
+// deadline can start: the challenge epoch, or later if a lead time is
+// configured for the deadline.
+func (s *WindowPoStScheduler) generateStartEpoch(di *dline.Info) abi.ChainEpoch {
+	start := di.Challenge
+	if lead := s.deadlineConfig(di.Index).GenerateLeadEpochs; lead > 0 && di.Open-lead > start {
+		start = di.Open - lead
+	}
+	return start
+}
+
 type changeHandlerAPIImpl struct {
 	storageMinerApi
 	*WindowPoStScheduler